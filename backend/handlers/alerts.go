@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// Alert statuses reported in PodAlert.Status
+const (
+	alertOverProvisioned  = "over_provisioned"
+	alertUnderProvisioned = "under_provisioned"
+)
+
+// Alerter periodically runs the historical analysis and posts newly over- or
+// under-provisioned containers to a webhook
+type Alerter struct {
+	metricsClient k8s.MetricsClient
+	webhookURL    string
+	interval      time.Duration
+	cooldown      time.Duration
+	httpClient    *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // alert key -> last time it was posted
+}
+
+// NewAlerter creates an alerter posting to webhookURL every interval, suppressing repeats within cooldown
+func NewAlerter(metricsClient k8s.MetricsClient, webhookURL string, interval, cooldown time.Duration) *Alerter {
+	return &Alerter{
+		metricsClient: metricsClient,
+		webhookURL:    webhookURL,
+		interval:      interval,
+		cooldown:      cooldown,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		lastSent:      make(map[string]time.Time),
+	}
+}
+
+// Run checks for alerts immediately and then every interval until ctx is cancelled
+func (a *Alerter) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.check(ctx); err != nil {
+			log.Printf("Warning: alert check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check runs the analysis once and posts any alerts outside their cooldown
+func (a *Alerter) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	historicalData, err := a.metricsClient.GetHistoricalMetrics(ctx, "", k8s.HistoricalOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get historical metrics from %s: %w", a.metricsClient.GetClientType(), err)
+	}
+
+	now := time.Now()
+	alerts := a.collectAlerts(historicalData, now)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	if err := a.post(ctx, alerts); err != nil {
+		return err
+	}
+
+	// Only start the cooldown once the alert was delivered
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, alert := range alerts {
+		a.lastSent[alertKey(alert)] = now
+	}
+	return nil
+}
+
+// alertKey identifies an alert for deduplication
+func alertKey(alert models.PodAlert) string {
+	return alert.Namespace + "/" + alert.Pod + "/" + alert.Container + "/" + alert.Status
+}
+
+// collectAlerts returns alerts for over- or under-provisioned containers that were not sent within the cooldown
+func (a *Alerter) collectAlerts(historicalData []k8s.HistoricalMetrics, now time.Time) []models.PodAlert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var alerts []models.PodAlert
+	for _, hm := range historicalData {
+		waste := hm.Analysis.ResourceWaste
+		var status string
+		switch {
+		case waste.CPUOverProvisioned || waste.MemoryOverProvisioned:
+			status = alertOverProvisioned
+		case waste.CPUUnderProvisioned || waste.MemoryUnderProvisioned:
+			status = alertUnderProvisioned
+		default:
+			continue
+		}
+
+		alert := models.PodAlert{
+			Pod:              hm.PodName,
+			Namespace:        hm.Namespace,
+			Container:        hm.ContainerName,
+			Status:           status,
+			CPUEfficiency:    hm.Analysis.CPUEfficiency,
+			MemoryEfficiency: hm.Analysis.MemoryEfficiency,
+			Recommendations:  hm.Analysis.Recommendations,
+		}
+		if sent, ok := a.lastSent[alertKey(alert)]; ok && now.Sub(sent) < a.cooldown {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+
+	// Drop expired entries so the map doesn't grow with deleted pods
+	for key, sent := range a.lastSent {
+		if now.Sub(sent) >= a.cooldown {
+			delete(a.lastSent, key)
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Namespace != alerts[j].Namespace {
+			return alerts[i].Namespace < alerts[j].Namespace
+		}
+		if alerts[i].Pod != alerts[j].Pod {
+			return alerts[i].Pod < alerts[j].Pod
+		}
+		return alerts[i].Container < alerts[j].Container
+	})
+
+	return alerts
+}
+
+// post sends the alerts to the webhook
+func (a *Alerter) post(ctx context.Context, alerts []models.PodAlert) error {
+	var over, under int
+	var lines []string
+	for _, alert := range alerts {
+		if alert.Status == alertOverProvisioned {
+			over++
+		} else {
+			under++
+		}
+		lines = append(lines, fmt.Sprintf("• %s/%s (%s): %s - CPU %.1f%%, memory %.1f%%",
+			alert.Namespace, alert.Pod, alert.Container, strings.ReplaceAll(alert.Status, "_", "-"),
+			alert.CPUEfficiency, alert.MemoryEfficiency))
+	}
+
+	payload := models.AlertPayload{
+		Text:        fmt.Sprintf("%d over-provisioned, %d under-provisioned containers\n%s", over, under, strings.Join(lines, "\n")),
+		Alerts:      alerts,
+		GeneratedAt: time.Now(),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alerts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("INFO: Posted %d alerts to webhook", len(alerts))
+	return nil
+}
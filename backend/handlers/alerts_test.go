@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// webhookRecorder is a mock webhook recording the bodies posted to it
+type webhookRecorder struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	status int
+}
+
+func (wr *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.bodies = append(wr.bodies, body)
+	if wr.status != 0 {
+		w.WriteHeader(wr.status)
+	}
+}
+
+// posts returns the number of posted bodies
+func (wr *webhookRecorder) posts() int {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return len(wr.bodies)
+}
+
+// analyzedContainer returns the historical metrics of a container with the given waste analysis
+func analyzedContainer(namespace, pod string, waste k8s.ResourceWasteAnalysis, cpuEfficiency float64, recommendations ...string) k8s.HistoricalMetrics {
+	hm := k8s.HistoricalMetrics{PodName: pod, Namespace: namespace, ContainerName: "app"}
+	hm.Analysis.ResourceWaste = waste
+	hm.Analysis.CPUEfficiency = cpuEfficiency
+	hm.Analysis.MemoryEfficiency = 50
+	hm.Analysis.Recommendations = recommendations
+	return hm
+}
+
+var alertTestData = []k8s.HistoricalMetrics{
+	analyzedContainer("shop", "web-0", k8s.ResourceWasteAnalysis{CPUOverProvisioned: true}, 10, "Consider reducing CPU requests - current efficiency: 10.0%"),
+	analyzedContainer("billing", "api-0", k8s.ResourceWasteAnalysis{MemoryUnderProvisioned: true}, 50, "Consider increasing memory requests - current efficiency: 95.0%"),
+	analyzedContainer("shop", "cache-0", k8s.ResourceWasteAnalysis{}, 50, "Resource usage appears well-optimized"),
+}
+
+func TestAlerterPayload(t *testing.T) {
+	webhook := &webhookRecorder{}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	alerter := NewAlerter(&fakeMetricsClient{historical: alertTestData}, server.URL, time.Hour, time.Hour)
+	if err := alerter.check(context.Background()); err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if webhook.posts() != 1 {
+		t.Fatalf("got %d webhook posts, want 1", webhook.posts())
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(webhook.bodies[0], &raw); err != nil {
+		t.Fatalf("failed to decode payload %s: %v", webhook.bodies[0], err)
+	}
+	for _, field := range []string{"text", "alerts", "generatedAt"} {
+		if _, ok := raw[field]; !ok {
+			t.Errorf("payload %s is missing %s", webhook.bodies[0], field)
+		}
+	}
+
+	var payload models.AlertPayload
+	if err := json.Unmarshal(webhook.bodies[0], &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	want := []models.PodAlert{
+		{Pod: "api-0", Namespace: "billing", Container: "app", Status: alertUnderProvisioned, CPUEfficiency: 50, MemoryEfficiency: 50,
+			Recommendations: []string{"Consider increasing memory requests - current efficiency: 95.0%"}},
+		{Pod: "web-0", Namespace: "shop", Container: "app", Status: alertOverProvisioned, CPUEfficiency: 10, MemoryEfficiency: 50,
+			Recommendations: []string{"Consider reducing CPU requests - current efficiency: 10.0%"}},
+	}
+	if !reflect.DeepEqual(payload.Alerts, want) {
+		t.Errorf("alerts = %+v, want %+v", payload.Alerts, want)
+	}
+	for _, line := range []string{"1 over-provisioned, 1 under-provisioned containers", "shop/web-0 (app): over-provisioned - CPU 10.0%, memory 50.0%"} {
+		if !strings.Contains(payload.Text, line) {
+			t.Errorf("text %q does not contain %q", payload.Text, line)
+		}
+	}
+}
+
+func TestAlerterCooldown(t *testing.T) {
+	tests := []struct {
+		name          string
+		webhookStatus int
+		cooldown      time.Duration
+		wantPosts     int
+	}{
+		{"repeats suppressed within the cooldown", http.StatusOK, time.Hour, 1},
+		{"repeats sent after the cooldown", http.StatusOK, 0, 2},
+		{"failed deliveries are retried", http.StatusInternalServerError, time.Hour, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := &webhookRecorder{status: tt.webhookStatus}
+			server := httptest.NewServer(webhook)
+			defer server.Close()
+
+			alerter := NewAlerter(&fakeMetricsClient{historical: alertTestData}, server.URL, time.Hour, tt.cooldown)
+			for i := 0; i < 2; i++ {
+				err := alerter.check(context.Background())
+				if (err != nil) != (tt.webhookStatus != http.StatusOK) {
+					t.Fatalf("check() error = %v with webhook status %d", err, tt.webhookStatus)
+				}
+			}
+			if got := webhook.posts(); got != tt.wantPosts {
+				t.Errorf("got %d webhook posts, want %d", got, tt.wantPosts)
+			}
+		})
+	}
+}
+
+func TestCollectAlertsCooldown(t *testing.T) {
+	alerter := NewAlerter(&fakeMetricsClient{}, "", time.Hour, 30*time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	alerter.lastSent[alertKey(models.PodAlert{Pod: "web-0", Namespace: "shop", Container: "app", Status: alertOverProvisioned})] = now.Add(-10 * time.Minute)
+	alerter.lastSent[alertKey(models.PodAlert{Pod: "api-0", Namespace: "billing", Container: "app", Status: alertUnderProvisioned})] = now.Add(-time.Hour)
+	alerter.lastSent["deleted/pod-0/app/over_provisioned"] = now.Add(-time.Hour)
+
+	alerts := alerter.collectAlerts(alertTestData, now)
+	if len(alerts) != 1 || alerts[0].Pod != "api-0" {
+		t.Errorf("alerts = %+v, want only the expired api-0 alert", alerts)
+	}
+	if _, ok := alerter.lastSent["deleted/pod-0/app/over_provisioned"]; ok {
+		t.Error("expired cooldown entry was not dropped")
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+// namespaceAllowlist restricts the namespaces served, a coarse tenancy control for shared dashboards.
+// A nil allowlist allows every namespace.
+type namespaceAllowlist struct {
+	names   map[string]bool
+	pattern *regexp.Regexp // Used instead of names when the allowlist is a regular expression
+}
+
+// parseNamespaceAllowlist parses NAMESPACE_ALLOWLIST: a comma-separated list of namespace names, or
+// otherwise a regular expression matched against whole namespace names. Empty allows every namespace.
+func parseNamespaceAllowlist(value string) (*namespaceAllowlist, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	allowlist := &namespaceAllowlist{names: make(map[string]bool)}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !namespacePattern.MatchString(name) {
+			allowlist.names = nil
+			break
+		}
+		allowlist.names[name] = true
+	}
+	if allowlist.names != nil {
+		return allowlist, nil
+	}
+
+	pattern, err := regexp.Compile(`^(?:` + value + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAMESPACE_ALLOWLIST %q: %w", value, err)
+	}
+	allowlist.pattern = pattern
+	return allowlist, nil
+}
+
+// allows reports whether namespace may be served
+func (a *namespaceAllowlist) allows(namespace string) bool {
+	if a == nil {
+		return true
+	}
+	if a.pattern != nil {
+		return a.pattern.MatchString(namespace)
+	}
+	return a.names[namespace]
+}
+
+// allowsPodKey reports whether the namespace of a namespace/pod key may be served
+func (a *namespaceAllowlist) allowsPodKey(key string) bool {
+	namespace, _, _ := strings.Cut(key, "/")
+	return a.allows(namespace)
+}
+
+// namespacesRestricted reports whether requests may be restricted to some namespaces, by the
+// allowlist or by authentication
+func (h *Handler) namespacesRestricted() bool {
+	return h.namespaces != nil || h.auth != nil
+}
+
+// checkNamespaces rejects requests for a namespace outside the allowlist or the namespaces granted
+// to the user with 403, whether given with the namespace parameter or in the path
+func (h *Handler) checkNamespaces(w http.ResponseWriter, r *http.Request) bool {
+	scope, _ := authScopeFrom(r)
+	for _, namespace := range []string{namespaceParam(r), r.PathValue("namespace")} {
+		if namespace != "" && (!h.namespaces.allows(namespace) || (h.auth != nil && !scope.namespaces.allows(namespace))) {
+			http.Error(w, fmt.Sprintf("Access to namespace %s is not allowed", namespace), http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// scopeNamespaces wraps a metrics client so its results only include allowlisted namespaces.
+// The client is returned as is without an allowlist.
+func scopeNamespaces(metricsClient k8s.MetricsClient, allowlist *namespaceAllowlist) k8s.MetricsClient {
+	if metricsClient == nil || allowlist == nil {
+		return metricsClient
+	}
+	return &namespaceScopedClient{MetricsClient: metricsClient, allowlist: allowlist}
+}
+
+// unscoped returns the client wrapped by scopeNamespaces, to check for optional client interfaces
+func unscoped(metricsClient k8s.MetricsClient) k8s.MetricsClient {
+	for {
+		scoped, ok := metricsClient.(*namespaceScopedClient)
+		if !ok {
+			return metricsClient
+		}
+		metricsClient = scoped.MetricsClient
+	}
+}
+
+// namespaceScopedClient filters the results of a metrics client to the namespaces of an allowlist
+type namespaceScopedClient struct {
+	k8s.MetricsClient
+	allowlist *namespaceAllowlist
+}
+
+func (c *namespaceScopedClient) GetNamespaces(ctx context.Context) ([]string, error) {
+	namespaces, err := c.MetricsClient.GetNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allowed := []string{}
+	for _, namespace := range namespaces {
+		if c.allowlist.allows(namespace) {
+			allowed = append(allowed, namespace)
+		}
+	}
+	return allowed, nil
+}
+
+func (c *namespaceScopedClient) GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]k8s.PodMetric, error) {
+	metrics, err := c.MetricsClient.GetCurrentPodMetrics(ctx, namespace, selector, at)
+	if err != nil {
+		return nil, err
+	}
+	return c.filterPodMetrics(metrics), nil
+}
+
+// filterPodMetrics returns the metrics of allowlisted namespaces
+func (c *namespaceScopedClient) filterPodMetrics(metrics []k8s.PodMetric) []k8s.PodMetric {
+	allowed := []k8s.PodMetric{}
+	for _, metric := range metrics {
+		if c.allowlist.allows(metric.Namespace) {
+			allowed = append(allowed, metric)
+		}
+	}
+	return allowed
+}
+
+// scopePodMetrics filters metrics read through an optional interface of the unscoped client to the
+// namespaces of every allowlist metricsClient is scoped to
+func scopePodMetrics(metricsClient k8s.MetricsClient, metrics []k8s.PodMetric) []k8s.PodMetric {
+	for {
+		scoped, ok := metricsClient.(*namespaceScopedClient)
+		if !ok {
+			return metrics
+		}
+		metrics = scoped.filterPodMetrics(metrics)
+		metricsClient = scoped.MetricsClient
+	}
+}
+
+func (c *namespaceScopedClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts k8s.HistoricalOptions) ([]k8s.HistoricalMetrics, error) {
+	metrics, err := c.MetricsClient.GetHistoricalMetrics(ctx, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+	allowed := []k8s.HistoricalMetrics{}
+	for _, metric := range metrics {
+		if c.allowlist.allows(metric.Namespace) {
+			allowed = append(allowed, metric)
+		}
+	}
+	return allowed, nil
+}
+
+func (c *namespaceScopedClient) StreamHistoricalMetrics(ctx context.Context, namespace string, opts k8s.HistoricalOptions, fn func(k8s.HistoricalMetrics) error) error {
+	return c.MetricsClient.StreamHistoricalMetrics(ctx, namespace, opts, func(metric k8s.HistoricalMetrics) error {
+		if !c.allowlist.allows(metric.Namespace) {
+			return nil
+		}
+		return fn(metric)
+	})
+}
+
+func (c *namespaceScopedClient) GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]k8s.PodInfo, error) {
+	pods, err := c.MetricsClient.GetActivePods(ctx, namespace, window)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []k8s.PodInfo
+	for _, pod := range pods {
+		if c.allowlist.allows(pod.Namespace) {
+			allowed = append(allowed, pod)
+		}
+	}
+	return allowed, nil
+}
+
+func (c *namespaceScopedClient) GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error) {
+	podNodes, err := c.MetricsClient.GetPodNodes(ctx, namespace, at)
+	if err != nil {
+		return nil, err
+	}
+	for key := range podNodes {
+		if !c.allowlist.allowsPodKey(key) {
+			delete(podNodes, key)
+		}
+	}
+	return podNodes, nil
+}
+
+func (c *namespaceScopedClient) GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error) {
+	podLabels, err := c.MetricsClient.GetPodLabels(ctx, namespace, at)
+	if err != nil {
+		return nil, err
+	}
+	for key := range podLabels {
+		if !c.allowlist.allowsPodKey(key) {
+			delete(podLabels, key)
+		}
+	}
+	return podLabels, nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestParseNamespaceAllowlist(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantNil     bool
+		wantPattern bool
+		allowed     []string
+		denied      []string
+		wantErr     bool
+	}{
+		{name: "empty", value: "", wantNil: true},
+		{name: "blank", value: "  ", wantNil: true},
+		{name: "single name", value: "shop", allowed: []string{"shop"}, denied: []string{"shop-staging", "billing"}},
+		{name: "list", value: "shop, billing", allowed: []string{"shop", "billing"}, denied: []string{"kube-system", "shop,billing"}},
+		{name: "regex", value: "team-.*", wantPattern: true, allowed: []string{"team-a", "team-payments"}, denied: []string{"xteam-a", "shop"}},
+		{name: "regex alternation", value: "shop|billing-.+", wantPattern: true, allowed: []string{"shop", "billing-eu"}, denied: []string{"billing-", "shop-eu"}},
+		{name: "invalid regex", value: "team-(", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowlist, err := parseNamespaceAllowlist(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNamespaceAllowlist(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (allowlist == nil) != tt.wantNil {
+				t.Fatalf("parseNamespaceAllowlist(%q) = %+v, want nil %v", tt.value, allowlist, tt.wantNil)
+			}
+			if tt.wantNil {
+				// A nil allowlist allows every namespace
+				if !allowlist.allows("anything") {
+					t.Error("nil allowlist denies a namespace")
+				}
+				return
+			}
+			if (allowlist.pattern != nil) != tt.wantPattern {
+				t.Errorf("parseNamespaceAllowlist(%q) pattern = %v, want a pattern %v", tt.value, allowlist.pattern, tt.wantPattern)
+			}
+			for _, namespace := range tt.allowed {
+				if !allowlist.allows(namespace) {
+					t.Errorf("allows(%q) = false, want true", namespace)
+				}
+			}
+			for _, namespace := range tt.denied {
+				if allowlist.allows(namespace) {
+					t.Errorf("allows(%q) = true, want false", namespace)
+				}
+			}
+		})
+	}
+}
+
+// newAllowlistHandler returns a Handler serving pods in the shop and billing namespaces, with only
+// shop allowlisted
+func newAllowlistHandler(t *testing.T) *Handler {
+	t.Helper()
+	allowlist, err := parseNamespaceAllowlist("shop")
+	if err != nil {
+		t.Fatalf("parseNamespaceAllowlist() error = %v", err)
+	}
+	client := &fakeMetricsClient{
+		namespaces: []string{"billing", "shop"},
+		current: []k8s.PodMetric{
+			{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.1, MemoryUsage: 100 << 20},
+			{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 0.2, MemoryUsage: 200 << 20},
+		},
+		historical: []k8s.HistoricalMetrics{
+			replica("shop", "web-0", []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+			replica("billing", "api-0", []float64{0.2, 0.3}, []float64{200 << 20, 210 << 20}),
+		},
+	}
+	h := newTestHandler(scopeNamespaces(client, allowlist))
+	h.namespaces = allowlist
+	return h
+}
+
+func TestNamespaceAllowlistFiltersResults(t *testing.T) {
+	h := newAllowlistHandler(t)
+
+	var namespaces models.NamespaceList
+	decodeResponse(t, serve(h.GetNamespaces, "/api/namespaces"), &namespaces)
+	if want := []string{"shop"}; !reflect.DeepEqual(namespaces.Namespaces, want) {
+		t.Errorf("namespaces = %v, want %v", namespaces.Namespaces, want)
+	}
+
+	var pods models.PodMetricsList
+	decodeResponse(t, serve(h.GetPodMetrics, "/api/pods"), &pods)
+	if len(pods.Pods) != 1 || pods.Pods[0].Namespace != "shop" {
+		t.Errorf("pods = %+v, want only the shop pod", pods.Pods)
+	}
+
+	var analysis models.HistoricalAnalysisList
+	decodeResponse(t, serve(h.GetHistoricalAnalysis, "/api/pods/analysis"), &analysis)
+	if len(analysis.HistoricalMetrics) != 1 || analysis.HistoricalMetrics[0].Namespace != "shop" {
+		t.Errorf("analysis = %+v, want only the shop pod", analysis.HistoricalMetrics)
+	}
+	if analysis.TotalCount != 1 {
+		t.Errorf("analysis total count = %d, want 1", analysis.TotalCount)
+	}
+}
+
+func TestNamespaceAllowlistForbidden(t *testing.T) {
+	h := newAllowlistHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/pods", h.GetPodMetrics)
+	mux.HandleFunc("/api/pods/analysis", h.GetHistoricalAnalysis)
+	mux.HandleFunc("/api/pods/{namespace}/{pod}", h.GetPodDetail)
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+	}{
+		{"allowed namespace parameter", "/api/pods?namespace=shop", http.StatusOK},
+		{"denied namespace parameter", "/api/pods?namespace=billing", http.StatusForbidden},
+		{"denied analysis namespace parameter", "/api/pods/analysis?namespace=billing", http.StatusForbidden},
+		{"allowed namespace path", "/api/pods/shop/web-0", http.StatusOK},
+		{"denied namespace path", "/api/pods/billing/api-0", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ParseRequestParams(mux).ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("GET %s = %d, want %d: %s", tt.target, rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"golang.org/x/sync/singleflight"
+)
+
+// analysisRevalidateTimeout bounds a background refresh of a stale historical analysis
+const analysisRevalidateTimeout = 30 * time.Second
+
+// defaultAnalysisCacheStale is how long a historical analysis is served past its fresh window by default
+const defaultAnalysisCacheStale = 10 * time.Minute
+
+// Analysis cache states, reported in the X-Analysis-Cache response header
+const (
+	analysisCacheHit   = "hit"   // Served from a fresh cached result
+	analysisCacheStale = "stale" // Served from a stale cached result while it is refreshed in the background
+	analysisCacheMiss  = "miss"  // Computed for this request
+)
+
+// analysisResult is the historical analysis of one namespace with the backend warnings of the run
+type analysisResult struct {
+	metrics  []k8s.HistoricalMetrics
+	warnings []string
+}
+
+// analysisCacheEntry is a cached historical analysis and whether it is being refreshed
+type analysisCacheEntry struct {
+	result     analysisResult
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// analysisCache caches historical analysis results with stale-while-revalidate: results are served
+// as is for the fresh window, then served for the stale window while a background refresh replaces
+// them, so requests only wait for the backend once results are older than both windows
+type analysisCache struct {
+	fresh time.Duration
+	stale time.Duration
+
+	mu      sync.Mutex
+	entries *lruCache[*analysisCacheEntry]
+	// Coalesces concurrent computations of the same missing result
+	flight singleflight.Group
+}
+
+// newAnalysisCache creates an analysis cache holding up to maxEntries results, or nil when fresh is not positive
+func newAnalysisCache(fresh, stale time.Duration, maxEntries int) *analysisCache {
+	if fresh <= 0 {
+		return nil
+	}
+	return &analysisCache{
+		fresh:   fresh,
+		stale:   stale,
+		entries: newLRUCache[*analysisCacheEntry](maxEntries),
+	}
+}
+
+// get returns the result of key and its cache state. Fresh results are returned as is, stale results
+// are returned while fetch refreshes them in the background, and otherwise fetch computes the result.
+// fetch runs detached from ctx's cancellation, keeping its values. The result is shared, so callers
+// must not modify it.
+func (c *analysisCache) get(ctx context.Context, key string, fetch func(context.Context) (analysisResult, error)) (analysisResult, string, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries.get(key); ok {
+		age := time.Since(entry.fetchedAt)
+		if age <= c.fresh {
+			c.mu.Unlock()
+			return entry.result, analysisCacheHit, nil
+		}
+		if age <= c.fresh+c.stale {
+			if !entry.refreshing {
+				entry.refreshing = true
+				go c.revalidate(context.WithoutCancel(ctx), key, fetch)
+			}
+			c.mu.Unlock()
+			return entry.result, analysisCacheStale, nil
+		}
+	}
+	c.mu.Unlock()
+
+	result, err := coalesce(ctx, &c.flight, key, func() (analysisResult, error) {
+		// Detach from the first caller so its cancellation doesn't fail the others
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), analysisRevalidateTimeout)
+		defer cancel()
+		result, err := fetch(fetchCtx)
+		if err == nil {
+			c.store(key, result)
+		}
+		return result, err
+	})
+	return result, analysisCacheMiss, err
+}
+
+// revalidate refreshes the stale result of key, keeping it when the refresh fails
+func (c *analysisCache) revalidate(ctx context.Context, key string, fetch func(context.Context) (analysisResult, error)) {
+	ctx, cancel := context.WithTimeout(ctx, analysisRevalidateTimeout)
+	defer cancel()
+
+	result, err := fetch(ctx)
+	if err != nil {
+		log.Printf("Error refreshing cached historical analysis: %v", err)
+		c.mu.Lock()
+		if entry, ok := c.entries.get(key); ok {
+			entry.refreshing = false
+		}
+		c.mu.Unlock()
+		return
+	}
+	c.store(key, result)
+}
+
+// store replaces the cached result of key
+func (c *analysisCache) store(key string, result analysisResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.put(key, &analysisCacheEntry{result: result, fetchedAt: time.Now()})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// versionedClient returns a single pod named after the current version of its data, and holds
+// analyses back while gate is open
+type versionedClient struct {
+	*fakeMetricsClient
+
+	mu      sync.Mutex
+	version int
+	calls   int
+	gate    chan struct{}
+}
+
+func (c *versionedClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts k8s.HistoricalOptions) ([]k8s.HistoricalMetrics, error) {
+	c.mu.Lock()
+	c.calls++
+	version, gate := c.version, c.gate
+	c.mu.Unlock()
+
+	if gate != nil {
+		select {
+		case <-gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return []k8s.HistoricalMetrics{
+		replica("shop", fmt.Sprintf("web-v%d", version), []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+	}, nil
+}
+
+// update changes the data version, holding the next analyses back until the returned release is called
+func (c *versionedClient) update(version int) (release func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = version
+	c.gate = make(chan struct{})
+	gate := c.gate
+	return func() {
+		c.mu.Lock()
+		c.gate = nil
+		c.mu.Unlock()
+		close(gate)
+	}
+}
+
+func (c *versionedClient) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// waitFor fails the test unless done reports true within a second
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAnalysisCacheStaleWhileRevalidate(t *testing.T) {
+	const fresh = 100 * time.Millisecond
+	client := &versionedClient{fakeMetricsClient: &fakeMetricsClient{}, version: 1}
+	h := newTestHandler(client)
+	h.analysisCache = newAnalysisCache(fresh, time.Hour, defaultCacheMaxEntries)
+
+	// get returns the analyzed pod and the cache state of a request
+	get := func() (string, string) {
+		t.Helper()
+		rec := serve(h.GetHistoricalAnalysis, "/api/pods/analysis")
+		var response models.HistoricalAnalysisList
+		decodeResponse(t, rec, &response)
+		if len(response.HistoricalMetrics) != 1 {
+			t.Fatalf("got %d pods, want 1", len(response.HistoricalMetrics))
+		}
+		return response.HistoricalMetrics[0].PodName, rec.Header().Get("X-Analysis-Cache")
+	}
+
+	if pod, state := get(); pod != "web-v1" || state != analysisCacheMiss {
+		t.Fatalf("first request = %s (%s), want web-v1 (miss)", pod, state)
+	}
+	if pod, state := get(); pod != "web-v1" || state != analysisCacheHit {
+		t.Fatalf("fresh request = %s (%s), want web-v1 (hit)", pod, state)
+	}
+
+	// Once stale, the old result is served without waiting while a single refresh runs
+	release := client.update(2)
+	time.Sleep(fresh + 20*time.Millisecond)
+	if pod, state := get(); pod != "web-v1" || state != analysisCacheStale {
+		t.Fatalf("stale request = %s (%s), want web-v1 (stale)", pod, state)
+	}
+	waitFor(t, func() bool { return client.callCount() == 2 })
+	for i := range 3 {
+		if pod, state := get(); pod != "web-v1" || state != analysisCacheStale {
+			t.Fatalf("stale request %d during revalidation = %s (%s), want web-v1 (stale)", i, pod, state)
+		}
+	}
+	if got := client.callCount(); got != 2 {
+		t.Errorf("analyses = %d, want 2 with a single revalidation", got)
+	}
+
+	// The refreshed result replaces the stale one
+	release()
+	deadline := time.Now().Add(time.Second)
+	for {
+		pod, state := get()
+		if pod == "web-v2" {
+			if state != analysisCacheHit {
+				t.Errorf("refreshed request = %s (%s), want web-v2 (hit)", pod, state)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("still served %s (%s) after the refresh", pod, state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Hashes of the supported JWT algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultNamespacesClaim is the JWT claim listing the namespaces a user may access
+	defaultNamespacesClaim = "namespaces"
+	// defaultJWKSRefreshInterval is how long fetched signing keys are used before being refetched
+	defaultJWKSRefreshInterval = time.Hour
+	// jwksMinRefetchInterval limits refetches triggered by tokens signed with an unknown key
+	jwksMinRefetchInterval = time.Minute
+	// jwksFetchTimeout bounds a JWKS request
+	jwksFetchTimeout = 10 * time.Second
+	// jwtLeeway tolerates clock skew with the token issuer
+	jwtLeeway = time.Minute
+)
+
+// authenticator validates bearer JWTs against the signing keys of a JWKS endpoint
+type authenticator struct {
+	jwksURL         string
+	issuer          string // Required iss claim, unchecked when empty
+	audience        string // Required aud claim entry, unchecked when empty
+	namespacesClaim string
+	refreshInterval time.Duration
+	httpClient      *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // Signing keys by key ID
+	fetchedAt time.Time
+	// Coalesces concurrent JWKS fetches, which run without holding mu
+	fetches singleflight.Group
+}
+
+// newAuthenticator creates an authenticator fetching its keys from jwksURL on first use
+func newAuthenticator(jwksURL, issuer, audience, namespacesClaim string, refreshInterval time.Duration) *authenticator {
+	return &authenticator{
+		jwksURL:         jwksURL,
+		issuer:          issuer,
+		audience:        audience,
+		namespacesClaim: namespacesClaim,
+		refreshInterval: refreshInterval,
+		httpClient:      &http.Client{Timeout: jwksFetchTimeout},
+	}
+}
+
+// authScope holds the namespaces an authenticated request may access
+type authScope struct {
+	namespaces *namespaceAllowlist
+	key        string // Identifies the scope, shared by users granted the same namespaces
+}
+
+type authScopeKey struct{}
+
+// authScopeFrom returns the scope stored by Authenticate, false for unauthenticated requests
+func authScopeFrom(r *http.Request) (authScope, bool) {
+	scope, ok := r.Context().Value(authScopeKey{}).(authScope)
+	return scope, ok
+}
+
+// Authenticate is a middleware that requires a valid bearer JWT when ENABLE_AUTH is set, and scopes
+// the request to the namespaces granted by the token. Health and readiness probes are exempt.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.auth == nil || r.URL.Path == "/health" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := h.auth.verify(r.Context(), token, time.Now())
+		if err != nil {
+			log.Printf("WARN: Rejected bearer token: %v", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		scope := h.auth.scope(claims)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authScopeKey{}, scope)))
+	})
+}
+
+// scope returns the namespaces granted by the namespaces claim, either a list of names or a string
+// of comma- or space-separated names. Invalid names are ignored.
+func (a *authenticator) scope(claims map[string]any) authScope {
+	var values []string
+	switch claim := claims[a.namespacesClaim].(type) {
+	case string:
+		values = strings.FieldsFunc(claim, func(r rune) bool { return r == ',' || r == ' ' })
+	case []any:
+		for _, value := range claim {
+			if name, ok := value.(string); ok {
+				values = append(values, name)
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, name := range values {
+		if namespacePattern.MatchString(name) {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return authScope{namespaces: &namespaceAllowlist{names: names}, key: "auth:" + strings.Join(sorted, ",")}
+}
+
+// verify checks the signature, expiry, issuer and audience of a JWT and returns its claims
+func (a *authenticator) verify(ctx context.Context, token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	key, err := a.key(ctx, header.Kid, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("missing exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return nil, fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if a.audience != "" && !hasAudience(claims["aud"], a.audience) {
+		return nil, fmt.Errorf("token not issued for audience %s", a.audience)
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON token segment
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether the aud claim, a string or a list of strings, includes audience
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwtHashes maps the supported JWS algorithms to their hash
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// jwtCurves maps the ECDSA JWS algorithms to the curve their keys must use
+var jwtCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verifySignature checks a JWS signature with an RSA or ECDSA public key
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %q does not match the RSA signing key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %q does not match the ECDSA signing key", alg)
+		}
+		if key.Curve != jwtCurves[alg] {
+			return fmt.Errorf("algorithm %q does not match the %s curve of the signing key", alg, key.Curve.Params().Name)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported signing key type %T", key)
+	}
+	return nil
+}
+
+// key returns the signing key with the given ID, fetching the JWKS when the keys are stale or the
+// ID is unknown. Tokens without a key ID are accepted when the JWKS holds a single key. Stale keys
+// keep being used while they are refreshed in the background, so only tokens signed with an unknown
+// key wait for the JWKS endpoint.
+func (a *authenticator) key(ctx context.Context, kid string, now time.Time) (crypto.PublicKey, error) {
+	a.mu.Lock()
+	key, found := a.lookup(kid)
+	sinceFetch := now.Sub(a.fetchedAt)
+	a.mu.Unlock()
+
+	switch {
+	case found && sinceFetch > a.refreshInterval:
+		// The result is delivered on a buffered channel, so nothing waits for it
+		a.fetches.DoChan("", func() (any, error) {
+			return nil, a.refreshKeys(now)
+		})
+	case !found && sinceFetch > jwksMinRefetchInterval:
+		if _, err := coalesce(ctx, &a.fetches, "", func() (any, error) {
+			return nil, a.refreshKeys(now)
+		}); err != nil {
+			return nil, err
+		}
+		a.mu.Lock()
+		key, found = a.lookup(kid)
+		a.mu.Unlock()
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup returns the cached signing key with the given ID, or the only key when kid is empty.
+// a.mu must be held.
+func (a *authenticator) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+// refreshKeys fetches the JWKS and swaps in its keys, keeping the cached keys when the fetch fails.
+// The fetch is shared by concurrent callers, so it is bounded by the client timeout rather than
+// the context of any single request.
+func (a *authenticator) refreshKeys(now time.Time) error {
+	keys, err := a.fetchKeys(context.Background())
+	if err != nil {
+		log.Printf("WARN: Failed to refresh JWKS, using cached keys: %v", err)
+		return err
+	}
+	a.mu.Lock()
+	a.keys, a.fetchedAt = keys, now
+	a.mu.Unlock()
+	return nil
+}
+
+// jsonWebKey is an RSA or EC public key of a JWKS
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys fetches the signing keys of the JWKS endpoint by key ID, skipping unsupported keys
+func (a *authenticator) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.jwksURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("WARN: Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey decodes the RSA or EC public key of a JWK
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, errors.New("invalid key parameter")
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJWKS serves the public keys of signers as a JWKS, counting the requests
+type testJWKS struct {
+	server   *httptest.Server
+	requests atomic.Int32
+	delay    atomic.Int64 // Response delay in nanoseconds
+}
+
+func newTestJWKS(t *testing.T, keys map[string]crypto.Signer) *testJWKS {
+	t.Helper()
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, signer := range keys {
+		switch key := signer.Public().(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			jwks.Keys = append(jwks.Keys, jsonWebKey{
+				Kty: "EC",
+				Kid: kid,
+				Crv: key.Curve.Params().Name,
+				X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+				Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+			})
+		}
+	}
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &testJWKS{}
+	j.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		j.requests.Add(1)
+		time.Sleep(time.Duration(j.delay.Load()))
+		w.Write(body)
+	}))
+	t.Cleanup(j.server.Close)
+	return j
+}
+
+// signToken returns a JWT with claims signed by signer with alg
+func signToken(t *testing.T, alg, kid string, signer crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := jwtHashes[alg]
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	var signature []byte
+	switch key := signer.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthenticatorVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := newTestJWKS(t, map[string]crypto.Signer{"rsa": rsaKey, "p256": p256Key, "p384": p384Key})
+
+	now := time.Unix(1_700_000_000, 0)
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":        "https://issuer.example",
+			"aud":        []any{"dashboard"},
+			"exp":        now.Add(time.Hour).Unix(),
+			"namespaces": []any{"team-a"},
+		}
+		for name, value := range overrides {
+			c[name] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"RS256", signToken(t, "RS256", "rsa", rsaKey, claims(nil)), ""},
+		{"RS512", signToken(t, "RS512", "rsa", rsaKey, claims(nil)), ""},
+		{"ES256 with P-256", signToken(t, "ES256", "p256", p256Key, claims(nil)), ""},
+		{"ES384 with P-384", signToken(t, "ES384", "p384", p384Key, claims(nil)), ""},
+		{"audience string", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"aud": "dashboard"})), ""},
+		{"expiry within leeway", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})), ""},
+		{"ES384 with P-256 key", signToken(t, "ES384", "p256", p256Key, claims(nil)), "does not match the P-256 curve"},
+		{"ES256 with P-384 key", signToken(t, "ES256", "p384", p384Key, claims(nil)), "does not match the P-384 curve"},
+		{"RSA algorithm with EC key", retagAlg(t, signToken(t, "ES256", "p256", p256Key, claims(nil)), "RS256"), "does not match the ECDSA signing key"},
+		{"EC algorithm with RSA key", retagAlg(t, signToken(t, "RS256", "rsa", rsaKey, claims(nil)), "ES256"), "does not match the RSA signing key"},
+		{"unsupported algorithm", retagAlg(t, signToken(t, "RS256", "rsa", rsaKey, claims(nil)), "HS256"), "unsupported algorithm"},
+		{"tampered payload", tamper(signToken(t, "RS256", "rsa", rsaKey, claims(nil))), "invalid signature"},
+		{"unknown key", signToken(t, "RS256", "other", rsaKey, claims(nil)), "unknown signing key"},
+		{"expired", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})), "token expired"},
+		{"missing exp", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"exp": nil})), "missing exp claim"},
+		{"not yet valid", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})), "not yet valid"},
+		{"wrong issuer", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"iss": "https://other.example"})), "unexpected issuer"},
+		{"wrong audience", signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"aud": "other"})), "not issued for audience"},
+		{"malformed", "not-a-jwt", "malformed token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(jwks.server.URL, "https://issuer.example", "dashboard", defaultNamespacesClaim, time.Hour)
+			_, err := a.verify(context.Background(), tt.token, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verify() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verify() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// retagAlg replaces the alg of a token's header, keeping its signature
+func retagAlg(t *testing.T, token, alg string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	var header map[string]string
+	if err := decodeSegment(parts[0], &header); err != nil {
+		t.Fatal(err)
+	}
+	header["alg"] = alg
+	encoded, _ := json.Marshal(header)
+	parts[0] = base64.RawURLEncoding.EncodeToString(encoded)
+	return strings.Join(parts, ".")
+}
+
+// tamper replaces the claims of a token, keeping its signature
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"exp":9999999999,"namespaces":["kube-system"]}`))
+	return strings.Join(parts, ".")
+}
+
+func TestVerifySignatureCurveMismatch(t *testing.T) {
+	tests := []struct {
+		alg   string
+		curve elliptic.Curve
+		ok    bool
+	}{
+		{"ES256", elliptic.P256(), true},
+		{"ES384", elliptic.P384(), true},
+		{"ES512", elliptic.P521(), true},
+		{"ES256", elliptic.P384(), false},
+		{"ES384", elliptic.P256(), false},
+		{"ES512", elliptic.P256(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg+"/"+tt.curve.Params().Name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := signToken(t, tt.alg, "", key, map[string]any{})
+			parts := strings.Split(token, ".")
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			err = verifySignature(tt.alg, &key.PublicKey, parts[0]+"."+parts[1], signature)
+			if tt.ok && err != nil {
+				t.Errorf("verifySignature() error = %v, want nil", err)
+			}
+			if !tt.ok && (err == nil || !strings.Contains(err.Error(), "curve")) {
+				t.Errorf("verifySignature() error = %v, want curve mismatch", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		claim   any
+		allowed []string
+		denied  []string
+		key     string
+	}{
+		{"list", []any{"team-b", "team-a"}, []string{"team-a", "team-b"}, []string{"team-c"}, "auth:team-a,team-b"},
+		{"comma separated", "team-a,team-b", []string{"team-a", "team-b"}, []string{"team-c"}, "auth:team-a,team-b"},
+		{"space separated", "team-a team-b", []string{"team-a", "team-b"}, nil, "auth:team-a,team-b"},
+		{"invalid names ignored", []any{"team-a", "Not_Valid", 42}, []string{"team-a"}, []string{"Not_Valid"}, "auth:team-a"},
+		{"missing claim", nil, nil, []string{"team-a"}, "auth:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator("", "", "", defaultNamespacesClaim, time.Hour)
+			claims := map[string]any{}
+			if tt.claim != nil {
+				claims[defaultNamespacesClaim] = tt.claim
+			}
+			scope := a.scope(claims)
+			for _, namespace := range tt.allowed {
+				if !scope.namespaces.allows(namespace) {
+					t.Errorf("scope denies %s, want allowed", namespace)
+				}
+			}
+			for _, namespace := range tt.denied {
+				if scope.namespaces.allows(namespace) {
+					t.Errorf("scope allows %s, want denied", namespace)
+				}
+			}
+			if scope.key != tt.key {
+				t.Errorf("scope key = %q, want %q", scope.key, tt.key)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorStaleKeysRefreshWithoutBlocking(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := newTestJWKS(t, map[string]crypto.Signer{"rsa": rsaKey})
+	a := newAuthenticator(jwks.server.URL, "", "", defaultNamespacesClaim, time.Hour)
+
+	now := time.Now()
+	if _, err := a.key(context.Background(), "rsa", now); err != nil {
+		t.Fatalf("initial key() error = %v", err)
+	}
+
+	// Once stale, the cached key is returned while a slow refresh runs in the background
+	jwks.delay.Store(int64(500 * time.Millisecond))
+	start := time.Now()
+	if _, err := a.key(context.Background(), "rsa", now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("stale key() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("stale key() took %s, want it not to wait for the refresh", elapsed)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for jwks.requests.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := jwks.requests.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestAuthenticatorCoalescesUnknownKeyFetches(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := newTestJWKS(t, map[string]crypto.Signer{"rsa": rsaKey})
+	jwks.delay.Store(int64(100 * time.Millisecond))
+	a := newAuthenticator(jwks.server.URL, "", "", defaultNamespacesClaim, time.Hour)
+
+	const callers = 10
+	errs := make(chan error, callers)
+	for range callers {
+		go func() {
+			_, err := a.key(context.Background(), "rsa", time.Now())
+			errs <- err
+		}()
+	}
+	for range callers {
+		if err := <-errs; err != nil {
+			t.Errorf("key() error = %v", err)
+		}
+	}
+	if got := jwks.requests.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+// backendURL returns the configured URL of a metrics backend, with support for new and legacy env vars
+func backendURL(backend string) string {
+	switch backend {
+	case "prometheus":
+		// Try new env var first, then legacy, then default
+		return getEnvWithDefault("METRICS_PROMETHEUS_URL",
+			getEnvWithDefault("PROMETHEUS_URL",
+				"http://prometheus-stack-kube-prom-prometheus.pod-metrics-dashboard.svc.cluster.local:9090"))
+	case "remoteread":
+		return getEnvWithDefault("METRICS_REMOTE_READ_URL",
+			"http://prometheus-stack-kube-prom-prometheus.pod-metrics-dashboard.svc.cluster.local:9090/api/v1/read")
+	case k8s.BackendMetricsServer:
+		return getEnvWithDefault("METRICS_SERVER_URL", "https://kubernetes.default.svc")
+	case k8s.BackendAuto:
+		// The URL is probed to detect the backend, so accept any of the backend URLs
+		return getEnvWithDefault("METRICS_URL",
+			getEnvWithDefault("METRICS_PROMETHEUS_URL",
+				getEnvWithDefault("PROMETHEUS_URL", backendURL("victoriametrics"))))
+	default: // victoriametrics, also the fallback
+		// Try new env var first, then legacy, then default
+		return getEnvWithDefault("METRICS_VICTORIAMETRICS_URL",
+			getEnvWithDefault("VICTORIAMETRICS_URL",
+				"http://victoria-metrics-victoria-metrics-cluster-vmselect.pod-metrics-dashboard.svc.cluster.local:8481/select/0/prometheus"))
+	}
+}
+
+// loadBackendClients creates the clients selectable per request with the backend parameter, used to
+// compare backends during migrations. METRICS_ALTERNATE_BACKENDS lists the backends besides
+// METRICS_BACKEND, each reading its URL from its usual env vars. Without it no backends are selectable.
+func loadBackendClients(factory *k8s.MetricsClientFactory, base k8s.MetricsClientConfig) (map[string]k8s.MetricsClient, error) {
+	backends := make(map[string]k8s.MetricsClient)
+	alternates := os.Getenv("METRICS_ALTERNATE_BACKENDS")
+	if alternates == "" {
+		return backends, nil
+	}
+
+	for _, name := range append([]string{base.Backend}, strings.Split(alternates, ",")...) {
+		name = strings.TrimSpace(name)
+		if _, ok := backends[name]; ok {
+			continue
+		}
+		if name != "victoriametrics" && name != "prometheus" && name != "remoteread" && name != k8s.BackendMetricsServer && name != k8s.BackendAuto {
+			return nil, fmt.Errorf("invalid backend %q in METRICS_ALTERNATE_BACKENDS", name)
+		}
+
+		config := base
+		config.Backend = name
+		config.URL = backendURL(name)
+		metricsClient, err := factory.CreateClient(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s client: %w", name, err)
+		}
+		backends[name] = metricsClient
+	}
+
+	return backends, nil
+}
+
+// backendFor returns the client selected by the backend query parameter, or nil without one.
+// Unknown backends and combining backend with cluster are rejected with 400.
+func (h *Handler) backendFor(w http.ResponseWriter, r *http.Request) (k8s.MetricsClient, bool) {
+	name := r.URL.Query().Get("backend")
+	if name == "" {
+		return nil, true
+	}
+	if paramsFrom(r).cluster != "" {
+		http.Error(w, "The backend and cluster parameters cannot be combined", http.StatusBadRequest)
+		return nil, false
+	}
+	metricsClient, ok := h.backends[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown backend: %s", name), http.StatusBadRequest)
+		return nil, false
+	}
+	return metricsClient, true
+}
+
+// clientKey identifies the cluster or overridden backend serving a request, regardless of the
+// namespaces granted to the caller
+func (h *Handler) clientKey(r *http.Request) string {
+	if name := r.URL.Query().Get("backend"); name != "" {
+		return "backend:" + name
+	}
+	return h.clusterParam(r)
+}
+
+// sourceKey identifies the client serving a request, keeping cached and coalesced results of
+// overridden backends apart from those of the clusters. Authenticated requests only share results
+// with requests granted the same namespaces.
+func (h *Handler) sourceKey(r *http.Request) string {
+	key := h.clientKey(r)
+	if scope, ok := authScopeFrom(r); ok {
+		key += "/" + scope.key
+	}
+	return key
+}
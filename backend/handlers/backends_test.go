@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestLoadBackendClients(t *testing.T) {
+	tests := []struct {
+		name       string
+		alternates string
+		want       map[string]string // backend -> client type
+		wantErr    bool
+	}{
+		{"none", "", map[string]string{}, false},
+		{"one alternate", "victoriametrics", map[string]string{"prometheus": "prometheus", "victoriametrics": "victoriametrics"}, false},
+		{"duplicates and spaces", " victoriametrics, prometheus,victoriametrics", map[string]string{"prometheus": "prometheus", "victoriametrics": "victoriametrics"}, false},
+		{"unknown backend", "graphite", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METRICS_ALTERNATE_BACKENDS", tt.alternates)
+			backends, err := loadBackendClients(k8s.NewMetricsClientFactory(), k8s.MetricsClientConfig{Backend: "prometheus"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadBackendClients() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make(map[string]string, len(backends))
+			for name, metricsClient := range backends {
+				got[name] = metricsClient.GetClientType()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadBackendClients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newUsageServer serves the CPU usage of one container with the given value for every
+// container usage query and empty results otherwise
+func newUsageServer(t *testing.T, cpu string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := "[]"
+		if strings.HasPrefix(r.FormValue("query"), "rate(container_cpu_usage_seconds_total") {
+			result = `[{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"` + cpu + `"]}]`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":` + result + `}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBackendOverride(t *testing.T) {
+	prometheus, err := k8s.NewPrometheusClient(k8s.MetricsClientConfig{URL: newUsageServer(t, "0.25").URL})
+	if err != nil {
+		t.Fatalf("NewPrometheusClient() error = %v", err)
+	}
+	victoriaMetrics, err := k8s.NewVictoriaMetricsClient(k8s.MetricsClientConfig{URL: newUsageServer(t, "0.3").URL})
+	if err != nil {
+		t.Fatalf("NewVictoriaMetricsClient() error = %v", err)
+	}
+	h := newTestHandler(victoriaMetrics)
+	h.backends = map[string]k8s.MetricsClient{"prometheus": prometheus, "victoriametrics": victoriaMetrics}
+
+	tests := []struct {
+		name        string
+		target      string
+		wantCode    int
+		wantBackend string
+		wantCPU     float64
+	}{
+		{"default", "/api/pods", http.StatusOK, "victoriametrics", 0.3},
+		{"prometheus", "/api/pods?backend=prometheus", http.StatusOK, "prometheus", 0.25},
+		{"victoriametrics", "/api/pods?backend=victoriametrics", http.StatusOK, "victoriametrics", 0.3},
+		{"unknown backend", "/api/pods?backend=graphite", http.StatusBadRequest, "", 0},
+		{"backend and cluster", "/api/pods?backend=prometheus&cluster=default", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h.GetPodMetrics, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if got := rec.Header().Get("X-Metrics-Backend"); got != tt.wantBackend {
+				t.Errorf("X-Metrics-Backend = %q, want %q", got, tt.wantBackend)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var response models.PodMetricsList
+			decodeResponse(t, rec, &response)
+			if len(response.Pods) != 1 || response.Pods[0].CPU.UsageValue != tt.wantCPU {
+				t.Errorf("pods = %+v, want one pod using %v cores", response.Pods, tt.wantCPU)
+			}
+		})
+	}
+}
+
+func TestSourceKey(t *testing.T) {
+	h := newTwoClusterHandler()
+	keys := map[string]string{}
+	for _, target := range []string{"/api/pods", "/api/pods?cluster=prod", "/api/pods?cluster=staging", "/api/pods?backend=prometheus"} {
+		r := httptest.NewRequest("GET", target, nil)
+		ParseRequestParams(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys[target] = h.sourceKey(r)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// The default cluster shares its key with explicit requests for it, overrides get their own
+	want := map[string]string{
+		"/api/pods":                    "prod",
+		"/api/pods?cluster=prod":       "prod",
+		"/api/pods?cluster=staging":    "staging",
+		"/api/pods?backend=prometheus": "backend:prometheus",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("sourceKey() = %v, want %v", keys, want)
+	}
+}
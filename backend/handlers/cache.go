@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+// cacheMaxBackoff caps the delay between refreshes while the backend keeps failing
+const cacheMaxBackoff = 5 * time.Minute
+
+// Default initial and maximum TTLs of empty current pod metrics results
+const (
+	defaultEmptyResultTTL    = 5 * time.Second
+	defaultEmptyResultMaxTTL = 2 * time.Minute
+)
+
+// metricsSnapshot is the current pod metrics of all namespaces in one cluster
+type metricsSnapshot struct {
+	metrics     []k8s.PodMetric
+	warnings    []string
+	refreshedAt time.Time
+}
+
+// metricsCache holds a background-refreshed metrics snapshot per cluster so live
+// pod requests are served without a backend round trip
+type metricsCache struct {
+	interval time.Duration
+
+	mu        sync.RWMutex
+	snapshots map[string]metricsSnapshot
+}
+
+// newMetricsCache creates an empty cache refreshed every interval
+func newMetricsCache(interval time.Duration) *metricsCache {
+	return &metricsCache{
+		interval:  interval,
+		snapshots: make(map[string]metricsSnapshot),
+	}
+}
+
+// lookup returns the cluster's snapshot filtered to namespace (all namespaces when empty).
+// Snapshots older than two refresh intervals are not served. Safe to call on a nil cache.
+func (c *metricsCache) lookup(cluster, namespace string, now time.Time) (metricsSnapshot, bool) {
+	if c == nil {
+		return metricsSnapshot{}, false
+	}
+
+	c.mu.RLock()
+	snapshot, ok := c.snapshots[cluster]
+	c.mu.RUnlock()
+	if !ok || now.Sub(snapshot.refreshedAt) > 2*c.interval {
+		return metricsSnapshot{}, false
+	}
+
+	if namespace != "" {
+		var metrics []k8s.PodMetric
+		for _, metric := range snapshot.metrics {
+			if metric.Namespace == namespace {
+				metrics = append(metrics, metric)
+			}
+		}
+		snapshot.metrics = metrics
+	}
+	return snapshot, true
+}
+
+// cachedPodMetrics returns the cached snapshot serving r, filtered to namespace and to the namespaces
+// metricsClient may read, so authenticated requests share the cluster-wide snapshot. Snapshots are
+// kept per cluster, so the backend parameter is only served from them when it names the backend of
+// the default cluster.
+func (h *Handler) cachedPodMetrics(r *http.Request, metricsClient k8s.MetricsClient, namespace string, now time.Time) (metricsSnapshot, bool) {
+	cluster := h.clusterParam(r)
+	if name := r.URL.Query().Get("backend"); name != "" {
+		if defaultClient := h.clusters[h.defaultCluster]; defaultClient == nil || name != defaultClient.GetClientType() {
+			return metricsSnapshot{}, false
+		}
+		cluster = h.defaultCluster
+	}
+
+	snapshot, ok := h.cache.lookup(cluster, namespace, now)
+	if !ok {
+		return metricsSnapshot{}, false
+	}
+	snapshot.metrics = scopePodMetrics(metricsClient, snapshot.metrics)
+	return snapshot, true
+}
+
+// store replaces the cluster's snapshot
+func (c *metricsCache) store(cluster string, snapshot metricsSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshots[cluster] = snapshot
+}
+
+// refreshCache fetches a fresh snapshot for every cluster, keeping the previous
+// snapshot of clusters whose backend fails
+func (h *Handler) refreshCache(ctx context.Context) error {
+	var errs []error
+	for _, name := range h.clusterNames {
+		queryCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		queryCtx, warnings := k8s.WithQueryWarnings(queryCtx)
+		metricsData, err := h.clusters[name].GetCurrentPodMetrics(queryCtx, "", "", time.Now())
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", name, err))
+			continue
+		}
+
+		h.cache.store(name, metricsSnapshot{
+			metrics:     metricsData,
+			warnings:    warnings.Warnings(),
+			refreshedAt: time.Now(),
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// runCacheRefresh refreshes the cache immediately and then every interval until ctx is cancelled.
+// Refreshes never overlap, and the delay doubles up to cacheMaxBackoff while refreshes fail.
+func (h *Handler) runCacheRefresh(ctx context.Context) {
+	delay := h.cache.interval
+	for {
+		if err := h.refreshCache(ctx); err != nil {
+			delay = min(delay*2, max(cacheMaxBackoff, h.cache.interval))
+			log.Printf("Error refreshing metrics cache, retrying in %s: %v", delay, err)
+		} else {
+			delay = h.cache.interval
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// emptyResultCache remembers live current pod metrics queries that returned no pods, such as on a
+// fresh install without metrics yet, so repeated dashboard polls don't hit the backend. The TTL of
+// an empty result doubles with each consecutive empty result of the query, up to maxTTL, and the
+// query is forgotten once it returns pods again.
+type emptyResultCache struct {
+	ttl    time.Duration
+	maxTTL time.Duration
+
+	mu      sync.Mutex
+	entries *lruCache[emptyResult]
+}
+
+// emptyResult is the latest empty result of a query and the number of consecutive empty results
+type emptyResult struct {
+	warnings []string
+	count    int
+	until    time.Time
+}
+
+// newEmptyResultCache creates an empty result cache with an initial TTL of ttl holding up to maxEntries
+// queries, or nil when ttl is not positive
+func newEmptyResultCache(ttl, maxTTL time.Duration, maxEntries int) *emptyResultCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &emptyResultCache{
+		ttl:     ttl,
+		maxTTL:  max(ttl, maxTTL),
+		entries: newLRUCache[emptyResult](maxEntries),
+	}
+}
+
+// lookup returns the cached empty result of key until its TTL expires. Safe to call on a nil cache.
+func (c *emptyResultCache) lookup(key string, now time.Time) (podMetricsResult, bool) {
+	if c == nil {
+		return podMetricsResult{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries.get(key)
+	if !ok || !now.Before(entry.until) {
+		return podMetricsResult{}, false
+	}
+	return podMetricsResult{metrics: []k8s.PodMetric{}, warnings: entry.warnings}, true
+}
+
+// record caches an empty result of key for longer than the previous one, or forgets key when result
+// has pods. Streaks idle for longer than maxTTL past their expiry are dropped. Safe to call on a nil cache.
+func (c *emptyResultCache) record(key string, result podMetricsResult, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.removeIf(func(_ string, entry emptyResult) bool {
+		return now.Sub(entry.until) > c.maxTTL
+	})
+	if len(result.metrics) > 0 {
+		c.entries.remove(key)
+		return
+	}
+
+	entry, _ := c.entries.get(key)
+	entry.count++
+	ttl := c.ttl
+	for i := 1; i < entry.count && ttl < c.maxTTL; i++ {
+		ttl *= 2
+	}
+	entry.warnings = result.warnings
+	entry.until = now.Add(min(ttl, c.maxTTL))
+	c.entries.put(key, entry)
+}
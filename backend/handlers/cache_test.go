@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestMetricsCacheLookup(t *testing.T) {
+	now := time.Now()
+	metrics := []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop"},
+		{Name: "api-0", Namespace: "billing"},
+	}
+	tests := []struct {
+		name        string
+		cluster     string
+		namespace   string
+		refreshedAt time.Time
+		wantOK      bool
+		wantPods    []string
+	}{
+		{"all namespaces", "default", "", now.Add(-time.Minute), true, []string{"web-0", "api-0"}},
+		{"one namespace", "default", "shop", now.Add(-time.Minute), true, []string{"web-0"}},
+		{"unknown namespace", "default", "payments", now.Add(-time.Minute), true, nil},
+		{"within two intervals", "default", "", now.Add(-2 * time.Minute), true, []string{"web-0", "api-0"}},
+		{"stale snapshot", "default", "", now.Add(-2*time.Minute - time.Second), false, nil},
+		{"unknown cluster", "staging", "", now, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMetricsCache(time.Minute)
+			cache.store("default", metricsSnapshot{metrics: metrics, refreshedAt: tt.refreshedAt})
+
+			snapshot, ok := cache.lookup(tt.cluster, tt.namespace, now)
+			if ok != tt.wantOK {
+				t.Fatalf("lookup() ok = %v, want %v", ok, tt.wantOK)
+			}
+			var pods []string
+			for _, metric := range snapshot.metrics {
+				pods = append(pods, metric.Name)
+			}
+			if !reflect.DeepEqual(pods, tt.wantPods) {
+				t.Errorf("lookup() pods = %v, want %v", pods, tt.wantPods)
+			}
+		})
+	}
+
+	var cache *metricsCache
+	if _, ok := cache.lookup("default", "", now); ok {
+		t.Errorf("lookup() on a nil cache ok = true, want false")
+	}
+}
+
+func TestCachedPodMetrics(t *testing.T) {
+	shopScope := authScope{namespaces: &namespaceAllowlist{names: map[string]bool{"shop": true}}, key: "auth:shop"}
+	tests := []struct {
+		name      string
+		target    string
+		scope     *authScope
+		wantPods  []string
+		wantCache bool
+	}{
+		{"cluster-wide", "/api/pods", nil, []string{"api-0", "web-0"}, true},
+		{"authenticated", "/api/pods", &shopScope, []string{"web-0"}, true},
+		{"authenticated namespace", "/api/pods?namespace=shop", &shopScope, []string{"web-0"}, true},
+		{"backend of the default cluster", "/api/pods?backend=prometheus", nil, []string{"api-0", "web-0"}, true},
+		{"alternate backend", "/api/pods?backend=victoriametrics", nil, []string{"live-0"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := []k8s.PodMetric{{Name: "live-0", Namespace: "shop"}}
+			prometheus := &fakeMetricsClient{clientType: "prometheus", current: live}
+			victoriaMetrics := &fakeMetricsClient{clientType: "victoriametrics", current: live}
+			h := newTestHandler(prometheus)
+			h.backends = map[string]k8s.MetricsClient{"prometheus": prometheus, "victoriametrics": victoriaMetrics}
+			h.cache = newMetricsCache(time.Minute)
+			h.cache.store("default", metricsSnapshot{
+				metrics:     []k8s.PodMetric{{Name: "web-0", Namespace: "shop"}, {Name: "api-0", Namespace: "billing"}},
+				refreshedAt: time.Now(),
+			})
+
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.scope != nil {
+				r = r.WithContext(context.WithValue(r.Context(), authScopeKey{}, *tt.scope))
+			}
+			rec := httptest.NewRecorder()
+			ParseRequestParams(http.HandlerFunc(h.GetPodMetrics)).ServeHTTP(rec, r)
+
+			var response models.PodMetricsList
+			decodeResponse(t, rec, &response)
+			var pods []string
+			for _, pod := range response.Pods {
+				pods = append(pods, pod.Name)
+			}
+			sort.Strings(pods)
+			if !reflect.DeepEqual(pods, tt.wantPods) {
+				t.Errorf("pods = %v, want %v", pods, tt.wantPods)
+			}
+			calls := prometheus.currentCalls.Load() + victoriaMetrics.currentCalls.Load()
+			if cached := calls == 0; cached != tt.wantCache {
+				t.Errorf("served from the cache = %v, want %v", cached, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestRunCacheRefresh(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	tests := []struct {
+		name string
+		err  error
+		// Refreshes expected within the first few intervals
+		minCalls, maxCalls int32
+		wantSnapshot       bool
+	}{
+		{"refreshes every interval", nil, 4, 12, true},
+		{"backs off while the backend fails", errors.New("connection refused"), 2, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeMetricsClient{
+				current: []k8s.PodMetric{{Name: "web-0", Namespace: "shop"}},
+				err:     tt.err,
+			}
+			h := newTestHandler(client)
+			h.cache = newMetricsCache(interval)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				h.runCacheRefresh(ctx)
+				close(done)
+			}()
+
+			time.Sleep(7 * interval)
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("runCacheRefresh() did not stop after the context was cancelled")
+			}
+
+			calls := client.currentCalls.Load()
+			if calls < tt.minCalls || calls > tt.maxCalls {
+				t.Errorf("refreshes = %d, want %d to %d", calls, tt.minCalls, tt.maxCalls)
+			}
+			if _, ok := h.cache.lookup("default", "", time.Now()); ok != tt.wantSnapshot {
+				t.Errorf("cached snapshot = %v, want %v", ok, tt.wantSnapshot)
+			}
+
+			// No refresh runs once stopped
+			time.Sleep(3 * interval)
+			if after := client.currentCalls.Load(); after != calls {
+				t.Errorf("refreshes after stopping = %d, want %d", after, calls)
+			}
+		})
+	}
+}
+
+func TestEmptyResultCacheBackoff(t *testing.T) {
+	const key = "default\x00shop"
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	empty := podMetricsResult{metrics: []k8s.PodMetric{}, warnings: []string{k8s.WarningKubeStateMetricsMissing}}
+	pods := podMetricsResult{metrics: []k8s.PodMetric{{Name: "web-0", Namespace: "shop", ContainerName: "app"}}}
+	c := newEmptyResultCache(10*time.Second, 40*time.Second, defaultCacheMaxEntries)
+
+	// Each step records a result at an offset from start, then expects it cached until wantUntil
+	steps := []struct {
+		name      string
+		at        time.Duration
+		result    podMetricsResult
+		wantUntil time.Duration // Zero when the key is not cached
+	}{
+		{"first empty result", 0, empty, 10 * time.Second},
+		{"second empty result doubles the TTL", 10 * time.Second, empty, 30 * time.Second},
+		{"third empty result doubles it again", 30 * time.Second, empty, 70 * time.Second},
+		{"TTL capped at the maximum", 70 * time.Second, empty, 110 * time.Second},
+		{"pods reset the streak", 110 * time.Second, pods, 0},
+		{"next empty result starts over", 110 * time.Second, empty, 120 * time.Second},
+	}
+	for _, step := range steps {
+		now := start.Add(step.at)
+		c.record(key, step.result, now)
+
+		if step.wantUntil == 0 {
+			if _, ok := c.lookup(key, now); ok {
+				t.Errorf("%s: cached, want the key forgotten", step.name)
+			}
+			continue
+		}
+		result, ok := c.lookup(key, start.Add(step.wantUntil-time.Millisecond))
+		if !ok {
+			t.Errorf("%s: not cached until %s", step.name, step.wantUntil)
+			continue
+		}
+		if len(result.metrics) != 0 || !reflect.DeepEqual(result.warnings, empty.warnings) {
+			t.Errorf("%s: cached result = %+v, want no pods with the warnings", step.name, result)
+		}
+		if _, ok := c.lookup(key, start.Add(step.wantUntil)); ok {
+			t.Errorf("%s: still cached at %s", step.name, step.wantUntil)
+		}
+	}
+
+	// Other queries keep their own streak
+	if _, ok := c.lookup("default\x00billing", start); ok {
+		t.Error("query never recorded is cached")
+	}
+}
+
+func TestEmptyResultCacheDisabled(t *testing.T) {
+	c := newEmptyResultCache(0, time.Minute, defaultCacheMaxEntries)
+	if c != nil {
+		t.Fatalf("newEmptyResultCache(0) = %+v, want nil", c)
+	}
+	// A nil cache never caches
+	c.record("key", podMetricsResult{}, time.Now())
+	if _, ok := c.lookup("key", time.Now()); ok {
+		t.Error("nil cache returned a result")
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// defaultClusterName names the single cluster configured when CLUSTERS is not set
+const defaultClusterName = "default"
+
+// clusterNamePattern restricts cluster names to values that map cleanly onto env var names
+var clusterNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// clusterConfig is the metrics backend configuration of one named cluster
+type clusterConfig struct {
+	name   string
+	config k8s.MetricsClientConfig
+}
+
+// loadClusterConfigs reads the comma-separated cluster names from CLUSTERS, each configured with
+// CLUSTER_<NAME>_BACKEND, CLUSTER_<NAME>_URL and CLUSTER_<NAME>_BEARER_TOKEN on top of base.
+// Without CLUSTERS a single cluster named "default" is built from base.
+func loadClusterConfigs(base k8s.MetricsClientConfig) ([]clusterConfig, error) {
+	names := os.Getenv("CLUSTERS")
+	if names == "" {
+		return []clusterConfig{{name: defaultClusterName, config: base}}, nil
+	}
+
+	var clusters []clusterConfig
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if !clusterNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid cluster name %q in CLUSTERS", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate cluster name %q in CLUSTERS", name)
+		}
+		seen[name] = true
+
+		config := base
+		config.Backend = getEnvWithDefault(clusterEnvKey(name, "BACKEND"), base.Backend)
+		config.URL = os.Getenv(clusterEnvKey(name, "URL"))
+		if config.URL == "" {
+			return nil, fmt.Errorf("%s is required for cluster %q", clusterEnvKey(name, "URL"), name)
+		}
+		config.BearerToken = os.Getenv(clusterEnvKey(name, "BEARER_TOKEN"))
+		// The cluster URL also wins over KUBE_CONTEXT for the metrics-server backend
+		config.KubeContext = ""
+		clusters = append(clusters, clusterConfig{name: name, config: config})
+	}
+
+	return clusters, nil
+}
+
+// clusterEnvKey returns the env var holding a cluster setting, e.g. CLUSTER_PROD_EU_URL for "prod-eu"
+func clusterEnvKey(name, setting string) string {
+	return "CLUSTER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + setting
+}
+
+// clientFor returns the metrics client of the backend selected by the backend query parameter or
+// of the cluster selected by the cluster query parameter, falling back to the default cluster.
+// Unknown clusters and backends are rejected with 400. The serving backend is reported in the
+// X-Metrics-Backend response header. Requests for a namespace outside NAMESPACE_ALLOWLIST or the
+// namespaces granted to the user are rejected with 403, and the client of authenticated requests
+// only returns the granted namespaces.
+func (h *Handler) clientFor(w http.ResponseWriter, r *http.Request) (k8s.MetricsClient, bool) {
+	if !h.checkNamespaces(w, r) {
+		return nil, false
+	}
+	metricsClient, ok := h.backendFor(w, r)
+	if !ok {
+		return nil, false
+	}
+	if metricsClient == nil {
+		name := h.clusterParam(r)
+		if metricsClient, ok = h.clusters[name]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown cluster: %s", name), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	if metricsClient != nil {
+		w.Header().Set("X-Metrics-Backend", metricsClient.GetClientType())
+	}
+	if scope, ok := authScopeFrom(r); ok {
+		metricsClient = scopeNamespaces(metricsClient, scope.namespaces)
+	}
+	return metricsClient, true
+}
+
+// clusterParam returns the cluster requested by the cluster query parameter, or the default cluster
+func (h *Handler) clusterParam(r *http.Request) string {
+	if name := paramsFrom(r).cluster; name != "" {
+		return name
+	}
+	return h.defaultCluster
+}
+
+// GetClusters returns the configured clusters and their metrics backends
+func (h *Handler) GetClusters(w http.ResponseWriter, r *http.Request) {
+	response := models.ClusterList{Clusters: []models.ClusterInfo{}}
+	for _, name := range h.clusterNames {
+		info := models.ClusterInfo{
+			Name:    name,
+			Default: name == h.defaultCluster,
+		}
+		if metricsClient := h.clusters[name]; metricsClient != nil {
+			info.Backend = metricsClient.GetClientType()
+		}
+		response.Clusters = append(response.Clusters, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestLoadClusterConfigs(t *testing.T) {
+	base := k8s.MetricsClientConfig{Backend: "prometheus", URL: "http://prometheus:9090", BearerToken: "base", KubeContext: "staging"}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []clusterConfig
+		wantErr string
+	}{
+		{
+			name: "default cluster",
+			want: []clusterConfig{{name: defaultClusterName, config: base}},
+		},
+		{
+			name: "two clusters",
+			env: map[string]string{
+				"CLUSTERS":                     "prod-eu, staging",
+				"CLUSTER_PROD_EU_BACKEND":      "victoriametrics",
+				"CLUSTER_PROD_EU_URL":          "http://vm.eu:8428",
+				"CLUSTER_PROD_EU_BEARER_TOKEN": "eu-token",
+				"CLUSTER_STAGING_URL":          "http://prometheus.staging:9090",
+			},
+			want: []clusterConfig{
+				{name: "prod-eu", config: k8s.MetricsClientConfig{Backend: "victoriametrics", URL: "http://vm.eu:8428", BearerToken: "eu-token"}},
+				{name: "staging", config: k8s.MetricsClientConfig{Backend: "prometheus", URL: "http://prometheus.staging:9090"}},
+			},
+		},
+		{
+			name:    "missing URL",
+			env:     map[string]string{"CLUSTERS": "prod"},
+			wantErr: "CLUSTER_PROD_URL is required",
+		},
+		{
+			name:    "invalid name",
+			env:     map[string]string{"CLUSTERS": "prod.eu"},
+			wantErr: "invalid cluster name",
+		},
+		{
+			name:    "duplicate name",
+			env:     map[string]string{"CLUSTERS": "prod,prod", "CLUSTER_PROD_URL": "http://vm:8428"},
+			wantErr: "duplicate cluster name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CLUSTERS", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			got, err := loadClusterConfigs(base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadClusterConfigs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadClusterConfigs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadClusterConfigs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTwoClusterHandler returns a handler routing to a Prometheus "prod" cluster, the default, and a
+// VictoriaMetrics "staging" cluster
+func newTwoClusterHandler() *Handler {
+	h := newTestHandler(nil)
+	h.clusters = map[string]k8s.MetricsClient{
+		"prod":    &fakeMetricsClient{clientType: "prometheus", namespaces: []string{"shop", "payments"}},
+		"staging": &fakeMetricsClient{clientType: "victoriametrics", namespaces: []string{"shop-staging"}},
+	}
+	h.clusterNames = []string{"prod", "staging"}
+	h.defaultCluster = "prod"
+	return h
+}
+
+func TestClusterRouting(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		wantCode       int
+		wantBackend    string
+		wantNamespaces []string
+	}{
+		{"default cluster", "/api/namespaces", http.StatusOK, "prometheus", []string{"shop", "payments"}},
+		{"prod cluster", "/api/namespaces?cluster=prod", http.StatusOK, "prometheus", []string{"shop", "payments"}},
+		{"staging cluster", "/api/namespaces?cluster=staging", http.StatusOK, "victoriametrics", []string{"shop-staging"}},
+		{"unknown cluster", "/api/namespaces?cluster=dev", http.StatusBadRequest, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTwoClusterHandler().GetNamespaces, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if got := rec.Header().Get("X-Metrics-Backend"); got != tt.wantBackend {
+				t.Errorf("X-Metrics-Backend = %q, want %q", got, tt.wantBackend)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var response models.NamespaceList
+			decodeResponse(t, rec, &response)
+			if !reflect.DeepEqual(response.Namespaces, tt.wantNamespaces) {
+				t.Errorf("namespaces = %v, want %v", response.Namespaces, tt.wantNamespaces)
+			}
+		})
+	}
+}
+
+func TestGetClusters(t *testing.T) {
+	var response models.ClusterList
+	decodeResponse(t, serve(newTwoClusterHandler().GetClusters, "/api/clusters"), &response)
+	want := []models.ClusterInfo{
+		{Name: "prod", Backend: "prometheus", Default: true},
+		{Name: "staging", Backend: "victoriametrics"},
+	}
+	if !reflect.DeepEqual(response.Clusters, want) {
+		t.Errorf("clusters = %+v, want %+v", response.Clusters, want)
+	}
+}
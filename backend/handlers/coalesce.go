@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"golang.org/x/sync/singleflight"
+)
+
+// coalesce runs fn once for all concurrent callers with the same key in group and returns its
+// result. A caller whose ctx is done stops waiting with ctx's error, while the shared call goes on
+// for the others, so fn must not depend on the cancellation of any single caller. The result is
+// shared, so callers must not modify it.
+func coalesce[T any](ctx context.Context, group *singleflight.Group, key string, fn func() (T, error)) (T, error) {
+	ch := group.DoChan(key, func() (any, error) {
+		return fn()
+	})
+	select {
+	case result := <-ch:
+		value, _ := result.Val.(T)
+		return value, result.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// podMetricsResult is the shared result of a coalesced current pod metrics query
+type podMetricsResult struct {
+	metrics  []k8s.PodMetric
+	warnings []string
+}
+
+// currentPodMetrics queries current pod metrics, sharing a single backend round trip between
+// concurrent identical requests. evalTime is the raw "at" parameter, empty for live data.
+// Live queries without pods are served from the empty result cache while it holds them.
+func (h *Handler) currentPodMetrics(ctx context.Context, metricsClient k8s.MetricsClient, cluster, namespace, selector, evalTime string, at time.Time) (podMetricsResult, error) {
+	key := strings.Join([]string{cluster, namespace, selector, evalTime}, "\x00")
+	if evalTime == "" {
+		if result, ok := h.emptyResults.lookup(key, time.Now()); ok {
+			return result, nil
+		}
+	}
+	return coalesce(ctx, &h.podMetricsFlight, key, func() (podMetricsResult, error) {
+		// Detach from the first caller so its cancellation doesn't fail the others
+		queryCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+		defer cancel()
+		queryCtx, warnings := k8s.WithQueryWarnings(queryCtx)
+
+		metrics, err := metricsClient.GetCurrentPodMetrics(queryCtx, namespace, selector, at)
+		result := podMetricsResult{metrics: metrics, warnings: warnings.Warnings()}
+		if err == nil && evalTime == "" {
+			h.emptyResults.record(key, result, time.Now())
+		}
+		return result, err
+	})
+}
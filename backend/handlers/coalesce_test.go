@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"golang.org/x/sync/singleflight"
+)
+
+func TestCoalesceSharesConcurrentCalls(t *testing.T) {
+	var group singleflight.Group
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	const callers = 5
+	results := make([]int, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := coalesce(context.Background(), &group, "key", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if err != nil {
+				t.Errorf("coalesce() error = %v", err)
+			}
+			results[i] = value
+		}()
+	}
+
+	// Let every caller join the in-flight call before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn ran %d times, want 1", got)
+	}
+	for i, value := range results {
+		if value != 42 {
+			t.Errorf("caller %d got %d, want 42", i, value)
+		}
+	}
+}
+
+func TestCoalesceReturnsError(t *testing.T) {
+	var group singleflight.Group
+	wantErr := errors.New("backend down")
+
+	_, err := coalesce(context.Background(), &group, "key", func() (int, error) {
+		return 0, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("coalesce() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestCoalesceCanceledCallerStopsWaiting(t *testing.T) {
+	var group singleflight.Group
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := coalesce(ctx, &group, "key", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("coalesce() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestGetPodMetricsCoalesced(t *testing.T) {
+	tests := []struct {
+		name      string
+		targets   []string
+		wantCalls int32
+	}{
+		{"identical requests", []string{"/api/pods?namespace=shop", "/api/pods?namespace=shop", "/api/pods?namespace=shop", "/api/pods?namespace=shop"}, 1},
+		{"different namespaces", []string{"/api/pods?namespace=shop", "/api/pods?namespace=shop", "/api/pods?namespace=billing", "/api/pods?namespace=billing"}, 2},
+		{"different eval times", []string{"/api/pods?namespace=shop&at=2023-11-14T22:00:00Z", "/api/pods?namespace=shop&at=2023-11-14T22:00:00Z", "/api/pods?namespace=shop&at=2023-11-14T23:00:00Z"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			client := &fakeMetricsClient{
+				current: []k8s.PodMetric{{Name: "web-0", Namespace: "shop"}, {Name: "api-0", Namespace: "billing"}},
+				currentHook: func(ctx context.Context, namespace, selector string, at time.Time) {
+					<-release
+				},
+			}
+			h := newTestHandler(client)
+
+			var wg sync.WaitGroup
+			codes := make([]int, len(tt.targets))
+			for i, target := range tt.targets {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					codes[i] = serve(h.GetPodMetrics, target).Code
+				}()
+			}
+
+			// Let every request join the in-flight query before it completes
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if got := client.currentCalls.Load(); got != tt.wantCalls {
+				t.Errorf("backend queried %d times, want %d", got, tt.wantCalls)
+			}
+			for i, code := range codes {
+				if code != http.StatusOK {
+					t.Errorf("request %d status = %d, want %d", i, code, http.StatusOK)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// defaultCompareTolerance is the relative difference, in percent, above which compared values are reported
+const defaultCompareTolerance = 5.0
+
+// CompareBackends runs the same current metrics query against two of the backends selectable with
+// the backend parameter and returns the containers whose values differ beyond a tolerance, to check
+// backends agree while migrating between them
+func (h *Handler) CompareBackends(w http.ResponseWriter, r *http.Request) {
+	if !h.enableDebug {
+		http.Error(w, "Debug endpoints are disabled - set ENABLE_DEBUG_ENDPOINTS=true to enable", http.StatusForbidden)
+		return
+	}
+	if !h.checkNamespaces(w, r) {
+		return
+	}
+
+	names, err := h.comparedBackends(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tolerance, err := parseFloatParam(r, "tolerance", defaultCompareTolerance)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	selector := r.URL.Query().Get("selector")
+	if selector != "" && !validSelector(selector) {
+		http.Error(w, "invalid selector parameter: expected comma-separated label matchers like app=\"nginx\"", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Query both backends at the same instant, concurrently
+	namespace := namespaceParam(r)
+	at := time.Now()
+	results := make([][]k8s.PodMetric, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		metricsClient := h.backends[name]
+		if scope, ok := authScopeFrom(r); ok {
+			metricsClient = scopeNamespaces(metricsClient, scope.namespaces)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = metricsClient.GetCurrentPodMetrics(ctx, namespace, selector, at)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("Error getting pod metrics from %s: %v", names[i], err)
+			http.Error(w, fmt.Sprintf("backend %s: %v", names[i], err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := h.format.compareMetrics(names, results[0], results[1], tolerance)
+	response.Namespace = namespace
+	response.GeneratedAt = at
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// comparedBackends returns the two backends named by the backends parameter, by default the only two
+// selectable backends
+func (h *Handler) comparedBackends(r *http.Request) ([]string, error) {
+	var names []string
+	if value := r.URL.Query().Get("backends"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	} else {
+		for name := range h.backends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) != 2 {
+			return nil, fmt.Errorf("backends parameter is required: expected two of the METRICS_BACKEND and METRICS_ALTERNATE_BACKENDS backends")
+		}
+	}
+
+	if len(names) != 2 || names[0] == names[1] {
+		return nil, fmt.Errorf("invalid backends parameter: expected two different backends")
+	}
+	for _, name := range names {
+		if _, ok := h.backends[name]; !ok {
+			return nil, fmt.Errorf("Unknown backend: %s", name)
+		}
+	}
+	return names, nil
+}
+
+// compareMetrics compares the containers reported by two backends, reporting the values whose
+// difference relative to the larger one exceeds tolerance percent and the containers reported by one
+// backend only
+func (f valueFormat) compareMetrics(names []string, first, second []k8s.PodMetric, tolerance float64) models.BackendComparison {
+	response := models.BackendComparison{
+		Backends:          names,
+		TolerancePercent:  tolerance,
+		Discrepancies:     []models.BackendDiscrepancy{},
+		MissingContainers: []models.BackendMissingContainer{},
+	}
+
+	others := make(map[string]k8s.PodMetric, len(second))
+	for _, metric := range second {
+		others[containerKey(metric)] = metric
+	}
+
+	for _, metric := range first {
+		key := containerKey(metric)
+		other, ok := others[key]
+		if !ok {
+			response.MissingContainers = append(response.MissingContainers, missingContainer(metric, names[0]))
+			continue
+		}
+		delete(others, key)
+		response.ContainerCount++
+
+		var fields []models.BackendFieldDiff
+		for _, field := range []struct {
+			name   string
+			values [2]float64
+		}{
+			{"cpuUsage", [2]float64{metric.CPUUsageCores(), other.CPUUsageCores()}},
+			{"cpuRequest", [2]float64{metric.CPURequest, other.CPURequest}},
+			{"cpuLimit", [2]float64{metric.CPULimit, other.CPULimit}},
+			{"memoryUsage", [2]float64{metric.MemoryUsage, other.MemoryUsage}},
+			{"memoryRequest", [2]float64{metric.MemoryRequest, other.MemoryRequest}},
+			{"memoryLimit", [2]float64{metric.MemoryLimit, other.MemoryLimit}},
+		} {
+			if difference := relativeDifference(field.values[0], field.values[1]); difference > tolerance {
+				fields = append(fields, models.BackendFieldDiff{
+					Field:             field.name,
+					Values:            []float64{f.roundValue(field.values[0]), f.roundValue(field.values[1])},
+					DifferencePercent: f.roundValue(difference),
+				})
+			}
+		}
+		if len(fields) == 0 {
+			response.MatchingCount++
+			continue
+		}
+		response.Discrepancies = append(response.Discrepancies, models.BackendDiscrepancy{
+			Namespace: metric.Namespace,
+			Pod:       metric.Name,
+			Container: metric.ContainerName,
+			Fields:    fields,
+		})
+	}
+	for _, metric := range others {
+		response.MissingContainers = append(response.MissingContainers, missingContainer(metric, names[1]))
+	}
+
+	sort.Slice(response.Discrepancies, func(i, j int) bool {
+		a, b := response.Discrepancies[i], response.Discrepancies[j]
+		return a.Namespace+"/"+a.Pod+"/"+a.Container < b.Namespace+"/"+b.Pod+"/"+b.Container
+	})
+	sort.Slice(response.MissingContainers, func(i, j int) bool {
+		a, b := response.MissingContainers[i], response.MissingContainers[j]
+		return a.Namespace+"/"+a.Pod+"/"+a.Container < b.Namespace+"/"+b.Pod+"/"+b.Container
+	})
+	return response
+}
+
+// containerKey identifies a container across backends
+func containerKey(metric k8s.PodMetric) string {
+	return metric.Namespace + "/" + metric.Name + "/" + metric.ContainerName
+}
+
+// missingContainer describes a container reported by backend only
+func missingContainer(metric k8s.PodMetric, backend string) models.BackendMissingContainer {
+	return models.BackendMissingContainer{
+		Namespace: metric.Namespace,
+		Pod:       metric.Name,
+		Container: metric.ContainerName,
+		Backend:   backend,
+	}
+}
+
+// relativeDifference returns the difference of a and b in percent of the larger magnitude, 0 when both are 0
+func relativeDifference(a, b float64) float64 {
+	larger := math.Max(math.Abs(a), math.Abs(b))
+	if larger == 0 {
+		return 0
+	}
+	return math.Abs(a-b) / larger * 100
+}
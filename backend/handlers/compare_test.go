@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// newCompareHandler returns a Handler with debug endpoints enabled and two backends reporting slightly
+// different metrics
+func newCompareHandler() *Handler {
+	prometheus := &fakeMetricsClient{clientType: "prometheus", current: []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.25, CPURequest: 0.5, MemoryUsage: 100 << 20, MemoryRequest: 128 << 20},
+		{Name: "web-0", Namespace: "shop", ContainerName: "sidecar", CPUUsage: 0.01, MemoryUsage: 10 << 20},
+		{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 0.1, MemoryUsage: 50 << 20},
+	}}
+	victoriaMetrics := &fakeMetricsClient{clientType: "victoriametrics", current: []k8s.PodMetric{
+		// CPU usage within 5%, memory usage 16.67% higher
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.26, CPURequest: 0.5, MemoryUsage: 120 << 20, MemoryRequest: 128 << 20},
+		// Same usage, reported in millicores
+		{Name: "web-0", Namespace: "shop", ContainerName: "sidecar", CPUUsage: 10, CPUUnit: k8s.CPUUnitMillicores, MemoryUsage: 10 << 20},
+		{Name: "cache-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.05, MemoryUsage: 20 << 20},
+	}}
+	h := newTestHandler(prometheus)
+	h.enableDebug = true
+	h.backends = map[string]k8s.MetricsClient{"prometheus": prometheus, "victoriametrics": victoriaMetrics}
+	return h
+}
+
+func TestCompareMetrics(t *testing.T) {
+	h := newCompareHandler()
+	prometheus, victoriaMetrics := h.backends["prometheus"].(*fakeMetricsClient), h.backends["victoriametrics"].(*fakeMetricsClient)
+	names := []string{"prometheus", "victoriametrics"}
+
+	got := h.format.compareMetrics(names, prometheus.current, victoriaMetrics.current, defaultCompareTolerance)
+	want := models.BackendComparison{
+		Backends:         names,
+		TolerancePercent: defaultCompareTolerance,
+		ContainerCount:   2,
+		MatchingCount:    1,
+		Discrepancies: []models.BackendDiscrepancy{{
+			Namespace: "shop", Pod: "web-0", Container: "app",
+			Fields: []models.BackendFieldDiff{{Field: "memoryUsage", Values: []float64{100 << 20, 120 << 20}, DifferencePercent: 16.67}},
+		}},
+		MissingContainers: []models.BackendMissingContainer{
+			{Namespace: "billing", Pod: "api-0", Container: "app", Backend: "prometheus"},
+			{Namespace: "shop", Pod: "cache-0", Container: "app", Backend: "victoriametrics"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("compareMetrics() = %+v, want %+v", got, want)
+	}
+
+	// Without a tolerance the CPU usage differs too, in the order of the compared backends
+	got = h.format.compareMetrics([]string{"victoriametrics", "prometheus"}, victoriaMetrics.current, prometheus.current, 0)
+	wantFields := []models.BackendFieldDiff{
+		{Field: "cpuUsage", Values: []float64{0.26, 0.25}, DifferencePercent: 3.85},
+		{Field: "memoryUsage", Values: []float64{120 << 20, 100 << 20}, DifferencePercent: 16.67},
+	}
+	if len(got.Discrepancies) != 1 || !reflect.DeepEqual(got.Discrepancies[0].Fields, wantFields) {
+		t.Errorf("discrepancies = %+v, want web-0/app with fields %+v", got.Discrepancies, wantFields)
+	}
+	if got.MatchingCount != 1 {
+		t.Errorf("matching count = %d, want 1", got.MatchingCount)
+	}
+}
+
+func TestCompareBackends(t *testing.T) {
+	tests := []struct {
+		name              string
+		target            string
+		disabled          bool
+		backends          []string // Limits the handler's backends when set
+		wantCode          int
+		wantDiscrepancies int
+	}{
+		{name: "default backends", target: "/api/pods/compare-backends", wantCode: http.StatusOK, wantDiscrepancies: 1},
+		{name: "tolerance", target: "/api/pods/compare-backends?tolerance=20", wantCode: http.StatusOK},
+		{name: "namespace", target: "/api/pods/compare-backends?namespace=billing", wantCode: http.StatusOK},
+		{name: "backends parameter", target: "/api/pods/compare-backends?backends=victoriametrics,prometheus", wantCode: http.StatusOK, wantDiscrepancies: 1},
+		{name: "same backend twice", target: "/api/pods/compare-backends?backends=prometheus,prometheus", wantCode: http.StatusBadRequest},
+		{name: "unknown backend", target: "/api/pods/compare-backends?backends=prometheus,graphite", wantCode: http.StatusBadRequest},
+		{name: "one selectable backend", target: "/api/pods/compare-backends", backends: []string{"prometheus"}, wantCode: http.StatusBadRequest},
+		{name: "invalid tolerance", target: "/api/pods/compare-backends?tolerance=lots", wantCode: http.StatusBadRequest},
+		{name: "debug endpoints disabled", target: "/api/pods/compare-backends", disabled: true, wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newCompareHandler()
+			h.enableDebug = !tt.disabled
+			if tt.backends != nil {
+				backends := map[string]k8s.MetricsClient{}
+				for _, name := range tt.backends {
+					backends[name] = h.backends[name]
+				}
+				h.backends = backends
+			}
+
+			rec := serve(h.CompareBackends, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var response models.BackendComparison
+			decodeResponse(t, rec, &response)
+			if len(response.Discrepancies) != tt.wantDiscrepancies {
+				t.Errorf("discrepancies = %+v, want %d", response.Discrepancies, tt.wantDiscrepancies)
+			}
+			if len(response.Backends) != 2 {
+				t.Errorf("backends = %v, want 2", response.Backends)
+			}
+		})
+	}
+}
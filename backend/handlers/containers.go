@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// GetPodContainers returns the current metrics of a pod's containers grouped under a pod-level rollup
+func (h *Handler) GetPodContainers(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	// Get path parameters
+	namespace := r.PathValue("namespace")
+	podName := r.PathValue("pod")
+	if !validLabelValue(namespace) || !validLabelValue(podName) {
+		http.Error(w, "invalid namespace or pod name", http.StatusBadRequest)
+		return
+	}
+
+	metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, namespace, fmt.Sprintf(`pod="%s"`, podName), time.Now())
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(metricsData) == 0 {
+		http.Error(w, "Pod not found", http.StatusNotFound)
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := h.format.rollupPodContainers(podName, namespace, metricsData)
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// rollupPodContainers converts a pod's container metrics and sums their usage, requests and limits
+func (f valueFormat) rollupPodContainers(podName, namespace string, metrics []k8s.PodMetric) models.PodContainers {
+	var total k8s.PodMetric
+	containers := make([]models.PodMetrics, 0, len(metrics))
+	for _, metric := range metrics {
+		total.CPUUsage += metric.CPUUsageCores()
+		total.CPURequest += metric.CPURequest
+		total.CPULimit += metric.CPULimit
+		total.MemoryUsage += metric.MemoryUsage
+		total.MemoryRequest += metric.MemoryRequest
+		total.MemoryLimit += metric.MemoryLimit
+		containers = append(containers, f.convertMetricsToModelMetric(metric))
+	}
+
+	return models.PodContainers{
+		Name:       podName,
+		Namespace:  namespace,
+		CPU:        f.buildResourceMetrics(total.CPUUsage, total.CPURequest, total.CPULimit, formatCPU),
+		Memory:     f.buildResourceMetrics(total.MemoryUsage, total.MemoryRequest, total.MemoryLimit, f.formatMemory),
+		Containers: containers,
+	}
+}
+
+// mergePodContainers merges the container metrics of each pod into a single pod-level metric with
+// summed usage, requests and limits, in the order pods first appear
+func mergePodContainers(metrics []k8s.PodMetric) []k8s.PodMetric {
+	merged := []k8s.PodMetric{}
+	index := make(map[string]int)
+	for _, metric := range metrics {
+		key := metric.Namespace + "/" + metric.Name
+		i, exists := index[key]
+		if !exists {
+			index[key] = len(merged)
+			pod := metric
+			pod.ContainerName = ""
+			pod.CPUUsage, pod.CPUUnit = metric.CPUUsageCores(), k8s.CPUUnitCores
+			merged = append(merged, pod)
+			continue
+		}
+
+		pod := &merged[i]
+		pod.CPUUsage += metric.CPUUsageCores()
+		pod.CPURequest += metric.CPURequest
+		pod.CPULimit += metric.CPULimit
+		pod.MemoryUsage += metric.MemoryUsage
+		pod.MemoryRequest += metric.MemoryRequest
+		pod.MemoryLimit += metric.MemoryLimit
+		pod.RestartCount += metric.RestartCount
+		pod.OOMKilled = pod.OOMKilled || metric.OOMKilled
+		if metric.SampleTime.After(pod.SampleTime) {
+			pod.SampleTime = metric.SampleTime
+		}
+	}
+	return merged
+}
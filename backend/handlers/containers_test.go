@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestGetPodContainers(t *testing.T) {
+	client := &fakeMetricsClient{current: []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.3, CPURequest: 0.5, CPULimit: 1, MemoryUsage: 200 << 20, MemoryRequest: 256 << 20, MemoryLimit: 512 << 20},
+		{Name: "web-0", Namespace: "shop", ContainerName: "sidecar", CPUUsage: 0.05, CPURequest: 0.1, CPULimit: 0.2, MemoryUsage: 32 << 20, MemoryRequest: 64 << 20, MemoryLimit: 128 << 20},
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/pods/containers/{namespace}/{pod}", newTestHandler(client).GetPodContainers)
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+	}{
+		{"two-container pod", "/api/pods/containers/shop/web-0", http.StatusOK},
+		{"namespace without the pod", "/api/pods/containers/billing/web-0", http.StatusNotFound},
+		{"invalid pod name", "/api/pods/containers/shop/web%220", http.StatusBadRequest},
+		{"unknown subresource", "/api/pods/shop/web-0/volumes", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(mux.ServeHTTP, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var response models.PodContainers
+			decodeResponse(t, rec, &response)
+			if response.Name != "web-0" || response.Namespace != "shop" || len(response.Containers) != 2 {
+				t.Fatalf("response = %s/%s with %d containers, want shop/web-0 with 2", response.Namespace, response.Name, len(response.Containers))
+			}
+
+			// The rollup sums equal the sums of the container parts
+			var sum models.PodMetrics
+			for _, container := range response.Containers {
+				sum.CPU.UsageValue += container.CPU.UsageValue
+				sum.CPU.RequestValue += container.CPU.RequestValue
+				sum.CPU.LimitValue += container.CPU.LimitValue
+				sum.Memory.UsageValue += container.Memory.UsageValue
+				sum.Memory.RequestValue += container.Memory.RequestValue
+				sum.Memory.LimitValue += container.Memory.LimitValue
+			}
+			rollups := []struct {
+				name      string
+				got, want float64
+			}{
+				{"cpu usage", response.CPU.UsageValue, sum.CPU.UsageValue},
+				{"cpu request", response.CPU.RequestValue, sum.CPU.RequestValue},
+				{"cpu limit", response.CPU.LimitValue, sum.CPU.LimitValue},
+				{"memory usage", response.Memory.UsageValue, sum.Memory.UsageValue},
+				{"memory request", response.Memory.RequestValue, sum.Memory.RequestValue},
+				{"memory limit", response.Memory.LimitValue, sum.Memory.LimitValue},
+			}
+			for _, rollup := range rollups {
+				if rollup.got == 0 || math.Abs(rollup.got-rollup.want) > 1e-9 {
+					t.Errorf("%s rollup = %v, want the container sum %v", rollup.name, rollup.got, rollup.want)
+				}
+			}
+			if response.CPU.RequestValue != 0.6 || response.Memory.RequestValue != 320<<20 {
+				t.Errorf("requests = %v, %v, want 0.6 and %v", response.CPU.RequestValue, response.Memory.RequestValue, 320<<20)
+			}
+		})
+	}
+}
+
+// mergeTestData holds a two-container pod with CPU usage in mixed units, and a single-container pod
+var mergeTestData = []k8s.PodMetric{
+	{
+		Name: "web-0", Namespace: "shop", ContainerName: "app",
+		CPUUsage: 200, CPUUnit: k8s.CPUUnitMillicores, CPURequest: 0.25, CPULimit: 0.5,
+		MemoryUsage: 100 << 20, MemoryRequest: 128 << 20, MemoryLimit: 256 << 20,
+		RestartCount: 1, SampleTime: time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC),
+	},
+	{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 0.1, CPURequest: 0.2, MemoryUsage: 50 << 20, MemoryRequest: 100 << 20},
+	{
+		Name: "web-0", Namespace: "shop", ContainerName: "sidecar",
+		CPUUsage: 0.05, CPURequest: 0.05,
+		MemoryUsage:  28 << 20,
+		RestartCount: 2, OOMKilled: true, SampleTime: time.Date(2026, 1, 2, 3, 0, 30, 0, time.UTC),
+	},
+}
+
+func TestMergePodContainers(t *testing.T) {
+	merged := mergePodContainers(mergeTestData)
+	if len(merged) != 2 || merged[0].Name != "web-0" || merged[1].Name != "api-0" {
+		t.Fatalf("mergePodContainers() = %+v, want web-0 and api-0 in order of appearance", merged)
+	}
+
+	web := merged[0]
+	totals := []struct {
+		name      string
+		got, want float64
+	}{
+		{"cpu usage", web.CPUUsage, 0.25},
+		{"cpu request", web.CPURequest, 0.3},
+		{"cpu limit", web.CPULimit, 0.5},
+		{"memory usage", web.MemoryUsage, 128 << 20},
+		{"memory request", web.MemoryRequest, 128 << 20},
+		{"memory limit", web.MemoryLimit, 256 << 20},
+	}
+	for _, total := range totals {
+		if math.Abs(total.got-total.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", total.name, total.got, total.want)
+		}
+	}
+	if web.ContainerName != "" || web.CPUUnit != k8s.CPUUnitCores {
+		t.Errorf("merged pod container = %q, CPU unit = %q, want no container and cores", web.ContainerName, web.CPUUnit)
+	}
+	if web.RestartCount != 3 || !web.OOMKilled || !web.SampleTime.Equal(mergeTestData[2].SampleTime) {
+		t.Errorf("merged pod restarts = %d, OOMKilled = %v, sampled %s, want 3, true and the newest sample", web.RestartCount, web.OOMKilled, web.SampleTime)
+	}
+
+	// The input is left untouched
+	if mergeTestData[0].CPUUsage != 200 || mergeTestData[0].ContainerName != "app" {
+		t.Errorf("mergePodContainers() modified its input: %+v", mergeTestData[0])
+	}
+}
+
+func TestGetPodMetricsPerContainer(t *testing.T) {
+	h := newTestHandler(&fakeMetricsClient{current: mergeTestData})
+
+	var perContainer models.PodMetricsList
+	decodeResponse(t, serve(h.GetPodMetrics, "/api/pods"), &perContainer)
+	if len(perContainer.Pods) != 3 {
+		t.Errorf("got %d containers by default, want 3", len(perContainer.Pods))
+	}
+
+	var response models.PodMetricsList
+	decodeResponse(t, serve(h.GetPodMetrics, "/api/pods?perContainer=false"), &response)
+	var web *models.PodMetrics
+	for i, pod := range response.Pods {
+		if pod.Name == "web-0" {
+			web = &response.Pods[i]
+		}
+	}
+	if len(response.Pods) != 2 || web == nil {
+		t.Fatalf("pods = %+v, want 2 merged pods including web-0", response.Pods)
+	}
+
+	// Percentages are recomputed from the pod totals rather than taken from a container
+	percentages := []struct {
+		name      string
+		got, want float64
+	}{
+		{"cpu request percentage", web.CPU.RequestPercentage, 83.33},
+		{"cpu limit percentage", web.CPU.LimitPercentage, 50},
+		{"memory request percentage", web.Memory.RequestPercentage, 100},
+		{"memory limit percentage", web.Memory.LimitPercentage, 50},
+	}
+	for _, percentage := range percentages {
+		if percentage.got != percentage.want {
+			t.Errorf("%s = %v, want %v", percentage.name, percentage.got, percentage.want)
+		}
+	}
+	if web.RestartCount != 3 || !web.OOMKilled {
+		t.Errorf("web-0 restarts = %d, OOMKilled = %v, want 3 and true", web.RestartCount, web.OOMKilled)
+	}
+
+	if rec := serve(h.GetPodMetrics, "/api/pods?perContainer=maybe"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid perContainer status = %d, want 400", rec.Code)
+	}
+}
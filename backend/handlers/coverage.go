@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// GetScrapeCoverage compares the running pods listed by the Kubernetes API with the pods the metrics
+// backend reports usage for, per namespace, to surface pods that are not scraped
+func (h *Handler) GetScrapeCoverage(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	if h.kube == nil {
+		http.Error(w, "Service unavailable - Kubernetes API not configured, set KUBERNETES_API_URL when running outside a cluster", http.StatusServiceUnavailable)
+		return
+	}
+	if cluster := h.clusterParam(r); cluster != h.defaultCluster {
+		http.Error(w, fmt.Sprintf("Scrape coverage is only available for the default cluster %s", h.defaultCluster), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	namespace := namespaceParam(r)
+	now := time.Now()
+	running, err := h.kube.GetRunningPods(ctx, namespace)
+	if err != nil {
+		log.Printf("Error listing pods from the Kubernetes API: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, namespace, "", now)
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The metrics client is already scoped, the pods listed by the Kubernetes API are not
+	scope, _ := authScopeFrom(r)
+	for ns := range running {
+		if !h.namespaces.allows(ns) || (h.auth != nil && !scope.namespaces.allows(ns)) {
+			delete(running, ns)
+		}
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := h.format.scrapeCoverage(running, metricsData)
+	response.GeneratedAt = now
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// scrapeCoverage counts the running pods of each namespace that have usage metrics, sorted by
+// namespace. Pods with metrics that are not running, such as recently deleted pods, are not counted.
+func (f valueFormat) scrapeCoverage(running map[string][]string, metrics []k8s.PodMetric) models.ScrapeCoverage {
+	scraped := make(map[string]bool, len(metrics))
+	for _, metric := range metrics {
+		scraped[metric.Namespace+"/"+metric.Name] = true
+	}
+
+	response := models.ScrapeCoverage{Namespaces: []models.NamespaceCoverage{}}
+	for namespace, pods := range running {
+		coverage := models.NamespaceCoverage{
+			Namespace:     namespace,
+			PodCount:      len(pods),
+			UnscrapedPods: []string{},
+		}
+		for _, pod := range pods {
+			if scraped[namespace+"/"+pod] {
+				coverage.ScrapedPodCount++
+			} else {
+				coverage.UnscrapedPods = append(coverage.UnscrapedPods, pod)
+			}
+		}
+		sort.Strings(coverage.UnscrapedPods)
+		coverage.CoveragePercent = f.coveragePercent(coverage.ScrapedPodCount, coverage.PodCount)
+
+		response.PodCount += coverage.PodCount
+		response.ScrapedPodCount += coverage.ScrapedPodCount
+		response.Namespaces = append(response.Namespaces, coverage)
+	}
+	response.CoveragePercent = f.coveragePercent(response.ScrapedPodCount, response.PodCount)
+
+	sort.Slice(response.Namespaces, func(i, j int) bool {
+		return response.Namespaces[i].Namespace < response.Namespaces[j].Namespace
+	})
+	return response
+}
+
+// coveragePercent returns scraped in percent of total, 100 when there is nothing to scrape
+func (f valueFormat) coveragePercent(scraped, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return f.roundValue(float64(scraped) / float64(total) * 100)
+}
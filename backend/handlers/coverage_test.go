@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// podMetrics returns a usage metric for each pod of namespace
+func podMetrics(namespace string, pods ...string) []k8s.PodMetric {
+	metrics := make([]k8s.PodMetric, len(pods))
+	for i, pod := range pods {
+		metrics[i] = k8s.PodMetric{Name: pod, Namespace: namespace, ContainerName: "app"}
+	}
+	return metrics
+}
+
+func TestScrapeCoverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		running map[string][]string
+		metrics []k8s.PodMetric
+		want    models.ScrapeCoverage
+	}{
+		{
+			name:    "eight of ten pods scraped",
+			running: map[string][]string{"shop": podNames("web", 10)},
+			metrics: podMetrics("shop", podNames("web", 8)...),
+			want: models.ScrapeCoverage{
+				Namespaces: []models.NamespaceCoverage{
+					{Namespace: "shop", PodCount: 10, ScrapedPodCount: 8, CoveragePercent: 80, UnscrapedPods: []string{"web-8", "web-9"}},
+				},
+				PodCount: 10, ScrapedPodCount: 8, CoveragePercent: 80,
+			},
+		},
+		{
+			name:    "namespaces sorted and totalled",
+			running: map[string][]string{"shop": {"web-1", "web-0"}, "billing": {"api-0"}},
+			metrics: podMetrics("shop", "web-0"),
+			want: models.ScrapeCoverage{
+				Namespaces: []models.NamespaceCoverage{
+					{Namespace: "billing", PodCount: 1, ScrapedPodCount: 0, CoveragePercent: 0, UnscrapedPods: []string{"api-0"}},
+					{Namespace: "shop", PodCount: 2, ScrapedPodCount: 1, CoveragePercent: 50, UnscrapedPods: []string{"web-1"}},
+				},
+				PodCount: 3, ScrapedPodCount: 1, CoveragePercent: 33.33,
+			},
+		},
+		{
+			name:    "metrics of pods that are not running are ignored",
+			running: map[string][]string{"shop": {"web-0"}},
+			metrics: append(podMetrics("shop", "web-0", "web-deleted"), podMetrics("billing", "web-0")...),
+			want: models.ScrapeCoverage{
+				Namespaces: []models.NamespaceCoverage{
+					{Namespace: "shop", PodCount: 1, ScrapedPodCount: 1, CoveragePercent: 100, UnscrapedPods: []string{}},
+				},
+				PodCount: 1, ScrapedPodCount: 1, CoveragePercent: 100,
+			},
+		},
+		{
+			name:    "pods with several containers are counted once",
+			running: map[string][]string{"shop": {"web-0"}},
+			metrics: append(podMetrics("shop", "web-0"), k8s.PodMetric{Name: "web-0", Namespace: "shop", ContainerName: "sidecar"}),
+			want: models.ScrapeCoverage{
+				Namespaces: []models.NamespaceCoverage{
+					{Namespace: "shop", PodCount: 1, ScrapedPodCount: 1, CoveragePercent: 100, UnscrapedPods: []string{}},
+				},
+				PodCount: 1, ScrapedPodCount: 1, CoveragePercent: 100,
+			},
+		},
+		{
+			name:    "no running pods",
+			running: map[string][]string{},
+			metrics: podMetrics("shop", "web-0"),
+			want:    models.ScrapeCoverage{Namespaces: []models.NamespaceCoverage{}, CoveragePercent: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := defaultValueFormat.scrapeCoverage(tt.running, tt.metrics)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scrapeCoverage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
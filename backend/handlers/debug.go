@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// GetQueryPreview lists the PromQL queries the backend client would run for the current metrics
+// of a namespace and the historical analysis of a container, without running them
+func (h *Handler) GetQueryPreview(w http.ResponseWriter, r *http.Request) {
+	if !h.enableDebug {
+		http.Error(w, "Debug endpoints are disabled - set ENABLE_DEBUG_ENDPOINTS=true to enable", http.StatusForbidden)
+		return
+	}
+
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	previewer, ok := unscoped(metricsClient).(k8s.QueryPreviewer)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Query previews are not supported by the %s backend", metricsClient.GetClientType()), http.StatusNotImplemented)
+		return
+	}
+
+	// Get parameters
+	namespace := namespaceParam(r)
+	podName := r.URL.Query().Get("pod")
+	container := r.URL.Query().Get("container")
+	if (podName != "" && !validLabelValue(podName)) || (container != "" && !validLabelValue(container)) {
+		http.Error(w, "invalid pod or container name", http.StatusBadRequest)
+		return
+	}
+
+	preview := previewer.PreviewQueries(namespace, podName, container)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := models.QueryPreview{
+		Backend:       metricsClient.GetClientType(),
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: container,
+		Current:       preview.Current,
+		Historical:    preview.Historical,
+	}
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestGetQueryPreview(t *testing.T) {
+	config := k8s.MetricsClientConfig{
+		URL: "http://prometheus.invalid",
+		Queries: k8s.QueryTemplates{
+			Metrics:      map[string]string{k8s.QueryMemoryUsage: "container_memory_rss"},
+			ExtraFilters: `cluster="prod"`,
+		},
+	}
+	prometheus, err := k8s.NewPrometheusClient(config)
+	if err != nil {
+		t.Fatalf("NewPrometheusClient() error = %v", err)
+	}
+	h := newTestHandler(prometheus)
+	h.enableDebug = true
+
+	var response models.QueryPreview
+	decodeResponse(t, serve(h.GetQueryPreview, "/api/debug/queries?namespace=shop"), &response)
+	if response.Backend != "prometheus" || response.Namespace != "shop" {
+		t.Errorf("preview of backend %q and namespace %q, want prometheus and shop", response.Backend, response.Namespace)
+	}
+
+	// Every query uses the configured metric names and filters, without running them
+	const filters = `container!="POD", container!=""`
+	wantCurrent := []string{
+		`rate(container_cpu_usage_seconds_total{` + filters + `, namespace="shop", cluster="prod"}[300s])`,
+		`container_memory_rss{` + filters + `, namespace="shop", cluster="prod"}`,
+		`timestamp(container_memory_rss{` + filters + `, namespace="shop", cluster="prod"})`,
+		`kube_pod_container_resource_requests{` + filters + `, resource="cpu", namespace="shop", cluster="prod"}`,
+		`kube_pod_container_resource_limits{` + filters + `, resource="cpu", namespace="shop", cluster="prod"}`,
+		`kube_pod_container_resource_requests{` + filters + `, resource="memory", namespace="shop", cluster="prod"}`,
+		`kube_pod_container_resource_limits{` + filters + `, resource="memory", namespace="shop", cluster="prod"}`,
+		`max by (namespace, pod) (kube_pod_start_time{namespace=~"shop", cluster="prod"})`,
+		`kube_pod_labels{namespace=~"shop", cluster="prod"}`,
+	}
+	if !reflect.DeepEqual(response.Current, wantCurrent) {
+		t.Errorf("current queries =\n%s\nwant\n%s", strings.Join(response.Current, "\n"), strings.Join(wantCurrent, "\n"))
+	}
+	if len(response.Historical) != 2 {
+		t.Errorf("historical queries without a container = %q, want the pod listing and start time queries", response.Historical)
+	}
+
+	// A container adds its own historical queries, filtered by namespace, pod and container
+	response = models.QueryPreview{}
+	decodeResponse(t, serve(h.GetQueryPreview, "/api/debug/queries?namespace=shop&pod=web-0&container=app"), &response)
+	if response.PodName != "web-0" || response.ContainerName != "app" {
+		t.Errorf("preview of %s/%s, want web-0/app", response.PodName, response.ContainerName)
+	}
+	if len(response.Historical) != 8 {
+		t.Fatalf("got %d historical queries, want 8: %q", len(response.Historical), response.Historical)
+	}
+	for _, query := range response.Historical[2:] {
+		if !strings.Contains(query, `namespace="shop", pod="web-0", container="app"`) || !strings.Contains(query, `cluster="prod"`) {
+			t.Errorf("historical query %q lacks the container or extra filters", query)
+		}
+	}
+	if !strings.HasPrefix(response.Historical[3], "container_memory_rss{") {
+		t.Errorf("historical memory query = %q, want the configured memory metric", response.Historical[3])
+	}
+}
+
+func TestGetQueryPreviewErrors(t *testing.T) {
+	prometheus, err := k8s.NewPrometheusClient(k8s.MetricsClientConfig{URL: "http://prometheus.invalid"})
+	if err != nil {
+		t.Fatalf("NewPrometheusClient() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		client   k8s.MetricsClient
+		disabled bool
+		target   string
+		wantCode int
+	}{
+		{"debug endpoints disabled", prometheus, true, "/api/debug/queries", http.StatusForbidden},
+		{"backend without previews", &fakeMetricsClient{}, false, "/api/debug/queries", http.StatusNotImplemented},
+		{"invalid pod name", prometheus, false, `/api/debug/queries?pod=web"0&container=app`, http.StatusBadRequest},
+		{"all namespaces", prometheus, false, "/api/debug/queries", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(tt.client)
+			h.enableDebug = !tt.disabled
+			if rec := serve(h.GetQueryPreview, tt.target); rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// Container classifications compared between analysis runs
+const (
+	classificationOverProvisioned  = "over_provisioned"
+	classificationUnderProvisioned = "under_provisioned"
+	classificationWellOptimized    = "well_optimized"
+)
+
+// defaultDiffThreshold is the efficiency change (in percentage points) reported by the diff endpoint
+const defaultDiffThreshold = 10.0
+
+// analysisBaselines keeps the previous analysis run per cluster and namespace in memory
+type analysisBaselines struct {
+	mu   sync.Mutex
+	runs *lruCache[models.HistoricalAnalysisList]
+}
+
+// newAnalysisBaselines creates an empty baseline store holding up to maxEntries runs
+func newAnalysisBaselines(maxEntries int) *analysisBaselines {
+	return &analysisBaselines{runs: newLRUCache[models.HistoricalAnalysisList](maxEntries)}
+}
+
+// swap stores run as the baseline for key and returns the previous one, if any
+func (b *analysisBaselines) swap(key string, run models.HistoricalAnalysisList) (models.HistoricalAnalysisList, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	previous, ok := b.runs.get(key)
+	b.runs.put(key, run)
+	return previous, ok
+}
+
+// baselineKey identifies the analysis runs of one namespace in one cluster
+func baselineKey(cluster, namespace string) string {
+	return cluster + "/" + namespace
+}
+
+// GetAnalysisDiff runs the historical analysis and returns the containers whose classification
+// or efficiency changed since the previous run for the same cluster and namespace
+func (h *Handler) GetAnalysisDiff(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Historical analysis not available - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	threshold, err := parseFloatParam(r, "threshold", defaultDiffThreshold)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Get namespace from query parameter, empty for all namespaces
+	namespace := namespaceParam(r)
+
+	historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, k8s.HistoricalOptions{})
+	if err != nil {
+		log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	modelMetrics := []models.HistoricalMetrics{}
+	for _, hm := range historicalData {
+		modelMetrics = append(modelMetrics, h.format.convertHistoricalMetrics(hm))
+	}
+	run := models.HistoricalAnalysisList{
+		HistoricalMetrics: modelMetrics,
+		GeneratedAt:       time.Now(),
+	}
+
+	response := models.AnalysisDiff{
+		GeneratedAt: run.GeneratedAt,
+		Threshold:   threshold,
+		Changes:     []models.AnalysisChange{},
+	}
+	if baseline, ok := h.baselines.swap(baselineKey(h.sourceKey(r), namespace), run); ok {
+		response.BaselineAt = &baseline.GeneratedAt
+		response.Changes = diffAnalysis(baseline.HistoricalMetrics, run.HistoricalMetrics, threshold)
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// classifyAnalysis classifies a container the same way as the analysis summary
+func classifyAnalysis(analysis models.UsageAnalysis) string {
+	waste := analysis.ResourceWaste
+	switch {
+	case waste.CPUOverProvisioned || waste.MemoryOverProvisioned:
+		return classificationOverProvisioned
+	case waste.CPUUnderProvisioned || waste.MemoryUnderProvisioned:
+		return classificationUnderProvisioned
+	default:
+		return classificationWellOptimized
+	}
+}
+
+// diffAnalysis returns the containers present in both runs whose classification changed or whose
+// CPU or memory efficiency moved by at least threshold percentage points
+func diffAnalysis(baseline, current []models.HistoricalMetrics, threshold float64) []models.AnalysisChange {
+	previous := make(map[string]models.HistoricalMetrics, len(baseline))
+	for _, hm := range baseline {
+		previous[hm.Namespace+"/"+hm.PodName+"/"+hm.ContainerName] = hm
+	}
+
+	changes := []models.AnalysisChange{}
+	for _, hm := range current {
+		before, ok := previous[hm.Namespace+"/"+hm.PodName+"/"+hm.ContainerName]
+		if !ok {
+			continue
+		}
+
+		change := models.AnalysisChange{
+			PodName:                  hm.PodName,
+			Namespace:                hm.Namespace,
+			ContainerName:            hm.ContainerName,
+			PreviousClassification:   classifyAnalysis(before.Analysis),
+			Classification:           classifyAnalysis(hm.Analysis),
+			PreviousCPUEfficiency:    before.Analysis.CPUEfficiency,
+			CPUEfficiency:            hm.Analysis.CPUEfficiency,
+			PreviousMemoryEfficiency: before.Analysis.MemoryEfficiency,
+			MemoryEfficiency:         hm.Analysis.MemoryEfficiency,
+		}
+		if change.PreviousClassification == change.Classification &&
+			math.Abs(change.CPUEfficiency-change.PreviousCPUEfficiency) < threshold &&
+			math.Abs(change.MemoryEfficiency-change.PreviousMemoryEfficiency) < threshold {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Namespace != changes[j].Namespace {
+			return changes[i].Namespace < changes[j].Namespace
+		}
+		if changes[i].PodName != changes[j].PodName {
+			return changes[i].PodName < changes[j].PodName
+		}
+		return changes[i].ContainerName < changes[j].ContainerName
+	})
+
+	return changes
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// changedPods returns the namespace/pod and classification change of every diff entry
+func changedPods(changes []models.AnalysisChange) []string {
+	names := []string{}
+	for _, change := range changes {
+		names = append(names, change.Namespace+"/"+change.PodName+": "+change.PreviousClassification+" -> "+change.Classification)
+	}
+	return names
+}
+
+func TestDiffAnalysis(t *testing.T) {
+	over := k8s.ResourceWasteAnalysis{CPUOverProvisioned: true}
+	under := k8s.ResourceWasteAnalysis{MemoryUnderProvisioned: true}
+	optimized := k8s.ResourceWasteAnalysis{}
+
+	tests := []struct {
+		name     string
+		baseline []k8s.HistoricalMetrics
+		current  []k8s.HistoricalMetrics
+		want     []string
+	}{
+		{
+			name:     "over-provisioned to well-optimized",
+			baseline: []k8s.HistoricalMetrics{analyzedContainer("shop", "web-0", over, 10)},
+			current:  []k8s.HistoricalMetrics{analyzedContainer("shop", "web-0", optimized, 12)},
+			want:     []string{"shop/web-0: over_provisioned -> well_optimized"},
+		},
+		{
+			name:     "unchanged",
+			baseline: []k8s.HistoricalMetrics{analyzedContainer("shop", "web-0", optimized, 50)},
+			current:  []k8s.HistoricalMetrics{analyzedContainer("shop", "web-0", optimized, 55)},
+			want:     []string{},
+		},
+		{
+			name:     "efficiency change at the threshold",
+			baseline: []k8s.HistoricalMetrics{analyzedContainer("shop", "web-0", optimized, 40)},
+			current:  []k8s.HistoricalMetrics{analyzedContainer("shop", "web-0", optimized, 50)},
+			want:     []string{"shop/web-0: well_optimized -> well_optimized"},
+		},
+		{
+			name:     "new and removed containers are skipped",
+			baseline: []k8s.HistoricalMetrics{analyzedContainer("shop", "old-0", over, 10)},
+			current:  []k8s.HistoricalMetrics{analyzedContainer("shop", "new-0", under, 95)},
+			want:     []string{},
+		},
+		{
+			name: "sorted by namespace and pod",
+			baseline: []k8s.HistoricalMetrics{
+				analyzedContainer("shop", "web-1", over, 10),
+				analyzedContainer("billing", "api-0", optimized, 50),
+				analyzedContainer("shop", "web-0", optimized, 50),
+			},
+			current: []k8s.HistoricalMetrics{
+				analyzedContainer("shop", "web-1", optimized, 50),
+				analyzedContainer("billing", "api-0", under, 50),
+				analyzedContainer("shop", "web-0", over, 50),
+			},
+			want: []string{
+				"billing/api-0: well_optimized -> under_provisioned",
+				"shop/web-0: well_optimized -> over_provisioned",
+				"shop/web-1: over_provisioned -> well_optimized",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var baseline, current []models.HistoricalMetrics
+			for _, hm := range tt.baseline {
+				baseline = append(baseline, defaultValueFormat.convertHistoricalMetrics(hm))
+			}
+			for _, hm := range tt.current {
+				current = append(current, defaultValueFormat.convertHistoricalMetrics(hm))
+			}
+			if got := changedPods(diffAnalysis(baseline, current, defaultDiffThreshold)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffAnalysis() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAnalysisDiff(t *testing.T) {
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{
+		analyzedContainer("shop", "web-0", k8s.ResourceWasteAnalysis{CPUOverProvisioned: true}, 10),
+		analyzedContainer("billing", "api-0", k8s.ResourceWasteAnalysis{}, 50),
+	}}
+	h := newTestHandler(client)
+
+	// The first run has no baseline to compare against
+	var first models.AnalysisDiff
+	decodeResponse(t, serve(h.GetAnalysisDiff, "/api/pods/analysis/diff"), &first)
+	if first.BaselineAt != nil || len(first.Changes) != 0 {
+		t.Fatalf("first run = %+v, want no baseline or changes", first)
+	}
+
+	client.historical = []k8s.HistoricalMetrics{
+		analyzedContainer("shop", "web-0", k8s.ResourceWasteAnalysis{}, 55),
+		analyzedContainer("billing", "api-0", k8s.ResourceWasteAnalysis{}, 52),
+	}
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"compared to the first run", "/api/pods/analysis/diff", []string{"shop/web-0: over_provisioned -> well_optimized"}},
+		{"compared to the second run", "/api/pods/analysis/diff", []string{}},
+		{"baselines are kept per namespace", "/api/pods/analysis/diff?namespace=shop", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diff models.AnalysisDiff
+			decodeResponse(t, serve(h.GetAnalysisDiff, tt.target), &diff)
+			if got := changedPods(diff.Changes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if rec := serve(h.GetAnalysisDiff, "/api/pods/analysis/diff?threshold=lots"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid threshold status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
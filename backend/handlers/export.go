@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// GetExport returns a ZIP report bundle with the current pod metrics, the historical
+// analysis summary and the per-container recommendations
+func (h *Handler) GetExport(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Get namespace from query parameter
+	namespace := namespaceParam(r)
+
+	now := time.Now()
+	result, err := h.currentPodMetrics(ctx, metricsClient, h.sourceKey(r), namespace, "", "", now)
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pods := []models.PodMetrics{}
+	for _, metric := range result.metrics {
+		pods = append(pods, h.format.convertMetricsToModelMetric(metric))
+	}
+
+	historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, k8s.HistoricalOptions{})
+	if err != nil {
+		log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	modelMetrics := []models.HistoricalMetrics{}
+	recommendations := []models.ContainerRecommendations{}
+	for _, hm := range historicalData {
+		modelMetrics = append(modelMetrics, h.format.convertHistoricalMetrics(hm))
+		recommendations = append(recommendations, models.ContainerRecommendations{
+			PodName:         hm.PodName,
+			Namespace:       hm.Namespace,
+			ContainerName:   hm.ContainerName,
+			Recommendations: append([]string{}, hm.Analysis.Recommendations...),
+		})
+	}
+
+	h.format.annotateNamespaceOutliers(modelMetrics, h.outlierStdDevs)
+
+	// Build the archive in memory so failures can still be reported with an error status
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"pods.json", models.PodMetricsList{Pods: pods, Warnings: result.warnings}},
+		{"analysis-summary.json", h.format.generateAnalysisSummary(modelMetrics, h.histogramBuckets)},
+		{"recommendations.json", recommendations},
+	}
+	for _, file := range files {
+		if err := writeZipJSON(archive, file.name, file.content, now); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if h.maxResponseBytes > 0 && int64(buf.Len()) > h.maxResponseBytes {
+		log.Printf("WARN: Rejected %d byte export exceeding MAX_RESPONSE_BYTES (%d)", buf.Len(), h.maxResponseBytes)
+		http.Error(w, responseTooLargeMessage(h.maxResponseBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	scope := namespace
+	if scope == "" {
+		scope = "all-namespaces"
+	}
+	filename := fmt.Sprintf("pod-report-%s-%s.zip", scope, now.UTC().Format("20060102T150405Z"))
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+
+	// Write response
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing export: %v", err)
+	}
+}
+
+// writeZipJSON adds content to the archive as an indented JSON file
+func writeZipJSON(archive *zip.Writer, name string, content interface{}, modified time.Time) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(content); err != nil {
+		return fmt.Errorf("failed to write %s to export: %w", name, err)
+	}
+	return nil
+}
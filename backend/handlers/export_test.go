@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// readExport returns the files of an export archive by name
+func readExport(t *testing.T, body []byte) map[string][]byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s error = %v", file.Name, err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(rc); err != nil {
+			t.Fatalf("read %s error = %v", file.Name, err)
+		}
+		rc.Close()
+		files[file.Name] = buf.Bytes()
+	}
+	return files
+}
+
+func TestGetExport(t *testing.T) {
+	client := &fakeMetricsClient{
+		current: []k8s.PodMetric{
+			{Name: "web-0", Namespace: "shop", ContainerName: "app"},
+			{Name: "api-0", Namespace: "billing", ContainerName: "app"},
+		},
+		historical: alertTestData,
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		wantFilename string
+		wantPods     []string
+		wantAnalyzed int
+	}{
+		{"all namespaces", "/api/pods/export", `^attachment; filename="pod-report-all-namespaces-\d{8}T\d{6}Z\.zip"$`, []string{"api-0", "web-0"}, 3},
+		{"one namespace", "/api/pods/export?namespace=shop", `^attachment; filename="pod-report-shop-\d{8}T\d{6}Z\.zip"$`, []string{"web-0"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTestHandler(client).GetExport, tt.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/zip" {
+				t.Errorf("Content-Type = %q, want application/zip", got)
+			}
+			if got := rec.Header().Get("Content-Disposition"); !regexp.MustCompile(tt.wantFilename).MatchString(got) {
+				t.Errorf("Content-Disposition = %q, want a match of %s", got, tt.wantFilename)
+			}
+
+			files := readExport(t, rec.Body.Bytes())
+			names := []string{}
+			for name := range files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if want := []string{"analysis-summary.json", "pods.json", "recommendations.json"}; !reflect.DeepEqual(names, want) {
+				t.Fatalf("archive files = %v, want %v", names, want)
+			}
+
+			var pods models.PodMetricsList
+			if err := json.Unmarshal(files["pods.json"], &pods); err != nil {
+				t.Fatalf("decode pods.json error = %v", err)
+			}
+			podNames := []string{}
+			for _, pod := range pods.Pods {
+				podNames = append(podNames, pod.Name)
+			}
+			sort.Strings(podNames)
+			if !reflect.DeepEqual(podNames, tt.wantPods) {
+				t.Errorf("pods = %v, want %v", podNames, tt.wantPods)
+			}
+
+			var summary models.AnalysisSummary
+			if err := json.Unmarshal(files["analysis-summary.json"], &summary); err != nil {
+				t.Fatalf("decode analysis-summary.json error = %v", err)
+			}
+			if summary.TotalPodsAnalyzed != tt.wantAnalyzed {
+				t.Errorf("pods analyzed = %d, want %d", summary.TotalPodsAnalyzed, tt.wantAnalyzed)
+			}
+
+			var recommendations []models.ContainerRecommendations
+			if err := json.Unmarshal(files["recommendations.json"], &recommendations); err != nil {
+				t.Fatalf("decode recommendations.json error = %v", err)
+			}
+			if len(recommendations) != tt.wantAnalyzed {
+				t.Errorf("recommendations = %d containers, want %d", len(recommendations), tt.wantAnalyzed)
+			}
+			for _, container := range recommendations {
+				if len(container.Recommendations) == 0 {
+					t.Errorf("%s/%s has no recommendations", container.Namespace, container.PodName)
+				}
+			}
+		})
+	}
+}
+
+func TestGetExportTooLarge(t *testing.T) {
+	h := newTestHandler(&fakeMetricsClient{historical: alertTestData})
+	h.maxResponseBytes = 64
+
+	rec := serve(h.GetExport, "/api/pods/export")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("Content-Disposition = %q, want none", got)
+	}
+}
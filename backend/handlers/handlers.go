@@ -3,79 +3,515 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 	"github.com/bean-stalk-k8s/backend/k8s"
 	"github.com/bean-stalk-k8s/backend/models"
+	"github.com/bean-stalk-k8s/backend/units"
+	"golang.org/x/sync/singleflight"
 )
 
-// Handler contains metrics client for unified data access
+// defaultFloatPrecision is the default number of decimals derived values are rounded to
+const defaultFloatPrecision = 2
+
+// defaultMemoryPressureThreshold is the default percentage of the memory limit above which a pod is under memory pressure
+const defaultMemoryPressureThreshold = 90.0
+
+// valueFormat is how response values are formatted and derived. The helpers building response values
+// are its methods, so a Handler's settings reach them without package state.
+type valueFormat struct {
+	// memoryUnitBase is the memory unit base used for every formatted value, set from MEMORY_UNIT_BASE
+	memoryUnitBase units.Base
+
+	// floatPrecision is the number of decimals derived values such as efficiencies and percentages are
+	// rounded to, set from FLOAT_PRECISION; negative keeps full precision
+	floatPrecision int
+
+	// memoryPressureThreshold is the percentage of the memory limit above which usage is flagged as memory
+	// pressure, set from MEMORY_PRESSURE_THRESHOLD
+	memoryPressureThreshold float64
+}
+
+// defaultValueFormat is the value format without MEMORY_UNIT_BASE, FLOAT_PRECISION and MEMORY_PRESSURE_THRESHOLD
+var defaultValueFormat = valueFormat{
+	memoryUnitBase:          units.Binary,
+	floatPrecision:          defaultFloatPrecision,
+	memoryPressureThreshold: defaultMemoryPressureThreshold,
+}
+
+// Handler contains metrics client for unified data access.
+//
+// A Handler serves concurrent requests. Its fields are set by NewHandler and never reassigned, so they
+// are read without locking; the caches and stores they point to synchronize their own state. Per-request
+// state, such as the metrics client selected by clientFor, is kept in locals and the request context,
+// never on the Handler. Results shared between requests, from the caches or coalesced queries, must not
+// be modified.
 type Handler struct {
-	metricsClient k8s.MetricsClient
+	clusters         map[string]k8s.MetricsClient // Metrics client per configured cluster
+	clusterNames     []string                     // Cluster names in configuration order
+	defaultCluster   string                       // Used when no cluster parameter is given
+	backends         map[string]k8s.MetricsClient // Clients selectable with the backend parameter, empty unless METRICS_ALTERNATE_BACKENDS is set
+	staleThreshold   time.Duration
+	enableRawQuery   bool
+	enableDebug      bool // Enables the /api/debug endpoints
+	// Upper bounds of the efficiency histogram buckets, in percent
+	histogramBuckets []float64
+	outlierStdDevs   float64       // Deviation beyond which a container is an outlier in its namespace
+	maxResponseBytes int64         // Larger responses are rejected with 413, 0 disables the limit
+	maxAnalysisRange time.Duration // Longest analysis window accepted with the range or days parameter
+	// Default headroom, in percent, added to recommended requests
+	headroomDefaults recommendationHeadroom
+	// Memory unit base, precision and memory pressure threshold of response values
+	format           valueFormat
+	ownerLabel       string              // Pod label attributing pods to an owner, sanitized like kube-state-metrics does
+	namespaces       *namespaceAllowlist // nil unless NAMESPACE_ALLOWLIST is set
+	auth             *authenticator      // nil unless ENABLE_AUTH is set
+	alerter          *Alerter            // nil unless ENABLE_ALERTS is set
+	thresholds       *ThresholdExporter  // nil unless ENABLE_THRESHOLD_EXPORT is set
+	baselines        *analysisBaselines  // Previous analysis run per cluster and namespace, for diffs
+	jobs             *analysisJobs       // Background analyses started with /api/pods/analysis/jobs
+	cache            *metricsCache       // nil unless METRICS_ENABLE_CACHING is set
+	analysisCache    *analysisCache      // nil unless ANALYSIS_CACHE_FRESH is set
+	emptyResults     *emptyResultCache   // nil when EMPTY_RESULT_CACHE_TTL is 0
+	headroom         *headroomCache      // nil unless METRICS_ENABLE_HEADROOM is set
+	// Kubernetes API of the default cluster, nil outside a cluster unless KUBERNETES_API_URL is set
+	kube             *k8s.KubernetesClient
+	// Coalesces concurrent identical current pod metrics queries
+	podMetricsFlight singleflight.Group
+	// Cached latency probe of the default cluster's backend, reported by /health
+	probe backendProbe
+	// Cached readiness probe of the default cluster's backend, for /readyz
+	readiness backendProbe
 }
 
-// NewHandler creates a new Handler with configurable metrics backend (Prometheus or VictoriaMetrics)
+// Limits applied to raw queries from /api/query
+const (
+	maxRawQueryLength = 2048
+	maxRawQuerySeries = 1000
+	rawQueryTimeout   = 10 * time.Second
+)
+
+// NewHandler creates a new Handler with configurable metrics backend (Prometheus, VictoriaMetrics or remote read)
 func NewHandler() (*Handler, error) {
 	// Get metrics backend configuration
 	backend := getEnvWithDefault("METRICS_BACKEND", "victoriametrics")
-	
-	// Get metrics URL based on backend with support for new and legacy env vars
-	var metricsURL string
-	switch backend {
-	case "victoriametrics":
-		// Try new env var first, then legacy, then default
-		metricsURL = getEnvWithDefault("METRICS_VICTORIAMETRICS_URL", 
-			getEnvWithDefault("VICTORIAMETRICS_URL", 
-				"http://victoria-metrics-victoria-metrics-cluster-vmselect.pod-metrics-dashboard.svc.cluster.local:8481/select/0/prometheus"))
-	case "prometheus":
-		// Try new env var first, then legacy, then default  
-		metricsURL = getEnvWithDefault("METRICS_PROMETHEUS_URL",
-			getEnvWithDefault("PROMETHEUS_URL",
-				"http://prometheus-stack-kube-prom-prometheus.pod-metrics-dashboard.svc.cluster.local:9090"))
-	default: // fallback to victoriametrics
-		metricsURL = getEnvWithDefault("METRICS_VICTORIAMETRICS_URL",
-			getEnvWithDefault("VICTORIAMETRICS_URL",
-				"http://victoria-metrics-victoria-metrics-cluster-vmselect.pod-metrics-dashboard.svc.cluster.local:8481/select/0/prometheus"))
-	}
+	metricsURL := backendURL(backend)
 
 	// Read advanced configuration from environment variables
 	timeout := getEnvWithDefault("METRICS_TIMEOUT", "30s")
 	retryAttempts := getEnvIntWithDefault("METRICS_RETRY_ATTEMPTS", 3)
+	if retryAttempts < 0 {
+		log.Printf("WARN: Invalid value for METRICS_RETRY_ATTEMPTS: %d, using default: 3", retryAttempts)
+		retryAttempts = 3
+	}
 	enableCaching := getEnvBoolWithDefault("METRICS_ENABLE_CACHING", false)
 	enableHistorical := getEnvBoolWithDefault("METRICS_ENABLE_HISTORICAL", true)
+	enableHeadroom := getEnvBoolWithDefault("METRICS_ENABLE_HEADROOM", false)
 	enableTrend := getEnvBoolWithDefault("METRICS_ENABLE_TREND", true)
+	enableContainerStatus := getEnvBoolWithDefault("METRICS_ENABLE_CONTAINER_STATUS", false)
+	enableRestartTrend := getEnvBoolWithDefault("METRICS_ENABLE_RESTART_TREND", false)
+	vmUseExport := getEnvBoolWithDefault("METRICS_VM_USE_EXPORT", false)
+	staleThreshold := getEnvDurationWithDefault("STALE_THRESHOLD", 2*time.Minute)
+	readinessCacheTTL := getEnvDurationWithDefault("READINESS_CACHE_TTL", defaultReadinessCacheTTL)
+	if readinessCacheTTL <= 0 {
+		log.Printf("WARN: Invalid value for READINESS_CACHE_TTL: %s, using default: %s", readinessCacheTTL, defaultReadinessCacheTTL)
+		readinessCacheTTL = defaultReadinessCacheTTL
+	}
+	emptyResultTTL := getEnvDurationWithDefault("EMPTY_RESULT_CACHE_TTL", defaultEmptyResultTTL)
+	if emptyResultTTL < 0 {
+		log.Printf("WARN: Invalid value for EMPTY_RESULT_CACHE_TTL: %s, using default: %s", emptyResultTTL, defaultEmptyResultTTL)
+		emptyResultTTL = defaultEmptyResultTTL
+	}
+	emptyResultMaxTTL := getEnvDurationWithDefault("EMPTY_RESULT_CACHE_MAX_TTL", defaultEmptyResultMaxTTL)
+	if emptyResultMaxTTL < emptyResultTTL {
+		log.Printf("WARN: Invalid value for EMPTY_RESULT_CACHE_MAX_TTL: %s, using EMPTY_RESULT_CACHE_TTL: %s", emptyResultMaxTTL, emptyResultTTL)
+		emptyResultMaxTTL = emptyResultTTL
+	}
+	analysisCacheFresh := getEnvDurationWithDefault("ANALYSIS_CACHE_FRESH", 0)
+	if analysisCacheFresh < 0 {
+		log.Printf("WARN: Invalid value for ANALYSIS_CACHE_FRESH: %s, using default: 0s", analysisCacheFresh)
+		analysisCacheFresh = 0
+	}
+	analysisCacheStale := getEnvDurationWithDefault("ANALYSIS_CACHE_STALE", defaultAnalysisCacheStale)
+	if analysisCacheStale < 0 {
+		log.Printf("WARN: Invalid value for ANALYSIS_CACHE_STALE: %s, using default: %s", analysisCacheStale, defaultAnalysisCacheStale)
+		analysisCacheStale = defaultAnalysisCacheStale
+	}
+	cacheMaxEntries := getEnvIntWithDefault("CACHE_MAX_ENTRIES", defaultCacheMaxEntries)
+	if cacheMaxEntries <= 0 {
+		log.Printf("WARN: Invalid value for CACHE_MAX_ENTRIES: %d, using default: %d", cacheMaxEntries, defaultCacheMaxEntries)
+		cacheMaxEntries = defaultCacheMaxEntries
+	}
+	instantLookback := getEnvDurationWithDefault("INSTANT_LOOKBACK", k8s.DefaultInstantLookback)
+	if instantLookback < 0 {
+		log.Printf("WARN: Invalid value for INSTANT_LOOKBACK: %s, using default: %s", instantLookback, k8s.DefaultInstantLookback)
+		instantLookback = k8s.DefaultInstantLookback
+	}
+	scrapeInterval := getEnvDurationWithDefault("SCRAPE_INTERVAL", 0)
+	if scrapeInterval < 0 {
+		log.Printf("WARN: Invalid value for SCRAPE_INTERVAL: %s, using the default %s rate window", scrapeInterval, k8s.DefaultRateWindow)
+		scrapeInterval = 0
+	}
+	queries := loadQueryTemplates()
+	enableRawQuery := getEnvBoolWithDefault("ENABLE_RAW_QUERY", false)
+	enableDebug := getEnvBoolWithDefault("ENABLE_DEBUG_ENDPOINTS", false)
+	wasteLow := getEnvFloatWithDefault("WASTE_LOW_THRESHOLD", k8s.DefaultWasteLowThreshold)
+	wasteHigh := getEnvFloatWithDefault("WASTE_HIGH_THRESHOLD", k8s.DefaultWasteHighThreshold)
+	cpuTrendThreshold := getEnvFloatWithDefault("CPU_TREND_THRESHOLD", k8s.DefaultTrendThreshold)
+	if cpuTrendThreshold <= 0 {
+		log.Printf("WARN: Invalid value for CPU_TREND_THRESHOLD: %g, using default: %g", cpuTrendThreshold, k8s.DefaultTrendThreshold)
+		cpuTrendThreshold = k8s.DefaultTrendThreshold
+	}
+	memoryTrendThreshold := getEnvFloatWithDefault("MEMORY_TREND_THRESHOLD", k8s.DefaultTrendThreshold)
+	if memoryTrendThreshold <= 0 {
+		log.Printf("WARN: Invalid value for MEMORY_TREND_THRESHOLD: %g, using default: %g", memoryTrendThreshold, k8s.DefaultTrendThreshold)
+		memoryTrendThreshold = k8s.DefaultTrendThreshold
+	}
+	maxSamples := getEnvIntWithDefault("MAX_SAMPLES_PER_SERIES", k8s.DefaultMaxSamplesPerSeries)
+	alignRangeQueries := getEnvBoolWithDefault("ALIGN_RANGE_QUERIES", true)
+	minTrendSamples := getEnvIntWithDefault("MIN_TREND_SAMPLES", k8s.DefaultMinTrendSamples)
+	duplicateAggregation := getEnvWithDefault("DUPLICATE_SERIES_AGGREGATION", k8s.MergeMax)
+	if duplicateAggregation != k8s.MergeMax && duplicateAggregation != k8s.MergeMin && duplicateAggregation != k8s.MergeAvg {
+		log.Printf("WARN: Invalid value for DUPLICATE_SERIES_AGGREGATION: %s, using default: %s", duplicateAggregation, k8s.MergeMax)
+		duplicateAggregation = k8s.MergeMax
+	}
+	efficiencyBasis := getEnvWithDefault("EFFICIENCY_BASIS", k8s.EfficiencyAverage)
+	if efficiencyBasis != k8s.EfficiencyAverage && efficiencyBasis != k8s.EfficiencyP95 && efficiencyBasis != k8s.EfficiencyPeak {
+		log.Printf("WARN: Invalid value for EFFICIENCY_BASIS: %s, using default: %s", efficiencyBasis, k8s.EfficiencyAverage)
+		efficiencyBasis = k8s.EfficiencyAverage
+	}
+	if wasteLow >= wasteHigh {
+		return nil, fmt.Errorf("WASTE_LOW_THRESHOLD (%g) must be lower than WASTE_HIGH_THRESHOLD (%g)", wasteLow, wasteHigh)
+	}
+	unitBase, ok := units.ParseBase(getEnvWithDefault("MEMORY_UNIT_BASE", string(units.Binary)))
+	if !ok {
+		log.Printf("WARN: Invalid value for MEMORY_UNIT_BASE: %s, using default: %s", os.Getenv("MEMORY_UNIT_BASE"), units.Binary)
+		unitBase = units.Binary
+	}
+	format := valueFormat{memoryUnitBase: unitBase}
+	format.floatPrecision = getEnvIntWithDefault("FLOAT_PRECISION", defaultFloatPrecision)
+	if format.floatPrecision > 15 {
+		log.Printf("WARN: Invalid value for FLOAT_PRECISION: %d, using default: %d", format.floatPrecision, defaultFloatPrecision)
+		format.floatPrecision = defaultFloatPrecision
+	}
+	ownerLabel := k8s.SanitizeLabelName(getEnvWithDefault("OWNER_LABEL", "team"))
+	format.memoryPressureThreshold = getEnvFloatWithDefault("MEMORY_PRESSURE_THRESHOLD", defaultMemoryPressureThreshold)
+	if format.memoryPressureThreshold <= 0 || format.memoryPressureThreshold > 100 {
+		log.Printf("WARN: Invalid value for MEMORY_PRESSURE_THRESHOLD: %g, using default: %g", format.memoryPressureThreshold, defaultMemoryPressureThreshold)
+		format.memoryPressureThreshold = defaultMemoryPressureThreshold
+	}
+	histogramBuckets := getEnvBucketsWithDefault("EFFICIENCY_HISTOGRAM_BUCKETS", []float64{20, 40, 60, 80, 100})
+	outlierStdDevs := getEnvFloatWithDefault("OUTLIER_STD_DEVS", defaultOutlierStdDevs)
+	if outlierStdDevs <= 0 {
+		log.Printf("WARN: Invalid value for OUTLIER_STD_DEVS: %g, using default: %g", outlierStdDevs, defaultOutlierStdDevs)
+		outlierStdDevs = defaultOutlierStdDevs
+	}
+	maxInflightQueries := getEnvIntWithDefault("METRICS_MAX_INFLIGHT_QUERIES", 0)
+	if maxInflightQueries < 0 {
+		log.Printf("WARN: Invalid value for METRICS_MAX_INFLIGHT_QUERIES: %d, using default: 0 (unlimited)", maxInflightQueries)
+		maxInflightQueries = 0
+	}
+	cpuHeadroom := getEnvFloatWithDefault("CPU_HEADROOM_PERCENT", defaultCPUHeadroomPercent)
+	if cpuHeadroom < 0 {
+		log.Printf("WARN: Invalid value for CPU_HEADROOM_PERCENT: %g, using default: %g", cpuHeadroom, defaultCPUHeadroomPercent)
+		cpuHeadroom = defaultCPUHeadroomPercent
+	}
+	memoryHeadroom := getEnvFloatWithDefault("MEMORY_HEADROOM_PERCENT", defaultMemoryHeadroomPercent)
+	if memoryHeadroom < 0 {
+		log.Printf("WARN: Invalid value for MEMORY_HEADROOM_PERCENT: %g, using default: %g", memoryHeadroom, defaultMemoryHeadroomPercent)
+		memoryHeadroom = defaultMemoryHeadroomPercent
+	}
+	analysisJobTTL := getEnvDurationWithDefault("ANALYSIS_JOB_TTL", defaultAnalysisJobTTL)
+	if analysisJobTTL <= 0 {
+		log.Printf("WARN: Invalid value for ANALYSIS_JOB_TTL: %s, using default: %s", analysisJobTTL, defaultAnalysisJobTTL)
+		analysisJobTTL = defaultAnalysisJobTTL
+	}
+	maxAnalysisJobs := getEnvIntWithDefault("ANALYSIS_JOB_MAX_RUNNING", defaultMaxRunningAnalysisJobs)
+	if maxAnalysisJobs < 1 {
+		log.Printf("WARN: Invalid value for ANALYSIS_JOB_MAX_RUNNING: %d, using default: %d", maxAnalysisJobs, defaultMaxRunningAnalysisJobs)
+		maxAnalysisJobs = defaultMaxRunningAnalysisJobs
+	}
+	maxResponseBytes := int64(getEnvIntWithDefault("MAX_RESPONSE_BYTES", defaultMaxResponseBytes))
+	if maxResponseBytes < 0 {
+		log.Printf("WARN: Invalid value for MAX_RESPONSE_BYTES: %d, using default: %d", maxResponseBytes, defaultMaxResponseBytes)
+		maxResponseBytes = defaultMaxResponseBytes
+	}
+	maxAnalysisRange := getEnvDurationWithDefault("ANALYSIS_MAX_RANGE", defaultMaxAnalysisRange)
+	if maxAnalysisRange <= 0 {
+		log.Printf("WARN: Invalid value for ANALYSIS_MAX_RANGE: %s, using default: %s", maxAnalysisRange, defaultMaxAnalysisRange)
+		maxAnalysisRange = defaultMaxAnalysisRange
+	}
+
+	// Load the TLS settings of backend connections, failing fast on unreadable certificate files
+	caFile := os.Getenv("METRICS_CA_FILE")
+	clientCertFile := os.Getenv("METRICS_CLIENT_CERT_FILE")
+	insecureSkipVerify := getEnvBoolWithDefault("METRICS_INSECURE_SKIP_VERIFY", false)
+	tlsConfig, err := k8s.LoadTLSConfig(caFile, clientCertFile, os.Getenv("METRICS_CLIENT_KEY_FILE"), insecureSkipVerify)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics backend TLS configuration: %w", err)
+	}
+	if insecureSkipVerify {
+		log.Printf("WARN: METRICS_INSECURE_SKIP_VERIFY is set, backend TLS certificates are not verified")
+	}
+
+	// Restrict the served namespaces when the dashboard is shared
+	namespaceAllowlist := os.Getenv("NAMESPACE_ALLOWLIST")
+	namespaces, err := parseNamespaceAllowlist(namespaceAllowlist)
+	if err != nil {
+		return nil, err
+	}
+
+	// Require bearer JWTs scoping each request to the namespaces granted by the token
+	var auth *authenticator
+	if getEnvBoolWithDefault("ENABLE_AUTH", false) {
+		jwksURL := os.Getenv("AUTH_JWKS_URL")
+		if jwksURL == "" {
+			return nil, fmt.Errorf("AUTH_JWKS_URL is required when ENABLE_AUTH is set")
+		}
+		refreshInterval := getEnvDurationWithDefault("AUTH_JWKS_REFRESH_INTERVAL", defaultJWKSRefreshInterval)
+		if refreshInterval <= 0 {
+			log.Printf("WARN: Invalid value for AUTH_JWKS_REFRESH_INTERVAL: %s, using default: %s", refreshInterval, defaultJWKSRefreshInterval)
+			refreshInterval = defaultJWKSRefreshInterval
+		}
+		auth = newAuthenticator(jwksURL, os.Getenv("AUTH_ISSUER"), os.Getenv("AUTH_AUDIENCE"),
+			getEnvWithDefault("AUTH_NAMESPACES_CLAIM", defaultNamespacesClaim), refreshInterval)
+		log.Printf("INFO: Authentication enabled: JWKS=%s, namespaces claim=%s", jwksURL, auth.namespacesClaim)
+	}
 
 	// Create metrics client using factory
 	factory := k8s.NewMetricsClientFactory()
 	config := k8s.MetricsClientConfig{
-		Backend: backend,
-		URL:     metricsURL,
+		Backend:               backend,
+		URL:                   metricsURL,
+		EnableContainerStatus: enableContainerStatus,
+		EnableRestartTrend:    enableRestartTrend,
+		Queries:               queries,
+		WasteLowThreshold:     wasteLow,
+		WasteHighThreshold:    wasteHigh,
+		CPUTrendThreshold:     cpuTrendThreshold,
+		MemoryTrendThreshold:  memoryTrendThreshold,
+		EfficiencyBasis:       efficiencyBasis,
+		DuplicateAggregation:  duplicateAggregation,
+		MaxSamplesPerSeries:   maxSamples,
+		AlignRangeQueries:     alignRangeQueries,
+		MinTrendSamples:       minTrendSamples,
+		VMUseExport:           vmUseExport,
+		InstantLookback:       instantLookback,
+		ScrapeInterval:        scrapeInterval,
+		MaxInflightQueries:    maxInflightQueries,
+		RetryAttempts:         retryAttempts,
+		BearerToken:           os.Getenv("METRICS_BEARER_TOKEN"),
+		TLSConfig:             tlsConfig,
+	}
+
+	// The metrics-server backend reaches the API server of KUBE_CONTEXT unless METRICS_SERVER_URL is set
+	if os.Getenv("METRICS_SERVER_URL") == "" {
+		config.KubeContext = os.Getenv("KUBE_CONTEXT")
+	}
+
+	// Create one metrics client per configured cluster
+	clusterConfigs, err := loadClusterConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+	clusters := make(map[string]k8s.MetricsClient, len(clusterConfigs))
+	var clusterNames []string
+	for _, cluster := range clusterConfigs {
+		metricsClient, err := factory.CreateClient(cluster.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s client for cluster %s: %w", cluster.config.Backend, cluster.name, err)
+		}
+		clusters[cluster.name] = scopeNamespaces(metricsClient, namespaces)
+		clusterNames = append(clusterNames, cluster.name)
+	}
+	defaultCluster := getEnvWithDefault("DEFAULT_CLUSTER", clusterNames[0])
+	if _, ok := clusters[defaultCluster]; !ok {
+		return nil, fmt.Errorf("DEFAULT_CLUSTER %q is not one of the configured clusters", defaultCluster)
 	}
 
-	metricsClient, err := factory.CreateClient(config)
+	// Create the clients selectable per request with the backend parameter
+	backends, err := loadBackendClients(factory, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create %s client: %w", backend, err)
+		return nil, err
+	}
+	for name, metricsClient := range backends {
+		backends[name] = scopeNamespaces(metricsClient, namespaces)
+	}
+
+	// Keep a background-refreshed snapshot of current metrics when caching is enabled
+	var cache *metricsCache
+	if enableCaching {
+		refreshInterval := getEnvDurationWithDefault("METRICS_CACHE_REFRESH_INTERVAL", 30*time.Second)
+		if refreshInterval <= 0 {
+			return nil, fmt.Errorf("METRICS_CACHE_REFRESH_INTERVAL must be positive, got %s", refreshInterval)
+		}
+		cache = newMetricsCache(refreshInterval)
+		log.Printf("INFO: Metrics cache enabled: refresh interval=%s", refreshInterval)
+	}
+
+	// Load historical P95 usage in the background to report headroom on current metrics. Each refresh
+	// analyzes every namespace over the default window, so it is opt-in.
+	var headroom *headroomCache
+	if enableHeadroom && !enableHistorical {
+		log.Printf("WARN: METRICS_ENABLE_HEADROOM requires METRICS_ENABLE_HISTORICAL, headroom is disabled")
+	}
+	if enableHeadroom && enableHistorical {
+		headroom = newHeadroomCache(getEnvDurationWithDefault("HEADROOM_REFRESH_INTERVAL", time.Hour), cacheMaxEntries)
+	}
+
+	// Configure webhook alerting
+	var alerter *Alerter
+	if getEnvBoolWithDefault("ENABLE_ALERTS", false) {
+		webhookURL := os.Getenv("ALERT_WEBHOOK_URL")
+		if webhookURL == "" {
+			return nil, fmt.Errorf("ALERT_WEBHOOK_URL is required when ENABLE_ALERTS is set")
+		}
+		interval := getEnvDurationWithDefault("ALERT_INTERVAL", time.Hour)
+		cooldown := getEnvDurationWithDefault("ALERT_COOLDOWN", 24*time.Hour)
+		alerter = NewAlerter(clusters[defaultCluster], webhookURL, interval, cooldown)
+		log.Printf("INFO: Alerts enabled for cluster %s: interval=%s, cooldown=%s", defaultCluster, interval, cooldown)
+	}
+
+	// Configure the export of containers crossing usage thresholds
+	var thresholds *ThresholdExporter
+	if getEnvBoolWithDefault("ENABLE_THRESHOLD_EXPORT", false) {
+		cpuPercent := getEnvFloatWithDefault("ALERT_CPU_PERCENT", 90)
+		memoryPercent := getEnvFloatWithDefault("ALERT_MEM_PERCENT", 90)
+		interval := getEnvDurationWithDefault("THRESHOLD_EXPORT_INTERVAL", time.Minute)
+		cooldown := getEnvDurationWithDefault("THRESHOLD_EXPORT_COOLDOWN", time.Hour)
+		if interval <= 0 {
+			return nil, fmt.Errorf("THRESHOLD_EXPORT_INTERVAL must be positive, got %s", interval)
+		}
+		sink := os.Getenv("THRESHOLD_EXPORT_SINK")
+		thresholds, err = NewThresholdExporter(clusters[defaultCluster], sink, cpuPercent, memoryPercent, interval, cooldown, format.floatPrecision)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("INFO: Threshold export enabled for cluster %s: sink=%s, cpu=%g%%, memory=%g%%, interval=%s, cooldown=%s", defaultCluster, sink, cpuPercent, memoryPercent, interval, cooldown)
+	}
+
+	// Configure the Kubernetes API client, listing pods for scrape coverage. An explicit URL wins over
+	// a kubeconfig context, which wins over the in-cluster API server.
+	var kube *k8s.KubernetesClient
+	kubeURL := os.Getenv("KUBERNETES_API_URL")
+	kubeContext := os.Getenv("KUBE_CONTEXT")
+	if kubeURL == "" && kubeContext == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		kubeURL = "https://kubernetes.default.svc"
+	}
+	if kubeURL != "" {
+		kube, err = k8s.NewKubernetesClient(k8s.MetricsClientConfig{URL: kubeURL})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes API client: %w", err)
+		}
+	} else if kubeContext != "" {
+		kube, err = k8s.NewKubernetesClientFromKubeconfig(kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes API client: %w", err)
+		}
 	}
 
 	log.Printf("INFO: Metrics configuration loaded:")
-	log.Printf("  - Backend: %s", backend)
-	log.Printf("  - URL: %s", metricsURL)
+	for _, cluster := range clusterConfigs {
+		log.Printf("  - Cluster %s: backend=%s, url=%s, default=%v", cluster.name, cluster.config.Backend, cluster.config.URL, cluster.name == defaultCluster)
+	}
+	for name := range backends {
+		log.Printf("  - Selectable Backend: %s, url=%s", name, backendURL(name))
+	}
 	log.Printf("  - Timeout: %s", timeout)
 	log.Printf("  - Retry Attempts: %d", retryAttempts)
-	log.Printf("  - Features: Caching=%v, Historical=%v, Trend=%v", enableCaching, enableHistorical, enableTrend)
+	if namespaces != nil {
+		log.Printf("  - Namespace Allowlist: %s", namespaceAllowlist)
+	}
+	if tlsConfig != nil {
+		log.Printf("  - Backend TLS: CA File=%s, Client Cert File=%s, Insecure Skip Verify=%v", caFile, clientCertFile, insecureSkipVerify)
+	}
+	log.Printf("  - Max Inflight Queries: %d", maxInflightQueries)
+	log.Printf("  - Stale Threshold: %s", staleThreshold)
+	log.Printf("  - Readiness Cache TTL: %s", readinessCacheTTL)
+	log.Printf("  - Empty Result Cache TTL: %s, max=%s", emptyResultTTL, emptyResultMaxTTL)
+	if analysisCacheFresh > 0 {
+		log.Printf("  - Analysis Cache: fresh=%s, stale=%s", analysisCacheFresh, analysisCacheStale)
+	}
+	log.Printf("  - Cache Max Entries: %d", cacheMaxEntries)
+	log.Printf("  - Instant Lookback: %s", instantLookback)
+	if kubeURL != "" {
+		log.Printf("  - Kubernetes API: %s", kubeURL)
+	} else if kube != nil {
+		log.Printf("  - Kubernetes API: kubeconfig context %s", kubeContext)
+	}
+	log.Printf("  - Scrape Interval: %s", scrapeInterval)
+	log.Printf("  - Waste Thresholds: low=%g%%, high=%g%%", wasteLow, wasteHigh)
+	log.Printf("  - Trend Thresholds: cpu=%g%%, memory=%g%%", cpuTrendThreshold, memoryTrendThreshold)
+	log.Printf("  - Efficiency Basis: %s", efficiencyBasis)
+	log.Printf("  - Memory Unit Base: %s", format.memoryUnitBase)
+	log.Printf("  - Float Precision: %d", format.floatPrecision)
+	log.Printf("  - Memory Pressure Threshold: %g%%", format.memoryPressureThreshold)
+	log.Printf("  - Owner Label: %s", ownerLabel)
+	log.Printf("  - Duplicate Series Aggregation: %s", duplicateAggregation)
+	log.Printf("  - Max Samples Per Series: %d", maxSamples)
+	log.Printf("  - Align Range Queries: %v", alignRangeQueries)
+	log.Printf("  - Min Trend Samples: %d", minTrendSamples)
+	log.Printf("  - Efficiency Histogram Buckets: %v", histogramBuckets)
+	log.Printf("  - Outlier Std Devs: %g", outlierStdDevs)
+	log.Printf("  - Max Response Bytes: %d", maxResponseBytes)
+	log.Printf("  - Max Analysis Range: %s", maxAnalysisRange)
+	log.Printf("  - Recommendation Headroom: cpu=%g%%, memory=%g%%", cpuHeadroom, memoryHeadroom)
+	log.Printf("  - Analysis Jobs: ttl=%s, max running=%d", analysisJobTTL, maxAnalysisJobs)
+	for name, metric := range queries.Metrics {
+		log.Printf("  - Query Override: %s=%s", name, metric)
+	}
+	if queries.ExtraFilters != "" {
+		log.Printf("  - Query Extra Filters: %s", queries.ExtraFilters)
+	}
+	log.Printf("  - Container Filter: %s", queries.ContainerFilter)
+	log.Printf("  - CPU Usage Unit: %s", queries.CPUUnit)
+	log.Printf("  - Features: Caching=%v, Historical=%v, Headroom=%v, Trend=%v, ContainerStatus=%v, RestartTrend=%v, VMExport=%v, RawQuery=%v, DebugEndpoints=%v", enableCaching, enableHistorical, headroom != nil, enableTrend, enableContainerStatus, enableRestartTrend, vmUseExport, enableRawQuery, enableDebug)
 
 	return &Handler{
-		metricsClient: metricsClient,
+		clusters:         clusters,
+		clusterNames:     clusterNames,
+		defaultCluster:   defaultCluster,
+		backends:         backends,
+		staleThreshold:   staleThreshold,
+		enableRawQuery:   enableRawQuery,
+		enableDebug:      enableDebug,
+		histogramBuckets: histogramBuckets,
+		outlierStdDevs:   outlierStdDevs,
+		maxResponseBytes: maxResponseBytes,
+		maxAnalysisRange: maxAnalysisRange,
+		headroomDefaults: recommendationHeadroom{cpu: cpuHeadroom, memory: memoryHeadroom},
+		format:           format,
+		ownerLabel:       ownerLabel,
+		namespaces:       namespaces,
+		auth:             auth,
+		alerter:          alerter,
+		thresholds:       thresholds,
+		baselines:        newAnalysisBaselines(cacheMaxEntries),
+		jobs:             newAnalysisJobs(analysisJobTTL, maxAnalysisJobs),
+		cache:            cache,
+		emptyResults:     newEmptyResultCache(emptyResultTTL, emptyResultMaxTTL, cacheMaxEntries),
+		analysisCache:    newAnalysisCache(analysisCacheFresh, analysisCacheStale, cacheMaxEntries),
+		headroom:         headroom,
+		kube:             kube,
+		readiness:        backendProbe{ttl: readinessCacheTTL},
 	}, nil
 }
 
 // GetNamespaces returns a list of all namespaces from metrics backend
 func (h *Handler) GetNamespaces(w http.ResponseWriter, r *http.Request) {
-	if h.metricsClient == nil {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
 		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
 		return
 	}
@@ -83,9 +519,9 @@ func (h *Handler) GetNamespaces(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	namespaces, err := h.metricsClient.GetNamespaces(ctx)
+	namespaces, err := metricsClient.GetNamespaces(ctx)
 	if err != nil {
-		log.Printf("Error getting namespaces from %s: %v", h.metricsClient.GetClientType(), err)
+		log.Printf("Error getting namespaces from %s: %v", metricsClient.GetClientType(), err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -95,19 +531,20 @@ func (h *Handler) GetNamespaces(w http.ResponseWriter, r *http.Request) {
 	
 	// Create response
 	response := models.NamespaceList{
-		Namespaces: namespaces,
+		Namespaces: append([]string{}, namespaces...),
 	}
 
 	// Write response
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	writeJSONLimited(w, response, h.maxResponseBytes)
 }
 
 // GetPodMetrics returns current metrics for all pods from metrics backend
 func (h *Handler) GetPodMetrics(w http.ResponseWriter, r *http.Request) {
-	if h.metricsClient == nil {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
 		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
 		return
 	}
@@ -116,40 +553,231 @@ func (h *Handler) GetPodMetrics(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Get namespace from query parameter
-	namespace := r.URL.Query().Get("namespace")
+	namespace := namespaceParam(r)
+
+	// Label selector pushed down into the usage queries
+	selector := r.URL.Query().Get("selector")
+	if selector != "" && !validSelector(selector) {
+		http.Error(w, "invalid selector parameter: expected comma-separated label matchers like app=\"nginx\"", http.StatusBadRequest)
+		return
+	}
 
-	metricsData, err := h.metricsClient.GetCurrentPodMetrics(ctx, namespace)
+	// Only return pods scheduled on this node
+	node := r.URL.Query().Get("node")
+	if node != "" && !validLabelValue(node) {
+		http.Error(w, "invalid node parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Return one row per pod instead of per container when disabled
+	perContainer := true
+	if value := r.URL.Query().Get("perContainer"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid perContainer parameter: %s", value), http.StatusBadRequest)
+			return
+		}
+		perContainer = parsed
+	}
+
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy != "" && groupBy != "workload" {
+		http.Error(w, "invalid groupBy parameter: only \"workload\" is supported", http.StatusBadRequest)
+		return
+	}
+
+	// Evaluate at a past instant when requested
+	at, err := parseEvalTime(r.URL.Query().Get("at"), time.Now())
 	if err != nil {
-		log.Printf("Error getting pod metrics from %s: %v", h.metricsClient.GetClientType(), err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Collect backend warnings so consumers know when data may be incomplete
+	ctx, warnings := k8s.WithQueryWarnings(ctx)
+
+	// Filter by node in the backend queries when the backend supports it
+	nodeGetter, _ := unscoped(metricsClient).(k8s.NodePodMetricsGetter)
+	nodeQuery := node != "" && nodeGetter != nil
+
+	// Serve live, unfiltered requests from the cached snapshot when available
+	var metricsData []k8s.PodMetric
+	var snapshot metricsSnapshot
+	var cached bool
+	if selector == "" && r.URL.Query().Get("at") == "" && !nodeQuery {
+		snapshot, cached = h.cachedPodMetrics(r, metricsClient, namespace, at)
+	}
+	if nodeQuery {
+		metricsData, err = nodeGetter.GetNodePodMetrics(ctx, namespace, node, selector, at)
+		if err != nil {
+			log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metricsData = scopePodMetrics(metricsClient, metricsData)
+	} else if cached {
+		metricsData = snapshot.metrics
+		warnings.Add(snapshot.warnings...)
+	} else {
+		result, err := h.currentPodMetrics(ctx, metricsClient, h.sourceKey(r), namespace, selector, r.URL.Query().Get("at"), at)
+		if err != nil {
+			log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metricsData = result.metrics
+		warnings.Add(result.warnings...)
+	}
+
+	// Otherwise filter by node through the kube-state-metrics pod to node mapping
+	if node != "" && !nodeQuery {
+		podNodes, err := metricsClient.GetPodNodes(ctx, namespace, at)
+		if err != nil {
+			log.Printf("Error getting pod nodes from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metricsData = filterByNode(metricsData, podNodes, node)
+	}
+
+	// Headroom is only meaningful against live limits
+	var p95 map[string]p95Usage
+	if r.URL.Query().Get("at") == "" {
+		p95 = h.headroomFor(r, at)
+	}
+
+	// Merge the containers of each pod, headroom is per container so it is left unset
+	if !perContainer {
+		metricsData = mergePodContainers(metricsData)
+	}
+
 	// Convert metrics to models format
-	var pods []models.PodMetrics
+	pods := []models.PodMetrics{}
+	var newestSample time.Time
 	for _, metric := range metricsData {
-		podMetric := convertMetricsToModelMetric(metric)
+		podMetric := h.format.convertMetricsToModelMetric(metric)
+		h.format.addHeadroom(&podMetric, metric, p95)
+		podMetric.Stale = !metric.SampleTime.IsZero() && at.Sub(metric.SampleTime) > h.staleThreshold
 		pods = append(pods, podMetric)
+		if metric.SampleTime.After(newestSample) {
+			newestSample = metric.SampleTime
+		}
 	}
 
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
 
+	// Flag the response as stale when the newest sample is older than expected
+	var dataTimestamp *time.Time
+	var stale bool
+	if !newestSample.IsZero() {
+		dataTimestamp = &newestSample
+		stale = at.Sub(newestSample) > h.staleThreshold
+	}
+
+	// Aggregate replicas per workload when requested
+	if groupBy == "workload" {
+		response := models.WorkloadMetricsList{
+			Workloads:     h.format.groupByWorkload(metricsData),
+			DataTimestamp: dataTimestamp,
+			Stale:         stale,
+			Warnings:      warnings.Warnings(),
+		}
+		writeJSONLimited(w, response, h.maxResponseBytes)
+		return
+	}
+
 	// Create response
 	response := models.PodMetricsList{
-		Pods: pods,
+		Pods:          pods,
+		DataTimestamp: dataTimestamp,
+		Stale:         stale,
+		Warnings:      warnings.Warnings(),
 	}
 
 	// Write response
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// GetPodDetail returns current metrics and status for a single pod
+func (h *Handler) GetPodDetail(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	// Get path parameters
+	namespace := r.PathValue("namespace")
+	podName := r.PathValue("pod")
+	if !validLabelValue(namespace) || !validLabelValue(podName) {
+		http.Error(w, "invalid namespace or pod name", http.StatusBadRequest)
+		return
+	}
+
+	metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, namespace, fmt.Sprintf(`pod="%s"`, podName), time.Now())
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status, err := metricsClient.GetPodStatus(ctx, namespace, podName)
+	if err != nil {
+		log.Printf("Error getting pod status from %s: %v", metricsClient.GetClientType(), err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	if len(metricsData) == 0 && status.Phase == "" {
+		http.Error(w, "Pod not found", http.StatusNotFound)
+		return
+	}
+
+	// Combine metrics and status per container
+	containers := make([]models.ContainerDetail, 0, len(metricsData))
+	for _, metric := range metricsData {
+		containers = append(containers, models.ContainerDetail{
+			PodMetrics:            h.format.convertMetricsToModelMetric(metric),
+			LastTerminationReason: status.LastTerminationReasons[metric.ContainerName],
+		})
+	}
+
+	// Create response
+	response := models.PodDetail{
+		Name:       podName,
+		Namespace:  namespace,
+		Phase:      status.Phase,
+		Containers: containers,
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// Helper function to check a Kubernetes object name is safe to use as a label value in queries
+func validLabelValue(value string) bool {
+	return value != "" && len(value) <= 253 && labelValuePattern.MatchString(value)
 }
 
+var labelValuePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
 // GetHistoricalAnalysis returns 7-day historical analysis for pods
 func (h *Handler) GetHistoricalAnalysis(w http.ResponseWriter, r *http.Request) {
-	if h.metricsClient == nil {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
 		http.Error(w, "Historical analysis not available - metrics client not initialized", http.StatusServiceUnavailable)
 		return
 	}
@@ -157,94 +785,197 @@ func (h *Handler) GetHistoricalAnalysis(w http.ResponseWriter, r *http.Request)
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	// Get namespace from query parameter
-	namespace := r.URL.Query().Get("namespace")
-	if namespace == "" {
-		namespace = ".*" // All namespaces
+	// Get namespace from query parameter, empty for all namespaces
+	namespace := namespaceParam(r)
+
+	// Optionally downsample the returned series
+	maxPoints, err := parseMaxPoints(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	historicalData, err := h.metricsClient.GetHistoricalMetrics(ctx, namespace)
+	// Analyze the requested window, 7 days by default, optionally also covering pods that completed
+	// during the window, e.g. finished Jobs
+	window, err := parseAnalysisRange(r, h.maxAnalysisRange)
 	if err != nil {
-		log.Printf("Error getting historical metrics from %s: %v", h.metricsClient.GetClientType(), err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	opts := k8s.HistoricalOptions{Window: window, IncludeCompleted: r.URL.Query().Get("includeCompleted") == "true"}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
+	// Optionally return only the containers needing action
+	filter, err := parseAnalysisFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Convert k8s types to models types
-	var modelMetrics []models.HistoricalMetrics
-	for _, hm := range historicalData {
-		modelMetrics = append(modelMetrics, models.HistoricalMetrics{
-			PodName:       hm.PodName,
-			Namespace:     hm.Namespace,
-			ContainerName: hm.ContainerName,
-			CPU: models.HistoricalResourceData{
-				Usage:    convertDataPoints(hm.CPU.Usage),
-				Requests: convertDataPoints(hm.CPU.Requests),
-				Limits:   convertDataPoints(hm.CPU.Limits),
-				Average:  hm.CPU.Average,
-				Peak:     hm.CPU.Peak,
-				Minimum:  hm.CPU.Minimum,
-				P95:      hm.CPU.P95,
-				P99:      hm.CPU.P99,
-				Trend:    hm.CPU.Trend,
-			},
-			Memory: models.HistoricalResourceData{
-				Usage:    convertDataPoints(hm.Memory.Usage),
-				Requests: convertDataPoints(hm.Memory.Requests),
-				Limits:   convertDataPoints(hm.Memory.Limits),
-				Average:  hm.Memory.Average,
-				Peak:     hm.Memory.Peak,
-				Minimum:  hm.Memory.Minimum,
-				P95:      hm.Memory.P95,
-				P99:      hm.Memory.P99,
-				Trend:    hm.Memory.Trend,
-			},
-			Analysis: models.UsageAnalysis{
-				CPUEfficiency:    hm.Analysis.CPUEfficiency,
-				MemoryEfficiency: hm.Analysis.MemoryEfficiency,
-				ResourceWaste: models.ResourceWasteAnalysis{
-					CPUOverProvisioned:     hm.Analysis.ResourceWaste.CPUOverProvisioned,
-					MemoryOverProvisioned:  hm.Analysis.ResourceWaste.MemoryOverProvisioned,
-					CPUUnderProvisioned:    hm.Analysis.ResourceWaste.CPUUnderProvisioned,
-					MemoryUnderProvisioned: hm.Analysis.ResourceWaste.MemoryUnderProvisioned,
-					CPUWastePercentage:     hm.Analysis.ResourceWaste.CPUWastePercentage,
-					MemoryWastePercentage:  hm.Analysis.ResourceWaste.MemoryWastePercentage,
-				},
-				Recommendations: hm.Analysis.Recommendations,
-				Patterns: models.UsagePatterns{
-					PeakHours:       hm.Analysis.Patterns.PeakHours,
-					LowUsageHours:   hm.Analysis.Patterns.LowUsageHours,
-					DailyVariation:  hm.Analysis.Patterns.DailyVariation,
-					WeeklyVariation: hm.Analysis.Patterns.WeeklyVariation,
-				},
-			},
+	// Optionally combine the replicas of each workload, re-analyzed with the backend's thresholds
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy != "" && groupBy != "workload" {
+		http.Error(w, "invalid groupBy parameter: only \"workload\" is supported", http.StatusBadRequest)
+		return
+	}
+	analyzer, ok := unscoped(metricsClient).(k8s.HistoricalAnalyzer)
+	if groupBy == "workload" && !ok {
+		http.Error(w, fmt.Sprintf("groupBy=workload is not supported by the %s backend", metricsClient.GetClientType()), http.StatusBadRequest)
+		return
+	}
+
+	format, ok := negotiateFormat(w, r, formatJSON, formatNDJSON)
+	if !ok {
+		return
+	}
+
+	// Stream one JSON object per line when NDJSON output is requested
+	if format == formatNDJSON {
+		if groupBy == "workload" {
+			http.Error(w, "groupBy=workload is not supported with NDJSON output", http.StatusBadRequest)
+			return
+		}
+		h.streamHistoricalAnalysis(ctx, w, metricsClient, namespace, opts, maxPoints, filter)
+		return
+	}
+
+	// Collect backend warnings so consumers know when data may be incomplete
+	ctx, warnings := k8s.WithQueryWarnings(ctx)
+
+	// Collect per-phase query timings when debugging
+	var timings *k8s.QueryTimings
+	debug := r.URL.Query().Get("debug") == "true"
+	if debug {
+		ctx, timings = k8s.WithQueryTimings(ctx)
+	}
+
+	// Serve from the analysis cache when enabled, unless timings of the queries are requested
+	var historicalData []k8s.HistoricalMetrics
+	if h.analysisCache != nil && !debug {
+		key := strings.Join([]string{h.sourceKey(r), namespace, r.URL.Query().Get("includeCompleted"), window.String()}, "\x00")
+		result, state, err := h.analysisCache.get(ctx, key, func(ctx context.Context) (analysisResult, error) {
+			ctx, warnings := k8s.WithQueryWarnings(ctx)
+			metrics, err := metricsClient.GetHistoricalMetrics(ctx, namespace, opts)
+			return analysisResult{metrics: metrics, warnings: warnings.Warnings()}, err
 		})
+		if err != nil {
+			log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		historicalData = result.metrics
+		warnings.Add(result.warnings...)
+		w.Header().Set("X-Analysis-Cache", state)
+	} else {
+		var err error
+		historicalData, err = metricsClient.GetHistoricalMetrics(ctx, namespace, opts)
+		if err != nil {
+			log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
 	// Create response
-	response := models.HistoricalAnalysisList{
-		HistoricalMetrics: modelMetrics,
-		GeneratedAt:      time.Now(),
-		TimeRange: models.TimeRange{
-			Start: time.Now().Add(-7 * 24 * time.Hour),
-			End:   time.Now(),
-		},
-		Summary: generateAnalysisSummary(modelMetrics),
+	summaryStart := time.Now()
+	var workloadPods map[string][]string
+	if groupBy == "workload" {
+		historicalData, workloadPods = aggregateWorkloads(historicalData, podOwners(ctx, metricsClient, namespace, window), analyzer)
+	}
+	response := h.buildHistoricalAnalysis(historicalData, maxPoints, window, warnings.Warnings())
+	for i, metrics := range response.HistoricalMetrics {
+		response.HistoricalMetrics[i].Pods = workloadPods[workloadKey(metrics.Namespace, metrics.PodName, metrics.ContainerName)]
+	}
+
+	// Keep this run as the baseline for /api/pods/analysis/diff, which analyzes the default window per container
+	if window == k8s.DefaultAnalysisWindow && groupBy == "" {
+		h.baselines.swap(baselineKey(h.sourceKey(r), namespace), response)
+	}
+
+	// Filter after the summary and baseline so both reflect every container
+	if filter.active() {
+		filtered := []models.HistoricalMetrics{}
+		for _, metrics := range response.HistoricalMetrics {
+			if filter.matches(metrics) {
+				filtered = append(filtered, metrics)
+			}
+		}
+		response.HistoricalMetrics = filtered
+		response.ReturnedCount = len(filtered)
+	}
+
+	if debug {
+		timings.Record("summary", time.Since(summaryStart))
+		response.Timings = convertQueryTimings(timings)
 	}
 
 	// Write response
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// streamHistoricalAnalysis writes each container's historical analysis as a JSON line as soon as it is computed
+func (h *Handler) streamHistoricalAnalysis(ctx context.Context, w http.ResponseWriter, metricsClient k8s.MetricsClient, namespace string, opts k8s.HistoricalOptions, maxPoints int, filter analysisFilter) {
+	// Streaming is bounded by the request context rather than the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to clear write deadline for streaming: %v", err)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	body := &limitedWriter{w: w, limit: h.maxResponseBytes}
+	encoder := json.NewEncoder(body)
+	written := false
+
+	err := metricsClient.StreamHistoricalMetrics(ctx, namespace, opts, func(hm k8s.HistoricalMetrics) error {
+		metrics := h.format.convertHistoricalMetrics(hm)
+		if !filter.matches(metrics) {
+			return nil
+		}
+		if !written {
+			w.Header().Set("Content-Type", formatMediaType(formatNDJSON))
+			written = true
+		}
+		downsampleHistoricalMetrics(&metrics, maxPoints)
+		if err := encoder.Encode(metrics); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if errors.Is(err, errResponseTooLarge) {
+		log.Printf("WARN: Stopped streaming historical metrics at MAX_RESPONSE_BYTES (%d)", h.maxResponseBytes)
+		if body.written == 0 {
+			http.Error(w, responseTooLargeMessage(h.maxResponseBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		// Once lines have been written the status code can no longer be changed, so end with an error line
+		json.NewEncoder(w).Encode(map[string]string{"error": responseTooLargeMessage(h.maxResponseBytes)})
+		return
+	}
+	if err != nil {
+		log.Printf("Error streaming historical metrics from %s: %v", metricsClient.GetClientType(), err)
+		// Once lines have been written the status code can no longer be changed
+		if !written {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
+
+	if !written {
+		w.Header().Set("Content-Type", formatMediaType(formatNDJSON))
+	}
 }
 
 // GetPodTrends returns trend analysis for a specific pod
 func (h *Handler) GetPodTrends(w http.ResponseWriter, r *http.Request) {
-	if h.metricsClient == nil {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
 		http.Error(w, "Trend analysis not available - metrics client not initialized", http.StatusServiceUnavailable)
 		return
 	}
@@ -253,27 +984,32 @@ func (h *Handler) GetPodTrends(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Get parameters
-	namespace := r.URL.Query().Get("namespace")
+	namespace := namespaceParam(r)
 	podName := r.URL.Query().Get("pod")
-	days := r.URL.Query().Get("days")
-	
+
 	if namespace == "" || podName == "" {
 		http.Error(w, "namespace and pod parameters are required", http.StatusBadRequest)
 		return
 	}
 
-	// Default to 7 days if not specified
-	daysInt := 7
-	if days != "" {
-		if d, err := time.ParseDuration(days + "d"); err == nil {
-			daysInt = int(d.Hours() / 24)
-		}
+	// Optionally downsample the returned series
+	maxPoints, err := parseMaxPoints(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Analyze the requested window, 7 days by default
+	window, err := parseAnalysisRange(r, h.maxAnalysisRange)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// Get historical data for the specific pod
-	historicalData, err := h.metricsClient.GetHistoricalMetrics(ctx, namespace)
+	historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, k8s.HistoricalOptions{Window: window})
 	if err != nil {
-		log.Printf("Error getting pod trends from %s: %v", h.metricsClient.GetClientType(), err)
+		log.Printf("Error getting pod trends from %s: %v", metricsClient.GetClientType(), err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -282,65 +1018,37 @@ func (h *Handler) GetPodTrends(w http.ResponseWriter, r *http.Request) {
 	var podTrends []models.HistoricalMetrics
 	for _, hm := range historicalData {
 		if hm.PodName == podName && hm.Namespace == namespace {
-			// Convert to models type
-			modelMetric := models.HistoricalMetrics{
-				PodName:       hm.PodName,
-				Namespace:     hm.Namespace,
-				ContainerName: hm.ContainerName,
-				CPU: models.HistoricalResourceData{
-					Usage:    convertDataPoints(hm.CPU.Usage),
-					Requests: convertDataPoints(hm.CPU.Requests),
-					Limits:   convertDataPoints(hm.CPU.Limits),
-					Average:  hm.CPU.Average,
-					Peak:     hm.CPU.Peak,
-					Minimum:  hm.CPU.Minimum,
-					P95:      hm.CPU.P95,
-					P99:      hm.CPU.P99,
-					Trend:    hm.CPU.Trend,
-				},
-				Memory: models.HistoricalResourceData{
-					Usage:    convertDataPoints(hm.Memory.Usage),
-					Requests: convertDataPoints(hm.Memory.Requests),
-					Limits:   convertDataPoints(hm.Memory.Limits),
-					Average:  hm.Memory.Average,
-					Peak:     hm.Memory.Peak,
-					Minimum:  hm.Memory.Minimum,
-					P95:      hm.Memory.P95,
-					P99:      hm.Memory.P99,
-					Trend:    hm.Memory.Trend,
-				},
-				Analysis: models.UsageAnalysis{
-					CPUEfficiency:    hm.Analysis.CPUEfficiency,
-					MemoryEfficiency: hm.Analysis.MemoryEfficiency,
-					ResourceWaste: models.ResourceWasteAnalysis{
-						CPUOverProvisioned:     hm.Analysis.ResourceWaste.CPUOverProvisioned,
-						MemoryOverProvisioned:  hm.Analysis.ResourceWaste.MemoryOverProvisioned,
-						CPUUnderProvisioned:    hm.Analysis.ResourceWaste.CPUUnderProvisioned,
-						MemoryUnderProvisioned: hm.Analysis.ResourceWaste.MemoryUnderProvisioned,
-						CPUWastePercentage:     hm.Analysis.ResourceWaste.CPUWastePercentage,
-						MemoryWastePercentage:  hm.Analysis.ResourceWaste.MemoryWastePercentage,
-					},
-					Recommendations: hm.Analysis.Recommendations,
-					Patterns: models.UsagePatterns{
-						PeakHours:       hm.Analysis.Patterns.PeakHours,
-						LowUsageHours:   hm.Analysis.Patterns.LowUsageHours,
-						DailyVariation:  hm.Analysis.Patterns.DailyVariation,
-						WeeklyVariation: hm.Analysis.Patterns.WeeklyVariation,
-					},
-				},
-			}
-			podTrends = append(podTrends, modelMetric)
+			podTrends = append(podTrends, h.format.convertHistoricalMetrics(hm))
 		}
 	}
 
+	// Tell a pod that doesn't exist apart from one without enough data
+	var note string
 	if len(podTrends) == 0 {
-		http.Error(w, "No trend data found for the specified pod", http.StatusNotFound)
-		return
+		activePods, err := metricsClient.GetActivePods(ctx, namespace, window)
+		if err != nil {
+			log.Printf("Error getting active pods from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !containsPod(activePods, namespace, podName) {
+			http.Error(w, "No trend data found for the specified pod", http.StatusNotFound)
+			return
+		}
+		podTrends = []models.HistoricalMetrics{}
+	}
+	if !hasUsageData(podTrends) {
+		note = insufficientTrendDataNote
 	}
 
 	// Generate summary
 	summary := generatePodTrendSummary(podTrends)
 
+	// Downsample after the summary so it is based on full-resolution data
+	for i := range podTrends {
+		downsampleHistoricalMetrics(&podTrends[i], maxPoints)
+	}
+
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
 
@@ -349,28 +1057,50 @@ func (h *Handler) GetPodTrends(w http.ResponseWriter, r *http.Request) {
 		PodName:      podName,
 		Namespace:    namespace,
 		Containers:   podTrends,
-		DaysAnalyzed: daysInt,
+		DaysAnalyzed: daysAnalyzed(window),
+		Range:        window.String(),
 		GeneratedAt:  time.Now(),
 		Summary:      summary,
+		Note:         note,
 	}
 
 	// Write response
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// insufficientTrendDataNote explains an empty trend analysis of a pod that exists
+const insufficientTrendDataNote = "The pod is active but none of its containers has usage datapoints in the analyzed window, e.g. because it just started or its metrics are not scraped"
+
+// Helper function to check whether a pod is among the listed pods
+func containsPod(pods []k8s.PodInfo, namespace, name string) bool {
+	for _, pod := range pods {
+		if pod.Namespace == namespace && pod.Name == name {
+			return true
+		}
 	}
+	return false
+}
+
+// Helper function to check whether any container has CPU or memory usage datapoints
+func hasUsageData(containers []models.HistoricalMetrics) bool {
+	for _, container := range containers {
+		if len(container.CPU.Usage) > 0 || len(container.Memory.Usage) > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // Health returns a simple health check response
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 	
 	metricsStatus := "unavailable"
 	var clientType string
-	if h.metricsClient != nil {
+	metricsClient := h.clusters[h.defaultCluster]
+	if metricsClient != nil {
 		metricsStatus = "available"
-		clientType = h.metricsClient.GetClientType()
+		clientType = metricsClient.GetClientType()
 	}
 	
 	response := map[string]interface{}{
@@ -378,158 +1108,490 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 		"timestamp":        time.Now().Format(time.RFC3339),
 		"metricsClient":    metricsStatus,
 		"metricsBackend":   clientType,
+		"clusters":         h.clusterNames,
 		"features": map[string]bool{
 			"realTimeMetrics":    true,
-			"historicalAnalysis": h.metricsClient != nil,
-			"trendAnalysis":      h.metricsClient != nil,
+			"historicalAnalysis": metricsClient != nil,
+			"trendAnalysis":      metricsClient != nil,
 		},
 	}
+	if metricsClient != nil {
+		response["backendProbe"] = h.probe.check(r.Context(), metricsClient)
+	}
 	
-	json.NewEncoder(w).Encode(response)
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// Helper function to convert k8s HistoricalMetrics to models HistoricalMetrics
+
+func (f valueFormat) convertHistoricalMetrics(hm k8s.HistoricalMetrics) models.HistoricalMetrics {
+	return models.HistoricalMetrics{
+		PodName:       hm.PodName,
+		Namespace:     hm.Namespace,
+		ContainerName: hm.ContainerName,
+		CPU: models.HistoricalResourceData{
+			Usage:            convertDataPoints(hm.CPU.Usage),
+			Requests:         convertDataPoints(hm.CPU.Requests),
+			Limits:           convertDataPoints(hm.CPU.Limits),
+			Average:          finiteValue(hm.CPU.Average),
+			Peak:             finiteValue(hm.CPU.Peak),
+			Minimum:          finiteValue(hm.CPU.Minimum),
+			P95:              finiteValue(hm.CPU.P95),
+			P99:              finiteValue(hm.CPU.P99),
+			Trend:            hm.CPU.Trend,
+			DataCompleteness: f.roundValue(hm.CPU.DataCompleteness),
+		},
+		Memory: models.HistoricalResourceData{
+			Usage:            convertDataPoints(hm.Memory.Usage),
+			Requests:         convertDataPoints(hm.Memory.Requests),
+			Limits:           convertDataPoints(hm.Memory.Limits),
+			Average:          finiteValue(hm.Memory.Average),
+			Peak:             finiteValue(hm.Memory.Peak),
+			Minimum:          finiteValue(hm.Memory.Minimum),
+			P95:              finiteValue(hm.Memory.P95),
+			P99:              finiteValue(hm.Memory.P99),
+			Trend:            hm.Memory.Trend,
+			DataCompleteness: f.roundValue(hm.Memory.DataCompleteness),
+		},
+		Analysis: models.UsageAnalysis{
+			CPUEfficiency:    f.roundValue(hm.Analysis.CPUEfficiency),
+			MemoryEfficiency: f.roundValue(hm.Analysis.MemoryEfficiency),
+			ResourceWaste: models.ResourceWasteAnalysis{
+				CPUOverProvisioned:     hm.Analysis.ResourceWaste.CPUOverProvisioned,
+				MemoryOverProvisioned:  hm.Analysis.ResourceWaste.MemoryOverProvisioned,
+				CPUUnderProvisioned:    hm.Analysis.ResourceWaste.CPUUnderProvisioned,
+				MemoryUnderProvisioned: hm.Analysis.ResourceWaste.MemoryUnderProvisioned,
+				CPUWastePercentage:     f.roundValue(hm.Analysis.ResourceWaste.CPUWastePercentage),
+				MemoryWastePercentage:  f.roundValue(hm.Analysis.ResourceWaste.MemoryWastePercentage),
+			},
+			Recommendations: append([]string{}, hm.Analysis.Recommendations...),
+			Patterns: models.UsagePatterns{
+				PeakHours:       append([]int{}, hm.Analysis.Patterns.PeakHours...),
+				LowUsageHours:   append([]int{}, hm.Analysis.Patterns.LowUsageHours...),
+				DailyVariation:  f.roundValue(hm.Analysis.Patterns.DailyVariation),
+				WeeklyVariation: f.roundValue(hm.Analysis.Patterns.WeeklyVariation),
+			},
+		},
+		RestartCount:        hm.RestartCount,
+		RestartTrend:        hm.RestartTrend,
+		OOMKilled:           hm.OOMKilled,
+		Step:                hm.Step.String(),
+		StepCoarsened:       hm.StepCoarsened,
+		Completed:           hm.Completed,
+		CreatedAt:           optionalTime(hm.StartTime),
+		InsufficientHistory: hm.InsufficientHistory,
+		MemoryPressure:      f.memoryPressure(hm.Memory.P95, averageLimit(hm.Memory.Limits)),
+	}
+}
+
+// Helper function to average limit datapoints, skipping the points when no limit was set
+func averageLimit(points []k8s.DataPoint) float64 {
+	var sum float64
+	var count int
+	for _, point := range points {
+		if point.Value > 0 {
+			sum += point.Value
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// Helper function to convert k8s query timings to models phase timings
+func convertQueryTimings(timings *k8s.QueryTimings) map[string]models.PhaseTiming {
+	result := make(map[string]models.PhaseTiming)
+	for phase, timing := range timings.Phases() {
+		result[phase] = models.PhaseTiming{
+			TotalMs: float64(timing.Total) / float64(time.Millisecond),
+			Count:   timing.Count,
+		}
+	}
+	return result
+}
+
+// buildHistoricalAnalysis converts the containers analyzed over window into the analysis response with its summary,
+// downsampling each series to maxPoints afterwards so the summary is based on full-resolution data
+func (h *Handler) buildHistoricalAnalysis(historicalData []k8s.HistoricalMetrics, maxPoints int, window time.Duration, warnings []string) models.HistoricalAnalysisList {
+	// Convert k8s types to models types
+	modelMetrics := []models.HistoricalMetrics{}
+	for _, hm := range historicalData {
+		modelMetrics = append(modelMetrics, h.format.convertHistoricalMetrics(hm))
+	}
+
+	h.format.annotateNamespaceOutliers(modelMetrics, h.outlierStdDevs)
+	summary := h.format.generateAnalysisSummary(modelMetrics, h.histogramBuckets)
+
+	for i := range modelMetrics {
+		downsampleHistoricalMetrics(&modelMetrics[i], maxPoints)
+	}
+
+	now := time.Now()
+	return models.HistoricalAnalysisList{
+		HistoricalMetrics: modelMetrics,
+		GeneratedAt:       now,
+		TimeRange: models.TimeRange{
+			Start: now.Add(-window),
+			End:   now,
+		},
+		Summary:       summary,
+		Warnings:      warnings,
+		TotalCount:    len(modelMetrics),
+		ReturnedCount: len(modelMetrics),
+	}
+}
+
+// Helper function to parse the maxPoints parameter, 0 when downsampling is not requested
+func parseMaxPoints(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("maxPoints")
+	if value == "" {
+		return 0, nil
+	}
+	maxPoints, err := strconv.Atoi(value)
+	if err != nil || maxPoints < 1 {
+		return 0, fmt.Errorf("invalid maxPoints parameter: %s", value)
+	}
+	return maxPoints, nil
+}
+
+// defaultMaxAnalysisRange is the longest analysis window accepted by default
+const defaultMaxAnalysisRange = 30 * 24 * time.Hour
+
+// Helper function to parse the analysis window from the range parameter, a duration such as 24h or
+// 90m, or otherwise the days parameter, a whole number of days. The window is at most maxRange and
+// defaults to k8s.DefaultAnalysisWindow.
+func parseAnalysisRange(r *http.Request, maxRange time.Duration) (time.Duration, error) {
+	window := k8s.DefaultAnalysisWindow
+	if value := r.URL.Query().Get("range"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return 0, fmt.Errorf("invalid range parameter: %s (expected a duration such as 24h or 90m)", value)
+		}
+		window = parsed
+	} else if value := r.URL.Query().Get("days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return 0, fmt.Errorf("invalid days parameter: %s (expected a positive number of days)", value)
+		}
+		window = time.Duration(days) * 24 * time.Hour
+	}
+	if window > maxRange {
+		return 0, fmt.Errorf("analysis window %s exceeds the maximum of %s", window, maxRange)
+	}
+	return window, nil
+}
+
+// daysAnalyzed returns the days covered by window, rounded up so that sub-day windows report 1
+func daysAnalyzed(window time.Duration) int {
+	const day = 24 * time.Hour
+	return int((window + day - 1) / day)
+}
+
+// analysisFilter selects the historical analysis entries returned to the client
+type analysisFilter struct {
+	problematic bool    // only=problematic: flagged over- or under-provisioned
+	minWaste    float64 // minWaste=N: CPU or memory waste of at least N percent, 0 when not requested
+}
+
+// Helper function to parse the only and minWaste parameters
+func parseAnalysisFilter(r *http.Request) (analysisFilter, error) {
+	var filter analysisFilter
+	switch only := r.URL.Query().Get("only"); only {
+	case "":
+	case "problematic":
+		filter.problematic = true
+	default:
+		return filter, fmt.Errorf("invalid only parameter: %s (expected problematic)", only)
+	}
+	if value := r.URL.Query().Get("minWaste"); value != "" {
+		minWaste, err := strconv.ParseFloat(value, 64)
+		if err != nil || minWaste < 0 || math.IsNaN(minWaste) {
+			return filter, fmt.Errorf("invalid minWaste parameter: %s", value)
+		}
+		filter.minWaste = minWaste
+	}
+	return filter, nil
+}
+
+// active reports whether any filter was requested
+func (f analysisFilter) active() bool {
+	return f.problematic || f.minWaste > 0
+}
+
+// matches reports whether an entry passes the filter. With both filters, entries matching either are kept.
+func (f analysisFilter) matches(hm models.HistoricalMetrics) bool {
+	if !f.active() {
+		return true
+	}
+	waste := hm.Analysis.ResourceWaste
+	if f.problematic && (waste.CPUOverProvisioned || waste.MemoryOverProvisioned || waste.CPUUnderProvisioned || waste.MemoryUnderProvisioned) {
+		return true
+	}
+	return f.minWaste > 0 && (waste.CPUWastePercentage >= f.minWaste || waste.MemoryWastePercentage >= f.minWaste)
+}
+
+// downsampleHistoricalMetrics reduces the usage, request and limit series to at most maxPoints
+// each. Statistics are left as computed on the full-resolution data.
+func downsampleHistoricalMetrics(hm *models.HistoricalMetrics, maxPoints int) {
+	if maxPoints <= 0 {
+		return
+	}
+	for _, data := range []*models.HistoricalResourceData{&hm.CPU, &hm.Memory} {
+		data.Usage = downsamplePoints(data.Usage, maxPoints)
+		data.Requests = downsamplePoints(data.Requests, maxPoints)
+		data.Limits = downsamplePoints(data.Limits, maxPoints)
+	}
+}
+
+// downsamplePoints averages consecutive points into at most maxPoints buckets,
+// each stamped with the timestamp of its first point
+func downsamplePoints(points []models.DataPoint, maxPoints int) []models.DataPoint {
+	if len(points) <= maxPoints {
+		return points
+	}
+
+	downsampled := make([]models.DataPoint, 0, maxPoints)
+	for bucket := 0; bucket < maxPoints; bucket++ {
+		from := bucket * len(points) / maxPoints
+		to := (bucket + 1) * len(points) / maxPoints
+		if from == to {
+			continue
+		}
+		var sum float64
+		for _, point := range points[from:to] {
+			sum += point.Value
+		}
+		downsampled = append(downsampled, models.DataPoint{
+			Timestamp: points[from].Timestamp,
+			Value:     sum / float64(to-from),
+		})
+	}
+	return downsampled
+}
+
+// finiteValue returns v, or 0 when it is NaN or ±Inf, which encoding/json cannot encode
+func finiteValue(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+// roundValue returns the finite value of a derived value, e.g. an efficiency or percentage, rounded
+// to floatPrecision decimals. Raw datapoints and absolute usage are not rounded.
+func (f valueFormat) roundValue(v float64) float64 {
+	return roundTo(v, f.floatPrecision)
+}
+
+// roundTo returns the finite value of v rounded to precision decimals, negative keeping full precision
+func roundTo(v float64, precision int) float64 {
+	v = finiteValue(v)
+	if precision < 0 {
+		return v
+	}
+	scale := math.Pow(10, float64(precision))
+	return math.Round(v*scale) / scale
 }
 
 // Helper function to convert k8s DataPoints to models DataPoints
 func convertDataPoints(k8sPoints []k8s.DataPoint) []models.DataPoint {
-	var modelPoints []models.DataPoint
+	modelPoints := []models.DataPoint{}
 	for _, point := range k8sPoints {
 		modelPoints = append(modelPoints, models.DataPoint{
 			Timestamp: point.Timestamp,
-			Value:     point.Value,
+			Value:     finiteValue(point.Value),
 		})
 	}
 	return modelPoints
 }
 
 // Helper function to convert PodMetric to models PodMetrics
-func convertMetricsToModelMetric(metric k8s.PodMetric) models.PodMetrics {
-	// Format values
-	cpuUsageStr := formatCPU(metric.CPUUsage)
-	cpuRequestStr := formatCPU(metric.CPURequest)
-	cpuLimitStr := formatCPU(metric.CPULimit)
-	
-	memUsageStr := formatMemory(metric.MemoryUsage)
-	memRequestStr := formatMemory(metric.MemoryRequest)
-	memLimitStr := formatMemory(metric.MemoryLimit)
-	
+func (f valueFormat) convertMetricsToModelMetric(metric k8s.PodMetric) models.PodMetrics {
+	return models.PodMetrics{
+		Name:           metric.Name,
+		Namespace:      metric.Namespace,
+		ContainerName:  metric.ContainerName,
+		CPU:            f.buildResourceMetrics(metric.CPUUsageCores(), metric.CPURequest, metric.CPULimit, formatCPU),
+		Memory:         f.buildResourceMetrics(metric.MemoryUsage, metric.MemoryRequest, metric.MemoryLimit, f.formatMemory),
+		Labels:         metric.Labels,
+		RestartCount:   metric.RestartCount,
+		OOMKilled:      metric.OOMKilled,
+		CreatedAt:      optionalTime(metric.StartTime),
+		LastSeen:       optionalTime(metric.SampleTime),
+		QoSClass:       metric.QoSClass,
+		MemoryPressure: f.memoryPressure(metric.MemoryUsage, metric.MemoryLimit),
+	}
+}
+
+// Helper function to check whether memory usage exceeds MEMORY_PRESSURE_THRESHOLD percent of the limit
+func (f valueFormat) memoryPressure(usage, limit float64) bool {
+	return limit > 0 && usage/limit*100 > f.memoryPressureThreshold
+}
+
+// Helper function to omit unknown times from responses
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Helper function to build formatted resource metrics with request/limit percentages
+func (f valueFormat) buildResourceMetrics(usage, request, limit float64, format func(float64) string) models.ResourceMetrics {
 	// Calculate percentages
-	var cpuRequestPercentage, cpuLimitPercentage float64
-	var memRequestPercentage, memLimitPercentage float64
-	
-	if metric.CPURequest > 0 {
-		cpuRequestPercentage = (metric.CPUUsage / metric.CPURequest) * 100
+	var requestPercentage, limitPercentage float64
+	if request > 0 {
+		requestPercentage = (usage / request) * 100
+	}
+	if limit > 0 {
+		limitPercentage = (usage / limit) * 100
+	}
+
+	usage, request, limit = finiteValue(usage), finiteValue(request), finiteValue(limit)
+	requestPercentage, limitPercentage = f.roundValue(requestPercentage), f.roundValue(limitPercentage)
+
+	return models.ResourceMetrics{
+		Usage:             format(usage),
+		Request:           format(request),
+		Limit:             format(limit),
+		UsageValue:        usage,
+		RequestValue:      request,
+		LimitValue:        limit,
+		RequestPercentage: requestPercentage,
+		LimitPercentage:   limitPercentage,
+		HasRequest:        request > 0,
+		HasLimit:          limit > 0,
 	}
-	if metric.CPULimit > 0 {
-		cpuLimitPercentage = (metric.CPUUsage / metric.CPULimit) * 100
+}
+
+// Pod name suffixes added by workload controllers. Generated suffixes use the
+// Kubernetes safe alphabet (no vowels, no 0/1/3) so they don't form words.
+var (
+	deploymentPodPattern  = regexp.MustCompile(`^(.+)-[bcdfghjklmnpqrstvwxz2456789]{6,10}-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+	statefulSetPodPattern = regexp.MustCompile(`^(.+)-\d+$`)
+	generatedPodPattern   = regexp.MustCompile(`^(.+)-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+)
+
+// Helper function to derive the owning workload name from a pod name
+func workloadName(pod string) string {
+	for _, pattern := range []*regexp.Regexp{deploymentPodPattern, statefulSetPodPattern, generatedPodPattern} {
+		if m := pattern.FindStringSubmatch(pod); m != nil {
+			return m[1]
+		}
 	}
-	if metric.MemoryRequest > 0 {
-		memRequestPercentage = (metric.MemoryUsage / metric.MemoryRequest) * 100
+	return pod
+}
+
+// Helper function to sum pod metrics per workload container
+func (f valueFormat) groupByWorkload(metrics []k8s.PodMetric) []models.WorkloadMetrics {
+	type workloadTotals struct {
+		name, namespace, container string
+		pods                       map[string]bool
+		total                      k8s.PodMetric
 	}
-	if metric.MemoryLimit > 0 {
-		memLimitPercentage = (metric.MemoryUsage / metric.MemoryLimit) * 100
+
+	groups := make(map[string]*workloadTotals)
+	var keys []string
+	for _, metric := range metrics {
+		name := workloadName(metric.Name)
+		key := metric.Namespace + "/" + name + "/" + metric.ContainerName
+		group, exists := groups[key]
+		if !exists {
+			group = &workloadTotals{name: name, namespace: metric.Namespace, container: metric.ContainerName, pods: make(map[string]bool)}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.pods[metric.Name] = true
+		group.total.CPUUsage += metric.CPUUsageCores()
+		group.total.CPURequest += metric.CPURequest
+		group.total.CPULimit += metric.CPULimit
+		group.total.MemoryUsage += metric.MemoryUsage
+		group.total.MemoryRequest += metric.MemoryRequest
+		group.total.MemoryLimit += metric.MemoryLimit
 	}
-	
-	return models.PodMetrics{
-		Name:          metric.Name,
-		Namespace:     metric.Namespace,
-		ContainerName: metric.ContainerName,
-		CPU: models.ResourceMetrics{
-			Usage:             cpuUsageStr,
-			Request:           cpuRequestStr,
-			Limit:             cpuLimitStr,
-			UsageValue:        metric.CPUUsage,
-			RequestValue:      metric.CPURequest,
-			LimitValue:        metric.CPULimit,
-			RequestPercentage: cpuRequestPercentage,
-			LimitPercentage:   cpuLimitPercentage,
-		},
-		Memory: models.ResourceMetrics{
-			Usage:             memUsageStr,
-			Request:           memRequestStr,
-			Limit:             memLimitStr,
-			UsageValue:        metric.MemoryUsage,
-			RequestValue:      metric.MemoryRequest,
-			LimitValue:        metric.MemoryLimit,
-			RequestPercentage: memRequestPercentage,
-			LimitPercentage:   memLimitPercentage,
-		},
-		Labels: metric.Labels,
+
+	workloads := make([]models.WorkloadMetrics, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		replicas := len(group.pods)
+		workloads = append(workloads, models.WorkloadMetrics{
+			Name:               group.name,
+			Namespace:          group.namespace,
+			ContainerName:      group.container,
+			Replicas:           replicas,
+			CPU:                f.buildResourceMetrics(group.total.CPUUsage, group.total.CPURequest, group.total.CPULimit, formatCPU),
+			Memory:             f.buildResourceMetrics(group.total.MemoryUsage, group.total.MemoryRequest, group.total.MemoryLimit, f.formatMemory),
+			AverageCPUUsage:    group.total.CPUUsage / float64(replicas),
+			AverageMemoryUsage: group.total.MemoryUsage / float64(replicas),
+		})
 	}
+	return workloads
 }
 
 // Helper function to format CPU values (cores to millicores)
 func formatCPU(cpuCores float64) string {
-	if cpuCores == 0 {
-		return "0m"
-	}
-	// Convert cores to millicores
-	millicores := cpuCores * 1000
-	if millicores < 1 {
-		return fmt.Sprintf("%.1fm", millicores)
-	}
-	return fmt.Sprintf("%.0fm", millicores)
+	return units.FormatCPU(cpuCores)
 }
 
-// Helper function to format memory values (bytes to human readable)
-func formatMemory(bytes float64) string {
-	// DEBUG: Log memory conversion
-	log.Printf("DEBUG: formatMemory input: %.0f bytes", bytes)
-	
-	if bytes == 0 {
-		return "0Mi"
-	}
-	
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-	)
-	
-	var result string
-	if bytes >= GB {
-		result = fmt.Sprintf("%.1fGi", bytes/GB)
-	} else if bytes >= MB {
-		result = fmt.Sprintf("%.0fMi", bytes/MB)
-	} else if bytes >= KB {
-		result = fmt.Sprintf("%.0fKi", bytes/KB)
-	} else {
-		result = fmt.Sprintf("%.0fB", bytes)
-	}
-	
-	// DEBUG: Log conversion result
-	log.Printf("DEBUG: formatMemory output: %s (%.2f Mi)", result, bytes/MB)
-	
-	return result
+// Helper function to format memory in the configured unit base
+func (f valueFormat) formatMemory(bytes float64) string {
+	return units.FormatMemory(bytes, f.memoryUnitBase)
 }
 
 // Helper function to generate analysis summary
-func generateAnalysisSummary(metrics []models.HistoricalMetrics) models.AnalysisSummary {
+func (f valueFormat) generateAnalysisSummary(metrics []models.HistoricalMetrics, buckets []float64) models.AnalysisSummary {
 	if len(metrics) == 0 {
-		return models.AnalysisSummary{}
+		// Keep the breakdown and histogram present, with zero counts
+		return models.AnalysisSummary{
+			RecommendationBreakdown: map[string]int{},
+			EfficiencyHistogram:     newEfficiencyHistogram(buckets),
+		}
 	}
 
 	var totalEfficiency float64
-	var overProvisioned, underProvisioned, wellOptimized int
+	var weightedCPU, totalCPURequest, weightedMemory, totalMemoryRequest float64
+	var overProvisioned, underProvisioned, wellOptimized, outliers int
 	var totalRecommendations int
 	recommendationCount := make(map[string]int)
+	categoryCount := make(map[string]int)
+	histogram := newEfficiencyHistogram(buckets)
 
 	for _, metric := range metrics {
 		// Count efficiency
 		avgEfficiency := (metric.Analysis.CPUEfficiency + metric.Analysis.MemoryEfficiency) / 2
 		totalEfficiency += avgEfficiency
+		histogram[efficiencyBucketIndex(buckets, metric.Analysis.CPUEfficiency)].CPU++
+		histogram[efficiencyBucketIndex(buckets, metric.Analysis.MemoryEfficiency)].Memory++
+
+		// Weight efficiency by the average request
+		cpuRequest := averageDataPoints(metric.CPU.Requests)
+		weightedCPU += metric.Analysis.CPUEfficiency * cpuRequest
+		totalCPURequest += cpuRequest
+		memRequest := averageDataPoints(metric.Memory.Requests)
+		weightedMemory += metric.Analysis.MemoryEfficiency * memRequest
+		totalMemoryRequest += memRequest
 
 		// Categorize based on resource waste analysis
-		if metric.Analysis.ResourceWaste.CPUOverProvisioned || metric.Analysis.ResourceWaste.MemoryOverProvisioned {
+		switch classifyAnalysis(metric.Analysis) {
+		case classificationOverProvisioned:
 			overProvisioned++
-		} else if metric.Analysis.ResourceWaste.CPUUnderProvisioned || metric.Analysis.ResourceWaste.MemoryUnderProvisioned {
+		case classificationUnderProvisioned:
 			underProvisioned++
-		} else {
+		default:
 			wellOptimized++
 		}
 
+		if metric.NamespaceComparison != nil && metric.NamespaceComparison.Outlier {
+			outliers++
+		}
+
 		// Count recommendations
 		totalRecommendations += len(metric.Analysis.Recommendations)
 		for _, rec := range metric.Analysis.Recommendations {
 			recommendationCount[rec]++
+			categoryCount[categorizeRecommendation(rec)]++
 		}
 	}
 
@@ -543,14 +1605,105 @@ func generateAnalysisSummary(metrics []models.HistoricalMetrics) models.Analysis
 		}
 	}
 
+	// Find most common category
+	var mostCommonCategory string
+	maxCount = 0
+	for category, count := range categoryCount {
+		if count > maxCount || (count == maxCount && category < mostCommonCategory) {
+			maxCount = count
+			mostCommonCategory = category
+		}
+	}
+
+	var weightedCPUEfficiency, weightedMemoryEfficiency float64
+	if totalCPURequest > 0 {
+		weightedCPUEfficiency = weightedCPU / totalCPURequest
+	}
+	if totalMemoryRequest > 0 {
+		weightedMemoryEfficiency = weightedMemory / totalMemoryRequest
+	}
+
 	return models.AnalysisSummary{
 		TotalPodsAnalyzed:        len(metrics),
 		OverProvisionedPods:      overProvisioned,
 		UnderProvisionedPods:     underProvisioned,
 		WellOptimizedPods:        wellOptimized,
-		AverageEfficiency:        totalEfficiency / float64(len(metrics)),
+		AverageEfficiency:        f.roundValue(totalEfficiency / float64(len(metrics))),
+		WeightedCPUEfficiency:    f.roundValue(weightedCPUEfficiency),
+		WeightedMemoryEfficiency: f.roundValue(weightedMemoryEfficiency),
+		WeightedEfficiency:       f.roundValue((weightedCPUEfficiency + weightedMemoryEfficiency) / 2),
+		OutlierPods:              outliers,
 		TotalRecommendations:     totalRecommendations,
 		MostCommonRecommendation: mostCommon,
+		MostCommonCategory:       mostCommonCategory,
+		RecommendationBreakdown:  categoryCount,
+		EfficiencyHistogram:      histogram,
+	}
+}
+
+// Helper function to create empty histogram buckets from ascending upper bounds.
+// The last bucket is open-ended since efficiency exceeds 100% when usage is above the request.
+func newEfficiencyHistogram(bounds []float64) []models.EfficiencyBucket {
+	histogram := make([]models.EfficiencyBucket, 0, len(bounds)+1)
+	lower := 0.0
+	for i := range bounds {
+		histogram = append(histogram, models.EfficiencyBucket{
+			Label: fmt.Sprintf("%g-%g", lower, bounds[i]),
+			Min:   lower,
+			Max:   &bounds[i],
+		})
+		lower = bounds[i]
+	}
+	return append(histogram, models.EfficiencyBucket{
+		Label: fmt.Sprintf("%g+", lower),
+		Min:   lower,
+	})
+}
+
+// Helper function to find the bucket for an efficiency, boundary values belong to the upper bucket
+func efficiencyBucketIndex(bounds []float64, efficiency float64) int {
+	for i, bound := range bounds {
+		if efficiency < bound {
+			return i
+		}
+	}
+	return len(bounds)
+}
+
+// Helper function to calculate the average value of data points
+func averageDataPoints(points []models.DataPoint) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, point := range points {
+		sum += point.Value
+	}
+	return sum / float64(len(points))
+}
+
+// Helper function to map a free-text recommendation to a stable category
+func categorizeRecommendation(recommendation string) string {
+	switch {
+	case strings.HasPrefix(recommendation, "Consider reducing CPU"):
+		return models.RecommendationReduceCPU
+	case strings.HasPrefix(recommendation, "Consider increasing CPU"):
+		return models.RecommendationIncreaseCPU
+	case strings.HasPrefix(recommendation, "Consider reducing memory"):
+		return models.RecommendationReduceMemory
+	case strings.HasPrefix(recommendation, "Consider increasing memory"):
+		return models.RecommendationIncreaseMemory
+	case strings.HasPrefix(recommendation, "CPU usage is trending upward"):
+		return models.RecommendationCPUTrendingUp
+	case strings.HasPrefix(recommendation, "Memory usage is trending upward"):
+		return models.RecommendationMemoryTrendingUp
+	case strings.Contains(recommendation, "OOMKilled"):
+		return models.RecommendationOOMKilled
+	case strings.HasPrefix(recommendation, "Resource usage appears well-optimized"):
+		return models.RecommendationWellOptimized
+	default:
+		return models.RecommendationOther
 	}
 }
 
@@ -558,8 +1711,9 @@ func generateAnalysisSummary(metrics []models.HistoricalMetrics) models.Analysis
 func generatePodTrendSummary(containers []models.HistoricalMetrics) models.PodTrendSummary {
 	if len(containers) == 0 {
 		return models.PodTrendSummary{
-			OverallTrend: "unknown",
-			RiskLevel:    "unknown",
+			OverallTrend:            "unknown",
+			ResourceRecommendations: []string{},
+			RiskLevel:               "unknown",
 		}
 	}
 
@@ -614,7 +1768,7 @@ func generatePodTrendSummary(containers []models.HistoricalMetrics) models.PodTr
 
 	// Remove duplicate recommendations
 	uniqueRecommendations := make(map[string]bool)
-	var finalRecommendations []string
+	finalRecommendations := []string{}
 	for _, rec := range allRecommendations {
 		if !uniqueRecommendations[rec] {
 			uniqueRecommendations[rec] = true
@@ -643,7 +1797,11 @@ func generatePodTrendSummary(containers []models.HistoricalMetrics) models.PodTr
 
 // GetPodSummary returns summary statistics including low and high usage pods
 func (h *Handler) GetPodSummary(w http.ResponseWriter, r *http.Request) {
-	if h.metricsClient == nil {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
 		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
 		return
 	}
@@ -652,19 +1810,26 @@ func (h *Handler) GetPodSummary(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Get namespace from query parameter
-	namespace := r.URL.Query().Get("namespace")
+	namespace := namespaceParam(r)
 
-	metricsData, err := h.metricsClient.GetCurrentPodMetrics(ctx, namespace)
-	if err != nil {
-		log.Printf("Error getting pod metrics from %s: %v", h.metricsClient.GetClientType(), err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Serve from the cached snapshot when available
+	var metricsData []k8s.PodMetric
+	if snapshot, ok := h.cachedPodMetrics(r, metricsClient, namespace, time.Now()); ok {
+		metricsData = snapshot.metrics
+	} else {
+		result, err := h.currentPodMetrics(ctx, metricsClient, h.sourceKey(r), namespace, "", "", time.Now())
+		if err != nil {
+			log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		metricsData = result.metrics
 	}
 
 	// Convert metrics to models format
 	var pods []models.PodMetrics
 	for _, metric := range metricsData {
-		podMetric := convertMetricsToModelMetric(metric)
+		podMetric := h.format.convertMetricsToModelMetric(metric)
 		pods = append(pods, podMetric)
 	}
 
@@ -673,6 +1838,7 @@ func (h *Handler) GetPodSummary(w http.ResponseWriter, r *http.Request) {
 	var totalCPUUsage, totalMemoryUsage float64
 	var highCPUPods, highMemoryPods int
 	var lowCPUPods, lowMemoryPods int
+	var memoryPressurePods int
 
 	for _, pod := range pods {
 		// Add to totals for averages
@@ -694,6 +1860,11 @@ func (h *Handler) GetPodSummary(w http.ResponseWriter, r *http.Request) {
 		if pod.Memory.RequestPercentage < 40 && pod.Memory.RequestPercentage > 0 {
 			lowMemoryPods++
 		}
+
+		// Count pods close to their memory limit
+		if pod.MemoryPressure {
+			memoryPressurePods++
+		}
 	}
 
 	// Calculate averages
@@ -705,24 +1876,412 @@ func (h *Handler) GetPodSummary(w http.ResponseWriter, r *http.Request) {
 
 	// Create response
 	response := models.PodSummaryResponse{
-		TotalPods:          totalPods,
-		AverageCPUUsage:    averageCPUUsage,
-		AverageMemoryUsage: averageMemoryUsage,
-		HighCPUPods:        highCPUPods,
-		HighMemoryPods:     highMemoryPods,
-		LowCPUPods:         lowCPUPods,
-		LowMemoryPods:      lowMemoryPods,
-		GeneratedAt:        time.Now(),
+		TotalPods:              totalPods,
+		AverageCPUUsage:        h.format.roundValue(averageCPUUsage),
+		AverageCPUUsageUnit:    models.SummaryUnitPercentOfRequest,
+		AverageMemoryUsage:     h.format.roundValue(averageMemoryUsage),
+		AverageMemoryUsageUnit: models.SummaryUnitPercentOfRequest,
+		HighCPUPods:            highCPUPods,
+		HighMemoryPods:         highMemoryPods,
+		LowCPUPods:             lowCPUPods,
+		LowMemoryPods:          lowMemoryPods,
+		MemoryPressurePods:     memoryPressurePods,
+		GeneratedAt:            time.Now(),
+	}
+
+	// Add display strings for the language requested with Accept-Language
+	if format, ok := negotiateNumberFormat(r); ok {
+		response.Formatted = &models.PodSummaryFormatted{
+			Locale:             format.locale,
+			TotalPods:          format.formatNumber(float64(totalPods)),
+			AverageCPUUsage:    format.formatPercent(response.AverageCPUUsage),
+			AverageMemoryUsage: format.formatPercent(response.AverageMemoryUsage),
+		}
+		w.Header().Set("Content-Language", format.locale)
+		w.Header().Add("Vary", "Accept-Language")
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// GetClusterCapacity returns cluster-wide requests, limits and usage compared to node allocatable
+func (h *Handler) GetClusterCapacity(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	nodes, err := metricsClient.GetNodeAllocatable(ctx)
+	if err != nil {
+		log.Printf("Error getting node allocatable from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, "", "", time.Now())
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Sum allocatable across nodes
+	var cpuAllocatable, memAllocatable float64
+	for _, node := range nodes {
+		cpuAllocatable += node.CPU
+		memAllocatable += node.Memory
+	}
+
+	// Sum requests, limits and usage across pods
+	var cpuRequests, cpuLimits, cpuUsage float64
+	var memRequests, memLimits, memUsage float64
+	for _, metric := range metricsData {
+		cpuRequests += metric.CPURequest
+		cpuLimits += metric.CPULimit
+		cpuUsage += metric.CPUUsageCores()
+		memRequests += metric.MemoryRequest
+		memLimits += metric.MemoryLimit
+		memUsage += metric.MemoryUsage
+	}
+
+	// Create response
+	response := models.ClusterCapacityResponse{
+		NodeCount:   len(nodes),
+		CPU:         h.format.buildResourceCapacity(cpuAllocatable, cpuRequests, cpuLimits, cpuUsage),
+		Memory:      h.format.buildResourceCapacity(memAllocatable, memRequests, memLimits, memUsage),
+		GeneratedAt: time.Now(),
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// Helper function to compare committed and used resources against allocatable capacity
+func (f valueFormat) buildResourceCapacity(allocatable, requests, limits, usage float64) models.ResourceCapacity {
+	capacity := models.ResourceCapacity{
+		Allocatable: allocatable,
+		Requests:    requests,
+		Limits:      limits,
+		Usage:       usage,
+		Headroom:    allocatable - requests,
+	}
+
+	if allocatable > 0 {
+		capacity.RequestsPercentage = f.roundValue((requests / allocatable) * 100)
+		capacity.LimitsPercentage = f.roundValue((limits / allocatable) * 100)
+		capacity.UsagePercentage = f.roundValue((usage / allocatable) * 100)
+	}
+
+	return capacity
+}
+
+// Default thresholds for /api/pods/idle
+const (
+	defaultIdleMaxCPUMillicores           = 5.0
+	defaultIdleMaxMemoryRequestPercentage = 10.0
+)
+
+// GetIdlePods returns pods whose CPU and memory usage are below the idle thresholds
+func (h *Handler) GetIdlePods(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Get parameters
+	namespace := namespaceParam(r)
+	historical := r.URL.Query().Get("historical") == "true"
+
+	maxCPU, err := parseFloatParam(r, "maxCpuMillicores", defaultIdleMaxCPUMillicores)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxMemory, err := parseFloatParam(r, "maxMemoryRequestPercent", defaultIdleMaxMemoryRequestPercentage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pods := []models.IdlePod{}
+	if historical {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, k8s.HistoricalOptions{})
+		if err != nil {
+			log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, hm := range historicalData {
+			memRequest := 0.0
+			if len(hm.Memory.Requests) > 0 {
+				memRequest = hm.Memory.Requests[len(hm.Memory.Requests)-1].Value
+			}
+			pod := h.format.buildIdlePod(hm.PodName, hm.Namespace, hm.ContainerName, hm.CPU.Average, hm.Memory.Average, memRequest)
+			if isIdle(pod, maxCPU, maxMemory) {
+				pods = append(pods, pod)
+			}
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+
+		metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, namespace, "", time.Now())
+		if err != nil {
+			log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, metric := range metricsData {
+			pod := h.format.buildIdlePod(metric.Name, metric.Namespace, metric.ContainerName, metric.CPUUsageCores(), metric.MemoryUsage, metric.MemoryRequest)
+			if isIdle(pod, maxCPU, maxMemory) {
+				pods = append(pods, pod)
+			}
+		}
+	}
+
+	// Create response
+	response := models.IdlePodsResponse{
+		Pods:                       pods,
+		Historical:                 historical,
+		MaxCPUMillicores:           maxCPU,
+		MaxMemoryRequestPercentage: maxMemory,
+		GeneratedAt:                time.Now(),
 	}
 
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
 
 	// Write response
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// Helper function to build an idle pod candidate from CPU cores and memory bytes
+func (f valueFormat) buildIdlePod(name, namespace, container string, cpuCores, memUsage, memRequest float64) models.IdlePod {
+	pod := models.IdlePod{
+		Name:          name,
+		Namespace:     namespace,
+		ContainerName: container,
+		CPUMillicores: cpuCores * 1000,
+		MemoryUsage:   memUsage,
+		MemoryRequest: memRequest,
+	}
+	if memRequest > 0 {
+		pod.MemoryRequestPercentage = f.roundValue((memUsage / memRequest) * 100)
+	}
+	return pod
+}
+
+// Helper function to check a pod against the idle thresholds.
+// Containers without a memory request are judged on CPU only.
+func isIdle(pod models.IdlePod, maxCPUMillicores, maxMemoryRequestPercentage float64) bool {
+	return pod.CPUMillicores < maxCPUMillicores && pod.MemoryRequestPercentage < maxMemoryRequestPercentage
+}
+
+// Helper function to validate a user-supplied label selector before it is injected into queries.
+// Matchers on __name__ are rejected since they would change the queried metric.
+func validSelector(selector string) bool {
+	if len(selector) > maxRawQueryLength || !k8s.ValidLabelFilters(selector) {
+		return false
+	}
+	return !selectorNamePattern.MatchString(selector)
+}
+
+var selectorNamePattern = regexp.MustCompile(`(^|,)\s*__name__\s*[=!]`)
+
+// Helper function to parse an evaluation time given as RFC3339 or relative to now (e.g. "-2h").
+// An empty value means now; future times are rejected.
+func parseEvalTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now, nil
+	}
+
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		offset, durErr := time.ParseDuration(value)
+		if durErr != nil {
+			return time.Time{}, fmt.Errorf("invalid at parameter: %s (expected RFC3339 or a relative duration like -2h)", value)
+		}
+		at = now.Add(offset)
+	}
+
+	if at.After(now) {
+		return time.Time{}, fmt.Errorf("invalid at parameter: %s is in the future", value)
+	}
+	return at, nil
+}
+
+// Helper function to parse a non-negative float query parameter
+func parseFloatParam(r *http.Request, name string, defaultValue float64) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s parameter: %s", name, value)
+	}
+	return parsed, nil
+}
+
+// RawQuery runs an arbitrary instant query through the configured backend and returns the raw result
+func (h *Handler) RawQuery(w http.ResponseWriter, r *http.Request) {
+	if !h.enableRawQuery {
+		http.Error(w, "Raw queries are disabled - set ENABLE_RAW_QUERY=true to enable", http.StatusForbidden)
+		return
+	}
+	if h.namespacesRestricted() {
+		http.Error(w, "Raw queries are disabled while NAMESPACE_ALLOWLIST or ENABLE_AUTH is set", http.StatusForbidden)
+		return
+	}
+
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	if err := validateRawQuery(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), rawQueryTimeout)
+	defer cancel()
+
+	samples, err := metricsClient.QueryInstant(ctx, query)
+	if err != nil {
+		if errors.Is(err, k8s.ErrInvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error running raw query on %s: %v", metricsClient.GetClientType(), err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	// Bound the response size
+	truncated := false
+	if len(samples) > maxRawQuerySeries {
+		samples = samples[:maxRawQuerySeries]
+		truncated = true
+	}
+
+	result := make([]models.RawQuerySample, 0, len(samples))
+	for _, sample := range samples {
+		result = append(result, models.RawQuerySample{
+			Metric:    sample.Metric,
+			Value:     sample.Value,
+			Timestamp: sample.Timestamp,
+		})
+	}
+
+	// Create response
+	response := models.RawQueryResponse{
+		Query:      query,
+		Result:     result,
+		Truncated:  truncated,
+		ExecutedAt: time.Now(),
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// Helper function to reject empty, oversized or obviously malformed raw queries
+func validateRawQuery(query string) error {
+	if query == "" {
+		return fmt.Errorf("query parameter is required")
+	}
+	if len(query) > maxRawQueryLength {
+		return fmt.Errorf("query exceeds maximum length of %d characters", maxRawQueryLength)
+	}
+
+	// Check that brackets are balanced outside of string literals
+	var stack []rune
+	var quote rune
+	escaped := false
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	for _, c := range query {
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '[', '{':
+			stack = append(stack, c)
+		case ')', ']', '}':
+			if len(stack) == 0 || stack[len(stack)-1] != pairs[c] {
+				return fmt.Errorf("malformed query: unbalanced %q", c)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("malformed query: unterminated string literal")
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("malformed query: unbalanced %q", stack[len(stack)-1])
+	}
+
+	return nil
+}
+
+// StartAlerts starts the background alerter and threshold exporter if they are enabled
+func (h *Handler) StartAlerts(ctx context.Context) {
+	if h.alerter != nil {
+		go h.alerter.Run(ctx)
+	}
+	if h.thresholds != nil {
+		go h.thresholds.Run(ctx)
+	}
+}
+
+// StartCacheRefresh starts refreshing the metrics cache in the background until ctx is cancelled.
+// It is a no-op unless METRICS_ENABLE_CACHING is set.
+func (h *Handler) StartCacheRefresh(ctx context.Context) {
+	if h.cache == nil {
+		return
+	}
+	go h.runCacheRefresh(ctx)
 }
 
 // Environment variable helper functions
@@ -746,6 +2305,17 @@ func getEnvIntWithDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvFloatWithDefault returns the environment variable as a float or the default if not set/invalid
+func getEnvFloatWithDefault(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		log.Printf("WARN: Invalid float value for %s: %s, using default: %g", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 // getEnvBoolWithDefault returns the environment variable as a boolean or the default if not set/invalid
 func getEnvBoolWithDefault(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
@@ -757,13 +2327,84 @@ func getEnvBoolWithDefault(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvBucketsWithDefault returns the environment variable as ascending, comma-separated positive numbers or the default if not set/invalid
+func getEnvBucketsWithDefault(key string, defaultValue []float64) []float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var buckets []float64
+	for _, part := range strings.Split(value, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || bound <= 0 || (len(buckets) > 0 && bound <= buckets[len(buckets)-1]) {
+			log.Printf("WARN: Invalid bucket list for %s: %s, using default: %v", key, value, defaultValue)
+			return defaultValue
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets
+}
+
+// loadQueryTemplates reads METRICS_QUERY_<NAME> metric overrides, METRICS_QUERY_EXTRA_FILTERS, CONTAINER_FILTER
+// and METRICS_QUERY_CPU_USAGE_UNIT
+func loadQueryTemplates() k8s.QueryTemplates {
+	templates := k8s.QueryTemplates{
+		Metrics:         make(map[string]string),
+		ExtraFilters:    getLabelFiltersFromEnv("METRICS_QUERY_EXTRA_FILTERS", ""),
+		ContainerFilter: getLabelFiltersFromEnv("CONTAINER_FILTER", k8s.DefaultContainerFilter),
+	}
+	// MEMORY_METRIC selects the memory usage metric unless it is overridden explicitly
+	memoryMetric := getEnvWithDefault("MEMORY_METRIC", k8s.MemoryWorkingSet)
+	if metric, ok := k8s.MemoryMetrics[memoryMetric]; ok {
+		templates.Metrics[k8s.QueryMemoryUsage] = metric
+	} else {
+		log.Printf("WARN: Invalid value for MEMORY_METRIC: %s, using default: %s", memoryMetric, k8s.MemoryWorkingSet)
+	}
+	for name := range k8s.DefaultQueryTemplates {
+		if metric := os.Getenv("METRICS_QUERY_" + strings.ToUpper(name)); metric != "" {
+			templates.Metrics[name] = metric
+		}
+	}
+	cpuUnit, err := k8s.ParseCPUUnit(os.Getenv("METRICS_QUERY_CPU_USAGE_UNIT"))
+	if err != nil {
+		log.Printf("WARN: Invalid value for METRICS_QUERY_CPU_USAGE_UNIT: %v, using default: %s", err, k8s.CPUUnitCores)
+		cpuUnit = k8s.CPUUnitCores
+	}
+	templates.CPUUnit = cpuUnit
+	return templates
+}
+
+// getLabelFiltersFromEnv returns the environment variable as PromQL label filters or the default if not set/invalid
+func getLabelFiltersFromEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		if k8s.ValidLabelFilters(value) {
+			return value
+		}
+		log.Printf("WARN: Invalid label filters for %s: %s, using default: %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvDurationWithDefault returns the environment variable as a duration or the default if not set/invalid
+func getEnvDurationWithDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+		log.Printf("WARN: Invalid duration value for %s: %s, using default: %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 // EnableCORS is a middleware that sets CORS headers
 func EnableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Metrics-Backend, X-Analysis-Cache")
 
 		// If this is a preflight request, respond with 200 OK
 		if r.Method == "OPTIONS" {
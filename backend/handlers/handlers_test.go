@@ -0,0 +1,1819 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+	"github.com/bean-stalk-k8s/backend/units"
+)
+
+// fakeMetricsClient is a MetricsClient serving canned data, filtered by namespace
+type fakeMetricsClient struct {
+	clientType string
+	current    []k8s.PodMetric
+	historical []k8s.HistoricalMetrics
+	active     []k8s.PodInfo
+	namespaces []string
+	nodes      []k8s.NodeAllocatable
+	podNodes   map[string]string
+	podLabels  map[string]map[string]string
+	status     k8s.PodStatus
+	series     []k8s.DataPoint
+	samples    []k8s.QuerySample
+	err        error
+
+	// currentHook runs on every GetCurrentPodMetrics call, e.g. to record its arguments or block
+	currentHook  func(ctx context.Context, namespace, selector string, at time.Time)
+	currentCalls atomic.Int32
+
+	// historicalOpts records the options of the last GetHistoricalMetrics call
+	historicalOpts atomic.Pointer[k8s.HistoricalOptions]
+}
+
+func (f *fakeMetricsClient) GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]k8s.PodMetric, error) {
+	f.currentCalls.Add(1)
+	if f.currentHook != nil {
+		f.currentHook(ctx, namespace, selector, at)
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	var metrics []k8s.PodMetric
+	for _, metric := range f.current {
+		if namespace == "" || metric.Namespace == namespace {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+func (f *fakeMetricsClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts k8s.HistoricalOptions) ([]k8s.HistoricalMetrics, error) {
+	f.historicalOpts.Store(&opts)
+	if f.err != nil {
+		return nil, f.err
+	}
+	var metrics []k8s.HistoricalMetrics
+	for _, hm := range f.historical {
+		if namespace == "" || hm.Namespace == namespace {
+			metrics = append(metrics, hm)
+		}
+	}
+	return metrics, nil
+}
+
+func (f *fakeMetricsClient) StreamHistoricalMetrics(ctx context.Context, namespace string, opts k8s.HistoricalOptions, fn func(k8s.HistoricalMetrics) error) error {
+	metrics, err := f.GetHistoricalMetrics(ctx, namespace, opts)
+	if err != nil {
+		return err
+	}
+	for _, hm := range metrics {
+		if err := fn(hm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeMetricsClient) GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]k8s.PodInfo, error) {
+	var pods []k8s.PodInfo
+	for _, pod := range f.active {
+		if namespace == "" || pod.Namespace == namespace {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, f.err
+}
+
+func (f *fakeMetricsClient) GetContainerSeries(ctx context.Context, namespace, pod, container, metric string, start, end time.Time) ([]k8s.DataPoint, error) {
+	return f.series, f.err
+}
+
+func (f *fakeMetricsClient) GetNamespaces(ctx context.Context) ([]string, error) {
+	return f.namespaces, f.err
+}
+
+func (f *fakeMetricsClient) GetNodeAllocatable(ctx context.Context) ([]k8s.NodeAllocatable, error) {
+	return f.nodes, f.err
+}
+
+func (f *fakeMetricsClient) GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error) {
+	return f.podNodes, f.err
+}
+
+func (f *fakeMetricsClient) GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error) {
+	return f.podLabels, f.err
+}
+
+func (f *fakeMetricsClient) GetPodStatus(ctx context.Context, namespace, pod string) (k8s.PodStatus, error) {
+	return f.status, f.err
+}
+
+func (f *fakeMetricsClient) QueryInstant(ctx context.Context, query string) ([]k8s.QuerySample, error) {
+	return f.samples, f.err
+}
+
+func (f *fakeMetricsClient) Ping(ctx context.Context) error {
+	return f.err
+}
+
+func (f *fakeMetricsClient) Close() error {
+	return nil
+}
+
+func (f *fakeMetricsClient) GetClientType() string {
+	if f.clientType == "" {
+		return "fake"
+	}
+	return f.clientType
+}
+
+// newTestHandler returns a Handler serving metricsClient
+func newTestHandler(metricsClient k8s.MetricsClient) *Handler {
+	return &Handler{
+		clusters:         map[string]k8s.MetricsClient{"default": metricsClient},
+		clusterNames:     []string{"default"},
+		defaultCluster:   "default",
+		staleThreshold:   2 * time.Minute,
+		histogramBuckets: []float64{20, 40, 60, 80, 100},
+		maxAnalysisRange: defaultMaxAnalysisRange,
+		baselines:        newAnalysisBaselines(defaultCacheMaxEntries),
+		jobs:             newAnalysisJobs(defaultAnalysisJobTTL, defaultMaxRunningAnalysisJobs),
+		headroomDefaults: recommendationHeadroom{cpu: defaultCPUHeadroomPercent, memory: defaultMemoryHeadroomPercent},
+		format:           defaultValueFormat,
+	}
+}
+
+// serve runs handler on a GET of target through the request parameter middleware
+func serve(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ParseRequestParams(handler).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+// decodeResponse decodes the JSON body of a successful response into v
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+func TestParseAnalysisRange(t *testing.T) {
+	const maxRange = 30 * 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		query   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"default", "", k8s.DefaultAnalysisWindow, false},
+		{"24h", "range=24h", 24 * time.Hour, false},
+		{"90m", "range=90m", 90 * time.Minute, false},
+		{"seconds", "range=45s", 45 * time.Second, false},
+		{"days", "days=3", 3 * 24 * time.Hour, false},
+		{"range takes precedence over days", "range=90m&days=3", 90 * time.Minute, false},
+		{"at the maximum", "range=720h", maxRange, false},
+		{"range over the maximum", "range=721h", 0, true},
+		{"days over the maximum", "days=31", 0, true},
+		{"day suffix", "range=7d", 0, true},
+		{"zero range", "range=0s", 0, true},
+		{"negative range", "range=-1h", 0, true},
+		{"invalid days", "days=two", 0, true},
+		{"zero days", "days=0", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/pods/trends?"+tt.query, nil)
+			got, err := parseAnalysisRange(r, maxRange)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAnalysisRange(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAnalysisRange(%q) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaysAnalyzed(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		want   int
+	}{
+		{30 * time.Minute, 1},
+		{90 * time.Minute, 1},
+		{24 * time.Hour, 1},
+		{25 * time.Hour, 2},
+		{7 * 24 * time.Hour, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window.String(), func(t *testing.T) {
+			if got := daysAnalyzed(tt.window); got != tt.want {
+				t.Errorf("daysAnalyzed(%s) = %d, want %d", tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalysisRangeReachesClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(h *Handler) http.HandlerFunc
+		target     string
+		wantWindow time.Duration
+	}{
+		{"analysis default", func(h *Handler) http.HandlerFunc { return h.GetHistoricalAnalysis }, "/api/pods/analysis?namespace=shop", k8s.DefaultAnalysisWindow},
+		{"analysis range", func(h *Handler) http.HandlerFunc { return h.GetHistoricalAnalysis }, "/api/pods/analysis?namespace=shop&range=90m", 90 * time.Minute},
+		{"trends days", func(h *Handler) http.HandlerFunc { return h.GetPodTrends }, "/api/pods/trends?namespace=shop&pod=web-0&days=2", 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{
+				replica("shop", "web-0", []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+			}}
+			h := newTestHandler(client)
+			rec := serve(tt.handler(h), tt.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			opts := client.historicalOpts.Load()
+			if opts == nil || opts.Window != tt.wantWindow {
+				t.Errorf("client options = %+v, want window %s", opts, tt.wantWindow)
+			}
+		})
+	}
+}
+
+var workloadTestStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// points returns datapoints five minutes apart starting at workloadTestStart
+func points(values ...float64) []k8s.DataPoint {
+	result := make([]k8s.DataPoint, len(values))
+	for i, v := range values {
+		result[i] = k8s.DataPoint{Timestamp: workloadTestStart.Add(time.Duration(i) * 5 * time.Minute), Value: v}
+	}
+	return result
+}
+
+// replica returns the historical metrics of an app container with constant requests and limits
+func replica(namespace, pod string, cpuUsage, memoryUsage []float64) k8s.HistoricalMetrics {
+	hm := k8s.HistoricalMetrics{PodName: pod, Namespace: namespace, ContainerName: "app"}
+	hm.CPU.Usage = points(cpuUsage...)
+	hm.CPU.Requests = points(0.5, 0.5)
+	hm.CPU.Limits = points(1, 1)
+	hm.Memory.Usage = points(memoryUsage...)
+	hm.Memory.Requests = points(256<<20, 256<<20)
+	hm.Memory.Limits = points(512<<20, 512<<20)
+	return hm
+}
+
+// podNames returns the names prefix-0 through prefix-(n-1)
+func podNames(prefix string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	return names
+}
+
+func TestIncludeCompletedReachesClient(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"&includeCompleted=false", false},
+		{"&includeCompleted=true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			client := &fakeMetricsClient{}
+			rec := serve(newTestHandler(client).GetHistoricalAnalysis, "/api/pods/analysis?namespace=batch"+tt.query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if opts := client.historicalOpts.Load(); opts == nil || opts.IncludeCompleted != tt.want {
+				t.Errorf("client options = %+v, want IncludeCompleted %v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildResourceCapacity(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		allocatable, requests, limits, usage float64
+		want                                 models.ResourceCapacity
+	}{
+		{
+			name:        "ratios of allocatable",
+			allocatable: 8, requests: 6, limits: 12, usage: 2,
+			want: models.ResourceCapacity{
+				Allocatable: 8, Requests: 6, Limits: 12, Usage: 2,
+				RequestsPercentage: 75, LimitsPercentage: 150, UsagePercentage: 25, Headroom: 2,
+			},
+		},
+		{
+			name:        "overcommitted requests leave negative headroom",
+			allocatable: 4, requests: 5, limits: 5, usage: 1,
+			want: models.ResourceCapacity{
+				Allocatable: 4, Requests: 5, Limits: 5, Usage: 1,
+				RequestsPercentage: 125, LimitsPercentage: 125, UsagePercentage: 25, Headroom: -1,
+			},
+		},
+		{
+			name:        "percentages rounded",
+			allocatable: 3, requests: 1, limits: 2, usage: 0.5,
+			want: models.ResourceCapacity{
+				Allocatable: 3, Requests: 1, Limits: 2, Usage: 0.5,
+				RequestsPercentage: 33.33, LimitsPercentage: 66.67, UsagePercentage: 16.67, Headroom: 2,
+			},
+		},
+		{
+			name:     "no allocatable",
+			requests: 1, limits: 2, usage: 0.5,
+			want: models.ResourceCapacity{Requests: 1, Limits: 2, Usage: 0.5, Headroom: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := defaultValueFormat.buildResourceCapacity(tt.allocatable, tt.requests, tt.limits, tt.usage)
+			if got != tt.want {
+				t.Errorf("buildResourceCapacity() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetClusterCapacity(t *testing.T) {
+	client := &fakeMetricsClient{
+		nodes: []k8s.NodeAllocatable{
+			{Node: "node-a", CPU: 4, Memory: 8 << 30},
+			{Node: "node-b", CPU: 4, Memory: 8 << 30},
+		},
+		current: []k8s.PodMetric{
+			{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 1, CPURequest: 2, CPULimit: 4, MemoryUsage: 2 << 30, MemoryRequest: 4 << 30, MemoryLimit: 8 << 30},
+			{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 1, CPURequest: 2, CPULimit: 4, MemoryUsage: 2 << 30, MemoryRequest: 4 << 30, MemoryLimit: 8 << 30},
+		},
+	}
+
+	var response models.ClusterCapacityResponse
+	decodeResponse(t, serve(newTestHandler(client).GetClusterCapacity, "/api/cluster/capacity"), &response)
+
+	if response.NodeCount != 2 {
+		t.Errorf("node count = %d, want 2", response.NodeCount)
+	}
+	tests := []struct {
+		name string
+		got  models.ResourceCapacity
+		want models.ResourceCapacity
+	}{
+		{"cpu", response.CPU, models.ResourceCapacity{
+			Allocatable: 8, Requests: 4, Limits: 8, Usage: 2,
+			RequestsPercentage: 50, LimitsPercentage: 100, UsagePercentage: 25, Headroom: 4,
+		}},
+		{"memory", response.Memory, models.ResourceCapacity{
+			Allocatable: 16 << 30, Requests: 8 << 30, Limits: 16 << 30, Usage: 4 << 30,
+			RequestsPercentage: 50, LimitsPercentage: 100, UsagePercentage: 25, Headroom: 8 << 30,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("capacity = %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodMetricsStale(t *testing.T) {
+	tests := []struct {
+		name           string
+		sampleAges     []time.Duration
+		staleThreshold time.Duration
+		wantTimestamp  bool
+		wantStale      bool
+		wantPodStale   []bool
+	}{
+		{"fresh samples", []time.Duration{30 * time.Second}, 2 * time.Minute, true, false, []bool{false}},
+		{"old samples", []time.Duration{10 * time.Minute}, 2 * time.Minute, true, true, []bool{true}},
+		{"newest sample decides", []time.Duration{10 * time.Minute, 30 * time.Second}, 2 * time.Minute, true, false, []bool{true, false}},
+		{"configured threshold", []time.Duration{10 * time.Minute}, 15 * time.Minute, true, false, []bool{false}},
+		{"no sample timestamps", []time.Duration{-1}, 2 * time.Minute, false, false, []bool{false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			client := &fakeMetricsClient{}
+			for i, age := range tt.sampleAges {
+				metric := k8s.PodMetric{Name: podNames("web", len(tt.sampleAges))[i], Namespace: "shop", ContainerName: "app"}
+				if age >= 0 {
+					metric.SampleTime = now.Add(-age)
+				}
+				client.current = append(client.current, metric)
+			}
+			h := newTestHandler(client)
+			h.staleThreshold = tt.staleThreshold
+
+			var response models.PodMetricsList
+			decodeResponse(t, serve(h.GetPodMetrics, "/api/pods?namespace=shop"), &response)
+
+			if (response.DataTimestamp != nil) != tt.wantTimestamp {
+				t.Errorf("dataTimestamp = %v, want set %v", response.DataTimestamp, tt.wantTimestamp)
+			}
+			if response.Stale != tt.wantStale {
+				t.Errorf("stale = %v, want %v", response.Stale, tt.wantStale)
+			}
+			if len(response.Pods) != len(tt.wantPodStale) {
+				t.Fatalf("got %d pods, want %d", len(response.Pods), len(tt.wantPodStale))
+			}
+			for i, pod := range response.Pods {
+				if pod.Stale != tt.wantPodStale[i] {
+					t.Errorf("pod %s stale = %v, want %v", pod.Name, pod.Stale, tt.wantPodStale[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetHistoricalAnalysisNDJSON(t *testing.T) {
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{
+		replica("shop", "web-0", []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+		replica("shop", "web-1", []float64{0.2, 0.3}, []float64{120 << 20, 130 << 20}),
+		replica("billing", "api-0", []float64{0.5, 0.5}, []float64{200 << 20, 200 << 20}),
+	}}
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"all namespaces", "/api/pods/analysis?format=ndjson", []string{"shop/web-0", "shop/web-1", "billing/api-0"}},
+		{"one namespace", "/api/pods/analysis?format=ndjson&namespace=shop", []string{"shop/web-0", "shop/web-1"}},
+		{"no containers", "/api/pods/analysis?format=ndjson&namespace=empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTestHandler(client).GetHistoricalAnalysis, tt.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+				t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+			}
+
+			var got []string
+			scanner := bufio.NewScanner(rec.Body)
+			for scanner.Scan() {
+				var metrics models.HistoricalMetrics
+				if err := json.Unmarshal(scanner.Bytes(), &metrics); err != nil {
+					t.Fatalf("failed to decode line %q: %v", scanner.Text(), err)
+				}
+				if len(metrics.CPU.Usage) != 2 {
+					t.Errorf("%s/%s has %d CPU datapoints, want 2", metrics.Namespace, metrics.PodName, len(metrics.CPU.Usage))
+				}
+				got = append(got, metrics.Namespace+"/"+metrics.PodName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("streamed containers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLabelFiltersFromEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"unset", "", k8s.DefaultContainerFilter},
+		{"configured", `container!~"POD|istio-proxy"`, `container!~"POD|istio-proxy"`},
+		{"several matchers", `container!="POD", container!="sidecar"`, `container!="POD", container!="sidecar"`},
+		{"unquoted value", `container!=POD`, k8s.DefaultContainerFilter},
+		{"injection", `container!="POD"} or vector(1) or up{a="b"`, k8s.DefaultContainerFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONTAINER_FILTER", tt.value)
+			if got := getLabelFiltersFromEnv("CONTAINER_FILTER", k8s.DefaultContainerFilter); got != tt.want {
+				t.Errorf("getLabelFiltersFromEnv() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategorizeRecommendation(t *testing.T) {
+	tests := []struct {
+		recommendation string
+		want           string
+	}{
+		{"Consider reducing CPU requests - current efficiency: 12.0%", models.RecommendationReduceCPU},
+		{"Consider increasing CPU requests - current efficiency: 95.0%", models.RecommendationIncreaseCPU},
+		{"Consider reducing memory requests - current efficiency: 20.0%", models.RecommendationReduceMemory},
+		{"Consider increasing memory requests - current efficiency: 90.0%", models.RecommendationIncreaseMemory},
+		{"CPU usage is trending upward - monitor for potential scaling needs", models.RecommendationCPUTrendingUp},
+		{"Memory usage is trending upward - monitor for potential memory leaks or scaling needs", models.RecommendationMemoryTrendingUp},
+		{"Container was recently OOMKilled - consider increasing memory limits", models.RecommendationOOMKilled},
+		{"Resource usage appears well-optimized", models.RecommendationWellOptimized},
+		{"Container restarting frequently - investigate crashes/OOM", models.RecommendationOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := categorizeRecommendation(tt.recommendation); got != tt.want {
+				t.Errorf("categorizeRecommendation(%q) = %s, want %s", tt.recommendation, got, tt.want)
+			}
+		})
+	}
+}
+
+// analyzed returns a container analysis with the given recommendations
+func analyzed(recommendations ...string) models.HistoricalMetrics {
+	return models.HistoricalMetrics{Analysis: models.UsageAnalysis{Recommendations: recommendations}}
+}
+
+func TestGenerateAnalysisSummaryRecommendations(t *testing.T) {
+	const (
+		reduceCPU    = "Consider reducing CPU requests - current efficiency: 10.0%"
+		reduceCPU2   = "Consider reducing CPU requests - current efficiency: 25.0%"
+		reduceMemory = "Consider reducing memory requests - current efficiency: 15.0%"
+		oomKilled    = "Container was recently OOMKilled - consider increasing memory limits"
+		optimized    = "Resource usage appears well-optimized"
+	)
+
+	tests := []struct {
+		name              string
+		metrics           []models.HistoricalMetrics
+		wantBreakdown     map[string]int
+		wantTotal         int
+		wantMostCommon    string
+		wantMostCommonCat string
+	}{
+		{
+			name: "mixed set",
+			metrics: []models.HistoricalMetrics{
+				analyzed(reduceCPU, reduceMemory),
+				analyzed(reduceCPU2, oomKilled),
+				analyzed(reduceCPU),
+				analyzed(optimized),
+			},
+			wantBreakdown: map[string]int{
+				models.RecommendationReduceCPU:     3,
+				models.RecommendationReduceMemory:  1,
+				models.RecommendationOOMKilled:     1,
+				models.RecommendationWellOptimized: 1,
+			},
+			wantTotal:         6,
+			wantMostCommon:    reduceCPU,
+			wantMostCommonCat: models.RecommendationReduceCPU,
+		},
+		{
+			name:              "ties pick the first category alphabetically",
+			metrics:           []models.HistoricalMetrics{analyzed(oomKilled), analyzed(reduceMemory)},
+			wantBreakdown:     map[string]int{models.RecommendationOOMKilled: 1, models.RecommendationReduceMemory: 1},
+			wantTotal:         2,
+			wantMostCommonCat: models.RecommendationOOMKilled,
+		},
+		{
+			name:          "no recommendations",
+			metrics:       []models.HistoricalMetrics{analyzed()},
+			wantBreakdown: map[string]int{},
+		},
+		{
+			name:          "no containers",
+			wantBreakdown: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := defaultValueFormat.generateAnalysisSummary(tt.metrics, []float64{50, 100})
+			if !reflect.DeepEqual(summary.RecommendationBreakdown, tt.wantBreakdown) {
+				t.Errorf("breakdown = %v, want %v", summary.RecommendationBreakdown, tt.wantBreakdown)
+			}
+			if summary.TotalRecommendations != tt.wantTotal {
+				t.Errorf("total recommendations = %d, want %d", summary.TotalRecommendations, tt.wantTotal)
+			}
+			if tt.wantMostCommon != "" && summary.MostCommonRecommendation != tt.wantMostCommon {
+				t.Errorf("most common recommendation = %q, want %q", summary.MostCommonRecommendation, tt.wantMostCommon)
+			}
+			if summary.MostCommonCategory != tt.wantMostCommonCat {
+				t.Errorf("most common category = %q, want %q", summary.MostCommonCategory, tt.wantMostCommonCat)
+			}
+		})
+	}
+}
+
+// sized returns a container analysis with constant requests and the given efficiencies
+func sized(cpuRequest, memoryRequest, cpuEfficiency, memoryEfficiency float64) models.HistoricalMetrics {
+	metrics := models.HistoricalMetrics{Analysis: models.UsageAnalysis{CPUEfficiency: cpuEfficiency, MemoryEfficiency: memoryEfficiency}}
+	metrics.CPU.Requests = []models.DataPoint{{Value: cpuRequest}, {Value: cpuRequest}}
+	metrics.Memory.Requests = []models.DataPoint{{Value: memoryRequest}, {Value: memoryRequest}}
+	return metrics
+}
+
+func TestGenerateAnalysisSummaryWeightedEfficiency(t *testing.T) {
+	tests := []struct {
+		name         string
+		metrics      []models.HistoricalMetrics
+		wantAverage  float64
+		wantCPU      float64
+		wantMemory   float64
+		wantWeighted float64
+	}{
+		{
+			name: "large pod dominates",
+			metrics: []models.HistoricalMetrics{
+				sized(16, 64<<30, 10, 20),
+				sized(0.01, 16<<20, 90, 100),
+			},
+			wantAverage:  55,
+			wantCPU:      10.05,
+			wantMemory:   20.02,
+			wantWeighted: 15.03,
+		},
+		{
+			name: "equal sizes match the simple mean",
+			metrics: []models.HistoricalMetrics{
+				sized(1, 1<<30, 20, 40),
+				sized(1, 1<<30, 60, 80),
+			},
+			wantAverage:  50,
+			wantCPU:      40,
+			wantMemory:   60,
+			wantWeighted: 50,
+		},
+		{
+			name:        "no requests",
+			metrics:     []models.HistoricalMetrics{sized(0, 0, 0, 0)},
+			wantAverage: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := defaultValueFormat.generateAnalysisSummary(tt.metrics, []float64{50, 100})
+			got := []float64{summary.AverageEfficiency, summary.WeightedCPUEfficiency, summary.WeightedMemoryEfficiency, summary.WeightedEfficiency}
+			want := []float64{tt.wantAverage, tt.wantCPU, tt.wantMemory, tt.wantWeighted}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("average, weighted cpu, memory and overall efficiency = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRawQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"selector", `up{job="node"}`, false},
+		{"function", `sum by (namespace) (rate(container_cpu_usage_seconds_total[5m]))`, false},
+		{"brackets in strings", `up{job=")]}"}`, false},
+		{"escaped quote", `up{job="a\"b"}`, false},
+		{"empty", "", true},
+		{"too long", strings.Repeat("a", maxRawQueryLength+1), true},
+		{"unclosed paren", `sum(up`, true},
+		{"unopened bracket", `up]`, true},
+		{"mismatched", `sum(up]`, true},
+		{"unterminated string", `up{job="node}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRawQuery(tt.query); (err != nil) != tt.wantErr {
+				t.Errorf("validateRawQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRawQuery(t *testing.T) {
+	sample := k8s.QuerySample{Metric: map[string]string{"job": "node"}, Value: 1, Timestamp: time.Unix(1700000000, 0).UTC()}
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		query      string
+		err        error
+		wantStatus int
+	}{
+		{"disabled", false, "up", nil, http.StatusForbidden},
+		{"enabled", true, "up", nil, http.StatusOK},
+		{"missing query", true, "", nil, http.StatusBadRequest},
+		{"malformed query", true, "sum(up", nil, http.StatusBadRequest},
+		{"rejected by the backend", true, "up", fmt.Errorf("%w: parse error", k8s.ErrInvalidQuery), http.StatusBadRequest},
+		{"backend failure", true, "up", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&fakeMetricsClient{samples: []k8s.QuerySample{sample}, err: tt.err})
+			h.enableRawQuery = tt.enabled
+
+			rec := serve(h.RawQuery, "/api/query?query="+url.QueryEscape(tt.query))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response models.RawQueryResponse
+			decodeResponse(t, rec, &response)
+			want := []models.RawQuerySample{{Metric: sample.Metric, Value: sample.Value, Timestamp: sample.Timestamp}}
+			if response.Query != tt.query || !reflect.DeepEqual(response.Result, want) {
+				t.Errorf("response = %+v, want query %q with result %+v", response, tt.query, want)
+			}
+		})
+	}
+}
+
+func TestLoadQueryTemplatesMemoryMetric(t *testing.T) {
+	tests := []struct {
+		name         string
+		memoryMetric string
+		override     string
+		want         string
+	}{
+		{"default", "", "", "container_memory_working_set_bytes"},
+		{"working set", "working_set", "", "container_memory_working_set_bytes"},
+		{"rss", "rss", "", "container_memory_rss"},
+		{"usage", "usage", "", "container_memory_usage_bytes"},
+		{"invalid", "cache", "", "container_memory_working_set_bytes"},
+		{"explicit override wins", "rss", "custom_memory_bytes", "custom_memory_bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MEMORY_METRIC", tt.memoryMetric)
+			t.Setenv("METRICS_QUERY_MEMORY_USAGE", tt.override)
+			if got := loadQueryTemplates().Metric(k8s.QueryMemoryUsage); got != tt.want {
+				t.Errorf("memory metric = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMetricsToModelMetricUnitBase(t *testing.T) {
+	metric := k8s.PodMetric{MemoryUsage: 256 << 20, MemoryRequest: 512e6, MemoryLimit: 1 << 30}
+	tests := []struct {
+		base                   units.Base
+		wantUsage, wantRequest string
+		wantLimit              string
+	}{
+		{units.Binary, "256Mi", "488Mi", "1Gi"},
+		{units.Decimal, "268M", "512M", "1074M"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.base), func(t *testing.T) {
+			format := defaultValueFormat
+			format.memoryUnitBase = tt.base
+
+			memory := format.convertMetricsToModelMetric(metric).Memory
+			if memory.Usage != tt.wantUsage || memory.Request != tt.wantRequest || memory.Limit != tt.wantLimit {
+				t.Errorf("memory usage, request, limit = %s, %s, %s, want %s, %s, %s",
+					memory.Usage, memory.Request, memory.Limit, tt.wantUsage, tt.wantRequest, tt.wantLimit)
+			}
+			// Raw values don't depend on the unit base
+			if memory.UsageValue != 256<<20 || memory.RequestValue != 512e6 || memory.LimitValue != 1<<30 {
+				t.Errorf("memory values = %v, %v, %v, want the bytes", memory.UsageValue, memory.RequestValue, memory.LimitValue)
+			}
+		})
+	}
+}
+
+// TestValueFormatPerHandler serves many concurrent requests from handlers with different value
+// formats, which must not leak into each other; run with -race to check nothing is shared
+func TestValueFormatPerHandler(t *testing.T) {
+	client := &fakeMetricsClient{current: []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.1, CPURequest: 0.3, MemoryUsage: 256 << 20, MemoryRequest: 1 << 30, MemoryLimit: 1 << 30},
+	}}
+	binary, decimal := newTestHandler(client), newTestHandler(client)
+	decimal.format = valueFormat{memoryUnitBase: units.Decimal, floatPrecision: 0, memoryPressureThreshold: 20}
+
+	tests := []struct {
+		handler      *Handler
+		wantUsage    string
+		wantPercent  float64
+		wantPressure bool
+	}{
+		{binary, "256Mi", 33.33, false},
+		{decimal, "268M", 33, true},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, tt := range tests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec := serve(tt.handler.GetPodMetrics, "/api/pods?namespace=shop")
+				var pods models.PodMetricsList
+				if err := json.Unmarshal(rec.Body.Bytes(), &pods); err != nil || len(pods.Pods) != 1 {
+					t.Errorf("GetPodMetrics() = %d %s", rec.Code, rec.Body.String())
+					return
+				}
+				pod := pods.Pods[0]
+				if pod.Memory.Usage != tt.wantUsage || pod.CPU.RequestPercentage != tt.wantPercent || pod.MemoryPressure != tt.wantPressure {
+					t.Errorf("memory usage, cpu request percentage, memory pressure = %s, %v, %v, want %s, %v, %v",
+						pod.Memory.Usage, pod.CPU.RequestPercentage, pod.MemoryPressure, tt.wantUsage, tt.wantPercent, tt.wantPressure)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// idleNames returns the names of the idle pods of a response
+func idleNames(response models.IdlePodsResponse) []string {
+	names := []string{}
+	for _, pod := range response.Pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func TestGetIdlePods(t *testing.T) {
+	current := []k8s.PodMetric{
+		{Name: "idle", Namespace: "shop", ContainerName: "app", CPUUsage: 0.002, MemoryUsage: 10 << 20, MemoryRequest: 256 << 20},
+		{Name: "busy", Namespace: "shop", ContainerName: "app", CPUUsage: 0.5, MemoryUsage: 200 << 20, MemoryRequest: 256 << 20},
+		{Name: "cpu-idle-memory-busy", Namespace: "shop", ContainerName: "app", CPUUsage: 0.001, MemoryUsage: 200 << 20, MemoryRequest: 256 << 20},
+		{Name: "no-request", Namespace: "shop", ContainerName: "app", CPUUsage: 0.001, MemoryUsage: 200 << 20},
+	}
+	historical := []k8s.HistoricalMetrics{
+		{PodName: "idle-week", Namespace: "shop", ContainerName: "app"},
+		{PodName: "busy-week", Namespace: "shop", ContainerName: "app"},
+	}
+	historical[0].CPU.Average, historical[0].Memory.Average = 0.001, 5<<20
+	historical[0].Memory.Requests = []k8s.DataPoint{{Value: 512 << 20}, {Value: 256 << 20}}
+	historical[1].CPU.Average, historical[1].Memory.Average = 0.2, 5<<20
+	historical[1].Memory.Requests = []k8s.DataPoint{{Value: 256 << 20}}
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"current snapshot", "/api/pods/idle", []string{"idle", "no-request"}},
+		{"higher memory threshold", "/api/pods/idle?maxMemoryRequestPercent=90", []string{"idle", "cpu-idle-memory-busy", "no-request"}},
+		{"lower cpu threshold", "/api/pods/idle?maxCpuMillicores=1.5", []string{"no-request"}},
+		{"historical average", "/api/pods/idle?historical=true", []string{"idle-week"}},
+		{"other namespace", "/api/pods/idle?namespace=billing", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeMetricsClient{current: current, historical: historical}
+			var response models.IdlePodsResponse
+			decodeResponse(t, serve(newTestHandler(client).GetIdlePods, tt.target), &response)
+			if got := idleNames(response); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("idle pods = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid threshold", func(t *testing.T) {
+		rec := serve(newTestHandler(&fakeMetricsClient{}).GetIdlePods, "/api/pods/idle?maxCpuMillicores=-1")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestConvertMetricsToModelMetricPresence(t *testing.T) {
+	tests := []struct {
+		name           string
+		metric         k8s.PodMetric
+		wantCPU        [2]bool // has request, has limit
+		wantMemory     [2]bool
+		wantCPURequest float64
+		wantCPULimit   float64
+	}{
+		{
+			name:           "requests and limits",
+			metric:         k8s.PodMetric{CPUUsage: 0.25, CPURequest: 0.5, CPULimit: 1, MemoryUsage: 64 << 20, MemoryRequest: 128 << 20, MemoryLimit: 256 << 20},
+			wantCPU:        [2]bool{true, true},
+			wantMemory:     [2]bool{true, true},
+			wantCPURequest: 50,
+			wantCPULimit:   25,
+		},
+		{
+			name:           "requests without limits",
+			metric:         k8s.PodMetric{CPUUsage: 0.25, CPURequest: 0.5, MemoryUsage: 64 << 20, MemoryRequest: 128 << 20},
+			wantCPU:        [2]bool{true, false},
+			wantMemory:     [2]bool{true, false},
+			wantCPURequest: 50,
+		},
+		{
+			name:    "neither",
+			metric:  k8s.PodMetric{CPUUsage: 0.25, MemoryUsage: 64 << 20},
+			wantCPU: [2]bool{false, false},
+		},
+		{
+			name:       "memory limit only",
+			metric:     k8s.PodMetric{MemoryLimit: 256 << 20},
+			wantMemory: [2]bool{false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := defaultValueFormat.convertMetricsToModelMetric(tt.metric)
+			if got := [2]bool{pod.CPU.HasRequest, pod.CPU.HasLimit}; got != tt.wantCPU {
+				t.Errorf("cpu has request, has limit = %v, want %v", got, tt.wantCPU)
+			}
+			if got := [2]bool{pod.Memory.HasRequest, pod.Memory.HasLimit}; got != tt.wantMemory {
+				t.Errorf("memory has request, has limit = %v, want %v", got, tt.wantMemory)
+			}
+			if pod.CPU.RequestPercentage != tt.wantCPURequest || pod.CPU.LimitPercentage != tt.wantCPULimit {
+				t.Errorf("cpu request, limit percentage = %v, %v, want %v, %v", pod.CPU.RequestPercentage, pod.CPU.LimitPercentage, tt.wantCPURequest, tt.wantCPULimit)
+			}
+
+			// Unset and zero values are both serialized, alongside the presence flags
+			encoded, err := json.Marshal(pod.CPU)
+			if err != nil {
+				t.Fatalf("failed to encode resource metrics: %v", err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(encoded, &fields); err != nil {
+				t.Fatalf("failed to decode resource metrics: %v", err)
+			}
+			for _, field := range []string{"requestValue", "limitValue", "requestPercentage", "limitPercentage", "hasRequest", "hasLimit"} {
+				if _, ok := fields[field]; !ok {
+					t.Errorf("%s missing from %s", field, encoded)
+				}
+			}
+		})
+	}
+}
+
+func TestEfficiencyHistogram(t *testing.T) {
+	buckets := []float64{20, 40, 60, 80, 100}
+	efficiencies := [][2]float64{ // cpu, memory
+		{0, 19.99},
+		{20, 20},
+		{39.9, 100},
+		{80, 150},
+		{100, 45},
+	}
+	var metrics []models.HistoricalMetrics
+	for _, e := range efficiencies {
+		metrics = append(metrics, models.HistoricalMetrics{Analysis: models.UsageAnalysis{CPUEfficiency: e[0], MemoryEfficiency: e[1]}})
+	}
+
+	histogram := defaultValueFormat.generateAnalysisSummary(metrics, buckets).EfficiencyHistogram
+	want := []struct {
+		label       string
+		cpu, memory int
+	}{
+		{"0-20", 1, 1},
+		{"20-40", 2, 1},
+		{"40-60", 0, 1},
+		{"60-80", 0, 0},
+		{"80-100", 1, 0},
+		{"100+", 1, 2},
+	}
+	if len(histogram) != len(want) {
+		t.Fatalf("got %d buckets, want %d: %+v", len(histogram), len(want), histogram)
+	}
+	for i, tt := range want {
+		t.Run(tt.label, func(t *testing.T) {
+			bucket := histogram[i]
+			if bucket.Label != tt.label || bucket.CPU != tt.cpu || bucket.Memory != tt.memory {
+				t.Errorf("bucket = %s cpu=%d memory=%d, want %s cpu=%d memory=%d", bucket.Label, bucket.CPU, bucket.Memory, tt.label, tt.cpu, tt.memory)
+			}
+		})
+	}
+	if last := histogram[len(histogram)-1]; last.Max != nil || last.Min != 100 {
+		t.Errorf("last bucket = [%v, %v), want open-ended from 100", last.Min, last.Max)
+	}
+}
+
+func TestGetEnvBucketsWithDefault(t *testing.T) {
+	defaultBuckets := []float64{20, 40, 60, 80, 100}
+	tests := []struct {
+		name  string
+		value string
+		want  []float64
+	}{
+		{"unset", "", defaultBuckets},
+		{"custom", "10, 50,90", []float64{10, 50, 90}},
+		{"fractional", "0.5,1.5", []float64{0.5, 1.5}},
+		{"not ascending", "50,10", defaultBuckets},
+		{"duplicate", "10,10", defaultBuckets},
+		{"zero", "0,10", defaultBuckets},
+		{"not a number", "10,abc", defaultBuckets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EFFICIENCY_HISTOGRAM_BUCKETS", tt.value)
+			if got := getEnvBucketsWithDefault("EFFICIENCY_HISTOGRAM_BUCKETS", defaultBuckets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getEnvBucketsWithDefault() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHistoricalAnalysisTimings(t *testing.T) {
+	// Every query returns an empty vector after a short delay, so each recorded phase takes time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+	client, err := k8s.NewVictoriaMetricsClient(k8s.MetricsClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewVictoriaMetricsClient() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		wantTimings bool
+	}{
+		{"default", "/api/pods/analysis", false},
+		{"debug disabled", "/api/pods/analysis?debug=false", false},
+		{"debug", "/api/pods/analysis?debug=true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response models.HistoricalAnalysisList
+			decodeResponse(t, serve(newTestHandler(client).GetHistoricalAnalysis, tt.target), &response)
+			if !tt.wantTimings {
+				if response.Timings != nil {
+					t.Errorf("timings = %v, want none", response.Timings)
+				}
+				return
+			}
+			for _, phase := range []string{k8s.TimingActivePods, "summary"} {
+				timing, ok := response.Timings[phase]
+				if !ok {
+					t.Errorf("timings %v are missing %s", response.Timings, phase)
+					continue
+				}
+				if timing.TotalMs <= 0 || timing.Count != 1 {
+					t.Errorf("%s timing = %+v, want a positive duration of one call", phase, timing)
+				}
+			}
+		})
+	}
+}
+
+func TestNewHandlerWasteThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		low, high string
+	}{
+		{"low above high", "90", "50"},
+		{"equal", "50", "50"},
+		{"low above the default high", "85", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WASTE_LOW_THRESHOLD", tt.low)
+			t.Setenv("WASTE_HIGH_THRESHOLD", tt.high)
+			if _, err := NewHandler(); err == nil || !strings.Contains(err.Error(), "WASTE_LOW_THRESHOLD") {
+				t.Errorf("NewHandler() error = %v, want the invalid thresholds reported", err)
+			}
+		})
+	}
+}
+
+func TestParseEvalTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"default", "", now, false},
+		{"RFC3339", "2026-03-01T10:30:00Z", time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC), false},
+		{"relative", "-2h", now.Add(-2 * time.Hour), false},
+		{"now", "0s", now, false},
+		{"future timestamp", "2026-03-01T12:00:01Z", time.Time{}, true},
+		{"future offset", "1h", time.Time{}, true},
+		{"invalid", "yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEvalTime(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEvalTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseEvalTime(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodMetricsEvalTime(t *testing.T) {
+	past := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		query      string
+		want       func(time.Time) bool
+		wantStatus int
+	}{
+		{"current", "", func(at time.Time) bool { return time.Since(at) < time.Minute }, http.StatusOK},
+		{"timestamp", "?at=2026-03-01T10:30:00Z", past.Equal, http.StatusOK},
+		{"relative", "?at=-2h", func(at time.Time) bool { return (time.Since(at) - 2*time.Hour).Abs() < time.Minute }, http.StatusOK},
+		{"future", "?at=1h", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded time.Time
+			client := &fakeMetricsClient{currentHook: func(ctx context.Context, namespace, selector string, at time.Time) {
+				forwarded = at
+			}}
+			rec := serve(newTestHandler(client).GetPodMetrics, "/api/pods"+tt.query)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.want == nil {
+				if client.currentCalls.Load() != 0 {
+					t.Error("the backend was queried for an invalid eval time")
+				}
+				return
+			}
+			if !tt.want(forwarded) {
+				t.Errorf("eval time forwarded to the backend = %s", forwarded)
+			}
+		})
+	}
+}
+
+func TestWorkloadName(t *testing.T) {
+	tests := []struct {
+		pod  string
+		want string
+	}{
+		{"web-7d4b9c8f6d-x2k4p", "web"},
+		{"api-server-5f6b8c9d7-m9n8q", "api-server"},
+		{"redis-0", "redis"},
+		{"kafka-broker-12", "kafka-broker"},
+		{"node-exporter-b4k2x", "node-exporter"},
+		{"standalone", "standalone"},
+		{"worker-about", "worker-about"}, // vowels are never generated
+	}
+	for _, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			if got := workloadName(tt.pod); got != tt.want {
+				t.Errorf("workloadName(%s) = %s, want %s", tt.pod, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodMetricsGroupByWorkload(t *testing.T) {
+	replicaMetric := func(pod string, cpu, memory float64) k8s.PodMetric {
+		return k8s.PodMetric{Name: pod, Namespace: "shop", ContainerName: "app", CPUUsage: cpu, CPURequest: 0.5, CPULimit: 1, MemoryUsage: memory, MemoryRequest: 256 << 20, MemoryLimit: 512 << 20}
+	}
+	client := &fakeMetricsClient{current: []k8s.PodMetric{
+		replicaMetric("web-7d4b9c8f6d-x2k4p", 0.1, 100<<20),
+		replicaMetric("web-7d4b9c8f6d-m9n8q", 0.2, 110<<20),
+		replicaMetric("web-7d4b9c8f6d-z5t6w", 0.3, 120<<20),
+		replicaMetric("db-0", 0.4, 200<<20),
+	}}
+
+	var response models.WorkloadMetricsList
+	decodeResponse(t, serve(newTestHandler(client).GetPodMetrics, "/api/pods?groupBy=workload"), &response)
+	if len(response.Workloads) != 2 {
+		t.Fatalf("got %d workloads, want 2: %+v", len(response.Workloads), response.Workloads)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"name", response.Workloads[0].Name, "web"},
+		{"replicas", response.Workloads[0].Replicas, 3},
+		{"summed cpu usage", defaultValueFormat.roundValue(response.Workloads[0].CPU.UsageValue), 0.6},
+		{"summed cpu request", response.Workloads[0].CPU.RequestValue, 1.5},
+		{"summed memory limit", response.Workloads[0].Memory.LimitValue, float64(1536 << 20)},
+		{"average cpu usage", defaultValueFormat.roundValue(response.Workloads[0].AverageCPUUsage), 0.2},
+		{"average memory usage", response.Workloads[0].AverageMemoryUsage, float64(110 << 20)},
+		{"single replica", response.Workloads[1].Replicas, 1},
+		{"single replica name", response.Workloads[1].Name, "db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+
+	if rec := serve(newTestHandler(client).GetPodMetrics, "/api/pods?groupBy=node"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported groupBy status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestValidSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{`app="nginx"`, true},
+		{`app="nginx",tier="frontend"`, true},
+		{`app=~"web|api", tier!="batch"`, true},
+		{`app=nginx`, false},
+		{`app="nginx"}) or vector(1`, false},
+		{`app="nginx"} or up{job="x"`, false},
+		{`__name__="up"`, false},
+		{`app="nginx", __name__=~".+"`, false},
+		{`app="` + strings.Repeat("a", maxRawQueryLength) + `"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			if got := validSelector(tt.selector); got != tt.want {
+				t.Errorf("validSelector(%s) = %v, want %v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodMetricsSelector(t *testing.T) {
+	tests := []struct {
+		name       string
+		selector   string
+		wantStatus int
+	}{
+		{"no selector", "", http.StatusOK},
+		{"label matchers", `app="nginx",tier="frontend"`, http.StatusOK},
+		{"injection", `app="nginx"} or vector(1) or up{a="b"`, http.StatusBadRequest},
+		{"metric name", `__name__="up"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded string
+			client := &fakeMetricsClient{currentHook: func(ctx context.Context, namespace, selector string, at time.Time) {
+				forwarded = selector
+			}}
+			rec := serve(newTestHandler(client).GetPodMetrics, "/api/pods?selector="+url.QueryEscape(tt.selector))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if client.currentCalls.Load() != 0 {
+					t.Error("the backend was queried with a rejected selector")
+				}
+				return
+			}
+			if forwarded != tt.selector {
+				t.Errorf("selector forwarded to the backend = %q, want %q", forwarded, tt.selector)
+			}
+		})
+	}
+}
+
+func TestGetPodDetail(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		current     []k8s.PodMetric
+		status      k8s.PodStatus
+		wantStatus  int
+		wantPhase   string
+		wantReasons map[string]string
+	}{
+		{
+			name:   "phase and termination reasons",
+			target: "/api/pods/shop/web-0",
+			current: []k8s.PodMetric{
+				{Name: "web-0", Namespace: "shop", ContainerName: "app"},
+				{Name: "web-0", Namespace: "shop", ContainerName: "sidecar"},
+			},
+			status:      k8s.PodStatus{Phase: "Running", LastTerminationReasons: map[string]string{"app": "OOMKilled"}},
+			wantStatus:  http.StatusOK,
+			wantPhase:   "Running",
+			wantReasons: map[string]string{"app": "OOMKilled", "sidecar": ""},
+		},
+		{
+			name:        "pending pod without metrics",
+			target:      "/api/pods/shop/web-1",
+			status:      k8s.PodStatus{Phase: "Pending"},
+			wantStatus:  http.StatusOK,
+			wantPhase:   "Pending",
+			wantReasons: map[string]string{},
+		},
+		{
+			name:       "unknown pod",
+			target:     "/api/pods/shop/missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid pod name",
+			target:     "/api/pods/shop/Web_0",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/pods/{namespace}/{pod}", newTestHandler(&fakeMetricsClient{current: tt.current, status: tt.status}).GetPodDetail)
+			rec := serve(mux.ServeHTTP, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var detail models.PodDetail
+			decodeResponse(t, rec, &detail)
+			if detail.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", detail.Phase, tt.wantPhase)
+			}
+			reasons := map[string]string{}
+			for _, container := range detail.Containers {
+				reasons[container.ContainerName] = container.LastTerminationReason
+			}
+			if !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("termination reasons = %v, want %v", reasons, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestBackendWarnings(t *testing.T) {
+	const sampleLimit = "query hit sample limit"
+	tests := []struct {
+		name   string
+		client string
+		extra  string // Extra response fields
+		want   []string
+	}{
+		{"prometheus without warnings", "prometheus", "", nil},
+		{"prometheus warnings", "prometheus", `,"warnings":["` + sampleLimit + `"]`, []string{sampleLimit}},
+		{"victoriametrics without warnings", "victoriametrics", "", nil},
+		{"victoriametrics warnings", "victoriametrics", `,"warnings":["` + sampleLimit + `"]`, []string{sampleLimit}},
+		{"victoriametrics partial response", "victoriametrics", `,"isPartial":true`,
+			[]string{"VictoriaMetrics returned a partial response - some storage nodes were unavailable"}},
+	}
+	for _, tt := range tests {
+		// Every query returns an empty result with the same warnings, which are reported once
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}` + tt.extra + `}`))
+		}))
+		defer server.Close()
+		config := k8s.MetricsClientConfig{URL: server.URL}
+		var client k8s.MetricsClient
+		var err error
+		if tt.client == "prometheus" {
+			client, err = k8s.NewPrometheusClient(config)
+		} else {
+			client, err = k8s.NewVictoriaMetricsClient(config)
+		}
+		if err != nil {
+			t.Fatalf("new %s client error = %v", tt.client, err)
+		}
+
+		t.Run(tt.name+"/pods", func(t *testing.T) {
+			var response models.PodMetricsList
+			decodeResponse(t, serve(newTestHandler(client).GetPodMetrics, "/api/pods"), &response)
+			if !reflect.DeepEqual(response.Warnings, tt.want) {
+				t.Errorf("warnings = %q, want %q", response.Warnings, tt.want)
+			}
+		})
+		t.Run(tt.name+"/analysis", func(t *testing.T) {
+			var response models.HistoricalAnalysisList
+			decodeResponse(t, serve(newTestHandler(client).GetHistoricalAnalysis, "/api/pods/analysis"), &response)
+			if !reflect.DeepEqual(response.Warnings, tt.want) {
+				t.Errorf("warnings = %q, want %q", response.Warnings, tt.want)
+			}
+		})
+	}
+}
+
+// point returns the datapoint of the i-th five minute step
+func point(i int, value float64) models.DataPoint {
+	return models.DataPoint{Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * 5 * time.Minute), Value: value}
+}
+
+// pointSeries returns datapoints with the given values five minutes apart
+func pointSeries(values ...float64) []models.DataPoint {
+	points := make([]models.DataPoint, len(values))
+	for i, value := range values {
+		points[i] = point(i, value)
+	}
+	return points
+}
+
+func TestDownsamplePoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		points    []models.DataPoint
+		maxPoints int
+		want      []models.DataPoint
+	}{
+		{"fewer points", pointSeries(1, 2, 3), 5, pointSeries(1, 2, 3)},
+		{"exact", pointSeries(1, 2, 3), 3, pointSeries(1, 2, 3)},
+		{"even buckets", pointSeries(1, 3, 5, 7), 2, []models.DataPoint{point(0, 2), point(2, 6)}},
+		{"uneven buckets", pointSeries(1, 2, 3, 4, 5), 2, []models.DataPoint{point(0, 1.5), point(2, 4)}},
+		{"single bucket", pointSeries(1, 2, 3, 6), 1, pointSeries(3)},
+		{"empty", nil, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := downsamplePoints(tt.points, tt.maxPoints); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("downsamplePoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHistoricalAnalysisMaxPoints(t *testing.T) {
+	// A week of 5 minute samples with a single spike
+	values := make([]float64, 2016)
+	for i := range values {
+		values[i] = 0.1
+	}
+	values[1000] = 2
+	hm := k8s.HistoricalMetrics{PodName: "web-0", Namespace: "shop", ContainerName: "app"}
+	hm.CPU = k8s.HistoricalResourceData{Average: 0.1, Peak: 2, P95: 0.1}
+	for _, usage := range pointSeries(values...) {
+		hm.CPU.Usage = append(hm.CPU.Usage, k8s.DataPoint{Timestamp: usage.Timestamp, Value: usage.Value})
+		hm.CPU.Requests = append(hm.CPU.Requests, k8s.DataPoint{Timestamp: usage.Timestamp, Value: 0.5})
+	}
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{hm}}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantPoints int
+	}{
+		{"full resolution", "/api/pods/analysis", 2016},
+		{"downsampled", "/api/pods/analysis?maxPoints=100", 100},
+		{"more than available", "/api/pods/analysis?maxPoints=5000", 2016},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response models.HistoricalAnalysisList
+			decodeResponse(t, serve(newTestHandler(client).GetHistoricalAnalysis, tt.target), &response)
+			if len(response.HistoricalMetrics) != 1 {
+				t.Fatalf("containers = %d, want 1", len(response.HistoricalMetrics))
+			}
+			cpu := response.HistoricalMetrics[0].CPU
+			if len(cpu.Usage) != tt.wantPoints || len(cpu.Requests) != tt.wantPoints {
+				t.Errorf("usage, requests = %d, %d points, want %d", len(cpu.Usage), len(cpu.Requests), tt.wantPoints)
+			}
+			// Statistics come from the full-resolution series
+			if cpu.Peak != 2 || cpu.Average != 0.1 {
+				t.Errorf("peak, average = %v, %v, want 2, 0.1", cpu.Peak, cpu.Average)
+			}
+		})
+	}
+
+	for _, maxPoints := range []string{"0", "-1", "many"} {
+		if rec := serve(newTestHandler(client).GetHistoricalAnalysis, "/api/pods/analysis?maxPoints="+maxPoints); rec.Code != http.StatusBadRequest {
+			t.Errorf("maxPoints=%s status = %d, want %d", maxPoints, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetHistoricalAnalysisFilter(t *testing.T) {
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{
+		analyzedContainer("shop", "over", k8s.ResourceWasteAnalysis{CPUOverProvisioned: true, CPUWastePercentage: 80}, 20),
+		analyzedContainer("shop", "under", k8s.ResourceWasteAnalysis{MemoryUnderProvisioned: true}, 50),
+		analyzedContainer("shop", "some-waste", k8s.ResourceWasteAnalysis{MemoryWastePercentage: 40}, 50),
+		analyzedContainer("shop", "optimized", k8s.ResourceWasteAnalysis{}, 50),
+	}}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		want     []string
+	}{
+		{"unfiltered", "/api/pods/analysis", http.StatusOK, []string{"over", "under", "some-waste", "optimized"}},
+		{"problematic", "/api/pods/analysis?only=problematic", http.StatusOK, []string{"over", "under"}},
+		{"minimum waste", "/api/pods/analysis?minWaste=30", http.StatusOK, []string{"over", "some-waste"}},
+		{"high minimum waste", "/api/pods/analysis?minWaste=90", http.StatusOK, []string{}},
+		{"problematic or wasteful", "/api/pods/analysis?only=problematic&minWaste=30", http.StatusOK, []string{"over", "under", "some-waste"}},
+		{"invalid only", "/api/pods/analysis?only=wasteful", http.StatusBadRequest, nil},
+		{"negative minimum waste", "/api/pods/analysis?minWaste=-1", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTestHandler(client).GetHistoricalAnalysis, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var response models.HistoricalAnalysisList
+			decodeResponse(t, rec, &response)
+			got := []string{}
+			for _, hm := range response.HistoricalMetrics {
+				got = append(got, hm.PodName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pods = %v, want %v", got, tt.want)
+			}
+			// The summary covers every container, whatever was returned
+			if response.TotalCount != 4 || response.ReturnedCount != len(tt.want) {
+				t.Errorf("total, returned = %d, %d, want 4, %d", response.TotalCount, response.ReturnedCount, len(tt.want))
+			}
+			summary := response.Summary
+			if summary.TotalPodsAnalyzed != 4 || summary.OverProvisionedPods != 1 || summary.UnderProvisionedPods != 1 || summary.WellOptimizedPods != 2 {
+				t.Errorf("summary = %+v, want all 4 containers", summary)
+			}
+		})
+	}
+}
+
+func TestGetHistoricalAnalysisNonFinite(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float64
+	}{
+		{"NaN", math.NaN()},
+		{"+Inf", math.Inf(1)},
+		{"-Inf", math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hm := analyzedContainer("shop", "web-0", k8s.ResourceWasteAnalysis{CPUWastePercentage: tt.value}, tt.value)
+			hm.CPU = k8s.HistoricalResourceData{
+				Usage:   []k8s.DataPoint{{Timestamp: time.Now(), Value: tt.value}},
+				Average: tt.value, Peak: tt.value, P95: tt.value, P99: tt.value,
+			}
+			client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{hm}}
+
+			rec := serve(newTestHandler(client).GetHistoricalAnalysis, "/api/pods/analysis")
+			if !json.Valid(rec.Body.Bytes()) {
+				t.Fatalf("response is not valid JSON: %s", rec.Body)
+			}
+			var response models.HistoricalAnalysisList
+			decodeResponse(t, rec, &response)
+			if len(response.HistoricalMetrics) != 1 {
+				t.Fatalf("got %d containers, want 1", len(response.HistoricalMetrics))
+			}
+			got := response.HistoricalMetrics[0]
+			for name, value := range map[string]float64{
+				"cpu usage":      got.CPU.Usage[0].Value,
+				"cpu average":    got.CPU.Average,
+				"cpu peak":       got.CPU.Peak,
+				"cpu p95":        got.CPU.P95,
+				"cpu efficiency": got.Analysis.CPUEfficiency,
+				"cpu waste":      got.Analysis.ResourceWaste.CPUWastePercentage,
+			} {
+				if value != 0 {
+					t.Errorf("%s = %v, want the %s value cleaned to 0", name, value, tt.name)
+				}
+			}
+			// The cleaned CPU efficiency averages with the 50% memory efficiency
+			if response.Summary.AverageEfficiency != 25 {
+				t.Errorf("average efficiency = %v, want 25", response.Summary.AverageEfficiency)
+			}
+		})
+	}
+}
+
+func TestEmptyListResponses(t *testing.T) {
+	// The fake only has data in shop, so a billing filter matches nothing
+	client := &fakeMetricsClient{
+		current:    []k8s.PodMetric{{Name: "web-0", Namespace: "shop", ContainerName: "app"}},
+		historical: []k8s.HistoricalMetrics{{PodName: "web-0", Namespace: "shop", ContainerName: "app"}},
+		active:     []k8s.PodInfo{{Name: "web-0", Namespace: "shop", Containers: []string{"app"}}, {Name: "new-0", Namespace: "billing"}},
+	}
+	h := newTestHandler(client)
+
+	tests := []struct {
+		name        string
+		client      *fakeMetricsClient
+		handler     func(h *Handler) http.HandlerFunc
+		target      string
+		wantArrays  []string // fields that must be empty arrays
+		wantObjects []string // fields that must be present objects
+	}{
+		{"pods", client, func(h *Handler) http.HandlerFunc { return h.GetPodMetrics }, "/api/pods?namespace=billing", []string{"pods"}, nil},
+		{"analysis", client, func(h *Handler) http.HandlerFunc { return h.GetHistoricalAnalysis }, "/api/pods/analysis?namespace=billing", []string{"historicalMetrics"}, []string{"summary"}},
+		{"trends without data", client, func(h *Handler) http.HandlerFunc { return h.GetPodTrends }, "/api/pods/trends?namespace=billing&pod=new-0", []string{"containers"}, []string{"summary"}},
+		{"namespaces", &fakeMetricsClient{}, func(h *Handler) http.HandlerFunc { return h.GetNamespaces }, "/api/namespaces", []string{"namespaces"}, nil},
+		{"idle pods", client, func(h *Handler) http.HandlerFunc { return h.GetIdlePods }, "/api/pods/idle?namespace=billing", []string{"pods"}, nil},
+		{"recommendations", client, func(h *Handler) http.HandlerFunc { return h.GetPodRecommendations }, "/api/pods/recommendations?namespace=billing", []string{"recommendations"}, nil},
+		{"owners", client, func(h *Handler) http.HandlerFunc { return h.GetOwnerSummary }, "/api/owners/summary?namespace=billing", []string{"owners"}, nil},
+		{"nodes", &fakeMetricsClient{}, func(h *Handler) http.HandlerFunc { return h.GetNodes }, "/api/nodes", []string{"nodes"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := h
+			if tt.client != client {
+				handler = newTestHandler(tt.client)
+			}
+			var response map[string]json.RawMessage
+			decodeResponse(t, serve(tt.handler(handler), tt.target), &response)
+			for _, field := range tt.wantArrays {
+				if got := string(response[field]); got != "[]" {
+					t.Errorf("%s = %s, want []", field, got)
+				}
+			}
+			for _, field := range tt.wantObjects {
+				if got := response[field]; len(got) == 0 || got[0] != '{' {
+					t.Errorf("%s = %s, want a present summary object", field, got)
+				}
+			}
+		})
+	}
+
+	// The summary of no pods is zero-valued but present
+	var summary map[string]json.RawMessage
+	decodeResponse(t, serve(h.GetPodSummary, "/api/pods/summary?namespace=billing"), &summary)
+	if got := string(summary["totalPods"]); got != "0" {
+		t.Errorf("summary totalPods = %s, want 0", got)
+	}
+}
+
+func TestRoundValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision int
+		value     float64
+		want      float64
+	}{
+		{"default precision", defaultFloatPrecision, 100.0 / 3, 33.33},
+		{"rounded up", 2, 200.0 / 3, 66.67},
+		{"one decimal", 1, 100.0 / 3, 33.3},
+		{"whole numbers", 0, 200.0 / 3, 67},
+		{"full precision", -1, 100.0 / 3, 100.0 / 3},
+		{"negative value", 2, -100.0 / 3, -33.33},
+		{"NaN", 2, math.NaN(), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := defaultValueFormat
+			format.floatPrecision = tt.precision
+			if got := format.roundValue(tt.value); got != tt.want {
+				t.Errorf("roundValue(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertHistoricalMetricsPrecision(t *testing.T) {
+	hm := k8s.HistoricalMetrics{PodName: "web-0", Namespace: "shop", ContainerName: "app"}
+	hm.CPU = k8s.HistoricalResourceData{
+		Usage:            []k8s.DataPoint{{Value: 0.123456789}},
+		Average:          0.123456789,
+		DataCompleteness: 100.0 / 3,
+	}
+	hm.Analysis = k8s.UsageAnalysis{
+		CPUEfficiency:    100.0 / 3,
+		MemoryEfficiency: 200.0 / 3,
+		ResourceWaste:    k8s.ResourceWasteAnalysis{CPUWastePercentage: 200.0 / 3},
+	}
+
+	got := defaultValueFormat.convertHistoricalMetrics(hm)
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"cpu efficiency", got.Analysis.CPUEfficiency, 33.33},
+		{"memory efficiency", got.Analysis.MemoryEfficiency, 66.67},
+		{"cpu waste", got.Analysis.ResourceWaste.CPUWastePercentage, 66.67},
+		{"data completeness", got.CPU.DataCompleteness, 33.33},
+		// Raw datapoints and absolute usage keep full precision
+		{"datapoint", got.CPU.Usage[0].Value, 0.123456789},
+		{"average usage", got.CPU.Average, 0.123456789},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestGetPodMetricsLastSeen(t *testing.T) {
+	seen := time.Now().Add(-30 * time.Second).Truncate(time.Second)
+	client := &fakeMetricsClient{current: []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", SampleTime: seen},
+		{Name: "web-1", Namespace: "shop", ContainerName: "app"},
+	}}
+	var response models.PodMetricsList
+	decodeResponse(t, serve(newTestHandler(client).GetPodMetrics, "/api/pods?namespace=shop"), &response)
+
+	want := map[string]*time.Time{"web-0": &seen, "web-1": nil}
+	for _, pod := range response.Pods {
+		got, wantSeen := pod.LastSeen, want[pod.Name]
+		if (got == nil) != (wantSeen == nil) || (got != nil && !got.Equal(*wantSeen)) {
+			t.Errorf("%s lastSeen = %v, want %v", pod.Name, got, wantSeen)
+		}
+	}
+}
+
+func TestMemoryPressure(t *testing.T) {
+	const limit = 1000 << 20
+	tests := []struct {
+		name      string
+		usage     float64
+		limit     float64
+		threshold float64
+		want      bool
+	}{
+		{"85% of the limit", 0.85 * limit, limit, defaultMemoryPressureThreshold, false},
+		{"92% of the limit", 0.92 * limit, limit, defaultMemoryPressureThreshold, true},
+		{"no limit", 0.92 * limit, 0, defaultMemoryPressureThreshold, false},
+		{"85% under a lower threshold", 0.85 * limit, limit, 80, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Current usage
+			client := &fakeMetricsClient{current: []k8s.PodMetric{
+				{Name: "web-0", Namespace: "shop", ContainerName: "app", MemoryUsage: tt.usage, MemoryRequest: limit / 2, MemoryLimit: tt.limit},
+			}}
+			handler := newTestHandler(client)
+			handler.format.memoryPressureThreshold = tt.threshold
+			var pods models.PodMetricsList
+			decodeResponse(t, serve(handler.GetPodMetrics, "/api/pods?namespace=shop"), &pods)
+			if len(pods.Pods) != 1 || pods.Pods[0].MemoryPressure != tt.want {
+				t.Errorf("pods = %+v, want memory pressure %v", pods.Pods, tt.want)
+			}
+
+			wantCount := 0
+			if tt.want {
+				wantCount = 1
+			}
+			var summary models.PodSummaryResponse
+			decodeResponse(t, serve(handler.GetPodSummary, "/api/pods/summary?namespace=shop"), &summary)
+			if summary.MemoryPressurePods != wantCount {
+				t.Errorf("summary memory pressure pods = %d, want %d", summary.MemoryPressurePods, wantCount)
+			}
+
+			// Historical P95 usage
+			hm := k8s.HistoricalMetrics{PodName: "web-0", Namespace: "shop", ContainerName: "app"}
+			hm.Memory = k8s.HistoricalResourceData{P95: tt.usage}
+			if tt.limit > 0 {
+				hm.Memory.Limits = []k8s.DataPoint{{Value: tt.limit}, {Value: tt.limit}}
+			}
+			if got := handler.format.convertHistoricalMetrics(hm).MemoryPressure; got != tt.want {
+				t.Errorf("historical memory pressure = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodTrendsNoContainers(t *testing.T) {
+	client := &fakeMetricsClient{
+		historical: []k8s.HistoricalMetrics{
+			replica("shop", "web-0", []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+			{PodName: "starting-0", Namespace: "shop", ContainerName: "app"},
+		},
+		active: []k8s.PodInfo{
+			{Name: "web-0", Namespace: "shop", Containers: []string{"app"}},
+			{Name: "starting-0", Namespace: "shop", Containers: []string{"app"}},
+			{Name: "unscraped-0", Namespace: "shop"},
+		},
+	}
+	tests := []struct {
+		name           string
+		pod            string
+		wantCode       int
+		wantContainers int
+		wantNote       string
+	}{
+		{"pod with datapoints", "web-0", http.StatusOK, 1, ""},
+		{"containers without datapoints", "starting-0", http.StatusOK, 1, insufficientTrendDataNote},
+		{"active pod without containers in history", "unscraped-0", http.StatusOK, 0, insufficientTrendDataNote},
+		{"unknown pod", "missing-0", http.StatusNotFound, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTestHandler(client).GetPodTrends, "/api/pods/trends?namespace=shop&pod="+tt.pod)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var response models.PodTrendAnalysis
+			decodeResponse(t, rec, &response)
+			if response.PodName != tt.pod || response.Containers == nil || len(response.Containers) != tt.wantContainers {
+				t.Errorf("response = %s with containers %v, want %s with %d", response.PodName, response.Containers, tt.pod, tt.wantContainers)
+			}
+			if response.Note != tt.wantNote {
+				t.Errorf("note = %q, want %q", response.Note, tt.wantNote)
+			}
+		})
+	}
+}
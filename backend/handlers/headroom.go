@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// headroomRefreshTimeout bounds the background historical analysis that feeds headroom
+const headroomRefreshTimeout = 5 * time.Minute
+
+// p95Usage is a container's 7-day P95 CPU (cores) and memory (bytes) usage
+type p95Usage struct {
+	cpu    float64
+	memory float64
+}
+
+// headroomSnapshot is the P95 usage of every container in one cluster
+type headroomSnapshot struct {
+	usage       map[string]p95Usage // namespace/pod/container -> P95 usage
+	refreshedAt time.Time
+	refreshing  bool // A background refresh is in flight
+}
+
+// headroomCache lazily loads historical P95 usage per cluster in the background so current
+// metrics can report headroom without waiting for the historical analysis
+type headroomCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	snapshots *lruCache[headroomSnapshot]
+}
+
+// newHeadroomCache creates an empty cache holding up to maxEntries snapshots, refreshed after ttl
+func newHeadroomCache(ttl time.Duration, maxEntries int) *headroomCache {
+	return &headroomCache{
+		ttl:       ttl,
+		snapshots: newLRUCache[headroomSnapshot](maxEntries),
+	}
+}
+
+// lookup returns the cluster's latest P95 usage, which is nil until the first refresh finished.
+// A background refresh is started when the snapshot is missing or older than the TTL.
+// Safe to call on a nil cache.
+func (c *headroomCache) lookup(cluster string, metricsClient k8s.MetricsClient, now time.Time) map[string]p95Usage {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot, ok := c.snapshots.get(cluster)
+	if (!ok || now.Sub(snapshot.refreshedAt) > c.ttl) && !snapshot.refreshing {
+		snapshot.refreshing = true
+		c.snapshots.put(cluster, snapshot)
+		go c.refresh(cluster, metricsClient)
+	}
+	return snapshot.usage
+}
+
+// headroomFor returns the P95 usage for the pods served by r. Snapshots are kept per cluster or
+// backend and refreshed without the namespaces granted to the caller, so authenticated requests
+// share them; the usage is filtered to those namespaces afterwards.
+func (h *Handler) headroomFor(r *http.Request, now time.Time) map[string]p95Usage {
+	if h.headroom == nil {
+		return nil
+	}
+	metricsClient := h.clusters[h.clusterParam(r)]
+	if name := r.URL.Query().Get("backend"); name != "" {
+		metricsClient = h.backends[name]
+	}
+	usage := h.headroom.lookup(h.clientKey(r), metricsClient, now)
+
+	scope, ok := authScopeFrom(r)
+	if !ok || usage == nil {
+		return usage
+	}
+	scoped := make(map[string]p95Usage, len(usage))
+	for key, p95 := range usage {
+		if scope.namespaces.allowsPodKey(key) {
+			scoped[key] = p95
+		}
+	}
+	return scoped
+}
+
+// refresh loads the P95 usage of all containers from the historical analysis
+func (c *headroomCache) refresh(cluster string, metricsClient k8s.MetricsClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), headroomRefreshTimeout)
+	defer cancel()
+
+	historicalData, err := metricsClient.GetHistoricalMetrics(ctx, "", k8s.HistoricalOptions{})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		log.Printf("Error refreshing headroom data from %s: %v", metricsClient.GetClientType(), err)
+		// Keep the previous snapshot, unless it was evicted meanwhile
+		if snapshot, ok := c.snapshots.get(cluster); ok {
+			snapshot.refreshing = false
+			c.snapshots.put(cluster, snapshot)
+		}
+		return
+	}
+
+	usage := make(map[string]p95Usage, len(historicalData))
+	for _, hm := range historicalData {
+		usage[hm.Namespace+"/"+hm.PodName+"/"+hm.ContainerName] = p95Usage{cpu: hm.CPU.P95, memory: hm.Memory.P95}
+	}
+	c.snapshots.put(cluster, headroomSnapshot{usage: usage, refreshedAt: time.Now()})
+}
+
+// headroomPercent returns the share of the limit left above the P95 usage, or nil without
+// a limit or historical data
+func (f valueFormat) headroomPercent(p95, limit float64, hasP95 bool) *float64 {
+	if !hasP95 || limit <= 0 {
+		return nil
+	}
+	headroom := f.roundValue((limit - p95) / limit * 100)
+	return &headroom
+}
+
+// addHeadroom sets the CPU and memory headroom of a pod from its P95 usage
+func (f valueFormat) addHeadroom(pod *models.PodMetrics, metric k8s.PodMetric, usage map[string]p95Usage) {
+	p95, ok := usage[metric.Namespace+"/"+metric.Name+"/"+metric.ContainerName]
+	pod.CPU.HeadroomPercent = f.headroomPercent(p95.cpu, metric.CPULimit, ok)
+	pod.Memory.HeadroomPercent = f.headroomPercent(p95.memory, metric.MemoryLimit, ok)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestHeadroomPercent(t *testing.T) {
+	tests := []struct {
+		name   string
+		p95    float64
+		limit  float64
+		hasP95 bool
+		want   *float64
+	}{
+		{"quarter of the limit used", 0.25, 1, true, ptr(75.0)},
+		{"P95 at the limit", 512 << 20, 512 << 20, true, ptr(0.0)},
+		{"P95 above the limit", 1.5, 1, true, ptr(-50.0)},
+		{"no limit", 0.25, 0, true, nil},
+		{"no historical data", 0, 1, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := defaultValueFormat.headroomPercent(tt.p95, tt.limit, tt.hasP95)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("headroomPercent() = %v, want %v", deref(got), deref(tt.want))
+			}
+		})
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+// deref formats an optional value for test failures
+func deref(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestGetPodMetricsHeadroom(t *testing.T) {
+	client := &fakeMetricsClient{
+		current: []k8s.PodMetric{
+			{Name: "limited", Namespace: "shop", ContainerName: "app", CPULimit: 2, MemoryLimit: 1 << 30},
+			{Name: "unlimited", Namespace: "shop", ContainerName: "app"},
+			{Name: "new", Namespace: "shop", ContainerName: "app", CPULimit: 1, MemoryLimit: 1 << 30},
+		},
+		historical: []k8s.HistoricalMetrics{
+			{PodName: "limited", Namespace: "shop", ContainerName: "app",
+				CPU: k8s.HistoricalResourceData{P95: 0.5}, Memory: k8s.HistoricalResourceData{P95: 768 << 20}},
+			{PodName: "unlimited", Namespace: "shop", ContainerName: "app",
+				CPU: k8s.HistoricalResourceData{P95: 0.5}, Memory: k8s.HistoricalResourceData{P95: 768 << 20}},
+		},
+	}
+	h := newTestHandler(client)
+	h.headroom = newHeadroomCache(time.Hour, defaultCacheMaxEntries)
+
+	// The first request starts loading the historical data in the background
+	var first models.PodMetricsList
+	decodeResponse(t, serve(h.GetPodMetrics, "/api/pods"), &first)
+	for _, pod := range first.Pods {
+		if pod.CPU.HeadroomPercent != nil || pod.Memory.HeadroomPercent != nil {
+			t.Errorf("%s headroom set before the historical data loaded", pod.Name)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for h.headroom.lookup("default", client, time.Now()) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("historical data was not loaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name                string
+		target              string
+		wantCPU, wantMemory map[string]*float64
+	}{
+		{
+			name:       "live",
+			target:     "/api/pods",
+			wantCPU:    map[string]*float64{"limited": ptr(75), "unlimited": nil, "new": nil},
+			wantMemory: map[string]*float64{"limited": ptr(25), "unlimited": nil, "new": nil},
+		},
+		{
+			name:       "historical eval time",
+			target:     "/api/pods?at=-1h",
+			wantCPU:    map[string]*float64{"limited": nil, "unlimited": nil, "new": nil},
+			wantMemory: map[string]*float64{"limited": nil, "unlimited": nil, "new": nil},
+		},
+		{
+			name:       "merged containers",
+			target:     "/api/pods?perContainer=false",
+			wantCPU:    map[string]*float64{"limited": nil, "unlimited": nil, "new": nil},
+			wantMemory: map[string]*float64{"limited": nil, "unlimited": nil, "new": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response models.PodMetricsList
+			decodeResponse(t, serve(h.GetPodMetrics, tt.target), &response)
+			if len(response.Pods) != len(tt.wantCPU) {
+				t.Fatalf("pods = %d, want %d", len(response.Pods), len(tt.wantCPU))
+			}
+			for _, pod := range response.Pods {
+				if got, want := pod.CPU.HeadroomPercent, tt.wantCPU[pod.Name]; (got == nil) != (want == nil) || (got != nil && *got != *want) {
+					t.Errorf("%s CPU headroom = %v, want %v", pod.Name, deref(got), deref(want))
+				}
+				if got, want := pod.Memory.HeadroomPercent, tt.wantMemory[pod.Name]; (got == nil) != (want == nil) || (got != nil && *got != *want) {
+					t.Errorf("%s memory headroom = %v, want %v", pod.Name, deref(got), deref(want))
+				}
+			}
+		})
+	}
+}
+
+func TestNewHandlerHeadroomOptIn(t *testing.T) {
+	tests := []struct {
+		name       string
+		headroom   string
+		historical string
+		want       bool
+	}{
+		{"unset", "", "", false},
+		{"enabled", "true", "", true},
+		{"historical disabled", "true", "false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METRICS_ENABLE_HEADROOM", tt.headroom)
+			t.Setenv("METRICS_ENABLE_HISTORICAL", tt.historical)
+			h, err := NewHandler()
+			if err != nil {
+				t.Fatalf("NewHandler() error = %v", err)
+			}
+			if got := h.headroom != nil; got != tt.want {
+				t.Errorf("headroom enabled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadroomCacheEviction(t *testing.T) {
+	client := &fakeMetricsClient{
+		historical: []k8s.HistoricalMetrics{
+			{PodName: "web-0", Namespace: "shop", ContainerName: "app",
+				CPU: k8s.HistoricalResourceData{P95: 0.5}, Memory: k8s.HistoricalResourceData{P95: 256 << 20}},
+		},
+	}
+	c := newHeadroomCache(time.Hour, 2)
+	now := time.Now()
+
+	c.refresh("prod", client)
+	c.refresh("staging", client)
+	// Using prod makes staging the least recently used snapshot
+	if c.lookup("prod", client, now) == nil {
+		t.Fatal("prod snapshot missing before reaching capacity")
+	}
+	c.refresh("dev", client)
+
+	for cluster, want := range map[string]bool{"prod": true, "staging": false, "dev": true} {
+		if _, ok := c.snapshots.get(cluster); ok != want {
+			t.Errorf("%s snapshot cached = %v, want %v", cluster, ok, want)
+		}
+	}
+	if got := c.snapshots.order.Len(); got != 2 {
+		t.Errorf("cached snapshots = %d, want 2", got)
+	}
+}
+
+func TestHeadroomForAuthScopes(t *testing.T) {
+	client := &fakeMetricsClient{
+		historical: []k8s.HistoricalMetrics{
+			{PodName: "web-0", Namespace: "shop", ContainerName: "app", CPU: k8s.HistoricalResourceData{P95: 0.5}},
+			{PodName: "api-0", Namespace: "billing", ContainerName: "app", CPU: k8s.HistoricalResourceData{P95: 0.25}},
+		},
+	}
+	h := newTestHandler(client)
+	h.headroom = newHeadroomCache(time.Hour, defaultCacheMaxEntries)
+	h.headroom.refresh("default", client)
+
+	request := func(namespace string) *http.Request {
+		r := httptest.NewRequest("GET", "/api/pods", nil)
+		scope := authScope{namespaces: &namespaceAllowlist{names: map[string]bool{namespace: true}}, key: "auth:" + namespace}
+		return r.WithContext(context.WithValue(r.Context(), authScopeKey{}, scope))
+	}
+	tests := []struct {
+		name string
+		r    *http.Request
+		want []string
+	}{
+		{"unauthenticated", httptest.NewRequest("GET", "/api/pods", nil), []string{"billing/api-0/app", "shop/web-0/app"}},
+		{"shop", request("shop"), []string{"shop/web-0/app"}},
+		{"billing", request("billing"), []string{"billing/api-0/app"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for key := range h.headroomFor(tt.r, time.Now()) {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("headroom keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+	// Every scope is served from the snapshot of the cluster
+	if got := h.headroom.snapshots.order.Len(); got != 1 {
+		t.Errorf("cached snapshots = %d, want 1", got)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+const (
+	// analysisJobTimeout bounds a background analysis, well beyond the synchronous 30s
+	analysisJobTimeout = 10 * time.Minute
+	// defaultAnalysisJobTTL is how long finished jobs are kept for polling
+	defaultAnalysisJobTTL = time.Hour
+	// defaultMaxRunningAnalysisJobs caps the analyses running in the background at once
+	defaultMaxRunningAnalysisJobs = 2
+)
+
+var errTooManyAnalysisJobs = errors.New("too many analysis jobs running")
+
+// analysisJobs keeps background analysis jobs in memory until they expire
+type analysisJobs struct {
+	mu         sync.Mutex
+	jobs       map[string]*models.AnalysisJob
+	ttl        time.Duration // Finished jobs are removed this long after finishing
+	maxRunning int
+	running    int
+}
+
+// newAnalysisJobs creates an empty job store
+func newAnalysisJobs(ttl time.Duration, maxRunning int) *analysisJobs {
+	return &analysisJobs{jobs: make(map[string]*models.AnalysisJob), ttl: ttl, maxRunning: maxRunning}
+}
+
+// start runs analyze in the background as a new job, or fails when maxRunning jobs are already running
+func (j *analysisJobs) start(namespace string, analyze func(context.Context) (models.HistoricalAnalysisList, error)) (models.AnalysisJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return models.AnalysisJob{}, err
+	}
+
+	j.mu.Lock()
+	j.expire(time.Now())
+	if j.running >= j.maxRunning {
+		j.mu.Unlock()
+		return models.AnalysisJob{}, errTooManyAnalysisJobs
+	}
+	job := &models.AnalysisJob{ID: id, Status: models.AnalysisJobRunning, Namespace: namespace, CreatedAt: time.Now()}
+	j.jobs[id] = job
+	j.running++
+	snapshot := *job
+	j.mu.Unlock()
+
+	go func() {
+		// Not tied to the submitting request, which returns immediately
+		ctx, cancel := context.WithTimeout(context.Background(), analysisJobTimeout)
+		defer cancel()
+		result, err := analyze(ctx)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		finished := time.Now()
+		job.FinishedAt = &finished
+		if err != nil {
+			log.Printf("Analysis job %s failed: %v", id, err)
+			job.Status = models.AnalysisJobFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = models.AnalysisJobDone
+			job.Result = &result
+		}
+		j.running--
+	}()
+
+	return snapshot, nil
+}
+
+// get returns a copy of the job, unless it doesn't exist or has expired
+func (j *analysisJobs) get(id string, now time.Time) (models.AnalysisJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.expire(now)
+	job, ok := j.jobs[id]
+	if !ok {
+		return models.AnalysisJob{}, false
+	}
+	return *job, true
+}
+
+// expire removes the jobs that finished more than the TTL ago. The caller must hold j.mu.
+func (j *analysisJobs) expire(now time.Time) {
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > j.ttl {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random job ID
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// StartAnalysisJob starts the historical analysis in the background and returns the job to poll,
+// decoupling analyses of very large clusters from the HTTP timeouts
+func (h *Handler) StartAnalysisJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Historical analysis not available - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Get namespace from query parameter, empty for all namespaces
+	namespace := namespaceParam(r)
+
+	// Optionally downsample the returned series
+	maxPoints, err := parseMaxPoints(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := k8s.HistoricalOptions{IncludeCompleted: r.URL.Query().Get("includeCompleted") == "true"}
+
+	job, err := h.jobs.start(namespaceParam(r), func(ctx context.Context) (models.HistoricalAnalysisList, error) {
+		// Collect backend warnings so consumers know when data may be incomplete
+		ctx, warnings := k8s.WithQueryWarnings(ctx)
+
+		historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, opts)
+		if err != nil {
+			return models.HistoricalAnalysisList{}, fmt.Errorf("failed to get historical metrics from %s: %w", metricsClient.GetClientType(), err)
+		}
+		return h.buildHistoricalAnalysis(historicalData, maxPoints, k8s.DefaultAnalysisWindow, warnings.Warnings()), nil
+	})
+	if errors.Is(err, errTooManyAnalysisJobs) {
+		http.Error(w, fmt.Sprintf("Too many analysis jobs running (max %d), try again later", h.jobs.maxRunning), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/pods/analysis/jobs/"+job.ID)
+
+	// Write response
+	writeJSONLimitedStatus(w, http.StatusAccepted, job, h.maxResponseBytes)
+}
+
+// GetAnalysisJob returns the status of an analysis job, including its result once done
+func (h *Handler) GetAnalysisJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.get(r.PathValue("id"), time.Now())
+	if !ok {
+		http.Error(w, "Analysis job not found or expired", http.StatusNotFound)
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	writeJSONLimited(w, job, h.maxResponseBytes)
+}
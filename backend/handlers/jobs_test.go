@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// pollAnalysisJob polls the job until it is no longer running
+func pollAnalysisJob(t *testing.T, mux *http.ServeMux, id string) models.AnalysisJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job models.AnalysisJob
+		decodeResponse(t, serve(mux.ServeHTTP, "/api/pods/analysis/jobs/"+id), &job)
+		if job.Status != models.AnalysisJobRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAnalysisJobLifecycle(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     *fakeMetricsClient
+		wantStatus string
+		wantPods   int
+	}{
+		{
+			name:       "done",
+			client:     &fakeMetricsClient{historical: []k8s.HistoricalMetrics{{PodName: "web-0", Namespace: "shop", ContainerName: "app"}}},
+			wantStatus: models.AnalysisJobDone,
+			wantPods:   1,
+		},
+		{
+			name:       "failed",
+			client:     &fakeMetricsClient{err: errors.New("backend unavailable")},
+			wantStatus: models.AnalysisJobFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(tt.client)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/pods/analysis/jobs", h.StartAnalysisJob)
+			mux.HandleFunc("GET /api/pods/analysis/jobs/{id}", h.GetAnalysisJob)
+
+			// Submit
+			rec := httptest.NewRecorder()
+			ParseRequestParams(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/pods/analysis/jobs?namespace=shop", nil))
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("submit status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+			}
+			var submitted models.AnalysisJob
+			if err := json.Unmarshal(rec.Body.Bytes(), &submitted); err != nil {
+				t.Fatalf("decoding submitted job: %v", err)
+			}
+			if submitted.ID == "" || submitted.Status != models.AnalysisJobRunning || submitted.Namespace != "shop" {
+				t.Errorf("submitted job = %+v, want a running shop job with an ID", submitted)
+			}
+			if location := rec.Header().Get("Location"); location != "/api/pods/analysis/jobs/"+submitted.ID {
+				t.Errorf("Location = %q, want the job URL", location)
+			}
+
+			// Poll until the result is ready
+			job := pollAnalysisJob(t, mux, submitted.ID)
+			if job.Status != tt.wantStatus || job.FinishedAt == nil {
+				t.Fatalf("job = %+v, want %s and finished", job, tt.wantStatus)
+			}
+			switch tt.wantStatus {
+			case models.AnalysisJobDone:
+				if job.Result == nil || len(job.Result.HistoricalMetrics) != tt.wantPods {
+					t.Errorf("result = %+v, want %d containers", job.Result, tt.wantPods)
+				}
+			case models.AnalysisJobFailed:
+				if job.Error == "" || job.Result != nil {
+					t.Errorf("failed job error = %q, result = %+v, want an error and no result", job.Error, job.Result)
+				}
+			}
+		})
+	}
+
+	h := newTestHandler(&fakeMetricsClient{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/pods/analysis/jobs", h.StartAnalysisJob)
+	mux.HandleFunc("GET /api/pods/analysis/jobs/{id}", h.GetAnalysisJob)
+	if rec := serve(mux.ServeHTTP, "/api/pods/analysis/jobs/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := serve(mux.ServeHTTP, "/api/pods/analysis/jobs"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET submit status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAnalysisJobsRunningCap(t *testing.T) {
+	jobs := newAnalysisJobs(time.Hour, 1)
+	release := make(chan struct{})
+	blocked := func(ctx context.Context) (models.HistoricalAnalysisList, error) {
+		<-release
+		return models.HistoricalAnalysisList{}, nil
+	}
+
+	first, err := jobs.start("shop", blocked)
+	if err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	if _, err := jobs.start("shop", blocked); !errors.Is(err, errTooManyAnalysisJobs) {
+		t.Errorf("second start() error = %v, want %v", err, errTooManyAnalysisJobs)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ := jobs.get(first.ID, time.Now())
+		if job.Status == models.AnalysisJobDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first job still running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := jobs.start("shop", blocked); err != nil {
+		t.Errorf("start() after the first job finished error = %v", err)
+	}
+}
+
+func TestAnalysisJobsExpiry(t *testing.T) {
+	const ttl = time.Minute
+	jobs := newAnalysisJobs(ttl, 1)
+	job, err := jobs.start("shop", func(ctx context.Context) (models.HistoricalAnalysisList, error) {
+		return models.HistoricalAnalysisList{}, nil
+	})
+	if err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+
+	var finished time.Time
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, ok := jobs.get(job.ID, time.Now())
+		if ok && got.FinishedAt != nil {
+			finished = *got.FinishedAt
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never finished")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		found bool
+	}{
+		{"just finished", finished, true},
+		{"at the TTL", finished.Add(ttl), true},
+		{"past the TTL", finished.Add(ttl + time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := jobs.get(job.ID, tt.at); ok != tt.found {
+				t.Errorf("get() found = %v, want %v", ok, tt.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// labelListerFor resolves the request's cluster and checks its backend can list labels
+func (h *Handler) labelListerFor(w http.ResponseWriter, r *http.Request) (k8s.LabelLister, k8s.MetricsClient, bool) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return nil, nil, false
+	}
+
+	// Label values aren't scoped to the allowed namespaces, so lookups must stay within a namespace
+	if h.namespacesRestricted() && namespaceParam(r) == "" {
+		http.Error(w, "A namespace parameter is required while NAMESPACE_ALLOWLIST or ENABLE_AUTH is set", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	lister, ok := unscoped(metricsClient).(k8s.LabelLister)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Label lookups are not supported by the %s backend", metricsClient.GetClientType()), http.StatusNotImplemented)
+		return nil, nil, false
+	}
+	return lister, metricsClient, true
+}
+
+// GetLabels returns the label names of pod metrics for autocomplete
+func (h *Handler) GetLabels(w http.ResponseWriter, r *http.Request) {
+	lister, metricsClient, ok := h.labelListerFor(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	labels, err := lister.GetLabelNames(ctx, namespaceParam(r))
+	if err != nil {
+		log.Printf("Error getting label names from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(labels)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := models.LabelList{Labels: append([]string{}, labels...)}
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// GetLabelValues returns the values of a pod metrics label for autocomplete
+func (h *Handler) GetLabelValues(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !labelNamePattern.MatchString(name) {
+		http.Error(w, "invalid label name", http.StatusBadRequest)
+		return
+	}
+
+	lister, metricsClient, ok := h.labelListerFor(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	values, err := lister.GetLabelValues(ctx, name, namespaceParam(r))
+	if err != nil {
+		log.Printf("Error getting label values from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Strings(values)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := models.LabelValueList{Label: name, Values: append([]string{}, values...)}
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// newLabelsServer serves the label names and pod label values of a Prometheus-compatible API,
+// restricting pods to the namespace in the match[] selector
+func newLabelsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		match := r.Form.Get("match[]")
+		if !strings.HasPrefix(match, "container_cpu_usage_seconds_total{") {
+			t.Errorf("match[] = %q, want container usage series", match)
+		}
+
+		var data string
+		switch r.URL.Path {
+		case "/api/v1/labels":
+			data = `["pod","namespace","container","app"]`
+		case "/api/v1/label/pod/values":
+			data = `["web-1","api-0","web-0"]`
+			if strings.Contains(match, `namespace="shop"`) {
+				data = `["web-1","web-0"]`
+			}
+		default:
+			data = `[]`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":` + data + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLabelEndpoints(t *testing.T) {
+	server := newLabelsServer(t)
+	config := k8s.MetricsClientConfig{URL: server.URL}
+	prometheus, err := k8s.NewPrometheusClient(config)
+	if err != nil {
+		t.Fatalf("NewPrometheusClient() error = %v", err)
+	}
+	victoriaMetrics, err := k8s.NewVictoriaMetricsClient(config)
+	if err != nil {
+		t.Fatalf("NewVictoriaMetricsClient() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantCode   int
+		wantLabels []string
+		wantValues []string
+	}{
+		{"label names", "/api/labels", http.StatusOK, []string{"app", "container", "namespace", "pod"}, nil},
+		{"label values", "/api/labels/pod/values", http.StatusOK, nil, []string{"api-0", "web-0", "web-1"}},
+		{"label values in a namespace", "/api/labels/pod/values?namespace=shop", http.StatusOK, nil, []string{"web-0", "web-1"}},
+		{"unknown label", "/api/labels/team/values", http.StatusOK, nil, []string{}},
+		{"invalid label name", "/api/labels/pod-name/values", http.StatusBadRequest, nil, nil},
+	}
+	for client, metricsClient := range map[string]k8s.MetricsClient{"prometheus": prometheus, "victoriametrics": victoriaMetrics} {
+		h := newTestHandler(metricsClient)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/labels", h.GetLabels)
+		mux.HandleFunc("/api/labels/{name}/values", h.GetLabelValues)
+
+		for _, tt := range tests {
+			t.Run(client+"/"+tt.name, func(t *testing.T) {
+				rec := serve(mux.ServeHTTP, tt.target)
+				if rec.Code != tt.wantCode {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+				}
+				if tt.wantCode != http.StatusOK {
+					return
+				}
+				if tt.wantLabels != nil {
+					var response models.LabelList
+					decodeResponse(t, rec, &response)
+					if !reflect.DeepEqual(response.Labels, tt.wantLabels) {
+						t.Errorf("labels = %v, want %v", response.Labels, tt.wantLabels)
+					}
+					return
+				}
+				var response models.LabelValueList
+				decodeResponse(t, rec, &response)
+				if !reflect.DeepEqual(response.Values, tt.wantValues) {
+					t.Errorf("values = %v, want %v", response.Values, tt.wantValues)
+				}
+			})
+		}
+	}
+}
+
+func TestLabelEndpointsUnsupported(t *testing.T) {
+	h := newTestHandler(&fakeMetricsClient{clientType: "metrics-server"})
+	if rec := serve(h.GetLabels, "/api/labels"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
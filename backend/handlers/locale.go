@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// numberFormat is how a locale writes decimal numbers and percentages
+type numberFormat struct {
+	locale        string
+	decimal       string
+	group         string
+	percentSuffix string
+}
+
+// numberFormats are the supported locales by language, English being the default. Only the
+// language of an Accept-Language tag is used, e.g. de-AT is formatted as de.
+var numberFormats = map[string]numberFormat{
+	"en": {locale: "en", decimal: ".", group: ",", percentSuffix: "%"},
+	"ja": {locale: "ja", decimal: ".", group: ",", percentSuffix: "%"},
+	"zh": {locale: "zh", decimal: ".", group: ",", percentSuffix: "%"},
+	"de": {locale: "de", decimal: ",", group: ".", percentSuffix: " %"},
+	"es": {locale: "es", decimal: ",", group: ".", percentSuffix: " %"},
+	"it": {locale: "it", decimal: ",", group: ".", percentSuffix: "%"},
+	"nl": {locale: "nl", decimal: ",", group: ".", percentSuffix: "%"},
+	"pt": {locale: "pt", decimal: ",", group: ".", percentSuffix: "%"},
+	"fr": {locale: "fr", decimal: ",", group: " ", percentSuffix: " %"},
+	"pl": {locale: "pl", decimal: ",", group: " ", percentSuffix: "%"},
+	"ru": {locale: "ru", decimal: ",", group: " ", percentSuffix: " %"},
+	"sv": {locale: "sv", decimal: ",", group: " ", percentSuffix: " %"},
+}
+
+// negotiateNumberFormat returns the number format of the preferred supported language of the
+// Accept-Language header, and false without the header or a supported language
+func negotiateNumberFormat(r *http.Request) (numberFormat, bool) {
+	acceptLanguage := r.Header.Get("Accept-Language")
+	if acceptLanguage == "" {
+		return numberFormat{}, false
+	}
+	for _, tag := range acceptedRanges(acceptLanguage) {
+		if tag == "*" {
+			return numberFormats["en"], true
+		}
+		language, _, _ := strings.Cut(tag, "-")
+		if format, ok := numberFormats[language]; ok {
+			return format, true
+		}
+	}
+	return numberFormat{}, false
+}
+
+// formatNumber formats value, already rounded, with trailing zeros trimmed and grouped thousands
+func (f numberFormat) formatNumber(value float64) string {
+	integer, fraction, _ := strings.Cut(strconv.FormatFloat(math.Abs(value), 'f', -1, 64), ".")
+
+	var b strings.Builder
+	if value < 0 {
+		b.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(f.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// formatPercent formats a percentage with the locale's percent sign
+func (f numberFormat) formatPercent(value float64) string {
+	return f.formatNumber(value) + f.percentSuffix
+}
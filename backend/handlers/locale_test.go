@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestNegotiateNumberFormat(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string // Locale, empty when none is negotiated
+	}{
+		{"no header", "", ""},
+		{"supported language", "de", "de"},
+		{"region of a supported language", "de-AT", "de"},
+		{"preferred supported language by quality", "fr;q=0.5, sv;q=0.9", "sv"},
+		{"unsupported languages skipped", "tlh, fr-CA;q=0.8", "fr"},
+		{"wildcard", "tlh, *;q=0.1", "en"},
+		{"only unsupported languages", "tlh, eo", ""},
+		{"excluded language", "de;q=0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			format, ok := negotiateNumberFormat(r)
+			if ok != (tt.want != "") || format.locale != tt.want {
+				t.Errorf("negotiateNumberFormat(%q) = %q, %v, want %q", tt.acceptLanguage, format.locale, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	// Spaces in numbers are non-breaking, narrow ones in French
+	tests := []struct {
+		locale      string
+		value       float64
+		wantNumber  string
+		wantPercent string
+	}{
+		{"en", 1234567.25, "1,234,567.25", "1,234,567.25%"},
+		{"en", 0.5, "0.5", "0.5%"},
+		{"en", 100, "100", "100%"},
+		{"de", 1234.5, "1.234,5", "1.234,5\u00a0%"},
+		{"fr", 1234.5, "1\u202f234,5", "1\u202f234,5\u202f%"},
+		{"it", -1234.5, "-1.234,5", "-1.234,5%"},
+		{"ja", 999, "999", "999%"},
+	}
+	for _, tt := range tests {
+		format := numberFormats[tt.locale]
+		if got := format.formatNumber(tt.value); got != tt.wantNumber {
+			t.Errorf("%s formatNumber(%v) = %q, want %q", tt.locale, tt.value, got, tt.wantNumber)
+		}
+		if got := format.formatPercent(tt.value); got != tt.wantPercent {
+			t.Errorf("%s formatPercent(%v) = %q, want %q", tt.locale, tt.value, got, tt.wantPercent)
+		}
+	}
+}
+
+func TestGetPodSummaryLocale(t *testing.T) {
+	// CPU usage averages 1234.5% of the requests and memory usage 62.5%
+	h := newTestHandler(&fakeMetricsClient{current: []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 1.2345, CPURequest: 0.1, MemoryUsage: 100 << 20, MemoryRequest: 200 << 20},
+		{Name: "web-1", Namespace: "shop", ContainerName: "app", CPUUsage: 2.469, CPURequest: 0.2, MemoryUsage: 300 << 20, MemoryRequest: 400 << 20},
+	}})
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		wantLocale     string
+		wantCPU        string
+		wantMemory     string
+	}{
+		{"no header", "", "", "", ""},
+		{"English", "en-US", "en", "1,234.5%", "62.5%"},
+		{"German", "de-DE, en;q=0.8", "de", "1.234,5\u00a0%", "62,5\u00a0%"},
+		{"French", "fr", "fr", "1\u202f234,5\u202f%", "62,5\u202f%"},
+		{"unsupported language", "tlh", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/pods/summary", nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			ParseRequestParams(http.HandlerFunc(h.GetPodSummary)).ServeHTTP(rec, r)
+
+			var response models.PodSummaryResponse
+			decodeResponse(t, rec, &response)
+
+			// The raw figures and their units don't depend on the language
+			if response.TotalPods != 2 || response.AverageCPUUsage != 1234.5 || response.AverageMemoryUsage != 62.5 {
+				t.Errorf("summary = %d pods, %v%% CPU, %v%% memory, want 2 pods, 1234.5%% CPU, 62.5%% memory",
+					response.TotalPods, response.AverageCPUUsage, response.AverageMemoryUsage)
+			}
+			if response.AverageCPUUsageUnit != models.SummaryUnitPercentOfRequest || response.AverageMemoryUsageUnit != models.SummaryUnitPercentOfRequest {
+				t.Errorf("units = %q, %q, want %q", response.AverageCPUUsageUnit, response.AverageMemoryUsageUnit, models.SummaryUnitPercentOfRequest)
+			}
+
+			if got := rec.Header().Get("Content-Language"); got != tt.wantLocale {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLocale)
+			}
+			if tt.wantLocale == "" {
+				if response.Formatted != nil {
+					t.Errorf("formatted = %+v, want none", response.Formatted)
+				}
+				return
+			}
+			want := models.PodSummaryFormatted{Locale: tt.wantLocale, TotalPods: "2", AverageCPUUsage: tt.wantCPU, AverageMemoryUsage: tt.wantMemory}
+			if response.Formatted == nil || *response.Formatted != want {
+				t.Errorf("formatted = %+v, want %+v", response.Formatted, want)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("Vary = %q, want Accept-Language", got)
+			}
+		})
+	}
+}
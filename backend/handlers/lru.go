@@ -0,0 +1,77 @@
+package handlers
+
+import "container/list"
+
+// defaultCacheMaxEntries bounds the entries of each cache keyed by request parameters
+const defaultCacheMaxEntries = 1000
+
+// lruCache is a map bounded to capacity entries, evicting the least recently used entry when full.
+// It is not safe for concurrent use; callers guard it with their own mutex.
+type lruCache[V any] struct {
+	capacity int
+	order    *list.List // Most recently used first
+	items    map[string]*list.Element
+}
+
+// lruEntry is the key and value of an lruCache element
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// newLRUCache creates an empty cache of capacity entries, unbounded when capacity is not positive
+func newLRUCache[V any](capacity int) *lruCache[V] {
+	return &lruCache[V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the value of key, marking it as the most recently used
+func (c *lruCache[V]) get(key string) (V, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry[V]).value, true
+}
+
+// put sets the value of key as the most recently used, evicting the least recently used entry when full
+func (c *lruCache[V]) put(key string, value V) {
+	if element, ok := c.items[key]; ok {
+		element.Value.(*lruEntry[V]).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// remove deletes key
+func (c *lruCache[V]) remove(key string) {
+	if element, ok := c.items[key]; ok {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+// removeIf deletes the entries for which drop returns true
+func (c *lruCache[V]) removeIf(drop func(key string, value V) bool) {
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*lruEntry[V])
+		if drop(entry.key, entry.value) {
+			c.order.Remove(element)
+			delete(c.items, entry.key)
+		}
+		element = next
+	}
+}
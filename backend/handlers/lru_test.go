@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lruKeys returns the keys of c from the most to the least recently used
+func lruKeys[V any](c *lruCache[V]) []string {
+	var keys []string
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(*lruEntry[V]).key)
+	}
+	return keys
+}
+
+func TestLRUCacheEvictsAtCapacity(t *testing.T) {
+	c := newLRUCache[int](2)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.put("c", 3)
+
+	if _, ok := c.get("a"); ok {
+		t.Error("get(a) found the least recently used entry past capacity")
+	}
+	for key, want := range map[string]int{"b": 2, "c": 3} {
+		if got, ok := c.get(key); !ok || got != want {
+			t.Errorf("get(%s) = %d, %v, want %d, true", key, got, ok, want)
+		}
+	}
+	if len(c.items) != 2 || c.order.Len() != 2 {
+		t.Errorf("entries = %d in the map and %d in the order, want 2", len(c.items), c.order.Len())
+	}
+}
+
+func TestLRUCacheRecency(t *testing.T) {
+	tests := []struct {
+		name  string
+		touch func(c *lruCache[int])
+		want  []string
+	}{
+		{"get", func(c *lruCache[int]) { c.get("a") }, []string{"d", "a", "c"}},
+		{"put", func(c *lruCache[int]) { c.put("a", 10) }, []string{"d", "a", "c"}},
+		{"missing get", func(c *lruCache[int]) { c.get("x") }, []string{"d", "c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache[int](3)
+			c.put("a", 1)
+			c.put("b", 2)
+			c.put("c", 3)
+
+			// The used entry survives the next insertion, the least recently used one is evicted
+			tt.touch(c)
+			c.put("d", 4)
+
+			if got := lruKeys(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLRUCacheOverwrite(t *testing.T) {
+	c := newLRUCache[string](2)
+	c.put("a", "old")
+	c.put("b", "other")
+	c.put("a", "new")
+
+	if got, ok := c.get("a"); !ok || got != "new" {
+		t.Errorf("get(a) = %q, %v, want %q, true", got, ok, "new")
+	}
+	// Overwriting doesn't add an entry, so nothing is evicted
+	if got := lruKeys(c); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("keys = %v, want [a b]", got)
+	}
+}
+
+func TestLRUCacheUnbounded(t *testing.T) {
+	c := newLRUCache[int](0)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		c.put(key, i)
+	}
+	if got := c.order.Len(); got != 4 {
+		t.Errorf("entries = %d, want 4 without a capacity", got)
+	}
+}
+
+func TestLRUCacheRemove(t *testing.T) {
+	c := newLRUCache[int](0)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		c.put(key, i)
+	}
+	c.remove("a")
+	c.remove("x")
+	c.removeIf(func(key string, value int) bool { return value%2 == 1 })
+
+	if got := lruKeys(c); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("keys = %v, want [c]", got)
+	}
+	if _, ok := c.items["a"]; ok {
+		t.Error("removed key a is still in the map")
+	}
+}
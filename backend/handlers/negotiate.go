@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Response formats selectable with the format parameter or the Accept header
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// formatMediaTypes maps the media types accepted in an Accept header to their response format
+var formatMediaTypes = map[string]string{
+	"application/json":     formatJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+}
+
+// negotiateFormat chooses the response format among the supported ones, the first being the default.
+// The format parameter takes precedence over the Accept header. When neither allows a supported
+// format it responds with 406 and returns false.
+func negotiateFormat(w http.ResponseWriter, r *http.Request, supported ...string) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if containsFormat(supported, format) {
+			return format, true
+		}
+		http.Error(w, fmt.Sprintf("unsupported format parameter: %s (expected %s)", format, strings.Join(supported, " or ")), http.StatusNotAcceptable)
+		return "", false
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return supported[0], true
+	}
+	for _, mediaType := range acceptedRanges(accept) {
+		switch {
+		case mediaType == "*/*":
+			return supported[0], true
+		case strings.HasSuffix(mediaType, "/*"):
+			// Pick the first supported format of the wildcard's type, e.g. text/* for CSV
+			for _, format := range supported {
+				if strings.HasPrefix(formatMediaType(format), strings.TrimSuffix(mediaType, "*")) {
+					return format, true
+				}
+			}
+		case containsFormat(supported, formatMediaTypes[mediaType]):
+			return formatMediaTypes[mediaType], true
+		}
+	}
+
+	http.Error(w, fmt.Sprintf("unsupported Accept header: %s (expected %s)", accept, strings.Join(supported, " or ")), http.StatusNotAcceptable)
+	return "", false
+}
+
+// NegotiateJSON wraps an endpoint only serving JSON, so that requests for another format through the
+// format parameter or the Accept header get 406 like on endpoints serving several formats
+func NegotiateJSON(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := negotiateFormat(w, r, formatJSON); !ok {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// acceptedRanges returns the media ranges of an Accept header, or the language ranges of an
+// Accept-Language header, by descending quality, in header order for equal qualities and without
+// the ones with quality 0
+func acceptedRanges(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					mr.quality = q
+				}
+			}
+		}
+		if mr.mediaType != "" && mr.quality > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	mediaTypes := make([]string, len(ranges))
+	for i, mr := range ranges {
+		mediaTypes[i] = mr.mediaType
+	}
+	return mediaTypes
+}
+
+// formatMediaType returns the Content-Type of a response format
+func formatMediaType(format string) string {
+	switch format {
+	case formatCSV:
+		return "text/csv"
+	case formatNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+func containsFormat(formats []string, format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
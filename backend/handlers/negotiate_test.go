@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	supported := []string{formatJSON, formatCSV}
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   string // "" for 406
+	}{
+		{"no parameter or header", "/", "", formatJSON},
+		{"Accept JSON", "/", "application/json", formatJSON},
+		{"Accept CSV", "/", "text/csv", formatCSV},
+		{"Accept any", "/", "*/*", formatJSON},
+		{"Accept text wildcard", "/", "text/*", formatCSV},
+		{"first supported media type", "/", "text/html, text/csv, application/json", formatCSV},
+		{"higher quality wins over header order", "/", "application/json;q=0.5, text/csv", formatCSV},
+		{"equal quality keeps header order", "/", "application/json;q=0.8, text/csv;q=0.8", formatJSON},
+		{"zero quality excluded", "/", "text/csv;q=0, */*;q=0.1", formatJSON},
+		{"media type case", "/", "Text/CSV", formatCSV},
+		{"format parameter", "/?format=csv", "", formatCSV},
+		{"format parameter overrides Accept", "/?format=csv", "application/json", formatCSV},
+		{"format parameter overrides an unsupported Accept", "/?format=json", "text/html", formatJSON},
+		{"unsupported format parameter", "/?format=xml", "", ""},
+		{"format parameter of another endpoint", "/?format=ndjson", "application/x-ndjson", ""},
+		{"unsupported Accept", "/", "text/html", ""},
+		{"only excluded media types", "/", "text/csv;q=0, application/json;q=0", ""},
+		{"wildcard of another type", "/", "image/*", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			got, ok := negotiateFormat(rec, r, supported...)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Fatalf("negotiateFormat() = %q, %v, want %q", got, ok, tt.want)
+			}
+			if !ok && rec.Code != http.StatusNotAcceptable {
+				t.Errorf("status = %d, want 406", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAcceptedRanges(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"application/json", []string{"application/json"}},
+		{"text/csv;q=0.2, application/json, */*;q=0.1", []string{"application/json", "text/csv", "*/*"}},
+		{"de-AT, en;q=0.8, fr;q=0", []string{"de-at", "en"}},
+		{"text/csv;q=invalid", []string{"text/csv"}},
+	}
+	for _, tt := range tests {
+		if got := acceptedRanges(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("acceptedRanges(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateJSON(t *testing.T) {
+	handler := NegotiateJSON(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	})
+
+	tests := []struct {
+		name     string
+		target   string
+		accept   string
+		wantCode int
+	}{
+		{"default", "/api/pods", "", http.StatusOK},
+		{"browser Accept", "/api/pods", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", http.StatusOK},
+		{"format json", "/api/pods?format=json", "", http.StatusOK},
+		{"format csv", "/api/pods?format=csv", "", http.StatusNotAcceptable},
+		{"Accept NDJSON", "/api/pods", "application/x-ndjson", http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
+
+func TestEndpointFormatNegotiation(t *testing.T) {
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{
+		replica("shop", "web-0", []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+	}}
+	h := newTestHandler(client)
+
+	tests := []struct {
+		name            string
+		handler         http.HandlerFunc
+		target          string
+		accept          string
+		wantCode        int
+		wantContentType string
+	}{
+		{"analysis default", h.GetHistoricalAnalysis, "/api/pods/analysis", "", http.StatusOK, "application/json"},
+		{"analysis Accept NDJSON", h.GetHistoricalAnalysis, "/api/pods/analysis", "application/x-ndjson", http.StatusOK, "application/x-ndjson"},
+		{"analysis format over Accept", h.GetHistoricalAnalysis, "/api/pods/analysis?format=json", "application/x-ndjson", http.StatusOK, "application/json"},
+		{"analysis Accept CSV", h.GetHistoricalAnalysis, "/api/pods/analysis", "text/csv", http.StatusNotAcceptable, ""},
+		{"recommendations Accept CSV", h.GetPodRecommendations, "/api/pods/recommendations", "text/csv, application/json;q=0.5", http.StatusOK, "text/csv"},
+		{"recommendations format NDJSON", h.GetPodRecommendations, "/api/pods/recommendations?format=ndjson", "", http.StatusNotAcceptable, ""},
+		{"JSON-only endpoint Accept JSON", NegotiateJSON(h.GetPodSummary), "/api/pods/summary", "application/json", http.StatusOK, "application/json"},
+		{"JSON-only endpoint format CSV", NegotiateJSON(h.GetPodSummary), "/api/pods/summary?format=csv", "", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			ParseRequestParams(tt.handler).ServeHTTP(rec, r)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if contentType := rec.Header().Get("Content-Type"); tt.wantContentType != "" && !strings.HasPrefix(contentType, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %s", contentType, tt.wantContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// GetNodes lists nodes with their pod count and the requests, limits and usage of their pods
+// compared to the node's allocatable capacity
+func (h *Handler) GetNodes(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	nodes, err := metricsClient.GetNodeAllocatable(ctx)
+	if err != nil {
+		log.Printf("Error getting node allocatable from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	podNodes, err := metricsClient.GetPodNodes(ctx, "", now)
+	if err != nil {
+		log.Printf("Error getting pod nodes from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, "", "", now)
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := models.NodeList{
+		Nodes:       h.format.summarizeNodes(nodes, podNodes, metricsData),
+		GeneratedAt: now,
+	}
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// summarizeNodes sums the container metrics of each node's pods, sorted by node name. Nodes without
+// reported allocatable capacity are listed when pods run on them; pods without a known node are skipped.
+func (f valueFormat) summarizeNodes(nodes []k8s.NodeAllocatable, podNodes map[string]string, metrics []k8s.PodMetric) []models.NodeSummary {
+	type nodeTotals struct {
+		allocatable k8s.NodeAllocatable
+		total       k8s.PodMetric
+		pods        map[string]bool
+	}
+
+	totals := make(map[string]*nodeTotals)
+	totalsFor := func(node string) *nodeTotals {
+		if _, exists := totals[node]; !exists {
+			totals[node] = &nodeTotals{allocatable: k8s.NodeAllocatable{Node: node}, pods: make(map[string]bool)}
+		}
+		return totals[node]
+	}
+	for _, node := range nodes {
+		totalsFor(node.Node).allocatable = node
+	}
+
+	for _, metric := range metrics {
+		key := metric.Namespace + "/" + metric.Name
+		node, ok := podNodes[key]
+		if !ok {
+			continue
+		}
+		t := totalsFor(node)
+		t.pods[key] = true
+		t.total.CPUUsage += metric.CPUUsageCores()
+		t.total.CPURequest += metric.CPURequest
+		t.total.CPULimit += metric.CPULimit
+		t.total.MemoryUsage += metric.MemoryUsage
+		t.total.MemoryRequest += metric.MemoryRequest
+		t.total.MemoryLimit += metric.MemoryLimit
+	}
+
+	summaries := []models.NodeSummary{}
+	for name, t := range totals {
+		summaries = append(summaries, models.NodeSummary{
+			Name:     name,
+			PodCount: len(t.pods),
+			CPU:      f.buildResourceCapacity(t.allocatable.CPU, t.total.CPURequest, t.total.CPULimit, t.total.CPUUsage),
+			Memory:   f.buildResourceCapacity(t.allocatable.Memory, t.total.MemoryRequest, t.total.MemoryLimit, t.total.MemoryUsage),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
+
+// filterByNode returns the container metrics of the pods scheduled on node
+func filterByNode(metrics []k8s.PodMetric, podNodes map[string]string, node string) []k8s.PodMetric {
+	filtered := []k8s.PodMetric{}
+	for _, metric := range metrics {
+		if podNodes[metric.Namespace+"/"+metric.Name] == node {
+			filtered = append(filtered, metric)
+		}
+	}
+	return filtered
+}
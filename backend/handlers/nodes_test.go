@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// nodeTestClient runs web-0 (two containers) and web-1 on node-a, api-0 on node-b and batch-0 on an unknown node
+func nodeTestClient() *fakeMetricsClient {
+	return &fakeMetricsClient{
+		nodes: []k8s.NodeAllocatable{
+			{Node: "node-a", CPU: 4, Memory: 8 << 30},
+			{Node: "node-b", CPU: 2, Memory: 4 << 30},
+			{Node: "node-c", CPU: 2, Memory: 4 << 30},
+		},
+		podNodes: map[string]string{"shop/web-0": "node-a", "shop/web-1": "node-a", "billing/api-0": "node-b"},
+		current: []k8s.PodMetric{
+			{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.5, CPURequest: 1, CPULimit: 2, MemoryUsage: 1 << 30, MemoryRequest: 2 << 30},
+			{Name: "web-0", Namespace: "shop", ContainerName: "sidecar", CPUUsage: 0.1, CPURequest: 0.2, MemoryUsage: 128 << 20, MemoryRequest: 256 << 20},
+			{Name: "web-1", Namespace: "shop", ContainerName: "app", CPUUsage: 0.4, CPURequest: 0.8, MemoryUsage: 1 << 30, MemoryRequest: 2 << 30},
+			{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 1, CPURequest: 1, MemoryUsage: 512 << 20, MemoryRequest: 1 << 30},
+			{Name: "batch-0", Namespace: "batch", ContainerName: "main", CPUUsage: 2, CPURequest: 2},
+		},
+	}
+}
+
+func TestGetPodMetricsNodeFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		want     []string // namespace/pod/container
+	}{
+		{"node-a", "/api/pods?node=node-a", http.StatusOK, []string{"shop/web-0/app", "shop/web-0/sidecar", "shop/web-1/app"}},
+		{"node-b", "/api/pods?node=node-b", http.StatusOK, []string{"billing/api-0/app"}},
+		{"node and namespace", "/api/pods?node=node-a&namespace=billing", http.StatusOK, []string{}},
+		{"node without pods", "/api/pods?node=node-c", http.StatusOK, []string{}},
+		{"invalid node", `/api/pods?node=node-a"}`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTestHandler(nodeTestClient()).GetPodMetrics, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var response models.PodMetricsList
+			decodeResponse(t, rec, &response)
+			got := []string{}
+			for _, pod := range response.Pods {
+				got = append(got, pod.Namespace+"/"+pod.Name+"/"+pod.ContainerName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pods = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// nodeQueryClient filters pods by node in its queries, recording the node and selector of each call
+type nodeQueryClient struct {
+	*fakeMetricsClient
+	nodes, selectors []string
+}
+
+func (c *nodeQueryClient) GetNodePodMetrics(ctx context.Context, namespace, node, selector string, at time.Time) ([]k8s.PodMetric, error) {
+	c.nodes = append(c.nodes, node)
+	c.selectors = append(c.selectors, selector)
+	// Only the node's pods would match the backend query
+	return []k8s.PodMetric{
+		{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.5},
+		{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 1},
+	}, nil
+}
+
+func TestGetPodMetricsNodeQuery(t *testing.T) {
+	allowlist, err := parseNamespaceAllowlist("shop")
+	if err != nil {
+		t.Fatalf("parseNamespaceAllowlist() error = %v", err)
+	}
+	// Without a pod to node mapping, only the node query can return pods
+	client := &nodeQueryClient{fakeMetricsClient: &fakeMetricsClient{}}
+	h := newTestHandler(scopeNamespaces(client, allowlist))
+	h.namespaces = allowlist
+
+	var response models.PodMetricsList
+	decodeResponse(t, serve(h.GetPodMetrics, `/api/pods?node=node-a&selector=app%3D%22web%22`), &response)
+	got := []string{}
+	for _, pod := range response.Pods {
+		got = append(got, pod.Namespace+"/"+pod.Name+"/"+pod.ContainerName)
+	}
+	if want := []string{"shop/web-0/app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pods = %v, want %v", got, want)
+	}
+	if want := []string{"node-a"}; !reflect.DeepEqual(client.nodes, want) {
+		t.Errorf("node queries = %v, want %v", client.nodes, want)
+	}
+	if want := []string{`app="web"`}; !reflect.DeepEqual(client.selectors, want) {
+		t.Errorf("node query selectors = %v, want %v", client.selectors, want)
+	}
+}
+
+func TestGetNodes(t *testing.T) {
+	var response models.NodeList
+	decodeResponse(t, serve(newTestHandler(nodeTestClient()).GetNodes, "/api/nodes"), &response)
+
+	tests := []struct {
+		name     string
+		podCount int
+		cpu      models.ResourceCapacity
+	}{
+		{"node-a", 2, models.ResourceCapacity{Allocatable: 4, Requests: 2, Limits: 2, Usage: 1, RequestsPercentage: 50, LimitsPercentage: 50, UsagePercentage: 25, Headroom: 2}},
+		{"node-b", 1, models.ResourceCapacity{Allocatable: 2, Requests: 1, Usage: 1, RequestsPercentage: 50, UsagePercentage: 50, Headroom: 1}},
+		{"node-c", 0, models.ResourceCapacity{Allocatable: 2, Headroom: 2}},
+	}
+	if len(response.Nodes) != len(tests) {
+		t.Fatalf("got %d nodes, want %d: %+v", len(response.Nodes), len(tests), response.Nodes)
+	}
+	for i, tt := range tests {
+		node := response.Nodes[i]
+		if node.Name != tt.name || node.PodCount != tt.podCount {
+			t.Errorf("node %d = %s with %d pods, want %s with %d", i, node.Name, node.PodCount, tt.name, tt.podCount)
+		}
+		if !reflect.DeepEqual(node.CPU, tt.cpu) {
+			t.Errorf("%s cpu = %+v, want %+v", node.Name, node.CPU, tt.cpu)
+		}
+	}
+	if got := response.Nodes[0].Memory.Requests; got != 4<<30+256<<20 {
+		t.Errorf("node-a memory requests = %v, want %v", got, 4<<30+256<<20)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"math"
+
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+const (
+	// defaultOutlierStdDevs is the default deviation, in standard deviations, beyond which a container is an outlier
+	defaultOutlierStdDevs = 2.0
+	// minOutlierGroupSize is the fewest containers a namespace needs before outliers are flagged
+	minOutlierGroupSize = 3
+	// minOutlierSpread floors the standard deviation, in efficiency percentage points, so a container
+	// isn't flagged for a tiny difference from otherwise identical peers
+	minOutlierSpread = 1.0
+)
+
+// annotateNamespaceOutliers compares each container's CPU and memory efficiency with the other containers
+// in its namespace and flags those more than stdDevs standard deviations away as outliers. Each container
+// is compared with the mean and standard deviation of its peers, excluding itself, so a single misconfigured
+// container among many similar ones stands out instead of inflating the spread it is measured against.
+func (f valueFormat) annotateNamespaceOutliers(metrics []models.HistoricalMetrics, stdDevs float64) {
+	namespaces := make(map[string][]int)
+	for i, metric := range metrics {
+		namespaces[metric.Namespace] = append(namespaces[metric.Namespace], i)
+	}
+
+	for _, indexes := range namespaces {
+		if len(indexes) < minOutlierGroupSize {
+			continue
+		}
+
+		cpu := make([]float64, len(indexes))
+		memory := make([]float64, len(indexes))
+		for j, i := range indexes {
+			cpu[j] = metrics[i].Analysis.CPUEfficiency
+			memory[j] = metrics[i].Analysis.MemoryEfficiency
+		}
+
+		for j, i := range indexes {
+			comparison := &models.NamespaceComparison{
+				CPUZScore:    f.roundValue(peerZScore(cpu, j)),
+				MemoryZScore: f.roundValue(peerZScore(memory, j)),
+			}
+			comparison.Outlier = math.Abs(comparison.CPUZScore) > stdDevs || math.Abs(comparison.MemoryZScore) > stdDevs
+			metrics[i].NamespaceComparison = comparison
+		}
+	}
+}
+
+// peerZScore returns how many standard deviations values[self] is from the mean of the other values
+func peerZScore(values []float64, self int) float64 {
+	var sum, sumSquares float64
+	for i, value := range values {
+		if i != self {
+			sum += value
+			sumSquares += value * value
+		}
+	}
+
+	peers := float64(len(values) - 1)
+	mean := sum / peers
+	variance := math.Max(sumSquares/peers-mean*mean, 0)
+	spread := math.Max(math.Sqrt(variance), minOutlierSpread)
+	return (values[self] - mean) / spread
+}
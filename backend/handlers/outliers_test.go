@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// efficiencies returns one container per CPU efficiency in namespace, named after its index
+func efficiencies(namespace string, cpu ...float64) []models.HistoricalMetrics {
+	metrics := make([]models.HistoricalMetrics, len(cpu))
+	for i, value := range cpu {
+		metrics[i] = models.HistoricalMetrics{
+			Namespace: namespace,
+			PodName:   namespace + "-" + string(rune('a'+i)),
+			Analysis:  models.UsageAnalysis{CPUEfficiency: value, MemoryEfficiency: 50},
+		}
+	}
+	return metrics
+}
+
+func TestAnnotateNamespaceOutliers(t *testing.T) {
+	tests := []struct {
+		name         string
+		metrics      []models.HistoricalMetrics
+		stdDevs      float64
+		wantOutliers []string // pods flagged as outliers
+		wantCompared bool     // whether containers get a namespace comparison at all
+	}{
+		{
+			name:         "one outlier among similar pods",
+			metrics:      efficiencies("shop", 60, 62, 58, 61, 59, 5),
+			stdDevs:      defaultOutlierStdDevs,
+			wantOutliers: []string{"shop-f"},
+			wantCompared: true,
+		},
+		{
+			name:         "similar pods",
+			metrics:      efficiencies("shop", 60, 61, 60, 61, 60),
+			stdDevs:      defaultOutlierStdDevs,
+			wantCompared: true,
+		},
+		{
+			name:         "identical pods but one a point apart",
+			metrics:      efficiencies("shop", 60, 60, 60, 60, 61),
+			stdDevs:      defaultOutlierStdDevs,
+			wantCompared: true,
+		},
+		{
+			name:         "outlier within a wider threshold",
+			metrics:      efficiencies("shop", 60, 62, 58, 61, 59, 55),
+			stdDevs:      10,
+			wantCompared: true,
+		},
+		{
+			name:         "namespace too small to compare",
+			metrics:      efficiencies("shop", 60, 5),
+			stdDevs:      defaultOutlierStdDevs,
+			wantCompared: false,
+		},
+		{
+			name:         "namespaces compared separately",
+			metrics:      append(efficiencies("shop", 60, 62, 58, 61, 59, 5), efficiencies("batch", 5, 6, 4, 5)...),
+			stdDevs:      defaultOutlierStdDevs,
+			wantOutliers: []string{"shop-f"},
+			wantCompared: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultValueFormat.annotateNamespaceOutliers(tt.metrics, tt.stdDevs)
+
+			want := make(map[string]bool)
+			for _, pod := range tt.wantOutliers {
+				want[pod] = true
+			}
+			for _, metric := range tt.metrics {
+				comparison := metric.NamespaceComparison
+				if (comparison != nil) != tt.wantCompared {
+					t.Fatalf("%s namespace comparison = %+v, want compared %v", metric.PodName, comparison, tt.wantCompared)
+				}
+				if comparison != nil && comparison.Outlier != want[metric.PodName] {
+					t.Errorf("%s outlier = %v (cpu z-score %v), want %v", metric.PodName, comparison.Outlier, comparison.CPUZScore, want[metric.PodName])
+				}
+			}
+
+			summary := defaultValueFormat.generateAnalysisSummary(tt.metrics, []float64{20, 40, 60, 80, 100})
+			if summary.OutlierPods != len(tt.wantOutliers) {
+				t.Errorf("summary outlier pods = %d, want %d", summary.OutlierPods, len(tt.wantOutliers))
+			}
+		})
+	}
+}
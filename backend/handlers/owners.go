@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// unattributedOwner groups the pods without the owner label
+const unattributedOwner = "unattributed"
+
+// GetOwnerSummary aggregates the historical analysis per value of the owner label (OWNER_LABEL),
+// so efficiency and waste can be attributed to teams
+func (h *Handler) GetOwnerSummary(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Historical analysis not available - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Get namespace from query parameter, empty for all namespaces
+	namespace := namespaceParam(r)
+
+	now := time.Now()
+	podLabels, err := metricsClient.GetPodLabels(ctx, namespace, now)
+	if err != nil {
+		log.Printf("Error getting pod labels from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, k8s.HistoricalOptions{})
+	if err != nil {
+		log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := models.OwnerSummaryList{
+		OwnerLabel:  h.ownerLabel,
+		Owners:      h.format.summarizeOwners(historicalData, podLabels, h.ownerLabel),
+		GeneratedAt: now,
+	}
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// summarizeOwners groups containers by the owner label of their pod, sorted by owner with the
+// unattributed pods last
+func (f valueFormat) summarizeOwners(historicalData []k8s.HistoricalMetrics, podLabels map[string]map[string]string, ownerLabel string) []models.OwnerSummary {
+	type ownerTotals struct {
+		pods                              map[string]bool
+		containers                        int
+		cpuEfficiency, memEfficiency      float64
+		wastedCPU, wastedMemory           float64
+		overProvisioned, underProvisioned int
+	}
+
+	totals := make(map[string]*ownerTotals)
+	for _, hm := range historicalData {
+		key := hm.Namespace + "/" + hm.PodName
+		owner := podLabels[key][ownerLabel]
+		if owner == "" {
+			owner = unattributedOwner
+		}
+		if totals[owner] == nil {
+			totals[owner] = &ownerTotals{pods: make(map[string]bool)}
+		}
+
+		metric := f.convertHistoricalMetrics(hm)
+		t := totals[owner]
+		t.pods[key] = true
+		t.containers++
+		t.cpuEfficiency += metric.Analysis.CPUEfficiency
+		t.memEfficiency += metric.Analysis.MemoryEfficiency
+		t.wastedCPU += wastedResource(metric.CPU)
+		t.wastedMemory += wastedResource(metric.Memory)
+		switch classifyAnalysis(metric.Analysis) {
+		case classificationOverProvisioned:
+			t.overProvisioned++
+		case classificationUnderProvisioned:
+			t.underProvisioned++
+		}
+	}
+
+	owners := []models.OwnerSummary{}
+	for owner, t := range totals {
+		cpuEfficiency := t.cpuEfficiency / float64(t.containers)
+		memEfficiency := t.memEfficiency / float64(t.containers)
+		owners = append(owners, models.OwnerSummary{
+			Owner:                   owner,
+			PodCount:                len(t.pods),
+			ContainerCount:          t.containers,
+			AverageCPUEfficiency:    f.roundValue(cpuEfficiency),
+			AverageMemoryEfficiency: f.roundValue(memEfficiency),
+			AverageEfficiency:       f.roundValue((cpuEfficiency + memEfficiency) / 2),
+			WastedCPU:               t.wastedCPU,
+			WastedMemory:            t.wastedMemory,
+			OverProvisionedPods:     t.overProvisioned,
+			UnderProvisionedPods:    t.underProvisioned,
+		})
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		if (owners[i].Owner == unattributedOwner) != (owners[j].Owner == unattributedOwner) {
+			return owners[j].Owner == unattributedOwner
+		}
+		return owners[i].Owner < owners[j].Owner
+	})
+	return owners
+}
+
+// wastedResource returns the average request not used on average, 0 when usage exceeds the request
+func wastedResource(data models.HistoricalResourceData) float64 {
+	if wasted := averageDataPoints(data.Requests) - data.Average; wasted > 0 {
+		return wasted
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// ownedContainer returns the historical metrics of a container with the given average usage and
+// request, efficiencies and waste analysis
+func ownedContainer(namespace, pod, container string, cpu, cpuRequest, memory, memoryRequest, cpuEfficiency, memoryEfficiency float64, waste k8s.ResourceWasteAnalysis) k8s.HistoricalMetrics {
+	hm := k8s.HistoricalMetrics{PodName: pod, Namespace: namespace, ContainerName: container}
+	hm.CPU.Average = cpu
+	hm.CPU.Requests = points(cpuRequest, cpuRequest)
+	hm.Memory.Average = memory
+	hm.Memory.Requests = points(memoryRequest, memoryRequest)
+	hm.Analysis.CPUEfficiency = cpuEfficiency
+	hm.Analysis.MemoryEfficiency = memoryEfficiency
+	hm.Analysis.ResourceWaste = waste
+	return hm
+}
+
+var ownerTestData = []k8s.HistoricalMetrics{
+	ownedContainer("shop", "web-0", "app", 0.1, 0.5, 100<<20, 256<<20, 20, 40, k8s.ResourceWasteAnalysis{CPUOverProvisioned: true}),
+	ownedContainer("shop", "web-0", "sidecar", 0.05, 0.05, 300<<20, 256<<20, 100, 120, k8s.ResourceWasteAnalysis{MemoryUnderProvisioned: true}),
+	ownedContainer("billing", "api-0", "app", 0.5, 1, 512<<20, 1<<30, 50, 50, k8s.ResourceWasteAnalysis{}),
+	ownedContainer("billing", "api-1", "app", 0.5, 1, 512<<20, 1<<30, 50, 50, k8s.ResourceWasteAnalysis{}),
+	ownedContainer("shop", "cron-0", "app", 0.1, 0.1, 10<<20, 10<<20, 100, 100, k8s.ResourceWasteAnalysis{}),
+	ownedContainer("shop", "debug-0", "app", 0.1, 0.2, 10<<20, 20<<20, 50, 50, k8s.ResourceWasteAnalysis{}),
+}
+
+// ownerTestLabels labels the pods of ownerTestData with two teams, leaving cron-0 without labels and
+// debug-0 without the team label
+var ownerTestLabels = map[string]map[string]string{
+	"shop/web-0":    {"team": "checkout"},
+	"billing/api-0": {"team": "web-platform", "app": "api"},
+	"billing/api-1": {"team": "web-platform", "app": "api"},
+	"shop/debug-0":  {"app": "debug"},
+}
+
+func TestSummarizeOwners(t *testing.T) {
+	got := defaultValueFormat.summarizeOwners(ownerTestData, ownerTestLabels, "team")
+
+	// Unattributed pods come last even when an owner sorts after them
+	want := []models.OwnerSummary{
+		{
+			Owner: "checkout", PodCount: 1, ContainerCount: 2,
+			AverageCPUEfficiency: 60, AverageMemoryEfficiency: 80, AverageEfficiency: 70,
+			WastedCPU: 0.4, WastedMemory: 156 << 20,
+			OverProvisionedPods: 1, UnderProvisionedPods: 1,
+		},
+		{
+			Owner: "web-platform", PodCount: 2, ContainerCount: 2,
+			AverageCPUEfficiency: 50, AverageMemoryEfficiency: 50, AverageEfficiency: 50,
+			WastedCPU: 1, WastedMemory: 1 << 30,
+		},
+		{
+			Owner: unattributedOwner, PodCount: 2, ContainerCount: 2,
+			AverageCPUEfficiency: 75, AverageMemoryEfficiency: 75, AverageEfficiency: 75,
+			WastedCPU: 0.1, WastedMemory: 10 << 20,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeOwners() = %+v, want %+v", got, want)
+	}
+
+	// Pods are unattributed when no pod has the owner label
+	got = defaultValueFormat.summarizeOwners(ownerTestData, ownerTestLabels, "cost_center")
+	if len(got) != 1 || got[0].Owner != unattributedOwner || got[0].PodCount != 5 || got[0].ContainerCount != 6 {
+		t.Errorf("summarizeOwners() without the owner label = %+v, want all pods unattributed", got)
+	}
+
+	if got := defaultValueFormat.summarizeOwners(nil, nil, "team"); got == nil || len(got) != 0 {
+		t.Errorf("summarizeOwners() of no containers = %#v, want an empty list", got)
+	}
+}
+
+func TestGetOwnerSummary(t *testing.T) {
+	h := newTestHandler(&fakeMetricsClient{historical: ownerTestData, podLabels: ownerTestLabels})
+	h.ownerLabel = "team"
+
+	rec := serve(h.GetOwnerSummary, "/api/owners/summary")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var response models.OwnerSummaryList
+	decodeResponse(t, rec, &response)
+	if response.OwnerLabel != "team" {
+		t.Errorf("owner label = %q, want team", response.OwnerLabel)
+	}
+	var owners []string
+	for _, owner := range response.Owners {
+		owners = append(owners, owner.Owner)
+	}
+	if want := []string{"checkout", "web-platform", unattributedOwner}; !reflect.DeepEqual(owners, want) {
+		t.Errorf("owners = %v, want %v", owners, want)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// packingPod is the CPU (cores) and memory (bytes) a pod needs on a node
+type packingPod struct {
+	cpu    float64
+	memory float64
+}
+
+// GetClusterPacking estimates how many nodes could be freed if pods were right-sized to their 7-day
+// P95 usage, by first-fit packing the pods onto the nodes at their current requests and at their P95
+func (h *Handler) GetClusterPacking(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	nodes, err := metricsClient.GetNodeAllocatable(ctx)
+	if err != nil {
+		log.Printf("Error getting node allocatable from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	podNodes, err := metricsClient.GetPodNodes(ctx, "", now)
+	if err != nil {
+		log.Printf("Error getting pod nodes from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metricsData, err := metricsClient.GetCurrentPodMetrics(ctx, "", "", now)
+	if err != nil {
+		log.Printf("Error getting pod metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Use the P95 usage loaded for headroom, or run the historical analysis until it is loaded
+	p95 := h.headroom.lookup(h.sourceKey(r), metricsClient, now)
+	if p95 == nil {
+		p95 = make(map[string]p95Usage)
+		err := metricsClient.StreamHistoricalMetrics(ctx, "", k8s.HistoricalOptions{}, func(hm k8s.HistoricalMetrics) error {
+			p95[hm.Namespace+"/"+hm.PodName+"/"+hm.ContainerName] = p95Usage{cpu: hm.CPU.P95, memory: hm.Memory.P95}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := estimatePacking(nodes, podNodes, metricsData, p95)
+	response.GeneratedAt = now
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// estimatePacking packs the pods scheduled on the given nodes at their current requests and right-sized
+// to their P95 usage, and reports the nodes left empty once right-sized. Containers without P95 usage
+// keep their requests; pods on nodes without reported allocatable capacity are skipped.
+func estimatePacking(nodes []k8s.NodeAllocatable, podNodes map[string]string, metrics []k8s.PodMetric, p95 map[string]p95Usage) models.ClusterPackingResponse {
+	known := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		known[node.Node] = true
+	}
+
+	// Sum the requests and P95 usage of each scheduled pod's containers
+	requested := make(map[string]*packingPod)
+	rightSized := make(map[string]*packingPod)
+	response := models.ClusterPackingResponse{NodeCount: len(nodes)}
+	for _, metric := range metrics {
+		key := metric.Namespace + "/" + metric.Name
+		if !known[podNodes[key]] {
+			continue
+		}
+		if _, exists := requested[key]; !exists {
+			requested[key] = &packingPod{}
+			rightSized[key] = &packingPod{}
+		}
+		requested[key].cpu += metric.CPURequest
+		requested[key].memory += metric.MemoryRequest
+
+		usage, ok := p95[key+"/"+metric.ContainerName]
+		if !ok {
+			usage = p95Usage{cpu: metric.CPURequest, memory: metric.MemoryRequest}
+		} else {
+			response.RightSizedContainers++
+		}
+		rightSized[key].cpu += usage.cpu
+		rightSized[key].memory += usage.memory
+
+		response.CPURequests += metric.CPURequest
+		response.CPURightSized += usage.cpu
+		response.MemoryRequests += metric.MemoryRequest
+		response.MemoryRightSized += usage.memory
+	}
+	response.PodCount = len(requested)
+
+	used, _ := firstFit(nodes, podSizes(requested))
+	response.NodesNeeded = len(used)
+
+	used, unplaced := firstFit(nodes, podSizes(rightSized))
+	response.NodesNeededRightSized = len(used)
+	response.UnplacedPods = unplaced
+
+	// Without room for every pod no node can be freed
+	response.ReclaimableNodes = []string{}
+	if unplaced == 0 {
+		for _, node := range nodes {
+			if !used[node.Node] {
+				response.ReclaimableNodes = append(response.ReclaimableNodes, node.Node)
+			}
+		}
+		sort.Strings(response.ReclaimableNodes)
+	}
+	response.ReclaimableNodeCount = len(response.ReclaimableNodes)
+	return response
+}
+
+// podSizes returns the pods of a namespace/pod keyed map in key order, for a deterministic packing
+func podSizes(pods map[string]*packingPod) []packingPod {
+	keys := make([]string, 0, len(pods))
+	for key := range pods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sizes := make([]packingPod, 0, len(keys))
+	for _, key := range keys {
+		sizes = append(sizes, *pods[key])
+	}
+	return sizes
+}
+
+// firstFit places the pods, largest first, on the first node with room for both their CPU and memory,
+// trying the largest nodes first. It returns the nodes given at least one pod and the number of pods
+// no node had room for. Sizes are compared by their share of the total allocatable CPU or memory,
+// whichever is larger.
+func firstFit(nodes []k8s.NodeAllocatable, pods []packingPod) (map[string]bool, int) {
+	var totalCPU, totalMemory float64
+	for _, node := range nodes {
+		totalCPU += node.CPU
+		totalMemory += node.Memory
+	}
+	share := func(cpu, memory float64) float64 {
+		var s float64
+		if totalCPU > 0 {
+			s = cpu / totalCPU
+		}
+		if totalMemory > 0 {
+			s = max(s, memory/totalMemory)
+		}
+		return s
+	}
+
+	free := make([]k8s.NodeAllocatable, len(nodes))
+	copy(free, nodes)
+	sort.SliceStable(free, func(i, j int) bool {
+		si, sj := share(free[i].CPU, free[i].Memory), share(free[j].CPU, free[j].Memory)
+		if si != sj {
+			return si > sj
+		}
+		return free[i].Node < free[j].Node
+	})
+
+	sorted := make([]packingPod, len(pods))
+	copy(sorted, pods)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return share(sorted[i].cpu, sorted[i].memory) > share(sorted[j].cpu, sorted[j].memory)
+	})
+
+	used := make(map[string]bool)
+	unplaced := 0
+	for _, pod := range sorted {
+		placed := false
+		for i := range free {
+			if pod.cpu <= free[i].CPU && pod.memory <= free[i].Memory {
+				free[i].CPU -= pod.cpu
+				free[i].Memory -= pod.memory
+				used[free[i].Node] = true
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			unplaced++
+		}
+	}
+	return used, unplaced
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// packingTestNodes are two 4-core 16Gi nodes and a 2-core 8Gi node
+var packingTestNodes = []k8s.NodeAllocatable{
+	{Node: "node-c", CPU: 2, Memory: 8 << 30},
+	{Node: "node-b", CPU: 4, Memory: 16 << 30},
+	{Node: "node-a", CPU: 4, Memory: 16 << 30},
+}
+
+var packingTestPodNodes = map[string]string{
+	"shop/web-0":      "node-a",
+	"shop/web-1":      "node-b",
+	"shop/api-0":      "node-b",
+	"billing/db-0":    "node-c",
+	"billing/ghost-0": "node-z",
+}
+
+var packingTestMetrics = []k8s.PodMetric{
+	{Namespace: "shop", Name: "web-0", ContainerName: "app", CPURequest: 2, MemoryRequest: 4 << 30},
+	{Namespace: "shop", Name: "web-1", ContainerName: "app", CPURequest: 2, MemoryRequest: 4 << 30},
+	{Namespace: "shop", Name: "api-0", ContainerName: "app", CPURequest: 1.5, MemoryRequest: 6 << 30},
+	{Namespace: "shop", Name: "api-0", ContainerName: "sidecar", CPURequest: 0.5, MemoryRequest: 2 << 30},
+	{Namespace: "billing", Name: "db-0", ContainerName: "app", CPURequest: 3, MemoryRequest: 12 << 30},
+	// On a node without reported capacity
+	{Namespace: "billing", Name: "ghost-0", ContainerName: "app", CPURequest: 1, MemoryRequest: 1 << 30},
+}
+
+// packingTestP95 right-sizes every container but the api-0 sidecar
+var packingTestP95 = map[string]p95Usage{
+	"shop/web-0/app":      {cpu: 0.5, memory: 1 << 30},
+	"shop/web-1/app":      {cpu: 0.5, memory: 1 << 30},
+	"shop/api-0/app":      {cpu: 0.25, memory: 2 << 30},
+	"billing/db-0/app":    {cpu: 2, memory: 10 << 30},
+	"billing/ghost-0/app": {cpu: 0.5, memory: 1 << 30},
+}
+
+func TestFirstFit(t *testing.T) {
+	tests := []struct {
+		name         string
+		nodes        []k8s.NodeAllocatable
+		pods         []packingPod
+		wantUsed     map[string]bool
+		wantUnplaced int
+	}{
+		{
+			// The 3-core pod takes node-a, the 2-core 4Gi pods fill node-b and the 8Gi pod only fits on node-c
+			name:     "requests",
+			nodes:    packingTestNodes,
+			pods:     []packingPod{{2, 4 << 30}, {2, 4 << 30}, {2, 8 << 30}, {3, 12 << 30}},
+			wantUsed: map[string]bool{"node-a": true, "node-b": true, "node-c": true},
+		},
+		{
+			name:     "right-sized pods fit on the largest node",
+			nodes:    packingTestNodes,
+			pods:     []packingPod{{0.5, 1 << 30}, {0.5, 1 << 30}, {0.75, 4 << 30}, {2, 10 << 30}},
+			wantUsed: map[string]bool{"node-a": true},
+		},
+		{
+			name:         "pod larger than any node",
+			nodes:        packingTestNodes,
+			pods:         []packingPod{{1, 1 << 30}, {1, 32 << 30}},
+			wantUsed:     map[string]bool{"node-a": true},
+			wantUnplaced: 1,
+		},
+		{
+			name:     "no pods",
+			nodes:    packingTestNodes,
+			wantUsed: map[string]bool{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used, unplaced := firstFit(tt.nodes, tt.pods)
+			if !reflect.DeepEqual(used, tt.wantUsed) || unplaced != tt.wantUnplaced {
+				t.Errorf("firstFit() = %v, %d unplaced, want %v, %d unplaced", used, unplaced, tt.wantUsed, tt.wantUnplaced)
+			}
+		})
+	}
+}
+
+func TestEstimatePacking(t *testing.T) {
+	got := estimatePacking(packingTestNodes, packingTestPodNodes, packingTestMetrics, packingTestP95)
+	want := models.ClusterPackingResponse{
+		NodeCount:             3,
+		PodCount:              4,
+		NodesNeeded:           3,
+		NodesNeededRightSized: 1,
+		ReclaimableNodeCount:  2,
+		ReclaimableNodes:      []string{"node-b", "node-c"},
+		RightSizedContainers:  4,
+		CPURequests:           9,
+		CPURightSized:         3.75,
+		MemoryRequests:        28 << 30,
+		MemoryRightSized:      16 << 30,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("estimatePacking() = %+v, want %+v", got, want)
+	}
+
+	// Without room for every right-sized pod no node is reclaimable
+	got = estimatePacking(packingTestNodes[:1], packingTestPodNodes, packingTestMetrics, packingTestP95)
+	if got.UnplacedPods != 1 || got.ReclaimableNodeCount != 0 || len(got.ReclaimableNodes) != 0 {
+		t.Errorf("estimatePacking() on node-c only = %+v, want 1 unplaced pod and no reclaimable nodes", got)
+	}
+}
+
+func TestGetClusterPacking(t *testing.T) {
+	var historical []k8s.HistoricalMetrics
+	for key, usage := range packingTestP95 {
+		var hm k8s.HistoricalMetrics
+		for _, metric := range packingTestMetrics {
+			if metric.Namespace+"/"+metric.Name+"/"+metric.ContainerName == key {
+				hm = k8s.HistoricalMetrics{PodName: metric.Name, Namespace: metric.Namespace, ContainerName: metric.ContainerName}
+			}
+		}
+		hm.CPU.P95, hm.Memory.P95 = usage.cpu, usage.memory
+		historical = append(historical, hm)
+	}
+	h := newTestHandler(&fakeMetricsClient{
+		current:    packingTestMetrics,
+		historical: historical,
+		nodes:      packingTestNodes,
+		podNodes:   packingTestPodNodes,
+	})
+
+	rec := serve(h.GetClusterPacking, "/api/cluster/packing")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var response models.ClusterPackingResponse
+	decodeResponse(t, rec, &response)
+	if response.NodesNeeded != 3 || response.NodesNeededRightSized != 1 || !reflect.DeepEqual(response.ReclaimableNodes, []string{"node-b", "node-c"}) {
+		t.Errorf("response = %+v, want node-b and node-c reclaimable", response)
+	}
+	if math.Abs(response.CPURightSized-3.75) > 1e-9 {
+		t.Errorf("right-sized CPU = %v, want 3.75", response.CPURightSized)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// namespacePattern matches a Kubernetes namespace name (an RFC 1123 label)
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// maxNamespaceLength is the longest namespace name Kubernetes accepts
+const maxNamespaceLength = 63
+
+// requestParams holds the common query parameters, validated once by ParseRequestParams
+type requestParams struct {
+	namespace string // Empty for all namespaces
+	cluster   string // Empty for the default cluster
+}
+
+type requestParamsKey struct{}
+
+// ParseRequestParams is a middleware that validates the common namespace and cluster query parameters
+// and stores them in the request context, rejecting invalid values with 400 before any handler runs
+func ParseRequestParams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		params := requestParams{
+			namespace: query.Get("namespace"),
+			cluster:   query.Get("cluster"),
+		}
+
+		if params.namespace != "" && (len(params.namespace) > maxNamespaceLength || !namespacePattern.MatchString(params.namespace)) {
+			http.Error(w, fmt.Sprintf("invalid namespace parameter: %q must be at most %d lowercase alphanumeric characters or '-', starting and ending with an alphanumeric character", params.namespace, maxNamespaceLength), http.StatusBadRequest)
+			return
+		}
+		if params.cluster != "" && !clusterNamePattern.MatchString(params.cluster) {
+			http.Error(w, fmt.Sprintf("invalid cluster parameter: %q", params.cluster), http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestParamsKey{}, params)))
+	})
+}
+
+// paramsFrom returns the parameters parsed by ParseRequestParams, empty when the middleware did not run
+func paramsFrom(r *http.Request) requestParams {
+	params, _ := r.Context().Value(requestParamsKey{}).(requestParams)
+	return params
+}
+
+// namespaceParam returns the validated namespace parameter, empty for all namespaces
+func namespaceParam(r *http.Request) string {
+	return paramsFrom(r).namespace
+}
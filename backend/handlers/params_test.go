@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRequestParams(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		wantCode      int
+		wantNamespace string
+		wantCluster   string
+	}{
+		{"no parameters", "/api/pods", http.StatusOK, "", ""},
+		{"empty namespace", "/api/pods?namespace=", http.StatusOK, "", ""},
+		{"namespace", "/api/pods?namespace=kube-system", http.StatusOK, "kube-system", ""},
+		{"single character", "/api/pods?namespace=a", http.StatusOK, "a", ""},
+		{"longest namespace", "/api/pods?namespace=" + strings.Repeat("a", 63), http.StatusOK, strings.Repeat("a", 63), ""},
+		{"namespace and cluster", "/api/pods?namespace=shop&cluster=prod-eu", http.StatusOK, "shop", "prod-eu"},
+		{"too long", "/api/pods?namespace=" + strings.Repeat("a", 64), http.StatusBadRequest, "", ""},
+		{"uppercase", "/api/pods?namespace=Shop", http.StatusBadRequest, "", ""},
+		{"leading dash", "/api/pods?namespace=-shop", http.StatusBadRequest, "", ""},
+		{"trailing dash", "/api/pods?namespace=shop-", http.StatusBadRequest, "", ""},
+		{"selector injection", `/api/pods?namespace=shop"}`, http.StatusBadRequest, "", ""},
+		{"regex", "/api/pods?namespace=shop.*", http.StatusBadRequest, "", ""},
+		{"invalid cluster", "/api/pods?cluster=prod.eu", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			var got requestParams
+			handler := ParseRequestParams(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				got = paramsFrom(r)
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if called != (tt.wantCode == http.StatusOK) {
+				t.Errorf("handler called = %v, want %v", called, tt.wantCode == http.StatusOK)
+			}
+			if got.namespace != tt.wantNamespace || got.cluster != tt.wantCluster {
+				t.Errorf("params = %+v, want namespace %q and cluster %q", got, tt.wantNamespace, tt.wantCluster)
+			}
+		})
+	}
+}
+
+func TestParamsFromWithoutMiddleware(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/pods?namespace=shop", nil)
+	if got := namespaceParam(r); got != "" {
+		t.Errorf("namespaceParam() = %q, want empty without the middleware", got)
+	}
+}
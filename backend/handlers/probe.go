@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+const (
+	// probeTimeout bounds the backend probe so health checks stay fast
+	probeTimeout = 2 * time.Second
+	// probeCacheTTL is how long a probe result is reused so health checks don't hammer the backend
+	probeCacheTTL = 10 * time.Second
+	// defaultReadinessCacheTTL is the default time a readiness probe result is reused
+	defaultReadinessCacheTTL = 5 * time.Second
+)
+
+// backendProbe measures the latency of a cheap backend query and caches the result briefly
+type backendProbe struct {
+	ttl         time.Duration // How long a result is reused, probeCacheTTL when zero
+	mu          sync.Mutex
+	result      models.BackendProbe
+	lastSuccess time.Time
+}
+
+// check returns the latest probe result, probing the backend when the cached one has expired.
+// Concurrent health checks wait for a single probe instead of each querying the backend.
+func (p *backendProbe) check(ctx context.Context, metricsClient k8s.MetricsClient) models.BackendProbe {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ttl := p.ttl
+	if ttl == 0 {
+		ttl = probeCacheTTL
+	}
+	now := time.Now()
+	if !p.result.CheckedAt.IsZero() && now.Sub(p.result.CheckedAt) < ttl {
+		return p.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := metricsClient.Ping(ctx)
+	latency := time.Since(start)
+
+	result := models.BackendProbe{
+		Status:    "ok",
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		CheckedAt: now,
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	} else {
+		p.lastSuccess = now
+	}
+	if !p.lastSuccess.IsZero() {
+		lastSuccess := p.lastSuccess
+		result.LastSuccess = &lastSuccess
+	}
+
+	p.result = result
+	return result
+}
+
+// Ready reports whether the default cluster's metrics backend answers, with 503 when it doesn't.
+// The probe result is reused for READINESS_CACHE_TTL so frequent Kubernetes probes don't hammer the backend.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	metricsClient := h.clusters[h.defaultCluster]
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := h.readiness.check(r.Context(), metricsClient)
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+	status := http.StatusOK
+	if result.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	// Write response
+	writeJSONLimitedStatus(w, status, result, h.maxResponseBytes)
+}
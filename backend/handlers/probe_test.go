@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// pingClient is a metrics client whose Ping takes delay and then returns err
+type pingClient struct {
+	*fakeMetricsClient
+	delay time.Duration
+	err   error
+	pings atomic.Int32
+}
+
+func (c *pingClient) Ping(ctx context.Context) error {
+	c.pings.Add(1)
+	select {
+	case <-time.After(c.delay):
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestBackendProbe(t *testing.T) {
+	tests := []struct {
+		name        string
+		delay       time.Duration
+		err         error
+		deadline    time.Duration // Deadline of the health check, none when zero
+		wantStatus  string
+		wantLatency time.Duration // Minimum latency
+		wantError   string
+	}{
+		{"fast", 0, nil, 0, "ok", 0, ""},
+		{"slow", 50 * time.Millisecond, nil, 0, "ok", 50 * time.Millisecond, ""},
+		{"failing", 0, errors.New("connection refused"), 0, "failed", 0, "connection refused"},
+		{"timed out", time.Second, nil, 20 * time.Millisecond, "failed", 20 * time.Millisecond, context.DeadlineExceeded.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			var probe backendProbe
+			result := probe.check(ctx, &pingClient{fakeMetricsClient: &fakeMetricsClient{}, delay: tt.delay, err: tt.err})
+
+			if result.Status != tt.wantStatus || result.Error != tt.wantError {
+				t.Errorf("status, error = %q, %q, want %q, %q", result.Status, result.Error, tt.wantStatus, tt.wantError)
+			}
+			if result.LatencyMs < float64(tt.wantLatency.Milliseconds()) || result.LatencyMs > float64(tt.wantLatency.Milliseconds())+500 {
+				t.Errorf("latency = %vms, want at least %s", result.LatencyMs, tt.wantLatency)
+			}
+			if (result.LastSuccess != nil) != (tt.wantStatus == "ok") {
+				t.Errorf("last success = %v, want set %v", result.LastSuccess, tt.wantStatus == "ok")
+			}
+		})
+	}
+}
+
+func TestBackendProbeCache(t *testing.T) {
+	client := &pingClient{fakeMetricsClient: &fakeMetricsClient{}}
+	probe := backendProbe{ttl: 50 * time.Millisecond}
+
+	first := probe.check(context.Background(), client)
+	if second := probe.check(context.Background(), client); second != first || client.pings.Load() != 1 {
+		t.Fatalf("pings = %d, want the cached result reused", client.pings.Load())
+	}
+
+	// Once expired a failing backend is probed again, keeping the last success
+	time.Sleep(60 * time.Millisecond)
+	client.err = errors.New("connection refused")
+	failed := probe.check(context.Background(), client)
+	if client.pings.Load() != 2 || failed.Status != "failed" {
+		t.Fatalf("pings, status = %d, %q, want a new failed probe", client.pings.Load(), failed.Status)
+	}
+	if failed.LastSuccess == nil || !failed.LastSuccess.Equal(first.CheckedAt) {
+		t.Errorf("last success = %v, want the first probe at %v", failed.LastSuccess, first.CheckedAt)
+	}
+}
+
+func TestHealthBackendProbe(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+	}{
+		{"available", nil, "ok"},
+		{"failing", errors.New("connection refused"), "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &pingClient{fakeMetricsClient: &fakeMetricsClient{clientType: "prometheus"}, err: tt.err}
+			var response struct {
+				Status         string              `json:"status"`
+				MetricsBackend string              `json:"metricsBackend"`
+				BackendProbe   models.BackendProbe `json:"backendProbe"`
+			}
+			rec := serve(newTestHandler(client).Health, "/health")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d even when the backend fails", rec.Code, http.StatusOK)
+			}
+			decodeResponse(t, rec, &response)
+			if response.Status != "healthy" || response.MetricsBackend != "prometheus" || response.BackendProbe.Status != tt.wantStatus {
+				t.Errorf("health = %+v, want a %s probe", response, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestReadyProbesBackendOncePerTTL(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	client := &pingClient{fakeMetricsClient: &fakeMetricsClient{}}
+	h := newTestHandler(client)
+	h.readiness = backendProbe{ttl: ttl}
+
+	// probe sends a burst of concurrent readiness probes and returns their status codes
+	probe := func() map[int]int {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		codes := make(map[int]int)
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec := serve(h.Ready, "/readyz")
+				mu.Lock()
+				codes[rec.Code]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		return codes
+	}
+
+	if codes := probe(); codes[http.StatusOK] != 20 {
+		t.Fatalf("status codes = %v, want 20 OK", codes)
+	}
+	if codes := probe(); codes[http.StatusOK] != 20 {
+		t.Fatalf("status codes = %v, want 20 OK", codes)
+	}
+	if got := client.pings.Load(); got != 1 {
+		t.Fatalf("pings within the TTL = %d, want 1", got)
+	}
+
+	// An outage shows once the cached result expires, again probing only once
+	client.err = errors.New("connection refused")
+	time.Sleep(ttl + 20*time.Millisecond)
+	if codes := probe(); codes[http.StatusServiceUnavailable] != 20 {
+		t.Fatalf("status codes = %v, want 20 Service Unavailable", codes)
+	}
+	if got := client.pings.Load(); got != 2 {
+		t.Errorf("pings after the TTL = %d, want 2", got)
+	}
+}
+
+func TestNewHandlerReadinessCacheTTL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultReadinessCacheTTL},
+		{"30s", 30 * time.Second},
+		{"0s", defaultReadinessCacheTTL},
+		{"-1s", defaultReadinessCacheTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("READINESS_CACHE_TTL", tt.value)
+			h, err := NewHandler()
+			if err != nil {
+				t.Fatalf("NewHandler() error = %v", err)
+			}
+			if h.readiness.ttl != tt.want {
+				t.Errorf("readiness cache TTL = %s, want %s", h.readiness.ttl, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// Request recommendation algorithms
+const (
+	recommendationAverage = "average" // Average usage plus the safety margin
+	recommendationVPA     = "vpa"     // Vertical Pod Autoscaler style target estimation
+)
+
+const (
+	// Default headroom, in percent, added on top of the estimated usage. Memory gets more
+	// since exceeding it gets the container OOMKilled rather than throttled.
+	defaultCPUHeadroomPercent    = 20.0
+	defaultMemoryHeadroomPercent = 30.0
+	// vpaCPUPercentile is the percentile of the decayed CPU usage histogram VPA targets
+	vpaCPUPercentile = 0.9
+	// vpaHalfLife is the half-life of the weight of CPU samples, so recent usage counts more
+	vpaHalfLife = 24 * time.Hour
+)
+
+// recommendationHeadroom is the headroom, in percent, added on top of the estimated CPU and memory usage
+type recommendationHeadroom struct {
+	cpu, memory float64
+}
+
+// GetPodRecommendations returns recommended CPU and memory requests per container, computed from the
+// 7-day history with the algorithm selected by the "algorithm" parameter (average or vpa).
+// The cpuHeadroom and memoryHeadroom parameters override the configured headroom percentages.
+// With format=csv a prioritized action list is returned instead.
+func (h *Handler) GetPodRecommendations(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Recommendations not available - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	algorithm := r.URL.Query().Get("algorithm")
+	if algorithm == "" {
+		algorithm = recommendationAverage
+	}
+	if algorithm != recommendationAverage && algorithm != recommendationVPA {
+		http.Error(w, fmt.Sprintf("invalid algorithm parameter: %s (expected %s or %s)", algorithm, recommendationAverage, recommendationVPA), http.StatusBadRequest)
+		return
+	}
+
+	format, ok := negotiateFormat(w, r, formatJSON, formatCSV)
+	if !ok {
+		return
+	}
+
+	var headroom recommendationHeadroom
+	var err error
+	if headroom.cpu, err = parseFloatParam(r, "cpuHeadroom", h.headroomDefaults.cpu); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if headroom.memory, err = parseFloatParam(r, "memoryHeadroom", h.headroomDefaults.memory); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Get namespace from query parameter, empty for all namespaces
+	namespace := namespaceParam(r)
+
+	// Collect backend warnings so consumers know when data may be incomplete
+	ctx, warnings := k8s.WithQueryWarnings(ctx)
+
+	historicalData, err := metricsClient.GetHistoricalMetrics(ctx, namespace, k8s.HistoricalOptions{})
+	if err != nil {
+		log.Printf("Error getting historical metrics from %s: %v", metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == formatCSV {
+		writeRecommendationsCSV(w, historicalData, algorithm, headroom, namespaceParam(r))
+		return
+	}
+
+	recommendations := []models.ResourceRecommendation{}
+	for _, hm := range historicalData {
+		cpu, memory := recommendRequests(hm, algorithm, headroom)
+		recommendations = append(recommendations, models.ResourceRecommendation{
+			PodName:       hm.PodName,
+			Namespace:     hm.Namespace,
+			ContainerName: hm.ContainerName,
+			CPU:           buildRecommendedResource(latestValue(hm.CPU.Requests), cpu, formatCPU),
+			Memory:        buildRecommendedResource(latestValue(hm.Memory.Requests), memory, h.format.formatMemory),
+		})
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write response
+	response := models.ResourceRecommendationList{
+		Algorithm:             algorithm,
+		CPUHeadroomPercent:    headroom.cpu,
+		MemoryHeadroomPercent: headroom.memory,
+		Recommendations:       recommendations,
+		GeneratedAt:           time.Now(),
+		Warnings:              warnings.Warnings(),
+	}
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
+
+// recommendRequests returns the recommended CPU (cores) and memory (bytes) requests of a container
+func recommendRequests(hm k8s.HistoricalMetrics, algorithm string, headroom recommendationHeadroom) (float64, float64) {
+	cpu, memory := hm.CPU.Average, hm.Memory.Average
+	if algorithm == recommendationVPA {
+		// Like the VPA recommender: a decayed percentile for CPU, since short spikes are only
+		// throttled, and the peak for memory, since exceeding it gets the container OOMKilled
+		cpu = decayedPercentile(hm.CPU.Usage, vpaCPUPercentile, vpaHalfLife)
+		memory = hm.Memory.Peak
+	}
+	return cpu * (1 + headroom.cpu/100), memory * (1 + headroom.memory/100)
+}
+
+// decayedPercentile returns the percentile of the points where each point is weighted by
+// 2^(-age/halfLife), its age being measured from the newest point
+func decayedPercentile(points []k8s.DataPoint, percentile float64, halfLife time.Duration) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+
+	newest := points[0].Timestamp
+	for _, point := range points {
+		if point.Timestamp.After(newest) {
+			newest = point.Timestamp
+		}
+	}
+
+	type weightedValue struct {
+		value  float64
+		weight float64
+	}
+	values := make([]weightedValue, len(points))
+	var total float64
+	for i, point := range points {
+		age := newest.Sub(point.Timestamp)
+		weight := math.Exp2(-float64(age) / float64(halfLife))
+		values[i] = weightedValue{value: point.Value, weight: weight}
+		total += weight
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].value < values[j].value })
+
+	// Return the smallest value whose cumulative weight reaches the percentile
+	threshold := percentile * total
+	var cumulative float64
+	for _, v := range values {
+		cumulative += v.weight
+		if cumulative >= threshold {
+			return v.value
+		}
+	}
+	return values[len(values)-1].value
+}
+
+// latestValue returns the value of the newest data point, 0 without points
+func latestValue(points []k8s.DataPoint) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	return points[len(points)-1].Value
+}
+
+// buildRecommendedResource builds a formatted current vs. recommended request comparison
+func buildRecommendedResource(current, recommended float64, format func(float64) string) models.RecommendedResource {
+	return models.RecommendedResource{
+		CurrentRequest:          current,
+		Recommended:             recommended,
+		CurrentRequestFormatted: format(current),
+		RecommendedFormatted:    format(recommended),
+	}
+}
+
+// recommendationRow is one line of the CSV action list
+type recommendationRow struct {
+	namespace, pod, container string
+	resource, unit            string
+	current, suggested        float64
+	wastePercentage           float64
+	savings                   float64 // wastePercentage of the current request, in the resource's unit
+}
+
+// writeRecommendationsCSV writes one row per container and resource, CPU rows first, each ordered by
+// estimated savings (waste percentage × current request) so the largest wins come first
+func writeRecommendationsCSV(w http.ResponseWriter, historicalData []k8s.HistoricalMetrics, algorithm string, headroom recommendationHeadroom, namespace string) {
+	var cpuRows, memoryRows []recommendationRow
+	for _, hm := range historicalData {
+		cpu, memory := recommendRequests(hm, algorithm, headroom)
+		waste := hm.Analysis.ResourceWaste
+
+		currentCPU := latestValue(hm.CPU.Requests)
+		cpuRows = append(cpuRows, recommendationRow{
+			namespace: hm.Namespace, pod: hm.PodName, container: hm.ContainerName,
+			resource: "cpu", unit: "cores",
+			current: currentCPU, suggested: cpu,
+			wastePercentage: waste.CPUWastePercentage,
+			savings:         waste.CPUWastePercentage / 100 * currentCPU,
+		})
+
+		currentMemory := latestValue(hm.Memory.Requests)
+		memoryRows = append(memoryRows, recommendationRow{
+			namespace: hm.Namespace, pod: hm.PodName, container: hm.ContainerName,
+			resource: "memory", unit: "bytes",
+			current: currentMemory, suggested: memory,
+			wastePercentage: waste.MemoryWastePercentage,
+			savings:         waste.MemoryWastePercentage / 100 * currentMemory,
+		})
+	}
+	for _, rows := range [][]recommendationRow{cpuRows, memoryRows} {
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].savings > rows[j].savings })
+	}
+
+	scope := namespace
+	if scope == "" {
+		scope = "all-namespaces"
+	}
+	filename := fmt.Sprintf("recommendations-%s-%s.csv", scope, time.Now().UTC().Format("20060102T150405Z"))
+
+	// Set response headers
+	w.Header().Set("Content-Type", formatMediaType(formatCSV))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Write response
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"namespace", "pod", "container", "resource", "unit", "current_request", "suggested_request", "waste_percent", "savings"})
+	for _, row := range append(cpuRows, memoryRows...) {
+		writer.Write([]string{
+			row.namespace,
+			row.pod,
+			row.container,
+			row.resource,
+			row.unit,
+			strconv.FormatFloat(row.current, 'f', -1, 64),
+			strconv.FormatFloat(row.suggested, 'f', -1, 64),
+			strconv.FormatFloat(row.wastePercentage, 'f', 1, 64),
+			strconv.FormatFloat(row.savings, 'f', -1, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing recommendations CSV: %v", err)
+	}
+}
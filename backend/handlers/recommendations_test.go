@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// usageAt returns datapoints with the given values, all age old relative to now
+func usageAt(now time.Time, age time.Duration, values ...float64) []k8s.DataPoint {
+	points := make([]k8s.DataPoint, len(values))
+	for i, value := range values {
+		points[i] = k8s.DataPoint{Timestamp: now.Add(-age), Value: value}
+	}
+	return points
+}
+
+func TestDecayedPercentile(t *testing.T) {
+	now := time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		points []k8s.DataPoint
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"equal weights", usageAt(now, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 9},
+		// Two of ten points at 10 reach the 90th percentile of equally weighted points...
+		{"recent spikes", usageAt(now, 0, 1, 1, 1, 1, 1, 1, 1, 1, 10, 10), 10},
+		// ...but three half-lives old they weigh 1/8 each: 8 of 8.25 is past 90%
+		{"decayed spikes", append(usageAt(now, 0, 1, 1, 1, 1, 1, 1, 1, 1), usageAt(now, 3*vpaHalfLife, 10, 10)...), 1},
+		{"single point", usageAt(now, time.Hour, 0.5), 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decayedPercentile(tt.points, vpaCPUPercentile, vpaHalfLife); got != tt.want {
+				t.Errorf("decayedPercentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recommendationTestMetrics is a container averaging 0.2 cores and 100Mi, spiking to 0.5 cores
+// in two of ten samples and to 200Mi
+func recommendationTestMetrics() k8s.HistoricalMetrics {
+	hm := k8s.HistoricalMetrics{PodName: "web-0", Namespace: "shop", ContainerName: "app"}
+	hm.CPU = k8s.HistoricalResourceData{
+		Average:  0.2,
+		Usage:    usageAt(time.Now(), 0, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.5, 0.5),
+		Requests: usageAt(time.Now(), 0, 1),
+	}
+	hm.Memory = k8s.HistoricalResourceData{
+		Average:  100 << 20,
+		Peak:     200 << 20,
+		Requests: usageAt(time.Now(), 0, 512<<20),
+	}
+	return hm
+}
+
+func TestRecommendRequests(t *testing.T) {
+	tests := []struct {
+		name                string
+		algorithm           string
+		headroom            recommendationHeadroom
+		wantCPU, wantMemory float64
+	}{
+		{"average", recommendationAverage, recommendationHeadroom{}, 0.2, 100 << 20},
+		{"average with headroom", recommendationAverage, recommendationHeadroom{cpu: 20, memory: 30}, 0.24, 130 << 20},
+		{"vpa", recommendationVPA, recommendationHeadroom{}, 0.5, 200 << 20},
+		{"vpa with headroom", recommendationVPA, recommendationHeadroom{cpu: 20, memory: 30}, 0.6, 260 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, memory := recommendRequests(recommendationTestMetrics(), tt.algorithm, tt.headroom)
+			if math.Abs(cpu-tt.wantCPU) > 1e-9 || math.Abs(memory-tt.wantMemory) > 1e-3 {
+				t.Errorf("recommendRequests() = %v, %v, want %v, %v", cpu, memory, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestGetPodRecommendationsAlgorithm(t *testing.T) {
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{recommendationTestMetrics()}}
+	tests := []struct {
+		name                string
+		target              string
+		wantAlgorithm       string
+		wantCPU, wantMemory float64
+	}{
+		{"default", "/api/pods/recommendations", recommendationAverage, 0.24, 130 << 20},
+		{"average", "/api/pods/recommendations?algorithm=average", recommendationAverage, 0.24, 130 << 20},
+		{"vpa", "/api/pods/recommendations?algorithm=vpa", recommendationVPA, 0.6, 260 << 20},
+		{"vpa without headroom", "/api/pods/recommendations?algorithm=vpa&cpuHeadroom=0&memoryHeadroom=0", recommendationVPA, 0.5, 200 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response models.ResourceRecommendationList
+			decodeResponse(t, serve(newTestHandler(client).GetPodRecommendations, tt.target), &response)
+			if response.Algorithm != tt.wantAlgorithm {
+				t.Errorf("algorithm = %q, want %q", response.Algorithm, tt.wantAlgorithm)
+			}
+			if len(response.Recommendations) != 1 {
+				t.Fatalf("recommendations = %d, want 1", len(response.Recommendations))
+			}
+			recommendation := response.Recommendations[0]
+			if recommendation.CPU.CurrentRequest != 1 || recommendation.Memory.CurrentRequest != 512<<20 {
+				t.Errorf("current requests = %v, %v, want 1, %v", recommendation.CPU.CurrentRequest, recommendation.Memory.CurrentRequest, 512<<20)
+			}
+			if math.Abs(recommendation.CPU.Recommended-tt.wantCPU) > 1e-9 || math.Abs(recommendation.Memory.Recommended-tt.wantMemory) > 1e-3 {
+				t.Errorf("recommended = %v, %v, want %v, %v", recommendation.CPU.Recommended, recommendation.Memory.Recommended, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+
+	for _, target := range []string{"/api/pods/recommendations?algorithm=p99", "/api/pods/recommendations?cpuHeadroom=-5"} {
+		if rec := serve(newTestHandler(client).GetPodRecommendations, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetPodRecommendationsCSV(t *testing.T) {
+	withWaste := func(pod string, cpuRequest, cpuWaste, memoryRequest, memoryWaste float64) k8s.HistoricalMetrics {
+		hm := recommendationTestMetrics()
+		hm.PodName = pod
+		hm.CPU.Requests = usageAt(time.Now(), 0, cpuRequest)
+		hm.Memory.Requests = usageAt(time.Now(), 0, memoryRequest)
+		hm.Analysis.ResourceWaste = k8s.ResourceWasteAnalysis{CPUWastePercentage: cpuWaste, MemoryWastePercentage: memoryWaste}
+		return hm
+	}
+	// CPU savings are 0.5, 0.4 and 1 cores, memory savings 100, 300 and 200 bytes
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{
+		withWaste("half-wasted", 1, 50, 1000, 10),
+		withWaste("mostly-wasted", 0.5, 80, 1000, 30),
+		withWaste("large-request", 4, 25, 1000, 20),
+	}}
+
+	rec := serve(newTestHandler(client).GetPodRecommendations, "/api/pods/recommendations?format=csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Errorf("Content-Type = %q, want text/csv", contentType)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+
+	wantHeader := []string{"namespace", "pod", "container", "resource", "unit", "current_request", "suggested_request", "waste_percent", "savings"}
+	if len(records) == 0 || !reflect.DeepEqual(records[0], wantHeader) {
+		t.Fatalf("header = %v, want %v", records, wantHeader)
+	}
+
+	tests := []struct {
+		resource, pod, savings string
+	}{
+		{"cpu", "large-request", "1"},
+		{"cpu", "half-wasted", "0.5"},
+		{"cpu", "mostly-wasted", "0.4"},
+		{"memory", "mostly-wasted", "300"},
+		{"memory", "large-request", "200"},
+		{"memory", "half-wasted", "100"},
+	}
+	rows := records[1:]
+	if len(rows) != len(tests) {
+		t.Fatalf("got %d rows, want %d: %v", len(rows), len(tests), rows)
+	}
+	for i, tt := range tests {
+		row := rows[i]
+		if row[3] != tt.resource || row[1] != tt.pod || row[8] != tt.savings {
+			t.Errorf("row %d = %s %s savings %s, want %s %s savings %s", i, row[3], row[1], row[8], tt.resource, tt.pod, tt.savings)
+		}
+	}
+}
+
+func TestNewHandlerHeadroomDefaults(t *testing.T) {
+	tests := []struct {
+		name                string
+		cpu, memory         string
+		wantCPU, wantMemory float64
+	}{
+		{"unset", "", "", defaultCPUHeadroomPercent, defaultMemoryHeadroomPercent},
+		{"configured", "10", "50", 10, 50},
+		{"no headroom", "0", "0", 0, 0},
+		{"negative", "-5", "-10", defaultCPUHeadroomPercent, defaultMemoryHeadroomPercent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CPU_HEADROOM_PERCENT", tt.cpu)
+			t.Setenv("MEMORY_HEADROOM_PERCENT", tt.memory)
+			h, err := NewHandler()
+			if err != nil {
+				t.Fatalf("NewHandler() error = %v", err)
+			}
+			if h.headroomDefaults.cpu != tt.wantCPU || h.headroomDefaults.memory != tt.wantMemory {
+				t.Errorf("headroom defaults = %+v, want cpu %v, memory %v", h.headroomDefaults, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestGetPodRecommendationsHeadroom(t *testing.T) {
+	// Average usage is 0.2 cores and 100Mi
+	client := &fakeMetricsClient{historical: []k8s.HistoricalMetrics{recommendationTestMetrics()}}
+	tests := []struct {
+		name                string
+		cpu, memory         float64 // configured defaults
+		query               string
+		wantCPU, wantMemory float64
+	}{
+		{"configured defaults", 20, 30, "", 0.24, 130 << 20},
+		{"larger configured margins", 50, 100, "", 0.3, 200 << 20},
+		{"cpu overridden per request", 20, 30, "&cpuHeadroom=100", 0.4, 130 << 20},
+		{"memory overridden per request", 20, 30, "&memoryHeadroom=0", 0.24, 100 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(client)
+			h.headroomDefaults = recommendationHeadroom{cpu: tt.cpu, memory: tt.memory}
+			var response models.ResourceRecommendationList
+			decodeResponse(t, serve(h.GetPodRecommendations, "/api/pods/recommendations?algorithm=average"+tt.query), &response)
+			if len(response.Recommendations) != 1 {
+				t.Fatalf("recommendations = %d, want 1", len(response.Recommendations))
+			}
+			recommendation := response.Recommendations[0]
+			if math.Abs(recommendation.CPU.Recommended-tt.wantCPU) > 1e-9 || math.Abs(recommendation.Memory.Recommended-tt.wantMemory) > 1e-3 {
+				t.Errorf("recommended = %v, %v, want %v, %v", recommendation.CPU.Recommended, recommendation.Memory.Recommended, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+}
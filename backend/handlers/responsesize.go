@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// defaultMaxResponseBytes caps serialized responses so an all-namespace analysis can't exhaust the memory
+// of the backend or the frontend
+const defaultMaxResponseBytes = 100 << 20
+
+var errResponseTooLarge = errors.New("response too large")
+
+// responseTooLargeMessage tells clients how to get a response below the size limit
+func responseTooLargeMessage(maxBytes int64) string {
+	return fmt.Sprintf("Response exceeds the maximum size of %d bytes - narrow the namespace or downsample with maxPoints", maxBytes)
+}
+
+// writeJSONLimited writes response as JSON, or 413 when it would exceed maxBytes (0 disables the limit)
+func writeJSONLimited(w http.ResponseWriter, response interface{}, maxBytes int64) {
+	writeJSONLimitedStatus(w, http.StatusOK, response, maxBytes)
+}
+
+// writeJSONLimitedStatus writes response as JSON with status, or 413 when it would exceed maxBytes
+// (0 disables the limit)
+func writeJSONLimitedStatus(w http.ResponseWriter, status int, response interface{}, maxBytes int64) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		log.Printf("WARN: Rejected %d byte response exceeding MAX_RESPONSE_BYTES (%d)", len(body), maxBytes)
+		http.Error(w, responseTooLargeMessage(maxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Terminate with a newline like json.Encoder
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// limitedWriter fails writes that would take the total written past limit (0 disables the limit),
+// so streamed responses stop at the size limit instead of growing unbounded
+type limitedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.written+int64(len(p)) > l.limit {
+		return 0, errResponseTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+func TestWriteJSONLimitedStatus(t *testing.T) {
+	response := map[string]string{"name": "nginx"} // {"name":"nginx"} is 16 bytes
+
+	tests := []struct {
+		name       string
+		status     int
+		maxBytes   int64
+		wantStatus int
+		wantBody   string
+	}{
+		{"within limit", http.StatusOK, 100, http.StatusOK, `{"name":"nginx"}` + "\n"},
+		{"exactly at limit", http.StatusOK, 16, http.StatusOK, `{"name":"nginx"}` + "\n"},
+		{"limit disabled", http.StatusOK, 0, http.StatusOK, `{"name":"nginx"}` + "\n"},
+		{"over limit", http.StatusOK, 10, http.StatusRequestEntityTooLarge, responseTooLargeMessage(10) + "\n"},
+		{"custom status", http.StatusAccepted, 100, http.StatusAccepted, `{"name":"nginx"}` + "\n"},
+		{"custom status over limit", http.StatusServiceUnavailable, 10, http.StatusRequestEntityTooLarge, responseTooLargeMessage(10) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeJSONLimitedStatus(rec, tt.status, response, tt.maxBytes)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestWriteJSONLimitedMarshalError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSONLimited(rec, map[string]any{"bad": make(chan int)}, 0)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var out strings.Builder
+	w := &limitedWriter{w: &out, limit: 8}
+
+	if _, err := w.Write([]byte("12345")); err != nil {
+		t.Fatalf("first write error = %v", err)
+	}
+	if _, err := w.Write([]byte("6789")); !errors.Is(err, errResponseTooLarge) {
+		t.Errorf("second write error = %v, want %v", err, errResponseTooLarge)
+	}
+	if out.String() != "12345" || w.written != 5 {
+		t.Errorf("written %q (%d bytes), want %q (5 bytes)", out.String(), w.written, "12345")
+	}
+}
+
+func TestGetHistoricalAnalysisResponseTooLarge(t *testing.T) {
+	// The 20 containers serialize to over 100KB with their 100 usage points each, and to 20KB downsampled to 2
+	var historical []k8s.HistoricalMetrics
+	for i := range 20 {
+		hm := k8s.HistoricalMetrics{PodName: fmt.Sprintf("web-%d", i), Namespace: "shop", ContainerName: "app"}
+		for j := range 100 {
+			hm.CPU.Usage = append(hm.CPU.Usage, k8s.DataPoint{Timestamp: time.Unix(int64(j)*300, 0), Value: 0.5})
+		}
+		historical = append(historical, hm)
+	}
+	client := &fakeMetricsClient{historical: historical}
+
+	tests := []struct {
+		name         string
+		target       string
+		maxBytes     int64
+		wantCode     int
+		wantTooLarge bool // whether the body reports the size limit
+	}{
+		{"json within the limit", "/api/pods/analysis", 0, http.StatusOK, false},
+		{"json over the limit", "/api/pods/analysis", 50000, http.StatusRequestEntityTooLarge, true},
+		{"json downsampled within the limit", "/api/pods/analysis?maxPoints=2", 50000, http.StatusOK, false},
+		{"ndjson over the limit before the first line", "/api/pods/analysis?format=ndjson", 100, http.StatusRequestEntityTooLarge, true},
+		{"ndjson stopped after some lines", "/api/pods/analysis?format=ndjson", 10000, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(client)
+			h.maxResponseBytes = tt.maxBytes
+			rec := serve(h.GetHistoricalAnalysis, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.maxBytes > 0 && tt.wantCode == http.StatusOK && !tt.wantTooLarge && int64(rec.Body.Len()) > tt.maxBytes {
+				t.Errorf("body is %d bytes, over the %d byte limit", rec.Body.Len(), tt.maxBytes)
+			}
+			if got := strings.Contains(rec.Body.String(), responseTooLargeMessage(tt.maxBytes)); got != tt.wantTooLarge {
+				t.Errorf("body reports the size limit = %v, want %v", got, tt.wantTooLarge)
+			}
+		})
+	}
+}
+
+func TestHealthResponseTooLarge(t *testing.T) {
+	h := newTestHandler(&fakeMetricsClient{})
+	h.maxResponseBytes = 10
+	rec := serve(h.Health, "/health")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if !strings.Contains(rec.Body.String(), responseTooLargeMessage(10)) {
+		t.Errorf("body = %q, want the size limit message", rec.Body.String())
+	}
+}
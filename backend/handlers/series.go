@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// Range accepted by the days parameter of the series endpoint
+const (
+	defaultSeriesDays = 7
+	maxSeriesDays     = 30
+)
+
+// GetPodSeries returns the raw datapoints of a single container metric, a lighter alternative
+// to the full analysis for charting
+func (h *Handler) GetPodSeries(w http.ResponseWriter, r *http.Request) {
+	metricsClient, ok := h.clientFor(w, r)
+	if !ok {
+		return
+	}
+	if metricsClient == nil {
+		http.Error(w, "Service unavailable - metrics client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	// Get parameters
+	namespace := namespaceParam(r)
+	podName := r.URL.Query().Get("pod")
+	container := r.URL.Query().Get("container")
+	if namespace == "" || podName == "" || container == "" {
+		http.Error(w, "namespace, pod and container parameters are required", http.StatusBadRequest)
+		return
+	}
+	if !validLabelValue(podName) || !validLabelValue(container) {
+		http.Error(w, "invalid pod or container name", http.StatusBadRequest)
+		return
+	}
+
+	metric := r.URL.Query().Get("metric")
+	if metric != k8s.SeriesCPU && metric != k8s.SeriesMemory {
+		http.Error(w, fmt.Sprintf("invalid metric parameter: %q (expected %s or %s)", metric, k8s.SeriesCPU, k8s.SeriesMemory), http.StatusBadRequest)
+		return
+	}
+
+	days := defaultSeriesDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSeriesDays {
+			http.Error(w, fmt.Sprintf("invalid days parameter: %s (expected 1 to %d)", value, maxSeriesDays), http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	// Optionally downsample the returned series
+	maxPoints, err := parseMaxPoints(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	end := time.Now()
+	start := end.Add(-time.Duration(days) * 24 * time.Hour)
+	points, err := metricsClient.GetContainerSeries(ctx, namespace, podName, container, metric, start, end)
+	if err != nil {
+		log.Printf("Error getting %s series from %s: %v", metric, metricsClient.GetClientType(), err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	modelPoints := convertDataPoints(points)
+	if maxPoints > 0 {
+		modelPoints = downsamplePoints(modelPoints, maxPoints)
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+
+	// Create response
+	response := models.ContainerSeries{
+		PodName:       podName,
+		Namespace:     namespace,
+		ContainerName: container,
+		Metric:        metric,
+		Days:          days,
+		Points:        modelPoints,
+		GeneratedAt:   end,
+	}
+
+	// Write response
+	writeJSONLimited(w, response, h.maxResponseBytes)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+func TestGetPodSeries(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	var query rangeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		query = rangeRequest{query: r.FormValue("query"), start: r.FormValue("start"), end: r.FormValue("end")}
+		mu.Unlock()
+		values := make([]string, 3)
+		for i := range values {
+			values[i] = "[" + strconv.FormatInt(start.Add(time.Duration(i)*5*time.Minute).Unix(), 10) + `,"` + strconv.Itoa(i+1) + `"]`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[` + strings.Join(values, ",") + `]}]}}`))
+	}))
+	defer server.Close()
+	client, err := k8s.NewVictoriaMetricsClient(k8s.MetricsClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewVictoriaMetricsClient() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantCode   int
+		wantMetric string // metric name in the range query
+		wantDays   int
+	}{
+		{"memory", "/api/pods/series?namespace=shop&pod=web-0&container=app&metric=memory", http.StatusOK, "container_memory_working_set_bytes", 7},
+		{"cpu over 2 days", "/api/pods/series?namespace=shop&pod=web-0&container=app&metric=cpu&days=2", http.StatusOK, "container_cpu_usage_seconds_total", 2},
+		{"missing container", "/api/pods/series?namespace=shop&pod=web-0&metric=cpu", http.StatusBadRequest, "", 0},
+		{"invalid metric", "/api/pods/series?namespace=shop&pod=web-0&container=app&metric=disk", http.StatusBadRequest, "", 0},
+		{"missing metric", "/api/pods/series?namespace=shop&pod=web-0&container=app", http.StatusBadRequest, "", 0},
+		{"too many days", "/api/pods/series?namespace=shop&pod=web-0&container=app&metric=cpu&days=31", http.StatusBadRequest, "", 0},
+		{"invalid pod", `/api/pods/series?namespace=shop&pod=web"&container=app&metric=cpu`, http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newTestHandler(client).GetPodSeries, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var response models.ContainerSeries
+			decodeResponse(t, rec, &response)
+			if response.Days != tt.wantDays || response.PodName != "web-0" || response.ContainerName != "app" {
+				t.Errorf("response = %s/%s over %d days, want web-0/app over %d", response.PodName, response.ContainerName, response.Days, tt.wantDays)
+			}
+			if len(response.Points) != 3 {
+				t.Fatalf("got %d points, want the 3 of the range result", len(response.Points))
+			}
+			for i, point := range response.Points {
+				if want := start.Add(time.Duration(i) * 5 * time.Minute); !point.Timestamp.Equal(want) || point.Value != float64(i+1) {
+					t.Errorf("point %d = %v at %s, want %d at %s", i, point.Value, point.Timestamp, i+1, want)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !strings.Contains(query.query, tt.wantMetric) || !strings.Contains(query.query, `pod="web-0"`) || !strings.Contains(query.query, `container="app"`) {
+				t.Errorf("range query = %s, want %s of shop/web-0/app", query.query, tt.wantMetric)
+			}
+			queryStart, _ := strconv.ParseInt(query.start, 10, 64)
+			queryEnd, _ := strconv.ParseInt(query.end, 10, 64)
+			if span := time.Duration(queryEnd-queryStart) * time.Second; span != time.Duration(tt.wantDays)*24*time.Hour {
+				t.Errorf("range spans %s, want %d days", span, tt.wantDays)
+			}
+		})
+	}
+}
+
+// rangeRequest records the parameters of a range query
+type rangeRequest struct {
+	query, start, end string
+}
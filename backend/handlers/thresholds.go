@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// thresholdSink receives the threshold crossings of each check
+type thresholdSink interface {
+	write(ctx context.Context, export models.ThresholdExport) error
+}
+
+// newThresholdSink returns a webhook sink for http(s) URLs, and otherwise a file sink for a path,
+// optionally given as a file:// URL
+func newThresholdSink(target string) (thresholdSink, error) {
+	switch {
+	case target == "":
+		return nil, errors.New("THRESHOLD_EXPORT_SINK is required when ENABLE_THRESHOLD_EXPORT is set")
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		return &webhookSink{url: target, httpClient: &http.Client{Timeout: 10 * time.Second}}, nil
+	default:
+		return &fileSink{path: strings.TrimPrefix(target, "file://")}, nil
+	}
+}
+
+// webhookSink posts each export as JSON
+type webhookSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (s *webhookSink) write(ctx context.Context, export models.ThresholdExport) error {
+	body, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post threshold crossings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("threshold export webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// fileSink appends each export to a file as a JSON line
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileSink) write(_ context.Context, export models.ThresholdExport) error {
+	line, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open threshold export file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write threshold export file: %w", err)
+	}
+	return f.Close()
+}
+
+// ThresholdExporter periodically checks the current usage of containers against CPU and memory
+// thresholds and writes the containers crossing them to a sink
+type ThresholdExporter struct {
+	metricsClient k8s.MetricsClient
+	sink          thresholdSink
+	cpuPercent    float64 // Of the limit, or the request without a limit; 0 disables the CPU check
+	memoryPercent float64 // Of the limit, or the request without a limit; 0 disables the memory check
+	interval      time.Duration
+	cooldown      time.Duration
+	// Decimals crossing percentages are rounded to, negative keeps full precision
+	floatPrecision int
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // crossing key -> last time it was exported
+}
+
+// NewThresholdExporter creates an exporter writing to sink, an http(s) webhook URL or a file path, every
+// interval, suppressing repeats of a crossing within cooldown. Crossing percentages are rounded to
+// floatPrecision decimals.
+func NewThresholdExporter(metricsClient k8s.MetricsClient, sink string, cpuPercent, memoryPercent float64, interval, cooldown time.Duration, floatPrecision int) (*ThresholdExporter, error) {
+	s, err := newThresholdSink(sink)
+	if err != nil {
+		return nil, err
+	}
+	return &ThresholdExporter{
+		metricsClient:  metricsClient,
+		sink:           s,
+		cpuPercent:     cpuPercent,
+		memoryPercent:  memoryPercent,
+		interval:       interval,
+		cooldown:       cooldown,
+		floatPrecision: floatPrecision,
+		lastSent:       make(map[string]time.Time),
+	}, nil
+}
+
+// Run checks the thresholds immediately and then every interval until ctx is cancelled
+func (e *ThresholdExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.check(ctx); err != nil {
+			log.Printf("Warning: threshold export failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check evaluates the current metrics once and exports any crossings outside their cooldown
+func (e *ThresholdExporter) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	metricsData, err := e.metricsClient.GetCurrentPodMetrics(ctx, "", "", now)
+	if err != nil {
+		return fmt.Errorf("failed to get pod metrics from %s: %w", e.metricsClient.GetClientType(), err)
+	}
+
+	crossings := e.collectCrossings(metricsData, now)
+	if len(crossings) == 0 {
+		return nil
+	}
+
+	export := models.ThresholdExport{
+		Text:        fmt.Sprintf("%d containers above their usage thresholds", len(crossings)),
+		Crossings:   crossings,
+		GeneratedAt: now,
+	}
+	if err := e.sink.write(ctx, export); err != nil {
+		return err
+	}
+	log.Printf("INFO: Exported %d threshold crossings", len(crossings))
+
+	// Only start the cooldown once the crossing was delivered
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, crossing := range crossings {
+		e.lastSent[crossingKey(crossing)] = now
+	}
+	return nil
+}
+
+// crossingKey identifies a threshold crossing for deduplication
+func crossingKey(crossing models.ThresholdCrossing) string {
+	return crossing.Namespace + "/" + crossing.Pod + "/" + crossing.Container + "/" + crossing.Resource
+}
+
+// collectCrossings returns the container resources whose usage crosses their threshold and that were
+// not exported within the cooldown
+func (e *ThresholdExporter) collectCrossings(metrics []k8s.PodMetric, now time.Time) []models.ThresholdCrossing {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var crossings []models.ThresholdCrossing
+	for _, metric := range metrics {
+		for _, c := range []struct {
+			resource              string
+			usage, request, limit float64
+			threshold             float64
+		}{
+			{"cpu", metric.CPUUsageCores(), metric.CPURequest, metric.CPULimit, e.cpuPercent},
+			{"memory", metric.MemoryUsage, metric.MemoryRequest, metric.MemoryLimit, e.memoryPercent},
+		} {
+			crossing, ok := thresholdCrossing(c.usage, c.request, c.limit, c.threshold)
+			if !ok {
+				continue
+			}
+			crossing.Pod = metric.Name
+			crossing.Namespace = metric.Namespace
+			crossing.Container = metric.ContainerName
+			crossing.Resource = c.resource
+			crossing.Percent = roundTo(crossing.Percent, e.floatPrecision)
+			if sent, ok := e.lastSent[crossingKey(crossing)]; ok && now.Sub(sent) < e.cooldown {
+				continue
+			}
+			crossings = append(crossings, crossing)
+		}
+	}
+
+	// Drop expired entries so the map doesn't grow with deleted pods
+	for key, sent := range e.lastSent {
+		if now.Sub(sent) >= e.cooldown {
+			delete(e.lastSent, key)
+		}
+	}
+
+	sort.Slice(crossings, func(i, j int) bool {
+		return crossingKey(crossings[i]) < crossingKey(crossings[j])
+	})
+	return crossings
+}
+
+// thresholdCrossing compares usage to threshold percent of the limit, or of the request without a limit,
+// reporting whether it is crossed. Without a threshold, limit or request nothing is crossed.
+func thresholdCrossing(usage, request, limit, threshold float64) (models.ThresholdCrossing, bool) {
+	reference, basis := limit, "limit"
+	if limit <= 0 {
+		reference, basis = request, "request"
+	}
+	if threshold <= 0 || reference <= 0 {
+		return models.ThresholdCrossing{}, false
+	}
+
+	percent := usage / reference * 100
+	if percent < threshold {
+		return models.ThresholdCrossing{}, false
+	}
+	return models.ThresholdCrossing{
+		Usage:     usage,
+		Reference: reference,
+		Basis:     basis,
+		Percent:   percent,
+		Threshold: threshold,
+	}, true
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+	"github.com/bean-stalk-k8s/backend/models"
+)
+
+// recordingSink is a mock threshold sink recording the exports written to it, failing with err when set
+type recordingSink struct {
+	mu      sync.Mutex
+	exports []models.ThresholdExport
+	err     error
+}
+
+func (s *recordingSink) write(_ context.Context, export models.ThresholdExport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.exports = append(s.exports, export)
+	return nil
+}
+
+// newTestThresholdExporter returns an exporter of the given metrics to a recording sink, with thresholds
+// of 80% CPU and 90% memory
+func newTestThresholdExporter(metrics []k8s.PodMetric, cooldown time.Duration) (*ThresholdExporter, *recordingSink) {
+	sink := &recordingSink{}
+	return &ThresholdExporter{
+		metricsClient:  &fakeMetricsClient{current: metrics},
+		sink:           sink,
+		cpuPercent:     80,
+		memoryPercent:  90,
+		interval:       time.Minute,
+		cooldown:       cooldown,
+		floatPrecision: 1,
+		lastSent:       make(map[string]time.Time),
+	}, sink
+}
+
+var thresholdTestData = []k8s.PodMetric{
+	// CPU at 90% of its limit
+	{Name: "web-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.45, CPULimit: 0.5, MemoryUsage: 100 << 20, MemoryLimit: 256 << 20},
+	// CPU in millicores at 120% of its request, without a limit
+	{Name: "web-0", Namespace: "shop", ContainerName: "sidecar", CPUUsage: 60, CPUUnit: k8s.CPUUnitMillicores, CPURequest: 0.05},
+	// Memory at 95% of its limit
+	{Name: "api-0", Namespace: "billing", ContainerName: "app", CPUUsage: 0.1, CPULimit: 1, MemoryUsage: 972.8 * (1 << 20), MemoryLimit: 1 << 30},
+	// Below both thresholds
+	{Name: "cache-0", Namespace: "shop", ContainerName: "app", CPUUsage: 0.1, CPULimit: 1, MemoryUsage: 100 << 20, MemoryLimit: 1 << 30},
+	// Without requests or limits nothing is crossed
+	{Name: "batch-0", Namespace: "shop", ContainerName: "app", CPUUsage: 4, MemoryUsage: 8 << 30},
+}
+
+func TestThresholdExporterCrossings(t *testing.T) {
+	exporter, sink := newTestThresholdExporter(thresholdTestData, time.Hour)
+	if err := exporter.check(context.Background()); err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+
+	if len(sink.exports) != 1 {
+		t.Fatalf("got %d exports, want 1", len(sink.exports))
+	}
+	export := sink.exports[0]
+	want := []models.ThresholdCrossing{
+		{Pod: "api-0", Namespace: "billing", Container: "app", Resource: "memory", Usage: 972.8 * (1 << 20), Reference: 1 << 30, Basis: "limit", Percent: 95, Threshold: 90},
+		{Pod: "web-0", Namespace: "shop", Container: "app", Resource: "cpu", Usage: 0.45, Reference: 0.5, Basis: "limit", Percent: 90, Threshold: 80},
+		{Pod: "web-0", Namespace: "shop", Container: "sidecar", Resource: "cpu", Usage: 0.06, Reference: 0.05, Basis: "request", Percent: 120, Threshold: 80},
+	}
+	if !reflect.DeepEqual(export.Crossings, want) {
+		t.Errorf("crossings = %+v, want %+v", export.Crossings, want)
+	}
+	if export.Text != "3 containers above their usage thresholds" {
+		t.Errorf("text = %q", export.Text)
+	}
+}
+
+func TestThresholdExporterSuppressesDuplicates(t *testing.T) {
+	exporter, sink := newTestThresholdExporter(thresholdTestData[:1], time.Hour)
+	ctx := context.Background()
+
+	// A failed delivery doesn't start the cooldown
+	sink.err = errors.New("sink unavailable")
+	if err := exporter.check(ctx); err == nil {
+		t.Fatal("check() error = nil with a failing sink")
+	}
+	sink.err = nil
+
+	for range 3 {
+		if err := exporter.check(ctx); err != nil {
+			t.Fatalf("check() error = %v", err)
+		}
+	}
+	if len(sink.exports) != 1 {
+		t.Fatalf("got %d exports of a repeated crossing within the cooldown, want 1", len(sink.exports))
+	}
+
+	// A new crossing is exported without the one still cooling down
+	exporter.metricsClient = &fakeMetricsClient{current: thresholdTestData[:3]}
+	if err := exporter.check(ctx); err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if len(sink.exports) != 2 || len(sink.exports[1].Crossings) != 2 {
+		t.Fatalf("exports = %+v, want a second export of the 2 new crossings", sink.exports)
+	}
+	for _, crossing := range sink.exports[1].Crossings {
+		if crossing.Pod == "web-0" && crossing.Container == "app" {
+			t.Errorf("crossing %+v exported again within the cooldown", crossing)
+		}
+	}
+
+	// Once the cooldown expires the crossing is exported again
+	for key := range exporter.lastSent {
+		exporter.lastSent[key] = time.Now().Add(-2 * time.Hour)
+	}
+	if err := exporter.check(ctx); err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if len(sink.exports) != 3 || len(sink.exports[2].Crossings) != 3 {
+		t.Errorf("exports = %+v, want a third export of all 3 crossings after the cooldown", sink.exports)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+// aggregateWorkloads combines the historical metrics of the replicas of each workload container into
+// one entry named after the workload: the owner in owners, keyed by namespace/pod, or the pod name
+// without its replica suffix for pods without one. At each timestamp, usage is averaged over the
+// replicas while requests and limits are summed, so efficiency compares the usage of a replica with
+// the resources reserved by the whole workload. Replicas replaced during the window, e.g. by a
+// rollout, are combined too. The entries are re-analyzed with analyzer, and the pods of each are
+// returned keyed by workloadKey.
+func aggregateWorkloads(historicalData []k8s.HistoricalMetrics, owners map[string]string, analyzer k8s.HistoricalAnalyzer) ([]k8s.HistoricalMetrics, map[string][]string) {
+	groups := make(map[string][]k8s.HistoricalMetrics)
+	names := make(map[string]string)
+	var keys []string
+	for _, hm := range historicalData {
+		name := owners[hm.Namespace+"/"+hm.PodName]
+		if name == "" {
+			name = workloadName(hm.PodName)
+		}
+		key := workloadKey(hm.Namespace, name, hm.ContainerName)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+			names[key] = name
+		}
+		groups[key] = append(groups[key], hm)
+	}
+
+	workloads := make([]k8s.HistoricalMetrics, 0, len(keys))
+	pods := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		replicas := groups[key]
+		workload := k8s.HistoricalMetrics{
+			PodName:             names[key],
+			Namespace:           replicas[0].Namespace,
+			ContainerName:       replicas[0].ContainerName,
+			Completed:           true,
+			InsufficientHistory: true,
+		}
+		series := make(map[string][][]k8s.DataPoint)
+		for _, hm := range replicas {
+			pods[key] = append(pods[key], hm.PodName)
+			series["cpuUsage"] = append(series["cpuUsage"], hm.CPU.Usage)
+			series["cpuRequests"] = append(series["cpuRequests"], hm.CPU.Requests)
+			series["cpuLimits"] = append(series["cpuLimits"], hm.CPU.Limits)
+			series["memoryUsage"] = append(series["memoryUsage"], hm.Memory.Usage)
+			series["memoryRequests"] = append(series["memoryRequests"], hm.Memory.Requests)
+			series["memoryLimits"] = append(series["memoryLimits"], hm.Memory.Limits)
+			workload.CPU.DataCompleteness = max(workload.CPU.DataCompleteness, hm.CPU.DataCompleteness)
+			workload.Memory.DataCompleteness = max(workload.Memory.DataCompleteness, hm.Memory.DataCompleteness)
+
+			workload.RestartCount += hm.RestartCount
+			workload.OOMKilled = workload.OOMKilled || hm.OOMKilled
+			// A crash loop building up in any replica is reported for the workload
+			if workload.RestartTrend == "" || hm.RestartTrend == "increasing" {
+				workload.RestartTrend = hm.RestartTrend
+			}
+			workload.Step = max(workload.Step, hm.Step)
+			workload.StepCoarsened = workload.StepCoarsened || hm.StepCoarsened
+			workload.Completed = workload.Completed && hm.Completed
+			if !hm.StartTime.IsZero() && (workload.StartTime.IsZero() || hm.StartTime.Before(workload.StartTime)) {
+				workload.StartTime = hm.StartTime
+			}
+			workload.InsufficientHistory = workload.InsufficientHistory && hm.InsufficientHistory
+		}
+		sort.Strings(pods[key])
+
+		workload.CPU.Usage = combineSeries(series["cpuUsage"], true)
+		workload.CPU.Requests = combineSeries(series["cpuRequests"], false)
+		workload.CPU.Limits = combineSeries(series["cpuLimits"], false)
+		workload.Memory.Usage = combineSeries(series["memoryUsage"], true)
+		workload.Memory.Requests = combineSeries(series["memoryRequests"], false)
+		workload.Memory.Limits = combineSeries(series["memoryLimits"], false)
+		workloads = append(workloads, analyzer.AnalyzeHistoricalMetrics(workload))
+	}
+	return workloads, pods
+}
+
+// podOwners returns the workload owning each pod from kube-state-metrics, keyed by namespace/pod, or
+// nil when the backend cannot look owners up, so that workloads are named after their pods instead
+func podOwners(ctx context.Context, metricsClient k8s.MetricsClient, namespace string, window time.Duration) map[string]string {
+	lister, ok := unscoped(metricsClient).(k8s.PodOwnerLister)
+	if !ok {
+		return nil
+	}
+	owners, err := lister.GetPodOwners(ctx, namespace, window)
+	if err != nil {
+		log.Printf("WARN: Failed to get pod owners from %s, grouping workloads by pod name: %v", metricsClient.GetClientType(), err)
+		return nil
+	}
+	return owners
+}
+
+// workloadKey identifies a workload container
+func workloadKey(namespace, workload, container string) string {
+	return namespace + "/" + workload + "/" + container
+}
+
+// combineSeries sums the values of series at each timestamp, or averages them over the series with a
+// point there when average is set, sorted by timestamp
+func combineSeries(series [][]k8s.DataPoint, average bool) []k8s.DataPoint {
+	type total struct {
+		sum   float64
+		count int
+	}
+	totals := make(map[time.Time]*total)
+	for _, points := range series {
+		for _, point := range points {
+			t := point.Timestamp.UTC()
+			if totals[t] == nil {
+				totals[t] = &total{}
+			}
+			totals[t].sum += point.Value
+			totals[t].count++
+		}
+	}
+
+	combined := make([]k8s.DataPoint, 0, len(totals))
+	for t, total := range totals {
+		value := total.sum
+		if average {
+			value /= float64(total.count)
+		}
+		combined = append(combined, k8s.DataPoint{Timestamp: t, Value: value})
+	}
+	sort.Slice(combined, func(i, j int) bool {
+		return combined[i].Timestamp.Before(combined[j].Timestamp)
+	})
+	return combined
+}
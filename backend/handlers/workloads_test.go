@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/bean-stalk-k8s/backend/k8s"
+)
+
+// passthroughAnalyzer returns historical metrics unchanged, so tests see the combined series
+type passthroughAnalyzer struct{}
+
+func (passthroughAnalyzer) AnalyzeHistoricalMetrics(hm k8s.HistoricalMetrics) k8s.HistoricalMetrics {
+	return hm
+}
+
+func TestAggregateWorkloads(t *testing.T) {
+	historicalData := []k8s.HistoricalMetrics{
+		replica("shop", "web-7d4b9c8f6d-x2k4p", []float64{0.1, 0.2}, []float64{100 << 20, 110 << 20}),
+		replica("shop", "web-7d4b9c8f6d-m9n8q", []float64{0.2, 0.3}, []float64{120 << 20, 130 << 20}),
+		replica("shop", "web-7d4b9c8f6d-z5t6w", []float64{0.3, 0.4}, []float64{140 << 20, 150 << 20}),
+		replica("shop", "api-0", []float64{0.5, 0.5}, []float64{200 << 20, 200 << 20}),
+	}
+
+	workloads, pods := aggregateWorkloads(historicalData, nil, passthroughAnalyzer{})
+	if len(workloads) != 2 {
+		t.Fatalf("got %d workloads, want 2: %+v", len(workloads), workloads)
+	}
+
+	web := workloads[0]
+	if web.PodName != "web" || web.Namespace != "shop" || web.ContainerName != "app" {
+		t.Fatalf("first workload = %s/%s/%s, want shop/web/app", web.Namespace, web.PodName, web.ContainerName)
+	}
+	wantPods := []string{"web-7d4b9c8f6d-m9n8q", "web-7d4b9c8f6d-x2k4p", "web-7d4b9c8f6d-z5t6w"}
+	if got := pods[workloadKey("shop", "web", "app")]; !reflect.DeepEqual(got, wantPods) {
+		t.Errorf("pods = %v, want %v", got, wantPods)
+	}
+
+	tests := []struct {
+		name string
+		got  []k8s.DataPoint
+		want []k8s.DataPoint
+	}{
+		{"cpu usage is averaged", web.CPU.Usage, points(0.2, 0.3)},
+		{"memory usage is averaged", web.Memory.Usage, points(120<<20, 130<<20)},
+		{"cpu requests are summed", web.CPU.Requests, points(1.5, 1.5)},
+		{"cpu limits are summed", web.CPU.Limits, points(3, 3)},
+		{"memory requests are summed", web.Memory.Requests, points(768<<20, 768<<20)},
+		{"memory limits are summed", web.Memory.Limits, points(1536<<20, 1536<<20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", tt.got, tt.want)
+			}
+			for i := range tt.got {
+				if !tt.got[i].Timestamp.Equal(tt.want[i].Timestamp) || !floatEqual(tt.got[i].Value, tt.want[i].Value) {
+					t.Errorf("datapoint %d = %v, want %v", i, tt.got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if api := workloads[1]; api.PodName != "api" || !reflect.DeepEqual(api.CPU.Requests, points(0.5, 0.5)) {
+		t.Errorf("single replica workload = %s with requests %v, want api with unchanged requests", api.PodName, api.CPU.Requests)
+	}
+}
+
+func TestAggregateWorkloadsOwners(t *testing.T) {
+	historicalData := []k8s.HistoricalMetrics{
+		replica("jobs", "sync-28000000-b4k2x", []float64{0.1}, []float64{1 << 20}),
+		replica("jobs", "sync-28000060-q7r8s", []float64{0.3}, []float64{3 << 20}),
+		replica("jobs", "worker-0", []float64{0.2}, []float64{2 << 20}),
+	}
+
+	tests := []struct {
+		name   string
+		owners map[string]string
+		want   map[string][]string
+	}{
+		{
+			name:   "pod names without owners",
+			owners: nil,
+			want: map[string][]string{
+				workloadKey("jobs", "sync-28000000", "app"): {"sync-28000000-b4k2x"},
+				workloadKey("jobs", "sync-28000060", "app"): {"sync-28000060-q7r8s"},
+				workloadKey("jobs", "worker", "app"):        {"worker-0"},
+			},
+		},
+		{
+			name: "owners take precedence over pod names",
+			owners: map[string]string{
+				"jobs/sync-28000000-b4k2x": "sync",
+				"jobs/sync-28000060-q7r8s": "sync",
+			},
+			want: map[string][]string{
+				workloadKey("jobs", "sync", "app"):   {"sync-28000000-b4k2x", "sync-28000060-q7r8s"},
+				workloadKey("jobs", "worker", "app"): {"worker-0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workloads, pods := aggregateWorkloads(historicalData, tt.owners, passthroughAnalyzer{})
+			if !reflect.DeepEqual(pods, tt.want) {
+				t.Errorf("pods = %v, want %v", pods, tt.want)
+			}
+			if len(workloads) != len(tt.want) {
+				t.Errorf("got %d workloads, want %d", len(workloads), len(tt.want))
+			}
+		})
+	}
+}
+
+// floatEqual reports whether a and b are equal within float rounding
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"fmt"
+	"time"
+)
+
+// completedPodsQuery returns the containers of pods in namespace whose kube-state-metrics completion
+// time falls within [start, end], evaluated at end
+func completedPodsQuery(queries QueryTemplates, namespace string, start, end time.Time) string {
+	namespaceFilter := namespaceMatcher(namespace)
+	window := promDuration(end.Sub(start))
+	return `group by (namespace, pod, container) (
+		last_over_time(` + queries.Selector(QueryContainerInfo, namespaceFilter, queries.BaseContainerFilter()) + `[` + window + `])
+		* on (namespace, pod) group_left()
+		group by (namespace, pod) (last_over_time(` + queries.Selector(QueryPodCompletionTime, namespaceFilter) + `[` + window + `]) >= ` + fmt.Sprint(start.Unix()) + `)
+	)`
+}
+
+// containerRef identifies a container of a pod
+type containerRef struct {
+	namespace, pod, container string
+}
+
+// groupCompletedPods groups container references into completed pods, ignoring duplicates
+func groupCompletedPods(refs []containerRef) []PodInfo {
+	var pods []PodInfo
+	index := make(map[string]int)
+	seen := make(map[containerRef]bool)
+	for _, ref := range refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		key := ref.namespace + "/" + ref.pod
+		if i, ok := index[key]; ok {
+			pods[i].Containers = append(pods[i].Containers, ref.container)
+			continue
+		}
+		index[key] = len(pods)
+		pods = append(pods, PodInfo{Name: ref.pod, Namespace: ref.namespace, Containers: []string{ref.container}, Completed: true})
+	}
+	return pods
+}
+
+// mergeCompletedPods appends the completed pods that are not among the active ones
+func mergeCompletedPods(active, completed []PodInfo) []PodInfo {
+	activeKeys := make(map[string]bool, len(active))
+	for _, pod := range active {
+		activeKeys[pod.Namespace+"/"+pod.Name] = true
+	}
+	for _, pod := range completed {
+		if !activeKeys[pod.Namespace+"/"+pod.Name] {
+			active = append(active, pod)
+		}
+	}
+	return active
+}
@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"fmt"
+	"time"
+)
+
+// CPUUnit is the unit of the CPU usage of the cpu_usage query template. cAdvisor's
+// container_cpu_usage_seconds_total is a counter of core-seconds, rated into cores, while the kubelet
+// summary API and metrics-server export gauges of the current usage, often in nanocores.
+type CPUUnit string
+
+// CPU usage units
+const (
+	CPUUnitCores      CPUUnit = "cores"
+	CPUUnitMillicores CPUUnit = "millicores"
+	CPUUnitNanocores  CPUUnit = "nanocores"
+)
+
+// cpuUnitCores is the number of cores in one of each unit
+var cpuUnitCores = map[CPUUnit]float64{
+	CPUUnitCores:      1,
+	CPUUnitMillicores: 1e-3,
+	CPUUnitNanocores:  1e-9,
+}
+
+// ParseCPUUnit parses a CPU usage unit, cores when empty
+func ParseCPUUnit(s string) (CPUUnit, error) {
+	if s == "" {
+		return CPUUnitCores, nil
+	}
+	unit := CPUUnit(s)
+	if _, ok := cpuUnitCores[unit]; !ok {
+		return "", fmt.Errorf("invalid CPU unit %q (expected cores, millicores or nanocores)", s)
+	}
+	return unit, nil
+}
+
+// ToCores converts a CPU usage value in unit to cores. Unknown and empty units are treated as cores.
+func (u CPUUnit) ToCores(value float64) float64 {
+	if factor, ok := cpuUnitCores[u]; ok {
+		return value * factor
+	}
+	return value
+}
+
+// gauge reports whether usage in unit is exported as a gauge of the current usage rather than as a
+// counter of core-seconds. Millicores and nanocores are only reported by gauges.
+func (u CPUUnit) gauge() bool {
+	return u == CPUUnitMillicores || u == CPUUnitNanocores
+}
+
+// usageQuery returns the PromQL expression of the CPU usage in unit of selector. Counters are rated
+// over window, while gauges are read as is, looking back over lookback, since rate() of a gauge is
+// meaningless.
+func (u CPUUnit) usageQuery(selector string, window, lookback time.Duration) string {
+	if u.gauge() {
+		return withLookback(selector, lookback)
+	}
+	return "rate(" + selector + "[" + promDuration(window) + "])"
+}
+
+// pointsToCores converts CPU usage datapoints in unit to cores, in place
+func (u CPUUnit) pointsToCores(points []DataPoint) []DataPoint {
+	for i := range points {
+		points[i].Value = u.ToCores(points[i].Value)
+	}
+	return points
+}
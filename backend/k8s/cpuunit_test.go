@@ -0,0 +1,225 @@
+package k8s
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCPUUnit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    CPUUnit
+		wantErr bool
+	}{
+		{"", CPUUnitCores, false},
+		{"cores", CPUUnitCores, false},
+		{"millicores", CPUUnitMillicores, false},
+		{"nanocores", CPUUnitNanocores, false},
+		{"Nanocores", "", true},
+		{"seconds", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCPUUnit(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCPUUnit(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCPUUnit(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUUnitToCores(t *testing.T) {
+	tests := []struct {
+		unit  CPUUnit
+		value float64
+	}{
+		{CPUUnitCores, 0.25},
+		{CPUUnitMillicores, 250},
+		{CPUUnitNanocores, 250_000_000},
+		{"", 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.unit), func(t *testing.T) {
+			if got := tt.unit.ToCores(tt.value); math.Abs(got-0.25) > 1e-12 {
+				t.Errorf("%q.ToCores(%v) = %v, want 0.25", tt.unit, tt.value, got)
+			}
+		})
+	}
+}
+
+func TestCPUUnitUsageQuery(t *testing.T) {
+	const selector = `container_cpu_usage_seconds_total{namespace="shop"}`
+	tests := []struct {
+		unit CPUUnit
+		want string
+	}{
+		{CPUUnitCores, "rate(" + selector + "[120s])"},
+		{"", "rate(" + selector + "[120s])"},
+		{CPUUnitMillicores, "last_over_time(" + selector + "[60s])"},
+		{CPUUnitNanocores, "last_over_time(" + selector + "[60s])"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.unit), func(t *testing.T) {
+			if got := tt.unit.usageQuery(selector, 2*time.Minute, time.Minute); got != tt.want {
+				t.Errorf("%q.usageQuery() = %q, want %q", tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCPUUnitExportedUsage checks the same usage exported as a core-seconds counter and as
+// millicores and nanocores gauges evaluates to identical datapoints in cores
+func TestCPUUnitExportedUsage(t *testing.T) {
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-time.Hour)
+
+	// A counter growing by 0.5 core-seconds per second, scraped every 30s
+	var coreSeconds []RemoteReadSample
+	for ts := start.Add(-10 * time.Minute); !ts.After(end); ts = ts.Add(30 * time.Second) {
+		coreSeconds = append(coreSeconds, RemoteReadSample{Timestamp: ts.UnixMilli(), Value: 0.5 * ts.Sub(start).Seconds()})
+	}
+	// The same usage as a gauge, e.g. kubelet summary API usageNanoCores
+	gauge := func(factor float64) []RemoteReadSample {
+		samples := make([]RemoteReadSample, len(coreSeconds))
+		for i, s := range coreSeconds {
+			samples[i] = RemoteReadSample{Timestamp: s.Timestamp, Value: 0.5 * factor}
+		}
+		return samples
+	}
+
+	series := func(unit CPUUnit, samples []RemoteReadSample) []DataPoint {
+		t.Helper()
+		vm := &VictoriaMetricsClient{config: MetricsClientConfig{
+			ScrapeInterval: 30 * time.Second,
+			Queries:        QueryTemplates{CPUUnit: unit},
+		}}
+		data := vmExportData{TimingCPUUsage: {"shop/web-1/app": samples}}
+		ctx := context.WithValue(context.Background(), vmExportKey{}, data)
+		points, err := vm.rangeSeries(ctx, TimingCPUUsage, "", "shop", "web-1", "app", start, end)
+		if err != nil {
+			t.Fatalf("rangeSeries(%s) error = %v", unit, err)
+		}
+		return points
+	}
+
+	want := series(CPUUnitCores, coreSeconds)
+	if len(want) == 0 {
+		t.Fatal("rangeSeries(cores) returned no datapoints")
+	}
+	for _, p := range want {
+		if math.Abs(p.Value-0.5) > 1e-9 {
+			t.Fatalf("cores datapoint at %s = %v, want 0.5", p.Timestamp, p.Value)
+		}
+	}
+
+	tests := []struct {
+		unit   CPUUnit
+		factor float64
+	}{
+		{CPUUnitMillicores, 1e3},
+		{CPUUnitNanocores, 1e9},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.unit), func(t *testing.T) {
+			got := series(tt.unit, gauge(tt.factor))
+			if len(got) != len(want) {
+				t.Fatalf("got %d datapoints, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if !got[i].Timestamp.Equal(want[i].Timestamp) || math.Abs(got[i].Value-want[i].Value) > 1e-9 {
+					t.Errorf("datapoint %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestActivePodsQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MetricsClientConfig
+		want   []string
+	}{
+		{
+			name:   "default rate window",
+			config: MetricsClientConfig{},
+			want:   []string{"[300s]"},
+		},
+		{
+			name:   "scrape interval and lookback",
+			config: MetricsClientConfig{ScrapeInterval: 15 * time.Second, InstantLookback: 30 * time.Second},
+			want:   []string{"[90s]"},
+		},
+		{
+			name:   "nanocores",
+			config: MetricsClientConfig{ScrapeInterval: 30 * time.Second, InstantLookback: 45 * time.Second, Queries: QueryTemplates{CPUUnit: CPUUnitNanocores}},
+			want:   []string{"last_over_time(", "[45s]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := activePodsQuery(tt.config, "shop")
+			if !strings.HasPrefix(got, "group by (pod, namespace, container) (") {
+				t.Errorf("activePodsQuery() = %q, want a group by query", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("activePodsQuery() = %q, want it to contain %q", got, want)
+				}
+			}
+			if strings.Contains(got, "[5m]") {
+				t.Errorf("activePodsQuery() = %q still hardcodes [5m]", got)
+			}
+		})
+	}
+}
+
+// TestCPUUnitCurrentUsage checks current usage read in cores and as a nanocores gauge reports
+// the same usage in cores, and that the gauge is read without rate()
+func TestCPUUnitCurrentUsage(t *testing.T) {
+	tests := []struct {
+		unit  CPUUnit
+		value string
+	}{
+		{CPUUnitCores, "0.25"},
+		{CPUUnitNanocores, "250000000"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.unit), func(t *testing.T) {
+			var rated bool
+			server := newVMServer(t, func(r *http.Request) string {
+				query := r.URL.Query().Get("query")
+				if !strings.Contains(query, "container_cpu_usage_seconds_total") {
+					return ""
+				}
+				rated = rated || strings.Contains(query, "rate(")
+				return `[{"metric":{"namespace":"shop","pod":"web-1","container":"app"},"value":[0,"` + tt.value + `"]}]`
+			})
+			vm := newTestVMClient(t, server, MetricsClientConfig{Queries: QueryTemplates{CPUUnit: tt.unit}})
+
+			pods, err := vm.GetCurrentPodMetrics(context.Background(), "shop", "", time.Now())
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if len(pods) != 1 {
+				t.Fatalf("GetCurrentPodMetrics() returned %d pods, want 1", len(pods))
+			}
+			if pods[0].CPUUnit != tt.unit {
+				t.Errorf("CPUUnit = %q, want %q", pods[0].CPUUnit, tt.unit)
+			}
+			if got := pods[0].CPUUsageCores(); math.Abs(got-0.25) > 1e-12 {
+				t.Errorf("CPUUsageCores() = %v, want 0.25", got)
+			}
+			if wantRate := tt.unit == CPUUnitCores; rated != wantRate {
+				t.Errorf("CPU query used rate() = %v, want %v", rated, wantRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BackendAuto selects the backend by probing the configured URL
+const BackendAuto = "auto"
+
+// Backend flavors recognized by DetectBackend. Thanos and Mimir serve the Prometheus API.
+const (
+	flavorPrometheus      = "prometheus"
+	flavorVictoriaMetrics = "victoriametrics"
+	flavorThanos          = "thanos"
+	flavorMimir           = "mimir"
+)
+
+// detectTimeout bounds each detection probe
+const detectTimeout = 5 * time.Second
+
+// DetectBackend probes the URL in config to tell VictoriaMetrics, Prometheus, Thanos and Mimir apart
+// and returns the backend serving it: "victoriametrics" or "prometheus". Ambiguous or unreachable
+// URLs fall back to VictoriaMetrics. The detection is logged.
+func DetectBackend(ctx context.Context, config MetricsClientConfig) string {
+	client := &http.Client{Timeout: detectTimeout, Transport: newTransport(config)}
+	baseURL := strings.TrimSuffix(config.URL, "/")
+
+	flavor := detectFlavor(ctx, client, baseURL)
+	backend := flavorVictoriaMetrics
+	switch flavor {
+	case flavorPrometheus, flavorThanos, flavorMimir:
+		backend = "prometheus"
+	case "":
+		log.Printf("WARN: Could not detect the metrics backend at %s, falling back to victoriametrics", config.URL)
+		return backend
+	}
+
+	log.Printf("INFO: Detected %s at %s, using the %s backend", flavor, config.URL, backend)
+	return backend
+}
+
+// detectFlavor returns the flavor of the server at baseURL, or "" when it can't be told
+func detectFlavor(ctx context.Context, client *http.Client, baseURL string) string {
+	// Only VictoriaMetrics serves the top queries statistics
+	var topQueries struct {
+		TopByCount json.RawMessage `json:"topByCount"`
+	}
+	if probeJSON(ctx, client, baseURL+"/api/v1/status/top_queries", &topQueries) && topQueries.TopByCount != nil {
+		return flavorVictoriaMetrics
+	}
+
+	// Prometheus and Mimir report build information, Mimir naming itself as the application
+	var buildInfo struct {
+		Status string `json:"status"`
+		Data   struct {
+			Application string `json:"application"`
+			Version     string `json:"version"`
+		} `json:"data"`
+	}
+	if probeJSON(ctx, client, baseURL+"/api/v1/status/buildinfo", &buildInfo) && buildInfo.Status == "success" {
+		if strings.Contains(strings.ToLower(buildInfo.Data.Application), "mimir") {
+			return flavorMimir
+		}
+		if buildInfo.Data.Version != "" {
+			return flavorPrometheus
+		}
+	}
+
+	// Thanos Query lists its store endpoints
+	var stores struct {
+		Status string `json:"status"`
+	}
+	if probeJSON(ctx, client, baseURL+"/api/v1/stores", &stores) && stores.Status == "success" {
+		return flavorThanos
+	}
+	return ""
+}
+
+// probeJSON reports whether GET url succeeds with a JSON body, decoding it into result
+func probeJSON(ctx context.Context, client *http.Client, url string, result interface{}) bool {
+	ctx, cancel := context.WithTimeout(ctx, detectTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	return json.NewDecoder(resp.Body).Decode(result) == nil
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectBackend(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoints  map[string]string // path -> JSON body, other paths return 404
+		wantFlavor string
+		want       string
+	}{
+		{
+			name: "victoriametrics",
+			endpoints: map[string]string{
+				"/api/v1/status/top_queries": `{"topByCount":[],"topByAvgDuration":[]}`,
+				"/api/v1/status/buildinfo":   `{"status":"success","data":{"version":"2.24.0"}}`,
+			},
+			wantFlavor: flavorVictoriaMetrics,
+			want:       "victoriametrics",
+		},
+		{
+			name: "prometheus",
+			endpoints: map[string]string{
+				"/api/v1/status/buildinfo": `{"status":"success","data":{"version":"2.53.0","revision":"abc"}}`,
+			},
+			wantFlavor: flavorPrometheus,
+			want:       "prometheus",
+		},
+		{
+			name: "mimir",
+			endpoints: map[string]string{
+				"/api/v1/status/buildinfo": `{"status":"success","data":{"application":"Grafana Mimir","version":"2.13.0"}}`,
+			},
+			wantFlavor: flavorMimir,
+			want:       "prometheus",
+		},
+		{
+			name: "thanos",
+			endpoints: map[string]string{
+				"/api/v1/stores": `{"status":"success","data":{"sidecar":[]}}`,
+			},
+			wantFlavor: flavorThanos,
+			want:       "prometheus",
+		},
+		{
+			name:       "ambiguous",
+			endpoints:  map[string]string{"/api/v1/status/buildinfo": `{"status":"success","data":{}}`},
+			wantFlavor: "",
+			want:       "victoriametrics",
+		},
+		{
+			name:       "not json",
+			endpoints:  map[string]string{"/api/v1/status/buildinfo": `<html></html>`},
+			wantFlavor: "",
+			want:       "victoriametrics",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.endpoints[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			if got := detectFlavor(context.Background(), server.Client(), server.URL); got != tt.wantFlavor {
+				t.Errorf("detectFlavor() = %q, want %q", got, tt.wantFlavor)
+			}
+			if got := DetectBackend(context.Background(), MetricsClientConfig{URL: server.URL + "/"}); got != tt.want {
+				t.Errorf("DetectBackend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// An unreachable URL falls back to VictoriaMetrics
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	if got := DetectBackend(context.Background(), MetricsClientConfig{URL: server.URL}); got != "victoriametrics" {
+		t.Errorf("DetectBackend() of an unreachable URL = %q, want victoriametrics", got)
+	}
+}
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"context"
+	"math"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// isFinite reports whether v is neither NaN nor ±Inf
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// finiteAPI drops NaN and ±Inf samples, e.g. from divisions by zero, from Prometheus query results
+// so they never reach averages, percentiles or the JSON responses
+type finiteAPI struct {
+	v1.API
+}
+
+func (f finiteAPI) Query(ctx context.Context, query string, ts time.Time, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	result, warnings, err := f.API.Query(ctx, query, ts, opts...)
+	return dropNonFinite(result), warnings, err
+}
+
+func (f finiteAPI) QueryRange(ctx context.Context, query string, r v1.Range, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	result, warnings, err := f.API.QueryRange(ctx, query, r, opts...)
+	return dropNonFinite(result), warnings, err
+}
+
+// dropNonFinite removes the NaN and ±Inf samples of a query result, dropping series left without samples
+func dropNonFinite(value model.Value) model.Value {
+	switch v := value.(type) {
+	case model.Vector:
+		finite := v[:0]
+		for _, sample := range v {
+			if isFinite(float64(sample.Value)) {
+				finite = append(finite, sample)
+			}
+		}
+		return finite
+	case model.Matrix:
+		finite := v[:0]
+		for _, stream := range v {
+			values := stream.Values[:0]
+			for _, pair := range stream.Values {
+				if isFinite(float64(pair.Value)) {
+					values = append(values, pair)
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+			stream.Values = values
+			finite = append(finite, stream)
+		}
+		return finite
+	case *model.Scalar:
+		if v != nil && !isFinite(float64(v.Value)) {
+			v.Value = 0
+		}
+		return v
+	}
+	return value
+}
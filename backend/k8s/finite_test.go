@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestDropNonFinite(t *testing.T) {
+	nan, inf := model.SampleValue(math.NaN()), model.SampleValue(math.Inf(1))
+	sample := func(pod string, value model.SampleValue) *model.Sample {
+		return &model.Sample{Metric: model.Metric{"pod": model.LabelValue(pod)}, Value: value}
+	}
+	stream := func(pod string, values ...model.SampleValue) *model.SampleStream {
+		pairs := make([]model.SamplePair, len(values))
+		for i, value := range values {
+			pairs[i] = model.SamplePair{Timestamp: model.Time(i * 1000), Value: value}
+		}
+		return &model.SampleStream{Metric: model.Metric{"pod": model.LabelValue(pod)}, Values: pairs}
+	}
+
+	tests := []struct {
+		name  string
+		value model.Value
+		want  model.Value
+	}{
+		{
+			name:  "vector",
+			value: model.Vector{sample("web-0", 1), sample("web-1", nan), sample("web-2", inf), sample("web-3", -inf)},
+			want:  model.Vector{sample("web-0", 1)},
+		},
+		{
+			name:  "matrix",
+			value: model.Matrix{stream("web-0", 1, nan, 2, inf), stream("web-1", nan, -inf)},
+			want:  model.Matrix{{Metric: model.Metric{"pod": "web-0"}, Values: []model.SamplePair{{Timestamp: 0, Value: 1}, {Timestamp: 2000, Value: 2}}}},
+		},
+		{
+			name:  "finite scalar",
+			value: &model.Scalar{Value: 3},
+			want:  &model.Scalar{Value: 3},
+		},
+		{
+			name:  "NaN scalar",
+			value: &model.Scalar{Value: nan},
+			want:  &model.Scalar{Value: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dropNonFinite(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dropNonFinite() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentPodMetricsNonFinite(t *testing.T) {
+	series := func(value string) string {
+		return `[{"metric":{"namespace":"shop","pod":"web-0","container":"app","resource":"cpu"},"value":[1700000000,"` + value + `"]}]`
+	}
+	for _, nonFinite := range []string{"NaN", "+Inf", "-Inf"} {
+		respond := func(query string) string {
+			switch {
+			case strings.Contains(query, "timestamp("):
+				return ""
+			case strings.Contains(query, "container_cpu_usage_seconds_total"):
+				return series(nonFinite)
+			case strings.Contains(query, "container_memory_working_set_bytes"):
+				return series("100")
+			case strings.Contains(query, "kube_pod_container_resource_requests") && strings.Contains(query, `resource="cpu"`):
+				return series(nonFinite)
+			}
+			return ""
+		}
+		clients := map[string]func(t *testing.T) MetricsClient{
+			"prometheus": func(t *testing.T) MetricsClient {
+				return newTestPrometheusClient(t, newPromServer(t, respond), MetricsClientConfig{})
+			},
+			"victoriametrics": func(t *testing.T) MetricsClient {
+				return newTestVMClient(t, newVMServer(t, func(r *http.Request) string { return respond(r.URL.Query().Get("query")) }), MetricsClientConfig{})
+			},
+		}
+		for client, newClient := range clients {
+			t.Run(client+"/"+nonFinite, func(t *testing.T) {
+				metrics, err := newClient(t).GetCurrentPodMetrics(context.Background(), "shop", "", time.Now())
+				if err != nil {
+					t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+				}
+				if len(metrics) != 1 {
+					t.Fatalf("GetCurrentPodMetrics() = %d containers, want 1", len(metrics))
+				}
+				metric := metrics[0]
+				if metric.CPUUsage != 0 || metric.CPURequest != 0 || metric.MemoryUsage != 100 {
+					t.Errorf("cpu=%v cpu request=%v memory=%v, want the %s samples dropped and memory 100", metric.CPUUsage, metric.CPURequest, metric.MemoryUsage, nonFinite)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// querySemaphore limits the backend queries in flight across every client created by a factory
+type querySemaphore chan struct{}
+
+// acquire blocks until a slot is free or ctx is done
+func (s querySemaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s querySemaphore) release() {
+	<-s
+}
+
+// inflightTransport holds a semaphore slot from sending a backend request until its response body is closed
+type inflightTransport struct {
+	slots querySemaphore
+	next  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *inflightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.slots.acquire(req.Context()); err != nil {
+		return nil, fmt.Errorf("waiting for a backend query slot: %w", err)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.slots.release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: sync.OnceFunc(t.slots.release)}
+	return resp, nil
+}
+
+// releasingBody frees its semaphore slot once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInflightQueryLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+	}{
+		{"one", 1},
+		{"three", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var current, peak atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := current.Add(1)
+				defer current.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			}))
+			defer server.Close()
+
+			// The limit is shared by every client of the factory, whatever its backend
+			factory := NewMetricsClientFactory()
+			var clients []MetricsClient
+			for _, backend := range []string{"prometheus", "victoriametrics"} {
+				client, err := factory.CreateClient(MetricsClientConfig{Backend: backend, URL: server.URL, MaxInflightQueries: tt.limit})
+				if err != nil {
+					t.Fatalf("CreateClient(%s) error = %v", backend, err)
+				}
+				clients = append(clients, client)
+			}
+
+			end := time.Now()
+			var wg sync.WaitGroup
+			for i := range 12 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					client := clients[i%len(clients)]
+					if _, err := client.GetContainerSeries(context.Background(), "shop", "web-0", "app", SeriesMemory, end.Add(-time.Hour), end); err != nil {
+						t.Errorf("GetContainerSeries() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := peak.Load(); got > int32(tt.limit) {
+				t.Errorf("peak in-flight queries = %d, want at most %d", got, tt.limit)
+			}
+		})
+	}
+}
+
+func TestInflightQueryContext(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	// Every slot is taken, so queries wait until their context is done
+	slots := make(querySemaphore, 1)
+	slots.acquire(context.Background())
+	client := &http.Client{Transport: &inflightTransport{slots: slots, next: http.DefaultTransport}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("server received %d requests, want none while the slot is taken", got)
+	}
+
+	// Once released, the slot is held until the response body is closed
+	slots.release()
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if len(slots) != 1 {
+		t.Errorf("%d slots taken with an open response body, want 1", len(slots))
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	if len(slots) != 0 {
+		t.Errorf("%d slots taken after closing the response body twice, want 0", len(slots))
+	}
+}
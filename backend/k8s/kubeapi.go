@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// In-cluster service account credentials, used when no bearer token or CA is configured
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// KubernetesClient reads objects from the Kubernetes API server
+type KubernetesClient struct {
+	clientset  kubernetes.Interface
+	restConfig *rest.Config // For the clientsets of other API groups, e.g. metrics.k8s.io
+}
+
+// NewKubernetesClient creates a new client for the Kubernetes API server at config.URL. Without a
+// configured bearer token or TLS configuration, the in-cluster service account token and CA are used.
+func NewKubernetesClient(config MetricsClientConfig) (*KubernetesClient, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("Kubernetes API server URL is required")
+	}
+
+	if config.TLSConfig == nil {
+		if _, err := os.Stat(serviceAccountCAFile); err == nil {
+			tlsConfig, err := LoadTLSConfig(serviceAccountCAFile, "", "", false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load service account CA: %w", err)
+			}
+			config.TLSConfig = tlsConfig
+		}
+	}
+
+	// The transport carries the TLS configuration and bearer token, like the other backends'
+	restConfig := &rest.Config{Host: config.URL, Transport: newTransport(config)}
+	if config.BearerToken == "" {
+		// The token file is rotated by the kubelet, so client-go rereads it
+		if _, err := os.Stat(serviceAccountTokenFile); err == nil {
+			restConfig.BearerTokenFile = serviceAccountTokenFile
+		}
+	}
+	return newKubernetesClientForConfig(restConfig)
+}
+
+// NewKubernetesClientFromKubeconfig creates a new client for the API server of a kubeconfig context,
+// with the context's credentials. The kubeconfig is loaded from KUBECONFIG or ~/.kube/config, and an
+// empty kubeContext selects the current context.
+func NewKubernetesClientFromKubeconfig(kubeContext string) (*KubernetesClient, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig context %q: %w", kubeContext, err)
+	}
+
+	client, err := newKubernetesClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure kubeconfig context %q: %w", kubeContext, err)
+	}
+	return client, nil
+}
+
+// newKubernetesClientForConfig creates a new client from a REST configuration. Client-side rate
+// limiting is disabled, as for the other backends; MaxInflightQueries bounds the load instead.
+func newKubernetesClientForConfig(restConfig *rest.Config) (*KubernetesClient, error) {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.QPS = -1
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesClient{clientset: clientset, restConfig: restConfig}, nil
+}
+
+// listPods lists the pods in namespace, all namespaces when empty
+func (k *KubernetesClient) listPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	list, err := k.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	return list.Items, nil
+}
+
+// GetRunningPods returns the names of the running pods in namespace, all namespaces when empty,
+// keyed by namespace
+func (k *KubernetesClient) GetRunningPods(ctx context.Context, namespace string) (map[string][]string, error) {
+	pods, err := k.listPods(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string][]string)
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning {
+			running[pod.Namespace] = append(running[pod.Namespace], pod.Name)
+		}
+	}
+	return running, nil
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewKubernetesClientFromKubeconfig(t *testing.T) {
+	t.Setenv("KUBECONFIG", filepath.Join("testdata", "kubeconfig"))
+
+	tests := []struct {
+		name        string
+		kubeContext string
+		wantURL     string
+		wantErr     bool
+	}{
+		{"current context", "", "https://dev.example.com:6443", false},
+		{"selected context", "staging", "https://staging.example.com:6443", false},
+		{"unknown context", "prod", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewKubernetesClientFromKubeconfig(tt.kubeContext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKubernetesClientFromKubeconfig(%q) error = %v, wantErr %v", tt.kubeContext, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if host := strings.TrimSuffix(client.restConfig.Host, "/"); host != tt.wantURL {
+				t.Errorf("host = %q, want %q", host, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestKubeconfigContextCredentials(t *testing.T) {
+	var gotAuth, gotPath string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotPath = r.Header.Get("Authorization"), r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items": [{"metadata": {"name": "web-1", "namespace": "shop"}, "status": {"phase": "Running"}}]}`)
+	}))
+	defer server.Close()
+
+	// The current context points elsewhere, so reaching the server shows the selected context is used.
+	// clientcmd only sends credentials over TLS.
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	content := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: other
+clusters:
+- name: local
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+- name: other
+  cluster:
+    server: https://other.invalid
+users:
+- name: local
+  user:
+    token: local-token
+contexts:
+- name: local
+  context:
+    cluster: local
+    user: local
+- name: other
+  context:
+    cluster: other
+    user: local
+`, server.URL)
+	if err := os.WriteFile(kubeconfig, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	client, err := NewKubernetesClientFromKubeconfig("local")
+	if err != nil {
+		t.Fatalf("NewKubernetesClientFromKubeconfig() error = %v", err)
+	}
+	running, err := client.GetRunningPods(context.Background(), "shop")
+	if err != nil {
+		t.Fatalf("GetRunningPods() error = %v", err)
+	}
+
+	if want := map[string][]string{"shop": {"web-1"}}; !reflect.DeepEqual(running, want) {
+		t.Errorf("GetRunningPods() = %v, want %v", running, want)
+	}
+	if gotPath != "/api/v1/namespaces/shop/pods" {
+		t.Errorf("request path = %q, want /api/v1/namespaces/shop/pods", gotPath)
+	}
+	if gotAuth != "Bearer local-token" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer local-token")
+	}
+}
+
+func TestGetRunningPods(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items": [
+			{"metadata": {"name": "web-0", "namespace": "shop"}, "status": {"phase": "Running"}},
+			{"metadata": {"name": "web-1", "namespace": "shop"}, "status": {"phase": "Pending"}},
+			{"metadata": {"name": "migrate-x7k2p", "namespace": "shop"}, "status": {"phase": "Succeeded"}},
+			{"metadata": {"name": "api-0", "namespace": "billing"}, "status": {"phase": "Running"}},
+			{"metadata": {"name": "api-1", "namespace": "billing"}, "status": {"phase": "Failed"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		namespace string
+		wantPath  string
+	}{
+		{"", "/api/v1/pods"},
+		{"shop", "/api/v1/namespaces/shop/pods"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantPath, func(t *testing.T) {
+			client, err := NewKubernetesClient(MetricsClientConfig{URL: server.URL, BearerToken: "test"})
+			if err != nil {
+				t.Fatalf("NewKubernetesClient() error = %v", err)
+			}
+			running, err := client.GetRunningPods(context.Background(), tt.namespace)
+			if err != nil {
+				t.Fatalf("GetRunningPods() error = %v", err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("request path = %q, want %q", gotPath, tt.wantPath)
+			}
+			want := map[string][]string{"shop": {"web-0"}, "billing": {"api-0"}}
+			if !reflect.DeepEqual(running, want) {
+				t.Errorf("GetRunningPods() = %v, want %v", running, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Aggregations used to merge duplicate series for the same namespace/pod/container,
+// e.g. from HA Prometheus replicas or multiple scrape jobs
+const (
+	MergeMax = "max"
+	MergeMin = "min"
+	MergeAvg = "avg"
+)
+
+// seriesMerger merges duplicate samples for the same key into a single value
+type seriesMerger struct {
+	aggregation string
+	seen        map[string]int
+}
+
+// newSeriesMerger creates a merger for one metric, defaulting to MergeMax
+func newSeriesMerger(aggregation string) *seriesMerger {
+	if aggregation != MergeMin && aggregation != MergeAvg {
+		aggregation = MergeMax
+	}
+	return &seriesMerger{aggregation: aggregation, seen: make(map[string]int)}
+}
+
+// merge stores value into field, combining it with any earlier sample for the same key
+func (m *seriesMerger) merge(key string, field *float64, value float64) {
+	n := m.seen[key]
+	m.seen[key] = n + 1
+	if n == 0 {
+		*field = value
+		return
+	}
+
+	switch m.aggregation {
+	case MergeMin:
+		if value < *field {
+			*field = value
+		}
+	case MergeAvg:
+		*field += (value - *field) / float64(n+1)
+	default:
+		if value > *field {
+			*field = value
+		}
+	}
+}
+
+// mergeSeriesPoints combines the series returned by a range query that should have returned one, e.g.
+// duplicates from HA replicas or multiple scrape jobs, into a single series ordered by timestamp.
+// Points of different series at the same timestamp are merged with the aggregation.
+func mergeSeriesPoints(series [][]DataPoint, aggregation string) []DataPoint {
+	if len(series) == 1 {
+		return series[0]
+	}
+
+	merger := newSeriesMerger(aggregation)
+	merged := make(map[int64]*DataPoint)
+	for _, points := range series {
+		for _, point := range points {
+			ts := point.Timestamp.UnixMilli()
+			existing, ok := merged[ts]
+			if !ok {
+				existing = &DataPoint{Timestamp: point.Timestamp}
+				merged[ts] = existing
+			}
+			merger.merge(strconv.FormatInt(ts, 10), &existing.Value, point.Value)
+		}
+	}
+
+	dataPoints := make([]DataPoint, 0, len(merged))
+	for _, point := range merged {
+		dataPoints = append(dataPoints, *point)
+	}
+	sort.Slice(dataPoints, func(i, j int) bool { return dataPoints[i].Timestamp.Before(dataPoints[j].Timestamp) })
+	return dataPoints
+}
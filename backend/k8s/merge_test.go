@@ -0,0 +1,138 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSeriesMerger(t *testing.T) {
+	tests := []struct {
+		aggregation string
+		values      []float64
+		want        float64
+	}{
+		{MergeMax, []float64{2, 5, 3}, 5},
+		{MergeMin, []float64{2, 5, 3}, 2},
+		{MergeAvg, []float64{2, 5, 3}, 10.0 / 3},
+		{"", []float64{2, 5, 3}, 5},
+		{"median", []float64{2, 5, 3}, 5},
+		{MergeMin, []float64{4}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.aggregation, func(t *testing.T) {
+			merger := newSeriesMerger(tt.aggregation)
+			var field, other float64
+			for _, v := range tt.values {
+				merger.merge("shop/web-0/app", &field, v)
+			}
+			merger.merge("shop/web-1/app", &other, 7)
+			if field-tt.want > 1e-9 || tt.want-field > 1e-9 {
+				t.Errorf("merged value = %v, want %v", field, tt.want)
+			}
+			if other != 7 {
+				t.Errorf("value of another key = %v, want 7", other)
+			}
+		})
+	}
+}
+
+func TestMergeSeriesPoints(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2026, 1, 1, 0, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name        string
+		series      [][]DataPoint
+		aggregation string
+		want        []DataPoint
+	}{
+		{
+			name:   "single series unchanged",
+			series: [][]DataPoint{{{at(1), 1}, {at(0), 2}}},
+			want:   []DataPoint{{at(1), 1}, {at(0), 2}},
+		},
+		{
+			name:        "duplicates merged with max",
+			series:      [][]DataPoint{{{at(0), 1}, {at(1), 4}}, {{at(0), 3}, {at(1), 2}}},
+			aggregation: MergeMax,
+			want:        []DataPoint{{at(0), 3}, {at(1), 4}},
+		},
+		{
+			name:        "duplicates merged with avg",
+			series:      [][]DataPoint{{{at(0), 1}, {at(1), 4}}, {{at(0), 3}, {at(1), 2}}},
+			aggregation: MergeAvg,
+			want:        []DataPoint{{at(0), 2}, {at(1), 3}},
+		},
+		{
+			name:        "gaps filled and sorted",
+			series:      [][]DataPoint{{{at(2), 5}}, {{at(1), 1}, {at(0), 2}}},
+			aggregation: MergeMin,
+			want:        []DataPoint{{at(0), 2}, {at(1), 1}, {at(2), 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeSeriesPoints(tt.series, tt.aggregation); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeSeriesPoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeQueryMultiSeries(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) string {
+		return strconv.FormatInt(start.Add(time.Duration(minutes)*time.Minute).Unix(), 10)
+	}
+	// Two replicas of the series, scraped at interleaved timestamps and both at minute 10
+	matrix := `[{"metric":{"replica":"a"},"values":[[` + at(0) + `,"1"],[` + at(10) + `,"3"],[` + at(20) + `,"5"]]},` +
+		`{"metric":{"replica":"b"},"values":[[` + at(5) + `,"2"],[` + at(10) + `,"7"],[` + at(15) + `,"4"]]}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":` + matrix + `}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		aggregation string
+		wantAt10    float64
+	}{
+		{MergeMax, 7},
+		{MergeMin, 3},
+		{MergeAvg, 5},
+	}
+	for _, tt := range tests {
+		config := MetricsClientConfig{DuplicateAggregation: tt.aggregation}
+		clients := map[string]MetricsClient{
+			"prometheus":      newTestPrometheusClient(t, server, config),
+			"victoriametrics": newTestVMClient(t, server, config),
+		}
+		for client, metricsClient := range clients {
+			t.Run(client+"/"+tt.aggregation, func(t *testing.T) {
+				points, err := metricsClient.GetContainerSeries(context.Background(), "shop", "web-0", "app", SeriesMemory, start, start.Add(time.Hour))
+				if err != nil {
+					t.Fatalf("GetContainerSeries() error = %v", err)
+				}
+				want := []DataPoint{
+					{Timestamp: start, Value: 1},
+					{Timestamp: start.Add(5 * time.Minute), Value: 2},
+					{Timestamp: start.Add(10 * time.Minute), Value: tt.wantAt10},
+					{Timestamp: start.Add(15 * time.Minute), Value: 4},
+					{Timestamp: start.Add(20 * time.Minute), Value: 5},
+				}
+				if len(points) != len(want) {
+					t.Fatalf("got %d points, want %d: %+v", len(points), len(want), points)
+				}
+				for i := range want {
+					if !points[i].Timestamp.Equal(want[i].Timestamp) || points[i].Value != want[i].Value {
+						t.Errorf("point %d = %v at %s, want %v at %s", i, points[i].Value, points[i].Timestamp, want[i].Value, want[i].Timestamp)
+					}
+				}
+			})
+		}
+	}
+}
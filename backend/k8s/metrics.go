@@ -2,19 +2,67 @@ package k8s
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
 )
 
+// ErrInvalidQuery is returned when the metrics backend rejects a query as malformed
+var ErrInvalidQuery = errors.New("invalid query")
+
+// QuerySample represents a single sample of an instant query result
+type QuerySample struct {
+	Metric    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
 // MetricsClient defines the interface for metrics collection backends
 type MetricsClient interface {
-	// GetCurrentPodMetrics retrieves current pod metrics from the metrics backend
-	GetCurrentPodMetrics(ctx context.Context, namespace string) ([]PodMetric, error)
+	// GetCurrentPodMetrics retrieves pod metrics from the metrics backend as of the given evaluation time.
+	// selector is an optional list of label filters (see ValidLabelFilters) added to the usage queries.
+	GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]PodMetric, error)
+	
+	// GetHistoricalMetrics retrieves and analyzes historical metrics for pods over the analysis window
+	// (7 days by default, see HistoricalOptions)
+	GetHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions) ([]HistoricalMetrics, error)
 	
-	// GetHistoricalMetrics retrieves and analyzes 7-day historical metrics for pods
-	GetHistoricalMetrics(ctx context.Context, namespace string) ([]HistoricalMetrics, error)
+	// StreamHistoricalMetrics passes each container's historical analysis to fn as soon as it is computed
+	StreamHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions, fn func(HistoricalMetrics) error) error
+	
+	// GetActivePods retrieves the pods that were active during the analysis window
+	GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]PodInfo, error)
+	
+	// GetContainerSeries retrieves the raw datapoints of a single container metric (SeriesCPU or SeriesMemory)
+	GetContainerSeries(ctx context.Context, namespace, pod, container, metric string, start, end time.Time) ([]DataPoint, error)
 	
 	// GetNamespaces retrieves all namespaces from metrics
 	GetNamespaces(ctx context.Context) ([]string, error)
 	
+	// GetNodeAllocatable retrieves allocatable CPU and memory for each node
+	GetNodeAllocatable(ctx context.Context) ([]NodeAllocatable, error)
+	
+	// GetPodNodes retrieves the node each pod is scheduled on as of the given evaluation time, keyed by namespace/pod
+	GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error)
+	
+	// GetPodLabels retrieves the Kubernetes labels of each pod as of the given evaluation time, keyed by namespace/pod.
+	// Only labels allowlisted in kube-state-metrics are available, with sanitized names (see SanitizeLabelName).
+	GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error)
+	
+	// GetPodStatus retrieves the phase and last termination reasons of a single pod
+	GetPodStatus(ctx context.Context, namespace, pod string) (PodStatus, error)
+	
+	// QueryInstant executes an arbitrary instant query and returns the raw samples
+	QueryInstant(ctx context.Context, query string) ([]QuerySample, error)
+	
+	// Ping runs a cheap query to check the backend is reachable
+	Ping(ctx context.Context) error
+	
 	// Close closes the metrics client connection
 	Close() error
 	
@@ -22,14 +70,293 @@ type MetricsClient interface {
 	GetClientType() string
 }
 
+// LabelLister is implemented by metrics clients that can list label names and values
+// of pod metrics, e.g. for UI autocomplete
+type LabelLister interface {
+	// GetLabelNames retrieves the label names of container usage series, optionally within a namespace
+	GetLabelNames(ctx context.Context, namespace string) ([]string, error)
+
+	// GetLabelValues retrieves the values of a label on container usage series, optionally within a namespace
+	GetLabelValues(ctx context.Context, name, namespace string) ([]string, error)
+}
+
+// HistoricalAnalyzer is implemented by metrics clients that can analyze series combined outside the
+// client, e.g. across the replicas of a workload, with the client's thresholds
+type HistoricalAnalyzer interface {
+	// AnalyzeHistoricalMetrics recomputes the statistics, trends and analysis of hm from its series
+	AnalyzeHistoricalMetrics(hm HistoricalMetrics) HistoricalMetrics
+}
+
+// PodOwnerLister is implemented by metrics clients that can look up the workload owning each pod
+type PodOwnerLister interface {
+	// GetPodOwners retrieves the controller owning each pod active during the analysis window,
+	// keyed by namespace/pod. Pods of a ReplicaSet are attributed to its Deployment.
+	GetPodOwners(ctx context.Context, namespace string, window time.Duration) (map[string]string, error)
+}
+
+// NodePodMetricsGetter is implemented by metrics clients that can filter current pod metrics by node
+// in the backend query instead of fetching the pods of every node
+type NodePodMetricsGetter interface {
+	// GetNodePodMetrics retrieves the current metrics of the pods scheduled on node, like GetCurrentPodMetrics
+	GetNodePodMetrics(ctx context.Context, namespace, node, selector string, at time.Time) ([]PodMetric, error)
+}
+
+// labelLookback is how far back label names and values are looked up
+const labelLookback = time.Hour
+
 // MetricsClientConfig contains configuration for metrics clients
 type MetricsClientConfig struct {
-	Backend string // "prometheus" or "vmagent"
+	Backend string // "prometheus", "victoriametrics", "remoteread" or "metrics-server"
 	URL     string // Connection URL for the metrics backend
+
+	// BearerToken is sent as an Authorization header on every backend request when set
+	BearerToken string
+
+	// TLSConfig customizes TLS of backend connections, e.g. a private CA or a client certificate
+	// (see LoadTLSConfig). nil keeps the default TLS configuration.
+	TLSConfig *tls.Config
+
+	// KubeContext is the kubeconfig context whose API server and credentials the metrics-server backend
+	// uses instead of URL, BearerToken and TLSConfig when set
+	KubeContext string
+
+	// MaxInflightQueries limits the backend queries in flight at once across all clients created by
+	// the same factory; further queries wait for a free slot. 0 disables the limit.
+	MaxInflightQueries int
+	inflight           querySemaphore // Shared by the factory's clients when MaxInflightQueries is set
+
+	// RetryAttempts is the number of times a query failing with a transport error or a 502, 503 or 504
+	// is retried. Each attempt gets an equal share of the time left until the request deadline.
+	RetryAttempts int
+
+	// EnableContainerStatus adds restart and OOMKill queries from kube-state-metrics
+	EnableContainerStatus bool
+
+	// EnableRestartTrend adds an hourly restart-rate range query to historical analysis
+	EnableRestartTrend bool
+	
+	// Queries overrides the metric names and label filters used to build queries
+	Queries QueryTemplates
+
+	// Efficiency (usage/request %) below WasteLowThreshold is over-provisioned,
+	// above WasteHighThreshold under-provisioned
+	WasteLowThreshold  float64
+	WasteHighThreshold float64
+
+	// A change of more than CPUTrendThreshold/MemoryTrendThreshold percent between the first and last
+	// quartile of usage is reported as an increasing or decreasing trend
+	CPUTrendThreshold    float64
+	MemoryTrendThreshold float64
+
+	// EfficiencyBasis is the usage statistic compared to requests for efficiency and waste:
+	// "average" (default), "p95" or "peak"
+	EfficiencyBasis string
+
+	// DuplicateAggregation merges duplicate series for the same container: "max" (default), "min" or "avg"
+	DuplicateAggregation string
+
+	// MaxSamplesPerSeries caps the datapoints per range query series; the step is coarsened to stay below it
+	MaxSamplesPerSeries int
+
+	// AlignRangeQueries floors the start and ceils the end of range queries to the step, so datapoints
+	// land on consistent timestamps across requests
+	AlignRangeQueries bool
+
+	// MinTrendSamples is the minimum number of usage points before a trend is calculated
+	MinTrendSamples int
+
+	// VMUseExport bulk-fetches historical series with the VictoriaMetrics export API
+	// instead of per-container range queries (victoriametrics backend only)
+	VMUseExport bool
+
+	// InstantLookback is how far back current metrics look for the latest sample, so containers
+	// scraped slightly in the past don't vanish. 0 relies on the backend's staleness handling.
+	InstantLookback time.Duration
+
+	// ScrapeInterval is the scrape interval of the CPU usage counters, from which CPU rate windows are
+	// derived. 0 keeps the fixed 5-minute window.
+	ScrapeInterval time.Duration
+}
+
+// DefaultMinTrendSamples is the default minimum number of points for trend calculation
+const DefaultMinTrendSamples = 10
+
+// DefaultRangeStep is the resolution of historical range queries
+const DefaultRangeStep = 5 * time.Minute
+
+// DefaultMaxSamplesPerSeries allows a 7-day range at the default step
+const DefaultMaxSamplesPerSeries = 2500
+
+// dataCompleteness returns the percentage of expected datapoints that were returned for a range at the given step
+func dataCompleteness(points int, start, end time.Time, step time.Duration) float64 {
+	if step <= 0 || end.Before(start) {
+		return 0
+	}
+	expected := int(end.Sub(start)/step) + 1
+	return math.Min(float64(points)/float64(expected)*100, 100)
+}
+
+// rangeStep returns the step for a range query, coarsened so the number of
+// datapoints per series stays within maxSamples. The second result reports whether it was coarsened.
+func rangeStep(start, end time.Time, maxSamples int) (time.Duration, bool) {
+	step := DefaultRangeStep
+	if maxSamples < 2 || end.Before(start) {
+		return step, false
+	}
+
+	span := end.Sub(start)
+	if int(span/step)+1 <= maxSamples {
+		return step, false
+	}
+
+	// Round up to whole seconds so every backend accepts the step
+	coarse := span / time.Duration(maxSamples-1)
+	coarse = (coarse + time.Second - 1).Truncate(time.Second)
+	if int(span/coarse)+1 > maxSamples {
+		coarse += time.Second
+	}
+	return coarse, true
+}
+
+// alignRange floors start and ceils end to a multiple of step, so datapoints land on the same
+// timestamps across requests, e.g. on the hour with an hourly step
+func alignRange(start, end time.Time, step time.Duration) (time.Time, time.Time) {
+	if step <= 0 {
+		return start, end
+	}
+	alignedEnd := end.Truncate(step)
+	if alignedEnd.Before(end) {
+		alignedEnd = alignedEnd.Add(step)
+	}
+	return start.Truncate(step), alignedEnd
+}
+
+// Default waste analysis thresholds
+const (
+	DefaultWasteLowThreshold  = 30.0
+	DefaultWasteHighThreshold = 80.0
+)
+
+// DefaultTrendThreshold is the default change, in percent, reported as a trend
+const DefaultTrendThreshold = 10.0
+
+// Usage statistics that efficiency can be based on
+const (
+	EfficiencyAverage = "average"
+	EfficiencyP95     = "p95"
+	EfficiencyPeak    = "peak"
+)
+
+// efficiencyUsage returns the usage statistic selected by basis, defaulting to the average
+func efficiencyUsage(data HistoricalResourceData, basis string) float64 {
+	switch basis {
+	case EfficiencyP95:
+		return data.P95
+	case EfficiencyPeak:
+		return data.Peak
+	default:
+		return data.Average
+	}
+}
+
+// DefaultInstantLookback is the default lookback of current metrics queries
+const DefaultInstantLookback = time.Minute
+
+// withLookback wraps a gauge selector in last_over_time over the lookback, returning the selector
+// unchanged when the lookback is disabled
+func withLookback(selector string, lookback time.Duration) string {
+	if lookback <= 0 {
+		return selector
+	}
+	return "last_over_time(" + selector + "[" + promDuration(lookback) + "])"
+}
+
+// DefaultRateWindow is the window of CPU rate queries when no scrape interval is configured
+const DefaultRateWindow = 5 * time.Minute
+
+// Rate windows derived from the scrape interval span this many scrapes, and at least minRateWindow,
+// so every window holds enough samples for rate() without smoothing over more than needed
+const (
+	rateWindowScrapes = 4
+	minRateWindow     = time.Minute
+)
+
+// rateWindow returns the window of CPU rate queries for the scrape interval
+func rateWindow(scrapeInterval time.Duration) time.Duration {
+	if scrapeInterval <= 0 {
+		return DefaultRateWindow
+	}
+	return max(rateWindowScrapes*scrapeInterval, minRateWindow)
+}
+
+// instantRateWindow returns the window of current CPU rate queries, the rate window extended by the lookback
+func instantRateWindow(scrapeInterval, lookback time.Duration) time.Duration {
+	return rateWindow(scrapeInterval) + max(lookback, 0)
+}
+
+// promDuration formats a duration in whole seconds, as accepted by PromQL range selectors
+func promDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", max(int64(d/time.Second), 1))
+}
+
+// sortDataPoints orders datapoints chronologically, as trend and pattern detection expect
+func sortDataPoints(points []DataPoint) {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+}
+
+// sortPodMetrics orders pod metrics by namespace, pod and container so responses are stable
+// despite being collected in a map
+func sortPodMetrics(pods []PodMetric) {
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		if pods[i].Name != pods[j].Name {
+			return pods[i].Name < pods[j].Name
+		}
+		return pods[i].ContainerName < pods[j].ContainerName
+	})
+}
+
+// bearerTokenTransport adds an Authorization header to every request
+type bearerTokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(req)
+}
+
+// newTransport returns the HTTP transport for a backend, with the configured TLS settings and
+// authenticating when a bearer token is configured
+func newTransport(config MetricsClientConfig) http.RoundTripper {
+	transport := http.DefaultTransport
+	if config.TLSConfig != nil {
+		custom := http.DefaultTransport.(*http.Transport).Clone()
+		custom.TLSClientConfig = config.TLSConfig
+		transport = custom
+	}
+	if config.inflight != nil {
+		transport = &inflightTransport{slots: config.inflight, next: transport}
+	}
+	if config.RetryAttempts > 0 {
+		transport = &retryTransport{retries: config.RetryAttempts, next: transport}
+	}
+	if config.BearerToken == "" {
+		return transport
+	}
+	return &bearerTokenTransport{token: config.BearerToken, next: transport}
 }
 
 // MetricsClientFactory creates metrics clients based on configuration
-type MetricsClientFactory struct{}
+type MetricsClientFactory struct {
+	mu       sync.Mutex
+	inflight querySemaphore // Created by the first client with MaxInflightQueries set
+}
 
 // NewMetricsClientFactory creates a new metrics client factory
 func NewMetricsClientFactory() *MetricsClientFactory {
@@ -38,13 +365,29 @@ func NewMetricsClientFactory() *MetricsClientFactory {
 
 // CreateClient creates a metrics client based on the provided configuration
 func (f *MetricsClientFactory) CreateClient(config MetricsClientConfig) (MetricsClient, error) {
+	if config.MaxInflightQueries > 0 {
+		f.mu.Lock()
+		if f.inflight == nil {
+			f.inflight = make(querySemaphore, config.MaxInflightQueries)
+		}
+		config.inflight = f.inflight
+		f.mu.Unlock()
+	}
+
 	switch config.Backend {
 	case "prometheus":
-		return NewPrometheusClient(config.URL)
+		return NewPrometheusClient(config)
 	case "victoriametrics":
-		return NewVictoriaMetricsClient(config.URL)
+		return NewVictoriaMetricsClient(config)
+	case "remoteread":
+		return NewRemoteReadClient(config)
+	case BackendMetricsServer:
+		return NewMetricsServerClient(config)
+	case BackendAuto:
+		config.Backend = DetectBackend(context.Background(), config)
+		return f.CreateClient(config)
 	default:
 		// Default to Prometheus for backward compatibility
-		return NewPrometheusClient(config.URL)
+		return NewPrometheusClient(config)
 	}
 }
@@ -0,0 +1,221 @@
+package k8s
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRangeStep(t *testing.T) {
+	end := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		span          time.Duration
+		maxSamples    int
+		wantStep      time.Duration
+		wantCoarsened bool
+	}{
+		{"7 days at the default cap", 7 * 24 * time.Hour, DefaultMaxSamplesPerSeries, DefaultRangeStep, false},
+		{"one hour", time.Hour, DefaultMaxSamplesPerSeries, DefaultRangeStep, false},
+		{"exactly at the cap", 100 * DefaultRangeStep, 101, DefaultRangeStep, false},
+		{"30 days at the default cap", 30 * 24 * time.Hour, DefaultMaxSamplesPerSeries, 1038 * time.Second, true},
+		{"small cap", 24 * time.Hour, 25, time.Hour, true},
+		{"uneven span rounded up to seconds", 1001 * time.Second, 3, 501 * time.Second, true},
+		{"cap disabled", 30 * 24 * time.Hour, 0, DefaultRangeStep, false},
+		{"reversed range", -time.Hour, 10, DefaultRangeStep, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := end.Add(-tt.span)
+			step, coarsened := rangeStep(start, end, tt.maxSamples)
+			if step != tt.wantStep || coarsened != tt.wantCoarsened {
+				t.Errorf("rangeStep() = %s, %v, want %s, %v", step, coarsened, tt.wantStep, tt.wantCoarsened)
+			}
+			if tt.maxSamples >= 2 && tt.span > 0 {
+				if samples := int(tt.span/step) + 1; samples > tt.maxSamples {
+					t.Errorf("%d samples at step %s exceed the cap of %d", samples, step, tt.maxSamples)
+				}
+			}
+			if step%time.Second != 0 {
+				t.Errorf("step %s is not a whole number of seconds", step)
+			}
+		})
+	}
+}
+
+func TestAlignRange(t *testing.T) {
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2026, 1, 2, hour, minute, second, 0, time.UTC)
+	}
+	tests := []struct {
+		name               string
+		start, end         time.Time
+		step               time.Duration
+		wantStart, wantEnd time.Time
+	}{
+		{"unaligned", at(3, 7, 30), at(9, 2, 10), 5 * time.Minute, at(3, 5, 0), at(9, 5, 0)},
+		{"already aligned", at(3, 5, 0), at(9, 5, 0), 5 * time.Minute, at(3, 5, 0), at(9, 5, 0)},
+		{"hourly step", at(3, 7, 30), at(9, 2, 10), time.Hour, at(3, 0, 0), at(10, 0, 0)},
+		{"no step", at(3, 7, 30), at(9, 2, 10), 0, at(3, 7, 30), at(9, 2, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := alignRange(tt.start, tt.end, tt.step)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("alignRange() = %s, %s, want %s, %s", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+// TestAlignRangeQueries checks the window each backend passes to its range queries
+func TestAlignRangeQueries(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 7, 30, 0, time.UTC)
+	end := time.Date(2026, 1, 2, 9, 2, 10, 0, time.UTC)
+
+	type rangeQuerier interface {
+		queryRangeMetric(ctx context.Context, query string, start, end time.Time) ([]DataPoint, error)
+	}
+	clients := map[string]func(*testing.T, *httptest.Server, MetricsClientConfig) rangeQuerier{
+		"prometheus": func(t *testing.T, server *httptest.Server, config MetricsClientConfig) rangeQuerier {
+			return newTestPrometheusClient(t, server, config)
+		},
+		"victoriametrics": func(t *testing.T, server *httptest.Server, config MetricsClientConfig) rangeQuerier {
+			return newTestVMClient(t, server, config)
+		},
+	}
+	tests := []struct {
+		name               string
+		align              bool
+		wantStart, wantEnd time.Time
+	}{
+		{"aligned", true, time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC), time.Date(2026, 1, 2, 9, 5, 0, 0, time.UTC)},
+		{"not aligned", false, start, end},
+	}
+	for backend, newClient := range clients {
+		for _, tt := range tests {
+			t.Run(backend+" "+tt.name, func(t *testing.T) {
+				var mu sync.Mutex
+				var gotStart, gotEnd time.Time
+				server := newVMServer(t, func(r *http.Request) string {
+					mu.Lock()
+					defer mu.Unlock()
+					gotStart, gotEnd = formTime(t, r, "start"), formTime(t, r, "end")
+					return ""
+				})
+				client := newClient(t, server, MetricsClientConfig{AlignRangeQueries: tt.align})
+				if _, err := client.queryRangeMetric(context.Background(), "up", start, end); err != nil {
+					t.Fatalf("queryRangeMetric() error = %v", err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if !gotStart.Equal(tt.wantStart) || !gotEnd.Equal(tt.wantEnd) {
+					t.Errorf("range query window = %s - %s, want %s - %s", gotStart.UTC(), gotEnd.UTC(), tt.wantStart, tt.wantEnd)
+				}
+			})
+		}
+	}
+}
+
+// formTime parses a request's Unix timestamp parameter
+func formTime(t *testing.T, r *http.Request, name string) time.Time {
+	t.Helper()
+	seconds, err := strconv.ParseFloat(r.FormValue(name), 64)
+	if err != nil {
+		t.Errorf("%s parameter %q: %v", name, r.FormValue(name), err)
+		return time.Time{}
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+func TestDataCompleteness(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		points int
+		span   time.Duration
+		step   time.Duration
+		want   float64
+	}{
+		{"complete hour", 13, time.Hour, 5 * time.Minute, 100},
+		{"hour with a 15 minute gap", 10, time.Hour, 5 * time.Minute, 10.0 / 13 * 100},
+		{"half of a day", 145, 24 * time.Hour, 5 * time.Minute, 145.0 / 289 * 100},
+		{"no points", 0, time.Hour, 5 * time.Minute, 0},
+		{"more points than expected", 20, time.Hour, 5 * time.Minute, 100},
+		{"no step", 10, time.Hour, 0, 0},
+		{"reversed range", 10, -time.Hour, 5 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dataCompleteness(tt.points, start, start.Add(tt.span), tt.step)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("dataCompleteness() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeResourceDataShuffled(t *testing.T) {
+	// reversed returns points in reverse chronological order, as merged series can arrive
+	reversed := func(points []DataPoint) []DataPoint {
+		shuffled := make([]DataPoint, len(points))
+		for i, point := range points {
+			shuffled[len(points)-1-i] = point
+		}
+		return shuffled
+	}
+	// interleaved returns the odd-indexed points before the even-indexed ones
+	interleaved := func(points []DataPoint) []DataPoint {
+		var odd, even []DataPoint
+		for i, point := range points {
+			if i%2 == 1 {
+				odd = append(odd, point)
+			} else {
+				even = append(even, point)
+			}
+		}
+		return append(odd, even...)
+	}
+	// falling returns n datapoints falling linearly from 2 to 1
+	falling := func(n int) []DataPoint {
+		points := rampPoints(n)
+		for i := range points {
+			points[i].Value = 3 - points[i].Value
+		}
+		return points
+	}
+
+	tests := []struct {
+		name   string
+		points []DataPoint
+		want   string
+	}{
+		{"chronological rise", rampPoints(20), "increasing"},
+		{"reversed rise", reversed(rampPoints(20)), "increasing"},
+		{"interleaved rise", interleaved(rampPoints(20)), "increasing"},
+		{"reversed fall", reversed(falling(20)), "decreasing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := append([]DataPoint(nil), tt.points...)
+			requests := reversed(rampPoints(4))
+			data := analyzeResourceData(MetricsClientConfig{}, points, requests, nil, DefaultTrendThreshold)
+			if data.Trend != tt.want {
+				t.Errorf("trend = %s, want %s", data.Trend, tt.want)
+			}
+			for _, series := range [][]DataPoint{data.Usage, data.Requests} {
+				for i := 1; i < len(series); i++ {
+					if series[i].Timestamp.Before(series[i-1].Timestamp) {
+						t.Fatalf("datapoint %d at %s precedes the previous one at %s", i, series[i].Timestamp, series[i-1].Timestamp)
+					}
+				}
+			}
+		})
+	}
+}
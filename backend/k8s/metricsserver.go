@@ -0,0 +1,353 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// BackendMetricsServer reads current usage from the Kubernetes metrics API served by metrics-server,
+// and requests, limits and pod details from the Kubernetes API
+const BackendMetricsServer = "metrics-server"
+
+// ErrNoHistory is returned for historical data by backends that only report current usage
+var ErrNoHistory = errors.New("historical metrics are not supported by the metrics-server backend, which only reports current usage")
+
+// metricsServerMaxAge is how far in the past an evaluation time may be, since metrics-server has no history
+const metricsServerMaxAge = time.Minute
+
+// MetricsServerClient implements MetricsClient for clusters with metrics-server but no Prometheus
+type MetricsServerClient struct {
+	*KubernetesClient
+	metrics metricsv.Interface
+	config  MetricsClientConfig
+}
+
+// NewMetricsServerClient creates a new metrics-server client against the Kubernetes API server at config.URL,
+// or of the kubeconfig context config.KubeContext when set. Without a configured bearer token or TLS
+// configuration, the in-cluster service account token and CA are used.
+func NewMetricsServerClient(config MetricsClientConfig) (*MetricsServerClient, error) {
+	var kube *KubernetesClient
+	var err error
+	if config.KubeContext != "" {
+		kube, err = NewKubernetesClientFromKubeconfig(config.KubeContext)
+	} else {
+		kube, err = NewKubernetesClient(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metricsv.NewForConfig(kube.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics API client: %w", err)
+	}
+	return &MetricsServerClient{KubernetesClient: kube, metrics: metrics, config: config}, nil
+}
+
+// Ping checks the metrics API is served
+func (ms *MetricsServerClient) Ping(ctx context.Context) error {
+	return ms.metrics.MetricsV1beta1().RESTClient().Get().AbsPath("/apis", metricsv1beta1.SchemeGroupVersion.String()).Do(ctx).Error()
+}
+
+// Close closes the metrics-server client connection
+func (ms *MetricsServerClient) Close() error {
+	// HTTP client doesn't require explicit closing
+	return nil
+}
+
+// GetClientType returns the type of metrics client
+func (ms *MetricsServerClient) GetClientType() string {
+	return BackendMetricsServer
+}
+
+// listPodMetrics lists the usage of the pods in namespace, all namespaces when empty
+func (ms *MetricsServerClient) listPodMetrics(ctx context.Context, namespace string) ([]metricsv1beta1.PodMetrics, error) {
+	list, err := ms.metrics.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod metrics: %w", err)
+	}
+	return list.Items, nil
+}
+
+// quantity returns a quantity of a resource list in cores or bytes, 0 when unset
+func quantity(resources corev1.ResourceList, name corev1.ResourceName) float64 {
+	value, ok := resources[name]
+	if !ok {
+		return 0
+	}
+	return value.AsApproximateFloat64()
+}
+
+// podLabels converts Kubernetes pod labels to the sanitized names kube-state-metrics uses
+func podLabels(labels map[string]string) map[string]string {
+	sanitized := make(map[string]string, len(labels))
+	for name, value := range labels {
+		sanitized[SanitizeLabelName(name)] = value
+	}
+	return sanitized
+}
+
+// GetCurrentPodMetrics retrieves the current usage of containers from metrics-server, with their requests
+// and limits from the pod specs. Only the current time can be evaluated. selector filters are matched
+// against the namespace, pod and container names and the pod labels, prefixed with "label_".
+func (ms *MetricsServerClient) GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]PodMetric, error) {
+	if time.Since(at) > metricsServerMaxAge {
+		return nil, fmt.Errorf("%w: past evaluation times are not supported by the metrics-server backend", ErrInvalidQuery)
+	}
+
+	usage, err := ms.listPodMetrics(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := parseLabelFilters(selector)
+	podMetrics := make(map[string]*PodMetric)
+	for _, pod := range usage {
+		for _, container := range pod.Containers {
+			labels := map[string]string{"namespace": pod.Namespace, "pod": pod.Name, "container": container.Name}
+			for name, value := range podLabels(pod.Labels) {
+				labels[podLabelPrefix+name] = value
+			}
+			if !matchesLabelFilters(labels, filters) {
+				continue
+			}
+
+			podMetrics[pod.Namespace+"/"+pod.Name+"/"+container.Name] = &PodMetric{
+				Name:          pod.Name,
+				Namespace:     pod.Namespace,
+				ContainerName: container.Name,
+				CPUUsage:      quantity(container.Usage, corev1.ResourceCPU),
+				MemoryUsage:   quantity(container.Usage, corev1.ResourceMemory),
+				Labels:        make(map[string]string),
+				SampleTime:    pod.Timestamp.Time,
+			}
+		}
+	}
+
+	// Get resource requests and limits from the pod specs
+	pods, err := ms.addResourceLimitsAndRequests(ctx, podMetrics, namespace)
+	if err != nil {
+		log.Printf("Warning: failed to get resource requests/limits: %v", err)
+		recordWarnings(ctx, []string{WarningPodSpecsUnavailable})
+	}
+
+	// Get pod labels, start times, container restarts and OOMKills
+	ms.addPodDetails(podMetrics, pods)
+
+	// Classify pods by QoS class, unknown without requests and limits
+	if err == nil {
+		setQoSClasses(podMetrics)
+	}
+
+	// Convert map to slice, in a stable order
+	var result []PodMetric
+	for _, metric := range podMetrics {
+		result = append(result, *metric)
+	}
+	sortPodMetrics(result)
+
+	return result, nil
+}
+
+// addResourceLimitsAndRequests lists the pods of namespace, all namespaces when empty, and sets the
+// requests and limits of the containers in podMetrics from their specs. The listed pods are returned
+// for their other details.
+func (ms *MetricsServerClient) addResourceLimitsAndRequests(ctx context.Context, podMetrics map[string]*PodMetric, namespace string) ([]corev1.Pod, error) {
+	pods, err := ms.listPods(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		for _, container := range pod.Spec.Containers {
+			metric, ok := podMetrics[key+"/"+container.Name]
+			if !ok {
+				continue
+			}
+			metric.CPURequest = quantity(container.Resources.Requests, corev1.ResourceCPU)
+			metric.CPULimit = quantity(container.Resources.Limits, corev1.ResourceCPU)
+			metric.MemoryRequest = quantity(container.Resources.Requests, corev1.ResourceMemory)
+			metric.MemoryLimit = quantity(container.Resources.Limits, corev1.ResourceMemory)
+		}
+	}
+	return pods, nil
+}
+
+// addPodDetails sets the pod labels and start times of the containers in podMetrics, and their restarts
+// and OOMKills when container status is enabled
+func (ms *MetricsServerClient) addPodDetails(podMetrics map[string]*PodMetric, pods []corev1.Pod) {
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		labels := podLabels(pod.Labels)
+		for _, container := range pod.Spec.Containers {
+			metric, ok := podMetrics[key+"/"+container.Name]
+			if !ok {
+				continue
+			}
+			for name, value := range labels {
+				metric.Labels[name] = value
+			}
+			if pod.Status.StartTime != nil {
+				metric.StartTime = pod.Status.StartTime.Time
+			}
+		}
+
+		if ms.config.EnableContainerStatus {
+			for _, status := range pod.Status.ContainerStatuses {
+				if metric, ok := podMetrics[key+"/"+status.Name]; ok {
+					metric.RestartCount = int(status.RestartCount)
+					metric.OOMKilled = status.LastTerminationState.Terminated != nil && status.LastTerminationState.Terminated.Reason == "OOMKilled"
+				}
+			}
+		}
+	}
+}
+
+// matchesLabelFilters reports whether labels match every filter, a missing label matching as empty
+func matchesLabelFilters(labels map[string]string, filters []RemoteReadMatcher) bool {
+	for _, filter := range filters {
+		value := labels[filter.Name]
+		var matches bool
+		switch filter.Type {
+		case MatchEqual:
+			matches = value == filter.Value
+		case MatchNotEqual:
+			matches = value != filter.Value
+		case MatchRegexp, MatchNotRegexp:
+			pattern, err := regexp.Compile("^(?:" + filter.Value + ")$")
+			matches = err == nil && pattern.MatchString(value) == (filter.Type == MatchRegexp)
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+// GetHistoricalMetrics is not supported because metrics-server has no history
+func (ms *MetricsServerClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions) ([]HistoricalMetrics, error) {
+	return nil, ErrNoHistory
+}
+
+// StreamHistoricalMetrics is not supported because metrics-server has no history
+func (ms *MetricsServerClient) StreamHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions, fn func(HistoricalMetrics) error) error {
+	return ErrNoHistory
+}
+
+// GetActivePods is not supported because metrics-server has no history
+func (ms *MetricsServerClient) GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]PodInfo, error) {
+	return nil, ErrNoHistory
+}
+
+// GetContainerSeries is not supported because metrics-server has no history
+func (ms *MetricsServerClient) GetContainerSeries(ctx context.Context, namespace, pod, container, metric string, start, end time.Time) ([]DataPoint, error) {
+	return nil, ErrNoHistory
+}
+
+// GetNamespaces retrieves the namespaces of pods with metrics
+func (ms *MetricsServerClient) GetNamespaces(ctx context.Context) ([]string, error) {
+	usage, err := ms.listPodMetrics(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query namespaces: %w", err)
+	}
+
+	var namespaces []string
+	namespacesSet := make(map[string]bool)
+	for _, pod := range usage {
+		if namespace := pod.Namespace; namespace != "" && !namespacesSet[namespace] {
+			namespacesSet[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// GetNodeAllocatable retrieves allocatable CPU and memory per node from the node objects
+func (ms *MetricsServerClient) GetNodeAllocatable(ctx context.Context) ([]NodeAllocatable, error) {
+	list, err := ms.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	nodes := make([]NodeAllocatable, 0, len(list.Items))
+	for _, node := range list.Items {
+		nodes = append(nodes, NodeAllocatable{
+			Node:   node.Name,
+			CPU:    quantity(node.Status.Allocatable, corev1.ResourceCPU),
+			Memory: quantity(node.Status.Allocatable, corev1.ResourceMemory),
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Node < nodes[j].Node
+	})
+	return nodes, nil
+}
+
+// GetPodNodes retrieves the node each pod is scheduled on from the pod specs. Only the current
+// time can be evaluated.
+func (ms *MetricsServerClient) GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error) {
+	pods, err := ms.listPods(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	podNodes := make(map[string]string)
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			podNodes[pod.Namespace+"/"+pod.Name] = pod.Spec.NodeName
+		}
+	}
+	return podNodes, nil
+}
+
+// GetPodLabels retrieves the labels of each pod from the pod objects, with names sanitized like
+// kube-state-metrics does. Only the current time can be evaluated.
+func (ms *MetricsServerClient) GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error) {
+	pods, err := ms.listPods(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]map[string]string)
+	for _, pod := range pods {
+		if len(pod.Labels) > 0 {
+			labels[pod.Namespace+"/"+pod.Name] = podLabels(pod.Labels)
+		}
+	}
+	return labels, nil
+}
+
+// GetPodStatus retrieves the phase and last termination reasons of a pod from the pod object
+func (ms *MetricsServerClient) GetPodStatus(ctx context.Context, namespace, pod string) (PodStatus, error) {
+	status := PodStatus{LastTerminationReasons: make(map[string]string)}
+
+	object, err := ms.clientset.CoreV1().Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
+	if err != nil {
+		return status, fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	status.Phase = string(object.Status.Phase)
+	for _, container := range object.Status.ContainerStatuses {
+		if container.LastTerminationState.Terminated != nil && container.LastTerminationState.Terminated.Reason != "" {
+			status.LastTerminationReasons[container.Name] = container.LastTerminationState.Terminated.Reason
+		}
+	}
+	return status, nil
+}
+
+// QueryInstant is not supported because metrics-server has no query language
+func (ms *MetricsServerClient) QueryInstant(ctx context.Context, query string) ([]QuerySample, error) {
+	return nil, fmt.Errorf("raw PromQL queries are not supported by the metrics-server backend")
+}
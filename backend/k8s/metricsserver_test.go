@@ -0,0 +1,318 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// newTestMetricsServerClient returns a metrics-server client reading objects from a fake Kubernetes
+// API and usage from a fake metrics API
+func newTestMetricsServerClient(config MetricsClientConfig, objects []runtime.Object, usage ...*metricsv1beta1.PodMetrics) *MetricsServerClient {
+	// The fake object tracker files PodMetrics under "podmetricses" while the client lists "pods", so
+	// the list is served by a reactor instead
+	metrics := metricsfake.NewSimpleClientset()
+	metrics.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		namespace := action.GetNamespace()
+		list := &metricsv1beta1.PodMetricsList{}
+		for _, pod := range usage {
+			if namespace == "" || pod.Namespace == namespace {
+				list.Items = append(list.Items, *pod)
+			}
+		}
+		return true, list, nil
+	})
+	return &MetricsServerClient{
+		KubernetesClient: &KubernetesClient{clientset: kubefake.NewSimpleClientset(objects...)},
+		metrics:          metrics,
+		config:           config,
+	}
+}
+
+// resources returns a resource list of cpu and memory quantities, omitting empty ones
+func resources(cpu, memory string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	if cpu != "" {
+		list[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
+
+// testPod returns a running pod with containers of the given resources, keyed by container name
+func testPod(namespace, name string, labels map[string]string, containers map[string]corev1.ResourceRequirements) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodRunning,
+			StartTime: &metav1.Time{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+	}
+	for _, name := range []string{"app", "sidecar"} {
+		if requirements, ok := containers[name]; ok {
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name, Resources: requirements})
+		}
+	}
+	return pod
+}
+
+// testPodMetrics returns the usage of a pod's containers, keyed by container name
+func testPodMetrics(namespace, name string, containers map[string]corev1.ResourceList) *metricsv1beta1.PodMetrics {
+	usage := &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Timestamp:  metav1.Time{Time: time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)},
+	}
+	for _, name := range []string{"app", "sidecar"} {
+		if list, ok := containers[name]; ok {
+			usage.Containers = append(usage.Containers, metricsv1beta1.ContainerMetrics{Name: name, Usage: list})
+		}
+	}
+	return usage
+}
+
+func TestMetricsServerGetCurrentPodMetrics(t *testing.T) {
+	objects := []runtime.Object{
+		testPod("shop", "web-0", map[string]string{"app.kubernetes.io/name": "web"}, map[string]corev1.ResourceRequirements{
+			"app":     {Requests: resources("250m", "128Mi"), Limits: resources("500m", "256Mi")},
+			"sidecar": {Requests: resources("50m", "")},
+		}),
+		testPod("billing", "api-0", nil, map[string]corev1.ResourceRequirements{
+			"app": {Requests: resources("1", "1Gi"), Limits: resources("1", "1Gi")},
+		}),
+	}
+	usage := []*metricsv1beta1.PodMetrics{
+		testPodMetrics("shop", "web-0", map[string]corev1.ResourceList{
+			"app":     resources("120m", "100Mi"),
+			"sidecar": resources("5m", "10Mi"),
+		}),
+		testPodMetrics("billing", "api-0", map[string]corev1.ResourceList{
+			"app": resources("250000000n", "512Mi"),
+		}),
+	}
+	ms := newTestMetricsServerClient(MetricsClientConfig{}, objects, usage...)
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	sampled := time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		namespace string
+		selector  string
+		want      []PodMetric
+	}{
+		{
+			name:      "namespace",
+			namespace: "shop",
+			want: []PodMetric{
+				{
+					Name: "web-0", Namespace: "shop", ContainerName: "app",
+					CPUUsage: 0.12, CPURequest: 0.25, CPULimit: 0.5,
+					MemoryUsage: 100 << 20, MemoryRequest: 128 << 20, MemoryLimit: 256 << 20,
+					Labels: map[string]string{"app_kubernetes_io_name": "web"}, QoSClass: QoSBurstable,
+					StartTime: start, SampleTime: sampled,
+				},
+				{
+					Name: "web-0", Namespace: "shop", ContainerName: "sidecar",
+					CPUUsage: 0.005, CPURequest: 0.05,
+					MemoryUsage: 10 << 20,
+					Labels:      map[string]string{"app_kubernetes_io_name": "web"}, QoSClass: QoSBurstable,
+					StartTime: start, SampleTime: sampled,
+				},
+			},
+		},
+		{
+			name:     "all namespaces with a selector",
+			selector: `container="app", namespace="billing"`,
+			want: []PodMetric{
+				{
+					Name: "api-0", Namespace: "billing", ContainerName: "app",
+					CPUUsage: 0.25, CPURequest: 1, CPULimit: 1,
+					MemoryUsage: 512 << 20, MemoryRequest: 1 << 30, MemoryLimit: 1 << 30,
+					Labels: map[string]string{}, QoSClass: QoSGuaranteed,
+					StartTime: start, SampleTime: sampled,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ms.GetCurrentPodMetrics(context.Background(), tt.namespace, tt.selector, time.Now())
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCurrentPodMetrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ms.GetCurrentPodMetrics(context.Background(), "shop", "", time.Now().Add(-time.Hour)); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("GetCurrentPodMetrics() of a past time error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestMetricsServerResourceLimitsAndRequests(t *testing.T) {
+	objects := []runtime.Object{
+		testPod("shop", "web-0", nil, map[string]corev1.ResourceRequirements{
+			"app":     {Requests: resources("250m", "128Mi"), Limits: resources("500m", "256Mi")},
+			"sidecar": {Limits: resources("", "64Mi")},
+		}),
+		// Pods without usage are listed but not added
+		testPod("shop", "web-1", nil, map[string]corev1.ResourceRequirements{
+			"app": {Requests: resources("1", "1Gi")},
+		}),
+	}
+	ms := newTestMetricsServerClient(MetricsClientConfig{}, objects)
+
+	podMetrics := map[string]*PodMetric{
+		"shop/web-0/app":     {Name: "web-0", Namespace: "shop", ContainerName: "app"},
+		"shop/web-0/sidecar": {Name: "web-0", Namespace: "shop", ContainerName: "sidecar"},
+	}
+	pods, err := ms.addResourceLimitsAndRequests(context.Background(), podMetrics, "shop")
+	if err != nil {
+		t.Fatalf("addResourceLimitsAndRequests() error = %v", err)
+	}
+	if len(pods) != 2 {
+		t.Errorf("addResourceLimitsAndRequests() returned %d pods, want 2", len(pods))
+	}
+
+	want := map[string]*PodMetric{
+		"shop/web-0/app": {
+			Name: "web-0", Namespace: "shop", ContainerName: "app",
+			CPURequest: 0.25, CPULimit: 0.5, MemoryRequest: 128 << 20, MemoryLimit: 256 << 20,
+		},
+		"shop/web-0/sidecar": {Name: "web-0", Namespace: "shop", ContainerName: "sidecar", MemoryLimit: 64 << 20},
+	}
+	if !reflect.DeepEqual(podMetrics, want) {
+		t.Errorf("pod metrics = %+v, want %+v", podMetrics, want)
+	}
+}
+
+func TestMetricsServerPodSpecsUnavailable(t *testing.T) {
+	usage := testPodMetrics("shop", "web-0", map[string]corev1.ResourceList{"app": resources("120m", "100Mi")})
+	ms := newTestMetricsServerClient(MetricsClientConfig{}, nil, usage)
+	ms.clientset.(*kubefake.Clientset).PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+
+	// Usage is still returned, without requests, limits or a QoS class
+	ctx, warnings := WithQueryWarnings(context.Background())
+	got, err := ms.GetCurrentPodMetrics(ctx, "shop", "", time.Now())
+	if err != nil {
+		t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+	}
+	if len(got) != 1 || got[0].CPUUsage != 0.12 || got[0].CPURequest != 0 || got[0].MemoryLimit != 0 || got[0].QoSClass != "" {
+		t.Errorf("GetCurrentPodMetrics() = %+v, want the usage only", got)
+	}
+	if want := []string{WarningPodSpecsUnavailable}; !reflect.DeepEqual(warnings.Warnings(), want) {
+		t.Errorf("warnings = %v, want %v", warnings.Warnings(), want)
+	}
+}
+
+func TestMetricsServerContainerStatus(t *testing.T) {
+	pod := testPod("shop", "web-0", nil, map[string]corev1.ResourceRequirements{"app": {}})
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:                 "app",
+		RestartCount:         3,
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+	}}
+	usage := testPodMetrics("shop", "web-0", map[string]corev1.ResourceList{"app": resources("10m", "10Mi")})
+
+	tests := []struct {
+		name            string
+		containerStatus bool
+		wantRestarts    int
+		wantOOMKilled   bool
+	}{
+		{"disabled", false, 0, false},
+		{"enabled", true, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestMetricsServerClient(MetricsClientConfig{EnableContainerStatus: tt.containerStatus}, []runtime.Object{pod}, usage)
+			pods, err := ms.GetCurrentPodMetrics(context.Background(), "shop", "", time.Now())
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if len(pods) != 1 || pods[0].RestartCount != tt.wantRestarts || pods[0].OOMKilled != tt.wantOOMKilled {
+				t.Errorf("GetCurrentPodMetrics() = %+v, want %d restarts and OOMKilled %v", pods, tt.wantRestarts, tt.wantOOMKilled)
+			}
+
+			status, err := ms.GetPodStatus(context.Background(), "shop", "web-0")
+			if err != nil {
+				t.Fatalf("GetPodStatus() error = %v", err)
+			}
+			want := PodStatus{Phase: "Running", LastTerminationReasons: map[string]string{"app": "OOMKilled"}}
+			if !reflect.DeepEqual(status, want) {
+				t.Errorf("GetPodStatus() = %+v, want %+v", status, want)
+			}
+		})
+	}
+}
+
+func TestMetricsServerPodObjects(t *testing.T) {
+	objects := []runtime.Object{
+		testPod("shop", "web-0", map[string]string{"team": "checkout"}, map[string]corev1.ResourceRequirements{"app": {}}),
+		testPod("billing", "api-0", nil, map[string]corev1.ResourceRequirements{"app": {}}),
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-b"},
+			Status:     corev1.NodeStatus{Allocatable: resources("3920m", "15Gi")},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Status:     corev1.NodeStatus{Allocatable: resources("2", "8Gi")},
+		},
+	}
+	usage := []*metricsv1beta1.PodMetrics{
+		testPodMetrics("shop", "web-0", map[string]corev1.ResourceList{"app": resources("10m", "10Mi")}),
+		testPodMetrics("billing", "api-0", map[string]corev1.ResourceList{"app": resources("10m", "10Mi")}),
+	}
+	ms := newTestMetricsServerClient(MetricsClientConfig{}, objects, usage...)
+	ctx := context.Background()
+
+	namespaces, err := ms.GetNamespaces(ctx)
+	if err != nil {
+		t.Fatalf("GetNamespaces() error = %v", err)
+	}
+	if want := []string{"billing", "shop"}; !reflect.DeepEqual(namespaces, want) {
+		t.Errorf("GetNamespaces() = %v, want %v", namespaces, want)
+	}
+
+	nodes, err := ms.GetNodeAllocatable(ctx)
+	if err != nil {
+		t.Fatalf("GetNodeAllocatable() error = %v", err)
+	}
+	wantNodes := []NodeAllocatable{{Node: "node-a", CPU: 2, Memory: 8 << 30}, {Node: "node-b", CPU: 3.92, Memory: 15 << 30}}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("GetNodeAllocatable() = %+v, want %+v", nodes, wantNodes)
+	}
+
+	podNodes, err := ms.GetPodNodes(ctx, "shop", time.Now())
+	if err != nil {
+		t.Fatalf("GetPodNodes() error = %v", err)
+	}
+	if want := map[string]string{"shop/web-0": "node-a"}; !reflect.DeepEqual(podNodes, want) {
+		t.Errorf("GetPodNodes() = %v, want %v", podNodes, want)
+	}
+
+	podLabels, err := ms.GetPodLabels(ctx, "", time.Now())
+	if err != nil {
+		t.Fatalf("GetPodLabels() error = %v", err)
+	}
+	if want := map[string]map[string]string{"shop/web-0": {"team": "checkout"}}; !reflect.DeepEqual(podLabels, want) {
+		t.Errorf("GetPodLabels() = %v, want %v", podLabels, want)
+	}
+}
@@ -0,0 +1,24 @@
+package k8s
+
+// podNodesQuery returns the node of each pod in namespace from kube-state-metrics, all namespaces when empty
+func podNodesQuery(queries QueryTemplates, namespace string) string {
+	namespaceFilter := ""
+	if namespace != "" {
+		namespaceFilter = `namespace="` + namespace + `"`
+	}
+	return `group by (namespace, pod, node) (` + queries.Selector(QueryPodInfo, `node!=""`, namespaceFilter) + `)`
+}
+
+// onNode restricts query to the pods of namespace scheduled on node, joining it with the kube-state-metrics
+// pod to node mapping. query is returned as is without a node.
+func onNode(queries QueryTemplates, query, namespace, node string) string {
+	if node == "" {
+		return query
+	}
+	namespaceFilter := ""
+	if namespace != "" {
+		namespaceFilter = `namespace="` + namespace + `"`
+	}
+	return `(` + query + `) * on (namespace, pod) group_left () group by (namespace, pod) (` +
+		queries.Selector(QueryPodInfo, `node="`+node+`"`, namespaceFilter) + `)`
+}
@@ -0,0 +1,24 @@
+package k8s
+
+import (
+	"time"
+)
+
+// podStartTimeQuery returns the kube-state-metrics start time of the pods in namespace, in Unix seconds
+func podStartTimeQuery(queries QueryTemplates, namespace string) string {
+	return `max by (namespace, pod) (` + queries.Selector(QueryPodStartTime, namespaceMatcher(namespace)) + `)`
+}
+
+// setPodStartTimes sets the start time of each pod metric, keyed by namespace/pod in startTimes
+func setPodStartTimes(podMetrics map[string]*PodMetric, startTimes map[string]time.Time) {
+	for _, metric := range podMetrics {
+		metric.StartTime = startTimes[metric.Namespace+"/"+metric.Name]
+	}
+}
+
+// setHistoryCoverage sets the start time of the pod and flags it when it started after windowStart,
+// i.e. its history does not cover the whole analysis window
+func setHistoryCoverage(metrics *HistoricalMetrics, startTime, windowStart time.Time) {
+	metrics.StartTime = startTime
+	metrics.InsufficientHistory = !startTime.IsZero() && startTime.After(windowStart)
+}
@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetHistoryCoverage(t *testing.T) {
+	windowStart := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		startTime time.Time
+		want      bool
+	}{
+		{"started an hour into the window", windowStart.Add(time.Hour), true},
+		{"started before the window", windowStart.Add(-time.Hour), false},
+		{"started at the window start", windowStart, false},
+		{"unknown start time", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metrics HistoricalMetrics
+			setHistoryCoverage(&metrics, tt.startTime, windowStart)
+			if metrics.InsufficientHistory != tt.want || !metrics.StartTime.Equal(tt.startTime) {
+				t.Errorf("insufficient history = %v, start time = %s, want %v, %s", metrics.InsufficientHistory, metrics.StartTime, tt.want, tt.startTime)
+			}
+		})
+	}
+}
+
+func TestPodStartTimes(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	started := map[string]time.Time{
+		"new-0": now.Add(-time.Hour),           // inside the 7 day window
+		"old-0": now.Add(-30 * 24 * time.Hour), // before the window
+	}
+	respond := func(query string) string {
+		var series []string
+		switch {
+		case strings.Contains(query, "kube_pod_start_time"):
+			for pod, start := range started {
+				series = append(series, `{"metric":{"namespace":"shop","pod":"`+pod+`"},"value":[1700000000,"`+strconv.FormatInt(start.Unix(), 10)+`"]}`)
+			}
+		case strings.HasPrefix(query, "group by (pod, namespace, container)"),
+			strings.Contains(query, "container_memory_working_set_bytes") && !strings.Contains(query, "timestamp("):
+			for _, pod := range []string{"new-0", "old-0", "unknown-0"} {
+				series = append(series, `{"metric":{"namespace":"shop","pod":"`+pod+`","container":"app"},"value":[1700000000,"1"]}`)
+			}
+		}
+		return "[" + strings.Join(series, ",") + "]"
+	}
+	clients := map[string]func(t *testing.T) MetricsClient{
+		"prometheus": func(t *testing.T) MetricsClient {
+			return newTestPrometheusClient(t, newPromServer(t, respond), MetricsClientConfig{})
+		},
+		"victoriametrics": func(t *testing.T) MetricsClient {
+			return newTestVMClient(t, newVMServer(t, func(r *http.Request) string { return respond(r.URL.Query().Get("query")) }), MetricsClientConfig{})
+		},
+	}
+	for client, newClient := range clients {
+		t.Run(client, func(t *testing.T) {
+			metricsClient := newClient(t)
+
+			current, err := metricsClient.GetCurrentPodMetrics(context.Background(), "shop", "", now)
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if len(current) != 3 {
+				t.Fatalf("GetCurrentPodMetrics() = %d containers, want 3", len(current))
+			}
+			for _, metric := range current {
+				if !metric.StartTime.Equal(started[metric.Name]) {
+					t.Errorf("%s start time = %s, want %s", metric.Name, metric.StartTime, started[metric.Name])
+				}
+			}
+
+			// The range queries return no samples, only the pod list and start times matter here
+			historical, err := metricsClient.GetHistoricalMetrics(context.Background(), "shop", HistoricalOptions{})
+			if err != nil {
+				t.Fatalf("GetHistoricalMetrics() error = %v", err)
+			}
+			want := map[string]bool{"new-0": true, "old-0": false, "unknown-0": false}
+			if len(historical) != len(want) {
+				t.Fatalf("GetHistoricalMetrics() = %d containers, want %d", len(historical), len(want))
+			}
+			for _, hm := range historical {
+				if hm.InsufficientHistory != want[hm.PodName] || !hm.StartTime.Equal(started[hm.PodName]) {
+					t.Errorf("%s insufficient history = %v, start time = %s, want %v, %s", hm.PodName, hm.InsufficientHistory, hm.StartTime, want[hm.PodName], started[hm.PodName])
+				}
+			}
+		})
+	}
+}
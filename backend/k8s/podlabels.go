@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"strings"
+)
+
+// podLabelPrefix prefixes the Kubernetes pod labels exposed as kube_pod_labels series labels
+const podLabelPrefix = "label_"
+
+// podLabelsQuery returns the kube-state-metrics label series of the pods in namespace
+func podLabelsQuery(queries QueryTemplates, namespace string) string {
+	return queries.Selector(QueryPodLabels, namespaceMatcher(namespace))
+}
+
+// addPodLabels adds the pod labels of a kube_pod_labels series to the labels of its pod, keyed by namespace/pod.
+// kube-state-metrics sanitizes label names, e.g. app.kubernetes.io/name becomes app_kubernetes_io_name.
+func addPodLabels(podLabels map[string]map[string]string, series map[string]string) {
+	key := series["namespace"] + "/" + series["pod"]
+	for name, value := range series {
+		if !strings.HasPrefix(name, podLabelPrefix) || value == "" {
+			continue
+		}
+		if podLabels[key] == nil {
+			podLabels[key] = make(map[string]string)
+		}
+		podLabels[key][strings.TrimPrefix(name, podLabelPrefix)] = value
+	}
+}
+
+// setPodLabels copies the labels of each pod, keyed by namespace/pod, to its container metrics
+func setPodLabels(podMetrics map[string]*PodMetric, podLabels map[string]map[string]string) {
+	for _, metric := range podMetrics {
+		for name, value := range podLabels[metric.Namespace+"/"+metric.Name] {
+			metric.Labels[name] = value
+		}
+	}
+}
+
+// SanitizeLabelName converts a Kubernetes label name to the form kube-state-metrics exposes,
+// replacing every character other than letters, digits and underscores with an underscore
+func SanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
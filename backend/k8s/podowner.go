@@ -0,0 +1,30 @@
+package k8s
+
+import (
+	"regexp"
+	"time"
+)
+
+// podOwnerQuery returns the kube-state-metrics controllers of the pods in namespace seen during window
+func podOwnerQuery(queries QueryTemplates, namespace string, window time.Duration) string {
+	selector := queries.Selector(QueryPodOwner, namespaceMatcher(namespace), `owner_is_controller="true"`)
+	return `max by (namespace, pod, owner_kind, owner_name) (max_over_time(` + selector + `[` + promDuration(window) + `]))`
+}
+
+// replicaSetPattern matches the name of a ReplicaSet created by a Deployment, capturing the Deployment
+var replicaSetPattern = regexp.MustCompile(`^(.+)-[bcdfghjklmnpqrstvwxz2456789]{6,10}$`)
+
+// addPodOwner adds the owner of a kube_pod_owner series to owners, keyed by namespace/pod. A ReplicaSet is
+// replaced by its Deployment by stripping the pod template hash from its name.
+func addPodOwner(owners map[string]string, series map[string]string) {
+	owner := series["owner_name"]
+	if owner == "" || owner == "<none>" {
+		return
+	}
+	if series["owner_kind"] == "ReplicaSet" {
+		if m := replicaSetPattern.FindStringSubmatch(owner); m != nil {
+			owner = m[1]
+		}
+	}
+	owners[series["namespace"]+"/"+series["pod"]] = owner
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddPodOwner(t *testing.T) {
+	tests := []struct {
+		name   string
+		series map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "deployment through its replicaset",
+			series: map[string]string{"namespace": "shop", "pod": "web-7d4b9c8f6d-x2k4p", "owner_kind": "ReplicaSet", "owner_name": "web-7d4b9c8f6d"},
+			want:   map[string]string{"shop/web-7d4b9c8f6d-x2k4p": "web"},
+		},
+		{
+			name:   "replicaset without a deployment hash",
+			series: map[string]string{"namespace": "shop", "pod": "legacy-x2k4p", "owner_kind": "ReplicaSet", "owner_name": "legacy"},
+			want:   map[string]string{"shop/legacy-x2k4p": "legacy"},
+		},
+		{
+			name:   "statefulset",
+			series: map[string]string{"namespace": "db", "pod": "postgres-0", "owner_kind": "StatefulSet", "owner_name": "postgres"},
+			want:   map[string]string{"db/postgres-0": "postgres"},
+		},
+		{
+			name:   "job",
+			series: map[string]string{"namespace": "jobs", "pod": "sync-28000000-b4k2x", "owner_kind": "Job", "owner_name": "sync-28000000"},
+			want:   map[string]string{"jobs/sync-28000000-b4k2x": "sync-28000000"},
+		},
+		{
+			name:   "no owner",
+			series: map[string]string{"namespace": "shop", "pod": "debug", "owner_kind": "<none>", "owner_name": "<none>"},
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owners := make(map[string]string)
+			addPodOwner(owners, tt.series)
+			if !reflect.DeepEqual(owners, tt.want) {
+				t.Errorf("owners = %v, want %v", owners, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodOwnerQuery(t *testing.T) {
+	queries := QueryTemplates{ExtraFilters: `cluster="prod"`}
+	want := `max by (namespace, pod, owner_kind, owner_name) (max_over_time(kube_pod_owner{namespace=~"shop", owner_is_controller="true", cluster="prod"}[604800s]))`
+	if got := podOwnerQuery(queries, "shop", 7*24*time.Hour); got != want {
+		t.Errorf("podOwnerQuery() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"fmt"
+	"time"
+)
+
+// QueryPreviewer is implemented by metrics clients that run PromQL queries, to list the
+// queries they would run without running them
+type QueryPreviewer interface {
+	// PreviewQueries returns the queries of the current metrics of namespace and of the
+	// historical analysis of a container, the latter only when pod and container are set
+	PreviewQueries(namespace, pod, container string) QueryPreview
+}
+
+// QueryPreview lists the PromQL queries of current and historical metrics
+type QueryPreview struct {
+	Current    []string
+	Historical []string
+}
+
+// containerQueries are the PromQL queries for the usage, requests, limits and status of containers
+type containerQueries struct {
+	cpuUsage       string
+	memoryUsage    string
+	sampleTime     string // Newest memory sample timestamp, current metrics only
+	cpuRequests    string
+	cpuLimits      string
+	memoryRequests string
+	memoryLimits   string
+	restarts       string
+	oomKilled      string
+	restartRate    string // Hourly restart rate, historical metrics only
+}
+
+// currentQueries returns the instant queries of the current metrics of namespace.
+// selector and node, when set, only apply to the usage queries.
+func currentQueries(config MetricsClientConfig, namespace, node, selector string) containerQueries {
+	queries := config.Queries
+	namespaceFilter := ""
+	if namespace != "" {
+		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
+	}
+
+	memSelector := queries.Selector(QueryMemoryUsage, queries.BaseContainerFilter(), namespaceFilter, selector)
+	return containerQueries{
+		cpuUsage:       onNode(queries, queries.CPUUnit.usageQuery(queries.Selector(QueryCPUUsage, queries.BaseContainerFilter(), namespaceFilter, selector), instantRateWindow(config.ScrapeInterval, config.InstantLookback), config.InstantLookback), namespace, node),
+		memoryUsage:    onNode(queries, withLookback(memSelector, config.InstantLookback), namespace, node),
+		sampleTime:     `timestamp(` + memSelector + `)`,
+		cpuRequests:    withLookback(queries.Selector(QueryResourceRequests, queries.BaseContainerFilter(), `resource="cpu"`, namespaceFilter), config.InstantLookback),
+		cpuLimits:      withLookback(queries.Selector(QueryResourceLimits, queries.BaseContainerFilter(), `resource="cpu"`, namespaceFilter), config.InstantLookback),
+		memoryRequests: withLookback(queries.Selector(QueryResourceRequests, queries.BaseContainerFilter(), `resource="memory"`, namespaceFilter), config.InstantLookback),
+		memoryLimits:   withLookback(queries.Selector(QueryResourceLimits, queries.BaseContainerFilter(), `resource="memory"`, namespaceFilter), config.InstantLookback),
+		restarts:       queries.Selector(QueryRestarts, queries.BaseContainerFilter(), namespaceFilter),
+		oomKilled:      queries.Selector(QueryTerminatedReason, queries.BaseContainerFilter(), `reason="OOMKilled"`, namespaceFilter),
+	}
+}
+
+// historicalQueries returns the range queries of a container's historical analysis, and the
+// instant queries of its restarts and OOMKills over the whole window
+func historicalQueries(config MetricsClientConfig, namespace, pod, container string, start, end time.Time) containerQueries {
+	queries := config.Queries
+	containerFilter := fmt.Sprintf(`namespace="%s", pod="%s", container="%s"`, namespace, pod, container)
+	window := fmt.Sprintf("%ds", int(end.Sub(start).Seconds()))
+
+	return containerQueries{
+		cpuUsage:       queries.CPUUnit.usageQuery(queries.Selector(QueryCPUUsage, containerFilter), rateWindow(config.ScrapeInterval), 0),
+		memoryUsage:    queries.Selector(QueryMemoryUsage, containerFilter),
+		cpuRequests:    queries.Selector(QueryResourceRequests, containerFilter, `resource="cpu"`),
+		cpuLimits:      queries.Selector(QueryResourceLimits, containerFilter, `resource="cpu"`),
+		memoryRequests: queries.Selector(QueryResourceRequests, containerFilter, `resource="memory"`),
+		memoryLimits:   queries.Selector(QueryResourceLimits, containerFilter, `resource="memory"`),
+		restarts:       fmt.Sprintf(`increase(%s[%s])`, queries.Selector(QueryRestarts, containerFilter), window),
+		oomKilled:      fmt.Sprintf(`max_over_time(%s[%s])`, queries.Selector(QueryTerminatedReason, containerFilter, `reason="OOMKilled"`), window),
+		restartRate:    `rate(` + queries.Selector(QueryRestarts, containerFilter) + `[1h])`,
+	}
+}
+
+// activePodsQuery returns the query listing the containers of namespace with CPU usage, over the
+// same rate window as the current CPU usage query
+func activePodsQuery(config MetricsClientConfig, namespace string) string {
+	queries := config.Queries
+	usage := queries.CPUUnit.usageQuery(queries.Selector(QueryCPUUsage, namespaceMatcher(namespace), queries.BaseContainerFilter()), instantRateWindow(config.ScrapeInterval, config.InstantLookback), config.InstantLookback)
+	return `group by (pod, namespace, container) (` + usage + `)`
+}
+
+// previewQueries lists the PromQL queries the Prometheus and VictoriaMetrics clients run, in order
+func previewQueries(config MetricsClientConfig, namespace, pod, container string) QueryPreview {
+	current := currentQueries(config, namespace, "", "")
+	preview := QueryPreview{
+		Current: []string{current.cpuUsage, current.memoryUsage, current.sampleTime,
+			current.cpuRequests, current.cpuLimits, current.memoryRequests, current.memoryLimits},
+	}
+	if config.EnableContainerStatus {
+		preview.Current = append(preview.Current, current.restarts, current.oomKilled)
+	}
+	preview.Current = append(preview.Current, podStartTimeQuery(config.Queries, namespace), podLabelsQuery(config.Queries, namespace))
+
+	preview.Historical = []string{activePodsQuery(config, namespace), podStartTimeQuery(config.Queries, namespace)}
+	if pod == "" || container == "" {
+		return preview
+	}
+	end := time.Now()
+	historical := historicalQueries(config, namespace, pod, container, end.Add(-7*24*time.Hour), end)
+	preview.Historical = append(preview.Historical, historical.cpuUsage, historical.memoryUsage,
+		historical.cpuRequests, historical.memoryRequests, historical.cpuLimits, historical.memoryLimits)
+	if config.EnableContainerStatus {
+		preview.Historical = append(preview.Historical, historical.restarts, historical.oomKilled)
+	}
+	if config.EnableRestartTrend {
+		preview.Historical = append(preview.Historical, historical.restartRate)
+	}
+	return preview
+}
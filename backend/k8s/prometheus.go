@@ -2,8 +2,10 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
@@ -14,24 +16,33 @@ import (
 // PrometheusClient wraps the Prometheus API client
 type PrometheusClient struct {
 	client v1.API
+	config MetricsClientConfig
 }
 
 // NewPrometheusClient creates a new Prometheus client
-func NewPrometheusClient(prometheusURL string) (*PrometheusClient, error) {
-	config := api.Config{
-		Address: prometheusURL,
+func NewPrometheusClient(config MetricsClientConfig) (*PrometheusClient, error) {
+	apiConfig := api.Config{
+		Address:      config.URL,
+		RoundTripper: newTransport(config),
 	}
 
-	client, err := api.NewClient(config)
+	client, err := api.NewClient(apiConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Prometheus client: %w", err)
 	}
 
 	return &PrometheusClient{
-		client: v1.NewAPI(client),
+		client: finiteAPI{v1.NewAPI(client)},
+		config: config,
 	}, nil
 }
 
+// Ping runs a cheap instant query to check Prometheus is reachable
+func (p *PrometheusClient) Ping(ctx context.Context) error {
+	_, _, err := p.client.Query(ctx, "vector(1)", time.Now())
+	return err
+}
+
 // Close closes the Prometheus client connection
 func (p *PrometheusClient) Close() error {
 	// Prometheus client doesn't require explicit closing
@@ -51,6 +62,15 @@ type HistoricalMetrics struct {
 	CPU           HistoricalResourceData `json:"cpu"`
 	Memory        HistoricalResourceData `json:"memory"`
 	Analysis      UsageAnalysis          `json:"analysis"`
+	RestartCount  int                    `json:"restartCount"`           // Restarts during the analyzed window
+	OOMKilled     bool                   `json:"oomKilled"`              // OOMKilled during the analyzed window
+	RestartTrend  string                 `json:"restartTrend,omitempty"` // Trend of the hourly restart rate, when enabled
+	Step          time.Duration          `json:"step"`                   // Resolution of the range queries
+	StepCoarsened bool                   `json:"stepCoarsened"`          // Step was coarsened to respect the sample cap
+	Completed     bool                   `json:"completed,omitempty"`    // Pod completed during the window, so it has no current usage
+	StartTime     time.Time              `json:"startTime"`              // Pod start time, zero if not reported
+	// Pod started after the beginning of the analysis window, so its history does not cover it
+	InsufficientHistory bool `json:"insufficientHistory"`
 }
 
 // HistoricalResourceData contains historical resource usage data
@@ -64,6 +84,8 @@ type HistoricalResourceData struct {
 	P95        float64     `json:"p95"`
 	P99        float64     `json:"p99"`
 	Trend      string      `json:"trend"` // "increasing", "decreasing", "stable"
+	// Percentage of expected usage points (given the step) that were returned
+	DataCompleteness float64 `json:"dataCompleteness"`
 }
 
 // DataPoint represents a single metric data point
@@ -99,31 +121,69 @@ type UsagePatterns struct {
 	WeeklyVariation float64 `json:"weeklyVariation"` // Variation across week
 }
 
-// GetHistoricalMetrics retrieves and analyzes 7-day historical metrics for pods
-func (p *PrometheusClient) GetHistoricalMetrics(ctx context.Context, namespace string) ([]HistoricalMetrics, error) {
+// GetHistoricalMetrics retrieves and analyzes historical metrics for pods over the analysis window (7 days by default)
+func (p *PrometheusClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions) ([]HistoricalMetrics, error) {
+	var results []HistoricalMetrics
+	err := p.StreamHistoricalMetrics(ctx, namespace, opts, func(metrics HistoricalMetrics) error {
+		results = append(results, metrics)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return results, nil
+}
+
+// StreamHistoricalMetrics analyzes historical metrics over the analysis window and passes each container result to fn as soon as it is computed
+func (p *PrometheusClient) StreamHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions, fn func(HistoricalMetrics) error) error {
 	now := time.Now()
-	sevenDaysAgo := now.Add(-7 * 24 * time.Hour)
+	windowStart := now.Add(-analysisWindow(opts.Window))
+	
+	// Get pod list from the analysis window
+	stop := startTiming(ctx, TimingActivePods)
+	pods, err := p.getActivePods(ctx, namespace, windowStart, now)
+	stop()
+	if err != nil {
+		return fmt.Errorf("failed to get active pods: %w", err)
+	}
+	
+	// Add pods that completed during the window, e.g. finished Jobs, when requested
+	if opts.IncludeCompleted {
+		stop = startTiming(ctx, TimingCompletedPods)
+		completed, err := p.getCompletedPods(ctx, namespace, windowStart, now)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to get completed pods: %v", err)
+			recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+		} else {
+			pods = mergeCompletedPods(pods, completed)
+		}
+	}
 	
-	// Get pod list from the last 7 days
-	pods, err := p.getActivePods(ctx, namespace, sevenDaysAgo, now)
+	// Get pod start times to flag pods younger than the window
+	startTimes, err := p.getPodStartTimes(ctx, namespace, now)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get active pods: %w", err)
+		log.Printf("Warning: failed to get pod start times: %v", err)
 	}
 
-	var results []HistoricalMetrics
 	for _, pod := range pods {
 		for _, container := range pod.Containers {
-			metrics, err := p.getHistoricalMetricsForContainer(ctx, pod.Name, pod.Namespace, container, sevenDaysAgo, now)
+			metrics, err := p.getHistoricalMetricsForContainer(ctx, pod.Name, pod.Namespace, container, windowStart, now)
 			if err != nil {
 				log.Printf("Warning: failed to get metrics for pod %s/%s container %s: %v", 
 					pod.Namespace, pod.Name, container, err)
 				continue
 			}
-			results = append(results, metrics)
+			metrics.Completed = pod.Completed
+			setHistoryCoverage(&metrics, startTimes[pod.Namespace+"/"+pod.Name], windowStart)
+			if err := fn(metrics); err != nil {
+				return err
+			}
 		}
 	}
 
-	return results, nil
+	return nil
 }
 
 // PodInfo represents basic pod information
@@ -131,19 +191,25 @@ type PodInfo struct {
 	Name       string   `json:"name"`
 	Namespace  string   `json:"namespace"`
 	Containers []string `json:"containers"`
+	Completed  bool     `json:"completed,omitempty"` // Completed during the window rather than still running
+}
+
+// GetActivePods retrieves the pods that were active during the analysis window (7 days by default)
+func (p *PrometheusClient) GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]PodInfo, error) {
+	now := time.Now()
+	return p.getActivePods(ctx, namespace, now.Add(-analysisWindow(window)), now)
 }
 
 // getActivePods retrieves pods that were active during the specified time range
 func (p *PrometheusClient) getActivePods(ctx context.Context, namespace string, start, end time.Time) ([]PodInfo, error) {
-	query := `group by (pod, namespace, container) (
-		rate(container_cpu_usage_seconds_total{namespace=~"` + namespace + `", container!="POD", container!=""}[5m])
-	)`
+	query := activePodsQuery(p.config, namespace)
 	
 	result, warnings, err := p.client.Query(ctx, query, end)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active pods: %w", err)
 	}
 	
+	recordWarnings(ctx, warnings)
 	if len(warnings) > 0 {
 		log.Printf("Prometheus query warnings: %v", warnings)
 	}
@@ -156,11 +222,6 @@ func (p *PrometheusClient) getActivePods(ctx context.Context, namespace string,
 			ns := string(sample.Metric["namespace"])
 			container := string(sample.Metric["container"])
 			
-			// Filter by namespace if specified
-			if namespace != "" && ns != namespace {
-				continue
-			}
-			
 			key := ns + "/" + pod
 			if existing, exists := podMap[key]; exists {
 				// Add container to existing pod
@@ -184,65 +245,156 @@ func (p *PrometheusClient) getActivePods(ctx context.Context, namespace string,
 	return pods, nil
 }
 
+// getCompletedPods retrieves pods that completed during the specified time range
+func (p *PrometheusClient) getCompletedPods(ctx context.Context, namespace string, start, end time.Time) ([]PodInfo, error) {
+	result, warnings, err := p.client.Query(ctx, completedPodsQuery(p.config.Queries, namespace, start, end), end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completed pods: %w", err)
+	}
+	recordWarnings(ctx, warnings)
+	
+	var refs []containerRef
+	if vector, ok := result.(model.Vector); ok {
+		for _, sample := range vector {
+			refs = append(refs, containerRef{
+				namespace: string(sample.Metric["namespace"]),
+				pod:       string(sample.Metric["pod"]),
+				container: string(sample.Metric["container"]),
+			})
+		}
+	}
+	
+	return groupCompletedPods(refs), nil
+}
+
+// getPodStartTimes retrieves the start time of each pod in namespace, keyed by namespace/pod
+func (p *PrometheusClient) getPodStartTimes(ctx context.Context, namespace string, at time.Time) (map[string]time.Time, error) {
+	result, warnings, err := p.client.Query(ctx, podStartTimeQuery(p.config.Queries, namespace), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod start times: %w", err)
+	}
+	recordWarnings(ctx, warnings)
+	
+	startTimes := make(map[string]time.Time)
+	if vector, ok := result.(model.Vector); ok {
+		for _, sample := range vector {
+			key := string(sample.Metric["namespace"]) + "/" + string(sample.Metric["pod"])
+			startTimes[key] = time.Unix(int64(sample.Value), 0)
+		}
+	}
+	
+	return startTimes, nil
+}
+
+// GetContainerSeries retrieves the raw datapoints of a single container metric
+func (p *PrometheusClient) GetContainerSeries(ctx context.Context, namespace, pod, container, metric string, start, end time.Time) ([]DataPoint, error) {
+	query, err := seriesQuery(p.config.Queries, p.config.ScrapeInterval, metric, namespace, pod, container)
+	if err != nil {
+		return nil, err
+	}
+	points, err := p.queryRangeMetric(ctx, query, start, end)
+	if err != nil || metric != SeriesCPU {
+		return points, err
+	}
+	return p.config.Queries.CPUUnit.pointsToCores(points), nil
+}
+
+// PreviewQueries lists the PromQL queries of current and historical metrics without running them
+func (p *PrometheusClient) PreviewQueries(namespace, pod, container string) QueryPreview {
+	return previewQueries(p.config, namespace, pod, container)
+}
+
 // getHistoricalMetricsForContainer retrieves and analyzes historical metrics for a specific container
 func (p *PrometheusClient) getHistoricalMetricsForContainer(ctx context.Context, pod, namespace, container string, start, end time.Time) (HistoricalMetrics, error) {
+	step, coarsened := rangeStep(start, end, p.config.MaxSamplesPerSeries)
+	queries := historicalQueries(p.config, namespace, pod, container, start, end)
+
 	// Query CPU usage over time
-	cpuUsage, err := p.queryRangeMetric(ctx, 
-		fmt.Sprintf(`rate(container_cpu_usage_seconds_total{namespace="%s", pod="%s", container="%s"}[5m])`, 
-			namespace, pod, container), start, end)
+	stop := startTiming(ctx, TimingCPUUsage)
+	cpuUsage, err := p.queryRangeMetric(ctx, queries.cpuUsage, start, end)
+	stop()
 	if err != nil {
 		return HistoricalMetrics{}, fmt.Errorf("failed to query CPU usage: %w", err)
 	}
+	cpuUsage = p.config.Queries.CPUUnit.pointsToCores(cpuUsage)
 
 	// Query Memory usage over time
-	memUsage, err := p.queryRangeMetric(ctx,
-		fmt.Sprintf(`container_memory_working_set_bytes{namespace="%s", pod="%s", container="%s"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingMemoryUsage)
+	memUsage, err := p.queryRangeMetric(ctx, queries.memoryUsage, start, end)
+	stop()
 	if err != nil {
 		return HistoricalMetrics{}, fmt.Errorf("failed to query memory usage: %w", err)
 	}
 
 	// Query CPU requests
-	cpuRequests, err := p.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_requests{namespace="%s", pod="%s", container="%s", resource="cpu"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingCPURequests)
+	cpuRequests, err := p.queryRangeMetric(ctx, queries.cpuRequests, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query CPU requests for %s/%s/%s: %v", namespace, pod, container, err)
 		cpuRequests = []DataPoint{} // Continue without requests data
 	}
 
 	// Query Memory requests
-	memRequests, err := p.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_requests{namespace="%s", pod="%s", container="%s", resource="memory"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingMemoryRequests)
+	memRequests, err := p.queryRangeMetric(ctx, queries.memoryRequests, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query memory requests for %s/%s/%s: %v", namespace, pod, container, err)
 		memRequests = []DataPoint{} // Continue without requests data
 	}
 
 	// Query CPU limits
-	cpuLimits, err := p.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_limits{namespace="%s", pod="%s", container="%s", resource="cpu"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingCPULimits)
+	cpuLimits, err := p.queryRangeMetric(ctx, queries.cpuLimits, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query CPU limits for %s/%s/%s: %v", namespace, pod, container, err)
 		cpuLimits = []DataPoint{} // Continue without limits data
 	}
 
 	// Query Memory limits
-	memLimits, err := p.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_limits{namespace="%s", pod="%s", container="%s", resource="memory"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingMemoryLimits)
+	memLimits, err := p.queryRangeMetric(ctx, queries.memoryLimits, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query memory limits for %s/%s/%s: %v", namespace, pod, container, err)
 		memLimits = []DataPoint{} // Continue without limits data
 	}
 
+	// Query container restarts and OOMKills
+	var restarts int
+	var oomKilled bool
+	if p.config.EnableContainerStatus {
+		stop = startTiming(ctx, TimingContainerStatus)
+		restarts, oomKilled, err = p.getContainerStatus(ctx, pod, namespace, container, start, end)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to query container status for %s/%s/%s: %v", namespace, pod, container, err)
+		}
+	}
+
+	// Query the hourly restart rate to detect crash loops building up over the window
+	var restartTrend string
+	if p.config.EnableRestartTrend {
+		stop = startTiming(ctx, TimingRestartTrend)
+		restartRate, err := p.queryRangeMetric(ctx, queries.restartRate, start, end)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to query restart rate for %s/%s/%s: %v", namespace, pod, container, err)
+		} else {
+			restartTrend = calculateTrend(p.config, restartRate, DefaultTrendThreshold)
+		}
+	}
+
 	// Analyze the data
-	cpuData := p.analyzeResourceData(cpuUsage, cpuRequests, cpuLimits)
-	memData := p.analyzeResourceData(memUsage, memRequests, memLimits)
-	
-	analysis := p.generateUsageAnalysis(cpuData, memData)
+	stop = startTiming(ctx, TimingAnalysis)
+	cpuData := analyzeResourceData(p.config, cpuUsage, cpuRequests, cpuLimits, p.config.CPUTrendThreshold)
+	memData := analyzeResourceData(p.config, memUsage, memRequests, memLimits, p.config.MemoryTrendThreshold)
+	analysis := generateUsageAnalysis(p.config, cpuData, memData, oomKilled, restartTrend)
+	stop()
+	cpuData.DataCompleteness = dataCompleteness(len(cpuUsage), start, end, step)
+	memData.DataCompleteness = dataCompleteness(len(memUsage), start, end, step)
 
 	return HistoricalMetrics{
 		PodName:       pod,
@@ -251,12 +403,99 @@ func (p *PrometheusClient) getHistoricalMetricsForContainer(ctx context.Context,
 		CPU:           cpuData,
 		Memory:        memData,
 		Analysis:      analysis,
+		RestartCount:  restarts,
+		OOMKilled:     oomKilled,
+		RestartTrend:  restartTrend,
+		Step:          step,
+		StepCoarsened: coarsened,
 	}, nil
 }
 
+// getContainerStatus retrieves restarts and OOMKill status for a container over the specified time range
+func (p *PrometheusClient) getContainerStatus(ctx context.Context, pod, namespace, container string, start, end time.Time) (int, bool, error) {
+	queries := historicalQueries(p.config, namespace, pod, container, start, end)
+	
+	restarts, err := p.queryScalar(ctx, queries.restarts, end)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query restarts: %w", err)
+	}
+	
+	oomKilled, err := p.queryScalar(ctx, queries.oomKilled, end)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query OOMKills: %w", err)
+	}
+	
+	return int(math.Round(restarts)), oomKilled > 0, nil
+}
+
+// queryScalar executes an instant query and returns the value of the first sample
+func (p *PrometheusClient) queryScalar(ctx context.Context, query string, ts time.Time) (float64, error) {
+	result, warnings, err := p.client.Query(ctx, query, ts)
+	if err != nil {
+		return 0, err
+	}
+	
+	recordWarnings(ctx, warnings)
+	if len(warnings) > 0 {
+		log.Printf("Prometheus query warnings: %v", warnings)
+	}
+	
+	if vector, ok := result.(model.Vector); ok && len(vector) > 0 {
+		return float64(vector[0].Value), nil
+	}
+	
+	return 0, nil
+}
+
+// QueryInstant executes an arbitrary instant query and returns the raw samples
+func (p *PrometheusClient) QueryInstant(ctx context.Context, query string) ([]QuerySample, error) {
+	result, warnings, err := p.client.Query(ctx, query, time.Now())
+	if err != nil {
+		var apiErr *v1.Error
+		if errors.As(err, &apiErr) && apiErr.Type == v1.ErrBadData {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, apiErr.Msg)
+		}
+		return nil, err
+	}
+	
+	recordWarnings(ctx, warnings)
+	if len(warnings) > 0 {
+		log.Printf("Prometheus query warnings: %v", warnings)
+	}
+	
+	var samples []QuerySample
+	switch value := result.(type) {
+	case model.Vector:
+		for _, sample := range value {
+			labels := make(map[string]string, len(sample.Metric))
+			for name, labelValue := range sample.Metric {
+				labels[string(name)] = string(labelValue)
+			}
+			samples = append(samples, QuerySample{
+				Metric:    labels,
+				Value:     float64(sample.Value),
+				Timestamp: sample.Timestamp.Time(),
+			})
+		}
+	case *model.Scalar:
+		samples = append(samples, QuerySample{
+			Metric:    map[string]string{},
+			Value:     float64(value.Value),
+			Timestamp: value.Timestamp.Time(),
+		})
+	default:
+		return nil, fmt.Errorf("%w: unsupported result type %s", ErrInvalidQuery, result.Type())
+	}
+	
+	return samples, nil
+}
+
 // queryRangeMetric executes a range query and returns data points
 func (p *PrometheusClient) queryRangeMetric(ctx context.Context, query string, start, end time.Time) ([]DataPoint, error) {
-	step := 5 * time.Minute // 5-minute resolution
+	step, _ := rangeStep(start, end, p.config.MaxSamplesPerSeries) // Coarsened to respect the sample cap
+	if p.config.AlignRangeQueries {
+		start, end = alignRange(start, end, step)
+	}
 	
 	result, warnings, err := p.client.QueryRange(ctx, query, v1.Range{
 		Start: start,
@@ -268,354 +507,298 @@ func (p *PrometheusClient) queryRangeMetric(ctx context.Context, query string, s
 		return nil, err
 	}
 	
+	recordWarnings(ctx, warnings)
 	if len(warnings) > 0 {
 		log.Printf("Prometheus query warnings: %v", warnings)
 	}
 
-	var dataPoints []DataPoint
+	var series [][]DataPoint
 	
 	if matrix, ok := result.(model.Matrix); ok {
-		for _, series := range matrix {
-			for _, value := range series.Values {
+		for _, stream := range matrix {
+			var dataPoints []DataPoint
+			for _, value := range stream.Values {
 				dataPoints = append(dataPoints, DataPoint{
 					Timestamp: value.Timestamp.Time(),
 					Value:     float64(value.Value),
 				})
 			}
+			series = append(series, dataPoints)
 		}
 	}
 	
-	return dataPoints, nil
+	// Merge series the query unexpectedly matched instead of interleaving their points
+	return mergeSeriesPoints(series, p.config.DuplicateAggregation), nil
 }
 
-// analyzeResourceData performs statistical analysis on resource data
-func (p *PrometheusClient) analyzeResourceData(usage, requests, limits []DataPoint) HistoricalResourceData {
-	if len(usage) == 0 {
-		return HistoricalResourceData{
-			Usage:    usage,
-			Requests: requests,
-			Limits:   limits,
-			Trend:    "unknown",
-		}
-	}
+// AnalyzeHistoricalMetrics recomputes the statistics, trends and analysis of hm from its series,
+// keeping its data completeness
+func (p *PrometheusClient) AnalyzeHistoricalMetrics(hm HistoricalMetrics) HistoricalMetrics {
+	return analyzeHistoricalMetrics(p.config, hm)
+}
 
-	// Calculate statistics
-	var total, min, max float64
-	min = usage[0].Value
-	max = usage[0].Value
+// GetNamespaces retrieves all namespaces from Prometheus metrics, falling back to container
+// metrics when kube-state-metrics is not installed
+func (p *PrometheusClient) GetNamespaces(ctx context.Context) ([]string, error) {
+	query := `group by (namespace) (` + p.config.Queries.Selector(QueryPodInfo) + `)`
 	
-	values := make([]float64, len(usage))
-	for i, point := range usage {
-		values[i] = point.Value
-		total += point.Value
-		if point.Value < min {
-			min = point.Value
-		}
-		if point.Value > max {
-			max = point.Value
-		}
+	namespaces, err := p.queryNamespaces(ctx, query)
+	if err == nil && len(namespaces) > 0 {
+		return namespaces, nil
+	}
+	if err != nil {
+		log.Printf("Warning: failed to query namespaces from kube-state-metrics: %v", err)
 	}
 	
-	average := total / float64(len(usage))
-	
-	// Calculate percentiles
-	p95 := p.calculatePercentile(values, 0.95)
-	p99 := p.calculatePercentile(values, 0.99)
+	// Use container metrics to get namespaces since we don't have kube-state-metrics
+	log.Printf("INFO: No kube-state-metrics namespaces found, falling back to container metrics")
+	recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+	query = `group by (namespace) (` + p.config.Queries.Selector(QueryCPUUsage, p.config.Queries.BaseContainerFilter()) + `)`
 	
-	// Determine trend
-	trend := p.calculateTrend(usage)
-
-	return HistoricalResourceData{
-		Usage:    usage,
-		Requests: requests,
-		Limits:   limits,
-		Average:  average,
-		Peak:     max,
-		Minimum:  min,
-		P95:      p95,
-		P99:      p99,
-		Trend:    trend,
-	}
+	return p.queryNamespaces(ctx, query)
 }
 
-// calculatePercentile calculates the specified percentile of a dataset
-func (p *PrometheusClient) calculatePercentile(values []float64, percentile float64) float64 {
-	if len(values) == 0 {
-		return 0
+// queryNamespaces returns the distinct namespace labels of the query result
+func (p *PrometheusClient) queryNamespaces(ctx context.Context, query string) ([]string, error) {
+	result, warnings, err := p.client.Query(ctx, query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query namespaces: %w", err)
 	}
 	
-	// Simple percentile calculation (could be improved with proper sorting)
-	n := len(values)
-	index := int(percentile * float64(n))
-	if index >= n {
-		index = n - 1
+	recordWarnings(ctx, warnings)
+	if len(warnings) > 0 {
+		log.Printf("Prometheus query warnings: %v", warnings)
 	}
+
+	var namespaces []string
+	namespacesSet := make(map[string]bool)
 	
-	// For simplicity, return a rough approximation
-	var sum float64
-	count := 0
-	for _, v := range values {
-		if count < index {
-			sum += v
-			count++
+	if vector, ok := result.(model.Vector); ok {
+		for _, sample := range vector {
+			namespace := string(sample.Metric["namespace"])
+			if namespace != "" && !namespacesSet[namespace] {
+				namespacesSet[namespace] = true
+				namespaces = append(namespaces, namespace)
+			}
 		}
 	}
 	
-	if count == 0 {
-		return 0
-	}
-	return sum / float64(count)
+	return namespaces, nil
 }
 
-// calculateTrend determines if the usage is increasing, decreasing, or stable
-func (p *PrometheusClient) calculateTrend(usage []DataPoint) string {
-	if len(usage) < 10 {
-		return "insufficient_data"
-	}
-	
-	// Simple trend calculation using first vs last quartile
-	quarterSize := len(usage) / 4
-	firstQuarter := usage[:quarterSize]
-	lastQuarter := usage[len(usage)-quarterSize:]
-	
-	var firstSum, lastSum float64
-	for _, point := range firstQuarter {
-		firstSum += point.Value
-	}
-	for _, point := range lastQuarter {
-		lastSum += point.Value
-	}
-	
-	firstAvg := firstSum / float64(len(firstQuarter))
-	lastAvg := lastSum / float64(len(lastQuarter))
-	
-	diff := (lastAvg - firstAvg) / firstAvg
-	
-	if diff > 0.1 { // 10% increase
-		return "increasing"
-	} else if diff < -0.1 { // 10% decrease
-		return "decreasing"
-	}
-	return "stable"
+// PodMetric represents current pod metrics. CPU usage is in CPUUnit as read from the backend (see
+// CPUUsageCores), CPU requests and limits are in cores, and memory values are in bytes.
+type PodMetric struct {
+	Name          string
+	Namespace     string
+	ContainerName string
+	CPUUsage      float64
+	CPUUnit       CPUUnit // Unit of CPUUsage; empty is cores
+	CPURequest    float64
+	CPULimit      float64
+	MemoryUsage   float64
+	MemoryRequest float64
+	MemoryLimit   float64
+	Labels        map[string]string
+	RestartCount  int
+	OOMKilled     bool
+	SampleTime    time.Time // Timestamp of the newest usage sample
+	StartTime     time.Time // Pod start time, zero if not reported
+	QoSClass      string    // Guaranteed, Burstable or BestEffort; empty if requests and limits are unknown
 }
 
-// generateUsageAnalysis creates usage analysis and recommendations
-func (p *PrometheusClient) generateUsageAnalysis(cpu, memory HistoricalResourceData) UsageAnalysis {
-	analysis := UsageAnalysis{
-		Recommendations: []string{},
-	}
-	
-	// Calculate efficiency if requests data is available
-	if len(cpu.Requests) > 0 && len(cpu.Requests[0:]) > 0 {
-		avgRequest := p.getAverageValue(cpu.Requests)
-		if avgRequest > 0 {
-			analysis.CPUEfficiency = (cpu.Average / avgRequest) * 100
-		}
-	}
-	
-	if len(memory.Requests) > 0 && len(memory.Requests[0:]) > 0 {
-		avgRequest := p.getAverageValue(memory.Requests)
-		if avgRequest > 0 {
-			analysis.MemoryEfficiency = (memory.Average / avgRequest) * 100
-		}
-	}
-	
-	// Generate waste analysis
-	analysis.ResourceWaste = p.generateWasteAnalysis(cpu, memory, analysis.CPUEfficiency, analysis.MemoryEfficiency)
-	
-	// Generate recommendations
-	analysis.Recommendations = p.generateRecommendations(cpu, memory, analysis.CPUEfficiency, analysis.MemoryEfficiency)
-	
-	// Generate patterns (simplified)
-	analysis.Patterns = UsagePatterns{
-		DailyVariation:  p.calculateVariation(cpu.Usage),
-		WeeklyVariation: p.calculateVariation(memory.Usage),
-	}
-	
-	return analysis
+// CPUUsageCores returns the CPU usage converted from CPUUnit to cores
+func (m PodMetric) CPUUsageCores() float64 {
+	return m.CPUUnit.ToCores(m.CPUUsage)
 }
 
-// getAverageValue calculates average of data points
-func (p *PrometheusClient) getAverageValue(points []DataPoint) float64 {
-	if len(points) == 0 {
-		return 0
-	}
-	
-	var sum float64
-	for _, point := range points {
-		sum += point.Value
-	}
-	return sum / float64(len(points))
+// NodeAllocatable represents the allocatable resources of a single node
+type NodeAllocatable struct {
+	Node   string
+	CPU    float64 // cores
+	Memory float64 // bytes
 }
 
-// generateWasteAnalysis identifies resource waste
-func (p *PrometheusClient) generateWasteAnalysis(cpu, memory HistoricalResourceData, cpuEff, memEff float64) ResourceWasteAnalysis {
-	waste := ResourceWasteAnalysis{}
-	
-	// CPU analysis
-	if cpuEff > 0 && cpuEff < 30 {
-		waste.CPUOverProvisioned = true
-		waste.CPUWastePercentage = 100 - cpuEff
-	} else if cpuEff > 80 {
-		waste.CPUUnderProvisioned = true
-	}
-	
-	// Memory analysis
-	if memEff > 0 && memEff < 30 {
-		waste.MemoryOverProvisioned = true
-		waste.MemoryWastePercentage = 100 - memEff
-	} else if memEff > 80 {
-		waste.MemoryUnderProvisioned = true
-	}
-	
-	return waste
+// PodStatus represents the status of a single pod from kube-state-metrics
+type PodStatus struct {
+	Phase                  string            // Running, Pending, Succeeded, Failed or Unknown; empty if not reported
+	LastTerminationReasons map[string]string // container -> reason of its last termination
 }
 
-// generateRecommendations creates actionable recommendations
-func (p *PrometheusClient) generateRecommendations(cpu, memory HistoricalResourceData, cpuEff, memEff float64) []string {
-	var recommendations []string
-	
-	if cpuEff > 0 && cpuEff < 30 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider reducing CPU requests - current efficiency: %.1f%%", cpuEff))
-	} else if cpuEff > 80 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider increasing CPU requests - current efficiency: %.1f%%", cpuEff))
-	}
-	
-	if memEff > 0 && memEff < 30 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider reducing memory requests - current efficiency: %.1f%%", memEff))
-	} else if memEff > 80 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider increasing memory requests - current efficiency: %.1f%%", memEff))
+// GetPodStatus retrieves the phase and last termination reasons of a pod from kube-state-metrics
+func (p *PrometheusClient) GetPodStatus(ctx context.Context, namespace, pod string) (PodStatus, error) {
+	status := PodStatus{LastTerminationReasons: make(map[string]string)}
+	podFilter := fmt.Sprintf(`namespace="%s", pod="%s"`, namespace, pod)
+	
+	// Get the current phase (the series for the active phase has value 1)
+	phaseResult, warnings, err := p.client.Query(ctx, p.config.Queries.Selector(QueryPodPhase, podFilter)+` == 1`, time.Now())
+	recordWarnings(ctx, warnings)
+	if err != nil {
+		return status, fmt.Errorf("failed to query pod phase: %w", err)
 	}
 	
-	if cpu.Trend == "increasing" {
-		recommendations = append(recommendations, "CPU usage is trending upward - monitor for potential scaling needs")
+	if phaseVector, ok := phaseResult.(model.Vector); ok {
+		for _, sample := range phaseVector {
+			status.Phase = string(sample.Metric["phase"])
+		}
 	}
 	
-	if memory.Trend == "increasing" {
-		recommendations = append(recommendations, "Memory usage is trending upward - monitor for potential memory leaks or scaling needs")
+	// Get the last termination reason per container
+	reasonResult, warnings, err := p.client.Query(ctx, p.config.Queries.Selector(QueryTerminatedReason, podFilter)+` > 0`, time.Now())
+	recordWarnings(ctx, warnings)
+	if err != nil {
+		return status, fmt.Errorf("failed to query last terminated reason: %w", err)
 	}
 	
-	if len(recommendations) == 0 {
-		recommendations = append(recommendations, "Resource usage appears well-optimized")
+	if reasonVector, ok := reasonResult.(model.Vector); ok {
+		for _, sample := range reasonVector {
+			status.LastTerminationReasons[string(sample.Metric["container"])] = string(sample.Metric["reason"])
+		}
 	}
 	
-	return recommendations
+	return status, nil
 }
 
-// calculateVariation calculates coefficient of variation
-func (p *PrometheusClient) calculateVariation(points []DataPoint) float64 {
-	if len(points) < 2 {
-		return 0
+// GetNodeAllocatable retrieves allocatable CPU and memory per node from kube-state-metrics
+func (p *PrometheusClient) GetNodeAllocatable(ctx context.Context) ([]NodeAllocatable, error) {
+	nodes := make(map[string]*NodeAllocatable)
+	
+	for _, resource := range []string{"cpu", "memory"} {
+		query := p.config.Queries.Selector(QueryNodeAllocatable, fmt.Sprintf(`resource="%s"`, resource))
+		
+		result, warnings, err := p.client.Query(ctx, query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query node allocatable %s: %w", resource, err)
+		}
+		recordWarnings(ctx, warnings)
+		if len(warnings) > 0 {
+			log.Printf("Prometheus query warnings: %v", warnings)
+		}
+		
+		if vector, ok := result.(model.Vector); ok {
+			for _, sample := range vector {
+				node := string(sample.Metric["node"])
+				if _, exists := nodes[node]; !exists {
+					nodes[node] = &NodeAllocatable{Node: node}
+				}
+				if resource == "cpu" {
+					nodes[node].CPU = float64(sample.Value)
+				} else {
+					nodes[node].Memory = float64(sample.Value)
+				}
+			}
+		}
 	}
 	
-	// Calculate mean
-	var sum float64
-	for _, point := range points {
-		sum += point.Value
+	var allocatable []NodeAllocatable
+	for _, node := range nodes {
+		allocatable = append(allocatable, *node)
 	}
-	mean := sum / float64(len(points))
 	
-	if mean == 0 {
-		return 0
+	return allocatable, nil
+}
+
+// GetPodNodes retrieves the node each pod is scheduled on from kube-state-metrics
+func (p *PrometheusClient) GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error) {
+	result, warnings, err := p.client.Query(ctx, podNodesQuery(p.config.Queries, namespace), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod nodes: %w", err)
 	}
+	recordWarnings(ctx, warnings)
 	
-	// Calculate variance
-	var variance float64
-	for _, point := range points {
-		variance += (point.Value - mean) * (point.Value - mean)
+	podNodes := make(map[string]string)
+	if vector, ok := result.(model.Vector); ok {
+		for _, sample := range vector {
+			key := string(sample.Metric["namespace"]) + "/" + string(sample.Metric["pod"])
+			podNodes[key] = string(sample.Metric["node"])
+		}
 	}
-	variance /= float64(len(points))
 	
-	// Return coefficient of variation (std dev / mean)
-	stdDev := variance // Simplified - should be sqrt(variance)
-	return stdDev / mean * 100
+	return podNodes, nil
 }
 
-// GetNamespaces retrieves all namespaces from Prometheus metrics
-func (p *PrometheusClient) GetNamespaces(ctx context.Context) ([]string, error) {
-	query := `group by (namespace) (kube_pod_info)`
-	
-	result, warnings, err := p.client.Query(ctx, query, time.Now())
+// GetPodLabels retrieves the Kubernetes labels of each pod from kube-state-metrics
+func (p *PrometheusClient) GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error) {
+	result, warnings, err := p.client.Query(ctx, podLabelsQuery(p.config.Queries, namespace), at)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query namespaces: %w", err)
+		return nil, fmt.Errorf("failed to query pod labels: %w", err)
 	}
+	recordWarnings(ctx, warnings)
 	
-	if len(warnings) > 0 {
-		log.Printf("Prometheus query warnings: %v", warnings)
+	podLabels := make(map[string]map[string]string)
+	if vector, ok := result.(model.Vector); ok {
+		for _, sample := range vector {
+			series := make(map[string]string, len(sample.Metric))
+			for name, value := range sample.Metric {
+				series[string(name)] = string(value)
+			}
+			addPodLabels(podLabels, series)
+		}
 	}
+	
+	return podLabels, nil
+}
 
-	var namespaces []string
-	namespacesSet := make(map[string]bool)
+// GetPodOwners retrieves the workload owning each pod active during the analysis window from kube-state-metrics
+func (p *PrometheusClient) GetPodOwners(ctx context.Context, namespace string, window time.Duration) (map[string]string, error) {
+	result, warnings, err := p.client.Query(ctx, podOwnerQuery(p.config.Queries, namespace, analysisWindow(window)), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod owners: %w", err)
+	}
+	recordWarnings(ctx, warnings)
 	
+	owners := make(map[string]string)
 	if vector, ok := result.(model.Vector); ok {
 		for _, sample := range vector {
-			namespace := string(sample.Metric["namespace"])
-			if namespace != "" && !namespacesSet[namespace] {
-				namespacesSet[namespace] = true
-				namespaces = append(namespaces, namespace)
+			series := make(map[string]string, len(sample.Metric))
+			for name, value := range sample.Metric {
+				series[string(name)] = string(value)
 			}
+			addPodOwner(owners, series)
 		}
 	}
 	
-	return namespaces, nil
+	return owners, nil
 }
 
-// PodMetric represents current pod metrics
-type PodMetric struct {
-	Name          string
-	Namespace     string
-	ContainerName string
-	CPUUsage      float64
-	CPURequest    float64
-	CPULimit      float64
-	MemoryUsage   float64
-	MemoryRequest float64
-	MemoryLimit   float64
-	Labels        map[string]string
+// GetCurrentPodMetrics retrieves current pod metrics from Prometheus
+func (p *PrometheusClient) GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]PodMetric, error) {
+	return p.GetNodePodMetrics(ctx, namespace, "", selector, at)
 }
 
-// GetCurrentPodMetrics retrieves current pod metrics from Prometheus
-func (p *PrometheusClient) GetCurrentPodMetrics(ctx context.Context, namespace string) ([]PodMetric, error) {
+// GetNodePodMetrics retrieves the current metrics of the pods scheduled on node from Prometheus, of all
+// nodes when empty. The usage queries are joined with the kube-state-metrics pod to node mapping.
+func (p *PrometheusClient) GetNodePodMetrics(ctx context.Context, namespace, node, selector string, at time.Time) ([]PodMetric, error) {
 	var pods []PodMetric
 	
-	// Build namespace filter
-	namespaceFilter := ""
-	if namespace != "" {
-		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
-	}
+	queries := currentQueries(p.config, namespace, node, selector)
 	
 	// Get current CPU usage
-	cpuQuery := `rate(container_cpu_usage_seconds_total{container!="POD", container!=""`
-	if namespaceFilter != "" {
-		cpuQuery += "," + namespaceFilter
-	}
-	cpuQuery += `}[5m])`
+	cpuQuery := queries.cpuUsage
 	
 	// DEBUG: Log the exact CPU query being executed
 	log.Printf("DEBUG: Executing CPU query: %s", cpuQuery)
 	
-	cpuResult, warnings, err := p.client.Query(ctx, cpuQuery, time.Now())
+	cpuResult, warnings, err := p.client.Query(ctx, cpuQuery, at)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query CPU usage: %w", err)
 	}
+	recordWarnings(ctx, warnings)
 	if len(warnings) > 0 {
 		log.Printf("CPU query warnings: %v", warnings)
 	}
 	
 	// Get current Memory usage
-	memQuery := `container_memory_working_set_bytes{container!="POD", container!=""`
-	if namespaceFilter != "" {
-		memQuery += "," + namespaceFilter
-	}
-	memQuery += `}`
+	memQuery := queries.memoryUsage
 	
 	// DEBUG: Log the exact memory query being executed
 	log.Printf("DEBUG: Executing Memory query: %s", memQuery)
 	
-	memResult, warnings, err := p.client.Query(ctx, memQuery, time.Now())
+	memResult, warnings, err := p.client.Query(ctx, memQuery, at)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query memory usage: %w", err)
 	}
+	recordWarnings(ctx, warnings)
 	if len(warnings) > 0 {
 		log.Printf("Memory query warnings: %v", warnings)
 	}
@@ -623,7 +806,8 @@ func (p *PrometheusClient) GetCurrentPodMetrics(ctx context.Context, namespace s
 	// Create a map to group metrics by pod/container
 	podMetrics := make(map[string]*PodMetric)
 	
-	// Process CPU usage
+	// Process CPU usage, merging duplicate series
+	cpuMerger := newSeriesMerger(p.config.DuplicateAggregation)
 	if cpuVector, ok := cpuResult.(model.Vector); ok {
 		for _, sample := range cpuVector {
 			key := fmt.Sprintf("%s/%s/%s", 
@@ -639,11 +823,12 @@ func (p *PrometheusClient) GetCurrentPodMetrics(ctx context.Context, namespace s
 					Labels:        make(map[string]string),
 				}
 			}
-			podMetrics[key].CPUUsage = float64(sample.Value)
+			cpuMerger.merge(key, &podMetrics[key].CPUUsage, float64(sample.Value))
 		}
 	}
 	
-	// Process Memory usage
+	// Process Memory usage, merging duplicate series
+	memMerger := newSeriesMerger(p.config.DuplicateAggregation)
 	if memVector, ok := memResult.(model.Vector); ok {
 		for _, sample := range memVector {
 			key := fmt.Sprintf("%s/%s/%s", 
@@ -664,45 +849,93 @@ func (p *PrometheusClient) GetCurrentPodMetrics(ctx context.Context, namespace s
 					Labels:        make(map[string]string),
 				}
 			}
-			podMetrics[key].MemoryUsage = memoryBytes
+			memMerger.merge(key, &podMetrics[key].MemoryUsage, memoryBytes)
+		}
+	}
+	
+	// Get the timestamp of the newest memory sample per container
+	tsResult, warnings, err := p.client.Query(ctx, queries.sampleTime, at)
+	recordWarnings(ctx, warnings)
+	if err != nil {
+		log.Printf("Warning: failed to query sample timestamps: %v", err)
+	} else if tsVector, ok := tsResult.(model.Vector); ok {
+		for _, sample := range tsVector {
+			key := fmt.Sprintf("%s/%s/%s", 
+				string(sample.Metric["namespace"]), 
+				string(sample.Metric["pod"]), 
+				string(sample.Metric["container"]))
+			
+			// Keep the newest timestamp across duplicate series
+			if metric, exists := podMetrics[key]; exists {
+				if ts := time.Unix(int64(sample.Value), 0); ts.After(metric.SampleTime) {
+					metric.SampleTime = ts
+				}
+			}
 		}
 	}
 	
-	// Get resource requests and limits
-	err = p.addResourceLimitsAndRequests(ctx, podMetrics, namespace)
+	// Get resource requests and limits, which are left empty without kube-state-metrics
+	err = p.addResourceLimitsAndRequests(ctx, podMetrics, namespace, at)
 	if err != nil {
 		log.Printf("Warning: failed to get resource requests/limits: %v", err)
+		recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+	}
+	
+	// Classify pods by QoS class, unknown without requests and limits
+	if err == nil {
+		setQoSClasses(podMetrics)
+	}
+	
+	// Get container restarts and OOMKills
+	if p.config.EnableContainerStatus {
+		if err := p.addContainerStatus(ctx, podMetrics, namespace, at); err != nil {
+			log.Printf("Warning: failed to get container status: %v", err)
+		}
 	}
 	
-	// Convert map to slice
+	// Get pod start times to report pod age
+	startTimes, err := p.getPodStartTimes(ctx, namespace, at)
+	if err != nil {
+		log.Printf("Warning: failed to get pod start times: %v", err)
+	}
+	setPodStartTimes(podMetrics, startTimes)
+	
+	// Get the Kubernetes pod labels
+	podLabels, err := p.GetPodLabels(ctx, namespace, at)
+	if err != nil {
+		log.Printf("Warning: failed to get pod labels: %v", err)
+	}
+	setPodLabels(podMetrics, podLabels)
+	
+	// Convert map to slice, in a stable order, with the unit of the CPU usage
 	for _, metric := range podMetrics {
+		metric.CPUUnit = p.config.Queries.CPUUnit
 		pods = append(pods, *metric)
 	}
+	sortPodMetrics(pods)
 	
 	return pods, nil
 }
 
 // addResourceLimitsAndRequests adds resource requests and limits to pod metrics
-func (p *PrometheusClient) addResourceLimitsAndRequests(ctx context.Context, podMetrics map[string]*PodMetric, namespace string) error {
-	// Build namespace filter
-	namespaceFilter := ""
-	if namespace != "" {
-		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
-	}
+func (p *PrometheusClient) addResourceLimitsAndRequests(ctx context.Context, podMetrics map[string]*PodMetric, namespace string, at time.Time) error {
+	queries := currentQueries(p.config, namespace, "", "")
+	
+	// Count request and limit series to detect a missing kube-state-metrics
+	seriesFound := 0
 	
 	// Get CPU requests
-	cpuReqQuery := `kube_pod_container_resource_requests{resource="cpu"`
-	if namespaceFilter != "" {
-		cpuReqQuery += "," + namespaceFilter
-	}
-	cpuReqQuery += `}`
+	cpuReqQuery := queries.cpuRequests
 	
-	cpuReqResult, _, err := p.client.Query(ctx, cpuReqQuery, time.Now())
+	cpuReqResult, warnings, err := p.client.Query(ctx, cpuReqQuery, at)
+	recordWarnings(ctx, warnings)
 	if err != nil {
 		return fmt.Errorf("failed to query CPU requests: %w", err)
 	}
 	
+	cpuReqMerger := newSeriesMerger(p.config.DuplicateAggregation)
 	if cpuReqVector, ok := cpuReqResult.(model.Vector); ok {
+		seriesFound += len(cpuReqVector)
 		for _, sample := range cpuReqVector {
 			key := fmt.Sprintf("%s/%s/%s", 
 				string(sample.Metric["namespace"]), 
@@ -710,24 +943,23 @@ func (p *PrometheusClient) addResourceLimitsAndRequests(ctx context.Context, pod
 				string(sample.Metric["container"]))
 			
 			if metric, exists := podMetrics[key]; exists {
-				metric.CPURequest = float64(sample.Value)
+				cpuReqMerger.merge(key, &metric.CPURequest, float64(sample.Value))
 			}
 		}
 	}
 	
 	// Get CPU limits
-	cpuLimitQuery := `kube_pod_container_resource_limits{resource="cpu"`
-	if namespaceFilter != "" {
-		cpuLimitQuery += "," + namespaceFilter
-	}
-	cpuLimitQuery += `}`
+	cpuLimitQuery := queries.cpuLimits
 	
-	cpuLimitResult, _, err := p.client.Query(ctx, cpuLimitQuery, time.Now())
+	cpuLimitResult, warnings, err := p.client.Query(ctx, cpuLimitQuery, at)
+	recordWarnings(ctx, warnings)
 	if err != nil {
 		return fmt.Errorf("failed to query CPU limits: %w", err)
 	}
 	
+	cpuLimitMerger := newSeriesMerger(p.config.DuplicateAggregation)
 	if cpuLimitVector, ok := cpuLimitResult.(model.Vector); ok {
+		seriesFound += len(cpuLimitVector)
 		for _, sample := range cpuLimitVector {
 			key := fmt.Sprintf("%s/%s/%s", 
 				string(sample.Metric["namespace"]), 
@@ -735,24 +967,23 @@ func (p *PrometheusClient) addResourceLimitsAndRequests(ctx context.Context, pod
 				string(sample.Metric["container"]))
 			
 			if metric, exists := podMetrics[key]; exists {
-				metric.CPULimit = float64(sample.Value)
+				cpuLimitMerger.merge(key, &metric.CPULimit, float64(sample.Value))
 			}
 		}
 	}
 	
 	// Get Memory requests
-	memReqQuery := `kube_pod_container_resource_requests{resource="memory"`
-	if namespaceFilter != "" {
-		memReqQuery += "," + namespaceFilter
-	}
-	memReqQuery += `}`
+	memReqQuery := queries.memoryRequests
 	
-	memReqResult, _, err := p.client.Query(ctx, memReqQuery, time.Now())
+	memReqResult, warnings, err := p.client.Query(ctx, memReqQuery, at)
+	recordWarnings(ctx, warnings)
 	if err != nil {
 		return fmt.Errorf("failed to query memory requests: %w", err)
 	}
 	
+	memReqMerger := newSeriesMerger(p.config.DuplicateAggregation)
 	if memReqVector, ok := memReqResult.(model.Vector); ok {
+		seriesFound += len(memReqVector)
 		for _, sample := range memReqVector {
 			key := fmt.Sprintf("%s/%s/%s", 
 				string(sample.Metric["namespace"]), 
@@ -760,24 +991,23 @@ func (p *PrometheusClient) addResourceLimitsAndRequests(ctx context.Context, pod
 				string(sample.Metric["container"]))
 			
 			if metric, exists := podMetrics[key]; exists {
-				metric.MemoryRequest = float64(sample.Value)
+				memReqMerger.merge(key, &metric.MemoryRequest, float64(sample.Value))
 			}
 		}
 	}
 	
 	// Get Memory limits
-	memLimitQuery := `kube_pod_container_resource_limits{resource="memory"`
-	if namespaceFilter != "" {
-		memLimitQuery += "," + namespaceFilter
-	}
-	memLimitQuery += `}`
+	memLimitQuery := queries.memoryLimits
 	
-	memLimitResult, _, err := p.client.Query(ctx, memLimitQuery, time.Now())
+	memLimitResult, warnings, err := p.client.Query(ctx, memLimitQuery, at)
+	recordWarnings(ctx, warnings)
 	if err != nil {
 		return fmt.Errorf("failed to query memory limits: %w", err)
 	}
 	
+	memLimitMerger := newSeriesMerger(p.config.DuplicateAggregation)
 	if memLimitVector, ok := memLimitResult.(model.Vector); ok {
+		seriesFound += len(memLimitVector)
 		for _, sample := range memLimitVector {
 			key := fmt.Sprintf("%s/%s/%s", 
 				string(sample.Metric["namespace"]), 
@@ -785,10 +1015,103 @@ func (p *PrometheusClient) addResourceLimitsAndRequests(ctx context.Context, pod
 				string(sample.Metric["container"]))
 			
 			if metric, exists := podMetrics[key]; exists {
-				metric.MemoryLimit = float64(sample.Value)
+				memLimitMerger.merge(key, &metric.MemoryLimit, float64(sample.Value))
 			}
 		}
 	}
 	
+	if seriesFound == 0 && len(podMetrics) > 0 {
+		return errKubeStateMetricsMissing
+	}
+	
 	return nil
 }
+
+
+// addContainerStatus adds restart counts and OOMKill status to pod metrics
+func (p *PrometheusClient) addContainerStatus(ctx context.Context, podMetrics map[string]*PodMetric, namespace string, at time.Time) error {
+	queries := currentQueries(p.config, namespace, "", "")
+	
+	// Get container restarts
+	restartsQuery := queries.restarts
+	
+	restartsResult, warnings, err := p.client.Query(ctx, restartsQuery, at)
+	recordWarnings(ctx, warnings)
+	if err != nil {
+		return fmt.Errorf("failed to query container restarts: %w", err)
+	}
+	
+	if restartsVector, ok := restartsResult.(model.Vector); ok {
+		for _, sample := range restartsVector {
+			key := fmt.Sprintf("%s/%s/%s", 
+				string(sample.Metric["namespace"]), 
+				string(sample.Metric["pod"]), 
+				string(sample.Metric["container"]))
+			
+			if metric, exists := podMetrics[key]; exists {
+				metric.RestartCount = int(sample.Value)
+			}
+		}
+	}
+	
+	// Get OOMKilled terminations
+	oomQuery := queries.oomKilled
+	
+	oomResult, warnings, err := p.client.Query(ctx, oomQuery, at)
+	recordWarnings(ctx, warnings)
+	if err != nil {
+		return fmt.Errorf("failed to query OOMKilled containers: %w", err)
+	}
+	
+	if oomVector, ok := oomResult.(model.Vector); ok {
+		for _, sample := range oomVector {
+			key := fmt.Sprintf("%s/%s/%s", 
+				string(sample.Metric["namespace"]), 
+				string(sample.Metric["pod"]), 
+				string(sample.Metric["container"]))
+			
+			if metric, exists := podMetrics[key]; exists && sample.Value > 0 {
+				metric.OOMKilled = true
+			}
+		}
+	}
+	
+	return nil
+}
+
+// labelMatch restricts label lookups to container CPU usage series, the series filtered by selectors
+func (p *PrometheusClient) labelMatch(namespace string) []string {
+	namespaceFilter := ""
+	if namespace != "" {
+		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
+	}
+	return []string{p.config.Queries.Selector(QueryCPUUsage, p.config.Queries.BaseContainerFilter(), namespaceFilter)}
+}
+
+// GetLabelNames retrieves the label names of container usage series from Prometheus
+func (p *PrometheusClient) GetLabelNames(ctx context.Context, namespace string) ([]string, error) {
+	now := time.Now()
+	names, warnings, err := p.client.LabelNames(ctx, p.labelMatch(namespace), now.Add(-labelLookback), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query label names: %w", err)
+	}
+	recordWarnings(ctx, warnings)
+	
+	return names, nil
+}
+
+// GetLabelValues retrieves the values of a label on container usage series from Prometheus
+func (p *PrometheusClient) GetLabelValues(ctx context.Context, name, namespace string) ([]string, error) {
+	now := time.Now()
+	result, warnings, err := p.client.LabelValues(ctx, name, p.labelMatch(namespace), now.Add(-labelLookback), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query label values: %w", err)
+	}
+	recordWarnings(ctx, warnings)
+	
+	values := make([]string, 0, len(result))
+	for _, value := range result {
+		values = append(values, string(value))
+	}
+	return values, nil
+}
\ No newline at end of file
@@ -0,0 +1,318 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPromServer serves Prometheus instant queries with the vector fn returns for each query,
+// an empty vector when it returns "", or a server error when it returns "error"
+func newPromServer(t *testing.T, fn func(query string) string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := fn(r.FormValue("query"))
+		w.Header().Set("Content-Type", "application/json")
+		switch result {
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"status":"error","errorType":"internal","error":"query failed"}`))
+			return
+		case "":
+			result = "[]"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":` + result + `}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestPrometheusClient returns a Prometheus client of server
+func newTestPrometheusClient(t *testing.T, server *httptest.Server, config MetricsClientConfig) *PrometheusClient {
+	t.Helper()
+	config.URL = server.URL
+	p, err := NewPrometheusClient(config)
+	if err != nil {
+		t.Fatalf("NewPrometheusClient() error = %v", err)
+	}
+	return p
+}
+
+func TestPrometheusGetNamespacesKSMFallback(t *testing.T) {
+	const (
+		podInfoQuery    = `group by (namespace) (kube_pod_info{})`
+		containerQuery  = `group by (namespace) (container_cpu_usage_seconds_total{container!="POD", container!=""})`
+		ksmNamespaces   = `[{"metric":{"namespace":"shop"}},{"metric":{"namespace":"billing"}},{"metric":{"namespace":"idle"}}]`
+		usageNamespaces = `[{"metric":{"namespace":"shop"}},{"metric":{"namespace":"billing"}}]`
+	)
+	tests := []struct {
+		name         string
+		podInfo      string
+		want         []string
+		wantWarnings []string
+	}{
+		{"kube-state-metrics", ksmNamespaces, []string{"shop", "billing", "idle"}, nil},
+		{"no kube-state-metrics series", "", []string{"shop", "billing"}, []string{WarningKubeStateMetricsMissing}},
+		{"kube-state-metrics query fails", "error", []string{"shop", "billing"}, []string{WarningKubeStateMetricsMissing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			server := newPromServer(t, func(query string) string {
+				queries = append(queries, query)
+				switch query {
+				case podInfoQuery:
+					return tt.podInfo
+				case containerQuery:
+					return usageNamespaces
+				}
+				t.Errorf("unexpected query %q", query)
+				return ""
+			})
+			p := newTestPrometheusClient(t, server, MetricsClientConfig{})
+
+			ctx, warnings := WithQueryWarnings(context.Background())
+			got, err := p.GetNamespaces(ctx)
+			if err != nil {
+				t.Fatalf("GetNamespaces() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetNamespaces() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(warnings.Warnings(), tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings.Warnings(), tt.wantWarnings)
+			}
+			if queries[0] != podInfoQuery {
+				t.Errorf("first query = %q, want kube-state-metrics queried first", queries[0])
+			}
+		})
+	}
+}
+
+func TestGetCurrentPodMetricsKSMMissing(t *testing.T) {
+	const (
+		usage    = `[{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"0.25"]}]`
+		requests = `[{"metric":{"namespace":"shop","pod":"web-0","container":"app","resource":"cpu"},"value":[1700000000,"0.5"]}]`
+	)
+	tests := []struct {
+		name         string
+		ksm          bool
+		wantRequest  float64
+		wantWarnings []string
+	}{
+		{"kube-state-metrics", true, 0.5, nil},
+		{"no kube-state-metrics", false, 0, []string{WarningKubeStateMetricsMissing}},
+	}
+	for _, tt := range tests {
+		respond := func(query string) string {
+			switch {
+			case strings.Contains(query, "container_cpu_usage_seconds_total"), strings.Contains(query, "container_memory_working_set_bytes"):
+				return usage
+			case tt.ksm && strings.Contains(query, "kube_pod_container_resource_requests") && strings.Contains(query, `resource="cpu"`):
+				return requests
+			}
+			return ""
+		}
+		clients := map[string]func(t *testing.T) MetricsClient{
+			"prometheus": func(t *testing.T) MetricsClient {
+				return newTestPrometheusClient(t, newPromServer(t, respond), MetricsClientConfig{})
+			},
+			"victoriametrics": func(t *testing.T) MetricsClient {
+				return newTestVMClient(t, newVMServer(t, func(r *http.Request) string { return respond(r.URL.Query().Get("query")) }), MetricsClientConfig{})
+			},
+		}
+		for client, newClient := range clients {
+			t.Run(client+"/"+tt.name, func(t *testing.T) {
+				ctx, warnings := WithQueryWarnings(context.Background())
+				metrics, err := newClient(t).GetCurrentPodMetrics(ctx, "shop", "", time.Now())
+				if err != nil {
+					t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+				}
+				if len(metrics) != 1 {
+					t.Fatalf("GetCurrentPodMetrics() = %d containers, want 1", len(metrics))
+				}
+				if metrics[0].CPUUsage != 0.25 || metrics[0].CPURequest != tt.wantRequest {
+					t.Errorf("CPU usage, request = %v, %v, want 0.25, %v", metrics[0].CPUUsage, metrics[0].CPURequest, tt.wantRequest)
+				}
+				if !reflect.DeepEqual(warnings.Warnings(), tt.wantWarnings) {
+					t.Errorf("warnings = %q, want %q", warnings.Warnings(), tt.wantWarnings)
+				}
+			})
+		}
+	}
+}
+
+// podKeys returns the namespace/pod/container of each pod metric
+func podKeys(pods []PodMetric) []string {
+	keys := make([]string, len(pods))
+	for i, pod := range pods {
+		keys[i] = pod.Namespace + "/" + pod.Name + "/" + pod.ContainerName
+	}
+	return keys
+}
+
+func TestGetCurrentPodMetricsOrdering(t *testing.T) {
+	// Series in no particular order, so the clients' map iteration order would show
+	const usage = `[` +
+		`{"metric":{"namespace":"shop","pod":"web-1","container":"app"},"value":[1700000000,"0.1"]},` +
+		`{"metric":{"namespace":"billing","pod":"api-0","container":"sidecar"},"value":[1700000000,"0.1"]},` +
+		`{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"0.1"]},` +
+		`{"metric":{"namespace":"billing","pod":"api-0","container":"app"},"value":[1700000000,"0.1"]},` +
+		`{"metric":{"namespace":"shop","pod":"web-0","container":"proxy"},"value":[1700000000,"0.1"]},` +
+		`{"metric":{"namespace":"billing","pod":"api-1","container":"app"},"value":[1700000000,"0.1"]},` +
+		`{"metric":{"namespace":"shop","pod":"cache-0","container":"redis"},"value":[1700000000,"0.1"]}]`
+	want := []string{
+		"billing/api-0/app", "billing/api-0/sidecar", "billing/api-1/app",
+		"shop/cache-0/redis", "shop/web-0/app", "shop/web-0/proxy", "shop/web-1/app",
+	}
+	respond := func(query string) string {
+		if strings.Contains(query, "container_cpu_usage_seconds_total") || strings.HasPrefix(query, "container_memory_working_set_bytes") {
+			return usage
+		}
+		return ""
+	}
+	clients := map[string]func(t *testing.T) MetricsClient{
+		"prometheus": func(t *testing.T) MetricsClient {
+			return newTestPrometheusClient(t, newPromServer(t, respond), MetricsClientConfig{})
+		},
+		"victoriametrics": func(t *testing.T) MetricsClient {
+			return newTestVMClient(t, newVMServer(t, func(r *http.Request) string { return respond(r.URL.Query().Get("query")) }), MetricsClientConfig{})
+		},
+	}
+	for client, newClient := range clients {
+		t.Run(client, func(t *testing.T) {
+			metricsClient := newClient(t)
+			for call := range 5 {
+				pods, err := metricsClient.GetCurrentPodMetrics(context.Background(), "", "", time.Now())
+				if err != nil {
+					t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+				}
+				if got := podKeys(pods); !reflect.DeepEqual(got, want) {
+					t.Fatalf("call %d order = %v, want %v", call, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCurrentPodMetricsSampleTime(t *testing.T) {
+	seen := time.Date(2026, 3, 1, 11, 59, 30, 0, time.UTC)
+	series := func(pod, value string) string {
+		return `{"metric":{"namespace":"shop","pod":"` + pod + `","container":"app"},"value":[1772366400,"` + value + `"]}`
+	}
+	unix := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+	tests := []struct {
+		name       string
+		timestamps string // timestamp() query result
+		want       map[string]time.Time
+	}{
+		{
+			name:       "sample timestamps",
+			timestamps: "[" + series("web-0", unix(seen)) + "," + series("web-1", unix(seen.Add(-10*time.Minute))) + "]",
+			want:       map[string]time.Time{"web-0": seen, "web-1": seen.Add(-10 * time.Minute)},
+		},
+		{
+			name:       "newest duplicate kept",
+			timestamps: "[" + series("web-0", unix(seen.Add(-time.Minute))) + "," + series("web-0", unix(seen)) + "," + series("web-1", unix(seen)) + "]",
+			want:       map[string]time.Time{"web-0": seen, "web-1": seen},
+		},
+		{
+			name:       "no timestamps",
+			timestamps: "",
+			want:       map[string]time.Time{"web-0": {}, "web-1": {}},
+		},
+	}
+	for _, tt := range tests {
+		respond := func(query string) string {
+			switch {
+			case strings.HasPrefix(query, "timestamp("):
+				return tt.timestamps
+			case strings.Contains(query, "container_memory_working_set_bytes"):
+				return "[" + series("web-0", "100") + "," + series("web-1", "100") + "]"
+			}
+			return ""
+		}
+		clients := map[string]func(t *testing.T) MetricsClient{
+			"prometheus": func(t *testing.T) MetricsClient {
+				return newTestPrometheusClient(t, newPromServer(t, respond), MetricsClientConfig{})
+			},
+			"victoriametrics": func(t *testing.T) MetricsClient {
+				return newTestVMClient(t, newVMServer(t, func(r *http.Request) string { return respond(r.URL.Query().Get("query")) }), MetricsClientConfig{})
+			},
+		}
+		for client, newClient := range clients {
+			t.Run(client+"/"+tt.name, func(t *testing.T) {
+				metrics, err := newClient(t).GetCurrentPodMetrics(context.Background(), "shop", "", seen.Add(30*time.Second))
+				if err != nil {
+					t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+				}
+				if len(metrics) != len(tt.want) {
+					t.Fatalf("GetCurrentPodMetrics() = %d containers, want %d", len(metrics), len(tt.want))
+				}
+				for _, metric := range metrics {
+					if !metric.SampleTime.Equal(tt.want[metric.Name]) {
+						t.Errorf("%s sample time = %s, want %s", metric.Name, metric.SampleTime, tt.want[metric.Name])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestGetNodePodMetrics(t *testing.T) {
+	// The backend only returns the usage of the pod joined with node-a's pod info
+	const usage = `[{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"0.1"]}]`
+	const podInfo = `kube_pod_info{node="node-a"}`
+
+	clients := map[string]func(t *testing.T, respond func(query string) string) NodePodMetricsGetter{
+		"prometheus": func(t *testing.T, respond func(query string) string) NodePodMetricsGetter {
+			return newTestPrometheusClient(t, newPromServer(t, respond), MetricsClientConfig{})
+		},
+		"victoriametrics": func(t *testing.T, respond func(query string) string) NodePodMetricsGetter {
+			return newTestVMClient(t, newVMServer(t, func(r *http.Request) string { return respond(r.URL.Query().Get("query")) }), MetricsClientConfig{})
+		},
+	}
+	for client, newClient := range clients {
+		t.Run(client, func(t *testing.T) {
+			var mu sync.Mutex
+			var queries []string
+			metricsClient := newClient(t, func(query string) string {
+				mu.Lock()
+				queries = append(queries, query)
+				mu.Unlock()
+				if strings.Contains(query, podInfo) && (strings.Contains(query, "container_cpu_usage_seconds_total") || strings.Contains(query, "container_memory_working_set_bytes")) {
+					return usage
+				}
+				return ""
+			})
+
+			pods, err := metricsClient.GetNodePodMetrics(context.Background(), "", "node-a", "", time.Now())
+			if err != nil {
+				t.Fatalf("GetNodePodMetrics() error = %v", err)
+			}
+			if got, want := podKeys(pods), []string{"shop/web-0/app"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("pods = %v, want %v", got, want)
+			}
+
+			usageQueries := 0
+			for _, query := range queries {
+				if strings.Contains(query, "group by (namespace, pod, node)") {
+					t.Errorf("pod nodes queried separately: %s", query)
+				}
+				if strings.HasPrefix(query, "(") && strings.Contains(query, podInfo) {
+					usageQueries++
+				}
+			}
+			if usageQueries != 2 {
+				t.Errorf("got %d usage queries joined with the node's pods, want 2: %v", usageQueries, queries)
+			}
+		})
+	}
+}
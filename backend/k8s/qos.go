@@ -0,0 +1,47 @@
+package k8s
+
+// Pod QoS classes, as assigned by Kubernetes from the requests and limits of a pod's containers
+const (
+	QoSGuaranteed = "Guaranteed"
+	QoSBurstable  = "Burstable"
+	QoSBestEffort = "BestEffort"
+)
+
+// qosClass returns the QoS class of a pod from its containers: Guaranteed when every container has
+// CPU and memory limits equal to its requests, BestEffort when no container has a request or limit,
+// and Burstable otherwise
+func qosClass(containers []*PodMetric) string {
+	guaranteed, bestEffort := true, true
+	for _, c := range containers {
+		if c.CPURequest > 0 || c.CPULimit > 0 || c.MemoryRequest > 0 || c.MemoryLimit > 0 {
+			bestEffort = false
+		}
+		if c.CPULimit <= 0 || c.MemoryLimit <= 0 || c.CPURequest != c.CPULimit || c.MemoryRequest != c.MemoryLimit {
+			guaranteed = false
+		}
+	}
+	switch {
+	case bestEffort:
+		return QoSBestEffort
+	case guaranteed:
+		return QoSGuaranteed
+	default:
+		return QoSBurstable
+	}
+}
+
+// setQoSClasses sets the QoS class of each pod metric from the requests and limits of all the
+// containers of its pod
+func setQoSClasses(podMetrics map[string]*PodMetric) {
+	pods := make(map[string][]*PodMetric)
+	for _, metric := range podMetrics {
+		key := metric.Namespace + "/" + metric.Name
+		pods[key] = append(pods[key], metric)
+	}
+	for _, containers := range pods {
+		class := qosClass(containers)
+		for _, metric := range containers {
+			metric.QoSClass = class
+		}
+	}
+}
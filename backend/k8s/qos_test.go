@@ -0,0 +1,57 @@
+package k8s
+
+import "testing"
+
+func TestQoSClass(t *testing.T) {
+	guaranteed := PodMetric{CPURequest: 0.5, CPULimit: 0.5, MemoryRequest: 256 << 20, MemoryLimit: 256 << 20}
+	tests := []struct {
+		name       string
+		containers []PodMetric
+		want       string
+	}{
+		{"requests equal limits", []PodMetric{guaranteed}, QoSGuaranteed},
+		{"every container guaranteed", []PodMetric{guaranteed, {CPURequest: 1, CPULimit: 1, MemoryRequest: 1 << 30, MemoryLimit: 1 << 30}}, QoSGuaranteed},
+		{"requests below limits", []PodMetric{{CPURequest: 0.25, CPULimit: 0.5, MemoryRequest: 256 << 20, MemoryLimit: 256 << 20}}, QoSBurstable},
+		{"memory limit only", []PodMetric{{MemoryLimit: 256 << 20}}, QoSBurstable},
+		{"CPU request only", []PodMetric{{CPURequest: 0.1}}, QoSBurstable},
+		{"limits without requests", []PodMetric{{CPULimit: 0.5, MemoryLimit: 256 << 20}}, QoSBurstable},
+		{"one container without resources", []PodMetric{guaranteed, {}}, QoSBurstable},
+		{"no requests or limits", []PodMetric{{}, {}}, QoSBestEffort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var containers []*PodMetric
+			for i := range tt.containers {
+				containers = append(containers, &tt.containers[i])
+			}
+			if got := qosClass(containers); got != tt.want {
+				t.Errorf("qosClass() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetQoSClasses(t *testing.T) {
+	podMetrics := map[string]*PodMetric{
+		"shop/web-0/app":      {Namespace: "shop", Name: "web-0", ContainerName: "app", CPURequest: 0.5, CPULimit: 0.5, MemoryRequest: 1 << 30, MemoryLimit: 1 << 30},
+		"shop/web-0/sidecar":  {Namespace: "shop", Name: "web-0", ContainerName: "sidecar", CPURequest: 0.1},
+		"shop/api-0/app":      {Namespace: "shop", Name: "api-0", ContainerName: "app", CPURequest: 1, CPULimit: 1, MemoryRequest: 1 << 30, MemoryLimit: 1 << 30},
+		"billing/web-0/app":   {Namespace: "billing", Name: "web-0", ContainerName: "app"},
+		"billing/batch-0/app": {Namespace: "billing", Name: "batch-0", ContainerName: "app", MemoryRequest: 1 << 30},
+	}
+	setQoSClasses(podMetrics)
+
+	// Containers share the class of their pod, and pods of the same name in other namespaces don't mix
+	want := map[string]string{
+		"shop/web-0/app":      QoSBurstable,
+		"shop/web-0/sidecar":  QoSBurstable,
+		"shop/api-0/app":      QoSGuaranteed,
+		"billing/web-0/app":   QoSBestEffort,
+		"billing/batch-0/app": QoSBurstable,
+	}
+	for key, class := range want {
+		if got := podMetrics[key].QoSClass; got != class {
+			t.Errorf("%s QoS class = %s, want %s", key, got, class)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package k8s
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Query template names used to build metric selectors
+const (
+	QueryCPUUsage         = "cpu_usage"
+	QueryMemoryUsage      = "memory_usage"
+	QueryResourceRequests = "resource_requests"
+	QueryResourceLimits   = "resource_limits"
+	QueryRestarts         = "restarts"
+	QueryTerminatedReason = "terminated_reason"
+	QueryNodeAllocatable  = "node_allocatable"
+	QueryPodInfo          = "pod_info"
+	QueryPodPhase         = "pod_phase"
+	// Used to find pods that completed during the analysis window
+	QueryPodCompletionTime = "pod_completion_time"
+	QueryContainerInfo     = "container_info"
+	// Used to report pod age and flag pods younger than the analysis window
+	QueryPodStartTime = "pod_start_time"
+	// Used to attach Kubernetes pod labels, e.g. the owner label, to pod metrics
+	QueryPodLabels = "pod_labels"
+	// Used to group the replicas of a workload by their controller
+	QueryPodOwner = "pod_owner"
+)
+
+// DefaultQueryTemplates maps each query template to its default metric name
+var DefaultQueryTemplates = map[string]string{
+	QueryCPUUsage:          "container_cpu_usage_seconds_total",
+	QueryMemoryUsage:       "container_memory_working_set_bytes",
+	QueryResourceRequests:  "kube_pod_container_resource_requests",
+	QueryResourceLimits:    "kube_pod_container_resource_limits",
+	QueryRestarts:          "kube_pod_container_status_restarts_total",
+	QueryTerminatedReason:  "kube_pod_container_status_last_terminated_reason",
+	QueryNodeAllocatable:   "kube_node_status_allocatable",
+	QueryPodInfo:           "kube_pod_info",
+	QueryPodPhase:          "kube_pod_status_phase",
+	QueryPodCompletionTime: "kube_pod_completion_time",
+	QueryContainerInfo:     "kube_pod_container_info",
+	QueryPodStartTime:      "kube_pod_start_time",
+	QueryPodLabels:         "kube_pod_labels",
+	QueryPodOwner:          "kube_pod_owner",
+}
+
+// Memory metric kinds selectable for memory usage queries
+const (
+	// MemoryWorkingSet is the cgroup usage minus inactive file cache - what the kubelet uses for eviction
+	// and the closest match to what the OOM killer acts on. This is the default.
+	MemoryWorkingSet = "working_set"
+	// MemoryRSS is anonymous memory only (heap, stacks); excludes all page cache so it
+	// under-reports containers that rely on file-backed memory
+	MemoryRSS = "rss"
+	// MemoryUsage is total cgroup usage including all page cache; it over-reports since
+	// reclaimable cache is counted and tends to grow towards the limit
+	MemoryUsage = "usage"
+)
+
+// MemoryMetrics maps each memory metric kind to its cAdvisor metric name
+var MemoryMetrics = map[string]string{
+	MemoryWorkingSet: "container_memory_working_set_bytes",
+	MemoryRSS:        "container_memory_rss",
+	MemoryUsage:      "container_memory_usage_bytes",
+}
+
+// DefaultContainerFilter excludes the pause container and pod-level cgroup series
+const DefaultContainerFilter = `container!="POD", container!=""`
+
+// QueryTemplates holds metric name overrides and extra label filters applied to every selector
+type QueryTemplates struct {
+	Metrics         map[string]string // Template name -> metric name, missing entries use the defaults
+	ExtraFilters    string            // Label filters added to every selector, e.g. `cluster="prod"`
+	ContainerFilter string            // Base filter for container-level selectors, defaults to DefaultContainerFilter
+	CPUUnit         CPUUnit           // Unit of the cpu_usage rate, converted to cores; empty is cores
+}
+
+// Metric returns the metric name for a query template, falling back to the default
+func (t QueryTemplates) Metric(name string) string {
+	if metric := t.Metrics[name]; metric != "" {
+		return metric
+	}
+	return DefaultQueryTemplates[name]
+}
+
+// Selector builds a PromQL selector for a query template with the given label filters
+func (t QueryTemplates) Selector(name string, filters ...string) string {
+	var matchers []string
+	for _, filter := range filters {
+		if filter != "" {
+			matchers = append(matchers, filter)
+		}
+	}
+	if t.ExtraFilters != "" {
+		matchers = append(matchers, t.ExtraFilters)
+	}
+	return t.Metric(name) + "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// BaseContainerFilter returns the filter applied to container-level selectors
+func (t QueryTemplates) BaseContainerFilter() string {
+	if t.ContainerFilter != "" {
+		return t.ContainerFilter
+	}
+	return DefaultContainerFilter
+}
+
+const labelFilter = `(\w+)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"`
+
+var (
+	labelFilterPattern     = regexp.MustCompile(labelFilter)
+	labelFilterListPattern = regexp.MustCompile(`^\s*` + labelFilter + `(\s*,\s*` + labelFilter + `)*\s*$`)
+)
+
+// ValidLabelFilters reports whether s is a comma-separated list of label matchers
+// such as `container!="POD", namespace=~"team-.*"`, so it can be injected into selectors safely
+func ValidLabelFilters(s string) bool {
+	return labelFilterListPattern.MatchString(s)
+}
+
+// namespaceMatcher returns the label filter selecting the namespaces matching the namespace regular
+// expression, or no filter when it is empty: namespace=~"" only matches series without a namespace
+// label, not every namespace
+func namespaceMatcher(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	return `namespace=~"` + namespace + `"`
+}
+
+// namespaceMatchers is namespaceMatcher for remote read
+func namespaceMatchers(namespace string) []RemoteReadMatcher {
+	if namespace == "" {
+		return nil
+	}
+	return []RemoteReadMatcher{{Type: MatchRegexp, Name: "namespace", Value: namespace}}
+}
+
+// ExtraMatchers converts the extra label filters into remote-read matchers
+func (t QueryTemplates) ExtraMatchers() []RemoteReadMatcher {
+	return parseLabelFilters(t.ExtraFilters)
+}
+
+// ContainerMatchers converts the base container filter into remote-read matchers
+func (t QueryTemplates) ContainerMatchers() []RemoteReadMatcher {
+	return parseLabelFilters(t.BaseContainerFilter())
+}
+
+// parseLabelFilters converts PromQL label filters into remote-read matchers
+func parseLabelFilters(filters string) []RemoteReadMatcher {
+	var matchers []RemoteReadMatcher
+	for _, m := range labelFilterPattern.FindAllStringSubmatch(filters, -1) {
+		matcher := RemoteReadMatcher{Name: m[1], Value: strings.ReplaceAll(m[3], `\"`, `"`)}
+		switch m[2] {
+		case "=":
+			matcher.Type = MatchEqual
+		case "!=":
+			matcher.Type = MatchNotEqual
+		case "=~":
+			matcher.Type = MatchRegexp
+		case "!~":
+			matcher.Type = MatchNotRegexp
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers
+}
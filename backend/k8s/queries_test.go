@@ -0,0 +1,418 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryTemplatesSelector(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates QueryTemplates
+		template  string
+		filters   []string
+		want      string
+	}{
+		{"default metric", QueryTemplates{}, QueryMemoryUsage, []string{`namespace="shop"`}, `container_memory_working_set_bytes{namespace="shop"}`},
+		{"overridden metric", QueryTemplates{Metrics: map[string]string{QueryMemoryUsage: "container_memory_rss"}}, QueryMemoryUsage, []string{`namespace="shop"`}, `container_memory_rss{namespace="shop"}`},
+		{"override of another template", QueryTemplates{Metrics: map[string]string{QueryCPUUsage: "cpu_seconds"}}, QueryMemoryUsage, nil, `container_memory_working_set_bytes{}`},
+		{"extra filters appended", QueryTemplates{ExtraFilters: `cluster="prod"`}, QueryRestarts, []string{`pod="web-0"`}, `kube_pod_container_status_restarts_total{pod="web-0", cluster="prod"}`},
+		{"empty filters skipped", QueryTemplates{}, QueryPodInfo, []string{"", `node="a"`, ""}, `kube_pod_info{node="a"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.templates.Selector(tt.template, tt.filters...); got != tt.want {
+				t.Errorf("Selector() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentQueriesTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates QueryTemplates
+		query     func(containerQueries) string
+		want      string
+		notWant   string
+	}{
+		{
+			name:      "overridden memory metric",
+			templates: QueryTemplates{Metrics: map[string]string{QueryMemoryUsage: "container_memory_rss"}},
+			query:     func(q containerQueries) string { return q.memoryUsage },
+			want:      `container_memory_rss{`,
+			notWant:   "container_memory_working_set_bytes",
+		},
+		{
+			name:      "overridden memory metric in the sample time query",
+			templates: QueryTemplates{Metrics: map[string]string{QueryMemoryUsage: "container_memory_rss"}},
+			query:     func(q containerQueries) string { return q.sampleTime },
+			want:      `timestamp(container_memory_rss{`,
+		},
+		{
+			name:      "overridden CPU metric",
+			templates: QueryTemplates{Metrics: map[string]string{QueryCPUUsage: "container_cpu_seconds"}},
+			query:     func(q containerQueries) string { return q.cpuUsage },
+			want:      `rate(container_cpu_seconds{`,
+		},
+		{
+			name:      "overridden requests metric",
+			templates: QueryTemplates{Metrics: map[string]string{QueryResourceRequests: "kube_pod_resource_request"}},
+			query:     func(q containerQueries) string { return q.cpuRequests },
+			want:      `kube_pod_resource_request{`,
+		},
+		{
+			name:      "extra filters",
+			templates: QueryTemplates{ExtraFilters: `cluster="prod"`},
+			query:     func(q containerQueries) string { return q.memoryLimits },
+			want:      `cluster="prod"}`,
+		},
+		{
+			name:      "overridden container filter",
+			templates: QueryTemplates{ContainerFilter: `container!=""`},
+			query:     func(q containerQueries) string { return q.cpuUsage },
+			want:      `{container!="", namespace="shop"}`,
+			notWant:   `container!="POD"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query(currentQueries(MetricsClientConfig{Queries: tt.templates}, "shop", "", ""))
+			if !strings.Contains(query, tt.want) {
+				t.Errorf("query %s does not contain %s", query, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(query, tt.notWant) {
+				t.Errorf("query %s contains %s", query, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestValidLabelFilters(t *testing.T) {
+	tests := []struct {
+		filters string
+		want    bool
+	}{
+		{`cluster="prod"`, true},
+		{`container!="POD", namespace=~"team-.*"`, true},
+		{` pod!~"debug-.*" `, true},
+		{`name="a \"quoted\" value"`, true},
+		{``, false},
+		{`cluster=prod`, false},
+		{`cluster="prod"} or vector(1`, false},
+		{`cluster="prod",`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filters, func(t *testing.T) {
+			if got := ValidLabelFilters(tt.filters); got != tt.want {
+				t.Errorf("ValidLabelFilters(%s) = %v, want %v", tt.filters, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLabelFilters(t *testing.T) {
+	got := parseLabelFilters(`container!="POD", namespace=~"team-.*", pod!~"debug-.*", cluster="a \"b\""`)
+	want := []RemoteReadMatcher{
+		{Type: MatchNotEqual, Name: "container", Value: "POD"},
+		{Type: MatchRegexp, Name: "namespace", Value: "team-.*"},
+		{Type: MatchNotRegexp, Name: "pod", Value: "debug-.*"},
+		{Type: MatchEqual, Name: "cluster", Value: `a "b"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLabelFilters() = %+v, want %+v", got, want)
+	}
+}
+
+func TestContainerFilterInQueries(t *testing.T) {
+	const filter = `container!~"POD|istio-proxy"`
+	config := MetricsClientConfig{Queries: QueryTemplates{ContainerFilter: filter}}
+	current := currentQueries(config, "shop", "", "")
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"cpu usage", current.cpuUsage},
+		{"memory usage", current.memoryUsage},
+		{"cpu requests", current.cpuRequests},
+		{"cpu limits", current.cpuLimits},
+		{"memory requests", current.memoryRequests},
+		{"memory limits", current.memoryLimits},
+		{"active pods", activePodsQuery(config, "shop")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(tt.query, filter) {
+				t.Errorf("query %s does not contain the container filter %s", tt.query, filter)
+			}
+			if strings.Contains(tt.query, DefaultContainerFilter) {
+				t.Errorf("query %s contains the default container filter", tt.query)
+			}
+		})
+	}
+
+	want := []RemoteReadMatcher{{Type: MatchNotRegexp, Name: "container", Value: "POD|istio-proxy"}}
+	if got := config.Queries.ContainerMatchers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ContainerMatchers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMemoryMetricInQueries(t *testing.T) {
+	end := time.Now()
+	for kind, metric := range MemoryMetrics {
+		t.Run(kind, func(t *testing.T) {
+			config := MetricsClientConfig{Queries: QueryTemplates{Metrics: map[string]string{QueryMemoryUsage: metric}}}
+			current := currentQueries(config, "shop", "", "")
+			historical := historicalQueries(config, "shop", "web-0", "app", end.Add(-time.Hour), end)
+			series, err := seriesQuery(config.Queries, 0, SeriesMemory, "shop", "web-0", "app")
+			if err != nil {
+				t.Fatalf("seriesQuery() error = %v", err)
+			}
+
+			for name, query := range map[string]string{
+				"current":     current.memoryUsage,
+				"sample time": current.sampleTime,
+				"historical":  historical.memoryUsage,
+				"series":      series,
+			} {
+				if !strings.Contains(query, metric+"{") {
+					t.Errorf("%s query %s does not use %s", name, query, metric)
+				}
+			}
+		})
+	}
+}
+
+func TestCurrentQueriesSelector(t *testing.T) {
+	const selector = `app="nginx", tier="frontend"`
+	current := currentQueries(MetricsClientConfig{}, "shop", "", selector)
+
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{"cpu usage", current.cpuUsage, true},
+		{"memory usage", current.memoryUsage, true},
+		{"sample time", current.sampleTime, true},
+		{"cpu requests", current.cpuRequests, false},
+		{"memory limits", current.memoryLimits, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Contains(tt.query, selector); got != tt.want {
+				t.Errorf("query %s contains the selector = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateWindow(t *testing.T) {
+	tests := []struct {
+		name           string
+		scrapeInterval time.Duration
+		want           time.Duration
+	}{
+		{"not configured", 0, DefaultRateWindow},
+		{"15s scrapes floored at a minute", 15 * time.Second, time.Minute},
+		{"30s scrapes", 30 * time.Second, 2 * time.Minute},
+		{"60s scrapes", time.Minute, 4 * time.Minute},
+		{"2m scrapes", 2 * time.Minute, 8 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rateWindow(tt.scrapeInterval); got != tt.want {
+				t.Errorf("rateWindow(%s) = %s, want %s", tt.scrapeInterval, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateWindowInQueries(t *testing.T) {
+	end := time.Now()
+	tests := []struct {
+		name           string
+		config         MetricsClientConfig
+		wantCurrent    string
+		wantHistorical string
+	}{
+		{"default", MetricsClientConfig{}, "[300s]", "[300s]"},
+		{"15s scrapes", MetricsClientConfig{ScrapeInterval: 15 * time.Second}, "[60s]", "[60s]"},
+		{"60s scrapes", MetricsClientConfig{ScrapeInterval: time.Minute}, "[240s]", "[240s]"},
+		{"60s scrapes with a lookback", MetricsClientConfig{ScrapeInterval: time.Minute, InstantLookback: time.Minute}, "[300s]", "[240s]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := currentQueries(tt.config, "shop", "", "").cpuUsage
+			if !strings.Contains(current, tt.wantCurrent) {
+				t.Errorf("current CPU query = %s, want a %s rate window", current, tt.wantCurrent)
+			}
+			historical := historicalQueries(tt.config, "shop", "web-0", "app", end.Add(-time.Hour), end).cpuUsage
+			if !strings.Contains(historical, tt.wantHistorical) {
+				t.Errorf("historical CPU query = %s, want a %s rate window", historical, tt.wantHistorical)
+			}
+		})
+	}
+}
+
+func TestCurrentQueriesNode(t *testing.T) {
+	const join = `) * on (namespace, pod) group_left () group by (namespace, pod) (kube_pod_info{node="node-a", namespace="shop"})`
+	current := currentQueries(MetricsClientConfig{}, "shop", "node-a", "")
+	unfiltered := currentQueries(MetricsClientConfig{}, "shop", "", "")
+
+	tests := []struct {
+		name       string
+		query      string
+		unfiltered string
+		want       bool
+	}{
+		{"cpu usage", current.cpuUsage, unfiltered.cpuUsage, true},
+		{"memory usage", current.memoryUsage, unfiltered.memoryUsage, true},
+		{"cpu requests", current.cpuRequests, unfiltered.cpuRequests, false},
+		{"memory limits", current.memoryLimits, unfiltered.memoryLimits, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.unfiltered
+			if tt.want {
+				want = "(" + tt.unfiltered + join
+			}
+			if tt.query != want {
+				t.Errorf("query = %s, want %s", tt.query, want)
+			}
+		})
+	}
+}
+
+// TestEmptyNamespaceQueries checks that an empty namespace selects all namespaces by omitting the
+// namespace matcher, instead of matching series without a namespace with namespace=~""
+func TestEmptyNamespaceQueries(t *testing.T) {
+	const activePod = `[{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"1"]}]`
+
+	// promQueries runs the current and historical metrics of namespace against a PromQL backend and
+	// returns the queries it received
+	promQueries := func(t *testing.T, newClient func(server string) MetricsClient, namespace string) []string {
+		var mu sync.Mutex
+		var queries []string
+		server := newVMServer(t, func(r *http.Request) string {
+			query := r.FormValue("query")
+			mu.Lock()
+			queries = append(queries, query)
+			mu.Unlock()
+			if strings.HasPrefix(query, "group by (pod, namespace, container)") {
+				return activePod
+			}
+			return ""
+		})
+		metricsClient := newClient(server.URL)
+		if _, err := metricsClient.GetCurrentPodMetrics(context.Background(), namespace, "", time.Now()); err != nil {
+			t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+		}
+		if _, err := metricsClient.GetHistoricalMetrics(context.Background(), namespace, HistoricalOptions{}); err != nil {
+			t.Fatalf("GetHistoricalMetrics() error = %v", err)
+		}
+		return queries
+	}
+
+	clients := map[string]func(server string) MetricsClient{
+		"prometheus": func(server string) MetricsClient {
+			p, err := NewPrometheusClient(MetricsClientConfig{URL: server})
+			if err != nil {
+				t.Fatalf("NewPrometheusClient() error = %v", err)
+			}
+			return p
+		},
+		"victoriametrics": func(server string) MetricsClient {
+			vm, err := NewVictoriaMetricsClient(MetricsClientConfig{URL: server})
+			if err != nil {
+				t.Fatalf("NewVictoriaMetricsClient() error = %v", err)
+			}
+			return vm
+		},
+	}
+	for client, newClient := range clients {
+		t.Run(client, func(t *testing.T) {
+			queries := promQueries(t, newClient, "")
+			if len(queries) == 0 {
+				t.Fatal("no queries sent")
+			}
+			ranged := false
+			for _, query := range queries {
+				if strings.Contains(query, `namespace=~""`) || strings.Contains(query, `namespace=""`) {
+					t.Errorf("query matches an empty namespace: %s", query)
+				}
+				ranged = ranged || strings.Contains(query, `pod="web-0"`)
+			}
+			if !ranged {
+				t.Errorf("no historical range queries of the active pod sent: %v", queries)
+			}
+
+			// A namespace is still matched
+			for _, query := range promQueries(t, newClient, "shop") {
+				if strings.Contains(query, "container_cpu_usage_seconds_total") && !strings.Contains(query, `namespace=~"shop"`) && !strings.Contains(query, `namespace="shop"`) {
+					t.Errorf("query does not match the shop namespace: %s", query)
+				}
+			}
+		})
+	}
+
+	t.Run("remoteread", func(t *testing.T) {
+		at := time.Now().Truncate(time.Second)
+		// namespaceMatchers returns the namespace matchers of each query of namespace
+		namespaceMatchers := func(namespace string) [][]RemoteReadMatcher {
+			var mu sync.Mutex
+			var matchers [][]RemoteReadMatcher
+			server := newRemoteReadServer(t, func(q remoteReadQuery) []RemoteReadSeries {
+				var namespaceMatchers []RemoteReadMatcher
+				for _, m := range q.matchers {
+					if m.Name == "namespace" {
+						namespaceMatchers = append(namespaceMatchers, m)
+					}
+				}
+				mu.Lock()
+				matchers = append(matchers, namespaceMatchers)
+				mu.Unlock()
+				if q.label("__name__") == "container_cpu_usage_seconds_total" {
+					return []RemoteReadSeries{containerSeries(nil,
+						RemoteReadSample{Timestamp: at.Add(-time.Minute).UnixMilli(), Value: 100},
+						RemoteReadSample{Timestamp: at.UnixMilli(), Value: 130})}
+				}
+				return nil
+			})
+			rr, err := NewRemoteReadClient(MetricsClientConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("NewRemoteReadClient() error = %v", err)
+			}
+			if _, err := rr.GetCurrentPodMetrics(context.Background(), namespace, "", at); err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if _, err := rr.GetHistoricalMetrics(context.Background(), namespace, HistoricalOptions{}); err != nil {
+				t.Fatalf("GetHistoricalMetrics() error = %v", err)
+			}
+			return matchers
+		}
+
+		queries := namespaceMatchers("")
+		if len(queries) == 0 {
+			t.Fatal("no queries sent")
+		}
+		for _, matchers := range queries {
+			for _, m := range matchers {
+				if m.Value == "" || m.Value == ".*" {
+					t.Errorf("query has the namespace matcher %+v", m)
+				}
+			}
+		}
+		for _, matchers := range namespaceMatchers("shop") {
+			if len(matchers) == 0 {
+				t.Error("query of the shop namespace has no namespace matcher")
+			}
+		}
+	})
+}
@@ -0,0 +1,1151 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteReadClient reads raw series through the Prometheus remote-read protocol
+type RemoteReadClient struct {
+	readURL string
+	client  *http.Client
+	config  MetricsClientConfig
+}
+
+// NewRemoteReadClient creates a new remote-read client
+func NewRemoteReadClient(config MetricsClientConfig) (*RemoteReadClient, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("remote-read URL is required")
+	}
+
+	return &RemoteReadClient{
+		readURL: config.URL,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: newTransport(config),
+		},
+		config: config,
+	}, nil
+}
+
+// Ping reads the last minute of the up series to check the remote-read endpoint is reachable,
+// since remote read has no PromQL to evaluate
+func (rr *RemoteReadClient) Ping(ctx context.Context) error {
+	now := time.Now()
+	_, err := rr.read(ctx, now.Add(-time.Minute), now, []RemoteReadMatcher{{Type: MatchEqual, Name: "__name__", Value: "up"}})
+	return err
+}
+
+// Close closes the remote-read client connection
+func (rr *RemoteReadClient) Close() error {
+	// HTTP client doesn't require explicit closing
+	return nil
+}
+
+// GetClientType returns the type of metrics client
+func (rr *RemoteReadClient) GetClientType() string {
+	return "remoteread"
+}
+
+// MatchType is the type of a remote-read label matcher
+type MatchType int
+
+// Label matcher types as defined by the remote-read protobuf schema
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+// RemoteReadMatcher selects series by label in a remote-read query
+type RemoteReadMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// RemoteReadSample represents a single raw sample returned by remote read
+type RemoteReadSample struct {
+	Timestamp int64 // milliseconds since epoch
+	Value     float64
+}
+
+// RemoteReadSeries represents a single raw series returned by remote read
+type RemoteReadSeries struct {
+	Labels  map[string]string
+	Samples []RemoteReadSample
+}
+
+// containerMatchers returns the matchers shared by all container-level selectors
+func (rr *RemoteReadClient) containerMatchers(metric, namespace string) []RemoteReadMatcher {
+	matchers := append([]RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: metric},
+	}, rr.config.Queries.ContainerMatchers()...)
+	if namespace != "" {
+		matchers = append(matchers, RemoteReadMatcher{Type: MatchEqual, Name: "namespace", Value: namespace})
+	}
+	return matchers
+}
+
+// GetCurrentPodMetrics retrieves current pod metrics through remote read
+func (rr *RemoteReadClient) GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]PodMetric, error) {
+	var pods []PodMetric
+
+	now := at
+	window := instantRateWindow(rr.config.ScrapeInterval, rr.config.InstantLookback)
+
+	// Get current CPU usage (rate is computed client-side from the raw counter, gauges are read as is)
+	cpuMatchers := append(rr.containerMatchers(rr.config.Queries.Metric(QueryCPUUsage), namespace), parseLabelFilters(selector)...)
+	cpuSeries, err := rr.read(ctx, now.Add(-window), now, cpuMatchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CPU usage: %w", err)
+	}
+
+	// Get current Memory usage
+	memMatchers := append(rr.containerMatchers(rr.config.Queries.Metric(QueryMemoryUsage), namespace), parseLabelFilters(selector)...)
+	memSeries, err := rr.read(ctx, now.Add(-window), now, memMatchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memory usage: %w", err)
+	}
+
+	// Create a map to group metrics by pod/container
+	podMetrics := make(map[string]*PodMetric)
+
+	// Process CPU usage, merging duplicate series
+	cpuMerger := newSeriesMerger(rr.config.DuplicateAggregation)
+	for _, series := range cpuSeries {
+		cpuUsage, ok := rr.currentCPUUsage(series.Samples)
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s",
+			series.Labels["namespace"],
+			series.Labels["pod"],
+			series.Labels["container"])
+
+		if _, exists := podMetrics[key]; !exists {
+			podMetrics[key] = &PodMetric{
+				Name:          series.Labels["pod"],
+				Namespace:     series.Labels["namespace"],
+				ContainerName: series.Labels["container"],
+				Labels:        make(map[string]string),
+			}
+		}
+		cpuMerger.merge(key, &podMetrics[key].CPUUsage, cpuUsage)
+	}
+
+	// Process Memory usage, merging duplicate series
+	memMerger := newSeriesMerger(rr.config.DuplicateAggregation)
+	for _, series := range memSeries {
+		if len(series.Samples) == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s",
+			series.Labels["namespace"],
+			series.Labels["pod"],
+			series.Labels["container"])
+
+		if _, exists := podMetrics[key]; !exists {
+			podMetrics[key] = &PodMetric{
+				Name:          series.Labels["pod"],
+				Namespace:     series.Labels["namespace"],
+				ContainerName: series.Labels["container"],
+				Labels:        make(map[string]string),
+			}
+		}
+		latest := series.Samples[len(series.Samples)-1]
+		memMerger.merge(key, &podMetrics[key].MemoryUsage, latest.Value)
+		if ts := time.UnixMilli(latest.Timestamp); ts.After(podMetrics[key].SampleTime) {
+			podMetrics[key].SampleTime = ts
+		}
+	}
+
+	// Get resource requests and limits, which are left empty without kube-state-metrics
+	err = rr.addResourceLimitsAndRequests(ctx, podMetrics, namespace, now.Add(-window), now)
+	if err != nil {
+		log.Printf("Warning: failed to get resource requests/limits: %v", err)
+		recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+	}
+
+	// Classify pods by QoS class, unknown without requests and limits
+	if err == nil {
+		setQoSClasses(podMetrics)
+	}
+
+	// Get container restarts and OOMKills
+	if rr.config.EnableContainerStatus {
+		if err := rr.addContainerStatus(ctx, podMetrics, namespace, now.Add(-window), now); err != nil {
+			log.Printf("Warning: failed to get container status: %v", err)
+		}
+	}
+
+	// Get pod start times to report pod age
+	startTimes, err := rr.getPodStartTimes(ctx, namespace, now.Add(-window), now)
+	if err != nil {
+		log.Printf("Warning: failed to get pod start times: %v", err)
+	}
+	setPodStartTimes(podMetrics, startTimes)
+
+	// Get the Kubernetes pod labels
+	podLabels, err := rr.GetPodLabels(ctx, namespace, now)
+	if err != nil {
+		log.Printf("Warning: failed to get pod labels: %v", err)
+	}
+	setPodLabels(podMetrics, podLabels)
+
+	// Convert map to slice, in a stable order, with the unit of the CPU usage
+	for _, metric := range podMetrics {
+		metric.CPUUnit = rr.config.Queries.CPUUnit
+		pods = append(pods, *metric)
+	}
+	sortPodMetrics(pods)
+
+	return pods, nil
+}
+
+// currentCPUUsage returns the current CPU usage of a raw series in the configured unit, the rate of
+// a counter or the newest value of a gauge
+func (rr *RemoteReadClient) currentCPUUsage(samples []RemoteReadSample) (float64, bool) {
+	if !rr.config.Queries.CPUUnit.gauge() {
+		return counterRate(samples)
+	}
+	if len(samples) == 0 {
+		return 0, false
+	}
+	return samples[len(samples)-1].Value, true
+}
+
+// addResourceLimitsAndRequests adds resource requests and limits to pod metrics
+func (rr *RemoteReadClient) addResourceLimitsAndRequests(ctx context.Context, podMetrics map[string]*PodMetric, namespace string, start, end time.Time) error {
+	resourceQueries := []struct {
+		metric   string
+		resource string
+		field    func(metric *PodMetric) *float64
+	}{
+		{rr.config.Queries.Metric(QueryResourceRequests), "cpu", func(m *PodMetric) *float64 { return &m.CPURequest }},
+		{rr.config.Queries.Metric(QueryResourceLimits), "cpu", func(m *PodMetric) *float64 { return &m.CPULimit }},
+		{rr.config.Queries.Metric(QueryResourceRequests), "memory", func(m *PodMetric) *float64 { return &m.MemoryRequest }},
+		{rr.config.Queries.Metric(QueryResourceLimits), "memory", func(m *PodMetric) *float64 { return &m.MemoryLimit }},
+	}
+
+	// Count request and limit series to detect a missing kube-state-metrics
+	seriesFound := 0
+	for _, rq := range resourceQueries {
+		matchers := append(rr.containerMatchers(rq.metric, namespace),
+			RemoteReadMatcher{Type: MatchEqual, Name: "resource", Value: rq.resource})
+
+		series, err := rr.read(ctx, start, end, matchers)
+		if err != nil {
+			return fmt.Errorf("failed to query %s %s: %w", rq.resource, rq.metric, err)
+		}
+		seriesFound += len(series)
+
+		merger := newSeriesMerger(rr.config.DuplicateAggregation)
+		for _, s := range series {
+			if len(s.Samples) == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%s",
+				s.Labels["namespace"],
+				s.Labels["pod"],
+				s.Labels["container"])
+
+			if metric, exists := podMetrics[key]; exists {
+				merger.merge(key, rq.field(metric), s.Samples[len(s.Samples)-1].Value)
+			}
+		}
+	}
+
+	if seriesFound == 0 && len(podMetrics) > 0 {
+		return errKubeStateMetricsMissing
+	}
+
+	return nil
+}
+
+// addContainerStatus adds restart counts and OOMKill status to pod metrics
+func (rr *RemoteReadClient) addContainerStatus(ctx context.Context, podMetrics map[string]*PodMetric, namespace string, start, end time.Time) error {
+	restartMatchers := rr.containerMatchers(rr.config.Queries.Metric(QueryRestarts), namespace)
+	oomMatchers := append(rr.containerMatchers(rr.config.Queries.Metric(QueryTerminatedReason), namespace),
+		RemoteReadMatcher{Type: MatchEqual, Name: "reason", Value: "OOMKilled"})
+
+	// Get container restarts
+	restartSeries, err := rr.read(ctx, start, end, restartMatchers)
+	if err != nil {
+		return fmt.Errorf("failed to query container restarts: %w", err)
+	}
+
+	for _, s := range restartSeries {
+		if len(s.Samples) == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", s.Labels["namespace"], s.Labels["pod"], s.Labels["container"])
+		if metric, exists := podMetrics[key]; exists {
+			metric.RestartCount = int(s.Samples[len(s.Samples)-1].Value)
+		}
+	}
+
+	// Get OOMKilled terminations
+	oomSeries, err := rr.read(ctx, start, end, oomMatchers)
+	if err != nil {
+		return fmt.Errorf("failed to query OOMKilled containers: %w", err)
+	}
+
+	for _, s := range oomSeries {
+		if len(s.Samples) == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", s.Labels["namespace"], s.Labels["pod"], s.Labels["container"])
+		if metric, exists := podMetrics[key]; exists && s.Samples[len(s.Samples)-1].Value > 0 {
+			metric.OOMKilled = true
+		}
+	}
+
+	return nil
+}
+
+// GetHistoricalMetrics retrieves and analyzes historical metrics for pods over the analysis window (7 days by default)
+func (rr *RemoteReadClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions) ([]HistoricalMetrics, error) {
+	var results []HistoricalMetrics
+	err := rr.StreamHistoricalMetrics(ctx, namespace, opts, func(metrics HistoricalMetrics) error {
+		results = append(results, metrics)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// StreamHistoricalMetrics analyzes historical metrics over the analysis window and passes each container result to fn as soon as it is computed
+func (rr *RemoteReadClient) StreamHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions, fn func(HistoricalMetrics) error) error {
+	now := time.Now()
+	windowStart := now.Add(-analysisWindow(opts.Window))
+
+	// Get pod list from the analysis window
+	stop := startTiming(ctx, TimingActivePods)
+	pods, err := rr.getActivePods(ctx, namespace, windowStart, now)
+	stop()
+	if err != nil {
+		return fmt.Errorf("failed to get active pods: %w", err)
+	}
+
+	// Add pods that completed during the window, e.g. finished Jobs, when requested
+	if opts.IncludeCompleted {
+		stop = startTiming(ctx, TimingCompletedPods)
+		completed, err := rr.getCompletedPods(ctx, namespace, windowStart, now)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to get completed pods: %v", err)
+			recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+		} else {
+			pods = mergeCompletedPods(pods, completed)
+		}
+	}
+
+	// Get pod start times to flag pods younger than the window
+	startTimes, err := rr.getPodStartTimes(ctx, namespace, now.Add(-DefaultRateWindow), now)
+	if err != nil {
+		log.Printf("Warning: failed to get pod start times: %v", err)
+	}
+
+	for _, pod := range pods {
+		for _, container := range pod.Containers {
+			metrics, err := rr.getHistoricalMetricsForContainer(ctx, pod.Name, pod.Namespace, container, windowStart, now)
+			if err != nil {
+				log.Printf("Warning: failed to get metrics for pod %s/%s container %s: %v",
+					pod.Namespace, pod.Name, container, err)
+				continue
+			}
+			metrics.Completed = pod.Completed
+			setHistoryCoverage(&metrics, startTimes[pod.Namespace+"/"+pod.Name], windowStart)
+			if err := fn(metrics); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetActivePods retrieves the pods that were active during the analysis window (7 days by default)
+func (rr *RemoteReadClient) GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]PodInfo, error) {
+	now := time.Now()
+	return rr.getActivePods(ctx, namespace, now.Add(-analysisWindow(window)), now)
+}
+
+// getActivePods retrieves pods that were active during the specified time range
+func (rr *RemoteReadClient) getActivePods(ctx context.Context, namespace string, start, end time.Time) ([]PodInfo, error) {
+	matchers := append([]RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryCPUUsage)},
+	}, rr.config.Queries.ContainerMatchers()...)
+	matchers = append(matchers, namespaceMatchers(namespace)...)
+
+	series, err := rr.read(ctx, end.Add(-instantRateWindow(rr.config.ScrapeInterval, rr.config.InstantLookback)), end, matchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active pods: %w", err)
+	}
+
+	podMap := make(map[string]PodInfo)
+	seen := make(map[string]bool)
+
+	for _, s := range series {
+		pod := s.Labels["pod"]
+		ns := s.Labels["namespace"]
+		container := s.Labels["container"]
+
+		// Several raw series may exist per container
+		containerKey := ns + "/" + pod + "/" + container
+		if seen[containerKey] {
+			continue
+		}
+		seen[containerKey] = true
+
+		key := ns + "/" + pod
+		if existing, exists := podMap[key]; exists {
+			// Add container to existing pod
+			existing.Containers = append(existing.Containers, container)
+			podMap[key] = existing
+		} else {
+			podMap[key] = PodInfo{
+				Name:       pod,
+				Namespace:  ns,
+				Containers: []string{container},
+			}
+		}
+	}
+
+	var pods []PodInfo
+	for _, pod := range podMap {
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
+
+// getPodStartTimes retrieves the start time of each pod in namespace, keyed by namespace/pod
+func (rr *RemoteReadClient) getPodStartTimes(ctx context.Context, namespace string, start, end time.Time) (map[string]time.Time, error) {
+	matchers := []RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryPodStartTime)},
+	}
+	matchers = append(matchers, namespaceMatchers(namespace)...)
+
+	series, err := rr.read(ctx, start, end, matchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod start times: %w", err)
+	}
+
+	// The sample value is the start time in Unix seconds
+	startTimes := make(map[string]time.Time)
+	for _, s := range series {
+		if len(s.Samples) > 0 {
+			startTimes[s.Labels["namespace"]+"/"+s.Labels["pod"]] = time.Unix(int64(s.Samples[len(s.Samples)-1].Value), 0)
+		}
+	}
+
+	return startTimes, nil
+}
+
+// getCompletedPods retrieves pods that completed during the specified time range
+func (rr *RemoteReadClient) getCompletedPods(ctx context.Context, namespace string, start, end time.Time) ([]PodInfo, error) {
+	completionMatchers := append([]RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryPodCompletionTime)},
+	}, namespaceMatchers(namespace)...)
+
+	completionSeries, err := rr.read(ctx, start, end, completionMatchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod completion times: %w", err)
+	}
+
+	// The sample value is the completion time in Unix seconds
+	completed := make(map[string]bool)
+	for _, s := range completionSeries {
+		if len(s.Samples) > 0 && s.Samples[len(s.Samples)-1].Value >= float64(start.Unix()) {
+			completed[s.Labels["namespace"]+"/"+s.Labels["pod"]] = true
+		}
+	}
+	if len(completed) == 0 {
+		return nil, nil
+	}
+
+	infoMatchers := append([]RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryContainerInfo)},
+	}, rr.config.Queries.ContainerMatchers()...)
+	infoMatchers = append(infoMatchers, namespaceMatchers(namespace)...)
+
+	infoSeries, err := rr.read(ctx, start, end, infoMatchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query container info: %w", err)
+	}
+
+	var refs []containerRef
+	for _, s := range infoSeries {
+		if completed[s.Labels["namespace"]+"/"+s.Labels["pod"]] {
+			refs = append(refs, containerRef{namespace: s.Labels["namespace"], pod: s.Labels["pod"], container: s.Labels["container"]})
+		}
+	}
+
+	return groupCompletedPods(refs), nil
+}
+
+// GetContainerSeries retrieves the raw datapoints of a single container metric
+func (rr *RemoteReadClient) GetContainerSeries(ctx context.Context, namespace, pod, container, metric string, start, end time.Time) ([]DataPoint, error) {
+	var name string
+	switch metric {
+	case SeriesCPU:
+		name = rr.config.Queries.Metric(QueryCPUUsage)
+	case SeriesMemory:
+		name = rr.config.Queries.Metric(QueryMemoryUsage)
+	default:
+		return nil, fmt.Errorf("unknown series metric %q", metric)
+	}
+
+	matchers := []RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: name},
+		{Type: MatchEqual, Name: "namespace", Value: namespace},
+		{Type: MatchEqual, Name: "pod", Value: pod},
+		{Type: MatchEqual, Name: "container", Value: container},
+	}
+	points, err := rr.queryRangeMetric(ctx, matchers, start, end, metric == SeriesCPU && !rr.config.Queries.CPUUnit.gauge())
+	if err != nil || metric != SeriesCPU {
+		return points, err
+	}
+	return rr.config.Queries.CPUUnit.pointsToCores(points), nil
+}
+
+// getHistoricalMetricsForContainer retrieves and analyzes historical metrics for a specific container
+func (rr *RemoteReadClient) getHistoricalMetricsForContainer(ctx context.Context, pod, namespace, container string, start, end time.Time) (HistoricalMetrics, error) {
+	step, coarsened := rangeStep(start, end, rr.config.MaxSamplesPerSeries)
+	selector := func(metric string, extra ...RemoteReadMatcher) []RemoteReadMatcher {
+		return append([]RemoteReadMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: metric},
+			{Type: MatchEqual, Name: "namespace", Value: namespace},
+			{Type: MatchEqual, Name: "pod", Value: pod},
+			{Type: MatchEqual, Name: "container", Value: container},
+		}, extra...)
+	}
+	cpuResource := RemoteReadMatcher{Type: MatchEqual, Name: "resource", Value: "cpu"}
+	memResource := RemoteReadMatcher{Type: MatchEqual, Name: "resource", Value: "memory"}
+
+	// Query CPU usage over time
+	stop := startTiming(ctx, TimingCPUUsage)
+	cpuUsage, err := rr.queryRangeMetric(ctx, selector(rr.config.Queries.Metric(QueryCPUUsage)), start, end, !rr.config.Queries.CPUUnit.gauge())
+	stop()
+	if err != nil {
+		return HistoricalMetrics{}, fmt.Errorf("failed to query CPU usage: %w", err)
+	}
+	cpuUsage = rr.config.Queries.CPUUnit.pointsToCores(cpuUsage)
+
+	// Query Memory usage over time
+	stop = startTiming(ctx, TimingMemoryUsage)
+	memUsage, err := rr.queryRangeMetric(ctx, selector(rr.config.Queries.Metric(QueryMemoryUsage)), start, end, false)
+	stop()
+	if err != nil {
+		return HistoricalMetrics{}, fmt.Errorf("failed to query memory usage: %w", err)
+	}
+
+	// Query CPU requests
+	stop = startTiming(ctx, TimingCPURequests)
+	cpuRequests, err := rr.queryRangeMetric(ctx, selector(rr.config.Queries.Metric(QueryResourceRequests), cpuResource), start, end, false)
+	stop()
+	if err != nil {
+		log.Printf("Warning: failed to query CPU requests for %s/%s/%s: %v", namespace, pod, container, err)
+		cpuRequests = []DataPoint{} // Continue without requests data
+	}
+
+	// Query Memory requests
+	stop = startTiming(ctx, TimingMemoryRequests)
+	memRequests, err := rr.queryRangeMetric(ctx, selector(rr.config.Queries.Metric(QueryResourceRequests), memResource), start, end, false)
+	stop()
+	if err != nil {
+		log.Printf("Warning: failed to query memory requests for %s/%s/%s: %v", namespace, pod, container, err)
+		memRequests = []DataPoint{} // Continue without requests data
+	}
+
+	// Query CPU limits
+	stop = startTiming(ctx, TimingCPULimits)
+	cpuLimits, err := rr.queryRangeMetric(ctx, selector(rr.config.Queries.Metric(QueryResourceLimits), cpuResource), start, end, false)
+	stop()
+	if err != nil {
+		log.Printf("Warning: failed to query CPU limits for %s/%s/%s: %v", namespace, pod, container, err)
+		cpuLimits = []DataPoint{} // Continue without limits data
+	}
+
+	// Query Memory limits
+	stop = startTiming(ctx, TimingMemoryLimits)
+	memLimits, err := rr.queryRangeMetric(ctx, selector(rr.config.Queries.Metric(QueryResourceLimits), memResource), start, end, false)
+	stop()
+	if err != nil {
+		log.Printf("Warning: failed to query memory limits for %s/%s/%s: %v", namespace, pod, container, err)
+		memLimits = []DataPoint{} // Continue without limits data
+	}
+
+	// Query container restarts and OOMKills
+	var restarts int
+	var oomKilled bool
+	if rr.config.EnableContainerStatus {
+		stop = startTiming(ctx, TimingContainerStatus)
+		restarts, oomKilled, err = rr.getContainerStatus(ctx, pod, namespace, container, start, end)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to query container status for %s/%s/%s: %v", namespace, pod, container, err)
+		}
+	}
+
+	// Query the hourly restart rate to detect crash loops building up over the window
+	var restartTrend string
+	if rr.config.EnableRestartTrend {
+		stop = startTiming(ctx, TimingRestartTrend)
+		restartRate, err := rr.queryRangeRate(ctx, selector(rr.config.Queries.Metric(QueryRestarts)), start, end, time.Hour)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to query restart rate for %s/%s/%s: %v", namespace, pod, container, err)
+		} else {
+			restartTrend = calculateTrend(rr.config, restartRate, DefaultTrendThreshold)
+		}
+	}
+
+	// Analyze the data
+	stop = startTiming(ctx, TimingAnalysis)
+	cpuData := analyzeResourceData(rr.config, cpuUsage, cpuRequests, cpuLimits, rr.config.CPUTrendThreshold)
+	memData := analyzeResourceData(rr.config, memUsage, memRequests, memLimits, rr.config.MemoryTrendThreshold)
+	analysis := generateUsageAnalysis(rr.config, cpuData, memData, oomKilled, restartTrend)
+	stop()
+	cpuData.DataCompleteness = dataCompleteness(len(cpuUsage), start, end, step)
+	memData.DataCompleteness = dataCompleteness(len(memUsage), start, end, step)
+
+	return HistoricalMetrics{
+		PodName:       pod,
+		Namespace:     namespace,
+		ContainerName: container,
+		CPU:           cpuData,
+		Memory:        memData,
+		Analysis:      analysis,
+		RestartCount:  restarts,
+		OOMKilled:     oomKilled,
+		RestartTrend:  restartTrend,
+		Step:          step,
+		StepCoarsened: coarsened,
+	}, nil
+}
+
+// getContainerStatus retrieves restarts and OOMKill status for a container over the specified time range
+func (rr *RemoteReadClient) getContainerStatus(ctx context.Context, pod, namespace, container string, start, end time.Time) (int, bool, error) {
+	selector := func(metric string, extra ...RemoteReadMatcher) []RemoteReadMatcher {
+		return append([]RemoteReadMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: metric},
+			{Type: MatchEqual, Name: "namespace", Value: namespace},
+			{Type: MatchEqual, Name: "pod", Value: pod},
+			{Type: MatchEqual, Name: "container", Value: container},
+		}, extra...)
+	}
+
+	restartSeries, err := rr.read(ctx, start, end, selector(rr.config.Queries.Metric(QueryRestarts)))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query restarts: %w", err)
+	}
+
+	var restarts float64
+	for _, s := range restartSeries {
+		restarts += counterIncrease(s.Samples)
+	}
+
+	oomSeries, err := rr.read(ctx, start, end, selector(rr.config.Queries.Metric(QueryTerminatedReason),
+		RemoteReadMatcher{Type: MatchEqual, Name: "reason", Value: "OOMKilled"}))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query OOMKills: %w", err)
+	}
+
+	oomKilled := false
+	for _, s := range oomSeries {
+		for _, sample := range s.Samples {
+			if sample.Value > 0 {
+				oomKilled = true
+			}
+		}
+	}
+
+	return int(math.Round(restarts)), oomKilled, nil
+}
+
+// GetNamespaces retrieves all namespaces through remote read
+func (rr *RemoteReadClient) GetNamespaces(ctx context.Context) ([]string, error) {
+	now := time.Now()
+
+	series, err := rr.read(ctx, now.Add(-5*time.Minute), now, rr.containerMatchers(rr.config.Queries.Metric(QueryCPUUsage), ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query namespaces: %w", err)
+	}
+
+	var namespaces []string
+	namespacesSet := make(map[string]bool)
+
+	for _, s := range series {
+		namespace := s.Labels["namespace"]
+		if namespace != "" && !namespacesSet[namespace] {
+			namespacesSet[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces, nil
+}
+
+// GetPodStatus retrieves the phase and last termination reasons of a pod through remote read
+func (rr *RemoteReadClient) GetPodStatus(ctx context.Context, namespace, pod string) (PodStatus, error) {
+	status := PodStatus{LastTerminationReasons: make(map[string]string)}
+	now := time.Now()
+	podMatchers := func(metric string) []RemoteReadMatcher {
+		return []RemoteReadMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: metric},
+			{Type: MatchEqual, Name: "namespace", Value: namespace},
+			{Type: MatchEqual, Name: "pod", Value: pod},
+		}
+	}
+
+	// Get the current phase (the series for the active phase has value 1)
+	phaseSeries, err := rr.read(ctx, now.Add(-5*time.Minute), now, podMatchers(rr.config.Queries.Metric(QueryPodPhase)))
+	if err != nil {
+		return status, fmt.Errorf("failed to query pod phase: %w", err)
+	}
+
+	for _, s := range phaseSeries {
+		if len(s.Samples) > 0 && s.Samples[len(s.Samples)-1].Value == 1 {
+			status.Phase = s.Labels["phase"]
+		}
+	}
+
+	// Get the last termination reason per container
+	reasonSeries, err := rr.read(ctx, now.Add(-5*time.Minute), now, podMatchers(rr.config.Queries.Metric(QueryTerminatedReason)))
+	if err != nil {
+		return status, fmt.Errorf("failed to query last terminated reason: %w", err)
+	}
+
+	for _, s := range reasonSeries {
+		if len(s.Samples) > 0 && s.Samples[len(s.Samples)-1].Value > 0 {
+			status.LastTerminationReasons[s.Labels["container"]] = s.Labels["reason"]
+		}
+	}
+
+	return status, nil
+}
+
+// GetNodeAllocatable retrieves allocatable CPU and memory per node from kube-state-metrics
+func (rr *RemoteReadClient) GetNodeAllocatable(ctx context.Context) ([]NodeAllocatable, error) {
+	now := time.Now()
+	nodes := make(map[string]*NodeAllocatable)
+
+	for _, resource := range []string{"cpu", "memory"} {
+		matchers := []RemoteReadMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryNodeAllocatable)},
+			{Type: MatchEqual, Name: "resource", Value: resource},
+		}
+
+		series, err := rr.read(ctx, now.Add(-5*time.Minute), now, matchers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query node allocatable %s: %w", resource, err)
+		}
+
+		for _, s := range series {
+			if len(s.Samples) == 0 {
+				continue
+			}
+
+			node := s.Labels["node"]
+			if _, exists := nodes[node]; !exists {
+				nodes[node] = &NodeAllocatable{Node: node}
+			}
+			value := s.Samples[len(s.Samples)-1].Value
+			if resource == "cpu" {
+				nodes[node].CPU = value
+			} else {
+				nodes[node].Memory = value
+			}
+		}
+	}
+
+	var allocatable []NodeAllocatable
+	for _, node := range nodes {
+		allocatable = append(allocatable, *node)
+	}
+
+	return allocatable, nil
+}
+
+// GetPodLabels retrieves the Kubernetes labels of each pod from kube-state-metrics
+func (rr *RemoteReadClient) GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error) {
+	matchers := []RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryPodLabels)},
+	}
+	matchers = append(matchers, namespaceMatchers(namespace)...)
+
+	series, err := rr.read(ctx, at.Add(-instantRateWindow(rr.config.ScrapeInterval, rr.config.InstantLookback)), at, matchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod labels: %w", err)
+	}
+
+	podLabels := make(map[string]map[string]string)
+	for _, s := range series {
+		if len(s.Samples) > 0 {
+			addPodLabels(podLabels, s.Labels)
+		}
+	}
+
+	return podLabels, nil
+}
+
+// GetPodNodes retrieves the node each pod is scheduled on from kube-state-metrics
+func (rr *RemoteReadClient) GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error) {
+	matchers := []RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: rr.config.Queries.Metric(QueryPodInfo)},
+		{Type: MatchNotEqual, Name: "node", Value: ""},
+	}
+	if namespace != "" {
+		matchers = append(matchers, RemoteReadMatcher{Type: MatchEqual, Name: "namespace", Value: namespace})
+	}
+
+	series, err := rr.read(ctx, at.Add(-instantRateWindow(rr.config.ScrapeInterval, rr.config.InstantLookback)), at, matchers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod nodes: %w", err)
+	}
+
+	// Keep the node of the series with the newest sample, e.g. after a pod was rescheduled
+	podNodes := make(map[string]string)
+	newest := make(map[string]int64)
+	for _, s := range series {
+		if len(s.Samples) == 0 {
+			continue
+		}
+		key := s.Labels["namespace"] + "/" + s.Labels["pod"]
+		if ts := s.Samples[len(s.Samples)-1].Timestamp; ts >= newest[key] {
+			newest[key] = ts
+			podNodes[key] = s.Labels["node"]
+		}
+	}
+
+	return podNodes, nil
+}
+
+// QueryInstant is not supported because remote read only returns raw series
+func (rr *RemoteReadClient) QueryInstant(ctx context.Context, query string) ([]QuerySample, error) {
+	return nil, fmt.Errorf("raw PromQL queries are not supported by the remote-read backend")
+}
+
+// queryRangeMetric reads raw series and evaluates them at a 5-minute resolution.
+// Counters are converted to a per-second rate over the rate window.
+func (rr *RemoteReadClient) queryRangeMetric(ctx context.Context, matchers []RemoteReadMatcher, start, end time.Time, counter bool) ([]DataPoint, error) {
+	window := 5 * time.Minute
+	if counter {
+		window = rateWindow(rr.config.ScrapeInterval)
+	}
+	return rr.queryRangeWindow(ctx, matchers, start, end, window, counter)
+}
+
+// queryRangeRate evaluates a counter as a per-second rate over the given window
+func (rr *RemoteReadClient) queryRangeRate(ctx context.Context, matchers []RemoteReadMatcher, start, end time.Time, window time.Duration) ([]DataPoint, error) {
+	return rr.queryRangeWindow(ctx, matchers, start, end, window, true)
+}
+
+// queryRangeWindow reads raw series and evaluates them at each step over the given window
+func (rr *RemoteReadClient) queryRangeWindow(ctx context.Context, matchers []RemoteReadMatcher, start, end time.Time, window time.Duration, counter bool) ([]DataPoint, error) {
+	step, _ := rangeStep(start, end, rr.config.MaxSamplesPerSeries) // Coarsened to respect the sample cap
+	if rr.config.AlignRangeQueries {
+		start, end = alignRange(start, end, step)
+	}
+
+	series, err := rr.read(ctx, start.Add(-window), end, matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	var evaluated [][]DataPoint
+	for _, s := range series {
+		evaluated = append(evaluated, evaluateSteps(s.Samples, start, end, step, window, counter))
+	}
+
+	// Merge series the matchers unexpectedly matched instead of interleaving their points
+	return mergeSeriesPoints(evaluated, rr.config.DuplicateAggregation), nil
+}
+
+// read issues a single remote-read query and returns the matching raw series
+func (rr *RemoteReadClient) read(ctx context.Context, start, end time.Time, matchers []RemoteReadMatcher) ([]RemoteReadSeries, error) {
+	matchers = append(matchers, rr.config.Queries.ExtraMatchers()...)
+	body := snappy.Encode(nil, encodeReadRequest(start.UnixMilli(), end.UnixMilli(), matchers))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", rr.readURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+
+	resp, err := rr.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote read failed with status %d", resp.StatusCode)
+	}
+
+	compressed, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress remote-read response: %w", err)
+	}
+
+	series, err := decodeReadResponse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode remote-read response: %w", err)
+	}
+
+	// Drop NaN and ±Inf samples, e.g. staleness markers, and the series left without samples
+	finite := series[:0]
+	for _, s := range series {
+		samples := s.Samples[:0]
+		for _, sample := range s.Samples {
+			if isFinite(sample.Value) {
+				samples = append(samples, sample)
+			}
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		s.Samples = samples
+		finite = append(finite, s)
+	}
+
+	return finite, nil
+}
+
+// encodeReadRequest encodes a prometheus.ReadRequest holding a single query.
+// Only SAMPLES responses are requested so the server replies with a single message.
+func encodeReadRequest(startMs, endMs int64, matchers []RemoteReadMatcher) []byte {
+	var query []byte
+	query = protowire.AppendTag(query, 1, protowire.VarintType)
+	query = protowire.AppendVarint(query, uint64(startMs))
+	query = protowire.AppendTag(query, 2, protowire.VarintType)
+	query = protowire.AppendVarint(query, uint64(endMs))
+	for _, m := range matchers {
+		var matcher []byte
+		matcher = protowire.AppendTag(matcher, 1, protowire.VarintType)
+		matcher = protowire.AppendVarint(matcher, uint64(m.Type))
+		matcher = protowire.AppendTag(matcher, 2, protowire.BytesType)
+		matcher = protowire.AppendString(matcher, m.Name)
+		matcher = protowire.AppendTag(matcher, 3, protowire.BytesType)
+		matcher = protowire.AppendString(matcher, m.Value)
+
+		query = protowire.AppendTag(query, 3, protowire.BytesType)
+		query = protowire.AppendBytes(query, matcher)
+	}
+
+	var request []byte
+	request = protowire.AppendTag(request, 1, protowire.BytesType)
+	request = protowire.AppendBytes(request, query)
+	request = protowire.AppendTag(request, 2, protowire.VarintType)
+	request = protowire.AppendVarint(request, 0) // SAMPLES
+
+	return request
+}
+
+// decodeReadResponse decodes a prometheus.ReadResponse into raw series
+func decodeReadResponse(b []byte) ([]RemoteReadSeries, error) {
+	var series []RemoteReadSeries
+
+	err := walkFields(b, func(num protowire.Number, _ protowire.Type, result []byte, _ uint64) error {
+		if num != 1 { // results
+			return nil
+		}
+		return walkFields(result, func(num protowire.Number, _ protowire.Type, ts []byte, _ uint64) error {
+			if num != 1 { // timeseries
+				return nil
+			}
+			s, err := decodeTimeSeries(ts)
+			if err != nil {
+				return err
+			}
+			series = append(series, s)
+			return nil
+		})
+	})
+
+	return series, err
+}
+
+// decodeTimeSeries decodes a prometheus.TimeSeries message
+func decodeTimeSeries(b []byte) (RemoteReadSeries, error) {
+	series := RemoteReadSeries{Labels: make(map[string]string)}
+
+	err := walkFields(b, func(num protowire.Number, _ protowire.Type, field []byte, _ uint64) error {
+		switch num {
+		case 1: // labels
+			var name, value string
+			err := walkFields(field, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				switch num {
+				case 1:
+					name = string(v)
+				case 2:
+					value = string(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			series.Labels[name] = value
+		case 2: // samples
+			var sample RemoteReadSample
+			err := walkFields(field, func(num protowire.Number, _ protowire.Type, _ []byte, v uint64) error {
+				switch num {
+				case 1:
+					sample.Value = math.Float64frombits(v)
+				case 2:
+					sample.Timestamp = int64(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			series.Samples = append(series.Samples, sample)
+		}
+		return nil
+	})
+
+	return series, err
+}
+
+// walkFields iterates over the fields of a protobuf message. Length-delimited
+// fields are passed as bytes; varint and fixed64 fields are passed as integers.
+func walkFields(b []byte, fn func(num protowire.Number, typ protowire.Type, bytesValue []byte, intValue uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var err error
+		switch typ {
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				err = fn(num, typ, v, 0)
+			}
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			if n >= 0 {
+				err = fn(num, typ, nil, v)
+			}
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(b)
+			if n >= 0 {
+				err = fn(num, typ, nil, v)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// counterIncrease calculates the increase of a counter, accounting for resets
+func counterIncrease(samples []RemoteReadSample) float64 {
+	var increase float64
+	for i := 1; i < len(samples); i++ {
+		delta := samples[i].Value - samples[i-1].Value
+		if delta < 0 {
+			// Counter reset - the new value is the increase since the reset
+			delta = samples[i].Value
+		}
+		increase += delta
+	}
+	return increase
+}
+
+// counterRate calculates the per-second rate of a counter, accounting for resets
+func counterRate(samples []RemoteReadSample) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+
+	elapsed := float64(samples[len(samples)-1].Timestamp-samples[0].Timestamp) / 1000
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return counterIncrease(samples) / elapsed, true
+}
+
+// evaluateSteps evaluates raw samples at each step between start and end.
+// Gauges use the latest sample within the window; counters use the rate over the window.
+func evaluateSteps(samples []RemoteReadSample, start, end time.Time, step, window time.Duration, counter bool) []DataPoint {
+	var dataPoints []DataPoint
+
+	lo, hi := 0, 0
+	for t := start; !t.After(end); t = t.Add(step) {
+		ts := t.UnixMilli()
+		for hi < len(samples) && samples[hi].Timestamp <= ts {
+			hi++
+		}
+		for lo < hi && samples[lo].Timestamp <= ts-window.Milliseconds() {
+			lo++
+		}
+		if lo == hi {
+			continue
+		}
+
+		value := samples[hi-1].Value
+		if counter {
+			rate, ok := counterRate(samples[lo:hi])
+			if !ok {
+				continue
+			}
+			value = rate
+		}
+
+		dataPoints = append(dataPoints, DataPoint{
+			Timestamp: time.Unix(ts/1000, 0),
+			Value:     value,
+		})
+	}
+
+	return dataPoints
+}
+
+// AnalyzeHistoricalMetrics recomputes the statistics, trends and analysis of hm from its series,
+// keeping its data completeness
+func (rr *RemoteReadClient) AnalyzeHistoricalMetrics(hm HistoricalMetrics) HistoricalMetrics {
+	return analyzeHistoricalMetrics(rr.config, hm)
+}
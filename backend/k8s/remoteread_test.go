@@ -0,0 +1,277 @@
+package k8s
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteReadQuery is a decoded remote-read query
+type remoteReadQuery struct {
+	start, end int64
+	matchers   []RemoteReadMatcher
+}
+
+// label returns the value of the equality matcher on name, empty if there is none
+func (q remoteReadQuery) label(name string) string {
+	for _, m := range q.matchers {
+		if m.Name == name && m.Type == MatchEqual {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+// decodeReadRequest decodes the single query of a prometheus.ReadRequest
+func decodeReadRequest(b []byte) (remoteReadQuery, error) {
+	var query remoteReadQuery
+	err := walkFields(b, func(num protowire.Number, _ protowire.Type, q []byte, _ uint64) error {
+		if num != 1 { // queries
+			return nil
+		}
+		return walkFields(q, func(num protowire.Number, _ protowire.Type, field []byte, v uint64) error {
+			switch num {
+			case 1:
+				query.start = int64(v)
+			case 2:
+				query.end = int64(v)
+			case 3:
+				var m RemoteReadMatcher
+				err := walkFields(field, func(num protowire.Number, _ protowire.Type, s []byte, v uint64) error {
+					switch num {
+					case 1:
+						m.Type = MatchType(v)
+					case 2:
+						m.Name = string(s)
+					case 3:
+						m.Value = string(s)
+					}
+					return nil
+				})
+				query.matchers = append(query.matchers, m)
+				return err
+			}
+			return nil
+		})
+	})
+	return query, err
+}
+
+// encodeReadResponse encodes series as a prometheus.ReadResponse with a single query result
+func encodeReadResponse(series []RemoteReadSeries) []byte {
+	var result []byte
+	for _, s := range series {
+		var ts []byte
+		for name, value := range s.Labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		for _, sample := range s.Samples {
+			var encoded []byte
+			encoded = protowire.AppendTag(encoded, 1, protowire.Fixed64Type)
+			encoded = protowire.AppendFixed64(encoded, math.Float64bits(sample.Value))
+			encoded = protowire.AppendTag(encoded, 2, protowire.VarintType)
+			encoded = protowire.AppendVarint(encoded, uint64(sample.Timestamp))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, encoded)
+		}
+		result = protowire.AppendTag(result, 1, protowire.BytesType)
+		result = protowire.AppendBytes(result, ts)
+	}
+
+	var response []byte
+	response = protowire.AppendTag(response, 1, protowire.BytesType)
+	return protowire.AppendBytes(response, result)
+}
+
+// newRemoteReadServer serves remote-read requests with the series fn returns for each query
+func newRemoteReadServer(t *testing.T, fn func(query remoteReadQuery) []RemoteReadSeries) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Read-Version") == "" {
+			http.Error(w, "missing remote-read headers", http.StatusBadRequest)
+			return
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		raw, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query, err := decodeReadRequest(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(snappy.Encode(nil, encodeReadResponse(fn(query))))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// containerSeries returns a series of the app container of pod web-0 in namespace shop
+func containerSeries(extra map[string]string, samples ...RemoteReadSample) RemoteReadSeries {
+	labels := map[string]string{"namespace": "shop", "pod": "web-0", "container": "app"}
+	for name, value := range extra {
+		labels[name] = value
+	}
+	return RemoteReadSeries{Labels: labels, Samples: samples}
+}
+
+func TestRemoteReadRequestRoundTrip(t *testing.T) {
+	matchers := []RemoteReadMatcher{
+		{Type: MatchEqual, Name: "__name__", Value: "container_cpu_usage_seconds_total"},
+		{Type: MatchNotEqual, Name: "container", Value: "POD"},
+		{Type: MatchRegexp, Name: "namespace", Value: "team-.*"},
+		{Type: MatchNotRegexp, Name: "pod", Value: "debug-.*"},
+	}
+	query, err := decodeReadRequest(encodeReadRequest(1000, 2000, matchers))
+	if err != nil {
+		t.Fatalf("decodeReadRequest() error = %v", err)
+	}
+	want := remoteReadQuery{start: 1000, end: 2000, matchers: matchers}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("decoded request = %+v, want %+v", query, want)
+	}
+}
+
+func TestDecodeReadResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []RemoteReadSeries
+	}{
+		{"empty", nil},
+		{"single series", []RemoteReadSeries{containerSeries(nil, RemoteReadSample{Timestamp: 1000, Value: 1.5})}},
+		{"several series and samples", []RemoteReadSeries{
+			containerSeries(nil, RemoteReadSample{Timestamp: 1000, Value: 1}, RemoteReadSample{Timestamp: 2000, Value: 2}),
+			containerSeries(map[string]string{"container": "sidecar"}, RemoteReadSample{Timestamp: 1000, Value: -0.25}),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeReadResponse(encodeReadResponse(tt.series))
+			if err != nil {
+				t.Fatalf("decodeReadResponse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.series) {
+				t.Errorf("decodeReadResponse() = %+v, want %+v", got, tt.series)
+			}
+		})
+	}
+}
+
+func TestCounterRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []RemoteReadSample
+		want    float64
+		wantOK  bool
+	}{
+		{"steady", []RemoteReadSample{{0, 0}, {30_000, 15}, {60_000, 30}}, 0.5, true},
+		{"counter reset", []RemoteReadSample{{0, 100}, {30_000, 115}, {60_000, 15}}, 0.5, true},
+		{"single sample", []RemoteReadSample{{0, 10}}, 0, false},
+		{"no elapsed time", []RemoteReadSample{{1000, 1}, {1000, 2}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := counterRate(tt.samples)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("counterRate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRemoteReadGetCurrentPodMetrics(t *testing.T) {
+	at := time.Now().Truncate(time.Second)
+	ms := func(ago time.Duration) int64 { return at.Add(-ago).UnixMilli() }
+
+	server := newRemoteReadServer(t, func(q remoteReadQuery) []RemoteReadSeries {
+		switch q.label("__name__") + "/" + q.label("resource") {
+		case "container_cpu_usage_seconds_total/":
+			return []RemoteReadSeries{containerSeries(nil,
+				RemoteReadSample{Timestamp: ms(time.Minute), Value: 100},
+				RemoteReadSample{Timestamp: ms(30 * time.Second), Value: 115},
+				RemoteReadSample{Timestamp: ms(0), Value: 130})}
+		case "container_memory_working_set_bytes/":
+			return []RemoteReadSeries{containerSeries(nil,
+				RemoteReadSample{Timestamp: ms(30 * time.Second), Value: 100 << 20},
+				RemoteReadSample{Timestamp: ms(0), Value: 120 << 20})}
+		case "kube_pod_container_resource_requests/cpu":
+			return []RemoteReadSeries{containerSeries(nil, RemoteReadSample{Timestamp: ms(0), Value: 0.25})}
+		case "kube_pod_container_resource_limits/cpu":
+			return []RemoteReadSeries{containerSeries(nil, RemoteReadSample{Timestamp: ms(0), Value: 1})}
+		case "kube_pod_container_resource_requests/memory":
+			return []RemoteReadSeries{containerSeries(nil, RemoteReadSample{Timestamp: ms(0), Value: 256 << 20})}
+		case "kube_pod_container_resource_limits/memory":
+			return []RemoteReadSeries{containerSeries(nil, RemoteReadSample{Timestamp: ms(0), Value: 512 << 20})}
+		}
+		return nil
+	})
+
+	rr, err := NewRemoteReadClient(MetricsClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewRemoteReadClient() error = %v", err)
+	}
+	pods, err := rr.GetCurrentPodMetrics(context.Background(), "shop", "", at)
+	if err != nil {
+		t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("got %d pod metrics, want 1: %+v", len(pods), pods)
+	}
+
+	pod := pods[0]
+	if pod.Name != "web-0" || pod.Namespace != "shop" || pod.ContainerName != "app" {
+		t.Errorf("pod = %s/%s/%s, want shop/web-0/app", pod.Namespace, pod.Name, pod.ContainerName)
+	}
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"cpu usage", pod.CPUUsage, 0.5},
+		{"memory usage", pod.MemoryUsage, 120 << 20},
+		{"cpu request", pod.CPURequest, 0.25},
+		{"cpu limit", pod.CPULimit, 1},
+		{"memory request", pod.MemoryRequest, 256 << 20},
+		{"memory limit", pod.MemoryLimit, 512 << 20},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if !pod.SampleTime.Equal(at) {
+		t.Errorf("sample time = %s, want %s", pod.SampleTime, at)
+	}
+}
+
+func TestRemoteReadErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	rr, err := NewRemoteReadClient(MetricsClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewRemoteReadClient() error = %v", err)
+	}
+	if err := rr.Ping(context.Background()); err == nil {
+		t.Error("Ping() error = nil, want the 503 reported")
+	}
+}
@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// retryBackoff is the pause before the first retry, doubled for each further one
+const retryBackoff = 100 * time.Millisecond
+
+// retryTransport retries backend requests that failed with a transport error or a 502, 503 or 504.
+// When the request has a deadline, each attempt gets an equal share of the remaining time, so a
+// single slow attempt can't use up the budget of the retries.
+type retryTransport struct {
+	retries int // Attempts after the first one
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		last := attempt == t.retries || (req.Body != nil && req.GetBody == nil)
+
+		attemptReq, cancel, err := t.attemptRequest(req, t.retries-attempt+1, attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(attemptReq)
+		if ctx.Err() != nil || (err == nil && !retryableStatus(resp.StatusCode)) || last {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			// The attempt deadline must outlive the request until its body is read
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: cancel}
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+
+		// Wait before retrying, unless the request is done first
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				err = errors.New("backend returned " + resp.Status)
+			}
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// attemptRequest returns the request for an attempt with its share of the remaining deadline,
+// and a fresh body for retries
+func (t *retryTransport) attemptRequest(req *http.Request, attemptsLeft int, retry bool) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok && attemptsLeft > 1 {
+		ctx, cancel = context.WithTimeout(ctx, time.Until(deadline)/time.Duration(attemptsLeft))
+	}
+
+	attemptReq := req.WithContext(ctx)
+	if retry && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// retryableStatus reports whether a response status is a transient backend or proxy failure
+func retryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer serves an empty range result, answering the first failures requests with fail
+func newFlakyServer(t *testing.T, failures int32, fail func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			fail(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryDeadlineSplitting(t *testing.T) {
+	// The first attempt hangs until it is abandoned, later ones answer right away
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+	const deadline = time.Second
+
+	tests := []struct {
+		name         string
+		retries      int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"retried within the deadline", 2, false, 2},
+		{"single retry", 1, false, 2},
+		{"no retries", 0, true, 1},
+	}
+	for _, tt := range tests {
+		clients := map[string]func(t *testing.T, server *httptest.Server) MetricsClient{
+			"prometheus": func(t *testing.T, server *httptest.Server) MetricsClient {
+				return newTestPrometheusClient(t, server, MetricsClientConfig{RetryAttempts: tt.retries})
+			},
+			"victoriametrics": func(t *testing.T, server *httptest.Server) MetricsClient {
+				return newTestVMClient(t, server, MetricsClientConfig{RetryAttempts: tt.retries})
+			},
+		}
+		for client, newClient := range clients {
+			t.Run(client+"/"+tt.name, func(t *testing.T) {
+				server, requests := newFlakyServer(t, 1, slow)
+				metricsClient := newClient(t, server)
+
+				ctx, cancel := context.WithTimeout(context.Background(), deadline)
+				defer cancel()
+				begin := time.Now()
+				end := time.Now()
+				_, err := metricsClient.GetContainerSeries(ctx, "shop", "web-0", "app", SeriesMemory, end.Add(-time.Hour), end)
+				elapsed := time.Since(begin)
+
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("GetContainerSeries() error = %v, want error %v", err, tt.wantErr)
+				}
+				if got := requests.Load(); got != tt.wantRequests {
+					t.Errorf("backend received %d requests, want %d", got, tt.wantRequests)
+				}
+				if !tt.wantErr && elapsed >= deadline {
+					t.Errorf("succeeded after %s, want before the %s deadline", elapsed, deadline)
+				}
+			})
+		}
+	}
+}
+
+func TestRetryTransportStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantRequests int32
+		wantStatus   int
+	}{
+		{"bad gateway retried", http.StatusBadGateway, 2, http.StatusOK},
+		{"unavailable retried", http.StatusServiceUnavailable, 2, http.StatusOK},
+		{"gateway timeout retried", http.StatusGatewayTimeout, 2, http.StatusOK},
+		{"bad request returned", http.StatusBadRequest, 1, http.StatusBadRequest},
+		{"internal error returned", http.StatusInternalServerError, 1, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newFlakyServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			})
+			client := &http.Client{Transport: &retryTransport{retries: 2, next: http.DefaultTransport}}
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("backend received %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
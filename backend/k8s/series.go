@@ -0,0 +1,24 @@
+package k8s
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metrics selectable for a single container series
+const (
+	SeriesCPU    = "cpu"    // CPU usage in cores
+	SeriesMemory = "memory" // Memory usage in bytes
+)
+
+// seriesQuery returns the PromQL range query for a container's series of the given metric
+func seriesQuery(queries QueryTemplates, scrapeInterval time.Duration, metric, namespace, pod, container string) (string, error) {
+	containerFilter := fmt.Sprintf(`namespace="%s", pod="%s", container="%s"`, namespace, pod, container)
+	switch metric {
+	case SeriesCPU:
+		return queries.CPUUnit.usageQuery(queries.Selector(QueryCPUUsage, containerFilter), rateWindow(scrapeInterval), 0), nil
+	case SeriesMemory:
+		return queries.Selector(QueryMemoryUsage, containerFilter), nil
+	}
+	return "", fmt.Errorf("unknown series metric %q", metric)
+}
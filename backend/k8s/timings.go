@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Timing phase names reported by QueryTimings
+const (
+	TimingActivePods      = "active_pods"
+	TimingCompletedPods   = "completed_pods"
+	TimingExport          = "export"
+	TimingCPUUsage        = "cpu_usage"
+	TimingMemoryUsage     = "memory_usage"
+	TimingCPURequests     = "cpu_requests"
+	TimingMemoryRequests  = "memory_requests"
+	TimingCPULimits       = "cpu_limits"
+	TimingMemoryLimits    = "memory_limits"
+	TimingContainerStatus = "container_status"
+	TimingRestartTrend    = "restart_trend"
+	TimingAnalysis        = "analysis"
+)
+
+// PhaseTiming is the accumulated duration of a query phase across all calls
+type PhaseTiming struct {
+	Total time.Duration
+	Count int
+}
+
+// QueryTimings collects per-phase durations for a single request
+type QueryTimings struct {
+	mu     sync.Mutex
+	phases map[string]PhaseTiming
+}
+
+type queryTimingsKey struct{}
+
+// WithQueryTimings returns a context that records query timings into the returned collector
+func WithQueryTimings(ctx context.Context) (context.Context, *QueryTimings) {
+	timings := &QueryTimings{phases: make(map[string]PhaseTiming)}
+	return context.WithValue(ctx, queryTimingsKey{}, timings), timings
+}
+
+// Record adds a duration to a phase
+func (t *QueryTimings) Record(phase string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := t.phases[phase]
+	timing.Total += d
+	timing.Count++
+	t.phases[phase] = timing
+}
+
+// Phases returns a copy of the recorded phase timings
+func (t *QueryTimings) Phases() map[string]PhaseTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	phases := make(map[string]PhaseTiming, len(t.phases))
+	for phase, timing := range t.phases {
+		phases[phase] = timing
+	}
+	return phases
+}
+
+// startTiming starts timing a phase and returns a function that records it.
+// It is a no-op when the context carries no QueryTimings.
+func startTiming(ctx context.Context, phase string) func() {
+	timings, ok := ctx.Value(queryTimingsKey{}).(*QueryTimings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		timings.Record(phase, time.Since(start))
+	}
+}
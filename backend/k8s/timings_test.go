@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryTimings(t *testing.T) {
+	ctx, timings := WithQueryTimings(context.Background())
+	timings.Record(TimingCPUUsage, 2*time.Millisecond)
+	timings.Record(TimingCPUUsage, 3*time.Millisecond)
+	timings.Record(TimingAnalysis, time.Millisecond)
+
+	stop := startTiming(ctx, TimingMemoryUsage)
+	time.Sleep(time.Millisecond)
+	stop()
+
+	// Without timings in the context nothing is recorded
+	startTiming(context.Background(), TimingRestartTrend)()
+
+	phases := timings.Phases()
+	if len(phases) != 3 {
+		t.Errorf("got %d phases, want 3: %v", len(phases), phases)
+	}
+	tests := []struct {
+		phase     string
+		wantCount int
+		minTotal  time.Duration
+	}{
+		{TimingCPUUsage, 2, 5 * time.Millisecond},
+		{TimingAnalysis, 1, time.Millisecond},
+		{TimingMemoryUsage, 1, time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phase, func(t *testing.T) {
+			timing := phases[tt.phase]
+			if timing.Count != tt.wantCount || timing.Total < tt.minTotal {
+				t.Errorf("timing = %+v, want count %d and total of at least %s", timing, tt.wantCount, tt.minTotal)
+			}
+		})
+	}
+
+	// Phases returns a copy
+	phases[TimingCPUUsage] = PhaseTiming{}
+	if timings.Phases()[TimingCPUUsage].Count != 2 {
+		t.Error("modifying the returned phases changed the recorded timings")
+	}
+}
@@ -0,0 +1,218 @@
+package k8s
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI is a CA with a server and a client certificate it signed, written as PEM files
+type testPKI struct {
+	caFile, certFile, keyFile string
+	pool                      *x509.CertPool
+	server                    tls.Certificate
+}
+
+// newTestPKI writes a CA certificate and a client certificate and key to a temporary directory, and
+// returns them with a server certificate for 127.0.0.1
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(CA) error = %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("ParseCertificate(CA) error = %v", err)
+	}
+
+	// issue returns the certificate and key PEM of a leaf signed by the CA
+	issue := func(serial int64, template *x509.Certificate) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey() error = %v", err)
+		}
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("CreateCertificate(%s) error = %v", template.Subject.CommonName, err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("MarshalECPrivateKey() error = %v", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	clientCert, clientKey := issue(2, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	serverCert, serverKey := issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	})
+	server, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatalf("X509KeyPair(server) error = %v", err)
+	}
+
+	pki := testPKI{
+		caFile:   filepath.Join(dir, "ca.crt"),
+		certFile: filepath.Join(dir, "client.crt"),
+		keyFile:  filepath.Join(dir, "client.key"),
+		pool:     x509.NewCertPool(),
+		server:   server,
+	}
+	pki.pool.AddCert(ca)
+	for path, data := range map[string][]byte{
+		pki.caFile:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pki.certFile: clientCert,
+		pki.keyFile:  clientKey,
+	} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("WriteFile(%s) error = %v", path, err)
+		}
+	}
+	return pki
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not-pem.crt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	missing := filepath.Join(dir, "missing.crt")
+
+	tests := []struct {
+		name                      string
+		caFile, certFile, keyFile string
+		insecureSkipVerify        bool
+		wantNil                   bool
+		wantRootCAs               bool
+		wantCertificates          int
+		wantErr                   bool
+	}{
+		{name: "nothing set", wantNil: true},
+		{name: "insecure skip verify only", insecureSkipVerify: true},
+		{name: "CA", caFile: pki.caFile, wantRootCAs: true},
+		{name: "client certificate", certFile: pki.certFile, keyFile: pki.keyFile, wantCertificates: 1},
+		{name: "CA and client certificate", caFile: pki.caFile, certFile: pki.certFile, keyFile: pki.keyFile, wantRootCAs: true, wantCertificates: 1},
+		{name: "missing CA file", caFile: missing, wantErr: true},
+		{name: "CA file without certificates", caFile: notPEM, wantErr: true},
+		{name: "certificate without key", certFile: pki.certFile, wantErr: true},
+		{name: "key without certificate", keyFile: pki.keyFile, wantErr: true},
+		{name: "missing key file", certFile: pki.certFile, keyFile: missing, wantErr: true},
+		{name: "missing certificate file", certFile: missing, keyFile: pki.keyFile, wantErr: true},
+		{name: "key of another certificate", certFile: pki.caFile, keyFile: pki.keyFile, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadTLSConfig(tt.caFile, tt.certFile, tt.keyFile, tt.insecureSkipVerify)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if config != nil {
+					t.Errorf("LoadTLSConfig() = %+v with an error, want nil", config)
+				}
+				return
+			}
+			if (config == nil) != tt.wantNil {
+				t.Fatalf("LoadTLSConfig() = %+v, want nil %v", config, tt.wantNil)
+			}
+			if tt.wantNil {
+				return
+			}
+			if config.InsecureSkipVerify != tt.insecureSkipVerify {
+				t.Errorf("InsecureSkipVerify = %v, want %v", config.InsecureSkipVerify, tt.insecureSkipVerify)
+			}
+			if (config.RootCAs != nil) != tt.wantRootCAs {
+				t.Errorf("RootCAs = %v, want set %v", config.RootCAs, tt.wantRootCAs)
+			}
+			if len(config.Certificates) != tt.wantCertificates {
+				t.Errorf("got %d client certificates, want %d", len(config.Certificates), tt.wantCertificates)
+			}
+			if config.MinVersion != tls.VersionTLS12 {
+				t.Errorf("MinVersion = %x, want TLS 1.2", config.MinVersion)
+			}
+		})
+	}
+}
+
+// TestLoadTLSConfigMutualTLS connects to a server requiring a client certificate signed by the CA
+func TestLoadTLSConfigMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "client" {
+			t.Errorf("peer certificates = %v, want the client certificate", r.TLS.PeerCertificates)
+		}
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientCAs:    pki.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name              string
+		certFile, keyFile string
+		wantErr           bool
+	}{
+		{"client certificate", pki.certFile, pki.keyFile, false},
+		{"no client certificate", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadTLSConfig(pki.caFile, tt.certFile, tt.keyFile, false)
+			if err != nil {
+				t.Fatalf("LoadTLSConfig() error = %v", err)
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GET error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -17,10 +18,13 @@ import (
 type VictoriaMetricsClient struct {
 	baseURL string
 	client  *http.Client
+	config  MetricsClientConfig
 }
 
 // NewVictoriaMetricsClient creates a new VictoriaMetrics client
-func NewVictoriaMetricsClient(vmSelectURL string) (*VictoriaMetricsClient, error) {
+func NewVictoriaMetricsClient(config MetricsClientConfig) (*VictoriaMetricsClient, error) {
+	vmSelectURL := config.URL
+	
 	// Ensure the URL ends with the API path
 	if !strings.HasSuffix(vmSelectURL, "/") {
 		vmSelectURL += "/"
@@ -29,11 +33,19 @@ func NewVictoriaMetricsClient(vmSelectURL string) (*VictoriaMetricsClient, error
 	return &VictoriaMetricsClient{
 		baseURL: vmSelectURL,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: newTransport(config),
 		},
+		config: config,
 	}, nil
 }
 
+// Ping runs a cheap instant query to check VictoriaMetrics is reachable
+func (vm *VictoriaMetricsClient) Ping(ctx context.Context) error {
+	_, err := vm.query(ctx, "vector(1)")
+	return err
+}
+
 // Close closes the VictoriaMetrics client connection
 func (vm *VictoriaMetricsClient) Close() error {
 	// HTTP client doesn't require explicit closing
@@ -47,8 +59,51 @@ func (vm *VictoriaMetricsClient) GetClientType() string {
 
 // VMResponse represents VictoriaMetrics API response structure
 type VMResponse struct {
-	Status string `json:"status"`
-	Data   VMData `json:"data"`
+	Status    string   `json:"status"`
+	Data      VMData   `json:"data"`
+	Warnings  []string `json:"warnings,omitempty"`
+	IsPartial bool     `json:"isPartial,omitempty"` // Set by vmselect when some storage nodes were unavailable
+}
+
+// vmNumber parses a sample timestamp or value, which the Prometheus API encodes as a string
+// (values) or number (timestamps) but some compatible servers and encoders emit either way
+func vmNumber(v interface{}) (float64, bool) {
+	var value float64
+	var err error
+	switch n := v.(type) {
+	case string:
+		value, err = strconv.ParseFloat(n, 64)
+	case float64:
+		value = n
+	case json.Number:
+		value, err = n.Float64()
+	default:
+		return 0, false
+	}
+	// NaN and ±Inf, e.g. from divisions by zero, are dropped like unparseable values
+	return value, err == nil && isFinite(value)
+}
+
+// vmSampleValue returns the value of an instant query [timestamp, value] pair
+func vmSampleValue(pair []interface{}) (float64, bool) {
+	if len(pair) < 2 {
+		return 0, false
+	}
+	return vmNumber(pair[1])
+}
+
+// recordVMWarnings records the warnings and partial-result flag of a VictoriaMetrics response
+func recordVMWarnings(ctx context.Context, resp *VMResponse) {
+	recordWarnings(ctx, resp.Warnings)
+	if resp.IsPartial {
+		recordWarnings(ctx, []string{"VictoriaMetrics returned a partial response - some storage nodes were unavailable"})
+	}
+}
+
+// VMLabelsResponse represents a VictoriaMetrics label names or label values response
+type VMLabelsResponse struct {
+	Status string   `json:"status"`
+	Data   []string `json:"data"`
 }
 
 // VMData represents the data section of VM response
@@ -65,39 +120,33 @@ type VMResult struct {
 }
 
 // GetCurrentPodMetrics retrieves current pod metrics from VictoriaMetrics
-func (vm *VictoriaMetricsClient) GetCurrentPodMetrics(ctx context.Context, namespace string) ([]PodMetric, error) {
+func (vm *VictoriaMetricsClient) GetCurrentPodMetrics(ctx context.Context, namespace, selector string, at time.Time) ([]PodMetric, error) {
+	return vm.GetNodePodMetrics(ctx, namespace, "", selector, at)
+}
+
+// GetNodePodMetrics retrieves the current metrics of the pods scheduled on node from VictoriaMetrics, of all
+// nodes when empty. The usage queries are joined with the kube-state-metrics pod to node mapping.
+func (vm *VictoriaMetricsClient) GetNodePodMetrics(ctx context.Context, namespace, node, selector string, at time.Time) ([]PodMetric, error) {
 	var pods []PodMetric
 	
-	// Build namespace filter
-	namespaceFilter := ""
-	if namespace != "" {
-		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
-	}
+	queries := currentQueries(vm.config, namespace, node, selector)
 	
 	// Get current CPU usage
-	cpuQuery := `rate(container_cpu_usage_seconds_total{container!="POD", container!=""`
-	if namespaceFilter != "" {
-		cpuQuery += "," + namespaceFilter
-	}
-	cpuQuery += `}[5m])`
+	cpuQuery := queries.cpuUsage
 	
 	log.Printf("DEBUG: Executing CPU query: %s", cpuQuery)
 	
-	cpuResult, err := vm.query(ctx, cpuQuery)
+	cpuResult, err := vm.queryAt(ctx, cpuQuery, at)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query CPU usage: %w", err)
 	}
 	
 	// Get current Memory usage
-	memQuery := `container_memory_working_set_bytes{container!="POD", container!=""`
-	if namespaceFilter != "" {
-		memQuery += "," + namespaceFilter
-	}
-	memQuery += `}`
+	memQuery := queries.memoryUsage
 	
 	log.Printf("DEBUG: Executing Memory query: %s", memQuery)
 	
-	memResult, err := vm.query(ctx, memQuery)
+	memResult, err := vm.queryAt(ctx, memQuery, at)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query memory usage: %w", err)
 	}
@@ -105,7 +154,8 @@ func (vm *VictoriaMetricsClient) GetCurrentPodMetrics(ctx context.Context, names
 	// Create a map to group metrics by pod/container
 	podMetrics := make(map[string]*PodMetric)
 	
-	// Process CPU usage
+	// Process CPU usage, merging duplicate series
+	cpuMerger := newSeriesMerger(vm.config.DuplicateAggregation)
 	for _, result := range cpuResult.Data.Result {
 		key := fmt.Sprintf("%s/%s/%s",
 			result.Metric["namespace"],
@@ -121,16 +171,13 @@ func (vm *VictoriaMetricsClient) GetCurrentPodMetrics(ctx context.Context, names
 			}
 		}
 		
-		if len(result.Value) >= 2 {
-			if val, ok := result.Value[1].(string); ok {
-				if cpuUsage, err := strconv.ParseFloat(val, 64); err == nil {
-					podMetrics[key].CPUUsage = cpuUsage
-				}
-			}
+		if cpuUsage, ok := vmSampleValue(result.Value); ok {
+			cpuMerger.merge(key, &podMetrics[key].CPUUsage, cpuUsage)
 		}
 	}
 	
-	// Process Memory usage
+	// Process Memory usage, merging duplicate series
+	memMerger := newSeriesMerger(vm.config.DuplicateAggregation)
 	for _, result := range memResult.Data.Result {
 		key := fmt.Sprintf("%s/%s/%s",
 			result.Metric["namespace"],
@@ -146,51 +193,93 @@ func (vm *VictoriaMetricsClient) GetCurrentPodMetrics(ctx context.Context, names
 			}
 		}
 		
-		if len(result.Value) >= 2 {
-			if val, ok := result.Value[1].(string); ok {
-				if memUsage, err := strconv.ParseFloat(val, 64); err == nil {
-					podMetrics[key].MemoryUsage = memUsage
-					log.Printf("DEBUG: Raw memory for %s: %.0f bytes (%.2f Mi)",
-						key, memUsage, memUsage/(1024*1024))
+		if memUsage, ok := vmSampleValue(result.Value); ok {
+			memMerger.merge(key, &podMetrics[key].MemoryUsage, memUsage)
+			log.Printf("DEBUG: Raw memory for %s: %.0f bytes (%.2f Mi)",
+				key, memUsage, memUsage/(1024*1024))
+		}
+	}
+	
+	// Get the timestamp of the newest memory sample per container
+	tsResult, err := vm.queryAt(ctx, queries.sampleTime, at)
+	if err != nil {
+		log.Printf("Warning: failed to query sample timestamps: %v", err)
+	} else {
+		for _, result := range tsResult.Data.Result {
+			key := fmt.Sprintf("%s/%s/%s",
+				result.Metric["namespace"],
+				result.Metric["pod"],
+				result.Metric["container"])
+			
+			if metric, exists := podMetrics[key]; exists {
+				// Keep the newest timestamp across duplicate series
+				if ts, ok := vmSampleValue(result.Value); ok && time.Unix(int64(ts), 0).After(metric.SampleTime) {
+					metric.SampleTime = time.Unix(int64(ts), 0)
 				}
 			}
 		}
 	}
 	
-	// Get resource requests and limits
-	err = vm.addResourceLimitsAndRequests(ctx, podMetrics, namespace)
+	// Get resource requests and limits, which are left empty without kube-state-metrics
+	err = vm.addResourceLimitsAndRequests(ctx, podMetrics, namespace, at)
 	if err != nil {
 		log.Printf("Warning: failed to get resource requests/limits: %v", err)
+		recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+	}
+	
+	// Classify pods by QoS class, unknown without requests and limits
+	if err == nil {
+		setQoSClasses(podMetrics)
+	}
+	
+	// Get container restarts and OOMKills
+	if vm.config.EnableContainerStatus {
+		if err := vm.addContainerStatus(ctx, podMetrics, namespace, at); err != nil {
+			log.Printf("Warning: failed to get container status: %v", err)
+		}
+	}
+	
+	// Get pod start times to report pod age
+	startTimes, err := vm.getPodStartTimes(ctx, namespace, at)
+	if err != nil {
+		log.Printf("Warning: failed to get pod start times: %v", err)
+	}
+	setPodStartTimes(podMetrics, startTimes)
+	
+	// Get the Kubernetes pod labels
+	podLabels, err := vm.GetPodLabels(ctx, namespace, at)
+	if err != nil {
+		log.Printf("Warning: failed to get pod labels: %v", err)
 	}
+	setPodLabels(podMetrics, podLabels)
 	
-	// Convert map to slice
+	// Convert map to slice, in a stable order, with the unit of the CPU usage
 	for _, metric := range podMetrics {
+		metric.CPUUnit = vm.config.Queries.CPUUnit
 		pods = append(pods, *metric)
 	}
+	sortPodMetrics(pods)
 	
 	return pods, nil
 }
 
 // addResourceLimitsAndRequests adds resource requests and limits to pod metrics
-func (vm *VictoriaMetricsClient) addResourceLimitsAndRequests(ctx context.Context, podMetrics map[string]*PodMetric, namespace string) error {
-	// Build namespace filter
-	namespaceFilter := ""
-	if namespace != "" {
-		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
-	}
+func (vm *VictoriaMetricsClient) addResourceLimitsAndRequests(ctx context.Context, podMetrics map[string]*PodMetric, namespace string, at time.Time) error {
+	queries := currentQueries(vm.config, namespace, "", "")
+	
+	// Count request and limit series to detect a missing kube-state-metrics
+	seriesFound := 0
 	
 	// Get CPU requests
-	cpuReqQuery := `kube_pod_container_resource_requests{resource="cpu"`
-	if namespaceFilter != "" {
-		cpuReqQuery += "," + namespaceFilter
-	}
-	cpuReqQuery += `}`
+	cpuReqQuery := queries.cpuRequests
 	
-	cpuReqResult, err := vm.query(ctx, cpuReqQuery)
+	cpuReqResult, err := vm.queryAt(ctx, cpuReqQuery, at)
 	if err != nil {
 		return fmt.Errorf("failed to query CPU requests: %w", err)
 	}
+	seriesFound += len(cpuReqResult.Data.Result)
 	
+	cpuReqMerger := newSeriesMerger(vm.config.DuplicateAggregation)
 	for _, result := range cpuReqResult.Data.Result {
 		key := fmt.Sprintf("%s/%s/%s",
 			result.Metric["namespace"],
@@ -198,28 +287,22 @@ func (vm *VictoriaMetricsClient) addResourceLimitsAndRequests(ctx context.Contex
 			result.Metric["container"])
 		
 		if metric, exists := podMetrics[key]; exists {
-			if len(result.Value) >= 2 {
-				if val, ok := result.Value[1].(string); ok {
-					if cpuReq, err := strconv.ParseFloat(val, 64); err == nil {
-						metric.CPURequest = cpuReq
-					}
-				}
+			if cpuReq, ok := vmSampleValue(result.Value); ok {
+				cpuReqMerger.merge(key, &metric.CPURequest, cpuReq)
 			}
 		}
 	}
 	
 	// Get CPU limits
-	cpuLimitQuery := `kube_pod_container_resource_limits{resource="cpu"`
-	if namespaceFilter != "" {
-		cpuLimitQuery += "," + namespaceFilter
-	}
-	cpuLimitQuery += `}`
+	cpuLimitQuery := queries.cpuLimits
 	
-	cpuLimitResult, err := vm.query(ctx, cpuLimitQuery)
+	cpuLimitResult, err := vm.queryAt(ctx, cpuLimitQuery, at)
 	if err != nil {
 		return fmt.Errorf("failed to query CPU limits: %w", err)
 	}
+	seriesFound += len(cpuLimitResult.Data.Result)
 	
+	cpuLimitMerger := newSeriesMerger(vm.config.DuplicateAggregation)
 	for _, result := range cpuLimitResult.Data.Result {
 		key := fmt.Sprintf("%s/%s/%s",
 			result.Metric["namespace"],
@@ -227,28 +310,22 @@ func (vm *VictoriaMetricsClient) addResourceLimitsAndRequests(ctx context.Contex
 			result.Metric["container"])
 		
 		if metric, exists := podMetrics[key]; exists {
-			if len(result.Value) >= 2 {
-				if val, ok := result.Value[1].(string); ok {
-					if cpuLimit, err := strconv.ParseFloat(val, 64); err == nil {
-						metric.CPULimit = cpuLimit
-					}
-				}
+			if cpuLimit, ok := vmSampleValue(result.Value); ok {
+				cpuLimitMerger.merge(key, &metric.CPULimit, cpuLimit)
 			}
 		}
 	}
 	
 	// Get Memory requests
-	memReqQuery := `kube_pod_container_resource_requests{resource="memory"`
-	if namespaceFilter != "" {
-		memReqQuery += "," + namespaceFilter
-	}
-	memReqQuery += `}`
+	memReqQuery := queries.memoryRequests
 	
-	memReqResult, err := vm.query(ctx, memReqQuery)
+	memReqResult, err := vm.queryAt(ctx, memReqQuery, at)
 	if err != nil {
 		return fmt.Errorf("failed to query memory requests: %w", err)
 	}
+	seriesFound += len(memReqResult.Data.Result)
 	
+	memReqMerger := newSeriesMerger(vm.config.DuplicateAggregation)
 	for _, result := range memReqResult.Data.Result {
 		key := fmt.Sprintf("%s/%s/%s",
 			result.Metric["namespace"],
@@ -256,28 +333,22 @@ func (vm *VictoriaMetricsClient) addResourceLimitsAndRequests(ctx context.Contex
 			result.Metric["container"])
 		
 		if metric, exists := podMetrics[key]; exists {
-			if len(result.Value) >= 2 {
-				if val, ok := result.Value[1].(string); ok {
-					if memReq, err := strconv.ParseFloat(val, 64); err == nil {
-						metric.MemoryRequest = memReq
-					}
-				}
+			if memReq, ok := vmSampleValue(result.Value); ok {
+				memReqMerger.merge(key, &metric.MemoryRequest, memReq)
 			}
 		}
 	}
 	
 	// Get Memory limits
-	memLimitQuery := `kube_pod_container_resource_limits{resource="memory"`
-	if namespaceFilter != "" {
-		memLimitQuery += "," + namespaceFilter
-	}
-	memLimitQuery += `}`
+	memLimitQuery := queries.memoryLimits
 	
-	memLimitResult, err := vm.query(ctx, memLimitQuery)
+	memLimitResult, err := vm.queryAt(ctx, memLimitQuery, at)
 	if err != nil {
 		return fmt.Errorf("failed to query memory limits: %w", err)
 	}
+	seriesFound += len(memLimitResult.Data.Result)
 	
+	memLimitMerger := newSeriesMerger(vm.config.DuplicateAggregation)
 	for _, result := range memLimitResult.Data.Result {
 		key := fmt.Sprintf("%s/%s/%s",
 			result.Metric["namespace"],
@@ -285,51 +356,154 @@ func (vm *VictoriaMetricsClient) addResourceLimitsAndRequests(ctx context.Contex
 			result.Metric["container"])
 		
 		if metric, exists := podMetrics[key]; exists {
-			if len(result.Value) >= 2 {
-				if val, ok := result.Value[1].(string); ok {
-					if memLimit, err := strconv.ParseFloat(val, 64); err == nil {
-						metric.MemoryLimit = memLimit
-					}
-				}
+			if memLimit, ok := vmSampleValue(result.Value); ok {
+				memLimitMerger.merge(key, &metric.MemoryLimit, memLimit)
 			}
 		}
 	}
 	
+	if seriesFound == 0 && len(podMetrics) > 0 {
+		return errKubeStateMetricsMissing
+	}
+	
 	return nil
 }
 
-// GetHistoricalMetrics retrieves and analyzes 7-day historical metrics for pods
-func (vm *VictoriaMetricsClient) GetHistoricalMetrics(ctx context.Context, namespace string) ([]HistoricalMetrics, error) {
-	now := time.Now()
-	sevenDaysAgo := now.Add(-7 * 24 * time.Hour)
+// addContainerStatus adds restart counts and OOMKill status to pod metrics
+func (vm *VictoriaMetricsClient) addContainerStatus(ctx context.Context, podMetrics map[string]*PodMetric, namespace string, at time.Time) error {
+	queries := currentQueries(vm.config, namespace, "", "")
+	
+	// Get container restarts
+	restartsQuery := queries.restarts
 	
-	// Get pod list from the last 7 days
-	pods, err := vm.getActivePods(ctx, namespace, sevenDaysAgo, now)
+	restartsResult, err := vm.queryAt(ctx, restartsQuery, at)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get active pods: %w", err)
+		return fmt.Errorf("failed to query container restarts: %w", err)
 	}
+	
+	for _, result := range restartsResult.Data.Result {
+		key := fmt.Sprintf("%s/%s/%s",
+			result.Metric["namespace"],
+			result.Metric["pod"],
+			result.Metric["container"])
+		
+		if metric, exists := podMetrics[key]; exists {
+			if restarts, ok := vmSampleValue(result.Value); ok {
+				metric.RestartCount = int(restarts)
+			}
+		}
+	}
+	
+	// Get OOMKilled terminations
+	oomQuery := queries.oomKilled
+	
+	oomResult, err := vm.queryAt(ctx, oomQuery, at)
+	if err != nil {
+		return fmt.Errorf("failed to query OOMKilled containers: %w", err)
+	}
+	
+	for _, result := range oomResult.Data.Result {
+		key := fmt.Sprintf("%s/%s/%s",
+			result.Metric["namespace"],
+			result.Metric["pod"],
+			result.Metric["container"])
+		
+		if metric, exists := podMetrics[key]; exists {
+			if oom, ok := vmSampleValue(result.Value); ok && oom > 0 {
+				metric.OOMKilled = true
+			}
+		}
+	}
+	
+	return nil
+}
 
+// GetHistoricalMetrics retrieves and analyzes historical metrics for pods over the analysis window (7 days by default)
+func (vm *VictoriaMetricsClient) GetHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions) ([]HistoricalMetrics, error) {
 	var results []HistoricalMetrics
+	err := vm.StreamHistoricalMetrics(ctx, namespace, opts, func(metrics HistoricalMetrics) error {
+		results = append(results, metrics)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return results, nil
+}
+
+// StreamHistoricalMetrics analyzes historical metrics over the analysis window and passes each container result to fn as soon as it is computed
+func (vm *VictoriaMetricsClient) StreamHistoricalMetrics(ctx context.Context, namespace string, opts HistoricalOptions, fn func(HistoricalMetrics) error) error {
+	now := time.Now()
+	windowStart := now.Add(-analysisWindow(opts.Window))
+	
+	// Get pod list from the analysis window
+	stop := startTiming(ctx, TimingActivePods)
+	pods, err := vm.getActivePods(ctx, namespace, windowStart, now)
+	stop()
+	if err != nil {
+		return fmt.Errorf("failed to get active pods: %w", err)
+	}
+	
+	// Add pods that completed during the window, e.g. finished Jobs, when requested
+	if opts.IncludeCompleted {
+		stop = startTiming(ctx, TimingCompletedPods)
+		completed, err := vm.getCompletedPods(ctx, namespace, windowStart, now)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to get completed pods: %v", err)
+			recordWarnings(ctx, []string{WarningKubeStateMetricsMissing})
+		} else {
+			pods = mergeCompletedPods(pods, completed)
+		}
+	}
+
+	// Bulk-export the raw samples of the whole namespace instead of per-container range queries
+	if vm.config.VMUseExport {
+		stop = startTiming(ctx, TimingExport)
+		data, err := vm.exportNamespace(ctx, namespace, windowStart, now)
+		stop()
+		if err != nil {
+			log.Printf("Warning: VictoriaMetrics export failed, falling back to query_range: %v", err)
+		} else {
+			ctx = context.WithValue(ctx, vmExportKey{}, data)
+		}
+	}
+	
+	// Get pod start times to flag pods younger than the window
+	startTimes, err := vm.getPodStartTimes(ctx, namespace, now)
+	if err != nil {
+		log.Printf("Warning: failed to get pod start times: %v", err)
+	}
+
 	for _, pod := range pods {
 		for _, container := range pod.Containers {
-			metrics, err := vm.getHistoricalMetricsForContainer(ctx, pod.Name, pod.Namespace, container, sevenDaysAgo, now)
+			metrics, err := vm.getHistoricalMetricsForContainer(ctx, pod.Name, pod.Namespace, container, windowStart, now)
 			if err != nil {
 				log.Printf("Warning: failed to get metrics for pod %s/%s container %s: %v", 
 					pod.Namespace, pod.Name, container, err)
 				continue
 			}
-			results = append(results, metrics)
+			metrics.Completed = pod.Completed
+			setHistoryCoverage(&metrics, startTimes[pod.Namespace+"/"+pod.Name], windowStart)
+			if err := fn(metrics); err != nil {
+				return err
+			}
 		}
 	}
 
-	return results, nil
+	return nil
+}
+
+// GetActivePods retrieves the pods that were active during the analysis window (7 days by default)
+func (vm *VictoriaMetricsClient) GetActivePods(ctx context.Context, namespace string, window time.Duration) ([]PodInfo, error) {
+	now := time.Now()
+	return vm.getActivePods(ctx, namespace, now.Add(-analysisWindow(window)), now)
 }
 
 // getActivePods retrieves pods that were active during the specified time range
 func (vm *VictoriaMetricsClient) getActivePods(ctx context.Context, namespace string, start, end time.Time) ([]PodInfo, error) {
-	query := `group by (pod, namespace, container) (
-		rate(container_cpu_usage_seconds_total{namespace=~"` + namespace + `", container!="POD", container!=""}[5m])
-	)`
+	query := activePodsQuery(vm.config, namespace)
 	
 	result, err := vm.query(ctx, query)
 	if err != nil {
@@ -343,11 +517,6 @@ func (vm *VictoriaMetricsClient) getActivePods(ctx context.Context, namespace st
 		ns := vmResult.Metric["namespace"]
 		container := vmResult.Metric["container"]
 		
-		// Filter by namespace if specified
-		if namespace != "" && ns != namespace {
-			continue
-		}
-		
 		key := ns + "/" + pod
 		if existing, exists := podMap[key]; exists {
 			// Add container to existing pod
@@ -370,65 +539,157 @@ func (vm *VictoriaMetricsClient) getActivePods(ctx context.Context, namespace st
 	return pods, nil
 }
 
+// getCompletedPods retrieves pods that completed during the specified time range
+func (vm *VictoriaMetricsClient) getCompletedPods(ctx context.Context, namespace string, start, end time.Time) ([]PodInfo, error) {
+	result, err := vm.queryAt(ctx, completedPodsQuery(vm.config.Queries, namespace, start, end), end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completed pods: %w", err)
+	}
+	
+	var refs []containerRef
+	for _, vmResult := range result.Data.Result {
+		refs = append(refs, containerRef{
+			namespace: vmResult.Metric["namespace"],
+			pod:       vmResult.Metric["pod"],
+			container: vmResult.Metric["container"],
+		})
+	}
+	
+	return groupCompletedPods(refs), nil
+}
+
+// getPodStartTimes retrieves the start time of each pod in namespace, keyed by namespace/pod
+func (vm *VictoriaMetricsClient) getPodStartTimes(ctx context.Context, namespace string, at time.Time) (map[string]time.Time, error) {
+	result, err := vm.queryAt(ctx, podStartTimeQuery(vm.config.Queries, namespace), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod start times: %w", err)
+	}
+	
+	startTimes := make(map[string]time.Time)
+	for _, vmResult := range result.Data.Result {
+		if started, ok := vmSampleValue(vmResult.Value); ok {
+			key := vmResult.Metric["namespace"] + "/" + vmResult.Metric["pod"]
+			startTimes[key] = time.Unix(int64(started), 0)
+		}
+	}
+	
+	return startTimes, nil
+}
+
+// GetContainerSeries retrieves the raw datapoints of a single container metric
+func (vm *VictoriaMetricsClient) GetContainerSeries(ctx context.Context, namespace, pod, container, metric string, start, end time.Time) ([]DataPoint, error) {
+	query, err := seriesQuery(vm.config.Queries, vm.config.ScrapeInterval, metric, namespace, pod, container)
+	if err != nil {
+		return nil, err
+	}
+	points, err := vm.queryRangeMetric(ctx, query, start, end)
+	if err != nil || metric != SeriesCPU {
+		return points, err
+	}
+	return vm.config.Queries.CPUUnit.pointsToCores(points), nil
+}
+
+// PreviewQueries lists the PromQL queries of current and historical metrics without running them
+func (vm *VictoriaMetricsClient) PreviewQueries(namespace, pod, container string) QueryPreview {
+	return previewQueries(vm.config, namespace, pod, container)
+}
+
 // getHistoricalMetricsForContainer retrieves and analyzes historical metrics for a specific container
 func (vm *VictoriaMetricsClient) getHistoricalMetricsForContainer(ctx context.Context, pod, namespace, container string, start, end time.Time) (HistoricalMetrics, error) {
+	step, coarsened := rangeStep(start, end, vm.config.MaxSamplesPerSeries)
+	queries := historicalQueries(vm.config, namespace, pod, container, start, end)
+
 	// Query CPU usage over time
-	cpuUsage, err := vm.queryRangeMetric(ctx, 
-		fmt.Sprintf(`rate(container_cpu_usage_seconds_total{namespace="%s", pod="%s", container="%s"}[5m])`, 
-			namespace, pod, container), start, end)
+	stop := startTiming(ctx, TimingCPUUsage)
+	cpuUsage, err := vm.rangeSeries(ctx, TimingCPUUsage,
+		queries.cpuUsage, namespace, pod, container, start, end)
+	stop()
 	if err != nil {
 		return HistoricalMetrics{}, fmt.Errorf("failed to query CPU usage: %w", err)
 	}
 
 	// Query Memory usage over time
-	memUsage, err := vm.queryRangeMetric(ctx,
-		fmt.Sprintf(`container_memory_working_set_bytes{namespace="%s", pod="%s", container="%s"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingMemoryUsage)
+	memUsage, err := vm.rangeSeries(ctx, TimingMemoryUsage,
+		queries.memoryUsage, namespace, pod, container, start, end)
+	stop()
 	if err != nil {
 		return HistoricalMetrics{}, fmt.Errorf("failed to query memory usage: %w", err)
 	}
 
 	// Query CPU requests
-	cpuRequests, err := vm.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_requests{namespace="%s", pod="%s", container="%s", resource="cpu"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingCPURequests)
+	cpuRequests, err := vm.rangeSeries(ctx, TimingCPURequests,
+		queries.cpuRequests, namespace, pod, container, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query CPU requests for %s/%s/%s: %v", namespace, pod, container, err)
 		cpuRequests = []DataPoint{} // Continue without requests data
 	}
 
 	// Query Memory requests
-	memRequests, err := vm.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_requests{namespace="%s", pod="%s", container="%s", resource="memory"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingMemoryRequests)
+	memRequests, err := vm.rangeSeries(ctx, TimingMemoryRequests,
+		queries.memoryRequests, namespace, pod, container, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query memory requests for %s/%s/%s: %v", namespace, pod, container, err)
 		memRequests = []DataPoint{} // Continue without requests data
 	}
 
 	// Query CPU limits
-	cpuLimits, err := vm.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_limits{namespace="%s", pod="%s", container="%s", resource="cpu"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingCPULimits)
+	cpuLimits, err := vm.rangeSeries(ctx, TimingCPULimits,
+		queries.cpuLimits, namespace, pod, container, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query CPU limits for %s/%s/%s: %v", namespace, pod, container, err)
 		cpuLimits = []DataPoint{} // Continue without limits data
 	}
 
 	// Query Memory limits
-	memLimits, err := vm.queryRangeMetric(ctx,
-		fmt.Sprintf(`kube_pod_container_resource_limits{namespace="%s", pod="%s", container="%s", resource="memory"}`, 
-			namespace, pod, container), start, end)
+	stop = startTiming(ctx, TimingMemoryLimits)
+	memLimits, err := vm.rangeSeries(ctx, TimingMemoryLimits,
+		queries.memoryLimits, namespace, pod, container, start, end)
+	stop()
 	if err != nil {
 		log.Printf("Warning: failed to query memory limits for %s/%s/%s: %v", namespace, pod, container, err)
 		memLimits = []DataPoint{} // Continue without limits data
 	}
 
+	// Query container restarts and OOMKills
+	var restarts int
+	var oomKilled bool
+	if vm.config.EnableContainerStatus {
+		stop = startTiming(ctx, TimingContainerStatus)
+		restarts, oomKilled, err = vm.getContainerStatus(ctx, pod, namespace, container, start, end)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to query container status for %s/%s/%s: %v", namespace, pod, container, err)
+		}
+	}
+
+	// Query the hourly restart rate to detect crash loops building up over the window
+	var restartTrend string
+	if vm.config.EnableRestartTrend {
+		stop = startTiming(ctx, TimingRestartTrend)
+		restartRate, err := vm.queryRangeMetric(ctx, queries.restartRate, start, end)
+		stop()
+		if err != nil {
+			log.Printf("Warning: failed to query restart rate for %s/%s/%s: %v", namespace, pod, container, err)
+		} else {
+			restartTrend = calculateTrend(vm.config, restartRate, DefaultTrendThreshold)
+		}
+	}
+
 	// Analyze the data (reuse existing analysis functions)
-	cpuData := vm.analyzeResourceData(cpuUsage, cpuRequests, cpuLimits)
-	memData := vm.analyzeResourceData(memUsage, memRequests, memLimits)
-	
-	analysis := vm.generateUsageAnalysis(cpuData, memData)
+	stop = startTiming(ctx, TimingAnalysis)
+	cpuData := analyzeResourceData(vm.config, cpuUsage, cpuRequests, cpuLimits, vm.config.CPUTrendThreshold)
+	memData := analyzeResourceData(vm.config, memUsage, memRequests, memLimits, vm.config.MemoryTrendThreshold)
+	analysis := generateUsageAnalysis(vm.config, cpuData, memData, oomKilled, restartTrend)
+	stop()
+	cpuData.DataCompleteness = dataCompleteness(len(cpuUsage), start, end, step)
+	memData.DataCompleteness = dataCompleteness(len(memUsage), start, end, step)
 
 	return HistoricalMetrics{
 		PodName:       pod,
@@ -437,13 +698,35 @@ func (vm *VictoriaMetricsClient) getHistoricalMetricsForContainer(ctx context.Co
 		CPU:           cpuData,
 		Memory:        memData,
 		Analysis:      analysis,
+		RestartCount:  restarts,
+		OOMKilled:     oomKilled,
+		RestartTrend:  restartTrend,
+		Step:          step,
+		StepCoarsened: coarsened,
 	}, nil
 }
 
+// getContainerStatus retrieves restarts and OOMKill status for a container over the specified time range
+func (vm *VictoriaMetricsClient) getContainerStatus(ctx context.Context, pod, namespace, container string, start, end time.Time) (int, bool, error) {
+	queries := historicalQueries(vm.config, namespace, pod, container, start, end)
+	
+	restarts, err := vm.queryScalar(ctx, queries.restarts)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query restarts: %w", err)
+	}
+	
+	oomKilled, err := vm.queryScalar(ctx, queries.oomKilled)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query OOMKills: %w", err)
+	}
+	
+	return int(math.Round(restarts)), oomKilled > 0, nil
+}
+
 // GetNamespaces retrieves all namespaces from VictoriaMetrics
 func (vm *VictoriaMetricsClient) GetNamespaces(ctx context.Context) ([]string, error) {
 	// Use container metrics to get namespaces since we don't have kube-state-metrics
-	query := `group by (namespace) (container_cpu_usage_seconds_total{container!="POD", container!=""})`
+	query := `group by (namespace) (` + vm.config.Queries.Selector(QueryCPUUsage, vm.config.Queries.BaseContainerFilter()) + `)`
 	
 	result, err := vm.query(ctx, query)
 	if err != nil {
@@ -464,11 +747,157 @@ func (vm *VictoriaMetricsClient) GetNamespaces(ctx context.Context) ([]string, e
 	return namespaces, nil
 }
 
+// GetPodStatus retrieves the phase and last termination reasons of a pod from kube-state-metrics
+func (vm *VictoriaMetricsClient) GetPodStatus(ctx context.Context, namespace, pod string) (PodStatus, error) {
+	status := PodStatus{LastTerminationReasons: make(map[string]string)}
+	podFilter := fmt.Sprintf(`namespace="%s", pod="%s"`, namespace, pod)
+	
+	// Get the current phase (the series for the active phase has value 1)
+	phaseResult, err := vm.query(ctx, vm.config.Queries.Selector(QueryPodPhase, podFilter)+` == 1`)
+	if err != nil {
+		return status, fmt.Errorf("failed to query pod phase: %w", err)
+	}
+	
+	for _, result := range phaseResult.Data.Result {
+		status.Phase = result.Metric["phase"]
+	}
+	
+	// Get the last termination reason per container
+	reasonResult, err := vm.query(ctx, vm.config.Queries.Selector(QueryTerminatedReason, podFilter)+` > 0`)
+	if err != nil {
+		return status, fmt.Errorf("failed to query last terminated reason: %w", err)
+	}
+	
+	for _, result := range reasonResult.Data.Result {
+		status.LastTerminationReasons[result.Metric["container"]] = result.Metric["reason"]
+	}
+	
+	return status, nil
+}
+
+// GetNodeAllocatable retrieves allocatable CPU and memory per node from kube-state-metrics
+func (vm *VictoriaMetricsClient) GetNodeAllocatable(ctx context.Context) ([]NodeAllocatable, error) {
+	nodes := make(map[string]*NodeAllocatable)
+	
+	for _, resource := range []string{"cpu", "memory"} {
+		query := vm.config.Queries.Selector(QueryNodeAllocatable, fmt.Sprintf(`resource="%s"`, resource))
+		
+		result, err := vm.query(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query node allocatable %s: %w", resource, err)
+		}
+		
+		for _, vmResult := range result.Data.Result {
+			node := vmResult.Metric["node"]
+			value, ok := vmSampleValue(vmResult.Value)
+			if !ok {
+				continue
+			}
+			
+			if _, exists := nodes[node]; !exists {
+				nodes[node] = &NodeAllocatable{Node: node}
+			}
+			if resource == "cpu" {
+				nodes[node].CPU = value
+			} else {
+				nodes[node].Memory = value
+			}
+		}
+	}
+	
+	var allocatable []NodeAllocatable
+	for _, node := range nodes {
+		allocatable = append(allocatable, *node)
+	}
+	
+	return allocatable, nil
+}
+
+// GetPodNodes retrieves the node each pod is scheduled on from kube-state-metrics
+func (vm *VictoriaMetricsClient) GetPodNodes(ctx context.Context, namespace string, at time.Time) (map[string]string, error) {
+	result, err := vm.queryAt(ctx, podNodesQuery(vm.config.Queries, namespace), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod nodes: %w", err)
+	}
+	
+	podNodes := make(map[string]string)
+	for _, vmResult := range result.Data.Result {
+		podNodes[vmResult.Metric["namespace"]+"/"+vmResult.Metric["pod"]] = vmResult.Metric["node"]
+	}
+	
+	return podNodes, nil
+}
+
+// GetPodLabels retrieves the Kubernetes labels of each pod from kube-state-metrics
+func (vm *VictoriaMetricsClient) GetPodLabels(ctx context.Context, namespace string, at time.Time) (map[string]map[string]string, error) {
+	result, err := vm.queryAt(ctx, podLabelsQuery(vm.config.Queries, namespace), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod labels: %w", err)
+	}
+	
+	podLabels := make(map[string]map[string]string)
+	for _, vmResult := range result.Data.Result {
+		addPodLabels(podLabels, vmResult.Metric)
+	}
+	
+	return podLabels, nil
+}
+
+// GetPodOwners retrieves the workload owning each pod active during the analysis window from kube-state-metrics
+func (vm *VictoriaMetricsClient) GetPodOwners(ctx context.Context, namespace string, window time.Duration) (map[string]string, error) {
+	result, err := vm.query(ctx, podOwnerQuery(vm.config.Queries, namespace, analysisWindow(window)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pod owners: %w", err)
+	}
+	
+	owners := make(map[string]string)
+	for _, vmResult := range result.Data.Result {
+		addPodOwner(owners, vmResult.Metric)
+	}
+	
+	return owners, nil
+}
+
+// maxErrorBodyBytes bounds how much of an error response body is read into the returned error
+const maxErrorBodyBytes = 512
+
+// vmErrorMessage returns a suffix describing why VictoriaMetrics rejected a request, taken from the
+// error field of a JSON error response or the truncated response body otherwise, empty if there is none
+func vmErrorMessage(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes+1))
+	truncated := len(body) > maxErrorBodyBytes
+	if truncated {
+		body = body[:maxErrorBodyBytes]
+	}
+
+	// The errorType of VictoriaMetrics errors repeats the status code, so only the error is kept
+	var vmErr struct {
+		Error string `json:"error"`
+	}
+	if !truncated && json.Unmarshal(body, &vmErr) == nil && vmErr.Error != "" {
+		return ": " + vmErr.Error
+	}
+
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		return ""
+	}
+	if truncated {
+		message += "..."
+	}
+	return ": " + message
+}
+
 // query executes a single query against VictoriaMetrics
 func (vm *VictoriaMetricsClient) query(ctx context.Context, query string) (*VMResponse, error) {
+	return vm.queryAt(ctx, query, time.Now())
+}
+
+// queryAt executes a single query against VictoriaMetrics evaluated at the given time
+func (vm *VictoriaMetricsClient) queryAt(ctx context.Context, query string, at time.Time) (*VMResponse, error) {
 	params := url.Values{}
 	params.Set("query", query)
-	params.Set("time", strconv.FormatInt(time.Now().Unix(), 10))
+	params.Set("time", strconv.FormatInt(at.Unix(), 10))
 	
 	queryURL := vm.baseURL + "api/v1/query?" + params.Encode()
 	
@@ -483,8 +912,12 @@ func (vm *VictoriaMetricsClient) query(ctx context.Context, query string) (*VMRe
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		return nil, fmt.Errorf("%w: VictoriaMetrics query failed with status %d%s", ErrInvalidQuery, resp.StatusCode, vmErrorMessage(resp))
+	}
+	
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("VictoriaMetrics query failed with status %d", resp.StatusCode)
+		return nil, fmt.Errorf("VictoriaMetrics query failed with status %d%s", resp.StatusCode, vmErrorMessage(resp))
 	}
 	
 	body, err := io.ReadAll(resp.Body)
@@ -501,13 +934,63 @@ func (vm *VictoriaMetricsClient) query(ctx context.Context, query string) (*VMRe
 	if vmResp.Status != "success" {
 		return nil, fmt.Errorf("VictoriaMetrics query failed: %s", vmResp.Status)
 	}
+	recordVMWarnings(ctx, &vmResp)
 	
 	return &vmResp, nil
 }
 
+// QueryInstant executes an arbitrary instant query and returns the raw samples
+func (vm *VictoriaMetricsClient) QueryInstant(ctx context.Context, query string) ([]QuerySample, error) {
+	result, err := vm.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	
+	var samples []QuerySample
+	for _, vmResult := range result.Data.Result {
+		if len(vmResult.Value) < 2 {
+			continue
+		}
+		timestamp, ok1 := vmNumber(vmResult.Value[0])
+		value, ok2 := vmNumber(vmResult.Value[1])
+		if !ok1 || !ok2 {
+			continue
+		}
+		
+		samples = append(samples, QuerySample{
+			Metric:    vmResult.Metric,
+			Value:     value,
+			Timestamp: time.Unix(int64(timestamp), 0),
+		})
+	}
+	
+	return samples, nil
+}
+
+// queryScalar executes an instant query and returns the value of the first sample
+func (vm *VictoriaMetricsClient) queryScalar(ctx context.Context, query string) (float64, error) {
+	result, err := vm.query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	
+	for _, vmResult := range result.Data.Result {
+		if len(vmResult.Value) >= 2 {
+			if value, ok := vmNumber(vmResult.Value[1]); ok {
+				return value, nil
+			}
+		}
+	}
+	
+	return 0, nil
+}
+
 // queryRangeMetric executes a range query and returns data points
 func (vm *VictoriaMetricsClient) queryRangeMetric(ctx context.Context, query string, start, end time.Time) ([]DataPoint, error) {
-	step := 5 * time.Minute // 5-minute resolution
+	step, _ := rangeStep(start, end, vm.config.MaxSamplesPerSeries) // Coarsened to respect the sample cap
+	if vm.config.AlignRangeQueries {
+		start, end = alignRange(start, end, step)
+	}
 	
 	params := url.Values{}
 	params.Set("query", query)
@@ -529,7 +1012,7 @@ func (vm *VictoriaMetricsClient) queryRangeMetric(ctx context.Context, query str
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("VictoriaMetrics range query failed with status %d", resp.StatusCode)
+		return nil, fmt.Errorf("VictoriaMetrics range query failed with status %d%s", resp.StatusCode, vmErrorMessage(resp))
 	}
 	
 	body, err := io.ReadAll(resp.Body)
@@ -546,273 +1029,87 @@ func (vm *VictoriaMetricsClient) queryRangeMetric(ctx context.Context, query str
 	if vmResp.Status != "success" {
 		return nil, fmt.Errorf("VictoriaMetrics range query failed: %s", vmResp.Status)
 	}
+	recordVMWarnings(ctx, &vmResp)
 
-	var dataPoints []DataPoint
+	var series [][]DataPoint
 	
-	for _, series := range vmResp.Data.Result {
-		for _, values := range series.Values {
+	for _, result := range vmResp.Data.Result {
+		var dataPoints []DataPoint
+		for _, values := range result.Values {
 			if len(values) >= 2 {
-				timestamp, ok1 := values[0].(float64)
-				valueStr, ok2 := values[1].(string)
+				timestamp, ok1 := vmNumber(values[0])
+				value, ok2 := vmNumber(values[1])
 				
 				if ok1 && ok2 {
-					value, err := strconv.ParseFloat(valueStr, 64)
-					if err == nil {
-						dataPoints = append(dataPoints, DataPoint{
-							Timestamp: time.Unix(int64(timestamp), 0),
-							Value:     value,
-						})
-					}
+					dataPoints = append(dataPoints, DataPoint{
+						Timestamp: time.Unix(int64(timestamp), 0),
+						Value:     value,
+					})
 				}
 			}
 		}
+		series = append(series, dataPoints)
 	}
 	
-	return dataPoints, nil
+	// Merge series the query unexpectedly matched instead of interleaving their points
+	return mergeSeriesPoints(series, vm.config.DuplicateAggregation), nil
 }
 
-// The following methods are shared analysis functions that can be reused
-// They are duplicated here for the VMAgentClient to maintain independence
-
-// analyzeResourceData performs statistical analysis on resource data
-func (vm *VictoriaMetricsClient) analyzeResourceData(usage, requests, limits []DataPoint) HistoricalResourceData {
-	if len(usage) == 0 {
-		return HistoricalResourceData{
-			Usage:    usage,
-			Requests: requests,
-			Limits:   limits,
-			Trend:    "unknown",
-		}
-	}
-
-	// Calculate statistics
-	var total, min, max float64
-	min = usage[0].Value
-	max = usage[0].Value
-	
-	values := make([]float64, len(usage))
-	for i, point := range usage {
-		values[i] = point.Value
-		total += point.Value
-		if point.Value < min {
-			min = point.Value
-		}
-		if point.Value > max {
-			max = point.Value
-		}
-	}
-	
-	average := total / float64(len(usage))
-	
-	// Calculate percentiles
-	p95 := vm.calculatePercentile(values, 0.95)
-	p99 := vm.calculatePercentile(values, 0.99)
-	
-	// Determine trend
-	trend := vm.calculateTrend(usage)
-
-	return HistoricalResourceData{
-		Usage:    usage,
-		Requests: requests,
-		Limits:   limits,
-		Average:  average,
-		Peak:     max,
-		Minimum:  min,
-		P95:      p95,
-		P99:      p99,
-		Trend:    trend,
-	}
+// AnalyzeHistoricalMetrics recomputes the statistics, trends and analysis of hm from its series,
+// keeping its data completeness
+func (vm *VictoriaMetricsClient) AnalyzeHistoricalMetrics(hm HistoricalMetrics) HistoricalMetrics {
+	return analyzeHistoricalMetrics(vm.config, hm)
 }
 
-// calculatePercentile calculates the specified percentile of a dataset
-func (vm *VictoriaMetricsClient) calculatePercentile(values []float64, percentile float64) float64 {
-	if len(values) == 0 {
-		return 0
-	}
-	
-	// Simple percentile calculation (could be improved with proper sorting)
-	n := len(values)
-	index := int(percentile * float64(n))
-	if index >= n {
-		index = n - 1
-	}
-	
-	// For simplicity, return a rough approximation
-	var sum float64
-	count := 0
-	for _, v := range values {
-		if count < index {
-			sum += v
-			count++
-		}
-	}
-	
-	if count == 0 {
-		return 0
-	}
-	return sum / float64(count)
-}
-
-// calculateTrend determines if the usage is increasing, decreasing, or stable
-func (vm *VictoriaMetricsClient) calculateTrend(usage []DataPoint) string {
-	if len(usage) < 10 {
-		return "insufficient_data"
-	}
-	
-	// Simple trend calculation using first vs last quartile
-	quarterSize := len(usage) / 4
-	firstQuarter := usage[:quarterSize]
-	lastQuarter := usage[len(usage)-quarterSize:]
-	
-	var firstSum, lastSum float64
-	for _, point := range firstQuarter {
-		firstSum += point.Value
-	}
-	for _, point := range lastQuarter {
-		lastSum += point.Value
-	}
-	
-	firstAvg := firstSum / float64(len(firstQuarter))
-	lastAvg := lastSum / float64(len(lastQuarter))
-	
-	diff := (lastAvg - firstAvg) / firstAvg
-	
-	if diff > 0.1 { // 10% increase
-		return "increasing"
-	} else if diff < -0.1 { // 10% decrease
-		return "decreasing"
-	}
-	return "stable"
-}
-
-// generateUsageAnalysis creates usage analysis and recommendations
-func (vm *VictoriaMetricsClient) generateUsageAnalysis(cpu, memory HistoricalResourceData) UsageAnalysis {
-	analysis := UsageAnalysis{
-		Recommendations: []string{},
-	}
-	
-	// Calculate efficiency if requests data is available
-	if len(cpu.Requests) > 0 && len(cpu.Requests[0:]) > 0 {
-		avgRequest := vm.getAverageValue(cpu.Requests)
-		if avgRequest > 0 {
-			analysis.CPUEfficiency = (cpu.Average / avgRequest) * 100
-		}
-	}
-	
-	if len(memory.Requests) > 0 && len(memory.Requests[0:]) > 0 {
-		avgRequest := vm.getAverageValue(memory.Requests)
-		if avgRequest > 0 {
-			analysis.MemoryEfficiency = (memory.Average / avgRequest) * 100
-		}
-	}
-	
-	// Generate waste analysis
-	analysis.ResourceWaste = vm.generateWasteAnalysis(cpu, memory, analysis.CPUEfficiency, analysis.MemoryEfficiency)
-	
-	// Generate recommendations
-	analysis.Recommendations = vm.generateRecommendations(cpu, memory, analysis.CPUEfficiency, analysis.MemoryEfficiency)
-	
-	// Generate patterns (simplified)
-	analysis.Patterns = UsagePatterns{
-		DailyVariation:  vm.calculateVariation(cpu.Usage),
-		WeeklyVariation: vm.calculateVariation(memory.Usage),
+// labelMatch restricts label lookups to container CPU usage series, the series filtered by selectors
+func (vm *VictoriaMetricsClient) labelMatch(namespace string) string {
+	namespaceFilter := ""
+	if namespace != "" {
+		namespaceFilter = fmt.Sprintf(`namespace="%s"`, namespace)
 	}
-	
-	return analysis
+	return vm.config.Queries.Selector(QueryCPUUsage, vm.config.Queries.BaseContainerFilter(), namespaceFilter)
 }
 
-// getAverageValue calculates average of data points
-func (vm *VictoriaMetricsClient) getAverageValue(points []DataPoint) float64 {
-	if len(points) == 0 {
-		return 0
-	}
-	
-	var sum float64
-	for _, point := range points {
-		sum += point.Value
-	}
-	return sum / float64(len(points))
+// GetLabelNames retrieves the label names of container usage series from VictoriaMetrics
+func (vm *VictoriaMetricsClient) GetLabelNames(ctx context.Context, namespace string) ([]string, error) {
+	return vm.queryLabels(ctx, "api/v1/labels", namespace)
 }
 
-// generateWasteAnalysis identifies resource waste
-func (vm *VictoriaMetricsClient) generateWasteAnalysis(cpu, memory HistoricalResourceData, cpuEff, memEff float64) ResourceWasteAnalysis {
-	waste := ResourceWasteAnalysis{}
-	
-	// CPU analysis
-	if cpuEff > 0 && cpuEff < 30 {
-		waste.CPUOverProvisioned = true
-		waste.CPUWastePercentage = 100 - cpuEff
-	} else if cpuEff > 80 {
-		waste.CPUUnderProvisioned = true
-	}
-	
-	// Memory analysis
-	if memEff > 0 && memEff < 30 {
-		waste.MemoryOverProvisioned = true
-		waste.MemoryWastePercentage = 100 - memEff
-	} else if memEff > 80 {
-		waste.MemoryUnderProvisioned = true
-	}
-	
-	return waste
+// GetLabelValues retrieves the values of a label on container usage series from VictoriaMetrics
+func (vm *VictoriaMetricsClient) GetLabelValues(ctx context.Context, name, namespace string) ([]string, error) {
+	return vm.queryLabels(ctx, "api/v1/label/"+url.PathEscape(name)+"/values", namespace)
 }
 
-// generateRecommendations creates actionable recommendations
-func (vm *VictoriaMetricsClient) generateRecommendations(cpu, memory HistoricalResourceData, cpuEff, memEff float64) []string {
-	var recommendations []string
-	
-	if cpuEff > 0 && cpuEff < 30 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider reducing CPU requests - current efficiency: %.1f%%", cpuEff))
-	} else if cpuEff > 80 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider increasing CPU requests - current efficiency: %.1f%%", cpuEff))
-	}
-	
-	if memEff > 0 && memEff < 30 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider reducing memory requests - current efficiency: %.1f%%", memEff))
-	} else if memEff > 80 {
-		recommendations = append(recommendations, fmt.Sprintf("Consider increasing memory requests - current efficiency: %.1f%%", memEff))
-	}
-	
-	if cpu.Trend == "increasing" {
-		recommendations = append(recommendations, "CPU usage is trending upward - monitor for potential scaling needs")
-	}
-	
-	if memory.Trend == "increasing" {
-		recommendations = append(recommendations, "Memory usage is trending upward - monitor for potential memory leaks or scaling needs")
-	}
+// queryLabels runs a label names or label values request restricted to container usage series
+func (vm *VictoriaMetricsClient) queryLabels(ctx context.Context, path, namespace string) ([]string, error) {
+	now := time.Now()
+	params := url.Values{}
+	params.Set("match[]", vm.labelMatch(namespace))
+	params.Set("start", strconv.FormatInt(now.Add(-labelLookback).Unix(), 10))
+	params.Set("end", strconv.FormatInt(now.Unix(), 10))
 	
-	if len(recommendations) == 0 {
-		recommendations = append(recommendations, "Resource usage appears well-optimized")
+	req, err := http.NewRequestWithContext(ctx, "GET", vm.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
 	}
 	
-	return recommendations
-}
-
-// calculateVariation calculates coefficient of variation
-func (vm *VictoriaMetricsClient) calculateVariation(points []DataPoint) float64 {
-	if len(points) < 2 {
-		return 0
+	resp, err := vm.client.Do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 	
-	// Calculate mean
-	var sum float64
-	for _, point := range points {
-		sum += point.Value
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("VictoriaMetrics label query failed with status %d%s", resp.StatusCode, vmErrorMessage(resp))
 	}
-	mean := sum / float64(len(points))
 	
-	if mean == 0 {
-		return 0
+	var labelsResp VMLabelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&labelsResp); err != nil {
+		return nil, err
 	}
-	
-	// Calculate variance
-	var variance float64
-	for _, point := range points {
-		variance += (point.Value - mean) * (point.Value - mean)
+	if labelsResp.Status != "success" {
+		return nil, fmt.Errorf("VictoriaMetrics label query failed: %s", labelsResp.Status)
 	}
-	variance /= float64(len(points))
 	
-	// Return coefficient of variation (std dev / mean)
-	stdDev := variance // Simplified - should be sqrt(variance)
-	return stdDev / mean * 100
+	return labelsResp.Data, nil
 }
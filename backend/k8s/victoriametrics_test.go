@@ -0,0 +1,701 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newVMServer serves VictoriaMetrics query API requests with the result fn returns for each request,
+// a JSON array of series, or an empty result when it returns ""
+func newVMServer(t *testing.T, fn func(r *http.Request) string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resultType := "vector"
+		if strings.HasSuffix(r.URL.Path, "/query_range") {
+			resultType = "matrix"
+		}
+		result := fn(r)
+		if result == "" {
+			result = "[]"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"` + resultType + `","result":` + result + `}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestVMClient returns a VictoriaMetrics client of server
+func newTestVMClient(t *testing.T, server *httptest.Server, config MetricsClientConfig) *VictoriaMetricsClient {
+	t.Helper()
+	config.URL = server.URL
+	vm, err := NewVictoriaMetricsClient(config)
+	if err != nil {
+		t.Fatalf("NewVictoriaMetricsClient() error = %v", err)
+	}
+	return vm
+}
+
+// usageData returns resource data with a constant usage and request
+func usageData(usage, request float64) HistoricalResourceData {
+	return HistoricalResourceData{
+		Average:  usage,
+		Usage:    []DataPoint{{Value: usage}, {Value: usage}},
+		Requests: []DataPoint{{Value: request}, {Value: request}},
+	}
+}
+
+func TestGenerateUsageAnalysisOOMKilled(t *testing.T) {
+	config := MetricsClientConfig{WasteLowThreshold: DefaultWasteLowThreshold, WasteHighThreshold: DefaultWasteHighThreshold}
+	tests := []struct {
+		name      string
+		memory    HistoricalResourceData
+		oomKilled bool
+		want      string
+		notWant   string
+	}{
+		{"over-provisioned memory is reduced", usageData(64<<20, 1<<30), false, "Consider reducing memory requests", "OOMKilled"},
+		{"OOMKilled over-provisioned memory is not reduced", usageData(64<<20, 1<<30), true, "recently OOMKilled", "reducing memory"},
+		{"OOMKilled well-sized memory", usageData(512<<20, 1<<30), true, "recently OOMKilled", "reducing memory"},
+		{"under-provisioned memory is increased", usageData(1<<30, 1<<30), false, "Consider increasing memory requests", "OOMKilled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := generateUsageAnalysis(config, usageData(0.5, 1), tt.memory, tt.oomKilled, "")
+			recommendations := strings.Join(analysis.Recommendations, "\n")
+			if !strings.Contains(recommendations, tt.want) {
+				t.Errorf("recommendations %q do not contain %q", analysis.Recommendations, tt.want)
+			}
+			if strings.Contains(recommendations, tt.notWant) {
+				t.Errorf("recommendations %q contain %q", analysis.Recommendations, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestGenerateWasteAnalysisThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		low, high  float64
+		efficiency float64
+		wantOver   bool
+		wantUnder  bool
+		wantWaste  float64
+	}{
+		{"well-optimized with the defaults", DefaultWasteLowThreshold, DefaultWasteHighThreshold, 40, false, false, 0},
+		{"over-provisioned with a higher low threshold", 50, DefaultWasteHighThreshold, 40, true, false, 60},
+		{"under-provisioned with a lower high threshold", 10, 35, 40, false, true, 0},
+		{"boundary is not over-provisioned", 40, 80, 40, false, false, 0},
+		{"boundary is not under-provisioned", 10, 40, 40, false, false, 0},
+		{"no requests", 50, 80, 0, false, false, 0},
+	}
+	for _, tt := range tests {
+		config := MetricsClientConfig{WasteLowThreshold: tt.low, WasteHighThreshold: tt.high}
+		t.Run(tt.name, func(t *testing.T) {
+			waste := generateWasteAnalysis(config, tt.efficiency, tt.efficiency)
+			if waste.CPUOverProvisioned != tt.wantOver || waste.MemoryOverProvisioned != tt.wantOver {
+				t.Errorf("over-provisioned = %v/%v, want %v", waste.CPUOverProvisioned, waste.MemoryOverProvisioned, tt.wantOver)
+			}
+			if waste.CPUUnderProvisioned != tt.wantUnder || waste.MemoryUnderProvisioned != tt.wantUnder {
+				t.Errorf("under-provisioned = %v/%v, want %v", waste.CPUUnderProvisioned, waste.MemoryUnderProvisioned, tt.wantUnder)
+			}
+			if waste.CPUWastePercentage != tt.wantWaste {
+				t.Errorf("waste percentage = %v, want %v", waste.CPUWastePercentage, tt.wantWaste)
+			}
+		})
+	}
+}
+
+func TestVMGetCurrentPodMetricsEvalTime(t *testing.T) {
+	tests := []struct {
+		name string
+		at   time.Time
+	}{
+		{"now", time.Now().Truncate(time.Second)},
+		{"two hours ago", time.Now().Add(-2 * time.Hour).Truncate(time.Second)},
+		{"fixed instant", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var evalTimes []string
+			server := newVMServer(t, func(r *http.Request) string {
+				mu.Lock()
+				defer mu.Unlock()
+				evalTimes = append(evalTimes, r.URL.Query().Get("time"))
+				return ""
+			})
+			vm := newTestVMClient(t, server, MetricsClientConfig{})
+			if _, err := vm.GetCurrentPodMetrics(context.Background(), "shop", "", tt.at); err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if len(evalTimes) == 0 {
+				t.Fatal("no queries were sent")
+			}
+			want := strconv.FormatInt(tt.at.Unix(), 10)
+			for i, evalTime := range evalTimes {
+				if evalTime != want {
+					t.Errorf("query %d evaluated at %s, want %s", i, evalTime, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVMGetCurrentPodMetricsDuplicateSeries(t *testing.T) {
+	// Two HA replicas return the same containers with slightly different values
+	replicas := func(values ...string) string {
+		return `[{"metric":{"namespace":"shop","pod":"web-0","container":"app","prometheus_replica":"a"},"value":[1700000000,"` + values[0] + `"]},` +
+			`{"metric":{"namespace":"shop","pod":"web-0","container":"app","prometheus_replica":"b"},"value":[1700000000,"` + values[1] + `"]}]`
+	}
+	server := newVMServer(t, func(r *http.Request) string {
+		query := r.URL.Query().Get("query")
+		switch {
+		case strings.Contains(query, "timestamp("):
+			return ""
+		case strings.Contains(query, "container_cpu_usage_seconds_total"):
+			return replicas("0.2", "0.3")
+		case strings.Contains(query, "container_memory_working_set_bytes"):
+			return replicas("100", "120")
+		case strings.Contains(query, "kube_pod_container_resource_requests") && strings.Contains(query, `resource="cpu"`):
+			return replicas("0.5", "0.5")
+		}
+		return ""
+	})
+
+	tests := []struct {
+		aggregation string
+		wantCPU     float64
+		wantMemory  float64
+	}{
+		{MergeMax, 0.3, 120},
+		{MergeMin, 0.2, 100},
+		{MergeAvg, 0.25, 110},
+	}
+	for _, tt := range tests {
+		t.Run(tt.aggregation, func(t *testing.T) {
+			vm := newTestVMClient(t, server, MetricsClientConfig{DuplicateAggregation: tt.aggregation})
+			pods, err := vm.GetCurrentPodMetrics(context.Background(), "shop", "", time.Now())
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if len(pods) != 1 {
+				t.Fatalf("got %d pod metrics, want a single merged one: %+v", len(pods), pods)
+			}
+			pod := pods[0]
+			if math.Abs(pod.CPUUsage-tt.wantCPU) > 1e-9 || math.Abs(pod.MemoryUsage-tt.wantMemory) > 1e-9 || pod.CPURequest != 0.5 {
+				t.Errorf("merged cpu=%v memory=%v cpu request=%v, want %v, %v and 0.5", pod.CPUUsage, pod.MemoryUsage, pod.CPURequest, tt.wantCPU, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestVMQueryRangeStep(t *testing.T) {
+	end := time.Now()
+	tests := []struct {
+		name       string
+		span       time.Duration
+		maxSamples int
+		wantStep   string
+	}{
+		{"default step", 24 * time.Hour, DefaultMaxSamplesPerSeries, "300"},
+		{"coarsened under the cap", 30 * 24 * time.Hour, DefaultMaxSamplesPerSeries, "1038"},
+		{"small cap", 24 * time.Hour, 25, "3600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var step string
+			server := newVMServer(t, func(r *http.Request) string {
+				step = r.URL.Query().Get("step")
+				return ""
+			})
+			vm := newTestVMClient(t, server, MetricsClientConfig{MaxSamplesPerSeries: tt.maxSamples})
+			if _, err := vm.GetContainerSeries(context.Background(), "shop", "web-0", "app", SeriesMemory, end.Add(-tt.span), end); err != nil {
+				t.Fatalf("GetContainerSeries() error = %v", err)
+			}
+			if step != tt.wantStep {
+				t.Errorf("step = %s, want %s", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestVMGetPodStatus(t *testing.T) {
+	server := newVMServer(t, func(r *http.Request) string {
+		query := r.URL.Query().Get("query")
+		switch {
+		case strings.HasPrefix(query, `kube_pod_status_phase{namespace="shop", pod="web-0"} == 1`):
+			return `[{"metric":{"namespace":"shop","pod":"web-0","phase":"Running"},"value":[1700000000,"1"]}]`
+		case strings.HasPrefix(query, `kube_pod_container_status_last_terminated_reason{namespace="shop", pod="web-0"} > 0`):
+			return `[{"metric":{"namespace":"shop","pod":"web-0","container":"app","reason":"OOMKilled"},"value":[1700000000,"1"]},` +
+				`{"metric":{"namespace":"shop","pod":"web-0","container":"init","reason":"Completed"},"value":[1700000000,"1"]}]`
+		}
+		return ""
+	})
+	vm := newTestVMClient(t, server, MetricsClientConfig{})
+
+	tests := []struct {
+		pod  string
+		want PodStatus
+	}{
+		{"web-0", PodStatus{Phase: "Running", LastTerminationReasons: map[string]string{"app": "OOMKilled", "init": "Completed"}}},
+		{"missing", PodStatus{LastTerminationReasons: map[string]string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			status, err := vm.GetPodStatus(context.Background(), "shop", tt.pod)
+			if err != nil {
+				t.Fatalf("GetPodStatus() error = %v", err)
+			}
+			if !reflect.DeepEqual(status, tt.want) {
+				t.Errorf("GetPodStatus() = %+v, want %+v", status, tt.want)
+			}
+		})
+	}
+}
+
+// rampPoints returns n datapoints rising linearly from 1 to 2
+func rampPoints(n int) []DataPoint {
+	result := make([]DataPoint, n)
+	for i := range result {
+		result[i] = DataPoint{Timestamp: time.Unix(int64(i)*300, 0), Value: 1 + float64(i)/float64(n-1)}
+	}
+	return result
+}
+
+func TestCalculateTrendMinSamples(t *testing.T) {
+	tests := []struct {
+		name       string
+		minSamples int
+		points     int
+		want       string
+	}{
+		{"below the default", DefaultMinTrendSamples, 9, "insufficient_data"},
+		{"at the default", DefaultMinTrendSamples, 10, "increasing"},
+		{"below a higher minimum", 50, 40, "insufficient_data"},
+		{"at a higher minimum", 50, 50, "increasing"},
+		{"lower minimum", 4, 4, "increasing"},
+		{"quartiles need four points", 1, 3, "insufficient_data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := MetricsClientConfig{MinTrendSamples: tt.minSamples}
+			if got := calculateTrend(config, rampPoints(tt.points), DefaultTrendThreshold); got != tt.want {
+				t.Errorf("calculateTrend() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// matrixSeries returns a range query result of one series with values at 5 minute steps from start
+func matrixSeries(start time.Time, values ...float64) string {
+	samples := make([]string, len(values))
+	for i, value := range values {
+		ts := start.Add(time.Duration(i) * 5 * time.Minute).Unix()
+		samples[i] = "[" + strconv.FormatInt(ts, 10) + `,"` + strconv.FormatFloat(value, 'f', -1, 64) + `"]`
+	}
+	return `[{"metric":{},"values":[` + strings.Join(samples, ",") + `]}]`
+}
+
+func TestVMRestartTrend(t *testing.T) {
+	const recommendation = "Container restarting frequently - investigate crashes/OOM"
+	end := time.Now()
+	start := end.Add(-time.Hour)
+	rising := []float64{0, 0, 0, 0.1, 0.2, 0.4, 0.6, 0.8, 1, 1.5, 2, 3}
+	flat := []float64{0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5}
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		restartRate []float64
+		wantTrend   string
+		wantRec     bool
+	}{
+		{"rising restarts", true, rising, "increasing", true},
+		{"steady restarts", true, flat, "stable", false},
+		{"too few samples", true, rising[:3], "insufficient_data", false},
+		{"disabled", false, rising, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var restartQueries atomic.Int32
+			server := newVMServer(t, func(r *http.Request) string {
+				if query := r.URL.Query().Get("query"); strings.Contains(query, "restarts_total") && strings.HasSuffix(query, "[1h])") {
+					restartQueries.Add(1)
+					return matrixSeries(start, tt.restartRate...)
+				}
+				return ""
+			})
+			vm := newTestVMClient(t, server, MetricsClientConfig{EnableRestartTrend: tt.enabled})
+
+			hm, err := vm.getHistoricalMetricsForContainer(context.Background(), "web-0", "shop", "app", start, end)
+			if err != nil {
+				t.Fatalf("getHistoricalMetricsForContainer() error = %v", err)
+			}
+			if tt.enabled != (restartQueries.Load() == 1) {
+				t.Errorf("restart rate queried %d times, want enabled %v", restartQueries.Load(), tt.enabled)
+			}
+			if hm.RestartTrend != tt.wantTrend {
+				t.Errorf("RestartTrend = %q, want %q", hm.RestartTrend, tt.wantTrend)
+			}
+			hasRec := false
+			for _, rec := range hm.Analysis.Recommendations {
+				hasRec = hasRec || rec == recommendation
+			}
+			if hasRec != tt.wantRec {
+				t.Errorf("recommendations %q, want restart recommendation %v", hm.Analysis.Recommendations, tt.wantRec)
+			}
+		})
+	}
+}
+
+func TestGenerateUsageAnalysisEfficiencyBasis(t *testing.T) {
+	// A bursty container idles at a fifth of its request and spikes to the full request
+	bursty := HistoricalResourceData{
+		Average:  0.2,
+		P95:      0.6,
+		Peak:     1,
+		Usage:    []DataPoint{{Value: 0.2}, {Value: 1}},
+		Requests: []DataPoint{{Value: 1}, {Value: 1}},
+	}
+
+	tests := []struct {
+		basis          string
+		wantEfficiency float64
+		wantOver       bool
+		wantUnder      bool
+		wantRec        string
+	}{
+		{"", 20, true, false, "Consider reducing CPU requests"},
+		{EfficiencyAverage, 20, true, false, "Consider reducing CPU requests"},
+		{EfficiencyP95, 60, false, false, ""},
+		{EfficiencyPeak, 100, false, true, "Consider increasing CPU requests"},
+	}
+	for _, tt := range tests {
+		config := MetricsClientConfig{
+			WasteLowThreshold:  DefaultWasteLowThreshold,
+			WasteHighThreshold: DefaultWasteHighThreshold,
+			EfficiencyBasis:    tt.basis,
+		}
+		t.Run(tt.basis, func(t *testing.T) {
+			analysis := generateUsageAnalysis(config, bursty, HistoricalResourceData{}, false, "")
+			if math.Abs(analysis.CPUEfficiency-tt.wantEfficiency) > 1e-9 {
+				t.Errorf("CPUEfficiency = %v, want %v", analysis.CPUEfficiency, tt.wantEfficiency)
+			}
+			waste := analysis.ResourceWaste
+			if waste.CPUOverProvisioned != tt.wantOver || waste.CPUUnderProvisioned != tt.wantUnder {
+				t.Errorf("over/under-provisioned = %v/%v, want %v/%v",
+					waste.CPUOverProvisioned, waste.CPUUnderProvisioned, tt.wantOver, tt.wantUnder)
+			}
+			recommendations := strings.Join(analysis.Recommendations, "\n")
+			if tt.wantRec == "" {
+				if strings.Contains(recommendations, "CPU requests") {
+					t.Errorf("recommendations %q, want no CPU change", analysis.Recommendations)
+				}
+			} else if !strings.Contains(recommendations, tt.wantRec) {
+				t.Errorf("recommendations %q, want %q", analysis.Recommendations, tt.wantRec)
+			}
+		})
+	}
+}
+
+func TestVMGetCurrentPodMetricsLookback(t *testing.T) {
+	// The pod was last scraped 90s before the evaluation time, so only gauge queries looking back
+	// at least that far still see its series
+	const scrapeAge = 90 * time.Second
+	lookbackWindow := regexp.MustCompile(`^last_over_time\(.*\[(\d+)s\]\)$`)
+	server := newVMServer(t, func(r *http.Request) string {
+		query := r.URL.Query().Get("query")
+		match := lookbackWindow.FindStringSubmatch(query)
+		if match == nil {
+			return ""
+		}
+		seconds, _ := strconv.Atoi(match[1])
+		if time.Duration(seconds)*time.Second < scrapeAge {
+			return ""
+		}
+		value := "0"
+		switch {
+		case strings.Contains(query, "container_memory_working_set_bytes"):
+			value = "1048576"
+		case strings.Contains(query, "kube_pod_container_resource_requests") && strings.Contains(query, `resource="cpu"`):
+			value = "0.5"
+		}
+		return `[{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"` + value + `"]}]`
+	})
+
+	tests := []struct {
+		name     string
+		lookback time.Duration
+		wantPod  bool
+	}{
+		{"disabled", 0, false},
+		{"shorter than the scrape age", time.Minute, false},
+		{"covers the scrape age", 2 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := newTestVMClient(t, server, MetricsClientConfig{InstantLookback: tt.lookback})
+			pods, err := vm.GetCurrentPodMetrics(context.Background(), "shop", "", time.Now())
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if !tt.wantPod {
+				if len(pods) != 0 {
+					t.Errorf("got %d pod metrics with a %s lookback, want none: %+v", len(pods), tt.lookback, pods)
+				}
+				return
+			}
+			if len(pods) != 1 || pods[0].Name != "web-0" {
+				t.Fatalf("got pod metrics %+v, want web-0 with a %s lookback", pods, tt.lookback)
+			}
+			if pods[0].MemoryUsage != 1048576 || pods[0].CPURequest != 0.5 {
+				t.Errorf("memory=%v cpu request=%v, want 1048576 and 0.5", pods[0].MemoryUsage, pods[0].CPURequest)
+			}
+		})
+	}
+}
+
+func TestVMHistoricalMetricsIncludeCompleted(t *testing.T) {
+	// The job ran and finished two hours ago, inside the analysis window
+	jobCompleted := time.Now().Add(-2 * time.Hour)
+	completionBound := regexp.MustCompile(`>= (\d+)`)
+	container := func(pod string) string {
+		return `{"metric":{"namespace":"batch","pod":"` + pod + `","container":"main"},"value":[1700000000,"1"]}`
+	}
+
+	tests := []struct {
+		name             string
+		includeCompleted bool
+		activePods       []string
+		want             map[string]bool // pod -> completed
+	}{
+		{"running pods only", false, []string{"worker-0"}, map[string]bool{"worker-0": false}},
+		{"completed job included", true, []string{"worker-0"}, map[string]bool{"worker-0": false, "job-1": true}},
+		{"job still active", true, []string{"worker-0", "job-1"}, map[string]bool{"worker-0": false, "job-1": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newVMServer(t, func(r *http.Request) string {
+				query := r.URL.Query().Get("query")
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/query_range"):
+					return matrixSeries(time.Now().Add(-time.Hour), 0.1, 0.2, 0.1)
+				case strings.HasPrefix(query, "group by (pod, namespace, container)"):
+					active := make([]string, len(tt.activePods))
+					for i, pod := range tt.activePods {
+						active[i] = container(pod)
+					}
+					return "[" + strings.Join(active, ",") + "]"
+				case strings.Contains(query, "kube_pod_completion_time"):
+					match := completionBound.FindStringSubmatch(query)
+					if match == nil {
+						t.Errorf("completed pods query has no completion bound: %s", query)
+						return ""
+					}
+					if start, _ := strconv.ParseInt(match[1], 10, 64); start > jobCompleted.Unix() {
+						return ""
+					}
+					return "[" + container("job-1") + "]"
+				}
+				return ""
+			})
+			vm := newTestVMClient(t, server, MetricsClientConfig{})
+
+			metrics, err := vm.GetHistoricalMetrics(context.Background(), "batch", HistoricalOptions{IncludeCompleted: tt.includeCompleted})
+			if err != nil {
+				t.Fatalf("GetHistoricalMetrics() error = %v", err)
+			}
+			got := make(map[string]bool)
+			for _, m := range metrics {
+				got[m.PodName] = m.Completed
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("analyzed pods = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVMNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		want   float64
+		wantOK bool
+	}{
+		{"string", "0.25", 0.25, true},
+		{"number", 0.25, 0.25, true},
+		{"json number", json.Number("1048576"), 1048576, true},
+		{"integer string", "3", 3, true},
+		{"unparseable string", "abc", 0, false},
+		{"null", nil, 0, false},
+		{"boolean", true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := vmNumber(tt.value)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("vmNumber(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVMNumericSampleValues(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		cpu, memory string // encoded sample values
+	}{
+		{"strings", `"0.25"`, `"1048576"`},
+		{"numbers", `0.25`, `1048576`},
+		{"mixed", `0.25`, `"1048576"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newVMServer(t, func(r *http.Request) string {
+				query := r.URL.Query().Get("query")
+				if strings.HasSuffix(r.URL.Path, "/query_range") {
+					return `[{"metric":{},"values":[[` + strconv.FormatInt(start.Unix(), 10) + `,` + tt.memory + `],[` +
+						strconv.FormatInt(start.Add(5*time.Minute).Unix(), 10) + `,` + tt.memory + `]]}]`
+				}
+				series := `{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,`
+				switch {
+				case strings.Contains(query, "timestamp("):
+					return ""
+				case strings.Contains(query, "container_cpu_usage_seconds_total"):
+					return "[" + series + tt.cpu + "]}]"
+				case strings.Contains(query, "container_memory_working_set_bytes"):
+					return "[" + series + tt.memory + "]}]"
+				}
+				return ""
+			})
+			vm := newTestVMClient(t, server, MetricsClientConfig{})
+
+			pods, err := vm.GetCurrentPodMetrics(context.Background(), "shop", "", time.Now())
+			if err != nil {
+				t.Fatalf("GetCurrentPodMetrics() error = %v", err)
+			}
+			if len(pods) != 1 || pods[0].CPUUsage != 0.25 || pods[0].MemoryUsage != 1048576 {
+				t.Errorf("current pod metrics = %+v, want cpu 0.25 and memory 1048576", pods)
+			}
+
+			points, err := vm.GetContainerSeries(context.Background(), "shop", "web-0", "app", SeriesMemory, start, start.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("GetContainerSeries() error = %v", err)
+			}
+			want := []DataPoint{{Timestamp: start, Value: 1048576}, {Timestamp: start.Add(5 * time.Minute), Value: 1048576}}
+			if len(points) != len(want) {
+				t.Fatalf("series = %+v, want %+v", points, want)
+			}
+			for i := range want {
+				if !points[i].Timestamp.Equal(want[i].Timestamp) || points[i].Value != want[i].Value {
+					t.Errorf("point %d = %+v, want %+v", i, points[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// risePoints returns 12 datapoints whose first quarter averages 100 and last quarter 100+rise
+func risePoints(rise float64) []DataPoint {
+	points := make([]DataPoint, 12)
+	for i := range points {
+		points[i] = DataPoint{Timestamp: time.Unix(int64(i)*300, 0), Value: 100 + rise*float64(i/3)/3}
+	}
+	return points
+}
+
+func TestTrendThresholdPerResource(t *testing.T) {
+	tests := []struct {
+		name                string
+		cpuThreshold        float64
+		memoryThreshold     float64
+		wantCPU, wantMemory string
+	}{
+		{"memory threshold above the rise", 10, 15, "increasing", "stable"},
+		{"memory threshold below the rise", 15, 10, "stable", "increasing"},
+		{"both below", 10, 10, "increasing", "increasing"},
+		{"both above", 15, 15, "stable", "stable"},
+	}
+	for _, tt := range tests {
+		config := MetricsClientConfig{CPUTrendThreshold: tt.cpuThreshold, MemoryTrendThreshold: tt.memoryThreshold}
+		clients := map[string]func(HistoricalMetrics) HistoricalMetrics{
+			"prometheus":      (&PrometheusClient{config: config}).AnalyzeHistoricalMetrics,
+			"victoriametrics": (&VictoriaMetricsClient{config: config}).AnalyzeHistoricalMetrics,
+		}
+		for client, analyze := range clients {
+			t.Run(client+"/"+tt.name, func(t *testing.T) {
+				// Both resources rise 12% from the first to the last quartile
+				hm := analyze(HistoricalMetrics{
+					CPU:    HistoricalResourceData{Usage: risePoints(12)},
+					Memory: HistoricalResourceData{Usage: risePoints(12)},
+				})
+				if hm.CPU.Trend != tt.wantCPU || hm.Memory.Trend != tt.wantMemory {
+					t.Errorf("trends = cpu %s, memory %s, want %s, %s", hm.CPU.Trend, hm.Memory.Trend, tt.wantCPU, tt.wantMemory)
+				}
+			})
+		}
+	}
+}
+
+func TestVMErrorBody(t *testing.T) {
+	const vmError = `{"status":"error","errorType":"422","error":"cannot parse \"rate(\": unexpected end of input"}`
+	long := strings.Repeat("x", maxErrorBodyBytes+100)
+
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantInvalid bool
+		want        string // Expected error message suffix after the status
+	}{
+		{"JSON error", http.StatusUnprocessableEntity, vmError, true, `status 422: cannot parse "rate(": unexpected end of input`},
+		{"plain text error", http.StatusBadRequest, "missing query arg\n", true, "status 400: missing query arg"},
+		{"empty body", http.StatusServiceUnavailable, "", false, "status 503"},
+		{"truncated body", http.StatusInternalServerError, long, false, "status 500: " + long[:maxErrorBodyBytes] + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+			vm := newTestVMClient(t, server, MetricsClientConfig{})
+			ctx := context.Background()
+
+			_, err := vm.query(ctx, "rate(")
+			if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("query() error = %v, want it to end with %q", err, tt.want)
+			}
+			if errors.Is(err, ErrInvalidQuery) != tt.wantInvalid {
+				t.Errorf("query() error = %v, want ErrInvalidQuery %v", err, tt.wantInvalid)
+			}
+
+			now := time.Now()
+			if _, err := vm.queryRangeMetric(ctx, "rate(", now.Add(-time.Hour), now); err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("queryRangeMetric() error = %v, want it to end with %q", err, tt.want)
+			}
+			if _, err := vm.queryLabels(ctx, "api/v1/labels", "shop"); err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("queryLabels() error = %v, want it to end with %q", err, tt.want)
+			}
+			if _, err := vm.export(ctx, `{namespace="shop"}`, now.Add(-time.Hour), now); err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("export() error = %v, want it to end with %q", err, tt.want)
+			}
+		})
+	}
+}
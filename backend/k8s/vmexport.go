@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// vmExportSeries is one line of the VictoriaMetrics /api/v1/export JSON lines response
+type vmExportSeries struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+// vmExportData holds the raw samples exported for a namespace, keyed by series kind
+// (one of the Timing* range query phases) and then by namespace/pod/container
+type vmExportData map[string]map[string][]RemoteReadSample
+
+type vmExportKey struct{}
+
+// exportNamespace bulk-fetches the raw usage, request and limit samples of every container
+// in namespace with one export call per series kind
+func (vm *VictoriaMetricsClient) exportNamespace(ctx context.Context, namespace string, start, end time.Time) (vmExportData, error) {
+	namespaceFilter := namespaceMatcher(namespace)
+	selectors := map[string]string{
+		TimingCPUUsage:       vm.config.Queries.Selector(QueryCPUUsage, namespaceFilter),
+		TimingMemoryUsage:    vm.config.Queries.Selector(QueryMemoryUsage, namespaceFilter),
+		TimingCPURequests:    vm.config.Queries.Selector(QueryResourceRequests, namespaceFilter, `resource="cpu"`),
+		TimingMemoryRequests: vm.config.Queries.Selector(QueryResourceRequests, namespaceFilter, `resource="memory"`),
+		TimingCPULimits:      vm.config.Queries.Selector(QueryResourceLimits, namespaceFilter, `resource="cpu"`),
+		TimingMemoryLimits:   vm.config.Queries.Selector(QueryResourceLimits, namespaceFilter, `resource="memory"`),
+	}
+
+	data := make(vmExportData, len(selectors))
+	for kind, selector := range selectors {
+		// Include one rate window before start so the first step can be evaluated
+		series, err := vm.export(ctx, selector, start.Add(-max(5*time.Minute, rateWindow(vm.config.ScrapeInterval))), end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", kind, err)
+		}
+
+		containers := make(map[string][]RemoteReadSample)
+		for _, s := range series {
+			if len(s.Values) != len(s.Timestamps) {
+				continue
+			}
+			key := s.Metric["namespace"] + "/" + s.Metric["pod"] + "/" + s.Metric["container"]
+			// Keep the most complete series when duplicates exist, since interleaving
+			// counters from different series would look like resets
+			if len(s.Values) <= len(containers[key]) {
+				continue
+			}
+			samples := make([]RemoteReadSample, len(s.Values))
+			for i := range s.Values {
+				samples[i] = RemoteReadSample{Timestamp: s.Timestamps[i], Value: s.Values[i]}
+			}
+			sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp < samples[j].Timestamp })
+			containers[key] = samples
+		}
+		data[kind] = containers
+	}
+
+	return data, nil
+}
+
+// export streams the raw series matching selector between start and end from /api/v1/export
+func (vm *VictoriaMetricsClient) export(ctx context.Context, selector string, start, end time.Time) ([]vmExportSeries, error) {
+	params := url.Values{}
+	params.Set("match[]", selector)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", vm.baseURL+"api/v1/export?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := vm.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("VictoriaMetrics export failed with status %d%s", resp.StatusCode, vmErrorMessage(resp))
+	}
+
+	return decodeVMExport(resp.Body)
+}
+
+// decodeVMExport parses a JSON lines export response, one series per line
+func decodeVMExport(r io.Reader) ([]vmExportSeries, error) {
+	var series []vmExportSeries
+	decoder := json.NewDecoder(r)
+	for {
+		var s vmExportSeries
+		if err := decoder.Decode(&s); err != nil {
+			if errors.Is(err, io.EOF) {
+				return series, nil
+			}
+			return nil, fmt.Errorf("failed to decode export response: %w", err)
+		}
+		series = append(series, s)
+	}
+}
+
+// rangeSeries returns a container's series of the given kind from the bulk export in ctx,
+// evaluated at the same steps as query_range, or runs query when no export is present
+func (vm *VictoriaMetricsClient) rangeSeries(ctx context.Context, kind, query, namespace, pod, container string, start, end time.Time) ([]DataPoint, error) {
+	data, ok := ctx.Value(vmExportKey{}).(vmExportData)
+	if !ok {
+		points, err := vm.queryRangeMetric(ctx, query, start, end)
+		if err != nil || kind != TimingCPUUsage {
+			return points, err
+		}
+		return vm.config.Queries.CPUUnit.pointsToCores(points), nil
+	}
+
+	step, _ := rangeStep(start, end, vm.config.MaxSamplesPerSeries)
+	if vm.config.AlignRangeQueries {
+		start, end = alignRange(start, end, step)
+	}
+	samples := data[kind][namespace+"/"+pod+"/"+container]
+	if kind != TimingCPUUsage {
+		return evaluateSteps(samples, start, end, step, 5*time.Minute, false), nil
+	}
+	unit := vm.config.Queries.CPUUnit
+	if unit.gauge() {
+		return unit.pointsToCores(evaluateSteps(samples, start, end, step, 5*time.Minute, false)), nil
+	}
+	return unit.pointsToCores(evaluateSteps(samples, start, end, step, rateWindow(vm.config.ScrapeInterval), true)), nil
+}
@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDecodeVMExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []vmExportSeries
+		wantErr bool
+	}{
+		{
+			name: "series per line",
+			body: `{"metric":{"__name__":"container_memory_working_set_bytes","namespace":"shop","pod":"web-0","container":"app"},"values":[1048576,2097152],"timestamps":[1700000000000,1700000060000]}
+{"metric":{"__name__":"container_memory_working_set_bytes","namespace":"shop","pod":"web-1","container":"app"},"values":[0.5],"timestamps":[1700000000000]}
+`,
+			want: []vmExportSeries{
+				{
+					Metric:     map[string]string{"__name__": "container_memory_working_set_bytes", "namespace": "shop", "pod": "web-0", "container": "app"},
+					Values:     []float64{1048576, 2097152},
+					Timestamps: []int64{1700000000000, 1700000060000},
+				},
+				{
+					Metric:     map[string]string{"__name__": "container_memory_working_set_bytes", "namespace": "shop", "pod": "web-1", "container": "app"},
+					Values:     []float64{0.5},
+					Timestamps: []int64{1700000000000},
+				},
+			},
+		},
+		{name: "empty", body: ""},
+		{name: "truncated line", body: `{"metric":{"pod":"web-0"},"values":[1`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeVMExport(strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeVMExport() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeVMExport() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// exportLine returns an export response line of a container series with samples every minute
+// from start, its values computed by value from the sample index
+func exportLine(start time.Time, pod string, samples int, value func(i int) float64) string {
+	values := make([]string, samples)
+	timestamps := make([]string, samples)
+	for i := range samples {
+		values[i] = fmt.Sprint(value(i))
+		timestamps[i] = fmt.Sprint(start.Add(time.Duration(i) * time.Minute).UnixMilli())
+	}
+	return `{"metric":{"namespace":"shop","pod":"` + pod + `","container":"app"},"values":[` +
+		strings.Join(values, ",") + `],"timestamps":[` + strings.Join(timestamps, ",") + "]}\n"
+}
+
+func TestVMExportHistoricalMetrics(t *testing.T) {
+	const window = time.Hour
+	exportStart := time.Now().Add(-window - 10*time.Minute)
+	samples := int((window+20*time.Minute)/time.Minute) + 1
+
+	tests := []struct {
+		name            string
+		exportStatus    int
+		wantRangeQuery  bool
+		wantCPU         float64
+		wantMemory      float64
+		wantMemoryLimit float64
+	}{
+		{"export", http.StatusOK, false, 0.5, 100 << 20, 256 << 20},
+		{"export unavailable", http.StatusNotFound, true, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exports, rangeQueries atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v1/export":
+					exports.Add(1)
+					if tt.exportStatus != http.StatusOK {
+						http.Error(w, "unsupported path", tt.exportStatus)
+						return
+					}
+					match := r.URL.Query().Get("match[]")
+					switch {
+					case strings.Contains(match, "container_cpu_usage_seconds_total"):
+						// A counter of half a core
+						w.Write([]byte(exportLine(exportStart, "web-0", samples, func(i int) float64 { return float64(i) * 30 })))
+					case strings.Contains(match, "container_memory_working_set_bytes"):
+						// A shorter duplicate series is ignored
+						w.Write([]byte(exportLine(exportStart, "web-0", 3, func(int) float64 { return 1 << 30 })))
+						w.Write([]byte(exportLine(exportStart, "web-0", samples, func(int) float64 { return 100 << 20 })))
+					case strings.Contains(match, "limits") && strings.Contains(match, `resource="memory"`):
+						w.Write([]byte(exportLine(exportStart, "web-0", samples, func(int) float64 { return 256 << 20 })))
+					}
+				case "/api/v1/query_range":
+					rangeQueries.Add(1)
+					w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+				default:
+					result := "[]"
+					if strings.HasPrefix(r.URL.Query().Get("query"), "group by") {
+						result = `[{"metric":{"namespace":"shop","pod":"web-0","container":"app"},"value":[1700000000,"1"]}]`
+					}
+					w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":` + result + `}}`))
+				}
+			}))
+			defer server.Close()
+			vm := newTestVMClient(t, server, MetricsClientConfig{VMUseExport: true})
+
+			metrics, err := vm.GetHistoricalMetrics(context.Background(), "shop", HistoricalOptions{Window: window})
+			if err != nil {
+				t.Fatalf("GetHistoricalMetrics() error = %v", err)
+			}
+			if len(metrics) != 1 {
+				t.Fatalf("GetHistoricalMetrics() = %d containers, want 1", len(metrics))
+			}
+			if got := exports.Load(); tt.exportStatus == http.StatusOK && got != 6 {
+				t.Errorf("exports = %d, want one per series kind", got)
+			}
+			if got := rangeQueries.Load() > 0; got != tt.wantRangeQuery {
+				t.Errorf("range queries run = %v, want %v", got, tt.wantRangeQuery)
+			}
+
+			hm := metrics[0]
+			if math.Abs(hm.CPU.Average-tt.wantCPU) > 1e-9 {
+				t.Errorf("CPU average = %v, want %v", hm.CPU.Average, tt.wantCPU)
+			}
+			if hm.Memory.Average != tt.wantMemory || hm.Memory.Peak != tt.wantMemory {
+				t.Errorf("memory average, peak = %v, %v, want %v", hm.Memory.Average, hm.Memory.Peak, tt.wantMemory)
+			}
+			if tt.wantMemoryLimit == 0 {
+				if len(hm.Memory.Limits) != 0 {
+					t.Errorf("memory limits = %d points, want none", len(hm.Memory.Limits))
+				}
+				return
+			}
+			if len(hm.Memory.Limits) == 0 || len(hm.Memory.Limits) != len(hm.Memory.Usage) {
+				t.Fatalf("memory limits, usage = %d, %d points, want the same number", len(hm.Memory.Limits), len(hm.Memory.Usage))
+			}
+			for _, point := range hm.Memory.Limits {
+				if point.Value != tt.wantMemoryLimit {
+					t.Fatalf("memory limit = %v, want %v", point.Value, tt.wantMemoryLimit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// WarningKubeStateMetricsMissing is reported when no kube-state-metrics series were found, so
+// resource requests and limits are missing from the response
+const WarningKubeStateMetricsMissing = "kube-state-metrics data unavailable: resource requests and limits are not reported"
+
+// WarningPodSpecsUnavailable is reported when the metrics-server backend could not list pods, so
+// resource requests and limits are missing from the response
+const WarningPodSpecsUnavailable = "pod specs unavailable: resource requests and limits are not reported"
+
+// errKubeStateMetricsMissing is returned when no request or limit series exist for any container
+var errKubeStateMetricsMissing = errors.New("no kube-state-metrics request or limit series found")
+
+// QueryWarnings collects backend warnings (e.g. partial results or hit sample limits) for a single request
+type QueryWarnings struct {
+	mu       sync.Mutex
+	seen     map[string]bool
+	warnings []string
+}
+
+type queryWarningsKey struct{}
+
+// WithQueryWarnings returns a context that records backend warnings into the returned collector
+func WithQueryWarnings(ctx context.Context) (context.Context, *QueryWarnings) {
+	warnings := &QueryWarnings{seen: make(map[string]bool)}
+	return context.WithValue(ctx, queryWarningsKey{}, warnings), warnings
+}
+
+// Warnings returns the distinct warnings in the order they were first recorded
+func (w *QueryWarnings) Warnings() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.warnings...)
+}
+
+// Add records warnings that were not recorded before, e.g. when replaying a cached response
+func (w *QueryWarnings) Add(warnings ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, warning := range warnings {
+		if !w.seen[warning] {
+			w.seen[warning] = true
+			w.warnings = append(w.warnings, warning)
+		}
+	}
+}
+
+// recordWarnings adds warnings to the collector in ctx, if any
+func recordWarnings(ctx context.Context, warnings []string) {
+	collector, ok := ctx.Value(queryWarningsKey{}).(*QueryWarnings)
+	if !ok || len(warnings) == 0 {
+		return
+	}
+	collector.Add(warnings...)
+}
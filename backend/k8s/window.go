@@ -0,0 +1,26 @@
+package k8s
+
+import (
+	"time"
+)
+
+// DefaultAnalysisWindow is the window of the historical analysis when none is requested
+const DefaultAnalysisWindow = 7 * 24 * time.Hour
+
+// HistoricalOptions selects what the historical analysis covers
+type HistoricalOptions struct {
+	// Window is how far back from now the analysis looks, DefaultAnalysisWindow when zero
+	Window time.Duration
+
+	// IncludeCompleted also covers pods that completed during the window, e.g. finished Jobs,
+	// instead of only pods still running at its end
+	IncludeCompleted bool
+}
+
+// analysisWindow returns window, or DefaultAnalysisWindow when it is unset
+func analysisWindow(window time.Duration) time.Duration {
+	if window > 0 {
+		return window
+	}
+	return DefaultAnalysisWindow
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging writes logs to out, routing every log line through slog's JSON handler when
+// LOG_FORMAT is json and keeping the plain text output otherwise
+func setupLogging(out io.Writer) {
+	log.SetOutput(out)
+	format := os.Getenv("LOG_FORMAT")
+	switch format {
+	case "", "text":
+		return
+	case "json":
+	default:
+		log.Printf("WARN: Invalid value for LOG_FORMAT: %s, using default: text", format)
+		return
+	}
+
+	// Debug lines are printed in text mode too, so keep them
+	logger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	slog.SetDefault(logger)
+
+	// Existing log.Printf sites keep working, with the level taken from their message prefix
+	log.SetFlags(0)
+	log.SetOutput(&slogWriter{logger: logger})
+}
+
+// slogWriter logs each line written by the standard logger as a slog record
+type slogWriter struct {
+	logger *slog.Logger
+}
+
+func (w *slogWriter) Write(p []byte) (int, error) {
+	level, message := logLevel(strings.TrimSuffix(string(p), "\n"))
+	w.logger.Log(context.Background(), level, message)
+	return len(p), nil
+}
+
+// logLevelPrefixes maps the level prefixes used in log messages to their slog level
+var logLevelPrefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"DEBUG:", slog.LevelDebug},
+	{"INFO:", slog.LevelInfo},
+	{"WARN:", slog.LevelWarn},
+	{"Warning:", slog.LevelWarn},
+}
+
+// logLevel returns the level of a log message and the message without its level prefix.
+// Messages starting with "Error" or "Failed" are errors, others without a prefix are info.
+func logLevel(message string) (slog.Level, string) {
+	for _, p := range logLevelPrefixes {
+		if strings.HasPrefix(message, p.prefix) {
+			return p.level, strings.TrimSpace(strings.TrimPrefix(message, p.prefix))
+		}
+	}
+	if strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "Failed") {
+		return slog.LevelError, message
+	}
+	return slog.LevelInfo, message
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLogging runs setupLogging with format, returning its output and restoring the
+// standard and slog default loggers afterwards
+func captureLogging(t *testing.T, format string) *bytes.Buffer {
+	t.Helper()
+	output, flags, logger := log.Writer(), log.Flags(), slog.Default()
+	t.Cleanup(func() {
+		// slog.SetDefault redirects the standard logger, so restore it last
+		slog.SetDefault(logger)
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	})
+
+	t.Setenv("LOG_FORMAT", format)
+	var buf bytes.Buffer
+	setupLogging(&buf)
+	return &buf
+}
+
+func TestSetupLoggingJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		wantLevel string
+		wantMsg   string
+	}{
+		{"plain message", "Starting server on port 8080", "INFO", "Starting server on port 8080"},
+		{"info prefix", "INFO: Cache refreshed", "INFO", "Cache refreshed"},
+		{"debug prefix", "DEBUG: Query took 12ms", "DEBUG", "Query took 12ms"},
+		{"warn prefix", "WARN: Invalid value for FLOAT_PRECISION: x, using default: 2", "WARN", "Invalid value for FLOAT_PRECISION: x, using default: 2"},
+		{"warning prefix", "Warning: no metrics for pod", "WARN", "no metrics for pod"},
+		{"error message", "Error fetching pod metrics from prometheus: timeout", "ERROR", "Error fetching pod metrics from prometheus: timeout"},
+		{"failed message", "Failed to create handler: bad config", "ERROR", "Failed to create handler: bad config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLogging(t, "json")
+			log.Print(tt.message)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("log output %q is not valid JSON: %v", buf.String(), err)
+			}
+			if _, ok := record["time"]; !ok {
+				t.Errorf("log record %v has no time", record)
+			}
+			if record["level"] != tt.wantLevel {
+				t.Errorf("level = %v, want %s", record["level"], tt.wantLevel)
+			}
+			if record["msg"] != tt.wantMsg {
+				t.Errorf("msg = %v, want %q", record["msg"], tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestSetupLoggingJSONFields(t *testing.T) {
+	buf := captureLogging(t, "json")
+	slog.Info("request served", "path", "/api/pods", "status", 200)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log output %q is not valid JSON: %v", buf.String(), err)
+	}
+	if record["path"] != "/api/pods" || record["status"] != float64(200) {
+		t.Errorf("log record %v is missing its fields", record)
+	}
+}
+
+func TestSetupLoggingText(t *testing.T) {
+	for _, format := range []string{"", "text", "xml"} {
+		t.Run(format, func(t *testing.T) {
+			buf := captureLogging(t, format)
+			buf.Reset() // drop the warning for an invalid format
+			log.Print("Starting server on port 8080")
+
+			line := strings.TrimSpace(buf.String())
+			if !strings.HasSuffix(line, "Starting server on port 8080") || json.Valid([]byte(line)) {
+				t.Errorf("log output = %q, want a plain text line", line)
+			}
+		})
+	}
+}
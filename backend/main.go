@@ -1,31 +1,73 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/bean-stalk-k8s/backend/handlers"
 )
 
+// defaultReadTimeout bounds reading a request, including headers
+const defaultReadTimeout = 15 * time.Second
+
 func main() {
+	// Emit structured logs when LOG_FORMAT=json
+	setupLogging(os.Stderr)
+
 	// Create a new handler
 	handler, err := handlers.NewHandler()
 	if err != nil {
 		log.Fatalf("Failed to create handler: %v", err)
 	}
 
+	// Background work stops when the process is asked to shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start background alerting (no-op unless ENABLE_ALERTS or ENABLE_THRESHOLD_EXPORT is set)
+	handler.StartAlerts(ctx)
+
+	// Start background cache refresh (no-op unless METRICS_ENABLE_CACHING is set)
+	handler.StartCacheRefresh(ctx)
+
 	// Create a new router
 	mux := http.NewServeMux()
 
-	// Register routes
+	// Register routes, JSON-only endpoints negotiate the format like the ones serving CSV or NDJSON
 	mux.HandleFunc("/health", handler.Health)
-	mux.HandleFunc("/api/namespaces", handler.GetNamespaces)
-	mux.HandleFunc("/api/pods", handler.GetPodMetrics)
+	mux.HandleFunc("/readyz", handler.Ready)
+	mux.HandleFunc("/api/clusters", handlers.NegotiateJSON(handler.GetClusters))
+	mux.HandleFunc("/api/namespaces", handlers.NegotiateJSON(handler.GetNamespaces))
+	mux.HandleFunc("/api/namespaces/coverage", handlers.NegotiateJSON(handler.GetScrapeCoverage))
+	mux.HandleFunc("/api/labels", handlers.NegotiateJSON(handler.GetLabels))
+	mux.HandleFunc("/api/labels/{name}/values", handlers.NegotiateJSON(handler.GetLabelValues))
+	mux.HandleFunc("/api/pods", handlers.NegotiateJSON(handler.GetPodMetrics))
 	mux.HandleFunc("/api/pods/analysis", handler.GetHistoricalAnalysis)
-	mux.HandleFunc("/api/pods/trends", handler.GetPodTrends)
-	mux.HandleFunc("/api/pods/summary", handler.GetPodSummary)
+	mux.HandleFunc("/api/pods/analysis/diff", handlers.NegotiateJSON(handler.GetAnalysisDiff))
+	mux.HandleFunc("/api/pods/analysis/jobs", handlers.NegotiateJSON(handler.StartAnalysisJob))
+	mux.HandleFunc("GET /api/pods/analysis/jobs/{id}", handlers.NegotiateJSON(handler.GetAnalysisJob))
+	mux.HandleFunc("/api/pods/trends", handlers.NegotiateJSON(handler.GetPodTrends))
+	mux.HandleFunc("/api/pods/series", handlers.NegotiateJSON(handler.GetPodSeries))
+	mux.HandleFunc("/api/pods/summary", handlers.NegotiateJSON(handler.GetPodSummary))
+	mux.HandleFunc("/api/pods/idle", handlers.NegotiateJSON(handler.GetIdlePods))
+	mux.HandleFunc("/api/pods/export", handler.GetExport)
+	mux.HandleFunc("/api/pods/recommendations", handler.GetPodRecommendations)
+	mux.HandleFunc("/api/pods/{namespace}/{pod}", handlers.NegotiateJSON(handler.GetPodDetail))
+	mux.HandleFunc("/api/pods/containers/{namespace}/{pod}", handlers.NegotiateJSON(handler.GetPodContainers))
+	mux.HandleFunc("/api/cluster/capacity", handlers.NegotiateJSON(handler.GetClusterCapacity))
+	mux.HandleFunc("/api/cluster/packing", handlers.NegotiateJSON(handler.GetClusterPacking))
+	mux.HandleFunc("/api/nodes", handlers.NegotiateJSON(handler.GetNodes))
+	mux.HandleFunc("/api/owners/summary", handlers.NegotiateJSON(handler.GetOwnerSummary))
+	mux.HandleFunc("/api/query", handlers.NegotiateJSON(handler.RawQuery))
+	mux.HandleFunc("/api/debug/queries", handlers.NegotiateJSON(handler.GetQueryPreview))
+	mux.HandleFunc("/api/pods/compare-backends", handlers.NegotiateJSON(handler.CompareBackends))
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
@@ -34,14 +76,61 @@ func main() {
 	}
 
 	// Create server
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: handlers.EnableCORS(mux),
-	}
+	server := newServer(fmt.Sprintf(":%s", port), handlers.EnableCORS(handlers.ParseRequestParams(handler.Authenticate(mux))))
 
 	// Start server
-	log.Printf("Starting server on port %s", port)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	log.Printf("Starting server on port %s (read timeout %s, write timeout %s, idle timeout %s)", port, server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Serve profiles on the admin port (no-op unless ENABLE_PPROF is set)
+	pprofServer := startPprofServer()
+
+	// Wait for a shutdown signal and drain in-flight requests
+	<-ctx.Done()
+	log.Printf("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if pprofServer != nil {
+		pprofServer.Close()
+	}
+}
+
+// newServer returns the API server of addr with the timeouts set in the environment.
+// Connection lifetimes are bounded to protect against slow clients. The write timeout must
+// exceed the longest handler timeout (historical analysis at 30s); streaming responses
+// clear their own write deadline.
+func newServer(addr string, handler http.Handler) *http.Server {
+	readTimeout := getDurationEnv("SERVER_READ_TIMEOUT", defaultReadTimeout)
+	writeTimeout := getDurationEnv("SERVER_WRITE_TIMEOUT", 60*time.Second)
+	idleTimeout := getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second)
+	if writeTimeout > 0 && writeTimeout <= 30*time.Second {
+		log.Printf("WARN: SERVER_WRITE_TIMEOUT (%s) is not longer than the 30s analysis timeout, analysis responses may be cut off", writeTimeout)
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// getDurationEnv returns the environment variable as a duration or the default if not set/invalid
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+		log.Printf("WARN: Invalid duration value for %s: %s, using default: %s", key, value, defaultValue)
 	}
+	return defaultValue
 }
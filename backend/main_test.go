@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name                string
+		read, write, idle   string
+		wantRead, wantWrite time.Duration
+		wantIdle            time.Duration
+	}{
+		{"defaults", "", "", "", defaultReadTimeout, 60 * time.Second, 120 * time.Second},
+		{"configured", "5s", "2m", "30s", 5 * time.Second, 2 * time.Minute, 30 * time.Second},
+		{"invalid values use defaults", "soon", "-", "1 minute", defaultReadTimeout, 60 * time.Second, 120 * time.Second},
+		{"zero disables a timeout", "0s", "0s", "0s", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SERVER_READ_TIMEOUT", tt.read)
+			t.Setenv("SERVER_WRITE_TIMEOUT", tt.write)
+			t.Setenv("SERVER_IDLE_TIMEOUT", tt.idle)
+
+			server := newServer(":8080", http.NotFoundHandler())
+			if server.ReadTimeout != tt.wantRead || server.ReadHeaderTimeout != tt.wantRead {
+				t.Errorf("read timeouts = %s, %s, want %s", server.ReadTimeout, server.ReadHeaderTimeout, tt.wantRead)
+			}
+			if server.WriteTimeout != tt.wantWrite {
+				t.Errorf("write timeout = %s, want %s", server.WriteTimeout, tt.wantWrite)
+			}
+			if server.IdleTimeout != tt.wantIdle {
+				t.Errorf("idle timeout = %s, want %s", server.IdleTimeout, tt.wantIdle)
+			}
+		})
+	}
+}
+
+func TestNewServerSlowClient(t *testing.T) {
+	t.Setenv("SERVER_READ_TIMEOUT", "200ms")
+	server := newServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		pause  time.Duration // Pause before finishing the request headers
+		wantOK bool
+	}{
+		{"fast client", 0, true},
+		{"slow client within the read timeout", 50 * time.Millisecond, true},
+		{"slow client past the read timeout", 500 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Fatalf("net.Dial() error = %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example\r\n"); err != nil {
+				t.Fatalf("write request line error = %v", err)
+			}
+			time.Sleep(tt.pause)
+			// The server may already have closed the connection of a client that is too slow
+			io.WriteString(conn, "\r\n")
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if !tt.wantOK {
+				if err == nil {
+					defer resp.Body.Close()
+					if resp.StatusCode == http.StatusOK {
+						t.Errorf("status = %d, want the slow request rejected", resp.StatusCode)
+					}
+					return
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					t.Errorf("connection still open past the read timeout")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadResponse() error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "ok" {
+				t.Errorf("response = %d %q, want 200 ok", resp.StatusCode, body)
+			}
+		})
+	}
+}
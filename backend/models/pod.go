@@ -12,6 +12,14 @@ type PodMetrics struct {
 	CPU           ResourceMetrics   `json:"cpu"`
 	Memory        ResourceMetrics   `json:"memory"`
 	Labels        map[string]string `json:"labels,omitempty"`
+	RestartCount  int               `json:"restartCount"`
+	OOMKilled     bool              `json:"oomKilled"` // Last termination was an OOMKill
+	CreatedAt     *time.Time        `json:"createdAt,omitempty"` // Pod start time, unset when kube-state-metrics doesn't report it
+	LastSeen      *time.Time        `json:"lastSeen,omitempty"`  // Timestamp of the newest usage sample
+	Stale         bool              `json:"stale,omitempty"`     // Newest usage sample is older than STALE_THRESHOLD
+	QoSClass      string            `json:"qosClass,omitempty"`  // Guaranteed, Burstable or BestEffort, derived from requests and limits
+	// Memory usage exceeds MEMORY_PRESSURE_THRESHOLD percent of the limit, risking an OOMKill
+	MemoryPressure bool `json:"memoryPressure"`
 }
 
 // ResourceMetrics represents resource usage, requests, and limits
@@ -25,7 +33,24 @@ type ResourceMetrics struct {
 	// Percentage of request that's being used (usage/request * 100)
 	RequestPercentage float64 `json:"requestPercentage"`
 	// Percentage of limit that's being used (usage/limit * 100)
-	LimitPercentage float64 `json:"limitPercentage,omitempty"`
+	LimitPercentage float64 `json:"limitPercentage"`
+	// Whether a request/limit is set, to distinguish unset from zero
+	HasRequest bool `json:"hasRequest"`
+	HasLimit   bool `json:"hasLimit"`
+	// Percentage of the limit left above the 7-day P95 usage, omitted without a limit or historical data
+	HeadroomPercent *float64 `json:"headroomPercent,omitempty"`
+}
+
+// ClusterInfo describes a configured cluster
+type ClusterInfo struct {
+	Name    string `json:"name"`
+	Backend string `json:"backend"` // Metrics backend type, e.g. prometheus
+	Default bool   `json:"default"` // Used when no cluster parameter is given
+}
+
+// ClusterList represents the list of configured clusters
+type ClusterList struct {
+	Clusters []ClusterInfo `json:"clusters"`
 }
 
 // NamespaceList represents a list of available namespaces
@@ -33,9 +58,75 @@ type NamespaceList struct {
 	Namespaces []string `json:"namespaces"`
 }
 
+// BackendProbe is the result of the cheap backend query run by the health check
+type BackendProbe struct {
+	Status      string     `json:"status"` // ok or failed
+	LatencyMs   float64    `json:"latencyMs"`
+	CheckedAt   time.Time  `json:"checkedAt"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// LabelList represents the label names of pod metrics
+type LabelList struct {
+	Labels []string `json:"labels"`
+}
+
+// LabelValueList represents the values of a pod metrics label
+type LabelValueList struct {
+	Label  string   `json:"label"`
+	Values []string `json:"values"`
+}
+
 // PodMetricsList represents a list of pod metrics
 type PodMetricsList struct {
-	Pods []PodMetrics `json:"pods"`
+	Pods          []PodMetrics `json:"pods"`
+	DataTimestamp *time.Time   `json:"dataTimestamp,omitempty"` // Newest sample across all pods
+	Stale         bool         `json:"stale"`                   // Newest sample is older than the stale threshold
+	Warnings      []string     `json:"warnings,omitempty"`      // Backend warnings, data may be incomplete
+}
+
+// ContainerDetail represents a container's metrics together with its status
+type ContainerDetail struct {
+	PodMetrics
+	LastTerminationReason string `json:"lastTerminationReason,omitempty"` // e.g. OOMKilled, Error, Completed
+}
+
+// PodDetail represents the metrics and status of a single pod
+type PodDetail struct {
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace"`
+	Phase      string            `json:"phase"` // Running, Pending, Succeeded, Failed or Unknown
+	Containers []ContainerDetail `json:"containers"`
+}
+
+// PodContainers represents a pod's containers together with their pod-level rollup
+type PodContainers struct {
+	Name       string          `json:"name"`
+	Namespace  string          `json:"namespace"`
+	CPU        ResourceMetrics `json:"cpu"`    // Summed across containers
+	Memory     ResourceMetrics `json:"memory"` // Summed across containers
+	Containers []PodMetrics    `json:"containers"`
+}
+
+// WorkloadMetrics represents metrics summed across the replicas of a workload container
+type WorkloadMetrics struct {
+	Name               string          `json:"name"` // Pod name with the replica suffix stripped
+	Namespace          string          `json:"namespace"`
+	ContainerName      string          `json:"containerName"`
+	Replicas           int             `json:"replicas"`
+	CPU                ResourceMetrics `json:"cpu"`    // Summed across replicas
+	Memory             ResourceMetrics `json:"memory"` // Summed across replicas
+	AverageCPUUsage    float64         `json:"averageCpuUsage"`    // Per replica, in cores
+	AverageMemoryUsage float64         `json:"averageMemoryUsage"` // Per replica, in bytes
+}
+
+// WorkloadMetricsList represents a list of workload metrics
+type WorkloadMetricsList struct {
+	Workloads     []WorkloadMetrics `json:"workloads"`
+	DataTimestamp *time.Time        `json:"dataTimestamp,omitempty"` // Newest sample across all pods
+	Stale         bool              `json:"stale"`                   // Newest sample is older than the stale threshold
+	Warnings      []string          `json:"warnings,omitempty"`      // Backend warnings, data may be incomplete
 }
 
 // TimeRange represents a time range for historical data
@@ -61,6 +152,8 @@ type HistoricalResourceData struct {
 	P95        float64     `json:"p95"`
 	P99        float64     `json:"p99"`
 	Trend      string      `json:"trend"` // "increasing", "decreasing", "stable"
+	// Percentage of expected usage points (given the step) that were returned
+	DataCompleteness float64 `json:"dataCompleteness"`
 }
 
 // UsagePatterns identifies usage patterns
@@ -98,6 +191,28 @@ type HistoricalMetrics struct {
 	CPU           HistoricalResourceData `json:"cpu"`
 	Memory        HistoricalResourceData `json:"memory"`
 	Analysis      UsageAnalysis          `json:"analysis"`
+	RestartCount  int                    `json:"restartCount"`           // Restarts during the analyzed window
+	OOMKilled     bool                   `json:"oomKilled"`              // OOMKilled during the analyzed window
+	RestartTrend  string                 `json:"restartTrend,omitempty"` // Trend of the hourly restart rate, when enabled
+	Step          string                 `json:"step"`                   // Resolution of the range queries, e.g. "5m0s"
+	StepCoarsened bool                   `json:"stepCoarsened"`          // Step was coarsened to respect MAX_SAMPLES_PER_SERIES
+	Completed     bool                   `json:"completed,omitempty"`    // Pod completed during the window (includeCompleted=true), so it has no current usage
+	CreatedAt     *time.Time             `json:"createdAt,omitempty"`    // Pod start time, unset when kube-state-metrics doesn't report it
+	// Pod started after the beginning of the analysis window, so its recommendations rest on partial history
+	InsufficientHistory bool `json:"insufficientHistory"`
+	// P95 memory usage exceeds MEMORY_PRESSURE_THRESHOLD percent of the average limit
+	MemoryPressure bool `json:"memoryPressure"`
+	// Efficiency compared with the other containers in the namespace, nil in namespaces too small to compare
+	NamespaceComparison *NamespaceComparison `json:"namespaceComparison,omitempty"`
+	// Pods combined into this workload analysis with groupBy=workload, unset otherwise
+	Pods []string `json:"pods,omitempty"`
+}
+
+// NamespaceComparison compares a container's efficiency with the other containers in its namespace
+type NamespaceComparison struct {
+	CPUZScore    float64 `json:"cpuZScore"`    // Standard deviations from the mean CPU efficiency of the others
+	MemoryZScore float64 `json:"memoryZScore"` // Standard deviations from the mean memory efficiency of the others
+	Outlier      bool    `json:"outlier"`      // Either score exceeds OUTLIER_STD_DEVS
 }
 
 // HistoricalAnalysisList represents the response for historical analysis
@@ -106,6 +221,91 @@ type HistoricalAnalysisList struct {
 	GeneratedAt       time.Time           `json:"generatedAt"`
 	TimeRange         TimeRange           `json:"timeRange"`
 	Summary           AnalysisSummary     `json:"summary"`
+	Warnings          []string            `json:"warnings,omitempty"` // Backend warnings, data may be incomplete
+	TotalCount        int                 `json:"totalCount"`         // Containers analyzed, all reflected in the summary
+	ReturnedCount     int                 `json:"returnedCount"`      // Containers returned after the only/minWaste filters
+	// Per-phase backend query durations, only included with ?debug=true
+	Timings map[string]PhaseTiming `json:"timings,omitempty"`
+}
+
+// Analysis job statuses
+const (
+	AnalysisJobRunning = "running"
+	AnalysisJobDone    = "done"
+	AnalysisJobFailed  = "failed"
+)
+
+// AnalysisJob represents a historical analysis running in the background
+type AnalysisJob struct {
+	ID         string                  `json:"id"`
+	Status     string                  `json:"status"` // running, done or failed
+	Namespace  string                  `json:"namespace,omitempty"`
+	CreatedAt  time.Time               `json:"createdAt"`
+	FinishedAt *time.Time              `json:"finishedAt,omitempty"`
+	Error      string                  `json:"error,omitempty"`  // Set when failed
+	Result     *HistoricalAnalysisList `json:"result,omitempty"` // Set when done
+}
+
+// ContainerRecommendations lists the analysis recommendations for one container
+type ContainerRecommendations struct {
+	PodName         string   `json:"podName"`
+	Namespace       string   `json:"namespace"`
+	ContainerName   string   `json:"containerName"`
+	Recommendations []string `json:"recommendations"`
+}
+
+// RecommendedResource compares a container's current request with the recommended one
+type RecommendedResource struct {
+	CurrentRequest          float64 `json:"currentRequest"`
+	Recommended             float64 `json:"recommended"`
+	CurrentRequestFormatted string  `json:"currentRequestFormatted"`
+	RecommendedFormatted    string  `json:"recommendedFormatted"`
+}
+
+// ResourceRecommendation is the recommended CPU and memory request of one container
+type ResourceRecommendation struct {
+	PodName       string              `json:"podName"`
+	Namespace     string              `json:"namespace"`
+	ContainerName string              `json:"containerName"`
+	CPU           RecommendedResource `json:"cpu"`
+	Memory        RecommendedResource `json:"memory"`
+}
+
+// ResourceRecommendationList represents the response for request recommendations
+type ResourceRecommendationList struct {
+	Algorithm             string                   `json:"algorithm"`             // average or vpa
+	CPUHeadroomPercent    float64                  `json:"cpuHeadroomPercent"`    // Added on top of the estimated CPU usage
+	MemoryHeadroomPercent float64                  `json:"memoryHeadroomPercent"` // Added on top of the estimated memory usage
+	Recommendations       []ResourceRecommendation `json:"recommendations"`
+	GeneratedAt           time.Time                `json:"generatedAt"`
+	Warnings              []string                 `json:"warnings,omitempty"`
+}
+
+// AnalysisChange describes a container whose analysis changed since the baseline run
+type AnalysisChange struct {
+	PodName                  string  `json:"podName"`
+	Namespace                string  `json:"namespace"`
+	ContainerName            string  `json:"containerName"`
+	PreviousClassification   string  `json:"previousClassification"` // over_provisioned, under_provisioned or well_optimized
+	Classification           string  `json:"classification"`
+	PreviousCPUEfficiency    float64 `json:"previousCpuEfficiency"`
+	CPUEfficiency            float64 `json:"cpuEfficiency"`
+	PreviousMemoryEfficiency float64 `json:"previousMemoryEfficiency"`
+	MemoryEfficiency         float64 `json:"memoryEfficiency"`
+}
+
+// AnalysisDiff represents the changes between the previous and the current analysis run
+type AnalysisDiff struct {
+	BaselineAt  *time.Time       `json:"baselineAt,omitempty"` // Previous run, unset when there was none
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Threshold   float64          `json:"threshold"` // Efficiency change in percentage points that is reported
+	Changes     []AnalysisChange `json:"changes"`
+}
+
+// PhaseTiming reports the accumulated duration of a query or analysis phase
+type PhaseTiming struct {
+	TotalMs float64 `json:"totalMs"`
+	Count   int     `json:"count"` // Number of calls, e.g. one per container
 }
 
 // AnalysisSummary provides aggregate insights across all analyzed pods
@@ -115,18 +315,51 @@ type AnalysisSummary struct {
 	UnderProvisionedPods     int     `json:"underProvisionedPods"`
 	WellOptimizedPods        int     `json:"wellOptimizedPods"`
 	AverageEfficiency        float64 `json:"averageEfficiency"`
+	// Efficiency weighted by each pod's average request, so large pods dominate
+	WeightedCPUEfficiency    float64 `json:"weightedCpuEfficiency"`
+	WeightedMemoryEfficiency float64 `json:"weightedMemoryEfficiency"`
+	WeightedEfficiency       float64 `json:"weightedEfficiency"`
+	OutlierPods              int     `json:"outlierPods"` // Containers flagged as outliers within their namespace
 	TotalRecommendations     int     `json:"totalRecommendations"`
 	MostCommonRecommendation string  `json:"mostCommonRecommendation"`
+	MostCommonCategory       string  `json:"mostCommonCategory"`
+	// Number of recommendations per category (see Recommendation* constants)
+	RecommendationBreakdown map[string]int     `json:"recommendationBreakdown"`
+	EfficiencyHistogram     []EfficiencyBucket `json:"efficiencyHistogram"`
+}
+
+// EfficiencyBucket counts pods whose efficiency falls in [Min, Max)
+type EfficiencyBucket struct {
+	Label  string   `json:"label"`
+	Min    float64  `json:"min"`
+	Max    *float64 `json:"max,omitempty"` // nil for the open-ended last bucket
+	CPU    int      `json:"cpu"`           // Pods with CPU efficiency in this bucket
+	Memory int      `json:"memory"`        // Pods with memory efficiency in this bucket
 }
 
+// Recommendation categories used to group free-text recommendations
+const (
+	RecommendationReduceCPU        = "reduce_cpu"
+	RecommendationIncreaseCPU      = "increase_cpu"
+	RecommendationReduceMemory     = "reduce_memory"
+	RecommendationIncreaseMemory   = "increase_memory"
+	RecommendationCPUTrendingUp    = "cpu_trending_up"
+	RecommendationMemoryTrendingUp = "memory_trending_up"
+	RecommendationOOMKilled        = "oom_killed"
+	RecommendationWellOptimized    = "well_optimized"
+	RecommendationOther            = "other"
+)
+
 // PodTrendAnalysis represents detailed trend analysis for a specific pod
 type PodTrendAnalysis struct {
 	PodName      string              `json:"podName"`
 	Namespace    string              `json:"namespace"`
 	Containers   []HistoricalMetrics `json:"containers"`
-	DaysAnalyzed int                 `json:"daysAnalyzed"`
+	DaysAnalyzed int                 `json:"daysAnalyzed"` // Days of the analyzed window, rounded up
+	Range        string              `json:"range"`        // Analyzed window, e.g. "168h0m0s" or "1h30m0s"
 	GeneratedAt  time.Time           `json:"generatedAt"`
 	Summary      PodTrendSummary     `json:"summary"`
+	Note         string              `json:"note,omitempty"` // Why the analysis is empty, when the pod exists but lacks datapoints
 }
 
 // PodTrendSummary provides summary insights for pod trend analysis
@@ -139,12 +372,250 @@ type PodTrendSummary struct {
 
 // PodSummaryResponse provides summary statistics for all pods
 type PodSummaryResponse struct {
-	TotalPods         int     `json:"totalPods"`
-	AverageCPUUsage   float64 `json:"averageCpuUsage"`
-	AverageMemoryUsage float64 `json:"averageMemoryUsage"`
-	HighCPUPods       int     `json:"highCpuPods"`       // >80% usage
-	HighMemoryPods    int     `json:"highMemoryPods"`    // >80% usage
-	LowCPUPods        int     `json:"lowCpuPods"`        // <40% usage
-	LowMemoryPods     int     `json:"lowMemoryPods"`     // <40% usage
-	GeneratedAt       time.Time `json:"generatedAt"`
+	TotalPods              int                  `json:"totalPods"`
+	AverageCPUUsage        float64              `json:"averageCpuUsage"`
+	AverageCPUUsageUnit    string               `json:"averageCpuUsageUnit"` // Always SummaryUnitPercentOfRequest
+	AverageMemoryUsage     float64              `json:"averageMemoryUsage"`
+	AverageMemoryUsageUnit string               `json:"averageMemoryUsageUnit"` // Always SummaryUnitPercentOfRequest
+	HighCPUPods            int                  `json:"highCpuPods"`            // >80% usage
+	HighMemoryPods         int                  `json:"highMemoryPods"`         // >80% usage
+	LowCPUPods             int                  `json:"lowCpuPods"`             // <40% usage
+	LowMemoryPods          int                  `json:"lowMemoryPods"`          // <40% usage
+	MemoryPressurePods     int                  `json:"memoryPressurePods"`     // Memory usage above MEMORY_PRESSURE_THRESHOLD of the limit
+	Formatted              *PodSummaryFormatted `json:"formatted,omitempty"`    // Only with a supported Accept-Language
+	GeneratedAt            time.Time            `json:"generatedAt"`
+}
+
+// SummaryUnitPercentOfRequest is the unit of averages of usage as a percentage of the requests
+const SummaryUnitPercentOfRequest = "percent_of_request"
+
+// PodSummaryFormatted is the summary figures formatted for display in the requested locale
+type PodSummaryFormatted struct {
+	Locale             string `json:"locale"`
+	TotalPods          string `json:"totalPods"`
+	AverageCPUUsage    string `json:"averageCpuUsage"`
+	AverageMemoryUsage string `json:"averageMemoryUsage"`
 }
+
+
+// ResourceCapacity compares committed and used resources against allocatable capacity
+type ResourceCapacity struct {
+	Allocatable        float64 `json:"allocatable"`
+	Requests           float64 `json:"requests"`
+	Limits             float64 `json:"limits"`
+	Usage              float64 `json:"usage"`
+	RequestsPercentage float64 `json:"requestsPercentage"` // requests/allocatable * 100
+	LimitsPercentage   float64 `json:"limitsPercentage"`   // limits/allocatable * 100
+	UsagePercentage    float64 `json:"usagePercentage"`    // usage/allocatable * 100
+	Headroom           float64 `json:"headroom"`           // allocatable - requests
+}
+
+// ClusterCapacityResponse provides cluster-wide capacity versus usage
+type ClusterCapacityResponse struct {
+	NodeCount   int              `json:"nodeCount"`
+	CPU         ResourceCapacity `json:"cpu"`
+	Memory      ResourceCapacity `json:"memory"`
+	GeneratedAt time.Time        `json:"generatedAt"`
+}
+
+// ClusterPackingResponse estimates the nodes that could be freed by right-sizing pods to their P95 usage,
+// from first-fit packings of the scheduled pods onto the nodes
+type ClusterPackingResponse struct {
+	NodeCount             int       `json:"nodeCount"`
+	PodCount              int       `json:"podCount"`
+	NodesNeeded           int       `json:"nodesNeeded"`           // Nodes used when packing pods at their current requests
+	NodesNeededRightSized int       `json:"nodesNeededRightSized"` // Nodes used when packing pods at their P95 usage
+	ReclaimableNodeCount  int       `json:"reclaimableNodeCount"`
+	ReclaimableNodes      []string  `json:"reclaimableNodes"`     // Nodes left empty by the right-sized packing
+	UnplacedPods          int       `json:"unplacedPods"`         // Right-sized pods no node had room for
+	RightSizedContainers  int       `json:"rightSizedContainers"` // Containers with P95 usage, others keep their requests
+	CPURequests           float64   `json:"cpuRequests"`          // cores
+	CPURightSized         float64   `json:"cpuRightSized"`        // cores
+	MemoryRequests        float64   `json:"memoryRequests"`       // bytes
+	MemoryRightSized      float64   `json:"memoryRightSized"`     // bytes
+	GeneratedAt           time.Time `json:"generatedAt"`
+}
+
+// OwnerSummary aggregates the historical analysis of the pods sharing an owner label value
+type OwnerSummary struct {
+	Owner                   string  `json:"owner"` // Owner label value, "unattributed" for pods without it
+	PodCount                int     `json:"podCount"`
+	ContainerCount          int     `json:"containerCount"`
+	AverageCPUEfficiency    float64 `json:"averageCpuEfficiency"`
+	AverageMemoryEfficiency float64 `json:"averageMemoryEfficiency"`
+	AverageEfficiency       float64 `json:"averageEfficiency"`
+	WastedCPU               float64 `json:"wastedCpu"`    // Requested but unused cores, summed over containers
+	WastedMemory            float64 `json:"wastedMemory"` // Requested but unused bytes, summed over containers
+	OverProvisionedPods     int     `json:"overProvisionedPods"`
+	UnderProvisionedPods    int     `json:"underProvisionedPods"`
+}
+
+// OwnerSummaryList represents the response for the owner summary
+type OwnerSummaryList struct {
+	OwnerLabel  string         `json:"ownerLabel"`
+	Owners      []OwnerSummary `json:"owners"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+}
+
+// ContainerSeries holds the raw datapoints of a single container metric
+type ContainerSeries struct {
+	PodName       string      `json:"podName"`
+	Namespace     string      `json:"namespace"`
+	ContainerName string      `json:"containerName"`
+	Metric        string      `json:"metric"` // cpu (cores) or memory (bytes)
+	Days          int         `json:"days"`
+	Points        []DataPoint `json:"points"`
+	GeneratedAt   time.Time   `json:"generatedAt"`
+}
+
+// QueryPreview lists the PromQL queries the backend would run, for debugging differences between backends
+type QueryPreview struct {
+	Backend       string   `json:"backend"`
+	Namespace     string   `json:"namespace,omitempty"`
+	PodName       string   `json:"podName,omitempty"`
+	ContainerName string   `json:"containerName,omitempty"`
+	Current       []string `json:"current"`    // Instant queries of the current pod metrics
+	Historical    []string `json:"historical"` // Queries of the historical analysis, per container only with pod and container
+}
+
+// NodeSummary compares the requests, limits and usage of a node's pods with its allocatable capacity
+type NodeSummary struct {
+	Name     string           `json:"name"`
+	PodCount int              `json:"podCount"`
+	CPU      ResourceCapacity `json:"cpu"`
+	Memory   ResourceCapacity `json:"memory"`
+}
+
+// NodeList represents the response for the node list
+type NodeList struct {
+	Nodes       []NodeSummary `json:"nodes"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+}
+
+// NamespaceCoverage compares the running pods of a namespace with the pods the metrics backend has
+// usage for
+type NamespaceCoverage struct {
+	Namespace       string   `json:"namespace"`
+	PodCount        int      `json:"podCount"`        // Running pods listed by the Kubernetes API
+	ScrapedPodCount int      `json:"scrapedPodCount"` // Running pods with usage metrics
+	CoveragePercent float64  `json:"coveragePercent"`
+	UnscrapedPods   []string `json:"unscrapedPods"`
+}
+
+// ScrapeCoverage represents the response for the scrape coverage endpoint
+type ScrapeCoverage struct {
+	Namespaces      []NamespaceCoverage `json:"namespaces"`
+	PodCount        int                 `json:"podCount"`
+	ScrapedPodCount int                 `json:"scrapedPodCount"`
+	CoveragePercent float64             `json:"coveragePercent"`
+	GeneratedAt     time.Time           `json:"generatedAt"`
+}
+
+// IdlePod represents a container whose usage is below the idle thresholds
+type IdlePod struct {
+	Name                    string  `json:"name"`
+	Namespace               string  `json:"namespace"`
+	ContainerName           string  `json:"containerName"`
+	CPUMillicores           float64 `json:"cpuMillicores"`
+	MemoryUsage             float64 `json:"memoryUsage"`             // bytes
+	MemoryRequest           float64 `json:"memoryRequest"`           // bytes
+	MemoryRequestPercentage float64 `json:"memoryRequestPercentage"` // 0 when no memory request is set
+}
+
+// IdlePodsResponse lists idle pods and the thresholds used to find them
+type IdlePodsResponse struct {
+	Pods                       []IdlePod `json:"pods"`
+	Historical                 bool      `json:"historical"` // Based on the 7-day average instead of the current snapshot
+	MaxCPUMillicores           float64   `json:"maxCpuMillicores"`
+	MaxMemoryRequestPercentage float64   `json:"maxMemoryRequestPercentage"`
+	GeneratedAt                time.Time `json:"generatedAt"`
+}
+
+// RawQuerySample represents a single sample of a raw instant query
+type RawQuerySample struct {
+	Metric    map[string]string `json:"metric"`
+	Value     float64           `json:"value"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// RawQueryResponse represents the result of a raw instant query
+type RawQueryResponse struct {
+	Query      string           `json:"query"`
+	Result     []RawQuerySample `json:"result"`
+	Truncated  bool             `json:"truncated"` // Result exceeded the series limit
+	ExecutedAt time.Time        `json:"executedAt"`
+}
+
+// PodAlert describes a container that became over- or under-provisioned
+type PodAlert struct {
+	Pod              string   `json:"pod"`
+	Namespace        string   `json:"namespace"`
+	Container        string   `json:"container"`
+	Status           string   `json:"status"` // "over_provisioned" or "under_provisioned"
+	CPUEfficiency    float64  `json:"cpuEfficiency"`
+	MemoryEfficiency float64  `json:"memoryEfficiency"`
+	Recommendations  []string `json:"recommendations"`
+}
+
+// AlertPayload is posted to the alert webhook. Text makes it usable as a Slack incoming webhook message.
+type AlertPayload struct {
+	Text        string     `json:"text"`
+	Alerts      []PodAlert `json:"alerts"`
+	GeneratedAt time.Time  `json:"generatedAt"`
+}
+
+// ThresholdCrossing is a container resource whose current usage crosses its ALERT_CPU_PERCENT or
+// ALERT_MEM_PERCENT threshold
+type ThresholdCrossing struct {
+	Pod       string  `json:"pod"`
+	Namespace string  `json:"namespace"`
+	Container string  `json:"container"`
+	Resource  string  `json:"resource"`  // "cpu" or "memory"
+	Usage     float64 `json:"usage"`     // cores or bytes
+	Reference float64 `json:"reference"` // The limit, or the request without a limit; cores or bytes
+	Basis     string  `json:"basis"`     // "limit" or "request"
+	Percent   float64 `json:"percent"`   // usage/reference * 100
+	Threshold float64 `json:"threshold"` // Crossed threshold, in percent
+}
+
+// ThresholdExport is written to the threshold export sink, posted to a webhook or appended to a file as a JSON line
+type ThresholdExport struct {
+	Text        string              `json:"text"`
+	Crossings   []ThresholdCrossing `json:"crossings"`
+	GeneratedAt time.Time           `json:"generatedAt"`
+}
+
+// BackendComparison is the per-container difference between the current metrics of two backends
+type BackendComparison struct {
+	Backends          []string                  `json:"backends"` // Compared backends, in the order of each difference's values
+	Namespace         string                    `json:"namespace,omitempty"`
+	TolerancePercent  float64                   `json:"tolerancePercent"`
+	ContainerCount    int                       `json:"containerCount"` // Containers reported by both backends
+	MatchingCount     int                       `json:"matchingCount"`  // Containers with every value within the tolerance
+	Discrepancies     []BackendDiscrepancy      `json:"discrepancies"`
+	MissingContainers []BackendMissingContainer `json:"missingContainers"` // Containers reported by one backend only
+	GeneratedAt       time.Time                 `json:"generatedAt"`
+}
+
+// BackendDiscrepancy is a container with values differing by more than the tolerance between backends
+type BackendDiscrepancy struct {
+	Namespace string             `json:"namespace"`
+	Pod       string             `json:"pod"`
+	Container string             `json:"container"`
+	Fields    []BackendFieldDiff `json:"fields"`
+}
+
+// BackendFieldDiff is a value differing by more than the tolerance between backends
+type BackendFieldDiff struct {
+	Field             string    `json:"field"`             // e.g. "cpuUsage" or "memoryLimit"
+	Values            []float64 `json:"values"`            // Per backend; cores or bytes
+	DifferencePercent float64   `json:"differencePercent"` // Relative to the larger value
+}
+
+// BackendMissingContainer is a container reported by only one of the compared backends
+type BackendMissingContainer struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Backend   string `json:"backend"` // The backend reporting it
+}
\ No newline at end of file
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+)
+
+// startPprofServer serves the net/http/pprof profiles under /debug/pprof/ on PPROF_ADDR when
+// ENABLE_PPROF is set, returning nil otherwise or when PPROF_ADDR cannot be listened on. The profiles
+// are served on their own admin listener, outside the CORS and authentication middleware of the API.
+func startPprofServer() *http.Server {
+	enabled, _ := strconv.ParseBool(os.Getenv("ENABLE_PPROF"))
+	if !enabled {
+		return nil
+	}
+	addr := os.Getenv("PPROF_ADDR")
+	if addr == "" {
+		addr = "localhost:6060"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Error serving pprof: %v", err)
+		return nil
+	}
+
+	// No write timeout, since CPU profiles and traces stream for their requested duration
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: getDurationEnv("SERVER_READ_TIMEOUT", defaultReadTimeout),
+	}
+
+	log.Printf("Starting pprof server on %s", server.Addr)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Error serving pprof: %v", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStartPprofServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		enable  string
+		wantRun bool
+	}{
+		{"unset", "", false},
+		{"disabled", "false", false},
+		{"invalid", "maybe", false},
+		{"enabled", "true", true},
+		{"enabled numeric", "1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENABLE_PPROF", tt.enable)
+			t.Setenv("PPROF_ADDR", "127.0.0.1:0")
+
+			server := startPprofServer()
+			if (server != nil) != tt.wantRun {
+				t.Fatalf("startPprofServer() = %v, want running %v", server, tt.wantRun)
+			}
+			if server == nil {
+				return
+			}
+			defer server.Close()
+
+			resp, err := http.Get("http://" + server.Addr + "/debug/pprof/")
+			if err != nil {
+				t.Fatalf("GET /debug/pprof/ error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("GET /debug/pprof/ status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestStartPprofServerListenError(t *testing.T) {
+	t.Setenv("ENABLE_PPROF", "true")
+	t.Setenv("PPROF_ADDR", "127.0.0.1:-1")
+
+	if server := startPprofServer(); server != nil {
+		server.Close()
+		t.Error("startPprofServer() on an invalid address returned a server, want nil")
+	}
+}
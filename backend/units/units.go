@@ -0,0 +1,89 @@
+// Package units formats CPU and memory quantities for display. Formatted values are valid
+// Kubernetes quantities, parseable by resource.ParseQuantity.
+package units
+
+import (
+	"math"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Base selects the memory units: binary (Ki, Mi, Gi - powers of 1024) or decimal (k, M, G - powers of 1000)
+type Base string
+
+const (
+	Binary  Base = "binary"
+	Decimal Base = "decimal"
+)
+
+// ParseBase parses a base name, reporting whether it is valid
+func ParseBase(s string) (Base, bool) {
+	switch Base(s) {
+	case Binary, Decimal:
+		return Base(s), true
+	default:
+		return "", false
+	}
+}
+
+// memoryUnit is a memory unit size with the granularity values of that size are rounded to
+type memoryUnit struct {
+	size int64
+	step int64
+}
+
+// memoryUnits lists the units of each base from largest to smallest. Values of a gigabyte or more
+// keep megabyte precision so that e.g. 1.5Gi formats as "1536Mi" rather than "2Gi".
+var memoryUnits = map[Base][]memoryUnit{
+	Binary: {
+		{1 << 30, 1 << 20},
+		{1 << 20, 1 << 20},
+		{1 << 10, 1 << 10},
+	},
+	Decimal: {
+		{1e9, 1e6},
+		{1e6, 1e6},
+		{1e3, 1e3},
+	},
+}
+
+// memoryFormats maps each base to its quantity format
+var memoryFormats = map[Base]resource.Format{
+	Binary:  resource.BinarySI,
+	Decimal: resource.DecimalSI,
+}
+
+// FormatCPU formats CPU cores as a canonical quantity, e.g. "250m" or "2". Usage below one
+// millicore keeps its precision, e.g. "500u", rather than rounding to "0".
+func FormatCPU(cores float64) string {
+	if cores == 0 || math.IsNaN(cores) || math.IsInf(cores, 0) {
+		return "0"
+	}
+	millicores := math.Round(cores * 1000)
+	if millicores == 0 {
+		return resource.NewScaledQuantity(int64(math.Round(cores*1e9)), resource.Nano).String()
+	}
+	return resource.NewMilliQuantity(int64(millicores), resource.DecimalSI).String()
+}
+
+// FormatMemory formats bytes as a canonical quantity in base, rounded to the largest unit that
+// fits, e.g. "1536Mi" or "512M", or as plain bytes below the smallest unit, e.g. "512".
+// Unknown bases are treated as Binary.
+func FormatMemory(bytes float64, base Base) string {
+	format, ok := memoryFormats[base]
+	if !ok {
+		base, format = Binary, resource.BinarySI
+	}
+	if bytes == 0 || math.IsNaN(bytes) || math.IsInf(bytes, 0) {
+		return "0"
+	}
+
+	rounded := math.Round(bytes)
+	for _, unit := range memoryUnits[base] {
+		if math.Abs(bytes) >= float64(unit.size) {
+			rounded = math.Round(bytes/float64(unit.step)) * float64(unit.step)
+			break
+		}
+	}
+	return resource.NewQuantity(int64(rounded), format).String()
+}
@@ -0,0 +1,108 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestFormatCPU(t *testing.T) {
+	tests := []struct {
+		name      string
+		cores     float64
+		want      string
+		tolerance float64
+	}{
+		{"zero", 0, "0", 0},
+		{"millicores", 0.25, "250m", 0},
+		{"whole cores", 2, "2", 0},
+		{"fractional cores", 1.5, "1500m", 0},
+		{"rounds to millicores", 0.1234, "123m", 0.0005},
+		{"sub-millicore", 0.0004, "400u", 0},
+		{"nanocores", 0.000000123, "123n", 0},
+		{"negative", -0.5, "-500m", 0},
+		{"NaN", math.NaN(), "0", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatCPU(tt.cores)
+			if got != tt.want {
+				t.Errorf("FormatCPU(%v) = %q, want %q", tt.cores, got, tt.want)
+			}
+			assertRoundTrip(t, got, tt.cores, tt.tolerance)
+		})
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	tests := []struct {
+		name      string
+		bytes     float64
+		base      Base
+		want      string
+		tolerance float64
+	}{
+		{"zero", 0, Binary, "0", 0},
+		{"plain bytes", 512, Binary, "512", 0},
+		{"kibibytes", 4096, Binary, "4Ki", 0},
+		{"mebibytes", 512 << 20, Binary, "512Mi", 0},
+		{"gibibytes", 2 << 30, Binary, "2Gi", 0},
+		{"fractional gibibytes", 1.5 * (1 << 30), Binary, "1536Mi", 0},
+		{"rounds to mebibytes", 100<<20 + 1000, Binary, "100Mi", 1 << 19},
+		{"rounds to kibibytes", 10<<10 + 100, Binary, "10Ki", 1 << 9},
+		{"kilobytes", 4000, Decimal, "4k", 0},
+		{"megabytes", 512e6, Decimal, "512M", 0},
+		{"fractional gigabytes", 1.5e9, Decimal, "1500M", 0},
+		{"rounds to megabytes", 256e6 + 1234, Decimal, "256M", 5e5},
+		{"negative", -(256 << 20), Binary, "-256Mi", 0},
+		{"unknown base", 512 << 20, Base("octal"), "512Mi", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatMemory(tt.bytes, tt.base)
+			if got != tt.want {
+				t.Errorf("FormatMemory(%v, %q) = %q, want %q", tt.bytes, tt.base, got, tt.want)
+			}
+			assertRoundTrip(t, got, tt.bytes, tt.tolerance)
+		})
+	}
+}
+
+// assertRoundTrip checks formatted parses back into a Quantity within tolerance of want,
+// allowing for float64 rounding in the conversion
+func assertRoundTrip(t *testing.T, formatted string, want, tolerance float64) {
+	t.Helper()
+	if math.IsNaN(want) {
+		want = 0 // NaN formats as zero
+	}
+	quantity, err := resource.ParseQuantity(formatted)
+	if err != nil {
+		t.Fatalf("resource.ParseQuantity(%q) error = %v", formatted, err)
+	}
+	if got := quantity.AsApproximateFloat64(); math.Abs(got-want) > tolerance+1e-12*math.Abs(want) {
+		t.Errorf("resource.ParseQuantity(%q) = %v, want %v (±%v)", formatted, got, want, tolerance)
+	}
+}
+
+func TestParseBase(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   Base
+		wantOK bool
+	}{
+		{"binary", Binary, true},
+		{"decimal", Decimal, true},
+		{"", "", false},
+		{"Binary", "", false},
+		{"si", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseBase(tt.input)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseBase(%q) = %q, %v, want %q, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -7,6 +7,9 @@ export interface ResourceMetrics {
   limitValue: number;
   requestPercentage: number;
   limitPercentage: number;
+  hasRequest?: boolean;
+  hasLimit?: boolean;
+  headroomPercent?: number;
 }
 
 export interface PodMetrics {